@@ -38,6 +38,17 @@ type Canal struct {
 	syncer     *replication.BinlogSyncer
 
 	eventHandler EventHandler
+	// dispatcher handles the rows of the binlog with workers, see Config.RowsWorkers
+	dispatcher *rowsDispatcher
+	// txn is the transaction of the binlog read, see Config.BatchTransactions
+	txn *Transaction
+	// snapshotGTIDSet is the GTID set of the snapshot dumped, skipSnapshotted is set while the rows of a transaction
+	// read by the snapshot are skipped, see snapshotted
+	snapshotGTIDSet mysql.GTIDSet
+	skipSnapshotted bool
+	// schemaLoaded is set once the tables are cached from the store of the position or the master, see
+	// seedTrackedSchema
+	schemaLoaded bool
 
 	connLock sync.Mutex
 	conn     *client.Conn
@@ -50,7 +61,15 @@ type Canal struct {
 	includeTableRegex []*regexp.Regexp
 	excludeTableRegex []*regexp.Regexp
 
+	// columnFilters are the filters of Config.ColumnFilters, filteredTables the tables filtered by them
+	columnFilters  []*columnFilter
+	filteredTables map[string]*filteredTable
+
 	delay *uint32
+	stats syncStats
+
+	posSaveLock  sync.Mutex
+	posSavedTime time.Time
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -126,14 +145,18 @@ func NewCanal(cfg *Config) (*Canal, error) {
 		c.tableMatchCache = make(map[string]bool)
 	}
 
+	if err := c.prepareColumnFilters(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
 	return c, nil
 }
 
 func (c *Canal) prepareDumper() error {
 	var err error
 	dumpPath := c.cfg.Dump.ExecutionPath
-	if len(dumpPath) == 0 {
-		// ignore mysqldump, use binlog only
+	if len(dumpPath) == 0 || c.cfg.Dump.Snapshot {
+		// ignore mysqldump, use binlog only or dump with a snapshot
 		return nil
 	}
 
@@ -224,6 +247,20 @@ func (c *Canal) run() error {
 
 	c.master.UpdateTimestamp(uint32(time.Now().Unix()))
 
+	if err := c.checkEventHandler(); err != nil {
+		return errors.Trace(err)
+	}
+
+	if err := c.serveHealth(); err != nil {
+		c.cfg.Logger.Errorf("canal serve health endpoints err: %v", err)
+		return errors.Trace(err)
+	}
+
+	if err := c.loadPosition(); err != nil {
+		c.cfg.Logger.Errorf("canal load position err: %v", err)
+		return errors.Trace(err)
+	}
+
 	if !c.dumped {
 		c.dumped = true
 
@@ -236,6 +273,11 @@ func (c *Canal) run() error {
 		}
 	}
 
+	if err := c.seedTrackedSchema(); err != nil {
+		c.cfg.Logger.Errorf("canal seed tracked schema err: %v", err)
+		return errors.Trace(err)
+	}
+
 	if err := c.runSyncBinlog(); err != nil {
 		if errors.Cause(err) != context.Canceled {
 			c.cfg.Logger.Errorf("canal start sync binlog err: %v", err)
@@ -258,7 +300,11 @@ func (c *Canal) Close() {
 	c.conn = nil
 	c.connLock.Unlock()
 
-	_ = c.eventHandler.OnPosSynced(nil, c.master.Position(), c.master.GTIDSet(), true)
+	pos, gset := c.master.Position(), c.master.GTIDSet()
+	_ = c.eventHandler.OnPosSynced(nil, pos, gset, true)
+	if err := c.savePosition(pos, gset, true); err != nil {
+		c.cfg.Logger.Errorf("canal save position err: %v", err)
+	}
 }
 
 func (c *Canal) WaitDumpDone() <-chan struct{} {
@@ -451,6 +497,7 @@ func (c *Canal) prepareSyncer() error {
 		Logger:                  c.cfg.Logger,
 		Dialer:                  c.cfg.Dialer,
 		Localhost:               c.cfg.Localhost,
+		OnReconnect:             c.onReconnect,
 		RowsEventDecodeFunc: func(event *replication.RowsEvent, data []byte) error {
 			pos, err := event.DecodeHeader(data)
 			if err != nil {
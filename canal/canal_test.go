@@ -157,6 +157,44 @@ func (s *canalTestSuite) TestCanalFilter() {
 	require.Nil(s.T(), sch)
 }
 
+type snapshotEventHandler struct {
+	DummyEventHandler
+	rows []*RowsEvent
+}
+
+func (h *snapshotEventHandler) OnRow(e *RowsEvent) error {
+	h.rows = append(h.rows, e)
+	return nil
+}
+
+func (s *canalTestSuite) TestCanalSnapshot() {
+	cfg := NewDefaultConfig()
+	cfg.Addr = fmt.Sprintf("%s:%s", *test_util.MysqlHost, *test_util.MysqlPort)
+	cfg.User = "root"
+	cfg.Dump.Snapshot = true
+	cfg.Dump.TableDB = "test"
+	cfg.Dump.Tables = []string{"canal_test"}
+
+	c, err := NewCanal(cfg)
+	require.NoError(s.T(), err)
+	defer c.Close()
+
+	h := &snapshotEventHandler{}
+	c.SetEventHandler(h)
+	require.NoError(s.T(), c.Dump())
+
+	r := s.execute("SELECT COUNT(*) FROM test.canal_test")
+	count, err := r.GetInt(0, 0)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), h.rows, int(count))
+	for _, e := range h.rows {
+		require.True(s.T(), e.Snapshot)
+		require.Equal(s.T(), InsertAction, e.Action)
+		require.Len(s.T(), e.Rows[0], len(e.Table.Columns))
+	}
+	require.NotEmpty(s.T(), c.SyncedPosition().Name)
+}
+
 func TestCreateTableExp(t *testing.T) {
 	cases := []string{
 		"CREATE TABLE /*generated by server */ mydb.mytable (`id` int(10)) ENGINE=InnoDB",
@@ -0,0 +1,242 @@
+package canal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pingcap/errors"
+
+	"github.com/atoonk/go-mysql/replication"
+	"github.com/atoonk/go-mysql/schema"
+)
+
+// MaskFunc returns the masked value of a column of a row, see ColumnFilter.
+type MaskFunc func(column *schema.TableColumn, value interface{}) interface{}
+
+// MaskFuncs are the masks named by ColumnFilter.Masks: null replaces the values by NULL and redact by "REDACTED",
+// keeping the NULL values. More masks can be added before creating the canal.
+//
+// The hmac_sha256 mask, replacing the values by the hex HMAC-SHA256 of their text keyed by Config.MaskSecret, is
+// named by ColumnFilter.Masks too, it requires the secret. The columns masked by redact or hmac_sha256 are string
+// columns in the tables of the rows, whatever their type.
+var MaskFuncs = map[string]MaskFunc{
+	"null":   MaskNull,
+	"redact": MaskRedact,
+}
+
+// maskHMACSHA256 is the name of the mask of MaskHMACSHA256 keyed by Config.MaskSecret.
+const maskHMACSHA256 = "hmac_sha256"
+
+// textMasks are the names of the masks replacing the values by strings.
+var textMasks = map[string]bool{"redact": true, maskHMACSHA256: true}
+
+func MaskNull(*schema.TableColumn, interface{}) interface{} {
+	return nil
+}
+
+// MaskHMACSHA256 returns a mask replacing the values by the hex HMAC-SHA256 of their text keyed by secret, keeping
+// the NULL values. Unlike a plain hash, the values of small domains can't be found back without the secret.
+func MaskHMACSHA256(secret []byte) MaskFunc {
+	return func(_ *schema.TableColumn, value interface{}) interface{} {
+		if value == nil {
+			return nil
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(maskText(value)))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+}
+
+func MaskRedact(_ *schema.TableColumn, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return "REDACTED"
+}
+
+func maskText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type columnFilter struct {
+	table   *regexp.Regexp
+	include map[string]bool
+	exclude map[string]bool
+	masks   map[string]MaskFunc
+	// the columns masked by a mask of textMasks
+	textMasked map[string]bool
+}
+
+// filteredTable is a table filtered by a column filter, columns are the indexes of the columns kept in the source
+// table and masks their masks, nil if not masked.
+type filteredTable struct {
+	source  *schema.Table
+	table   *schema.Table
+	columns []int
+	masks   []MaskFunc
+}
+
+func (c *Canal) prepareColumnFilters() error {
+	for _, cfg := range c.cfg.ColumnFilters {
+		reg, err := regexp.Compile(cfg.TableRegex)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		f := &columnFilter{
+			table:      reg,
+			include:    make(map[string]bool),
+			exclude:    make(map[string]bool),
+			masks:      make(map[string]MaskFunc),
+			textMasked: make(map[string]bool),
+		}
+		for _, name := range cfg.IncludeColumns {
+			f.include[strings.ToLower(name)] = true
+		}
+		for _, name := range cfg.ExcludeColumns {
+			f.exclude[strings.ToLower(name)] = true
+		}
+		for name, mask := range cfg.Masks {
+			fn, ok := MaskFuncs[mask]
+			if mask == maskHMACSHA256 {
+				if len(c.cfg.MaskSecret) == 0 {
+					return errors.Errorf("mask %s of column %s of %s requires a mask secret", mask, name, cfg.TableRegex)
+				}
+				fn, ok = MaskHMACSHA256([]byte(c.cfg.MaskSecret)), true
+			}
+			if !ok {
+				return errors.Errorf("unknown mask %s of column %s of %s", mask, name, cfg.TableRegex)
+			}
+			f.masks[strings.ToLower(name)] = fn
+			f.textMasked[strings.ToLower(name)] = textMasks[mask]
+		}
+		for name, fn := range cfg.MaskFuncs {
+			f.masks[strings.ToLower(name)] = fn
+			delete(f.textMasked, strings.ToLower(name))
+		}
+		c.columnFilters = append(c.columnFilters, f)
+	}
+	if len(c.columnFilters) > 0 {
+		c.filteredTables = make(map[string]*filteredTable)
+	}
+	return nil
+}
+
+// newRowsEvent returns the event of rows of a table of the source, with their columns filtered by the column filters.
+func (c *Canal) newRowsEvent(table *schema.Table, action string, rows [][]interface{}, header *replication.EventHeader) *RowsEvent {
+	e := newRowsEvent(table, action, rows, header)
+	e.Source = c.cfg.Source
+	if len(c.columnFilters) == 0 {
+		return e
+	}
+
+	ft := c.filteredTable(table)
+	if ft == nil {
+		return e
+	}
+	e.Table = ft.table
+	for i, row := range e.Rows {
+		filtered := make([]interface{}, len(ft.columns))
+		for j, k := range ft.columns {
+			if k >= len(row) {
+				continue
+			}
+			filtered[j] = row[k]
+			if mask := ft.masks[j]; mask != nil {
+				filtered[j] = mask(&ft.table.Columns[j], row[k])
+			}
+		}
+		e.Rows[i] = filtered
+	}
+	return e
+}
+
+// filteredTable returns a table filtered by the first column filter matching it, nil if none does.
+func (c *Canal) filteredTable(table *schema.Table) *filteredTable {
+	key := table.String()
+	c.tableLock.RLock()
+	ft, ok := c.filteredTables[key]
+	c.tableLock.RUnlock()
+	if ok && ft.source == table {
+		if ft.table == nil {
+			return nil
+		}
+		return ft
+	}
+
+	ft = &filteredTable{source: table}
+	for _, f := range c.columnFilters {
+		if f.table.MatchString(key) {
+			ft.filter(f)
+			break
+		}
+	}
+
+	c.tableLock.Lock()
+	c.filteredTables[key] = ft
+	c.tableLock.Unlock()
+	if ft.table == nil {
+		return nil
+	}
+	return ft
+}
+
+// filter filters the source table with a column filter. The primary key and the indexes are kept only if all their
+// columns are. The columns masked by strings become string columns, so that the encoders of the rows don't expect
+// the values of their original type.
+func (ft *filteredTable) filter(f *columnFilter) {
+	source := ft.source
+	t := &schema.Table{Schema: source.Schema, Name: source.Name}
+	kept := make(map[int]int)
+	for i, column := range source.Columns {
+		name := strings.ToLower(column.Name)
+		if len(f.include) > 0 && !f.include[name] || f.exclude[name] {
+			continue
+		}
+		kept[i] = len(t.Columns)
+		if f.textMasked[name] && column.Type != schema.TYPE_STRING {
+			column = schema.TableColumn{Name: column.Name, Type: schema.TYPE_STRING, RawType: "text"}
+		}
+		t.Columns = append(t.Columns, column)
+		ft.columns = append(ft.columns, i)
+		ft.masks = append(ft.masks, f.masks[name])
+	}
+
+	for _, i := range source.PKColumns {
+		j, ok := kept[i]
+		if !ok {
+			t.PKColumns = nil
+			break
+		}
+		t.PKColumns = append(t.PKColumns, j)
+	}
+	for _, i := range source.UnsignedColumns {
+		if j, ok := kept[i]; ok && !f.textMasked[strings.ToLower(source.Columns[i].Name)] {
+			t.UnsignedColumns = append(t.UnsignedColumns, j)
+		}
+	}
+	for _, index := range source.Indexes {
+		all := true
+		for _, name := range index.Columns {
+			if findColumn(t, name) < 0 {
+				all = false
+				break
+			}
+		}
+		if all {
+			idx := *index
+			t.Indexes = append(t.Indexes, &idx)
+		}
+	}
+	ft.table = t
+}
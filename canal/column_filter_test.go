@@ -0,0 +1,92 @@
+package canal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/schema"
+)
+
+func TestColumnFilters(t *testing.T) {
+	c := &Canal{cfg: &Config{MaskSecret: "secret", ColumnFilters: []ColumnFilter{
+		{
+			TableRegex:     `test\.users`,
+			ExcludeColumns: []string{"Password"},
+			Masks:          map[string]string{"email": "hmac_sha256", "ssn": "null"},
+			MaskFuncs: map[string]MaskFunc{"name": func(_ *schema.TableColumn, v interface{}) interface{} {
+				return v.(string)[:1]
+			}},
+		},
+		{TableRegex: `test\..*`, IncludeColumns: []string{"name", "email"}},
+	}}}
+	require.NoError(t, c.prepareColumnFilters())
+
+	users := &schema.Table{Schema: "test", Name: "users"}
+	for _, name := range []string{"id", "name", "email", "password", "ssn"} {
+		users.AddColumn(name, "varchar(20)", "", "")
+	}
+	users.Indexes = []*schema.Index{{Name: "PRIMARY", Columns: []string{"id"}}, {Name: "pw", Columns: []string{"password"}}}
+	users.PKColumns = []int{0}
+
+	e := c.newRowsEvent(users, UpdateAction, [][]interface{}{
+		{"1", "alice", "a@example.com", "secret", "123"},
+		{"1", "bob", nil, "secret", "456"},
+	}, nil)
+	require.Equal(t, [][]interface{}{
+		{"1", "a", "0607236cc2fc521ca815254262b7014cb54eb5488f266e4777158cc52a33cfe9", nil},
+		{"1", "b", nil, nil},
+	}, e.Rows)
+
+	ta := e.Table
+	require.Equal(t, "test.users", ta.String())
+	require.Len(t, ta.Columns, 4)
+	require.Equal(t, "ssn", ta.Columns[3].Name)
+	require.Equal(t, []int{0}, ta.PKColumns)
+	require.Len(t, ta.Indexes, 1)
+	// the table is filtered once
+	require.Same(t, ta, c.newRowsEvent(users, InsertAction, nil, nil).Table)
+
+	// the primary key is dropped with its columns
+	orders := &schema.Table{Schema: "test", Name: "orders"}
+	for _, name := range []string{"id", "Name", "total"} {
+		orders.AddColumn(name, "varchar(20)", "", "")
+	}
+	orders.PKColumns = []int{0}
+	e = c.newRowsEvent(orders, InsertAction, [][]interface{}{{"1", "x", "2"}}, nil)
+	require.Equal(t, [][]interface{}{{"x"}}, e.Rows)
+	require.Len(t, e.Table.Columns, 1)
+	require.Nil(t, e.Table.PKColumns)
+
+	other := &schema.Table{Schema: "other", Name: "t"}
+	require.Same(t, other, c.newRowsEvent(other, InsertAction, nil, nil).Table)
+
+	c = &Canal{cfg: &Config{ColumnFilters: []ColumnFilter{{TableRegex: ".*", Masks: map[string]string{"a": "unknown"}}}}}
+	require.Error(t, c.prepareColumnFilters())
+
+	// the hmac_sha256 mask requires the secret
+	c = &Canal{cfg: &Config{ColumnFilters: []ColumnFilter{{TableRegex: ".*", Masks: map[string]string{"a": "hmac_sha256"}}}}}
+	require.Error(t, c.prepareColumnFilters())
+}
+
+func TestColumnFilterTextMasks(t *testing.T) {
+	c := &Canal{cfg: &Config{MaskSecret: "secret", ColumnFilters: []ColumnFilter{
+		{TableRegex: `test\.t`, Masks: map[string]string{"id": "redact", "total": "hmac_sha256", "state": "null"}},
+	}}}
+	require.NoError(t, c.prepareColumnFilters())
+	ta := sinkTestTable()
+	ta.AddColumn("total", "bigint(20) unsigned", "", "")
+
+	// the INT and BIGINT UNSIGNED columns masked by strings are string columns, as the encoders expect their values,
+	// the column masked by NULL keeps its type
+	e := c.newRowsEvent(ta, InsertAction, [][]interface{}{{int32(1), "a", int64(1), uint64(2)}}, nil)
+	require.Equal(t, "REDACTED", e.Rows[0][0])
+	require.Equal(t, schema.TableColumn{Name: "id", Type: schema.TYPE_STRING, RawType: "text"}, e.Table.Columns[0])
+	require.Equal(t, schema.TYPE_ENUM, e.Table.Columns[2].Type)
+	require.Equal(t, schema.TableColumn{Name: "total", Type: schema.TYPE_STRING, RawType: "text"}, e.Table.Columns[3])
+	require.Empty(t, e.Table.UnsignedColumns)
+
+	msg, err := NewJSONEncoder("server1").Encode("server1.test.t", e, nil, e.Rows[0])
+	require.NoError(t, err)
+	require.Equal(t, `{"id":"REDACTED"}`, string(msg.Key))
+}
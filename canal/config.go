@@ -48,9 +48,35 @@ type DumpConfig struct {
 
 	// Set extra options
 	ExtraOptions []string `toml:"extra_options"`
+
+	// Snapshot dumps the tables by reading their rows in a consistent snapshot of MySQL instead of with mysqldump,
+	// the rows passed to OnRow as the rows of mysqldump. Databases, Tables, TableDB, IgnoreTables, Where and
+	// SkipMasterData are used as with mysqldump, all the databases but the system ones are dumped by default.
+	// SkipMasterData requires MariaDB or Percona Server, which tell the binlog position of the snapshot.
+	Snapshot bool `toml:"snapshot"`
+}
+
+// ColumnFilter filters and masks the columns of the tables matched by TableRegex, with their database name as
+// IncludeTableRegex. The columns are matched by their name, case-insensitively.
+type ColumnFilter struct {
+	TableRegex string `toml:"table_regex"`
+
+	// IncludeColumns are the columns kept, all of them if empty, but the ExcludeColumns
+	IncludeColumns []string `toml:"include_columns"`
+	ExcludeColumns []string `toml:"exclude_columns"`
+
+	// Masks are the names of the masks of MaskFuncs replacing the values of the columns, by column,
+	// eg, Masks : {"email": "hmac_sha256", "ssn": "null"}
+	Masks map[string]string `toml:"masks"`
+	// MaskFuncs are the functions masking the values of the columns, by column, along with Masks
+	MaskFuncs map[string]MaskFunc `toml:"-"`
 }
 
 type Config struct {
+	// Source names the master, set as the Source of the events of its rows, transactions and schema changes, to tell
+	// apart the masters of a MultiCanal
+	Source string `toml:"source"`
+
 	Addr     string `toml:"addr"`
 	User     string `toml:"user"`
 	Password string `toml:"password"`
@@ -69,9 +95,36 @@ type Config struct {
 	IncludeTableRegex []string `toml:"include_table_regex"`
 	ExcludeTableRegex []string `toml:"exclude_table_regex"`
 
+	// ColumnFilters filter and mask the columns of the rows before they are passed to the handler, with the first
+	// filter matching their table. The tables of the rows filtered have the columns kept only.
+	ColumnFilters []ColumnFilter `toml:"column_filter"`
+	// MaskSecret is the key of the hmac_sha256 mask of the column filters, required by it
+	MaskSecret string `toml:"mask_secret"`
+
 	// discard row event without table meta
 	DiscardNoMetaRowEvent bool `toml:"discard_no_meta_row_event"`
 
+	// RowsWorkers is the number of workers calling OnRow concurrently if greater than 1. The rows of the same primary
+	// key, or of the same table if RowsOrderByTable is set or the table has no primary key, are handled in order by
+	// the same worker, the worker of the hash of the key by RowsHash, FNV-1a by default. The rows are sent to OnRow a
+	// row at a time, or an event at a time if they are ordered by table. The other handler methods are called in
+	// order once the rows before them are handled, OnTableChanged and OnDDL before the rows after them are, and may
+	// be called concurrently with OnRow.
+	RowsWorkers      int                     `toml:"rows_workers"`
+	RowsOrderByTable bool                    `toml:"rows_order_by_table"`
+	RowsHash         func(key []byte) uint64 `toml:"-"`
+
+	// BatchTransactions passes the rows of the binlog to OnTransaction with the transaction which changed them once
+	// it is committed, instead of to OnRow, so that they can be applied atomically. The rows of a transaction are
+	// kept in memory until it is committed. The event handler must implement TransactionHandler.
+	BatchTransactions bool `toml:"batch_transactions"`
+
+	// TrackSchemaFromDDL applies the DDL statements of the binlog to the tables cached instead of fetching them again
+	// from the master, so that the rows of a table are decoded with its schema at their position in the binlog. The
+	// tables are saved with the position by a SchemaPositionStore and restored with it, or else all the tables
+	// matched are fetched from the master when the binlog starts to be synced.
+	TrackSchemaFromDDL bool `toml:"track_schema_from_ddl"`
+
 	Dump DumpConfig `toml:"dump"`
 
 	UseDecimal bool `toml:"use_decimal"`
@@ -89,6 +142,21 @@ type Config struct {
 	// whether disable re-sync for broken connection
 	DisableRetrySync bool `toml:"disable_retry_sync"`
 
+	// PositionStore saves the position and the GTID set synced, loaded by Run to resume from them unless RunFrom or
+	// StartFromGTID is used. The position is saved at every transaction, or once PositionFlushInterval has passed
+	// since it was last saved, and always on the rotations, the DDL and Close.
+	PositionStore         PositionStore `toml:"-"`
+	PositionFlushInterval time.Duration `toml:"position_flush_interval"`
+
+	// Metrics receives the measurements of the sync, the latency of the methods of the event handler being measured
+	// if it is set before SetEventHandler.
+	Metrics Metrics `toml:"-"`
+
+	// HealthAddr is the address, host:port, serving the health endpoints of Canal.HealthHandler while Run runs if set,
+	// the sync being ready once its delay is at most HealthMaxDelay if set.
+	HealthAddr     string        `toml:"health_addr"`
+	HealthMaxDelay time.Duration `toml:"health_max_delay"`
+
 	// Set TLS config
 	TLSConfig *tls.Config
 
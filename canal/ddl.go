@@ -0,0 +1,147 @@
+package canal
+
+import (
+	"github.com/pingcap/tidb/pkg/parser/ast"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/replication"
+	"github.com/atoonk/go-mysql/schema"
+)
+
+// The changes of the tables of SchemaChangeEvent.
+const (
+	CreateTableChange   = "create table"
+	AlterTableChange    = "alter table"
+	RenameTableChange   = "rename table"
+	DropTableChange     = "drop table"
+	TruncateTableChange = "truncate table"
+)
+
+// The changes of the columns of ColumnChange.
+const (
+	AddColumnChange    = "add column"
+	DropColumnChange   = "drop column"
+	ModifyColumnChange = "modify column"
+	ChangeColumnChange = "change column"
+	RenameColumnChange = "rename column"
+)
+
+// ColumnChange is the change of a column by ALTER TABLE.
+type ColumnChange struct {
+	Action string
+	// Name is the name of the column before the change
+	Name string
+	// NewName is the name of the column after a CHANGE or RENAME COLUMN
+	NewName string
+	// Column is the column added, modified or changed, nil for DROP and RENAME COLUMN
+	Column *schema.TableColumn
+}
+
+// SchemaChangeEvent is the change of a table by a DDL statement, passed to the OnSchemaChange of a
+// SchemaChangeHandler.
+type SchemaChangeEvent struct {
+	Action string
+	Schema string
+	Table  string
+	// NewSchema and NewTable are the names of a table renamed, by RENAME TABLE or ALTER TABLE
+	NewSchema string
+	NewTable  string
+	// Columns are the changes of the columns by ALTER TABLE, in order
+	Columns []*ColumnChange
+	// Before is the table before the statement, nil if it was not cached yet
+	Before *schema.Table
+	// After is the table after the statement, nil if it was dropped or can't be fetched. It is the current table of
+	// the master unless Config.TrackSchemaFromDDL is set.
+	After *schema.Table
+
+	Header  *replication.EventHeader
+	NextPos mysql.Position
+	// Query is the statement of the change
+	Query *replication.QueryEvent
+	// Source is the master of the change, see Config.Source
+	Source string
+}
+
+// newSchemaChangeEvents returns the changes of the tables by a statement, with the names of the tables and the
+// columns only, db is the database the statement was executed in.
+func newSchemaChangeEvents(db string, stmt ast.StmtNode) []*SchemaChangeEvent {
+	var events []*SchemaChangeEvent
+	switch t := stmt.(type) {
+	case *ast.CreateTableStmt:
+		e := &SchemaChangeEvent{Action: CreateTableChange}
+		e.Schema, e.Table = tableNameOf(t.Table, db)
+		events = append(events, e)
+	case *ast.AlterTableStmt:
+		e := &SchemaChangeEvent{Action: AlterTableChange}
+		e.Schema, e.Table = tableNameOf(t.Table, db)
+		for _, spec := range t.Specs {
+			switch spec.Tp {
+			case ast.AlterTableAddColumns:
+				for _, def := range spec.NewColumns {
+					e.addColumnChange(AddColumnChange, def.Name.Name.O, "", def)
+				}
+			case ast.AlterTableDropColumn:
+				e.addColumnChange(DropColumnChange, spec.OldColumnName.Name.O, "", nil)
+			case ast.AlterTableModifyColumn:
+				def := spec.NewColumns[0]
+				e.addColumnChange(ModifyColumnChange, def.Name.Name.O, "", def)
+			case ast.AlterTableChangeColumn:
+				def := spec.NewColumns[0]
+				e.addColumnChange(ChangeColumnChange, spec.OldColumnName.Name.O, def.Name.Name.O, def)
+			case ast.AlterTableRenameColumn:
+				e.addColumnChange(RenameColumnChange, spec.OldColumnName.Name.O, spec.NewColumnName.Name.O, nil)
+			case ast.AlterTableRenameTable:
+				e.NewSchema, e.NewTable = tableNameOf(spec.NewTable, e.Schema)
+			}
+		}
+		events = append(events, e)
+	case *ast.RenameTableStmt:
+		for _, tt := range t.TableToTables {
+			e := &SchemaChangeEvent{Action: RenameTableChange}
+			e.Schema, e.Table = tableNameOf(tt.OldTable, db)
+			e.NewSchema, e.NewTable = tableNameOf(tt.NewTable, db)
+			events = append(events, e)
+		}
+	case *ast.DropTableStmt:
+		for _, table := range t.Tables {
+			e := &SchemaChangeEvent{Action: DropTableChange}
+			e.Schema, e.Table = tableNameOf(table, db)
+			events = append(events, e)
+		}
+	case *ast.TruncateTableStmt:
+		e := &SchemaChangeEvent{Action: TruncateTableChange}
+		e.Schema, e.Table = tableNameOf(t.Table, db)
+		events = append(events, e)
+	}
+	return events
+}
+
+func (e *SchemaChangeEvent) addColumnChange(action string, name string, newName string, def *ast.ColumnDef) {
+	change := &ColumnChange{Action: action, Name: name, NewName: newName}
+	if def != nil {
+		column := newTableColumn(def)
+		change.Column = &column
+	}
+	e.Columns = append(e.Columns, change)
+}
+
+// afterTable returns the table after a change, see SchemaChangeEvent.After.
+func (c *Canal) afterTable(e *SchemaChangeEvent) *schema.Table {
+	if e.Action == DropTableChange {
+		return nil
+	}
+	db, table := e.Schema, e.Table
+	if e.NewTable != "" {
+		db, table = e.NewSchema, e.NewTable
+	}
+	if c.cfg.TrackSchemaFromDDL {
+		if t := c.cachedTable(db, table); t != nil {
+			return t
+		}
+	}
+	t, err := c.GetTable(db, table)
+	if err != nil {
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,59 @@
+package canal
+
+import (
+	"testing"
+
+	"github.com/pingcap/tidb/pkg/parser"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/schema"
+)
+
+func TestSchemaChangeEvents(t *testing.T) {
+	pr := parser.New()
+	parse := func(query string) []*SchemaChangeEvent {
+		stmts, _, err := pr.Parse(query, "", "")
+		require.NoError(t, err)
+		require.Len(t, stmts, 1)
+		return newSchemaChangeEvents("mydb", stmts[0])
+	}
+
+	events := parse("ALTER TABLE `t` ADD COLUMN c1 INT UNSIGNED AFTER id, DROP COLUMN c2, " +
+		"MODIFY c3 VARCHAR(10), CHANGE c4 c5 DATETIME, RENAME COLUMN c6 TO c7, RENAME TO other.t2")
+	require.Len(t, events, 1)
+	e := events[0]
+	require.Equal(t, AlterTableChange, e.Action)
+	require.Equal(t, "mydb", e.Schema)
+	require.Equal(t, "t", e.Table)
+	require.Equal(t, "other", e.NewSchema)
+	require.Equal(t, "t2", e.NewTable)
+	require.Len(t, e.Columns, 5)
+
+	require.Equal(t, AddColumnChange, e.Columns[0].Action)
+	require.Equal(t, "c1", e.Columns[0].Name)
+	require.Equal(t, schema.TYPE_NUMBER, e.Columns[0].Column.Type)
+	require.True(t, e.Columns[0].Column.IsUnsigned)
+	require.Equal(t, &ColumnChange{Action: DropColumnChange, Name: "c2"}, e.Columns[1])
+	require.Equal(t, ModifyColumnChange, e.Columns[2].Action)
+	require.Equal(t, uint(10), e.Columns[2].Column.MaxSize)
+	require.Equal(t, ChangeColumnChange, e.Columns[3].Action)
+	require.Equal(t, "c4", e.Columns[3].Name)
+	require.Equal(t, "c5", e.Columns[3].NewName)
+	require.Equal(t, schema.TYPE_DATETIME, e.Columns[3].Column.Type)
+	require.Equal(t, &ColumnChange{Action: RenameColumnChange, Name: "c6", NewName: "c7"}, e.Columns[4])
+
+	events = parse("RENAME TABLE a TO b, db2.c TO db3.d")
+	require.Len(t, events, 2)
+	require.Equal(t, &SchemaChangeEvent{Action: RenameTableChange, Schema: "mydb", Table: "a", NewSchema: "mydb", NewTable: "b"}, events[0])
+	require.Equal(t, &SchemaChangeEvent{Action: RenameTableChange, Schema: "db2", Table: "c", NewSchema: "db3", NewTable: "d"}, events[1])
+
+	events = parse("DROP TABLE IF EXISTS a, db2.b")
+	require.Len(t, events, 2)
+	require.Equal(t, &SchemaChangeEvent{Action: DropTableChange, Schema: "db2", Table: "b"}, events[1])
+
+	require.Equal(t, []*SchemaChangeEvent{{Action: CreateTableChange, Schema: "mydb", Table: "a"}},
+		parse("CREATE TABLE a (id INT)"))
+	require.Equal(t, []*SchemaChangeEvent{{Action: TruncateTableChange, Schema: "mydb", Table: "a"}},
+		parse("TRUNCATE TABLE a"))
+	require.Empty(t, parse("CREATE DATABASE db4"))
+}
@@ -0,0 +1,218 @@
+package canal
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pingcap/errors"
+)
+
+// rowsDispatcher calls OnRow from a pool of workers, see Config.RowsWorkers. The rows of the same key are handled
+// in order by the same worker, and the other handler calls are made, in order, once the rows before them are
+// handled, by the last worker reaching them. The fences are the calls the workers wait for before handling the
+// next rows.
+type rowsDispatcher struct {
+	workers []chan rowsTask
+	hash    func(key []byte) uint64
+	wg      sync.WaitGroup
+
+	failOnce sync.Once
+	// closed once a task failed, err is its error
+	failed chan struct{}
+	err    error
+}
+
+// rowsTask is a task of a worker, the call of the handler of rows, or a barrier called by the last worker reaching
+// it if pending is set, which the other workers wait for if done is set.
+type rowsTask struct {
+	fn      func() error
+	pending *int32
+	done    chan struct{}
+}
+
+// rowsQueueSize is the number of tasks queued for a worker.
+const rowsQueueSize = 256
+
+func newRowsDispatcher(workers int, queueSize int, hash func(key []byte) uint64) *rowsDispatcher {
+	if hash == nil {
+		hash = fnvHash
+	}
+	d := &rowsDispatcher{
+		workers: make([]chan rowsTask, workers),
+		hash:    hash,
+		failed:  make(chan struct{}),
+	}
+	for i := range d.workers {
+		d.workers[i] = make(chan rowsTask, queueSize)
+		d.wg.Add(1)
+		go d.run(d.workers[i])
+	}
+	return d
+}
+
+func fnvHash(key []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(key)
+	return h.Sum64()
+}
+
+func (d *rowsDispatcher) run(tasks chan rowsTask) {
+	defer d.wg.Done()
+
+	for task := range tasks {
+		if d.isFailed() {
+			// drain the tasks left
+			continue
+		}
+		if task.pending != nil && atomic.AddInt32(task.pending, -1) > 0 {
+			if task.done != nil {
+				select {
+				case <-task.done:
+				case <-d.failed:
+				}
+			}
+			continue
+		}
+		if err := task.fn(); err != nil {
+			d.fail(err)
+		}
+		if task.done != nil {
+			close(task.done)
+		}
+	}
+}
+
+func (d *rowsDispatcher) fail(err error) {
+	d.failOnce.Do(func() {
+		d.err = err
+		close(d.failed)
+	})
+}
+
+func (d *rowsDispatcher) isFailed() bool {
+	select {
+	case <-d.failed:
+		return true
+	default:
+		return false
+	}
+}
+
+// dispatch queues the call of the handler of rows of a key, and returns the error of a task failed before.
+func (d *rowsDispatcher) dispatch(key []byte, fn func() error) error {
+	if d.isFailed() {
+		return d.err
+	}
+	select {
+	case d.workers[d.hash(key)%uint64(len(d.workers))] <- rowsTask{fn: fn}:
+		return nil
+	case <-d.failed:
+		return d.err
+	}
+}
+
+// barrier queues a call made once the rows queued before are handled, before the rows queued after if fence is set,
+// and returns the error of a task failed before.
+func (d *rowsDispatcher) barrier(fn func() error, fence bool) error {
+	task := rowsTask{fn: fn, pending: new(int32)}
+	*task.pending = int32(len(d.workers))
+	if fence {
+		task.done = make(chan struct{})
+	}
+	if d.isFailed() {
+		return d.err
+	}
+	for _, tasks := range d.workers {
+		select {
+		case tasks <- task:
+		case <-d.failed:
+			return d.err
+		}
+	}
+	return nil
+}
+
+// stop waits for the tasks queued to be done, or a task to fail, and stops the workers.
+func (d *rowsDispatcher) stop() error {
+	for _, tasks := range d.workers {
+		close(tasks)
+	}
+	d.wg.Wait()
+
+	if d.isFailed() {
+		return d.err
+	}
+	return nil
+}
+
+// rowsKey returns the key of the rows ordered, the table, and their primary key unless the rows are ordered by
+// table. It returns nil if the rows change their primary key.
+func (c *Canal) rowsKey(e *RowsEvent) []byte {
+	key := []byte(e.Table.String())
+	if c.cfg.RowsOrderByTable || len(e.Table.PKColumns) == 0 {
+		return key
+	}
+
+	var pk []interface{}
+	for _, row := range e.Rows {
+		values, err := e.Table.GetPKValues(row)
+		if err != nil {
+			return key
+		}
+		if pk != nil && fmt.Sprint(values) != fmt.Sprint(pk) {
+			return nil
+		}
+		pk = values
+	}
+	return append(key, fmt.Sprintf("%v", pk)...)
+}
+
+// handle calls fn now, or once the rows queued before are handled if the rows are handled by workers.
+func (c *Canal) handle(fn func() error) error {
+	if c.dispatcher == nil {
+		return fn()
+	}
+	return errors.Trace(c.dispatcher.barrier(fn, false))
+}
+
+// handleFenced calls fn as handle does, the rows queued after are handled once fn returned.
+func (c *Canal) handleFenced(fn func() error) error {
+	if c.dispatcher == nil {
+		return fn()
+	}
+	return errors.Trace(c.dispatcher.barrier(fn, true))
+}
+
+// handleRows calls OnRow now, or queues the call of each row of the event if the rows are handled by workers.
+func (c *Canal) handleRows(e *RowsEvent) error {
+	if c.dispatcher == nil {
+		return c.eventHandler.OnRow(e)
+	}
+	if c.cfg.RowsOrderByTable || len(e.Table.PKColumns) == 0 {
+		return errors.Trace(c.dispatcher.dispatch(c.rowsKey(e), func() error { return c.eventHandler.OnRow(e) }))
+	}
+
+	n := 1
+	if e.Action == UpdateAction {
+		// the rows before and after the update
+		n = 2
+	}
+	for i := 0; i+n <= len(e.Rows); i += n {
+		row := *e
+		row.Rows = e.Rows[i : i+n]
+
+		var err error
+		if key := c.rowsKey(&row); key == nil {
+			// the rows of both keys are handled first
+			err = c.dispatcher.barrier(func() error { return c.eventHandler.OnRow(&row) }, true)
+		} else {
+			err = c.dispatcher.dispatch(key, func() error { return c.eventHandler.OnRow(&row) })
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
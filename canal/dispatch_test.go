@@ -0,0 +1,94 @@
+package canal
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/schema"
+)
+
+type rowsRecorder struct {
+	DummyEventHandler
+
+	m    sync.Mutex
+	rows map[int64][]int64
+	err  error
+}
+
+func (h *rowsRecorder) OnRow(e *RowsEvent) error {
+	time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+
+	h.m.Lock()
+	defer h.m.Unlock()
+	for _, row := range e.Rows {
+		id := row[0].(int64)
+		h.rows[id] = append(h.rows[id], row[1].(int64))
+	}
+	return h.err
+}
+
+func TestRowsDispatcher(t *testing.T) {
+	ta := &schema.Table{Schema: "test", Name: "t"}
+	ta.AddColumn("id", "bigint", "", "")
+	ta.AddColumn("v", "bigint", "", "")
+	ta.PKColumns = []int{0}
+
+	h := &rowsRecorder{rows: make(map[int64][]int64)}
+	c := &Canal{cfg: &Config{}, eventHandler: h}
+	c.dispatcher = newRowsDispatcher(4, rowsQueueSize, nil)
+
+	var synced []int
+	for i := 0; i < 100; i++ {
+		rows := make([][]interface{}, 0, 10)
+		for id := int64(0); id < 10; id++ {
+			rows = append(rows, []interface{}{id, int64(i)})
+		}
+		require.NoError(t, c.handleRows(&RowsEvent{Table: ta, Action: InsertAction, Rows: rows}))
+
+		i := i
+		require.NoError(t, c.handle(func() error {
+			h.m.Lock()
+			defer h.m.Unlock()
+			// the rows before are handled, the rows after may be
+			handled := len(h.rows[0])
+			for id := int64(1); id < 10; id++ {
+				if len(h.rows[id]) < handled {
+					handled = len(h.rows[id])
+				}
+			}
+			synced = append(synced, handled-i-1)
+			return nil
+		}))
+	}
+	// an update changing the primary key is handled after the rows of both keys
+	require.NoError(t, c.handleRows(&RowsEvent{Table: ta, Action: UpdateAction, Rows: [][]interface{}{
+		{int64(0), int64(99)}, {int64(10), int64(100)},
+	}}))
+	require.NoError(t, c.dispatcher.stop())
+
+	for id := int64(0); id < 10; id++ {
+		for i := 0; i < 100; i++ {
+			require.Equal(t, int64(i), h.rows[id][i])
+		}
+	}
+	// the row before the update is the last of its key
+	require.Equal(t, []int64{99}, h.rows[0][100:])
+	require.Len(t, synced, 100)
+	for _, v := range synced {
+		require.GreaterOrEqual(t, v, 0)
+	}
+	require.Equal(t, []int64{100}, h.rows[10])
+
+	// the error of the handler is returned by the next rows queued
+	h.err = errors.New("handle rows error")
+	c.dispatcher = newRowsDispatcher(2, rowsQueueSize, nil)
+	require.NoError(t, c.handleRows(&RowsEvent{Table: ta, Action: InsertAction, Rows: [][]interface{}{{int64(0), int64(0)}}}))
+	require.Eventually(t, c.dispatcher.isFailed, time.Second, time.Millisecond)
+	require.Error(t, c.handleRows(&RowsEvent{Table: ta, Action: InsertAction, Rows: [][]interface{}{{int64(1), int64(0)}}}))
+	require.EqualError(t, c.dispatcher.stop(), "handle rows error")
+}
@@ -3,10 +3,12 @@ package canal
 import (
 	"encoding/hex"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/atoonk/go-mysql/dump"
 	"github.com/atoonk/go-mysql/mysql"
 	"github.com/atoonk/go-mysql/schema"
 	"github.com/pingcap/errors"
@@ -52,65 +54,139 @@ func (h *dumpParseHandler) Data(db string, table string, values []string) error
 		return errors.Trace(err)
 	}
 
+	vs, err := h.c.dumpRow(tableInfo, values)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	events := h.c.newRowsEvent(tableInfo, InsertAction, [][]interface{}{vs}, nil)
+	events.Snapshot = true
+	return h.c.eventHandler.OnRow(events)
+}
+
+// dumpRow converts the values of a row parsed from a dump to the values of the rows of the binlog: the numbers of
+// their column type, the strings unquoted, the binary strings quoted with _binary as bytes, the hex literals as
+// strings, and the bits as integers.
+func (c *Canal) dumpRow(tableInfo *schema.Table, values []string) ([]interface{}, error) {
+	if len(values) > len(tableInfo.Columns) {
+		return nil, fmt.Errorf("parse row %v error, at most %d columns expected", values, len(tableInfo.Columns))
+	}
+
 	vs := make([]interface{}, len(values))
 
 	for i, v := range values {
-		if v == "NULL" {
+		column := &tableInfo.Columns[i]
+		if v == "" {
+			return nil, fmt.Errorf("parse row %v error, empty value at %d", values, i)
+		} else if v == "NULL" {
 			vs[i] = nil
-		} else if v == "_binary ''" {
-			vs[i] = []byte{}
+		} else if strings.HasPrefix(v, "_binary '") {
+			vs[i] = []byte(v[len("_binary '") : len(v)-1])
+		} else if column.Type == schema.TYPE_BIT && v[0] != '\'' {
+			n, err := dumpBits(v)
+			if err != nil {
+				return nil, fmt.Errorf("parse row %v at %d error %v, bit literal expected", values, i, err)
+			}
+			vs[i] = n
 		} else if v[0] != '\'' {
-			if tableInfo.Columns[i].Type == schema.TYPE_NUMBER || tableInfo.Columns[i].Type == schema.TYPE_MEDIUM_INT {
+			if column.Type == schema.TYPE_NUMBER || column.Type == schema.TYPE_MEDIUM_INT {
 				var n interface{}
 				var err error
 
-				if tableInfo.Columns[i].IsUnsigned {
+				if column.IsUnsigned {
 					n, err = strconv.ParseUint(v, 10, 64)
 				} else {
 					n, err = strconv.ParseInt(v, 10, 64)
 				}
 
 				if err != nil {
-					return fmt.Errorf("parse row %v at %d error %v, int expected", values, i, err)
+					return nil, fmt.Errorf("parse row %v at %d error %v, int expected", values, i, err)
 				}
 
 				vs[i] = n
-			} else if tableInfo.Columns[i].Type == schema.TYPE_FLOAT {
+			} else if column.Type == schema.TYPE_FLOAT {
 				f, err := strconv.ParseFloat(v, 64)
 				if err != nil {
-					return fmt.Errorf("parse row %v at %d error %v, float expected", values, i, err)
+					return nil, fmt.Errorf("parse row %v at %d error %v, float expected", values, i, err)
 				}
 				vs[i] = f
-			} else if tableInfo.Columns[i].Type == schema.TYPE_DECIMAL {
-				if h.c.cfg.UseDecimal {
+			} else if column.Type == schema.TYPE_DECIMAL {
+				if c.cfg.UseDecimal {
 					d, err := decimal.NewFromString(v)
 					if err != nil {
-						return fmt.Errorf("parse row %v at %d error %v, decimal expected", values, i, err)
+						return nil, fmt.Errorf("parse row %v at %d error %v, decimal expected", values, i, err)
 					}
 					vs[i] = d
 				} else {
 					f, err := strconv.ParseFloat(v, 64)
 					if err != nil {
-						return fmt.Errorf("parse row %v at %d error %v, float expected", values, i, err)
+						return nil, fmt.Errorf("parse row %v at %d error %v, float expected", values, i, err)
 					}
 					vs[i] = f
 				}
-			} else if strings.HasPrefix(v, "0x") {
-				buf, err := hex.DecodeString(v[2:])
+			} else if buf, ok, err := dumpHex(v); ok {
 				if err != nil {
-					return fmt.Errorf("parse row %v at %d error %v, hex literal expected", values, i, err)
+					return nil, fmt.Errorf("parse row %v at %d error %v, hex literal expected", values, i, err)
 				}
 				vs[i] = string(buf)
 			} else {
-				return fmt.Errorf("parse row %v error, invalid type at %d", values, i)
+				return nil, fmt.Errorf("parse row %v error, invalid type at %d", values, i)
 			}
 		} else {
 			vs[i] = v[1 : len(v)-1]
 		}
 	}
 
-	events := newRowsEvent(tableInfo, InsertAction, [][]interface{}{vs}, nil)
-	return h.c.eventHandler.OnRow(events)
+	return vs, nil
+}
+
+// dumpHex decodes a hex literal, 0x... or X'...', and returns false if v is not one.
+func dumpHex(v string) ([]byte, bool, error) {
+	if strings.HasPrefix(v, "0x") {
+		buf, err := hex.DecodeString(v[2:])
+		return buf, true, err
+	}
+	if len(v) >= 3 && (v[0] == 'X' || v[0] == 'x') && v[1] == '\'' && v[len(v)-1] == '\'' {
+		buf, err := hex.DecodeString(v[2 : len(v)-1])
+		return buf, true, err
+	}
+	return nil, false, nil
+}
+
+// dumpBits decodes the value of a BIT column, a bit literal b'...', a hex literal or a number, as the rows of the
+// binlog decode it.
+func dumpBits(v string) (int64, error) {
+	if len(v) >= 3 && (v[0] == 'b' || v[0] == 'B') && v[1] == '\'' && v[len(v)-1] == '\'' {
+		n, err := strconv.ParseUint(v[2:len(v)-1], 2, 64)
+		return int64(n), err
+	}
+	if buf, ok, err := dumpHex(v); ok {
+		if err != nil {
+			return 0, err
+		}
+		if len(buf) > 8 {
+			return 0, errors.Errorf("%d bytes bit literal", len(buf))
+		}
+		var n uint64
+		for _, b := range buf {
+			n = n<<8 | uint64(b)
+		}
+		return int64(n), nil
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	return int64(n), err
+}
+
+// ParseDump passes the rows of a dump written by mysqldump, e.g. a file, to OnRow as the rows dumped by the canal,
+// inserted with Snapshot set, so that the same handler loads the dump and syncs the binlog. The rows of the tables
+// which are excluded or do not exist are skipped. It returns the binlog position and the GTID set of the dump, if
+// written with --master-data, to sync the binlog from, see RunFrom and StartFromGTID.
+func (c *Canal) ParseDump(r io.Reader) (mysql.Position, mysql.GTIDSet, error) {
+	h := &dumpParseHandler{c: c}
+	if err := dump.Parse(r, h, true); err != nil {
+		return mysql.Position{}, nil, errors.Trace(err)
+	}
+	return mysql.Position{Name: h.name, Pos: uint32(h.pos)}, h.gset, nil
 }
 
 func (c *Canal) AddDumpDatabases(dbs ...string) {
@@ -138,6 +214,9 @@ func (c *Canal) AddDumpIgnoreTables(db string, tables ...string) {
 }
 
 func (c *Canal) dump() error {
+	if c.cfg.Dump.Snapshot {
+		return c.dumpSnapshot()
+	}
 	if c.dumper == nil {
 		return errors.New("mysqldump does not exist")
 	}
@@ -199,7 +278,7 @@ func (c *Canal) tryDump() error {
 		return nil
 	}
 
-	if c.dumper == nil {
+	if c.dumper == nil && !c.cfg.Dump.Snapshot {
 		c.cfg.Logger.Info("skip dump, no mysqldump")
 		return nil
 	}
@@ -0,0 +1,61 @@
+package canal
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/schema"
+)
+
+type dumpRecorder struct {
+	DummyEventHandler
+
+	events []*RowsEvent
+}
+
+func (h *dumpRecorder) OnRow(e *RowsEvent) error {
+	h.events = append(h.events, e)
+	return nil
+}
+
+func TestParseDump(t *testing.T) {
+	ta := &schema.Table{Schema: "test", Name: "t"}
+	ta.AddColumn("id", "int(10) unsigned", "", "")
+	ta.AddColumn("name", "varchar(20)", "", "")
+	ta.AddColumn("data", "varbinary(20)", "", "")
+	ta.AddColumn("flags", "bit(8)", "", "")
+	ta.AddColumn("price", "double", "", "")
+	ta.PKColumns = []int{0}
+
+	h := new(dumpRecorder)
+	c := &Canal{cfg: &Config{}, ctx: context.Background(), eventHandler: h,
+		tables: map[string]*schema.Table{"test.t": ta}}
+
+	dump := `SET @@GLOBAL.GTID_PURGED='5c9cc6ee-1b4f-11ee-8f0d-0242ac110002:1-10';
+CHANGE MASTER TO MASTER_LOG_FILE='mysql-bin.000003', MASTER_LOG_POS=1234;
+USE ` + "`test`" + `;
+INSERT INTO ` + "`t`" + ` VALUES (1,'a\'b',0x00ff,b'101',1.5),(2,NULL,_binary 'x\ny',0x05,-2);
+INSERT INTO ` + "`t`" + ` VALUES (3,'c),(d',X'61',NULL,0);
+`
+	pos, gset, err := c.ParseDump(strings.NewReader(dump))
+	require.NoError(t, err)
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000003", Pos: 1234}, pos)
+	require.Equal(t, "5c9cc6ee-1b4f-11ee-8f0d-0242ac110002:1-10", gset.String())
+
+	require.Len(t, h.events, 3)
+	for _, e := range h.events {
+		require.Equal(t, InsertAction, e.Action)
+		require.True(t, e.Snapshot)
+		require.Same(t, ta, e.Table)
+	}
+	require.Equal(t, []interface{}{uint64(1), "a'b", "\x00\xff", int64(5), 1.5}, h.events[0].Rows[0])
+	require.Equal(t, []interface{}{uint64(2), nil, []byte("x\ny"), int64(5), float64(-2)}, h.events[1].Rows[0])
+	require.Equal(t, []interface{}{uint64(3), "c),(d", "a", nil, float64(0)}, h.events[2].Rows[0])
+
+	_, _, err = c.ParseDump(strings.NewReader("USE `test`;\nINSERT INTO `t` VALUES (x,'a',NULL,NULL,NULL);\n"))
+	require.Error(t, err)
+}
@@ -0,0 +1,67 @@
+// Package etcdstore saves the position of a go-mysql canal in an etcd key, with an etcd v3 client.
+//
+//	cli, _ := clientv3.New(clientv3.Config{Endpoints: []string{"127.0.0.1:2379"}})
+//	cfg.PositionStore = etcdstore.New(cli, "/canal/position")
+package etcdstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/pingcap/errors"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/atoonk/go-mysql/canal"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/schema"
+)
+
+// PositionStore implements canal.SchemaPositionStore with the JSON of the position and the tables in an etcd key.
+type PositionStore struct {
+	kv      clientv3.KV
+	key     string
+	timeout time.Duration
+}
+
+var _ canal.SchemaPositionStore = (*PositionStore)(nil)
+
+// New creates a store saving the position in key with kv, e.g. a *clientv3.Client. The requests time out after 10
+// seconds.
+func New(kv clientv3.KV, key string) *PositionStore {
+	return &PositionStore{kv: kv, key: key, timeout: 10 * time.Second}
+}
+
+func (s *PositionStore) Load() (mysql.Position, mysql.GTIDSet, error) {
+	pos, gset, _, err := s.LoadSchema()
+	return pos, gset, err
+}
+
+func (s *PositionStore) Save(pos mysql.Position, gset mysql.GTIDSet) error {
+	return s.SaveSchema(pos, gset, nil)
+}
+
+func (s *PositionStore) LoadSchema() (mysql.Position, mysql.GTIDSet, []*schema.Table, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	resp, err := s.kv.Get(ctx, s.key)
+	if err != nil {
+		return mysql.Position{}, nil, nil, errors.Trace(err)
+	}
+	if len(resp.Kvs) == 0 {
+		return mysql.Position{}, nil, nil, nil
+	}
+	return canal.DecodePosition(resp.Kvs[0].Value)
+}
+
+func (s *PositionStore) SaveSchema(pos mysql.Position, gset mysql.GTIDSet, tables []*schema.Table) error {
+	data, err := canal.EncodePosition(pos, gset, tables)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	_, err = s.kv.Put(ctx, s.key, string(data))
+	return errors.Trace(err)
+}
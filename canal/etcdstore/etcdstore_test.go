@@ -0,0 +1,50 @@
+package etcdstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+// memoryKV implements the Get and the Put of clientv3.KV with a map.
+type memoryKV struct {
+	clientv3.KV
+	values map[string][]byte
+}
+
+func (kv *memoryKV) Get(_ context.Context, key string, _ ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	resp := &clientv3.GetResponse{}
+	if v, ok := kv.values[key]; ok {
+		resp.Kvs = []*mvccpb.KeyValue{{Key: []byte(key), Value: v}}
+	}
+	return resp, nil
+}
+
+func (kv *memoryKV) Put(_ context.Context, key, val string, _ ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	kv.values[key] = []byte(val)
+	return &clientv3.PutResponse{}, nil
+}
+
+func TestPositionStore(t *testing.T) {
+	kv := &memoryKV{values: make(map[string][]byte)}
+	s := New(kv, "/canal/position")
+
+	pos, gset, err := s.Load()
+	require.NoError(t, err)
+	require.Equal(t, mysql.Position{}, pos)
+	require.Nil(t, gset)
+
+	set, err := mysql.ParseMysqlGTIDSet("3E11FA47-71CA-11E1-9E33-C80AA9429562:1-23")
+	require.NoError(t, err)
+	require.NoError(t, s.Save(mysql.Position{Name: "mysql-bin.000002", Pos: 120}, set))
+	pos, gset, err = s.Load()
+	require.NoError(t, err)
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000002", Pos: 120}, pos)
+	require.True(t, set.Equal(gset))
+	require.Contains(t, kv.values, "/canal/position")
+}
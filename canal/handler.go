@@ -1,6 +1,8 @@
 package canal
 
 import (
+	"github.com/pingcap/errors"
+
 	"github.com/atoonk/go-mysql/mysql"
 	"github.com/atoonk/go-mysql/replication"
 )
@@ -24,6 +26,22 @@ type EventHandler interface {
 	String() string
 }
 
+// SchemaChangeHandler is implemented by the event handlers receiving the changes of the tables parsed from the DDL
+// statements.
+type SchemaChangeHandler interface {
+	// OnSchemaChange is called after OnDDL for each table created, altered, renamed, dropped or truncated by the
+	// statement, with the changes parsed and the table before and after them.
+	OnSchemaChange(e *SchemaChangeEvent) error
+}
+
+// TransactionHandler is implemented by the event handlers receiving the rows of the binlog by transaction, it is
+// required by Config.BatchTransactions.
+type TransactionHandler interface {
+	// OnTransaction is called instead of OnRow for the rows of the binlog if Config.BatchTransactions is set,
+	// once the transaction which changed them is committed, after OnXID.
+	OnTransaction(txn *Transaction) error
+}
+
 type DummyEventHandler struct {
 }
 
@@ -53,5 +71,25 @@ func (h *DummyEventHandler) String() string { return "DummyEventHandler" }
 // `SetEventHandler` registers the sync handler, you must register your
 // own handler before starting Canal.
 func (c *Canal) SetEventHandler(h EventHandler) {
+	if c.cfg.Metrics != nil {
+		h = &meteredHandler{EventHandler: h, m: c.cfg.Metrics}
+	}
 	c.eventHandler = h
 }
+
+// handler returns the event handler set by SetEventHandler, without the measuring of Config.Metrics, to check the
+// optional interfaces it implements.
+func (c *Canal) handler() EventHandler {
+	if h, ok := c.eventHandler.(*meteredHandler); ok {
+		return h.EventHandler
+	}
+	return c.eventHandler
+}
+
+// checkEventHandler checks that the event handler implements the optional interfaces required by the config.
+func (c *Canal) checkEventHandler() error {
+	if _, ok := c.handler().(TransactionHandler); c.cfg.BatchTransactions && !ok {
+		return errors.Errorf("event handler %s does not implement TransactionHandler, required by BatchTransactions", c.eventHandler)
+	}
+	return nil
+}
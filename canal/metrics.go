@@ -0,0 +1,235 @@
+package canal
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/replication"
+)
+
+// Metrics receives the measurements of the sync of a Canal, e.g. to export them to a monitoring system, see the
+// prommetrics module for Prometheus. The methods must be safe for concurrent use and should not block.
+type Metrics interface {
+	// EventReceived counts an event of the binlog received, with its size in bytes
+	EventReceived(eventType replication.EventType, size uint32)
+	// ReplicationDelay observes the seconds behind the master of the last event received, see Canal.GetDelay
+	ReplicationDelay(seconds uint32)
+	// HandlerLatency observes the time spent in a method of the event handler, e.g. OnRow
+	HandlerLatency(method string, d time.Duration)
+	// Reconnected counts a sync resumed after a broken connection
+	Reconnected()
+}
+
+// Status is the state of the sync of a Canal, reported by its health endpoint.
+type Status struct {
+	// Syncing is set while the binlog is synced, once dumped
+	Syncing             bool           `json:"syncing"`
+	Position            mysql.Position `json:"position"`
+	GTIDSet             string         `json:"gtid_set,omitempty"`
+	SecondsBehindMaster uint32         `json:"seconds_behind_master"`
+	// Events and Bytes are the events of the binlog received and their size since the canal was created, with their
+	// rates over the last rateWindow seconds
+	Events          uint64  `json:"events"`
+	Bytes           uint64  `json:"bytes"`
+	EventsPerSecond float64 `json:"events_per_second"`
+	BytesPerSecond  float64 `json:"bytes_per_second"`
+	Reconnects      uint64  `json:"reconnects"`
+}
+
+// rateWindow is the number of seconds the rates of the events are computed over.
+const rateWindow = 10
+
+// syncStats counts the events of the binlog received, with their rates in buckets of a second, the ones of the
+// rateWindow seconds before the current one.
+type syncStats struct {
+	syncing    int32
+	events     uint64
+	bytes      uint64
+	reconnects uint64
+
+	m            sync.Mutex
+	seconds      [rateWindow + 1]int64
+	secondEvents [rateWindow + 1]uint64
+	secondBytes  [rateWindow + 1]uint64
+}
+
+func (s *syncStats) add(now time.Time, size uint32) {
+	atomic.AddUint64(&s.events, 1)
+	atomic.AddUint64(&s.bytes, uint64(size))
+
+	second := now.Unix()
+	i := second % (rateWindow + 1)
+	s.m.Lock()
+	if s.seconds[i] != second {
+		s.seconds[i] = second
+		s.secondEvents[i] = 0
+		s.secondBytes[i] = 0
+	}
+	s.secondEvents[i]++
+	s.secondBytes[i] += uint64(size)
+	s.m.Unlock()
+}
+
+// rates returns the events and bytes per second over the last complete rateWindow seconds.
+func (s *syncStats) rates(now time.Time) (float64, float64) {
+	var events, bytes uint64
+	second := now.Unix()
+	s.m.Lock()
+	for i := range s.seconds {
+		if s.seconds[i] >= second-rateWindow && s.seconds[i] < second {
+			events += s.secondEvents[i]
+			bytes += s.secondBytes[i]
+		}
+	}
+	s.m.Unlock()
+	return float64(events) / rateWindow, float64(bytes) / rateWindow
+}
+
+// receiveEvent measures an event of the binlog received.
+func (c *Canal) receiveEvent(ev *replication.BinlogEvent) {
+	c.stats.add(time.Now(), ev.Header.EventSize)
+	if c.cfg.Metrics != nil {
+		c.cfg.Metrics.EventReceived(ev.Header.EventType, ev.Header.EventSize)
+		c.cfg.Metrics.ReplicationDelay(c.GetDelay())
+	}
+}
+
+func (c *Canal) onReconnect(mysql.Position, mysql.GTIDSet) {
+	atomic.AddUint64(&c.stats.reconnects, 1)
+	if c.cfg.Metrics != nil {
+		c.cfg.Metrics.Reconnected()
+	}
+}
+
+// Status returns the state of the sync.
+func (c *Canal) Status() *Status {
+	s := &Status{
+		Syncing:             atomic.LoadInt32(&c.stats.syncing) == 1,
+		Position:            c.master.Position(),
+		SecondsBehindMaster: c.GetDelay(),
+		Events:              atomic.LoadUint64(&c.stats.events),
+		Bytes:               atomic.LoadUint64(&c.stats.bytes),
+		Reconnects:          atomic.LoadUint64(&c.stats.reconnects),
+	}
+	if gset := c.master.GTIDSet(); gset != nil {
+		s.GTIDSet = gset.String()
+	}
+	s.EventsPerSecond, s.BytesPerSecond = c.stats.rates(time.Now())
+	return s
+}
+
+// HealthHandler returns the handler of the health endpoints, reporting the Status in JSON: /health responds 200 OK
+// until the canal is closed, /ready once the binlog is synced and its delay is at most Config.HealthMaxDelay if set,
+// and 503 Service Unavailable otherwise.
+func (c *Canal) HealthHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		c.writeStatus(w, c.ctx.Err() == nil)
+	})
+	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+		s := c.Status()
+		ready := c.ctx.Err() == nil && s.Syncing
+		if c.cfg.HealthMaxDelay > 0 && time.Duration(s.SecondsBehindMaster)*time.Second > c.cfg.HealthMaxDelay {
+			ready = false
+		}
+		c.writeStatus(w, ready)
+	})
+	return mux
+}
+
+func (c *Canal) writeStatus(w http.ResponseWriter, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(c.Status())
+}
+
+// serveHealth serves the health endpoints at Config.HealthAddr until the canal is closed.
+func (c *Canal) serveHealth() error {
+	if c.cfg.HealthAddr == "" {
+		return nil
+	}
+	l, err := net.Listen("tcp", c.cfg.HealthAddr)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	srv := &http.Server{Handler: c.HealthHandler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-c.ctx.Done()
+		srv.Close()
+	}()
+	go func() {
+		if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+			c.cfg.Logger.Errorf("canal serve health endpoints err: %v", err)
+		}
+	}()
+	return nil
+}
+
+// meteredHandler measures the latency of the methods of an event handler.
+type meteredHandler struct {
+	EventHandler
+	m Metrics
+}
+
+func (h *meteredHandler) observe(method string, start time.Time) {
+	h.m.HandlerLatency(method, time.Since(start))
+}
+
+func (h *meteredHandler) OnRotate(header *replication.EventHeader, e *replication.RotateEvent) error {
+	defer h.observe("OnRotate", time.Now())
+	return h.EventHandler.OnRotate(header, e)
+}
+
+func (h *meteredHandler) OnTableChanged(header *replication.EventHeader, schema string, table string) error {
+	defer h.observe("OnTableChanged", time.Now())
+	return h.EventHandler.OnTableChanged(header, schema, table)
+}
+
+func (h *meteredHandler) OnDDL(header *replication.EventHeader, nextPos mysql.Position, e *replication.QueryEvent) error {
+	defer h.observe("OnDDL", time.Now())
+	return h.EventHandler.OnDDL(header, nextPos, e)
+}
+
+func (h *meteredHandler) OnSchemaChange(e *SchemaChangeEvent) error {
+	defer h.observe("OnSchemaChange", time.Now())
+	return h.EventHandler.(SchemaChangeHandler).OnSchemaChange(e)
+}
+
+func (h *meteredHandler) OnRow(e *RowsEvent) error {
+	defer h.observe("OnRow", time.Now())
+	return h.EventHandler.OnRow(e)
+}
+
+func (h *meteredHandler) OnXID(header *replication.EventHeader, nextPos mysql.Position) error {
+	defer h.observe("OnXID", time.Now())
+	return h.EventHandler.OnXID(header, nextPos)
+}
+
+func (h *meteredHandler) OnGTID(header *replication.EventHeader, e mysql.BinlogGTIDEvent) error {
+	defer h.observe("OnGTID", time.Now())
+	return h.EventHandler.OnGTID(header, e)
+}
+
+func (h *meteredHandler) OnPosSynced(header *replication.EventHeader, pos mysql.Position, set mysql.GTIDSet, force bool) error {
+	defer h.observe("OnPosSynced", time.Now())
+	return h.EventHandler.OnPosSynced(header, pos, set, force)
+}
+
+func (h *meteredHandler) OnRowsQueryEvent(e *replication.RowsQueryEvent) error {
+	defer h.observe("OnRowsQueryEvent", time.Now())
+	return h.EventHandler.OnRowsQueryEvent(e)
+}
+
+func (h *meteredHandler) OnTransaction(txn *Transaction) error {
+	defer h.observe("OnTransaction", time.Now())
+	return h.EventHandler.(TransactionHandler).OnTransaction(txn)
+}
@@ -0,0 +1,100 @@
+package canal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/replication"
+)
+
+type metricsRecorder struct {
+	events     int
+	bytes      uint32
+	methods    []string
+	reconnects int
+}
+
+func (m *metricsRecorder) EventReceived(_ replication.EventType, size uint32) {
+	m.events++
+	m.bytes += size
+}
+
+func (m *metricsRecorder) ReplicationDelay(uint32) {}
+
+func (m *metricsRecorder) HandlerLatency(method string, _ time.Duration) {
+	m.methods = append(m.methods, method)
+}
+
+func (m *metricsRecorder) Reconnected() {
+	m.reconnects++
+}
+
+func TestSyncStatsRates(t *testing.T) {
+	var s syncStats
+	now := time.Unix(1700000000, 0)
+	for i := 19; i >= 0; i-- {
+		s.add(now.Add(-time.Duration(i)*time.Second), 100)
+	}
+	// the current second is not complete yet and the seconds before the window are not counted
+	events, bytes := s.rates(now)
+	require.Equal(t, 1.0, events)
+	require.Equal(t, 100.0, bytes)
+	require.Equal(t, uint64(20), s.events)
+
+	events, _ = s.rates(now.Add(time.Minute))
+	require.Equal(t, 0.0, events)
+}
+
+func TestHealthHandler(t *testing.T) {
+	m := &metricsRecorder{}
+	c := &Canal{cfg: &Config{Metrics: m, HealthMaxDelay: 10 * time.Second}, delay: new(uint32),
+		master: &masterInfo{pos: mysql.Position{Name: "mysql-bin.000001", Pos: 4}}}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.SetEventHandler(&DummyEventHandler{})
+
+	c.receiveEvent(&replication.BinlogEvent{Header: &replication.EventHeader{EventType: replication.XID_EVENT, EventSize: 31}})
+	c.onReconnect(mysql.Position{}, nil)
+	require.NoError(t, c.eventHandler.OnRow(&RowsEvent{}))
+	require.Equal(t, &metricsRecorder{events: 1, bytes: 31, methods: []string{"OnRow"}, reconnects: 1}, m)
+
+	srv := httptest.NewServer(c.HealthHandler())
+	defer srv.Close()
+	get := func(path string) (int, *Status) {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var s Status
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&s))
+		return resp.StatusCode, &s
+	}
+
+	code, s := get("/health")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000001", Pos: 4}, s.Position)
+	require.Equal(t, uint64(1), s.Events)
+	require.Equal(t, uint64(31), s.Bytes)
+	require.Equal(t, uint64(1), s.Reconnects)
+
+	// not ready until the binlog is synced, with a delay short enough
+	code, _ = get("/ready")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	atomic.StoreInt32(&c.stats.syncing, 1)
+	code, s = get("/ready")
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, s.Syncing)
+	atomic.StoreUint32(c.delay, 20)
+	code, _ = get("/ready")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+
+	c.cancel()
+	code, _ = get("/health")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+}
@@ -0,0 +1,98 @@
+package canal
+
+import (
+	"sync"
+
+	"github.com/pingcap/errors"
+)
+
+// MultiCanal syncs several masters concurrently in one process, with a Canal for each of them named by the Source of
+// its config. The events of each master are passed to its own handler, with its name as their Source, and its
+// position is saved by the PositionStore of its config, which must not be shared with the other masters.
+type MultiCanal struct {
+	canals []*Canal
+
+	closeOnce sync.Once
+}
+
+// NewMultiCanal creates the canals of the masters, named by the Source of their configs, unique and not empty.
+func NewMultiCanal(cfgs ...*Config) (*MultiCanal, error) {
+	m := new(MultiCanal)
+	sources := make(map[string]bool)
+	for _, cfg := range cfgs {
+		if cfg.Source == "" {
+			return nil, errors.Errorf("no source name of master %s", cfg.Addr)
+		}
+		if sources[cfg.Source] {
+			return nil, errors.Errorf("duplicate source %s", cfg.Source)
+		}
+		sources[cfg.Source] = true
+	}
+
+	for _, cfg := range cfgs {
+		c, err := NewCanal(cfg)
+		if err != nil {
+			m.Close()
+			return nil, errors.Annotatef(err, "source %s", cfg.Source)
+		}
+		m.canals = append(m.canals, c)
+	}
+	return m, nil
+}
+
+// Canal returns the canal of a source, nil if there is none.
+func (m *MultiCanal) Canal(source string) *Canal {
+	for _, c := range m.canals {
+		if c.cfg.Source == source {
+			return c
+		}
+	}
+	return nil
+}
+
+// Sources returns the names of the sources, in the order of their configs.
+func (m *MultiCanal) Sources() []string {
+	sources := make([]string, 0, len(m.canals))
+	for _, c := range m.canals {
+		sources = append(sources, c.cfg.Source)
+	}
+	return sources
+}
+
+// SetEventHandler registers the handlers of the sources, created by newHandler with their name, e.g. to publish the
+// rows of each source on its own topics with a SinkHandler.
+func (m *MultiCanal) SetEventHandler(newHandler func(source string) EventHandler) {
+	for _, c := range m.canals {
+		c.SetEventHandler(newHandler(c.cfg.Source))
+	}
+}
+
+// Run runs the canals of all the sources, see Canal.Run, until one of them fails, closing the others, or they are
+// closed.
+func (m *MultiCanal) Run() error {
+	errs := make(chan error, len(m.canals))
+	for _, c := range m.canals {
+		c := c
+		go func() {
+			errs <- errors.Annotatef(c.Run(), "source %s", c.cfg.Source)
+		}()
+	}
+
+	var err error
+	for range m.canals {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+			m.Close()
+		}
+	}
+	return err
+}
+
+// Close closes the canals of all the sources.
+func (m *MultiCanal) Close() {
+	m.closeOnce.Do(func() {
+		for _, c := range m.canals {
+			c.Close()
+		}
+	})
+}
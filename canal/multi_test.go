@@ -0,0 +1,37 @@
+package canal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/schema"
+)
+
+func TestMultiCanalSources(t *testing.T) {
+	_, err := NewMultiCanal(&Config{Source: "a", Addr: "127.0.0.1:3306"}, &Config{Addr: "127.0.0.1:3307"})
+	require.ErrorContains(t, err, "no source name of master 127.0.0.1:3307")
+
+	_, err = NewMultiCanal(&Config{Source: "a"}, &Config{Source: "b"}, &Config{Source: "a"})
+	require.ErrorContains(t, err, "duplicate source a")
+
+	// the events are namespaced by their source
+	c := &Canal{cfg: &Config{Source: "a", BatchTransactions: true}}
+	ta := &schema.Table{Schema: "test", Name: "t"}
+	require.Equal(t, "a", c.newRowsEvent(ta, InsertAction, nil, nil).Source)
+	c.beginTransaction(nil)
+	require.Equal(t, "a", c.txn.Source)
+
+	m := &MultiCanal{canals: []*Canal{c, {cfg: &Config{Source: "b"}}}}
+	require.Equal(t, []string{"a", "b"}, m.Sources())
+	require.Same(t, c, m.Canal("a"))
+	require.Nil(t, m.Canal("c"))
+
+	var sources []string
+	m.SetEventHandler(func(source string) EventHandler {
+		sources = append(sources, source)
+		return NewSinkHandler(&sinkRecorder{}, NewJSONEncoder(source), source)
+	})
+	require.Equal(t, []string{"a", "b"}, sources)
+	require.IsType(t, &SinkHandler{}, m.Canal("b").eventHandler)
+}
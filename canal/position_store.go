@@ -0,0 +1,349 @@
+package canal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/schema"
+)
+
+// PositionStore saves the binlog position and the GTID set synced by canal, see Config.PositionStore, so that a
+// restarted canal resumes where it left off. The redisstore and etcdstore modules save it in Redis and etcd.
+type PositionStore interface {
+	// Load returns the position and the GTID set saved, or the zero position and a nil set if none was saved.
+	Load() (mysql.Position, mysql.GTIDSet, error)
+	// Save atomically replaces the position and the GTID set saved, the set is nil if GTID is not used.
+	Save(pos mysql.Position, gset mysql.GTIDSet) error
+}
+
+// SchemaPositionStore is implemented by the position stores saving the tables cached along with the position, see
+// Config.TrackSchemaFromDDL, so that a restarted canal decodes the binlog from the position with the schema the
+// tables had then.
+type SchemaPositionStore interface {
+	PositionStore
+	// LoadSchema returns the position, the GTID set and the tables saved, the tables are nil if they were saved by
+	// Save.
+	LoadSchema() (mysql.Position, mysql.GTIDSet, []*schema.Table, error)
+	// SaveSchema atomically replaces the position, the GTID set and the tables saved.
+	SaveSchema(pos mysql.Position, gset mysql.GTIDSet, tables []*schema.Table) error
+}
+
+// savedPosition is the position and the GTID set saved by the stores, with the flavor of the set to parse it, and
+// the tables saved with them.
+type savedPosition struct {
+	Name    string          `json:"name"`
+	Pos     uint32          `json:"pos"`
+	Flavor  string          `json:"flavor,omitempty"`
+	GTIDSet string          `json:"gtid_set,omitempty"`
+	Tables  []*schema.Table `json:"tables,omitempty"`
+}
+
+func newSavedPosition(pos mysql.Position, gset mysql.GTIDSet) savedPosition {
+	p := savedPosition{Name: pos.Name, Pos: pos.Pos}
+	switch gset.(type) {
+	case *mysql.MysqlGTIDSet:
+		p.Flavor = mysql.MySQLFlavor
+	case *mysql.MariadbGTIDSet:
+		p.Flavor = mysql.MariaDBFlavor
+	}
+	if p.Flavor != "" {
+		p.GTIDSet = gset.String()
+	}
+	return p
+}
+
+func (p savedPosition) position() (mysql.Position, mysql.GTIDSet, error) {
+	pos := mysql.Position{Name: p.Name, Pos: p.Pos}
+	if p.Flavor == "" {
+		return pos, nil, nil
+	}
+	gset, err := mysql.ParseGTIDSet(p.Flavor, p.GTIDSet)
+	if err != nil {
+		return mysql.Position{}, nil, errors.Trace(err)
+	}
+	return pos, gset, nil
+}
+
+// EncodePosition encodes a position, a GTID set and the tables saved with them, nil if none are, in the JSON of the
+// position stores, with the flavor of the set.
+func EncodePosition(pos mysql.Position, gset mysql.GTIDSet, tables []*schema.Table) ([]byte, error) {
+	p := newSavedPosition(pos, gset)
+	p.Tables = tables
+	data, err := json.Marshal(p)
+	return data, errors.Trace(err)
+}
+
+// DecodePosition decodes a position, a GTID set and the tables encoded by EncodePosition.
+func DecodePosition(data []byte) (mysql.Position, mysql.GTIDSet, []*schema.Table, error) {
+	var p savedPosition
+	if err := json.Unmarshal(data, &p); err != nil {
+		return mysql.Position{}, nil, nil, errors.Annotatef(err, "decode position %q", data)
+	}
+	pos, gset, err := p.position()
+	if err != nil {
+		return mysql.Position{}, nil, nil, errors.Trace(err)
+	}
+	return pos, gset, p.Tables, nil
+}
+
+// FilePositionStore saves the position and the tables in a JSON file, replaced by renaming a new file.
+type FilePositionStore struct {
+	path string
+}
+
+func NewFilePositionStore(path string) *FilePositionStore {
+	return &FilePositionStore{path: path}
+}
+
+func (s *FilePositionStore) Load() (mysql.Position, mysql.GTIDSet, error) {
+	pos, gset, _, err := s.LoadSchema()
+	return pos, gset, err
+}
+
+func (s *FilePositionStore) Save(pos mysql.Position, gset mysql.GTIDSet) error {
+	return s.SaveSchema(pos, gset, nil)
+}
+
+func (s *FilePositionStore) LoadSchema() (mysql.Position, mysql.GTIDSet, []*schema.Table, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return mysql.Position{}, nil, nil, nil
+	} else if err != nil {
+		return mysql.Position{}, nil, nil, errors.Trace(err)
+	}
+	return DecodePosition(data)
+}
+
+func (s *FilePositionStore) SaveSchema(pos mysql.Position, gset mysql.GTIDSet, tables []*schema.Table) error {
+	data, err := EncodePosition(pos, gset, tables)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(os.Rename(f.Name(), s.path))
+}
+
+// Executor executes the statements of MySQLPositionStore, e.g. a *client.Conn.
+type Executor interface {
+	Execute(cmd string, args ...interface{}) (*mysql.Result, error)
+}
+
+// MySQLPositionStore saves the position and the tables in a row of a MySQL table, created if it does not exist,
+// keyed by a name so that several canals can share the table.
+//
+// The saves are written to the binlog of the server of the executor. If it is the master synced, each of them would
+// be read back by the canal and saved again: the executor must be a connection of its own, not the canal, to
+// another server or with sql_log_bin = 0 in its session, or PositionFlushInterval must bound the saves.
+type MySQLPositionStore struct {
+	m sync.Mutex
+
+	executor Executor
+	table    string
+	name     string
+	created  bool
+}
+
+// NewMySQLPositionStore creates a store saving the position in the row name of table, which may be qualified by its
+// database, e.g. "canal.positions".
+func NewMySQLPositionStore(executor Executor, table string, name string) *MySQLPositionStore {
+	return &MySQLPositionStore{executor: executor, table: quoteTable(table), name: name}
+}
+
+// quoteTable quotes a table name, qualified by its database or not.
+func quoteTable(table string) string {
+	if i := strings.IndexByte(table, '.'); i >= 0 {
+		return quoteName(table[:i]) + "." + quoteName(table[i+1:])
+	}
+	return quoteName(table)
+}
+
+func (s *MySQLPositionStore) createTable() error {
+	if s.created {
+		return nil
+	}
+	_, err := s.executor.Execute(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		name VARCHAR(128) NOT NULL PRIMARY KEY,
+		binlog_name VARCHAR(255) NOT NULL,
+		binlog_pos INT UNSIGNED NOT NULL,
+		flavor VARCHAR(16) NOT NULL,
+		gtid_set TEXT NOT NULL,
+		tables MEDIUMTEXT,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	)`, s.table))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	s.created = true
+	return nil
+}
+
+func (s *MySQLPositionStore) Load() (mysql.Position, mysql.GTIDSet, error) {
+	pos, gset, _, err := s.LoadSchema()
+	return pos, gset, err
+}
+
+func (s *MySQLPositionStore) Save(pos mysql.Position, gset mysql.GTIDSet) error {
+	return s.SaveSchema(pos, gset, nil)
+}
+
+func (s *MySQLPositionStore) LoadSchema() (mysql.Position, mysql.GTIDSet, []*schema.Table, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if err := s.createTable(); err != nil {
+		return mysql.Position{}, nil, nil, errors.Trace(err)
+	}
+	r, err := s.executor.Execute(fmt.Sprintf(
+		"SELECT binlog_name, binlog_pos, flavor, gtid_set, tables FROM %s WHERE name = ?", s.table), s.name)
+	if err != nil {
+		return mysql.Position{}, nil, nil, errors.Trace(err)
+	}
+	if r.RowNumber() == 0 {
+		return mysql.Position{}, nil, nil, nil
+	}
+
+	var p savedPosition
+	if p.Name, err = r.GetString(0, 0); err != nil {
+		return mysql.Position{}, nil, nil, errors.Trace(err)
+	}
+	offset, err := r.GetUint(0, 1)
+	if err != nil {
+		return mysql.Position{}, nil, nil, errors.Trace(err)
+	}
+	p.Pos = uint32(offset)
+	if p.Flavor, err = r.GetString(0, 2); err != nil {
+		return mysql.Position{}, nil, nil, errors.Trace(err)
+	}
+	if p.GTIDSet, err = r.GetString(0, 3); err != nil {
+		return mysql.Position{}, nil, nil, errors.Trace(err)
+	}
+	if tables, _ := r.GetString(0, 4); tables != "" {
+		if err := json.Unmarshal([]byte(tables), &p.Tables); err != nil {
+			return mysql.Position{}, nil, nil, errors.Annotate(err, "decode tables")
+		}
+	}
+	pos, gset, err := p.position()
+	if err != nil {
+		return mysql.Position{}, nil, nil, errors.Trace(err)
+	}
+	return pos, gset, p.Tables, nil
+}
+
+func (s *MySQLPositionStore) SaveSchema(pos mysql.Position, gset mysql.GTIDSet, tables []*schema.Table) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if err := s.createTable(); err != nil {
+		return errors.Trace(err)
+	}
+	p := newSavedPosition(pos, gset)
+	var data []byte
+	if tables != nil {
+		var err error
+		if data, err = json.Marshal(tables); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	_, err := s.executor.Execute(fmt.Sprintf(`INSERT INTO %s (name, binlog_name, binlog_pos, flavor, gtid_set, tables)
+		VALUES (?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE binlog_name = VALUES(binlog_name),
+		binlog_pos = VALUES(binlog_pos), flavor = VALUES(flavor), gtid_set = VALUES(gtid_set),
+		tables = VALUES(tables)`, s.table),
+		s.name, p.Name, p.Pos, p.Flavor, p.GTIDSet, string(data))
+	return errors.Trace(err)
+}
+
+// loadPosition resumes from the position saved in the store of the config, unless a position or a GTID set to
+// sync from is given.
+func (c *Canal) loadPosition() error {
+	if c.cfg.PositionStore == nil {
+		return nil
+	}
+	if s, ok := c.cfg.PositionStore.(*MySQLPositionStore); ok && s.executor == Executor(c) &&
+		c.cfg.PositionFlushInterval <= 0 {
+		return errors.New("the MySQLPositionStore executed by the canal saves each of its own saves, " +
+			"give it a connection of its own or set PositionFlushInterval")
+	}
+	if pos, gset := c.master.Position(), c.master.GTIDSet(); pos.Name != "" || !mysql.IsEmptyGTIDSet(gset) {
+		return nil
+	}
+
+	var pos mysql.Position
+	var gset mysql.GTIDSet
+	var tables []*schema.Table
+	var err error
+	if s, ok := c.cfg.PositionStore.(SchemaPositionStore); ok && c.cfg.TrackSchemaFromDDL {
+		pos, gset, tables, err = s.LoadSchema()
+	} else {
+		pos, gset, err = c.cfg.PositionStore.Load()
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if pos.Name == "" && gset == nil {
+		return nil
+	}
+	c.cfg.Logger.Infof("load saved position %s and GTID set %v", pos, gset)
+	if tables != nil {
+		c.cfg.Logger.Infof("load the schema of %d tables saved", len(tables))
+		for _, ta := range tables {
+			c.SetTableCache([]byte(ta.Schema), []byte(ta.Name), ta)
+		}
+		c.schemaLoaded = true
+	}
+	c.master.Update(pos)
+	if !mysql.IsEmptyGTIDSet(gset) {
+		c.master.UpdateGTIDSet(gset)
+	}
+	return nil
+}
+
+// savePosition saves the position synced in the store of the config if force is set, or once
+// Config.PositionFlushInterval has passed since it was last saved.
+func (c *Canal) savePosition(pos mysql.Position, gset mysql.GTIDSet, force bool) error {
+	if c.cfg.PositionStore == nil || (pos.Name == "" && gset == nil) {
+		return nil
+	}
+
+	c.posSaveLock.Lock()
+	defer c.posSaveLock.Unlock()
+
+	now := time.Now()
+	if !force && now.Sub(c.posSavedTime) < c.cfg.PositionFlushInterval {
+		return nil
+	}
+	var err error
+	if s, ok := c.cfg.PositionStore.(SchemaPositionStore); ok && c.cfg.TrackSchemaFromDDL {
+		err = s.SaveSchema(pos, gset, c.cachedTables())
+	} else {
+		err = c.cfg.PositionStore.Save(pos, gset)
+	}
+	if err != nil {
+		return errors.Annotatef(err, "save position %s", pos)
+	}
+	c.posSavedTime = now
+	return nil
+}
@@ -0,0 +1,112 @@
+package canal
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/siddontang/go-log/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/schema"
+)
+
+func testPositionStore(t *testing.T, s PositionStore) {
+	pos, gset, err := s.Load()
+	require.NoError(t, err)
+	require.Equal(t, mysql.Position{}, pos)
+	require.Nil(t, gset)
+
+	require.NoError(t, s.Save(mysql.Position{Name: "mysql-bin.000001", Pos: 4}, nil))
+	pos, gset, err = s.Load()
+	require.NoError(t, err)
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000001", Pos: 4}, pos)
+	require.Nil(t, gset)
+
+	set, err := mysql.ParseMysqlGTIDSet("3E11FA47-71CA-11E1-9E33-C80AA9429562:1-23")
+	require.NoError(t, err)
+	require.NoError(t, s.Save(mysql.Position{Name: "mysql-bin.000002", Pos: 120}, set))
+	pos, gset, err = s.Load()
+	require.NoError(t, err)
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000002", Pos: 120}, pos)
+	require.True(t, set.Equal(gset))
+
+	ss, ok := s.(SchemaPositionStore)
+	if !ok {
+		return
+	}
+	ta := &schema.Table{Schema: "test", Name: "t"}
+	ta.AddColumn("id", "int unsigned", "", "auto_increment")
+	ta.PKColumns = []int{0}
+	require.NoError(t, ss.SaveSchema(mysql.Position{Name: "mysql-bin.000003", Pos: 4}, nil, []*schema.Table{ta}))
+	pos, _, tables, err := ss.LoadSchema()
+	require.NoError(t, err)
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000003", Pos: 4}, pos)
+	require.Equal(t, []*schema.Table{ta}, tables)
+}
+
+func TestFilePositionStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.info")
+	testPositionStore(t, NewFilePositionStore(path))
+
+	matches, err := filepath.Glob(path + ".tmp*")
+	require.NoError(t, err)
+	require.Empty(t, matches)
+}
+
+// queryRecorder records the statements of a MySQLPositionStore, with no result.
+type queryRecorder struct {
+	queries []string
+}
+
+func (r *queryRecorder) Execute(cmd string, args ...interface{}) (*mysql.Result, error) {
+	r.queries = append(r.queries, cmd)
+	return &mysql.Result{}, nil
+}
+
+func TestMySQLPositionStore(t *testing.T) {
+	r := &queryRecorder{}
+	s := NewMySQLPositionStore(r, "canal.pos`itions", "c1")
+	require.NoError(t, s.Save(mysql.Position{Name: "mysql-bin.000001", Pos: 4}, nil))
+	require.Len(t, r.queries, 2)
+	for _, query := range r.queries {
+		require.Contains(t, query, "`canal`.`pos``itions`")
+	}
+
+	// the tables are saved with the position
+	r.queries = nil
+	require.NoError(t, s.SaveSchema(mysql.Position{Name: "mysql-bin.000001", Pos: 4}, nil, []*schema.Table{{Schema: "test", Name: "t"}}))
+	require.Contains(t, r.queries[0], "tables = VALUES(tables)")
+
+	// the canal can't save its own saves at every transaction
+	c := &Canal{cfg: &Config{}}
+	c.cfg.PositionStore = NewMySQLPositionStore(c, "positions", "c1")
+	require.Error(t, c.loadPosition())
+}
+
+func TestLoadPositionSchema(t *testing.T) {
+	streamHandler, _ := log.NewStreamHandler(io.Discard)
+	logger := log.NewDefault(streamHandler)
+	store := NewFilePositionStore(filepath.Join(t.TempDir(), "master.info"))
+	ta := &schema.Table{Schema: "test", Name: "t"}
+	ta.AddColumn("id", "int", "", "")
+
+	c := &Canal{
+		cfg:    &Config{Logger: logger, PositionStore: store, TrackSchemaFromDDL: true},
+		master: &masterInfo{logger: logger},
+		tables: map[string]*schema.Table{"test.t": ta},
+	}
+	require.NoError(t, c.savePosition(mysql.Position{Name: "mysql-bin.000001", Pos: 4}, nil, true))
+
+	// the schema of the tables at the position is restored with it
+	c = &Canal{
+		cfg:    &Config{Logger: logger, PositionStore: store, TrackSchemaFromDDL: true},
+		master: &masterInfo{logger: logger},
+		tables: make(map[string]*schema.Table),
+	}
+	require.NoError(t, c.loadPosition())
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000001", Pos: 4}, c.master.Position())
+	require.Equal(t, ta, c.cachedTable("test", "t"))
+	require.True(t, c.schemaLoaded)
+}
@@ -0,0 +1,93 @@
+// Package prommetrics exports the metrics of a go-mysql canal to Prometheus.
+//
+//	m := prommetrics.New("mysql_canal")
+//	prometheus.MustRegister(m)
+//	cfg.Metrics = m
+package prommetrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/atoonk/go-mysql/canal"
+	"github.com/atoonk/go-mysql/replication"
+)
+
+// Metrics implements canal.Metrics with Prometheus collectors, it is itself a prometheus.Collector to register.
+type Metrics struct {
+	events     *prometheus.CounterVec
+	bytes      prometheus.Counter
+	delay      prometheus.Gauge
+	latency    *prometheus.HistogramVec
+	reconnects prometheus.Counter
+}
+
+var _ canal.Metrics = (*Metrics)(nil)
+
+// New returns the metrics named with the given namespace, e.g. "mysql_canal_events_total".
+func New(namespace string) *Metrics {
+	return &Metrics{
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_total",
+			Help:      "Number of binlog events received by event type.",
+		}, []string{"type"}),
+		bytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "received_bytes_total",
+			Help:      "Number of bytes of the binlog events received.",
+		}),
+		delay: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "seconds_behind_master",
+			Help:      "Seconds between the execution of the last binlog event received by the master and its receipt.",
+		}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "handler_duration_seconds",
+			Help:      "Time spent in the event handler by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		reconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reconnects_total",
+			Help:      "Number of syncs resumed after a broken connection.",
+		}),
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.events, m.bytes, m.delay, m.latency, m.reconnects}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+func (m *Metrics) EventReceived(eventType replication.EventType, size uint32) {
+	m.events.WithLabelValues(eventType.String()).Inc()
+	m.bytes.Add(float64(size))
+}
+
+func (m *Metrics) ReplicationDelay(seconds uint32) {
+	m.delay.Set(float64(seconds))
+}
+
+func (m *Metrics) HandlerLatency(method string, d time.Duration) {
+	m.latency.WithLabelValues(method).Observe(d.Seconds())
+}
+
+func (m *Metrics) Reconnected() {
+	m.reconnects.Inc()
+}
@@ -0,0 +1,45 @@
+package prommetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/replication"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New("test")
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(m))
+
+	m.EventReceived(replication.WRITE_ROWS_EVENTv2, 100)
+	m.ReplicationDelay(3)
+	m.HandlerLatency("OnRow", 10*time.Millisecond)
+	m.Reconnected()
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	values := make(map[string]float64)
+	for _, f := range families {
+		metric := f.GetMetric()[0]
+		switch {
+		case metric.Counter != nil:
+			values[f.GetName()] = metric.Counter.GetValue()
+		case metric.Gauge != nil:
+			values[f.GetName()] = metric.Gauge.GetValue()
+		case metric.Histogram != nil:
+			values[f.GetName()] = float64(metric.Histogram.GetSampleCount())
+			require.Equal(t, "OnRow", metric.GetLabel()[0].GetValue())
+		}
+	}
+	require.Equal(t, map[string]float64{
+		"test_events_total":             1,
+		"test_received_bytes_total":     100,
+		"test_seconds_behind_master":    3,
+		"test_handler_duration_seconds": 1,
+		"test_reconnects_total":         1,
+	}, values)
+}
@@ -0,0 +1,65 @@
+// Package redisstore saves the position of a go-mysql canal in a Redis key, with a go-redis client.
+//
+//	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:6379"})
+//	cfg.PositionStore = redisstore.New(rdb, "canal:position")
+package redisstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/atoonk/go-mysql/canal"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/schema"
+)
+
+// PositionStore implements canal.SchemaPositionStore with the JSON of the position and the tables in a Redis key.
+type PositionStore struct {
+	client  redis.Cmdable
+	key     string
+	timeout time.Duration
+}
+
+var _ canal.SchemaPositionStore = (*PositionStore)(nil)
+
+// New creates a store saving the position in key with client, e.g. a *redis.Client or a *redis.ClusterClient. The
+// commands time out after 10 seconds.
+func New(client redis.Cmdable, key string) *PositionStore {
+	return &PositionStore{client: client, key: key, timeout: 10 * time.Second}
+}
+
+func (s *PositionStore) Load() (mysql.Position, mysql.GTIDSet, error) {
+	pos, gset, _, err := s.LoadSchema()
+	return pos, gset, err
+}
+
+func (s *PositionStore) Save(pos mysql.Position, gset mysql.GTIDSet) error {
+	return s.SaveSchema(pos, gset, nil)
+}
+
+func (s *PositionStore) LoadSchema() (mysql.Position, mysql.GTIDSet, []*schema.Table, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	data, err := s.client.Get(ctx, s.key).Bytes()
+	if err == redis.Nil {
+		return mysql.Position{}, nil, nil, nil
+	} else if err != nil {
+		return mysql.Position{}, nil, nil, errors.Trace(err)
+	}
+	return canal.DecodePosition(data)
+}
+
+func (s *PositionStore) SaveSchema(pos mysql.Position, gset mysql.GTIDSet, tables []*schema.Table) error {
+	data, err := canal.EncodePosition(pos, gset, tables)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	return errors.Trace(s.client.Set(ctx, s.key, data, 0).Err())
+}
@@ -0,0 +1,32 @@
+package redisstore
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+func TestPositionStore(t *testing.T) {
+	m := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	defer rdb.Close()
+	s := New(rdb, "canal:position")
+
+	pos, gset, err := s.Load()
+	require.NoError(t, err)
+	require.Equal(t, mysql.Position{}, pos)
+	require.Nil(t, gset)
+
+	set, err := mysql.ParseMysqlGTIDSet("3E11FA47-71CA-11E1-9E33-C80AA9429562:1-23")
+	require.NoError(t, err)
+	require.NoError(t, s.Save(mysql.Position{Name: "mysql-bin.000002", Pos: 120}, set))
+	pos, gset, err = s.Load()
+	require.NoError(t, err)
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000002", Pos: 120}, pos)
+	require.True(t, set.Equal(gset))
+	require.True(t, m.Exists("canal:position"))
+}
@@ -26,6 +26,10 @@ type RowsEvent struct {
 	Rows [][]interface{}
 	// Header can be used to inspect the event
 	Header *replication.EventHeader
+	// Snapshot is set for the rows of the tables dumped, nil Header, rather than inserted in the binlog
+	Snapshot bool
+	// Source is the master of the rows, see Config.Source
+	Source string
 }
 
 func newRowsEvent(table *schema.Table, action string, rows [][]interface{}, header *replication.EventHeader) *RowsEvent {
@@ -0,0 +1,434 @@
+package canal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+
+	"github.com/atoonk/go-mysql/schema"
+)
+
+// trackDDL applies a DDL statement to the tables cached, see Config.TrackSchemaFromDDL. A table the statement can't
+// be applied to, or which is not cached yet, is removed from the cache to be fetched from the master next time.
+func (c *Canal) trackDDL(db string, stmt ast.StmtNode) {
+	switch t := stmt.(type) {
+	case *ast.CreateTableStmt:
+		schemaName, tableName := tableNameOf(t.Table, db)
+		var ta *schema.Table
+		var err error
+		switch {
+		case t.ReferTable != nil:
+			// CREATE TABLE ... LIKE
+			ta = c.cachedTable(tableNameOf(t.ReferTable, db))
+			if ta != nil {
+				ta = copyTable(ta)
+				ta.Schema, ta.Name = schemaName, tableName
+			}
+		case t.Select == nil:
+			ta, err = newTableFromCreate(schemaName, tableName, t)
+		}
+		c.setTrackedTable(schemaName, tableName, ta, err)
+	case *ast.AlterTableStmt:
+		schemaName, tableName := tableNameOf(t.Table, db)
+		ta := c.cachedTable(schemaName, tableName)
+		if ta == nil {
+			c.ClearTableCache([]byte(schemaName), []byte(tableName))
+			return
+		}
+		ta = copyTable(ta)
+		err := alterTable(ta, t.Specs)
+		if err != nil || ta.Schema != schemaName || ta.Name != tableName {
+			c.ClearTableCache([]byte(schemaName), []byte(tableName))
+		}
+		c.setTrackedTable(ta.Schema, ta.Name, ta, err)
+	case *ast.RenameTableStmt:
+		for _, tt := range t.TableToTables {
+			oldSchema, oldName := tableNameOf(tt.OldTable, db)
+			newSchema, newName := tableNameOf(tt.NewTable, db)
+			ta := c.cachedTable(oldSchema, oldName)
+			c.ClearTableCache([]byte(oldSchema), []byte(oldName))
+			if ta != nil {
+				ta = copyTable(ta)
+				ta.Schema, ta.Name = newSchema, newName
+			}
+			c.setTrackedTable(newSchema, newName, ta, nil)
+		}
+	case *ast.DropTableStmt:
+		for _, table := range t.Tables {
+			schemaName, tableName := tableNameOf(table, db)
+			c.ClearTableCache([]byte(schemaName), []byte(tableName))
+		}
+	case *ast.DropDatabaseStmt:
+		prefix := t.Name.O + "."
+		c.tableLock.Lock()
+		for key := range c.tables {
+			if strings.HasPrefix(key, prefix) {
+				delete(c.tables, key)
+			}
+		}
+		c.tableLock.Unlock()
+	}
+}
+
+func tableNameOf(table *ast.TableName, db string) (string, string) {
+	if table.Schema.O != "" {
+		db = table.Schema.O
+	}
+	return db, table.Name.O
+}
+
+// cachedTables returns the tables cached, sorted by name, saved with the position by a SchemaPositionStore.
+func (c *Canal) cachedTables() []*schema.Table {
+	c.tableLock.RLock()
+	defer c.tableLock.RUnlock()
+
+	tables := make([]*schema.Table, 0, len(c.tables))
+	for _, ta := range c.tables {
+		tables = append(tables, ta)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].String() < tables[j].String() })
+	return tables
+}
+
+// seedTrackedSchema caches the tables matched by the filters when the canal starts syncing the binlog with
+// Config.TrackSchemaFromDDL, unless their schema was loaded with the position, so that the DDL statements read
+// afterwards are applied to all of them. The tables have their schema at the time, the one at the position the
+// binlog is synced from unless an older position is given without the schema saved with it.
+func (c *Canal) seedTrackedSchema() error {
+	if !c.cfg.TrackSchemaFromDDL || c.schemaLoaded {
+		return nil
+	}
+
+	r, err := c.Execute("SELECT TABLE_SCHEMA, TABLE_NAME FROM information_schema.TABLES WHERE TABLE_TYPE = 'BASE TABLE'")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for i := 0; i < r.RowNumber(); i++ {
+		db, _ := r.GetString(i, 0)
+		table, _ := r.GetString(i, 1)
+		if snapshotSystemDatabases[strings.ToLower(db)] || !c.checkTableMatch(db+"."+table) {
+			continue
+		}
+		if _, err := c.GetTable(db, table); err != nil {
+			c.cfg.Logger.Warnf("seed the schema of table %s.%s err: %v", db, table, err)
+		}
+	}
+	c.schemaLoaded = true
+	return nil
+}
+
+// cachedTable returns the table cached, nil if it is not.
+func (c *Canal) cachedTable(db string, table string) *schema.Table {
+	c.tableLock.RLock()
+	defer c.tableLock.RUnlock()
+
+	return c.tables[fmt.Sprintf("%s.%s", db, table)]
+}
+
+// setTrackedTable caches the table a DDL statement was applied to, or removes it from the cache if the statement
+// failed to be applied or ta is nil.
+func (c *Canal) setTrackedTable(db string, table string, ta *schema.Table, err error) {
+	if err != nil {
+		c.cfg.Logger.Warnf("apply DDL to table %s.%s err: %v, the table will be fetched from the master", db, table, err)
+	}
+	if err != nil || ta == nil || !c.checkTableMatch(fmt.Sprintf("%s.%s", db, table)) {
+		c.ClearTableCache([]byte(db), []byte(table))
+		return
+	}
+	c.cfg.Logger.Infof("table structure changed, track table %s.%s", db, table)
+	c.SetTableCache([]byte(db), []byte(table), ta)
+}
+
+// copyTable copies a table to be altered, the tables cached are shared with the rows events sent.
+func copyTable(ta *schema.Table) *schema.Table {
+	t := *ta
+	t.Columns = append([]schema.TableColumn(nil), ta.Columns...)
+	t.Indexes = make([]*schema.Index, len(ta.Indexes))
+	for i, index := range ta.Indexes {
+		idx := *index
+		idx.Columns = append([]string(nil), index.Columns...)
+		idx.Cardinality = append([]uint64(nil), index.Cardinality...)
+		t.Indexes[i] = &idx
+	}
+	t.PKColumns = append([]int(nil), ta.PKColumns...)
+	t.UnsignedColumns = append([]int(nil), ta.UnsignedColumns...)
+	return &t
+}
+
+func newTableFromCreate(db string, table string, stmt *ast.CreateTableStmt) (*schema.Table, error) {
+	ta := &schema.Table{
+		Schema:  db,
+		Name:    table,
+		Columns: make([]schema.TableColumn, 0, len(stmt.Cols)),
+		Indexes: make([]*schema.Index, 0, len(stmt.Constraints)),
+	}
+	for _, def := range stmt.Cols {
+		ta.Columns = append(ta.Columns, newTableColumn(def))
+	}
+	for _, def := range stmt.Cols {
+		addColumnIndexes(ta, def)
+	}
+	for _, constraint := range stmt.Constraints {
+		addConstraint(ta, constraint)
+	}
+	return ta, errors.Trace(refreshTable(ta))
+}
+
+// newTableColumn returns the column of a definition, as SHOW FULL COLUMNS would describe it.
+func newTableColumn(def *ast.ColumnDef) schema.TableColumn {
+	collation := def.Tp.GetCollate()
+	extra := ""
+	for _, option := range def.Options {
+		switch option.Tp {
+		case ast.ColumnOptionAutoIncrement:
+			extra = "auto_increment"
+		case ast.ColumnOptionGenerated:
+			if option.Stored {
+				extra = "STORED GENERATED"
+			} else {
+				extra = "VIRTUAL GENERATED"
+			}
+		case ast.ColumnOptionCollate:
+			collation = option.StrValue
+		}
+	}
+
+	ta := &schema.Table{}
+	ta.AddColumn(def.Name.Name.O, strings.ToLower(def.Tp.InfoSchemaStr()), collation, extra)
+	return ta.Columns[0]
+}
+
+// addColumnIndexes adds the indexes of the PRIMARY KEY and UNIQUE options of a column definition.
+func addColumnIndexes(ta *schema.Table, def *ast.ColumnDef) {
+	name := def.Name.Name.O
+	for _, option := range def.Options {
+		switch option.Tp {
+		case ast.ColumnOptionPrimaryKey:
+			addIndex(ta, "PRIMARY", []string{name}, 0)
+		case ast.ColumnOptionUniqKey:
+			addIndex(ta, indexName(ta, "", name), []string{name}, 0)
+		}
+	}
+}
+
+func addConstraint(ta *schema.Table, constraint *ast.Constraint) {
+	var columns []string
+	for _, key := range constraint.Keys {
+		// the functional key parts index no column
+		if key.Column != nil {
+			columns = append(columns, key.Column.Name.O)
+		}
+	}
+	if len(columns) == 0 {
+		return
+	}
+
+	switch constraint.Tp {
+	case ast.ConstraintPrimaryKey:
+		addIndex(ta, "PRIMARY", columns, 0)
+	case ast.ConstraintUniq, ast.ConstraintUniqKey, ast.ConstraintUniqIndex:
+		addIndex(ta, indexName(ta, constraint.Name, columns[0]), columns, 0)
+	case ast.ConstraintKey, ast.ConstraintIndex, ast.ConstraintFulltext:
+		addIndex(ta, indexName(ta, constraint.Name, columns[0]), columns, 1)
+	}
+}
+
+// indexName returns the name of an index, named after its first column with a _2, _3... suffix if it has no name.
+func indexName(ta *schema.Table, name string, column string) string {
+	if name != "" {
+		return name
+	}
+	name = column
+	for i := 2; findIndex(ta, name) >= 0; i++ {
+		name = fmt.Sprintf("%s_%d", column, i)
+	}
+	return name
+}
+
+// addIndex adds an index, the primary key first as SHOW INDEX lists it.
+func addIndex(ta *schema.Table, name string, columns []string, noneUnique uint64) {
+	index := schema.NewIndex(name)
+	for _, column := range columns {
+		index.AddColumn(column, 0)
+	}
+	index.NoneUnique = noneUnique
+
+	if name == "PRIMARY" {
+		if i := findIndex(ta, name); i >= 0 {
+			ta.Indexes = append(ta.Indexes[:i], ta.Indexes[i+1:]...)
+		}
+		ta.Indexes = append([]*schema.Index{index}, ta.Indexes...)
+	} else {
+		ta.Indexes = append(ta.Indexes, index)
+	}
+}
+
+func findIndex(ta *schema.Table, name string) int {
+	for i, index := range ta.Indexes {
+		if strings.EqualFold(index.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// findColumn returns the index of a column, the names of the columns are case insensitive.
+func findColumn(ta *schema.Table, name string) int {
+	for i, column := range ta.Columns {
+		if strings.EqualFold(column.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// alterTable applies the specifications of an ALTER TABLE statement changing the columns, the indexes or the name
+// of a table, the others are ignored.
+func alterTable(ta *schema.Table, specs []*ast.AlterTableSpec) error {
+	for _, spec := range specs {
+		switch spec.Tp {
+		case ast.AlterTableAddColumns:
+			for _, def := range spec.NewColumns {
+				if spec.IfNotExists && findColumn(ta, def.Name.Name.O) >= 0 {
+					continue
+				}
+				if err := insertColumn(ta, newTableColumn(def), spec.Position); err != nil {
+					return errors.Trace(err)
+				}
+				addColumnIndexes(ta, def)
+			}
+			for _, constraint := range spec.NewConstraints {
+				addConstraint(ta, constraint)
+			}
+		case ast.AlterTableAddConstraint:
+			addConstraint(ta, spec.Constraint)
+		case ast.AlterTableDropColumn:
+			i := findColumn(ta, spec.OldColumnName.Name.O)
+			if i < 0 {
+				if spec.IfExists {
+					continue
+				}
+				return errors.Errorf("column %s not found", spec.OldColumnName.Name.O)
+			}
+			name := ta.Columns[i].Name
+			ta.Columns = append(ta.Columns[:i], ta.Columns[i+1:]...)
+			dropIndexColumn(ta, name)
+		case ast.AlterTableModifyColumn, ast.AlterTableChangeColumn:
+			oldName := spec.NewColumns[0].Name.Name.O
+			if spec.Tp == ast.AlterTableChangeColumn {
+				oldName = spec.OldColumnName.Name.O
+			}
+			i := findColumn(ta, oldName)
+			if i < 0 {
+				if spec.IfExists {
+					continue
+				}
+				return errors.Errorf("column %s not found", oldName)
+			}
+			oldName = ta.Columns[i].Name
+			column := newTableColumn(spec.NewColumns[0])
+			ta.Columns = append(ta.Columns[:i], ta.Columns[i+1:]...)
+			if spec.Position == nil || spec.Position.Tp == ast.ColumnPositionNone {
+				ta.Columns = append(ta.Columns[:i], append([]schema.TableColumn{column}, ta.Columns[i:]...)...)
+			} else if err := insertColumn(ta, column, spec.Position); err != nil {
+				return errors.Trace(err)
+			}
+			renameIndexColumn(ta, oldName, column.Name)
+			addColumnIndexes(ta, spec.NewColumns[0])
+		case ast.AlterTableRenameColumn:
+			i := findColumn(ta, spec.OldColumnName.Name.O)
+			if i < 0 {
+				return errors.Errorf("column %s not found", spec.OldColumnName.Name.O)
+			}
+			oldName := ta.Columns[i].Name
+			ta.Columns[i].Name = spec.NewColumnName.Name.O
+			renameIndexColumn(ta, oldName, ta.Columns[i].Name)
+		case ast.AlterTableDropPrimaryKey:
+			if i := findIndex(ta, "PRIMARY"); i >= 0 {
+				ta.Indexes = append(ta.Indexes[:i], ta.Indexes[i+1:]...)
+			}
+		case ast.AlterTableDropIndex:
+			if i := findIndex(ta, spec.Name); i >= 0 {
+				ta.Indexes = append(ta.Indexes[:i], ta.Indexes[i+1:]...)
+			} else if !spec.IfExists {
+				return errors.Errorf("index %s not found", spec.Name)
+			}
+		case ast.AlterTableRenameIndex:
+			i := findIndex(ta, spec.FromKey.O)
+			if i < 0 {
+				return errors.Errorf("index %s not found", spec.FromKey.O)
+			}
+			ta.Indexes[i].Name = spec.ToKey.O
+		case ast.AlterTableRenameTable:
+			ta.Schema, ta.Name = tableNameOf(spec.NewTable, ta.Schema)
+		}
+	}
+	return errors.Trace(refreshTable(ta))
+}
+
+// insertColumn inserts a column at its position, after the last column by default.
+func insertColumn(ta *schema.Table, column schema.TableColumn, position *ast.ColumnPosition) error {
+	i := len(ta.Columns)
+	if position != nil {
+		switch position.Tp {
+		case ast.ColumnPositionFirst:
+			i = 0
+		case ast.ColumnPositionAfter:
+			after := findColumn(ta, position.RelativeColumn.Name.O)
+			if after < 0 {
+				return errors.Errorf("column %s not found", position.RelativeColumn.Name.O)
+			}
+			i = after + 1
+		}
+	}
+	ta.Columns = append(ta.Columns[:i], append([]schema.TableColumn{column}, ta.Columns[i:]...)...)
+	return nil
+}
+
+// dropIndexColumn removes a column dropped from the indexes, and the indexes left with no column.
+func dropIndexColumn(ta *schema.Table, name string) {
+	indexes := ta.Indexes[:0]
+	for _, index := range ta.Indexes {
+		if i := index.FindColumn(name); i >= 0 {
+			index.Columns = append(index.Columns[:i], index.Columns[i+1:]...)
+			index.Cardinality = append(index.Cardinality[:i], index.Cardinality[i+1:]...)
+		}
+		if len(index.Columns) > 0 {
+			indexes = append(indexes, index)
+		}
+	}
+	ta.Indexes = indexes
+}
+
+func renameIndexColumn(ta *schema.Table, oldName string, newName string) {
+	for _, index := range ta.Indexes {
+		if i := index.FindColumn(oldName); i >= 0 {
+			index.Columns[i] = newName
+		}
+	}
+}
+
+// refreshTable computes the unsigned and the primary key columns of a table from its columns and indexes.
+func refreshTable(ta *schema.Table) error {
+	ta.UnsignedColumns = nil
+	for i, column := range ta.Columns {
+		if column.IsUnsigned {
+			ta.UnsignedColumns = append(ta.UnsignedColumns, i)
+		}
+	}
+
+	ta.PKColumns = nil
+	if len(ta.Indexes) == 0 || ta.Indexes[0].Name != "PRIMARY" {
+		return nil
+	}
+	ta.PKColumns = make([]int, len(ta.Indexes[0].Columns))
+	for i, name := range ta.Indexes[0].Columns {
+		if ta.PKColumns[i] = findColumn(ta, name); ta.PKColumns[i] < 0 {
+			return errors.Errorf("primary key column %s not found", name)
+		}
+	}
+	return nil
+}
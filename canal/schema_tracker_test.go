@@ -0,0 +1,90 @@
+package canal
+
+import (
+	"io"
+	"testing"
+
+	"github.com/pingcap/tidb/pkg/parser"
+	"github.com/siddontang/go-log/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/schema"
+)
+
+func TestTrackDDL(t *testing.T) {
+	streamHandler, _ := log.NewStreamHandler(io.Discard)
+	c := &Canal{
+		cfg:    &Config{Logger: log.NewDefault(streamHandler), TrackSchemaFromDDL: true},
+		tables: make(map[string]*schema.Table),
+	}
+	pr := parser.New()
+	track := func(query string) {
+		stmts, _, err := pr.Parse(query, "", "")
+		require.NoError(t, err)
+		for _, stmt := range stmts {
+			c.trackDDL("test", stmt)
+		}
+	}
+	columns := func(ta *schema.Table) []string {
+		var names []string
+		for _, column := range ta.Columns {
+			names = append(names, column.Name)
+		}
+		return names
+	}
+
+	track("CREATE TABLE t (id INT UNSIGNED AUTO_INCREMENT, name VARCHAR(20) COLLATE utf8mb4_bin, " +
+		"e ENUM('a','b'), d DECIMAL(10,2), b VARBINARY(8), PRIMARY KEY (id), UNIQUE KEY (name))")
+	ta := c.cachedTable("test", "t")
+	require.NotNil(t, ta)
+	require.Equal(t, []string{"id", "name", "e", "d", "b"}, columns(ta))
+	require.Equal(t, schema.TYPE_NUMBER, ta.Columns[0].Type)
+	require.True(t, ta.Columns[0].IsUnsigned)
+	require.True(t, ta.Columns[0].IsAuto)
+	require.Equal(t, schema.TYPE_STRING, ta.Columns[1].Type)
+	require.Equal(t, uint(20), ta.Columns[1].MaxSize)
+	require.Equal(t, "utf8mb4_bin", ta.Columns[1].Collation)
+	require.Equal(t, schema.TYPE_ENUM, ta.Columns[2].Type)
+	require.Equal(t, []string{"a", "b"}, ta.Columns[2].EnumValues)
+	require.Equal(t, schema.TYPE_DECIMAL, ta.Columns[3].Type)
+	require.Equal(t, schema.TYPE_BINARY, ta.Columns[4].Type)
+	require.Equal(t, []int{0}, ta.PKColumns)
+	require.Equal(t, []int{0}, ta.UnsignedColumns)
+	require.Len(t, ta.Indexes, 2)
+	require.Equal(t, "name", ta.Indexes[1].Name)
+
+	track("ALTER TABLE t ADD COLUMN c BIGINT UNSIGNED FIRST, DROP COLUMN e, CHANGE name title CHAR(4) AFTER d, " +
+		"ADD INDEX idx_c (c)")
+	altered := c.cachedTable("test", "t")
+	require.Equal(t, []string{"c", "id", "d", "title", "b"}, columns(altered))
+	require.Equal(t, schema.TYPE_STRING, altered.Columns[3].Type)
+	require.Equal(t, uint(4), altered.Columns[3].FixedSize)
+	require.Equal(t, []int{1}, altered.PKColumns)
+	require.Equal(t, []int{0, 1}, altered.UnsignedColumns)
+	require.Equal(t, []string{"title"}, altered.Indexes[1].Columns)
+	require.Equal(t, "idx_c", altered.Indexes[2].Name)
+	// the table of the rows events sent before is not altered
+	require.Equal(t, []string{"id", "name", "e", "d", "b"}, columns(ta))
+
+	track("ALTER TABLE t DROP PRIMARY KEY, ADD PRIMARY KEY (c, id), RENAME COLUMN b TO bin")
+	altered = c.cachedTable("test", "t")
+	require.Equal(t, []int{0, 1}, altered.PKColumns)
+	require.Equal(t, "bin", altered.Columns[4].Name)
+
+	track("RENAME TABLE t TO test2.t2")
+	require.Nil(t, c.cachedTable("test", "t"))
+	renamed := c.cachedTable("test2", "t2")
+	require.NotNil(t, renamed)
+	require.Equal(t, "test2", renamed.Schema)
+	require.Equal(t, "t2", renamed.Name)
+
+	track("CREATE TABLE t3 LIKE test2.t2")
+	require.Equal(t, columns(renamed), columns(c.cachedTable("test", "t3")))
+
+	// the tables a statement can't be applied to are fetched from the master again
+	track("ALTER TABLE t3 DROP COLUMN missing")
+	require.Nil(t, c.cachedTable("test", "t3"))
+
+	track("DROP TABLE test2.t2")
+	require.Nil(t, c.cachedTable("test2", "t2"))
+}
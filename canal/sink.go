@@ -0,0 +1,103 @@
+package canal
+
+import (
+	"fmt"
+
+	"github.com/pingcap/errors"
+
+	"github.com/atoonk/go-mysql/schema"
+)
+
+// Message is the change of a row encoded by an Encoder, published by a Sink.
+type Message struct {
+	// Topic is the topic or the subject of the table of the row, see SinkHandler
+	Topic string
+	// Key is the primary key of the row, nil if the table has no primary key
+	Key   []byte
+	Value []byte
+}
+
+// Sink publishes the messages of the changes of the rows, in order, e.g. the sinks of the modules canal/sink/kafka
+// and canal/sink/nats.
+type Sink interface {
+	// Publish returns once the messages are published
+	Publish(msgs []*Message) error
+	Close() error
+}
+
+// Encoder encodes the change of a row of an event, before is nil for an inserted row and after for a deleted one,
+// e.g. JSONEncoder or the encoder of the module canal/sink/avro.
+type Encoder interface {
+	Encode(topic string, e *RowsEvent, before []interface{}, after []interface{}) (*Message, error)
+}
+
+// SinkHandler is an EventHandler publishing the changes of the rows to a sink, a message per row, on the topic
+// <prefix>.<schema>.<table> of its table as Debezium names them, or <schema>.<table> without prefix.
+type SinkHandler struct {
+	DummyEventHandler
+
+	sink    Sink
+	encoder Encoder
+	prefix  string
+}
+
+func NewSinkHandler(sink Sink, encoder Encoder, prefix string) *SinkHandler {
+	return &SinkHandler{sink: sink, encoder: encoder, prefix: prefix}
+}
+
+// Topic returns the topic of the messages of a table.
+func (h *SinkHandler) Topic(table *schema.Table) string {
+	if h.prefix == "" {
+		return table.String()
+	}
+	return fmt.Sprintf("%s.%s", h.prefix, table)
+}
+
+func (h *SinkHandler) OnRow(e *RowsEvent) error {
+	msgs, err := h.encode(nil, e)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(h.sink.Publish(msgs))
+}
+
+// OnTransaction publishes the rows of a transaction at once, see Config.BatchTransactions.
+func (h *SinkHandler) OnTransaction(txn *Transaction) error {
+	var msgs []*Message
+	for _, e := range txn.Rows {
+		var err error
+		if msgs, err = h.encode(msgs, e); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return errors.Trace(h.sink.Publish(msgs))
+}
+
+func (h *SinkHandler) encode(msgs []*Message, e *RowsEvent) ([]*Message, error) {
+	topic := h.Topic(e.Table)
+	n := 1
+	if e.Action == UpdateAction {
+		n = 2
+	}
+	for i := 0; i+n <= len(e.Rows); i += n {
+		var before, after []interface{}
+		switch e.Action {
+		case InsertAction:
+			after = e.Rows[i]
+		case DeleteAction:
+			before = e.Rows[i]
+		case UpdateAction:
+			before, after = e.Rows[i], e.Rows[i+1]
+		}
+		msg, err := h.encoder.Encode(topic, e, before, after)
+		if err != nil {
+			return nil, errors.Annotatef(err, "encode %s row of %s", e.Action, e.Table)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+func (h *SinkHandler) String() string {
+	return "SinkHandler"
+}
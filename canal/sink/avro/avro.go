@@ -0,0 +1,335 @@
+// Package avro encodes the changes of the rows of a go-mysql canal in Avro, with goavro and the schemas registered by
+// a srclient client of a Confluent schema registry.
+//
+//	registry := srclient.CreateSchemaRegistryClient("http://127.0.0.1:8081")
+//	c.SetEventHandler(canal.NewSinkHandler(sink, avro.NewEncoder("server1", registry), "server1"))
+package avro
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/pingcap/errors"
+	"github.com/riferrei/srclient"
+
+	"github.com/atoonk/go-mysql/canal"
+	"github.com/atoonk/go-mysql/schema"
+)
+
+// Encoder encodes the changes of the rows as Debezium does with the Avro converter: the key and the value are
+// the records of canal.JSONEncoder encoded in Avro, in the Confluent wire format, with their schemas registered in a
+// schema registry under the subjects <topic>-key and <topic>-value.
+type Encoder struct {
+	name     string
+	registry srclient.ISchemaRegistryClient
+
+	m sync.Mutex
+	// schemas are the schemas registered, by subject and schema
+	schemas map[string]*avroSchema
+}
+
+var _ canal.Encoder = (*Encoder)(nil)
+
+// avroSchema is a schema registered with its id, and its codec.
+type avroSchema struct {
+	id    int
+	codec *goavro.Codec
+}
+
+// NewEncoder creates an encoder registering the schemas in a schema registry, e.g.
+// srclient.CreateSchemaRegistryClient(url), name is the logical name of the server in the source of the changes.
+func NewEncoder(name string, registry srclient.ISchemaRegistryClient) *Encoder {
+	return &Encoder{
+		name:     name,
+		registry: registry,
+		schemas:  make(map[string]*avroSchema),
+	}
+}
+
+type avroField struct {
+	Name    string          `json:"name"`
+	Type    interface{}     `json:"type"`
+	Default json.RawMessage `json:"default,omitempty"`
+}
+
+type avroRecord struct {
+	Type      string      `json:"type"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace,omitempty"`
+	Fields    []avroField `json:"fields"`
+}
+
+var avroNull = json.RawMessage("null")
+
+func (e *Encoder) Encode(topic string, ev *canal.RowsEvent, before []interface{}, after []interface{}) (*canal.Message, error) {
+	namespace := avroNamespace(topic)
+	row := avroRecord{Type: "record", Name: "Value", Namespace: namespace}
+	for i := range ev.Table.Columns {
+		c := &ev.Table.Columns[i]
+		t, _ := avroType(c)
+		row.Fields = append(row.Fields, avroField{Name: avroName(c.Name), Type: []interface{}{"null", t}, Default: avroNull})
+	}
+	source := avroRecord{Type: "record", Name: "Source", Fields: []avroField{
+		{Name: "connector", Type: "string"},
+		{Name: "name", Type: "string"},
+		{Name: "ts_ms", Type: "long"},
+		{Name: "snapshot", Type: "string"},
+		{Name: "db", Type: "string"},
+		{Name: "table", Type: "string"},
+		{Name: "server_id", Type: "long"},
+		{Name: "pos", Type: "long"},
+	}}
+	envelope := avroRecord{Type: "record", Name: "Envelope", Namespace: namespace, Fields: []avroField{
+		{Name: "before", Type: []interface{}{"null", row}, Default: avroNull},
+		{Name: "after", Type: []string{"null", "Value"}, Default: avroNull},
+		{Name: "source", Type: source},
+		{Name: "op", Type: "string"},
+		{Name: "ts_ms", Type: []string{"null", "long"}, Default: avroNull},
+	}}
+
+	s := canal.NewDebeziumSource(e.name, ev)
+	value := map[string]interface{}{
+		"before": nil,
+		"after":  nil,
+		"source": map[string]interface{}{
+			"connector": s.Connector,
+			"name":      s.Name,
+			"ts_ms":     s.TsMs,
+			"snapshot":  s.Snapshot,
+			"db":        s.DB,
+			"table":     s.Table,
+			"server_id": s.ServerID,
+			"pos":       s.Pos,
+		},
+		"op":    canal.DebeziumOp(ev, before, after),
+		"ts_ms": goavro.Union("long", time.Now().UnixNano()/int64(time.Millisecond)),
+	}
+	for field, r := range map[string][]interface{}{"before": before, "after": after} {
+		if r == nil {
+			continue
+		}
+		record := make(map[string]interface{}, len(ev.Table.Columns))
+		for i := range ev.Table.Columns {
+			c := &ev.Table.Columns[i]
+			v, err := avroValue(c, rowValue(r, i))
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if v != nil {
+				_, name := avroType(c)
+				v = goavro.Union(name, v)
+			}
+			record[avroName(c.Name)] = v
+		}
+		value[field] = goavro.Union(namespace+".Value", record)
+	}
+
+	msg := &canal.Message{Topic: topic}
+	var err error
+	if msg.Value, err = e.encode(topic+"-value", envelope, value); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(ev.Table.PKColumns) == 0 {
+		return msg, nil
+	}
+
+	keySchema := avroRecord{Type: "record", Name: "Key", Namespace: namespace}
+	key := make(map[string]interface{}, len(ev.Table.PKColumns))
+	r := keyRow(before, after)
+	for _, i := range ev.Table.PKColumns {
+		c := &ev.Table.Columns[i]
+		t, _ := avroType(c)
+		keySchema.Fields = append(keySchema.Fields, avroField{Name: avroName(c.Name), Type: t})
+		v, err := avroValue(c, rowValue(r, i))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if v == nil {
+			return nil, errors.Errorf("null value of column %s", c.Name)
+		}
+		key[avroName(c.Name)] = v
+	}
+	if msg.Key, err = e.encode(topic+"-key", keySchema, key); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return msg, nil
+}
+
+// encode encodes a record with a schema in the Confluent wire format: a zero byte, the id of the schema and the
+// record in Avro.
+func (e *Encoder) encode(subject string, record avroRecord, v map[string]interface{}) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	s, err := e.schema(subject, string(data))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	b := make([]byte, 5)
+	binary.BigEndian.PutUint32(b[1:], uint32(s.id))
+	b, err = s.codec.BinaryFromNative(b, v)
+	return b, errors.Annotatef(err, "encode %s", subject)
+}
+
+// schema registers a schema under a subject, unless it was already, and returns it.
+func (e *Encoder) schema(subject string, schema string) (*avroSchema, error) {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	key := subject + "\x00" + schema
+	if s, ok := e.schemas[key]; ok {
+		return s, nil
+	}
+
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	registered, err := e.registry.CreateSchema(subject, schema, srclient.Avro)
+	if err != nil {
+		return nil, errors.Annotatef(err, "register schema of %s", subject)
+	}
+	s := &avroSchema{id: registered.ID(), codec: codec}
+	e.schemas[key] = s
+	return s, nil
+}
+
+// avroName returns a name valid in Avro, with the invalid characters replaced by underscores.
+func avroName(name string) string {
+	b := []byte(name)
+	for i, ch := range b {
+		if !(ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || i > 0 && ch >= '0' && ch <= '9') {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+// avroNamespace returns the namespace of the schemas of a topic.
+func avroNamespace(topic string) string {
+	names := strings.Split(topic, ".")
+	for i, name := range names {
+		names[i] = avroName(name)
+	}
+	return strings.Join(names, ".")
+}
+
+// avroUnsignedBigint is the type of the BIGINT UNSIGNED columns, whose values don't all fit in a long, a decimal as
+// with the precise bigint.unsigned.handling.mode of Debezium.
+var avroUnsignedBigint = map[string]interface{}{"type": "bytes", "logicalType": "decimal", "precision": 20, "scale": 0}
+
+// avroType returns the type of a column in the schemas, and its name in the unions.
+func avroType(c *schema.TableColumn) (interface{}, string) {
+	switch c.Type {
+	case schema.TYPE_NUMBER, schema.TYPE_MEDIUM_INT, schema.TYPE_BIT:
+		if c.Type == schema.TYPE_NUMBER && c.IsUnsigned && strings.HasPrefix(c.RawType, "bigint") {
+			return avroUnsignedBigint, "bytes.decimal"
+		}
+		return "long", "long"
+	case schema.TYPE_FLOAT:
+		return "double", "double"
+	case schema.TYPE_BINARY, schema.TYPE_POINT:
+		return "bytes", "bytes"
+	default:
+		return "string", "string"
+	}
+}
+
+// avroValue returns the value of a column as the Go value of its Avro type, nil for NULL.
+func avroValue(c *schema.TableColumn, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	v = canal.SinkValue(c, v)
+	switch _, name := avroType(c); name {
+	case "long":
+		n, err := avroLong(v)
+		return n, errors.Annotatef(err, "column %s", c.Name)
+	case "double":
+		switch v := v.(type) {
+		case float32:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		}
+		f, err := strconv.ParseFloat(fmt.Sprint(v), 64)
+		return f, errors.Annotatef(err, "column %s", c.Name)
+	case "bytes":
+		if b, ok := v.([]byte); ok {
+			return b, nil
+		}
+		return []byte(fmt.Sprint(v)), nil
+	case "string":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		return fmt.Sprint(v), nil
+	default:
+		r, ok := new(big.Rat).SetString(fmt.Sprint(v))
+		if !ok || !r.IsInt() {
+			return nil, errors.Errorf("invalid integer %v of column %s", v, c.Name)
+		}
+		return r, nil
+	}
+}
+
+func avroLong(v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return avroLong(uint64(v))
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, errors.Errorf("%d out of the range of a long", v)
+		}
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, errors.Errorf("invalid long %v of type %T", v, v)
+	}
+}
+
+// keyRow returns the row the key of a change is taken from.
+func keyRow(before []interface{}, after []interface{}) []interface{} {
+	if after != nil {
+		return after
+	}
+	return before
+}
+
+// rowValue returns the value of a column in a row, nil if the row is shorter than the table.
+func rowValue(row []interface{}, i int) interface{} {
+	if i < len(row) {
+		return row[i]
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package avro
+
+import (
+	"encoding/binary"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/riferrei/srclient"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/canal"
+	"github.com/atoonk/go-mysql/replication"
+	"github.com/atoonk/go-mysql/schema"
+)
+
+func testTable() *schema.Table {
+	ta := &schema.Table{Schema: "test", Name: "t"}
+	ta.AddColumn("id", "int(11)", "", "")
+	ta.AddColumn("name", "varchar(20)", "", "")
+	ta.AddColumn("state", "enum('on','off')", "", "")
+	ta.PKColumns = []int{0}
+	return ta
+}
+
+func TestEncoder(t *testing.T) {
+	registry := srclient.CreateMockSchemaRegistryClient("mock://registry")
+	e := NewEncoder("server1", registry)
+	ta := testTable()
+	ta.AddColumn("total", "bigint(20) unsigned", "", "")
+	ev := &canal.RowsEvent{Table: ta, Action: canal.DeleteAction, Header: &replication.EventHeader{Timestamp: 1, ServerID: 7, LogPos: 500, EventSize: 100}}
+	msg, err := e.Encode("server1.test.t", ev, []interface{}{int32(-2), "ab", int64(2), uint64(math.MaxUint64)}, nil)
+	require.NoError(t, err)
+	// the schemas are registered once
+	_, err = e.Encode("server1.test.t", ev, []interface{}{int32(3), nil, int64(1), nil}, nil)
+	require.NoError(t, err)
+	subjects, err := registry.GetSubjects()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"server1.test.t-value", "server1.test.t-key"}, subjects)
+
+	// decode decodes a message of the Confluent wire format with its registered schema
+	decode := func(subject string, data []byte) map[string]interface{} {
+		require.Equal(t, byte(0), data[0])
+		s, err := registry.GetLatestSchema(subject)
+		require.NoError(t, err)
+		require.Equal(t, uint32(s.ID()), binary.BigEndian.Uint32(data[1:5]))
+		v, rest, err := s.Codec().NativeFromBinary(data[5:])
+		require.NoError(t, err)
+		require.Empty(t, rest)
+		return v.(map[string]interface{})
+	}
+
+	require.Equal(t, map[string]interface{}{"id": int64(-2)}, decode("server1.test.t-key", msg.Key))
+	value := decode("server1.test.t-value", msg.Value)
+	require.Nil(t, value["after"])
+	require.Equal(t, map[string]interface{}{"server1.test.t.Value": map[string]interface{}{
+		"id":    map[string]interface{}{"long": int64(-2)},
+		"name":  map[string]interface{}{"string": "ab"},
+		"state": map[string]interface{}{"string": "off"},
+		"total": map[string]interface{}{"bytes.decimal": new(big.Rat).SetUint64(math.MaxUint64)},
+	}}, value["before"])
+	require.Equal(t, "d", value["op"])
+	require.Equal(t, map[string]interface{}{
+		"connector": "mysql",
+		"name":      "server1",
+		"ts_ms":     int64(1000),
+		"snapshot":  "false",
+		"db":        "test",
+		"table":     "t",
+		"server_id": int64(7),
+		"pos":       int64(400),
+	}, value["source"])
+
+	// the unsigned integers beyond the range of a long aren't truncated
+	ta.AddColumn("flags", "bit(64)", "", "")
+	_, err = e.Encode("server1.test.t", ev, []interface{}{int32(3), nil, int64(1), nil, uint64(math.MaxUint64)}, nil)
+	require.Error(t, err)
+}
+
+func TestEncoderMaskedColumns(t *testing.T) {
+	// the INT column masked by redact as the column filters of the canal type it
+	ta := testTable()
+	ta.Columns[0] = schema.TableColumn{Name: "id", Type: schema.TYPE_STRING, RawType: "text"}
+	row := []interface{}{canal.MaskRedact(&ta.Columns[0], int32(1)), "a", int64(1)}
+
+	registry := srclient.CreateMockSchemaRegistryClient("mock://registry")
+	msg, err := NewEncoder("server1", registry).Encode("server1.test.t", &canal.RowsEvent{Table: ta, Action: canal.InsertAction}, nil, row)
+	require.NoError(t, err)
+
+	s, err := registry.GetLatestSchema("server1.test.t-value")
+	require.NoError(t, err)
+	v, _, err := s.Codec().NativeFromBinary(msg.Value[5:])
+	require.NoError(t, err)
+	after := v.(map[string]interface{})["after"].(map[string]interface{})["server1.test.t.Value"].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{"string": "REDACTED"}, after["id"])
+	s, err = registry.GetLatestSchema("server1.test.t-key")
+	require.NoError(t, err)
+	v, _, err = s.Codec().NativeFromBinary(msg.Key[5:])
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"id": "REDACTED"}, v)
+}
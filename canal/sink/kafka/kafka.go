@@ -0,0 +1,73 @@
+// Package kafka publishes the changes of the rows of a go-mysql canal to Kafka, with a sarama producer.
+//
+//	sink, err := kafka.Dial([]string{"127.0.0.1:9092"}, nil)
+//	c.SetEventHandler(canal.NewSinkHandler(sink, canal.NewJSONEncoder("server1"), "server1"))
+package kafka
+
+import (
+	stderrors "errors"
+
+	"github.com/IBM/sarama"
+	"github.com/pingcap/errors"
+
+	"github.com/atoonk/go-mysql/canal"
+)
+
+// Sink implements canal.Sink with the Kafka topics named after the tables, the messages being keyed by the primary
+// key of their rows so that the hash partitioner of the producer keeps the changes of a row in a partition, in
+// order.
+type Sink struct {
+	producer sarama.SyncProducer
+}
+
+var _ canal.Sink = (*Sink)(nil)
+
+// New creates a sink publishing with producer, see Dial for its config.
+func New(producer sarama.SyncProducer) *Sink {
+	return &Sink{producer: producer}
+}
+
+// Dial creates a sink with a producer of the brokers at addrs. The config is sarama.NewConfig() if nil, with the
+// messages acknowledged by all the in-sync replicas and a request in flight at a time, so that the retries don't
+// reorder them.
+func Dial(addrs []string, cfg *sarama.Config) (*Sink, error) {
+	if cfg == nil {
+		cfg = sarama.NewConfig()
+		cfg.Producer.RequiredAcks = sarama.WaitForAll
+		cfg.Net.MaxOpenRequests = 1
+	}
+	cfg.Producer.Return.Successes = true
+
+	producer, err := sarama.NewSyncProducer(addrs, cfg)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return New(producer), nil
+}
+
+// Publish returns once the messages are acknowledged, or the first error of the ones which aren't.
+func (s *Sink) Publish(msgs []*canal.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	records := make([]*sarama.ProducerMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		record := &sarama.ProducerMessage{Topic: msg.Topic, Value: sarama.ByteEncoder(msg.Value)}
+		if msg.Key != nil {
+			record.Key = sarama.ByteEncoder(msg.Key)
+		}
+		records = append(records, record)
+	}
+
+	err := s.producer.SendMessages(records)
+	var errs sarama.ProducerErrors
+	if stderrors.As(err, &errs) && len(errs) > 0 {
+		return errors.Annotatef(errs[0].Err, "publish to %s", errs[0].Msg.Topic)
+	}
+	return errors.Trace(err)
+}
+
+func (s *Sink) Close() error {
+	return errors.Trace(s.producer.Close())
+}
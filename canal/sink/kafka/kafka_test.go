@@ -0,0 +1,52 @@
+package kafka
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/canal"
+)
+
+func TestSink(t *testing.T) {
+	cfg := mocks.NewTestConfig()
+	cfg.Producer.Return.Successes = true
+	producer := mocks.NewSyncProducer(t, cfg)
+
+	check := func(topic string, key []byte, value string) mocks.MessageChecker {
+		return func(msg *sarama.ProducerMessage) error {
+			if msg.Topic != topic {
+				return fmt.Errorf("topic %s, expected %s", msg.Topic, topic)
+			}
+			var k []byte
+			if msg.Key != nil {
+				k, _ = msg.Key.Encode()
+			}
+			if string(k) != string(key) || (k == nil) != (key == nil) {
+				return fmt.Errorf("key %q, expected %q", k, key)
+			}
+			if v, _ := msg.Value.Encode(); string(v) != value {
+				return fmt.Errorf("value %q, expected %q", v, value)
+			}
+			return nil
+		}
+	}
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(check("server1.test.t", []byte(`{"id":1}`), `{"op":"c"}`))
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(check("server1.test.t", nil, `{"op":"d"}`))
+	producer.ExpectSendMessageAndFail(sarama.ErrNotLeaderForPartition)
+
+	s := New(producer)
+	require.NoError(t, s.Publish(nil))
+	require.NoError(t, s.Publish([]*canal.Message{
+		{Topic: "server1.test.t", Key: []byte(`{"id":1}`), Value: []byte(`{"op":"c"}`)},
+		{Topic: "server1.test.t", Value: []byte(`{"op":"d"}`)},
+	}))
+
+	err := s.Publish([]*canal.Message{{Topic: "server1.test.t", Value: []byte(`{"op":"u"}`)}})
+	require.ErrorIs(t, err, sarama.ErrNotLeaderForPartition)
+
+	require.NoError(t, s.Close())
+}
@@ -0,0 +1,62 @@
+// Package nats publishes the changes of the rows of a go-mysql canal to NATS, with a nats.go connection.
+//
+//	sink, err := nats.Connect("nats://127.0.0.1:4222")
+//	c.SetEventHandler(canal.NewSinkHandler(sink, canal.NewJSONEncoder("server1"), "server1"))
+package nats
+
+import (
+	"encoding/base64"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pingcap/errors"
+
+	"github.com/atoonk/go-mysql/canal"
+)
+
+// KeyHeader is the header of the messages holding the base64 of their key, NATS having no keys of its own.
+const KeyHeader = "Key"
+
+// Sink implements canal.Sink with the NATS subjects named after the tables.
+type Sink struct {
+	conn *nats.Conn
+}
+
+var _ canal.Sink = (*Sink)(nil)
+
+// New creates a sink publishing with conn.
+func New(conn *nats.Conn) *Sink {
+	return &Sink{conn: conn}
+}
+
+// Connect creates a sink with a connection to the servers at url, see nats.Connect.
+func Connect(url string, opts ...nats.Option) (*Sink, error) {
+	conn, err := nats.Connect(url, opts...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return New(conn), nil
+}
+
+// Publish returns once the server has processed the messages.
+func (s *Sink) Publish(msgs []*canal.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	for _, msg := range msgs {
+		m := nats.NewMsg(msg.Topic)
+		m.Data = msg.Value
+		if msg.Key != nil {
+			m.Header.Set(KeyHeader, base64.StdEncoding.EncodeToString(msg.Key))
+		}
+		if err := s.conn.PublishMsg(m); err != nil {
+			return errors.Annotatef(err, "publish to %s", msg.Topic)
+		}
+	}
+	return errors.Trace(s.conn.Flush())
+}
+
+func (s *Sink) Close() error {
+	s.conn.Close()
+	return nil
+}
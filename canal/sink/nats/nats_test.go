@@ -0,0 +1,47 @@
+package nats
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/canal"
+)
+
+func TestSink(t *testing.T) {
+	srv := natsserver.RunRandClientPortServer()
+	defer srv.Shutdown()
+
+	sub, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err)
+	defer sub.Close()
+	ch, err := sub.SubscribeSync("server1.test.>")
+	require.NoError(t, err)
+	require.NoError(t, sub.Flush())
+
+	s, err := Connect(srv.ClientURL())
+	require.NoError(t, err)
+	require.NoError(t, s.Publish(nil))
+	require.NoError(t, s.Publish([]*canal.Message{
+		{Topic: "server1.test.t", Key: []byte(`{"id":1}`), Value: []byte(`{"op":"c"}`)},
+		{Topic: "server1.test.t", Value: []byte(`{"op":"d"}`)},
+	}))
+	require.NoError(t, s.Close())
+
+	m, err := ch.NextMsg(time.Second)
+	require.NoError(t, err)
+	require.Equal(t, "server1.test.t", m.Subject)
+	require.Equal(t, `{"op":"c"}`, string(m.Data))
+	key, err := base64.StdEncoding.DecodeString(m.Header.Get(KeyHeader))
+	require.NoError(t, err)
+	require.Equal(t, `{"id":1}`, string(key))
+
+	m, err = ch.NextMsg(time.Second)
+	require.NoError(t, err)
+	require.Equal(t, `{"op":"d"}`, string(m.Data))
+	require.Empty(t, m.Header.Values(KeyHeader))
+}
@@ -0,0 +1,183 @@
+package canal
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/atoonk/go-mysql/schema"
+)
+
+// DebeziumSource is the source of a change in the envelope of Debezium, see JSONEncoder.
+type DebeziumSource struct {
+	Connector string `json:"connector"`
+	Name      string `json:"name"`
+	TsMs      int64  `json:"ts_ms"`
+	Snapshot  string `json:"snapshot"`
+	DB        string `json:"db"`
+	Table     string `json:"table"`
+	ServerID  int64  `json:"server_id"`
+	Pos       int64  `json:"pos"`
+}
+
+// NewDebeziumSource returns the source of the changes of an event, name is the logical name of the server.
+func NewDebeziumSource(name string, e *RowsEvent) DebeziumSource {
+	s := DebeziumSource{
+		Connector: "mysql",
+		Name:      name,
+		TsMs:      time.Now().UnixNano() / int64(time.Millisecond),
+		Snapshot:  strconv.FormatBool(e.Snapshot),
+		DB:        e.Table.Schema,
+		Table:     e.Table.Name,
+	}
+	if e.Header != nil {
+		s.TsMs = int64(e.Header.Timestamp) * 1000
+		s.ServerID = int64(e.Header.ServerID)
+		s.Pos = int64(e.Header.LogPos) - int64(e.Header.EventSize)
+	}
+	return s
+}
+
+// DebeziumOp returns the operation of a change in the envelope of Debezium, r for the rows read by a snapshot.
+func DebeziumOp(e *RowsEvent, before []interface{}, after []interface{}) string {
+	switch {
+	case e.Snapshot:
+		return "r"
+	case before == nil:
+		return "c"
+	case after == nil:
+		return "d"
+	default:
+		return "u"
+	}
+}
+
+// keyRow returns the row the key of a change is taken from.
+func keyRow(before []interface{}, after []interface{}) []interface{} {
+	if after != nil {
+		return after
+	}
+	return before
+}
+
+// rowValue returns the value of a column in a row, nil if the row is shorter than the table.
+func rowValue(row []interface{}, i int) interface{} {
+	if i < len(row) {
+		return row[i]
+	}
+	return nil
+}
+
+// SinkValue returns the value of a column for a sink: the names of the values of ENUM and SET, the text of the
+// columns of text rather than bytes.
+func SinkValue(c *schema.TableColumn, v interface{}) interface{} {
+	switch c.Type {
+	case schema.TYPE_ENUM:
+		if i, ok := v.(int64); ok {
+			if i > 0 && int(i) <= len(c.EnumValues) {
+				return c.EnumValues[i-1]
+			}
+			return ""
+		}
+	case schema.TYPE_SET:
+		if bits, ok := v.(int64); ok {
+			var values []string
+			for i, value := range c.SetValues {
+				if bits&(1<<uint(i)) != 0 {
+					values = append(values, value)
+				}
+			}
+			return strings.Join(values, ",")
+		}
+	case schema.TYPE_BINARY, schema.TYPE_POINT:
+		return v
+	}
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
+
+// JSONEncoder encodes the changes of the rows as Debezium does with the JSON converter without schemas: the key is
+// the object of the primary key of the row and the value the envelope of the change, with its before and after rows,
+// its source and its operation, c, u, d or r.
+type JSONEncoder struct {
+	// name is the logical name of the server in the source of the changes
+	name string
+}
+
+func NewJSONEncoder(name string) *JSONEncoder {
+	return &JSONEncoder{name: name}
+}
+
+func (e *JSONEncoder) Encode(topic string, ev *RowsEvent, before []interface{}, after []interface{}) (*Message, error) {
+	envelope := struct {
+		Before json.RawMessage `json:"before"`
+		After  json.RawMessage `json:"after"`
+		Source DebeziumSource  `json:"source"`
+		Op     string          `json:"op"`
+		TsMs   int64           `json:"ts_ms"`
+	}{
+		Source: NewDebeziumSource(e.name, ev),
+		Op:     DebeziumOp(ev, before, after),
+		TsMs:   time.Now().UnixNano() / int64(time.Millisecond),
+	}
+
+	var err error
+	if envelope.Before, err = jsonRow(ev.Table, before, nil); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if envelope.After, err = jsonRow(ev.Table, after, nil); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	msg := &Message{Topic: topic}
+	if msg.Value, err = json.Marshal(envelope); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(ev.Table.PKColumns) > 0 {
+		if msg.Key, err = jsonRow(ev.Table, keyRow(before, after), ev.Table.PKColumns); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return msg, nil
+}
+
+// jsonRow encodes the columns of a row as an object in the order of the table, all of them if columns is nil.
+func jsonRow(table *schema.Table, row []interface{}, columns []int) (json.RawMessage, error) {
+	if row == nil {
+		return nil, nil
+	}
+	if columns == nil {
+		columns = make([]int, len(table.Columns))
+		for i := range columns {
+			columns[i] = i
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for n, i := range columns {
+		c := &table.Columns[i]
+		name, err := json.Marshal(c.Name)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		value, err := json.Marshal(SinkValue(c, rowValue(row, i)))
+		if err != nil {
+			return nil, errors.Annotatef(err, "column %s", c.Name)
+		}
+		if n > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
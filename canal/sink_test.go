@@ -0,0 +1,69 @@
+package canal
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/replication"
+	"github.com/atoonk/go-mysql/schema"
+)
+
+type sinkRecorder struct {
+	msgs []*Message
+}
+
+func (s *sinkRecorder) Publish(msgs []*Message) error {
+	s.msgs = append(s.msgs, msgs...)
+	return nil
+}
+
+func (s *sinkRecorder) Close() error {
+	return nil
+}
+
+func sinkTestTable() *schema.Table {
+	ta := &schema.Table{Schema: "test", Name: "t"}
+	ta.AddColumn("id", "int(11)", "", "")
+	ta.AddColumn("name", "varchar(20)", "", "")
+	ta.AddColumn("state", "enum('on','off')", "", "")
+	ta.PKColumns = []int{0}
+	return ta
+}
+
+func TestSinkHandlerJSON(t *testing.T) {
+	s := &sinkRecorder{}
+	h := NewSinkHandler(s, NewJSONEncoder("server1"), "server1")
+	ta := sinkTestTable()
+	header := &replication.EventHeader{Timestamp: 1700000000, ServerID: 7, LogPos: 500, EventSize: 100}
+
+	require.NoError(t, h.OnRow(&RowsEvent{Table: ta, Action: UpdateAction, Header: header,
+		Rows: [][]interface{}{{int32(1), []byte("a"), int64(1)}, {int32(1), "b", int64(2)}}}))
+	require.NoError(t, h.OnRow(&RowsEvent{Table: ta, Action: InsertAction, Snapshot: true,
+		Rows: [][]interface{}{{int32(2), nil, int64(1)}}}))
+	require.Len(t, s.msgs, 2)
+
+	msg := s.msgs[0]
+	require.Equal(t, "server1.test.t", msg.Topic)
+	require.Equal(t, `{"id":1}`, string(msg.Key))
+	var envelope map[string]interface{}
+	require.NoError(t, json.Unmarshal(msg.Value, &envelope))
+	require.Equal(t, map[string]interface{}{"id": 1.0, "name": "a", "state": "on"}, envelope["before"])
+	require.Equal(t, map[string]interface{}{"id": 1.0, "name": "b", "state": "off"}, envelope["after"])
+	require.Equal(t, "u", envelope["op"])
+	source := envelope["source"].(map[string]interface{})
+	require.Equal(t, "server1", source["name"])
+	require.Equal(t, "test", source["db"])
+	require.Equal(t, "t", source["table"])
+	require.Equal(t, 1700000000000.0, source["ts_ms"])
+	require.Equal(t, 7.0, source["server_id"])
+	require.Equal(t, 400.0, source["pos"])
+	require.Equal(t, "false", source["snapshot"])
+
+	envelope = nil
+	require.NoError(t, json.Unmarshal(s.msgs[1].Value, &envelope))
+	require.Nil(t, envelope["before"])
+	require.Equal(t, map[string]interface{}{"id": 2.0, "name": nil, "state": "on"}, envelope["after"])
+	require.Equal(t, "r", envelope["op"])
+}
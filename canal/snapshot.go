@@ -0,0 +1,315 @@
+package canal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/schema"
+)
+
+// snapshotSystemDatabases are not dumped unless they are listed.
+var snapshotSystemDatabases = map[string]bool{
+	"information_schema": true,
+	"mysql":              true,
+	"performance_schema": true,
+	"sys":                true,
+}
+
+// dumpSnapshot reads the rows of the tables dumped in a consistent snapshot of the master, see DumpConfig.Snapshot,
+// and sets the position of the binlog the snapshot was started at, so that the rows changed after the snapshot are
+// read from the binlog, and the rows changed before are not read twice.
+func (c *Canal) dumpSnapshot() error {
+	c.master.UpdateTimestamp(uint32(time.Now().Unix()))
+
+	var options []func(*client.Conn)
+	if c.cfg.TLSConfig != nil {
+		options = append(options, func(conn *client.Conn) {
+			conn.SetTLSConfig(c.cfg.TLSConfig)
+		})
+	}
+	conn, err := c.connect(options...)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	c.cfg.Logger.Info("try dump MySQL with a consistent snapshot")
+	pos, gset, err := c.startSnapshot(conn)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	tables, err := c.snapshotTables(conn)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, t := range tables {
+		if err := c.snapshotTable(conn, t.db, t.table); err != nil {
+			return errors.Annotatef(err, "dump table %s.%s", t.db, t.table)
+		}
+	}
+	if _, err := conn.Execute("COMMIT"); err != nil {
+		return errors.Trace(err)
+	}
+
+	c.master.Update(pos)
+	c.master.UpdateGTIDSet(gset)
+	if gset != nil {
+		c.snapshotGTIDSet = gset.Clone()
+	}
+	if err := c.eventHandler.OnPosSynced(nil, pos, c.master.GTIDSet(), true); err != nil {
+		return errors.Trace(err)
+	}
+	var startPos fmt.Stringer = pos
+	if gset != nil {
+		startPos = gset
+	}
+	c.cfg.Logger.Infof("dump MySQL with a consistent snapshot OK, use %0.2f seconds, start binlog replication at %s",
+		time.Since(start).Seconds(), startPos)
+	return nil
+}
+
+// startSnapshot starts a consistent snapshot with conn, and returns the binlog position and the GTID set of the
+// master at the time, the set only if GTID is used. The commits are blocked by FLUSH TABLES WITH READ LOCK while
+// the snapshot is started, or if SkipMasterData is set, the position of the snapshot is read in the snapshot, see
+// snapshotPosition.
+func (c *Canal) startSnapshot(conn *client.Conn) (mysql.Position, mysql.GTIDSet, error) {
+	if _, err := conn.Execute("SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+		return mysql.Position{}, nil, errors.Trace(err)
+	}
+
+	if c.cfg.Dump.SkipMasterData {
+		if _, err := conn.Execute("START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+			return mysql.Position{}, nil, errors.Trace(err)
+		}
+		return c.snapshotPosition(conn)
+	}
+
+	if _, err := conn.Execute("FLUSH TABLES WITH READ LOCK"); err != nil {
+		return mysql.Position{}, nil, errors.Annotate(err, "lock the tables, set skip_master_data without the privilege")
+	}
+	defer func() {
+		_, _ = conn.Execute("UNLOCK TABLES")
+	}()
+
+	pos, gset, err := c.masterPosition()
+	if err != nil {
+		return mysql.Position{}, nil, errors.Trace(err)
+	}
+	if _, err := conn.Execute("START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+		return mysql.Position{}, nil, errors.Trace(err)
+	}
+	return pos, gset, nil
+}
+
+// snapshotPosition returns the binlog position of the snapshot started by conn without locking the tables, and its
+// GTID set if GTID is used, as told by the binlog_snapshot_file and binlog_snapshot_position status of MariaDB and
+// Percona Server, consistent with the snapshot. The GTID set is the one of the position for MariaDB, and the
+// binlog_snapshot_gtid_executed status of Percona Server 8.0. MySQL has no such status, the tables must be locked.
+func (c *Canal) snapshotPosition(conn *client.Conn) (mysql.Position, mysql.GTIDSet, error) {
+	r, err := conn.Execute("SHOW SESSION STATUS LIKE 'binlog\\_snapshot\\_%'")
+	if err != nil {
+		return mysql.Position{}, nil, errors.Trace(err)
+	}
+	status := make(map[string]string)
+	for i := 0; i < r.RowNumber(); i++ {
+		name, _ := r.GetString(i, 0)
+		value, _ := r.GetString(i, 1)
+		status[strings.ToLower(name)] = value
+	}
+
+	name := status["binlog_snapshot_file"]
+	if name == "" {
+		return mysql.Position{}, nil, errors.New("no binlog position of the snapshot without the binlog_snapshot_file " +
+			"status of MariaDB or Percona Server, unset skip_master_data to lock the tables")
+	}
+	offset, err := strconv.ParseUint(status["binlog_snapshot_position"], 10, 32)
+	if err != nil {
+		return mysql.Position{}, nil, errors.Trace(err)
+	}
+	pos := mysql.Position{Name: name, Pos: uint32(offset)}
+	if c.master.GTIDSet() == nil {
+		return pos, nil, nil
+	}
+
+	var gx string
+	if c.cfg.Flavor == mysql.MariaDBFlavor {
+		r, err := conn.Execute("SELECT BINLOG_GTID_POS(?, ?)", pos.Name, pos.Pos)
+		if err != nil {
+			return mysql.Position{}, nil, errors.Trace(err)
+		}
+		gx, _ = r.GetString(0, 0)
+	} else {
+		var ok bool
+		if gx, ok = status["binlog_snapshot_gtid_executed"]; !ok {
+			return mysql.Position{}, nil, errors.New("no GTID set of the snapshot without the " +
+				"binlog_snapshot_gtid_executed status of Percona Server, unset skip_master_data to lock the tables")
+		}
+		gx = strings.ReplaceAll(gx, "\n", "")
+	}
+	gset, err := mysql.ParseGTIDSet(c.cfg.Flavor, gx)
+	if err != nil {
+		return mysql.Position{}, nil, errors.Trace(err)
+	}
+	return pos, gset, nil
+}
+
+// snapshotted reports whether the transaction of a GTID event of the binlog was read by the snapshot, its rows are
+// then skipped. The binlog is synced from the GTID set of the snapshot, none should be, unless it is synced from an
+// earlier position, e.g. a position saved before the snapshot.
+func (c *Canal) snapshotted(e mysql.BinlogGTIDEvent) bool {
+	if c.snapshotGTIDSet == nil {
+		return false
+	}
+	next, err := e.GTIDNext()
+	if err != nil {
+		return false
+	}
+	return c.snapshotGTIDSet.Contain(next)
+}
+
+// masterPosition returns the binlog position of the master, and its GTID set if GTID is used, see dump.
+func (c *Canal) masterPosition() (mysql.Position, mysql.GTIDSet, error) {
+	pos, err := c.GetMasterPos()
+	if err != nil {
+		return mysql.Position{}, nil, errors.Trace(err)
+	}
+	if c.master.GTIDSet() == nil {
+		return pos, nil, nil
+	}
+	gset, err := c.GetMasterGTIDSet()
+	if err != nil {
+		return mysql.Position{}, nil, errors.Trace(err)
+	}
+	return pos, gset, nil
+}
+
+// snapshotTables returns the tables dumped, the tables of DumpConfig or the base tables of the databases which are
+// not system databases, except the ignored tables.
+func (c *Canal) snapshotTables(conn *client.Conn) ([]*node, error) {
+	ignored := make(map[string]bool)
+	for _, ignoreTable := range c.cfg.Dump.IgnoreTables {
+		if seps := strings.Split(ignoreTable, ","); len(seps) == 2 {
+			ignored[seps[0]+"."+seps[1]] = true
+		}
+	}
+
+	var tables []*node
+	if len(c.cfg.Dump.Tables) > 0 {
+		for _, table := range c.cfg.Dump.Tables {
+			tables = append(tables, &node{db: c.cfg.Dump.TableDB, table: table})
+		}
+	} else {
+		dbs := c.cfg.Dump.Databases
+		if len(dbs) == 0 {
+			r, err := conn.Execute("SHOW DATABASES")
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			for i := 0; i < r.RowNumber(); i++ {
+				db, err := r.GetString(i, 0)
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+				if !snapshotSystemDatabases[strings.ToLower(db)] {
+					dbs = append(dbs, db)
+				}
+			}
+		}
+
+		for _, db := range dbs {
+			r, err := conn.Execute(fmt.Sprintf("SHOW FULL TABLES FROM %s WHERE Table_type = 'BASE TABLE'", quoteName(db)))
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			for i := 0; i < r.RowNumber(); i++ {
+				table, err := r.GetString(i, 0)
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+				tables = append(tables, &node{db: db, table: table})
+			}
+		}
+	}
+
+	n := 0
+	for _, t := range tables {
+		if !ignored[t.db+"."+t.table] {
+			tables[n] = t
+			n++
+		}
+	}
+	return tables[:n], nil
+}
+
+// snapshotTable reads the rows of a table in the snapshot, and passes them to OnRow as inserted rows.
+func (c *Canal) snapshotTable(conn *client.Conn, db string, table string) error {
+	t, err := c.GetTable(db, table)
+	if err != nil {
+		e := errors.Cause(err)
+		if e == ErrExcludedTable ||
+			e == schema.ErrTableNotExist ||
+			e == schema.ErrMissingTableMeta {
+			return nil
+		}
+		return errors.Trace(err)
+	}
+
+	columns := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		columns[i] = quoteName(column.Name)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s.%s", strings.Join(columns, ", "), quoteName(db), quoteName(table))
+	if c.cfg.Dump.Where != "" {
+		query += " WHERE " + c.cfg.Dump.Where
+	}
+
+	var result mysql.Result
+	return conn.ExecuteSelectStreaming(query, &result, func(row []mysql.FieldValue) error {
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+
+		values := make([]interface{}, len(row))
+		for i := range row {
+			if values[i], err = c.snapshotValue(&t.Columns[i], &row[i]); err != nil {
+				return errors.Annotatef(err, "column %s", t.Columns[i].Name)
+			}
+		}
+		e := c.newRowsEvent(t, InsertAction, [][]interface{}{values}, nil)
+		e.Snapshot = true
+		return c.eventHandler.OnRow(e)
+	}, nil)
+}
+
+// snapshotValue returns the value of a column read by the snapshot, of the type of the values read by mysqldump.
+func (c *Canal) snapshotValue(column *schema.TableColumn, v *mysql.FieldValue) (interface{}, error) {
+	if v.Type != mysql.FieldValueTypeString {
+		return v.Value(), nil
+	}
+
+	// the buffer of the value is reused by the next row
+	s := string(v.AsString())
+	if column.Type != schema.TYPE_DECIMAL {
+		return s, nil
+	}
+	if c.cfg.UseDecimal {
+		d, err := decimal.NewFromString(s)
+		return d, errors.Trace(err)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, errors.Trace(err)
+}
+
+// quoteName quotes an identifier with backticks.
+func quoteName(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
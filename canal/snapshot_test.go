@@ -0,0 +1,107 @@
+package canal
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/siddontang/go-log/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/replication"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// snapshotStatusHandler answers SHOW STATUS with the binlog_snapshot status of its values.
+type snapshotStatusHandler struct {
+	server.EmptyHandler
+	status [][]interface{}
+}
+
+func (h *snapshotStatusHandler) HandleQuery(query string) (*mysql.Result, error) {
+	if !strings.HasPrefix(query, "SHOW SESSION STATUS") {
+		return h.EmptyHandler.HandleQuery(query)
+	}
+	r, err := mysql.BuildSimpleTextResultset([]string{"Variable_name", "Value"}, h.status)
+	if err != nil {
+		return nil, err
+	}
+	return &mysql.Result{Resultset: r}, nil
+}
+
+// connectSnapshotStatus returns a connection to a server answering SHOW STATUS with status.
+func connectSnapshotStatus(t *testing.T, status [][]interface{}) *client.Conn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	svr := server.NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	p := server.NewInMemoryProvider()
+	p.AddUser("root", "")
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				co, err := server.NewCustomizedConn(conn, svr, p, &snapshotStatusHandler{status: status})
+				if err != nil {
+					return
+				}
+				for co.HandleCommand() == nil {
+				}
+			}()
+		}
+	}()
+
+	conn, err := client.Connect(l.Addr().String(), "root", "", "")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestSnapshotPosition(t *testing.T) {
+	uuid := "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	conn := connectSnapshotStatus(t, [][]interface{}{
+		{"Binlog_snapshot_file", "mysql-bin.000003"},
+		{"Binlog_snapshot_position", "1234"},
+		{"Binlog_snapshot_gtid_executed", uuid + ":1-10,\n4e11fa47-71ca-11e1-9e33-c80aa9429562:1"},
+	})
+
+	streamHandler, _ := log.NewStreamHandler(io.Discard)
+	c := &Canal{cfg: &Config{Flavor: mysql.MySQLFlavor}, master: &masterInfo{logger: log.NewDefault(streamHandler)}}
+	pos, gset, err := c.snapshotPosition(conn)
+	require.NoError(t, err)
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000003", Pos: 1234}, pos)
+	require.Nil(t, gset)
+
+	// the GTID set is read with GTID
+	empty, err := mysql.ParseMysqlGTIDSet("")
+	require.NoError(t, err)
+	c.master.UpdateGTIDSet(empty)
+	_, gset, err = c.snapshotPosition(conn)
+	require.NoError(t, err)
+	require.Equal(t, uuid+":1-10,4e11fa47-71ca-11e1-9e33-c80aa9429562:1", gset.String())
+
+	// MySQL has no position of the snapshot
+	conn = connectSnapshotStatus(t, nil)
+	_, _, err = c.snapshotPosition(conn)
+	require.ErrorContains(t, err, "unset skip_master_data")
+}
+
+func TestSnapshotted(t *testing.T) {
+	gset, err := mysql.ParseMysqlGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-10")
+	require.NoError(t, err)
+	sid := []byte{0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62}
+
+	c := &Canal{}
+	require.False(t, c.snapshotted(&replication.GTIDEvent{SID: sid, GNO: 10}))
+
+	c.snapshotGTIDSet = gset
+	require.True(t, c.snapshotted(&replication.GTIDEvent{SID: sid, GNO: 10}))
+	require.False(t, c.snapshotted(&replication.GTIDEvent{SID: sid, GNO: 11}))
+}
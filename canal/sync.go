@@ -1,6 +1,7 @@
 package canal
 
 import (
+	"context"
 	"sync/atomic"
 	"time"
 
@@ -32,12 +33,26 @@ func (c *Canal) startSyncer() (*replication.BinlogStreamer, error) {
 	}
 }
 
-func (c *Canal) runSyncBinlog() error {
+func (c *Canal) runSyncBinlog() (err error) {
 	s, err := c.startSyncer()
 	if err != nil {
 		return err
 	}
 
+	if c.cfg.RowsWorkers > 1 {
+		c.dispatcher = newRowsDispatcher(c.cfg.RowsWorkers, rowsQueueSize, c.cfg.RowsHash)
+		defer func() {
+			// the error of the rows handled is returned rather than the cancellation of the sync
+			if stopErr := c.dispatcher.stop(); stopErr != nil && (err == nil || errors.Cause(err) == context.Canceled) {
+				err = errors.Trace(stopErr)
+			}
+			c.dispatcher = nil
+		}()
+	}
+
+	atomic.StoreInt32(&c.stats.syncing, 1)
+	defer atomic.StoreInt32(&c.stats.syncing, 0)
+
 	savePos := false
 	force := false
 
@@ -49,6 +64,7 @@ func (c *Canal) runSyncBinlog() error {
 
 		// Update the delay between the Canal and the Master before the handler hooks are called
 		c.updateReplicationDelay(ev)
+		c.receiveEvent(ev)
 
 		// If log pos equals zero then the received event is a fake rotate event and
 		// contains only a name of the next binlog file
@@ -89,10 +105,13 @@ func (c *Canal) runSyncBinlog() error {
 			c.cfg.Logger.Infof("rotate binlog to %s", pos)
 			savePos = true
 			force = true
-			if err = c.eventHandler.OnRotate(ev.Header, e); err != nil {
+			if err = c.handle(func() error { return c.eventHandler.OnRotate(ev.Header, e) }); err != nil {
 				return errors.Trace(err)
 			}
 		case *replication.RowsEvent:
+			if c.skipSnapshotted {
+				continue
+			}
 			// we only focus row based event
 			err = c.handleRowsEvent(ev)
 			if err != nil {
@@ -100,36 +119,38 @@ func (c *Canal) runSyncBinlog() error {
 				return errors.Trace(err)
 			}
 			continue
-		case *replication.TransactionPayloadEvent:
-			// handle subevent row by row
-			ev := ev.Event.(*replication.TransactionPayloadEvent)
-			for _, subEvent := range ev.Events {
-				err = c.handleRowsEvent(subEvent)
-				if err != nil {
-					c.cfg.Logger.Errorf("handle transaction payload rows event at (%s, %d) error %v", pos.Name, curPos, err)
-					return errors.Trace(err)
-				}
-			}
-			continue
 		case *replication.XIDEvent:
 			savePos = true
 			// try to save the position later
-			if err := c.eventHandler.OnXID(ev.Header, pos); err != nil {
+			if err := c.handle(func() error { return c.eventHandler.OnXID(ev.Header, pos) }); err != nil {
 				return errors.Trace(err)
 			}
+			if c.cfg.BatchTransactions {
+				if err := c.commitTransaction(ev.Header, pos); err != nil {
+					return errors.Trace(err)
+				}
+			}
 			if e.GSet != nil {
 				c.master.UpdateGTIDSet(e.GSet)
 			}
 		case *replication.MariadbGTIDEvent:
-			if err := c.eventHandler.OnGTID(ev.Header, e); err != nil {
+			c.skipSnapshotted = c.snapshotted(e)
+			if err := c.handle(func() error { return c.eventHandler.OnGTID(ev.Header, e) }); err != nil {
 				return errors.Trace(err)
 			}
+			if c.cfg.BatchTransactions {
+				c.beginTransaction(e)
+			}
 		case *replication.GTIDEvent:
-			if err := c.eventHandler.OnGTID(ev.Header, e); err != nil {
+			c.skipSnapshotted = c.snapshotted(e)
+			if err := c.handle(func() error { return c.eventHandler.OnGTID(ev.Header, e) }); err != nil {
 				return errors.Trace(err)
 			}
+			if c.cfg.BatchTransactions {
+				c.beginTransaction(e)
+			}
 		case *replication.RowsQueryEvent:
-			if err := c.eventHandler.OnRowsQueryEvent(e); err != nil {
+			if err := c.handle(func() error { return c.eventHandler.OnRowsQueryEvent(e) }); err != nil {
 				return errors.Trace(err)
 			}
 		case *replication.QueryEvent:
@@ -139,6 +160,29 @@ func (c *Canal) runSyncBinlog() error {
 				continue
 			}
 			for _, stmt := range stmts {
+				if c.cfg.BatchTransactions {
+					switch stmt.(type) {
+					case *ast.BeginStmt:
+						c.beginTransaction(nil)
+					case *ast.CommitStmt:
+						// the transactions of the non-transactional tables
+						if err := c.commitTransaction(ev.Header, pos); err != nil {
+							return errors.Trace(err)
+						}
+					case *ast.RollbackStmt:
+						c.txn = nil
+					}
+				}
+				var changes []*SchemaChangeEvent
+				if _, ok := c.handler().(SchemaChangeHandler); ok {
+					changes = newSchemaChangeEvents(string(e.Schema), stmt)
+					for _, change := range changes {
+						change.Before = c.cachedTable(change.Schema, change.Table)
+					}
+				}
+				if c.cfg.TrackSchemaFromDDL {
+					c.trackDDL(string(e.Schema), stmt)
+				}
 				nodes := parseStmt(stmt)
 				for _, node := range nodes {
 					if node.db == "" {
@@ -152,7 +196,16 @@ func (c *Canal) runSyncBinlog() error {
 					savePos = true
 					force = true
 					// Now we only handle Table Changed DDL, maybe we will support more later.
-					if err = c.eventHandler.OnDDL(ev.Header, pos, e); err != nil {
+					if err = c.handleFenced(func() error { return c.eventHandler.OnDDL(ev.Header, pos, e) }); err != nil {
+						return errors.Trace(err)
+					}
+				}
+				for _, change := range changes {
+					change := change
+					change.After = c.afterTable(change)
+					change.Header, change.NextPos, change.Query = ev.Header, pos, e
+					change.Source = c.cfg.Source
+					if err = c.handleFenced(func() error { return c.eventHandler.(SchemaChangeHandler).OnSchemaChange(change) }); err != nil {
 						return errors.Trace(err)
 					}
 				}
@@ -168,7 +221,14 @@ func (c *Canal) runSyncBinlog() error {
 			c.master.Update(pos)
 			c.master.UpdateTimestamp(ev.Header.Timestamp)
 
-			if err := c.eventHandler.OnPosSynced(ev.Header, pos, c.master.GTIDSet(), force); err != nil {
+			gset, force := c.master.GTIDSet(), force
+			err := c.handle(func() error {
+				if err := c.eventHandler.OnPosSynced(ev.Header, pos, gset, force); err != nil {
+					return errors.Trace(err)
+				}
+				return errors.Trace(c.savePosition(pos, gset, force))
+			})
+			if err != nil {
 				return errors.Trace(err)
 			}
 		}
@@ -220,13 +280,17 @@ func parseStmt(stmt ast.StmtNode) (ns []*node) {
 	return ns
 }
 
-func (c *Canal) updateTable(header *replication.EventHeader, db, table string) (err error) {
-	c.ClearTableCache([]byte(db), []byte(table))
-	c.cfg.Logger.Infof("table structure changed, clear table cache: %s.%s\n", db, table)
-	if err = c.eventHandler.OnTableChanged(header, db, table); err != nil && errors.Cause(err) != schema.ErrTableNotExist {
-		return errors.Trace(err)
+func (c *Canal) updateTable(header *replication.EventHeader, db, table string) error {
+	if !c.cfg.TrackSchemaFromDDL {
+		c.ClearTableCache([]byte(db), []byte(table))
+		c.cfg.Logger.Infof("table structure changed, clear table cache: %s.%s\n", db, table)
 	}
-	return
+	return c.handleFenced(func() error {
+		if err := c.eventHandler.OnTableChanged(header, db, table); err != nil && errors.Cause(err) != schema.ErrTableNotExist {
+			return errors.Trace(err)
+		}
+		return nil
+	})
 }
 func (c *Canal) updateReplicationDelay(ev *replication.BinlogEvent) {
 	var newDelay uint32
@@ -256,17 +320,21 @@ func (c *Canal) handleRowsEvent(e *replication.BinlogEvent) error {
 	}
 	var action string
 	switch e.Header.EventType {
-	case replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2, replication.MARIADB_WRITE_ROWS_COMPRESSED_EVENT_V1:
+	case replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2, replication.MARIADB_WRITE_ROWS_COMPRESSED_EVENT_V1, replication.MARIADB_WRITE_ROWS_COMPRESSED_EVENT:
 		action = InsertAction
-	case replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2, replication.MARIADB_DELETE_ROWS_COMPRESSED_EVENT_V1:
+	case replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2, replication.MARIADB_DELETE_ROWS_COMPRESSED_EVENT_V1, replication.MARIADB_DELETE_ROWS_COMPRESSED_EVENT:
 		action = DeleteAction
-	case replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2, replication.MARIADB_UPDATE_ROWS_COMPRESSED_EVENT_V1:
+	case replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2, replication.MARIADB_UPDATE_ROWS_COMPRESSED_EVENT_V1, replication.MARIADB_UPDATE_ROWS_COMPRESSED_EVENT:
 		action = UpdateAction
 	default:
 		return errors.Errorf("%s not supported now", e.Header.EventType)
 	}
-	events := newRowsEvent(t, action, ev.Rows, e.Header)
-	return c.eventHandler.OnRow(events)
+	events := c.newRowsEvent(t, action, ev.Rows, e.Header)
+	if c.cfg.BatchTransactions {
+		c.addTransactionRows(events)
+		return nil
+	}
+	return c.handleRows(events)
 }
 
 func (c *Canal) FlushBinlog() error {
@@ -0,0 +1,69 @@
+package canal
+
+import (
+	"time"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/replication"
+)
+
+// Transaction is a transaction of the binlog with the rows it changed, passed to the OnTransaction of a
+// TransactionHandler once committed if Config.BatchTransactions is set.
+type Transaction struct {
+	// GTID is the GTID of the transaction, nil if GTID is not used
+	GTID mysql.GTIDSet
+	// Rows are the rows changed by the transaction, in order
+	Rows []*RowsEvent
+	// CommitTime is the commit time of the transaction on the original server if the binlog has it, MySQL 8.0.1 and
+	// later, or the time of the event committing the transaction
+	CommitTime time.Time
+	// Header is the header of the event committing the transaction, a XID event or a COMMIT query
+	Header *replication.EventHeader
+	// NextPos is the position of the binlog after the transaction
+	NextPos mysql.Position
+	// Source is the master of the transaction, see Config.Source
+	Source string
+}
+
+// beginTransaction starts the transaction of a GTID or BEGIN event, or of the rows of no such event.
+func (c *Canal) beginTransaction(e mysql.BinlogGTIDEvent) {
+	if e == nil && c.txn != nil {
+		// the BEGIN following the GTID
+		return
+	}
+
+	c.txn = &Transaction{Source: c.cfg.Source}
+	if e == nil {
+		return
+	}
+	if gtid, err := e.GTIDNext(); err == nil {
+		c.txn.GTID = gtid
+	}
+	if ev, ok := e.(*replication.GTIDEvent); ok {
+		c.txn.CommitTime = ev.OriginalCommitTime()
+	}
+}
+
+// addTransactionRows adds the rows of an event to the transaction.
+func (c *Canal) addTransactionRows(e *RowsEvent) {
+	if c.txn == nil {
+		c.beginTransaction(nil)
+	}
+	c.txn.Rows = append(c.txn.Rows, e)
+}
+
+// commitTransaction passes the transaction to OnTransaction unless it changed no row.
+func (c *Canal) commitTransaction(header *replication.EventHeader, nextPos mysql.Position) error {
+	txn := c.txn
+	c.txn = nil
+	if txn == nil || len(txn.Rows) == 0 {
+		return nil
+	}
+
+	txn.Header = header
+	txn.NextPos = nextPos
+	if txn.CommitTime.IsZero() {
+		txn.CommitTime = header.Time()
+	}
+	return c.handle(func() error { return c.eventHandler.(TransactionHandler).OnTransaction(txn) })
+}
@@ -0,0 +1,82 @@
+package canal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/replication"
+	"github.com/atoonk/go-mysql/schema"
+)
+
+type transactionRecorder struct {
+	DummyEventHandler
+	txns []*Transaction
+}
+
+func (h *transactionRecorder) OnTransaction(txn *Transaction) error {
+	h.txns = append(h.txns, txn)
+	return nil
+}
+
+func TestTransactionBatching(t *testing.T) {
+	h := &transactionRecorder{}
+	c := &Canal{cfg: &Config{BatchTransactions: true}, eventHandler: h}
+	ta := &schema.Table{Schema: "test", Name: "t"}
+
+	sid := []byte{0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62}
+	c.beginTransaction(&replication.GTIDEvent{SID: sid, GNO: 23})
+	// the BEGIN following the GTID
+	c.beginTransaction(nil)
+	insert := &RowsEvent{Table: ta, Action: InsertAction, Rows: [][]interface{}{{1}}}
+	update := &RowsEvent{Table: ta, Action: UpdateAction, Rows: [][]interface{}{{1}, {2}}}
+	c.addTransactionRows(insert)
+	c.addTransactionRows(update)
+
+	header := &replication.EventHeader{Timestamp: 1700000000, EventType: replication.XID_EVENT}
+	pos := mysql.Position{Name: "mysql-bin.000001", Pos: 1234}
+	require.NoError(t, c.commitTransaction(header, pos))
+	require.Nil(t, c.txn)
+	require.Len(t, h.txns, 1)
+
+	txn := h.txns[0]
+	require.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:23", txn.GTID.String())
+	require.Equal(t, []*RowsEvent{insert, update}, txn.Rows)
+	require.Equal(t, time.Unix(1700000000, 0), txn.CommitTime)
+	require.Equal(t, header, txn.Header)
+	require.Equal(t, pos, txn.NextPos)
+
+	// the rows of no GTID event
+	c.addTransactionRows(insert)
+	require.NoError(t, c.commitTransaction(header, pos))
+	require.Len(t, h.txns, 2)
+	require.Nil(t, h.txns[1].GTID)
+
+	// the transactions changing no row are not passed
+	c.beginTransaction(&replication.GTIDEvent{SID: sid, GNO: 24})
+	require.NoError(t, c.commitTransaction(header, pos))
+	require.Len(t, h.txns, 2)
+}
+
+func TestBatchTransactionsHandler(t *testing.T) {
+	c := &Canal{cfg: &Config{BatchTransactions: true}}
+	c.SetEventHandler(&DummyEventHandler{})
+	require.Error(t, c.checkEventHandler())
+
+	// the measuring of the handler keeps its interfaces
+	m := &metricsRecorder{}
+	c.cfg.Metrics = m
+	c.SetEventHandler(&transactionRecorder{})
+	require.NoError(t, c.checkEventHandler())
+	c.beginTransaction(nil)
+	c.addTransactionRows(&RowsEvent{Table: &schema.Table{Schema: "test", Name: "t"}, Action: InsertAction})
+	require.NoError(t, c.commitTransaction(&replication.EventHeader{EventType: replication.XID_EVENT}, mysql.Position{}))
+	require.Len(t, c.handler().(*transactionRecorder).txns, 1)
+	require.Equal(t, []string{"OnTransaction"}, m.methods)
+
+	c.cfg.BatchTransactions = false
+	c.SetEventHandler(&DummyEventHandler{})
+	require.NoError(t, c.checkEventHandler())
+}
@@ -5,6 +5,8 @@ import (
 	"crypto/tls"
 	"encoding/binary"
 	"fmt"
+	"runtime/debug"
+	"sort"
 
 	. "github.com/atoonk/go-mysql/mysql"
 	"github.com/atoonk/go-mysql/packet"
@@ -72,7 +74,15 @@ func (c *Conn) readInitialHandshake() error {
 		return errors.New("the MySQL server can not support protocol 41 and above required by the client")
 	}
 	if c.capability&CLIENT_SSL == 0 && c.tlsConfig != nil {
-		return errors.New("the MySQL Server does not support TLS required by the client")
+		if c.sslMode != SSLPreferred {
+			return errors.New("the MySQL Server does not support TLS required by the client")
+		}
+		// falls back to an unencrypted connection
+		c.tlsConfig = nil
+		seq := c.Sequence
+		c.Conn = packet.NewConn(c.Conn.Conn)
+		c.Sequence = seq
+		c.applyTimeouts()
 	}
 	pos += 2
 
@@ -115,7 +125,8 @@ func (c *Conn) readInitialHandshake() error {
 			authPluginDataPart2 := data[pos : pos+rest]
 			pos += rest
 
-			c.salt = append(c.salt, authPluginDataPart2...)
+			// the scramble is NUL terminated, the terminator is not part of it
+			c.salt = append(c.salt, bytes.TrimRight(authPluginDataPart2, "\x00")...)
 		}
 
 		if c.capability&CLIENT_PLUGIN_AUTH != 0 {
@@ -156,10 +167,14 @@ func (c *Conn) genAuthResponse(authData []byte) ([]byte, bool, error) {
 		if len(c.password) == 0 {
 			return nil, true, nil
 		}
-		if c.tlsConfig != nil || c.proto == "unix" {
+		if c.secureTransport() {
 			// write cleartext auth packet
 			// see: https://dev.mysql.com/doc/refman/8.0/en/sha256-pluggable-authentication.html
 			return []byte(c.password), true, nil
+		} else if c.serverPubKey != nil {
+			// encrypt the password with the known public key of the server
+			enc, err := EncryptPassword(c.password, authData, c.serverPubKey)
+			return enc, false, err
 		} else {
 			// request public key from server
 			// see: https://dev.mysql.com/doc/internals/en/public-key-retrieval.html
@@ -172,15 +187,37 @@ func (c *Conn) genAuthResponse(authData []byte) ([]byte, bool, error) {
 }
 
 // generate connection attributes data
+// clientVersion is the version of this module in the build of the application, sent as the _client_version
+// connection attribute if known.
+var clientVersion = func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/atoonk/go-mysql" {
+			return dep.Version
+		}
+	}
+	return ""
+}()
+
 func (c *Conn) genAttributes() []byte {
-	if len(c.attributes) == 0 {
+	keys := make([]string, 0, len(c.attributes))
+	for k, v := range c.attributes {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
 		return nil
 	}
+	sort.Strings(keys)
 
 	attrData := make([]byte, 0)
-	for k, v := range c.attributes {
+	for _, k := range keys {
 		attrData = append(attrData, PutLengthEncodedString([]byte(k))...)
-		attrData = append(attrData, PutLengthEncodedString([]byte(v))...)
+		attrData = append(attrData, PutLengthEncodedString([]byte(c.attributes[k]))...)
 	}
 	return append(PutLengthEncodedInt(uint64(len(attrData))), attrData...)
 }
@@ -193,9 +230,11 @@ func (c *Conn) writeAuthHandshake() error {
 
 	// Set default client capabilities that reflect the abilities of this library
 	capability := CLIENT_PROTOCOL_41 | CLIENT_SECURE_CONNECTION |
-		CLIENT_LONG_PASSWORD | CLIENT_TRANSACTIONS | CLIENT_PLUGIN_AUTH
+		CLIENT_LONG_PASSWORD | CLIENT_TRANSACTIONS | CLIENT_PLUGIN_AUTH |
+		CLIENT_MULTI_RESULTS | CLIENT_PS_MULTI_RESULTS
 	// Adjust client capability flags based on server support
 	capability |= c.capability & CLIENT_LONG_FLAG
+	capability |= c.capability & CLIENT_SESSION_TRACK
 	// Adjust client capability flags on specific client requests
 	// Only flags that would make any sense setting and aren't handled elsewhere
 	// in the library are supported here
@@ -243,8 +282,11 @@ func (c *Conn) writeAuthHandshake() error {
 		capability |= CLIENT_CONNECT_WITH_DB
 		length += len(c.db) + 1
 	}
-	// connection attributes
-	attrData := c.genAttributes()
+	// connection attributes, if the server supports them
+	var attrData []byte
+	if c.capability&CLIENT_CONNECT_ATTRS > 0 {
+		attrData = c.genAttributes()
+	}
 	if len(attrData) > 0 {
 		capability |= CLIENT_CONNECT_ATTRS
 		length += len(attrData)
@@ -288,6 +330,7 @@ func (c *Conn) writeAuthHandshake() error {
 		currentSequence := c.Sequence
 		c.Conn = packet.NewConn(tlsConn)
 		c.Sequence = currentSequence
+		c.applyTimeouts()
 	}
 
 	// Filler [23 bytes] (all 0x00)
@@ -331,6 +374,9 @@ func (c *Conn) writeAuthHandshake() error {
 	if c.ccaps&CLIENT_ZSTD_COMPRESSION_ALGORITHM > 0 {
 		// zstd_compression_level
 		data[pos] = 0x03
+		if c.compressionLevel > 0 {
+			data[pos] = byte(c.compressionLevel)
+		}
 	}
 
 	return c.WritePacket(data)
@@ -34,3 +34,17 @@ func TestConnGenAttributes(t *testing.T) {
 		require.Subset(t, data, fixt)
 	}
 }
+
+func TestConnGenAttributesOrder(t *testing.T) {
+	c := &Conn{attributes: map[string]string{"b": "2", "a": "1", "c": ""}}
+
+	// sorted by key, without the empty values
+	expected := mysql.PutLengthEncodedInt(8)
+	for _, s := range []string{"a", "1", "b", "2"} {
+		expected = append(expected, mysql.PutLengthEncodedString([]byte(s))...)
+	}
+	require.Equal(t, expected, c.genAttributes())
+
+	c.attributes = map[string]string{"c": ""}
+	require.Nil(t, c.genAttributes())
+}
@@ -0,0 +1,176 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/pingcap/errors"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// defaultBulkInsertSize bounds the statements of BulkInsert without max_allowed_packet, it is the default
+// max_allowed_packet of MySQL 5.7.
+const defaultBulkInsertSize = 4 << 20
+
+// BulkInsertOptions are the options of BulkInsert.
+type BulkInsertOptions struct {
+	// Ignore skips the rows conflicting with a unique key, with INSERT IGNORE
+	Ignore bool
+	// Replace replaces the rows conflicting with a unique key, with REPLACE
+	Replace bool
+	// OnDuplicateKeyUpdate are the columns updated with their new values in the rows conflicting with a unique key
+	OnDuplicateKeyUpdate []string
+	// MaxStatementSize bounds the length of the statements, the limit set with SetMaxAllowedPacket by default,
+	// 4MB without limit
+	MaxStatementSize int
+	// MaxRows bounds the number of rows of the statements, unlimited if 0
+	MaxRows int
+}
+
+// BulkInsert inserts the rows in table, which can be qualified by its database as db.table, with multi-row INSERT
+// statements as few as possible, their length staying under the max_allowed_packet. The values are escaped as by
+// ExecuteInterpolated and can be of the same types.
+//
+// The returned Result has the rows affected by all the statements and the first auto-increment id generated. The
+// statements are not atomic: once one failed, the rows of the previous ones stay inserted unless BulkInsert is
+// run in a transaction.
+func (c *Conn) BulkInsert(table string, columns []string, rows [][]interface{}, opts *BulkInsertOptions) (*Result, error) {
+	if opts == nil {
+		opts = &BulkInsertOptions{}
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("no column to insert")
+	}
+	if opts.Replace && (opts.Ignore || len(opts.OnDuplicateKeyUpdate) > 0) {
+		return nil, errors.New("REPLACE can't be combined with IGNORE or ON DUPLICATE KEY UPDATE")
+	}
+
+	maxSize := opts.MaxStatementSize
+	if maxSize <= 0 {
+		// the command byte is part of the packet
+		if maxSize = c.Conn.MaxAllowedPacket - 1; maxSize <= 0 {
+			maxSize = defaultBulkInsertSize
+		}
+	}
+
+	prefix := bulkInsertPrefix(table, columns, opts)
+	suffix := bulkInsertSuffix(opts)
+	noBackslashEscapes := c.status&SERVER_STATUS_NO_BACKSLASH_ESCAPED != 0
+
+	result := &Result{}
+	query := make([]byte, 0, len(prefix)+len(suffix)+64*len(columns))
+	count := 0
+	flush := func() error {
+		query = append(query, suffix...)
+		r, err := c.Execute(string(query))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		result.AffectedRows += r.AffectedRows
+		result.Warnings += r.Warnings
+		result.Status = r.Status
+		if result.InsertId == 0 {
+			result.InsertId = r.InsertId
+		}
+		query, count = query[:0], 0
+		return nil
+	}
+
+	var value []byte
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return nil, errors.Errorf("row %d has %d values for %d columns", i, len(row), len(columns))
+		}
+
+		value = append(value[:0], '(')
+		for j, v := range row {
+			if j > 0 {
+				value = append(value, ',')
+			}
+			var err error
+			if value, err = c.appendArgument(value, v, noBackslashEscapes); err != nil {
+				return nil, errors.Annotatef(err, "row %d", i)
+			}
+		}
+		value = append(value, ')')
+
+		if len(prefix)+len(value)+len(suffix) > maxSize {
+			return nil, errors.Errorf("row %d is too large for a statement of at most %d bytes", i, maxSize)
+		}
+		if count > 0 && (len(query)+1+len(value)+len(suffix) > maxSize || opts.MaxRows > 0 && count >= opts.MaxRows) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+
+		if count == 0 {
+			query = append(query, prefix...)
+		} else {
+			query = append(query, ',')
+		}
+		query = append(query, value...)
+		count++
+	}
+
+	if count > 0 {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func bulkInsertPrefix(table string, columns []string, opts *BulkInsertOptions) string {
+	var b strings.Builder
+	switch {
+	case opts.Replace:
+		b.WriteString("REPLACE INTO ")
+	case opts.Ignore:
+		b.WriteString("INSERT IGNORE INTO ")
+	default:
+		b.WriteString("INSERT INTO ")
+	}
+
+	if db, name, ok := strings.Cut(table, "."); ok {
+		b.WriteString(quoteIdentifier(db))
+		b.WriteByte('.')
+		b.WriteString(quoteIdentifier(name))
+	} else {
+		b.WriteString(quoteIdentifier(table))
+	}
+
+	b.WriteString(" (")
+	for i, column := range columns {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(quoteIdentifier(column))
+	}
+	b.WriteString(") VALUES ")
+	return b.String()
+}
+
+func bulkInsertSuffix(opts *BulkInsertOptions) string {
+	if len(opts.OnDuplicateKeyUpdate) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(" ON DUPLICATE KEY UPDATE ")
+	for i, column := range opts.OnDuplicateKeyUpdate {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		column = quoteIdentifier(column)
+		b.WriteString(column)
+		b.WriteString("=VALUES(")
+		b.WriteString(column)
+		b.WriteByte(')')
+	}
+	return b.String()
+}
+
+// quoteIdentifier quotes a name with backticks, doubling the backticks it contains.
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
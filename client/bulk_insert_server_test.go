@@ -0,0 +1,94 @@
+package client_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// insertRecordHandler records the statements, each inserting the number of rows of its VALUES
+type insertRecordHandler struct {
+	server.EmptyHandler
+	mu      sync.Mutex
+	queries []string
+}
+
+func (h *insertRecordHandler) HandleQuery(query string) (*mysql.Result, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.queries = append(h.queries, query)
+	rows := strings.Count(query, "),(") + 1
+	return &mysql.Result{AffectedRows: uint64(rows), InsertId: uint64(100 * len(h.queries))}, nil
+}
+
+func (h *insertRecordHandler) takeQueries() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	q := h.queries
+	h.queries = nil
+	return q
+}
+
+func TestClientBulkInsert(t *testing.T) {
+	h := &insertRecordHandler{}
+
+	addr := serveFake(t, h)
+
+	c, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+	defer c.Close()
+
+	rows := [][]interface{}{
+		{1, "it's"},
+		{2, nil},
+		{3, []byte{0xff}},
+	}
+	r, err := c.BulkInsert("db.t`1", []string{"id", "name"}, rows, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, r.AffectedRows)
+	require.EqualValues(t, 100, r.InsertId)
+	require.Equal(t, []string{"INSERT INTO `db`.`t``1` (`id`,`name`) VALUES (1,'it''s'),(2,NULL),(3,X'ff')"}, h.takeQueries())
+
+	// the statements are split by rows and by size
+	r, err = c.BulkInsert("t", []string{"id", "name"}, rows, &client.BulkInsertOptions{
+		MaxRows:              2,
+		OnDuplicateKeyUpdate: []string{"name"},
+	})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, r.AffectedRows)
+	require.EqualValues(t, 100, r.InsertId)
+	require.Equal(t, []string{
+		"INSERT INTO `t` (`id`,`name`) VALUES (1,'it''s'),(2,NULL) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)",
+		"INSERT INTO `t` (`id`,`name`) VALUES (3,X'ff') ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)",
+	}, h.takeQueries())
+
+	_, err = c.BulkInsert("t", []string{"id"}, [][]interface{}{{1}, {2}, {3}}, &client.BulkInsertOptions{
+		Ignore:           true,
+		MaxStatementSize: len("INSERT IGNORE INTO `t` (`id`) VALUES (1),(2)"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"INSERT IGNORE INTO `t` (`id`) VALUES (1),(2)",
+		"INSERT IGNORE INTO `t` (`id`) VALUES (3)",
+	}, h.takeQueries())
+
+	// nothing is sent for the invalid rows
+	_, err = c.BulkInsert("t", []string{"id", "name"}, [][]interface{}{{1, "a"}, {2}}, nil)
+	require.ErrorContains(t, err, "row 1 has 1 values for 2 columns")
+	_, err = c.BulkInsert("t", []string{"id"}, [][]interface{}{{strings.Repeat("a", 100)}}, &client.BulkInsertOptions{MaxStatementSize: 50})
+	require.ErrorContains(t, err, "too large")
+	_, err = c.BulkInsert("t", []string{"id"}, [][]interface{}{{1}}, &client.BulkInsertOptions{Replace: true, Ignore: true})
+	require.Error(t, err)
+	require.Empty(t, h.takeQueries())
+
+	r, err = c.BulkInsert("t", []string{"id"}, nil, nil)
+	require.NoError(t, err)
+	require.Zero(t, r.AffectedRows)
+	require.Empty(t, h.takeQueries())
+}
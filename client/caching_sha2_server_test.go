@@ -0,0 +1,59 @@
+package client_test
+
+import (
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+	"github.com/atoonk/go-mysql/test_util/test_keys"
+)
+
+type authMethodProvider struct {
+	*server.InMemoryProvider
+	method string
+}
+
+func (p *authMethodProvider) GetAuthMethod(username string) (string, error) {
+	return p.method, nil
+}
+
+// the go-mysql client authenticates without TLS with the full and the fast 'caching_sha2_password' authentications
+// and with 'sha256_password', requesting the public key of the server or using the known one
+func TestClientCachingSha2Auth(t *testing.T) {
+	block, _ := pem.Decode(test_keys.PubPem)
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	require.NoError(t, err)
+	withPubKey := func(c *client.Conn) { c.SetServerPubKey(pub.(*rsa.PublicKey)) }
+
+	tlsConf := server.NewServerTLSConfig(test_keys.CaPem, test_keys.CertPem, test_keys.KeyPem, tls.VerifyClientCertIfGiven)
+	const password = "a password longer than the scramble"
+	for _, defaultMethod := range []string{mysql.AUTH_CACHING_SHA2_PASSWORD, mysql.AUTH_NATIVE_PASSWORD} {
+		for _, userMethod := range []string{mysql.AUTH_CACHING_SHA2_PASSWORD, mysql.AUTH_SHA256_PASSWORD} {
+			svr := server.NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, defaultMethod, test_keys.PubPem, tlsConf)
+			// not an InMemoryProvider, so that the first authentication misses the cache
+			p := &authMethodProvider{server.NewInMemoryProvider(), userMethod}
+			p.AddUser("root", password)
+
+			addr := (&fakeServer{svr: svr, p: p}).listen(t)
+
+			for _, options := range [][]func(*client.Conn){nil, {withPubKey}, nil} {
+				c, err := client.Connect(addr, "root", password, "", options...)
+				require.NoError(t, err, "%s %s", defaultMethod, userMethod)
+				require.NoError(t, c.Ping())
+				c.Close()
+			}
+
+			_, err = client.Connect(addr, "root", "wrong", "")
+			var myErr *mysql.MyError
+			require.ErrorAs(t, err, &myErr)
+			require.EqualValues(t, mysql.ER_ACCESS_DENIED_ERROR, myErr.Code)
+		}
+	}
+}
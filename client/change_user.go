@@ -0,0 +1,89 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/pingcap/errors"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// ChangeUser authenticates the connection as another user with COM_CHANGE_USER and selects db, which starts a new
+// session without dialing again: the server resets the session state and closes the prepared statements. The
+// charset set with SetCharset is set again. If it fails, the client keeps the previous user, but the server may
+// have closed the connection.
+func (c *Conn) ChangeUser(user string, password string, db string) error {
+	prevUser, prevPassword, prevDB, prevPlugin := c.user, c.password, c.db, c.authPluginName
+
+	c.user, c.password, c.db = user, password, db
+	if err := c.changeUser(); err != nil {
+		c.user, c.password, c.db, c.authPluginName = prevUser, prevPassword, prevDB, prevPlugin
+		return errors.Trace(err)
+	}
+
+	return c.resetSession()
+}
+
+// See: https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_change_user.html
+func (c *Conn) changeUser() error {
+	auth, addNull, err := c.genAuthResponse(c.salt)
+	if err != nil {
+		return err
+	}
+	if addNull {
+		auth = append(auth, 0)
+	}
+	if len(auth) > 255 {
+		return errors.Errorf("auth response of %d bytes is too long for COM_CHANGE_USER", len(auth))
+	}
+
+	data := make([]byte, 0, len(c.user)+len(auth)+len(c.db)+len(c.authPluginName)+6)
+	data = append(data, c.user...)
+	data = append(data, 0, byte(len(auth)))
+	data = append(data, auth...)
+	data = append(data, c.db...)
+	data = append(data, 0)
+	// the same collation as the handshake
+	data = append(data, DEFAULT_COLLATION_ID, 0)
+	data = append(data, c.authPluginName...)
+	data = append(data, 0)
+	if c.capability&CLIENT_CONNECT_ATTRS > 0 {
+		data = append(data, c.genAttributes()...)
+	}
+
+	if err := c.writeCommandBuf(COM_CHANGE_USER, data); err != nil {
+		return err
+	}
+	return c.handleAuthResult()
+}
+
+// ResetConnection resets the session with COM_RESET_CONNECTION, keeping the user and the database: the server
+// rolls back the transaction, closes the prepared statements and resets the session variables, which is much
+// cheaper than a new connection when a pool hands it to another user. The charset set with SetCharset and the
+// variables of SetConnectVariables are set again.
+func (c *Conn) ResetConnection() error {
+	if err := c.writeCommand(COM_RESET_CONNECTION); err != nil {
+		return errors.Trace(err)
+	}
+	if _, err := c.readOK(); err != nil {
+		return errors.Trace(err)
+	}
+
+	return c.resetSession()
+}
+
+// resetSession syncs the state kept by the client with a session reset by the server.
+func (c *Conn) resetSession() error {
+	c.pendingStmt = nil
+	if c.stmtCache != nil {
+		c.stmtCache.reset()
+	}
+	c.sessionVariables = nil
+
+	if c.charset != DEFAULT_CHARSET {
+		if _, err := c.exec(fmt.Sprintf("SET NAMES %s", c.charset)); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return c.applyConnectVariables()
+}
@@ -397,6 +397,33 @@ func (s *clientTestSuite) TestStmt_Signed() {
 	require.NoError(s.T(), err)
 }
 
+func (s *clientTestSuite) TestStmt_Decimal() {
+	stmt, err := s.c.Prepare("SELECT CAST(? AS DECIMAL(30,10))")
+	require.NoError(s.T(), err)
+	defer stmt.Close()
+
+	result, err := stmt.Execute(mysql.MustParseDecimal("12345678901234567890.1234567891"))
+	require.NoError(s.T(), err)
+	d, err := result.GetMysqlDecimal(0, 0)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "12345678901234567890.1234567891", d.String())
+}
+
+func (s *clientTestSuite) TestStmt_Geometry() {
+	stmt, err := s.c.Prepare("SELECT ST_AsText(?), ST_GeomFromText('LINESTRING(0 0,1 1)')")
+	require.NoError(s.T(), err)
+	defer stmt.Close()
+
+	result, err := stmt.Execute(mysql.NewGeometry(0, mysql.Point{X: 1, Y: 2}))
+	require.NoError(s.T(), err)
+	wkt, err := result.GetString(0, 0)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), "POINT(1 2)", wkt)
+	g, err := result.GetGeometry(0, 1)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), mysql.LineString{{X: 0, Y: 0}, {X: 1, Y: 1}}, g.Shape)
+}
+
 func (s *clientTestSuite) TestStmt_Trans() {
 	_, err := s.c.Execute(`insert into mixer_test_stmt (id, str) values (1002, "abc")`)
 	require.NoError(s.T(), err)
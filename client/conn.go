@@ -3,6 +3,7 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/rsa"
 	"crypto/tls"
 	"fmt"
 	"net"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/pingcap/errors"
+	"github.com/siddontang/go-log/loggers"
 
 	. "github.com/atoonk/go-mysql/mysql"
 	"github.com/atoonk/go-mysql/packet"
@@ -24,17 +26,55 @@ type Conn struct {
 	password  string
 	db        string
 	tlsConfig *tls.Config
-	proto     string
+	// security level of the connection, see SetSSLMode
+	sslMode SSLMode
+	// RSA public key of the server encrypting the password over an insecure connection, requested if nil
+	serverPubKey *rsa.PublicKey
+	proto        string
+	addr         string
+	dialer       Dialer
+	// how the commands run with a context are cancelled, see SetCancelMode
+	cancelMode CancelMode
+	// PROXY protocol header sent before the handshake, if set
+	proxyHeader *ProxyHeader
+	// files the server can read with LOAD DATA LOCAL INFILE, see SetInfileProvider and SetInfileAllowlist
+	infileProvider  InfileProvider
+	infileAllowlist []string
+	// automatic reconnection, see SetReconnectPolicy
+	reconnectPolicy  *ReconnectPolicy
+	sessionVariables []sessionVariable
+	// session variables set once connected and after a reset, see SetConnectVariables
+	connectVariables map[string]interface{}
+	// the connection was lost by the last command, the next one reconnects first
+	connLost bool
 
 	serverVersion string
 	// server capabilities
 	capability uint32
 	// client-set capabilities only
 	ccaps uint32
+	// compression settings, see SetCompressionLevel and SetCompressionThreshold
+	compressionLevel     int
+	compressionThreshold int
+	// deadlines of the packets and length limit of the packets written, see SetReadTimeout, SetWriteTimeout
+	// and SetMaxAllowedPacket
+	readTimeout      time.Duration
+	writeTimeout     time.Duration
+	maxAllowedPacket int
 
 	attributes map[string]string
 
 	status uint16
+	// protocol of the result being read, so NextResult reads the next ones the same way
+	binaryResult bool
+	// statement prepared by Execute, closed once all of its results are read
+	pendingStmt *Stmt
+	// prepared statements reused by Prepare and Execute, see SetStmtCacheSize
+	stmtCache *stmtCache
+	// location of the DATE, DATETIME and TIMESTAMP values, see SetTimeLocation
+	timeLocation *time.Location
+	// run around the commands, see AddInterceptors
+	interceptors []Interceptor
 
 	charset string
 
@@ -42,6 +82,8 @@ type Conn struct {
 	authPluginName string
 
 	connectionID uint32
+
+	logger loggers.Advanced
 }
 
 // This function will be called for every row in resultset from ExecuteSelectStreaming.
@@ -80,12 +122,14 @@ func ConnectWithDialer(ctx context.Context, network string, addr string, user st
 	c := new(Conn)
 
 	c.attributes = map[string]string{
-		"_client_name": "go-mysql",
-		// "_client_version": "0.1",
+		"_client_name":     "go-mysql",
 		"_os":              runtime.GOOS,
 		"_platform":        runtime.GOARCH,
 		"_runtime_version": runtime.Version(),
 	}
+	if clientVersion != "" {
+		c.attributes["_client_version"] = clientVersion
+	}
 
 	if network == "" {
 		network = getNetProto(addr)
@@ -101,31 +145,65 @@ func ConnectWithDialer(ctx context.Context, network string, addr string, user st
 	c.password = password
 	c.db = dbName
 	c.proto = network
+	c.addr = addr
+	c.dialer = dialer
 	c.Conn = packet.NewConn(conn)
 
 	// use default charset here, utf-8
 	c.charset = DEFAULT_CHARSET
 
+	c.logger = NewDefaultLogger()
+
 	// Apply configuration functions.
 	for i := range options {
 		options[i](c)
 	}
+	// the timeouts can also be set on the packet connection by the options
+	if c.readTimeout == 0 {
+		c.readTimeout = c.ReadTimeout
+	}
+	if c.writeTimeout == 0 {
+		c.writeTimeout = c.WriteTimeout
+	}
+	c.applyTimeouts()
+
+	if c.proxyHeader != nil {
+		if err = c.writeProxyHeader(conn); err != nil {
+			c.Close()
+			return nil, errors.Trace(err)
+		}
+	}
 
+	c.applySSLMode()
 	if c.tlsConfig != nil {
 		seq := c.Conn.Sequence
 		c.Conn = packet.NewTLSConn(conn)
 		c.Conn.Sequence = seq
+		c.applyTimeouts()
 	}
 
 	if err = c.handshake(); err != nil {
 		return nil, errors.Trace(err)
 	}
 
-	if c.ccaps&CLIENT_COMPRESS > 0 {
+	// compression is only used if the server supports it too
+	if c.ccaps&c.capability&CLIENT_COMPRESS > 0 {
 		c.Conn.Compression = MYSQL_COMPRESS_ZLIB
-	} else if c.ccaps&CLIENT_ZSTD_COMPRESSION_ALGORITHM > 0 {
+	} else if c.ccaps&c.capability&CLIENT_ZSTD_COMPRESSION_ALGORITHM > 0 {
 		c.Conn.Compression = MYSQL_COMPRESS_ZSTD
 	}
+	c.Conn.CompressionLevel = c.compressionLevel
+	c.Conn.CompressionThreshold = c.compressionThreshold
+
+	if err = c.applyMaxAllowedPacket(); err != nil {
+		c.Close()
+		return nil, errors.Trace(err)
+	}
+
+	if err = c.applyConnectVariables(); err != nil {
+		c.Close()
+		return nil, errors.Trace(err)
+	}
 
 	return c, nil
 }
@@ -163,6 +241,13 @@ func (c *Conn) Quit() error {
 }
 
 func (c *Conn) Ping() error {
+	_, err := c.intercept(context.Background(), COM_PING, "", nil, func() (*Result, error) {
+		return nil, c.withReconnect(true, c.ping)
+	})
+	return err
+}
+
+func (c *Conn) ping() error {
 	if err := c.writeCommand(COM_PING); err != nil {
 		return errors.Trace(err)
 	}
@@ -184,6 +269,74 @@ func (c *Conn) UnsetCapability(cap uint32) {
 	c.ccaps &= ^cap
 }
 
+// SetCompressionLevel sets the level of the compression enabled with SetCapability(CLIENT_COMPRESS) or
+// SetCapability(CLIENT_ZSTD_COMPRESSION_ALGORITHM): 1 (fastest) to 9 for zlib, 1 to 22 for zstd. The level of zstd
+// is also sent to the server, 3 by default.
+// pass to options when connect
+func (c *Conn) SetCompressionLevel(level int) {
+	c.compressionLevel = level
+}
+
+// SetCompressionThreshold sets the length under which the packets are sent uncompressed, 50 bytes by default.
+// pass to options when connect
+func (c *Conn) SetCompressionThreshold(length int) {
+	c.compressionThreshold = length
+}
+
+// SetReadTimeout bounds the time spent reading every packet, from the handshake on: a command fails with
+// ErrBadConn once the server did not answer in time, instead of hanging.
+// pass to options when connect
+func (c *Conn) SetReadTimeout(timeout time.Duration) {
+	c.readTimeout = timeout
+}
+
+// SetWriteTimeout bounds the time spent writing every packet, from the handshake on.
+// pass to options when connect
+func (c *Conn) SetWriteTimeout(timeout time.Duration) {
+	c.writeTimeout = timeout
+}
+
+// MaxAllowedPacketFromServer makes SetMaxAllowedPacket use the max_allowed_packet of the server.
+const MaxAllowedPacketFromServer = -1
+
+// SetMaxAllowedPacket limits the length of the packets sent to the server, the commands and the statements
+// with longer arguments fail with ER_NET_PACKET_TOO_LARGE before anything is sent, the connection can still be
+// used. With MaxAllowedPacketFromServer, the max_allowed_packet of the server is read once connected instead of
+// having the server reject the packet and close the connection.
+// pass to options when connect
+func (c *Conn) SetMaxAllowedPacket(size int) {
+	c.maxAllowedPacket = size
+}
+
+func (c *Conn) applyTimeouts() {
+	c.Conn.ReadTimeout = c.readTimeout
+	c.Conn.WriteTimeout = c.writeTimeout
+}
+
+func (c *Conn) applyMaxAllowedPacket() error {
+	if c.maxAllowedPacket != MaxAllowedPacketFromServer {
+		c.Conn.MaxAllowedPacket = c.maxAllowedPacket
+		return nil
+	}
+
+	r, err := c.exec("SELECT @@max_allowed_packet")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	size, err := r.GetInt(0, 0)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	c.Conn.MaxAllowedPacket = int(size)
+	return nil
+}
+
+// SetLogger sets the logger used by the connection
+// pass to options when connect
+func (c *Conn) SetLogger(l loggers.Advanced) {
+	c.logger = l
+}
+
 // UseSSL: use default SSL
 // pass to options when connect
 func (c *Conn) UseSSL(insecureSkipVerify bool) {
@@ -196,21 +349,72 @@ func (c *Conn) SetTLSConfig(config *tls.Config) {
 	c.tlsConfig = config
 }
 
+// SetServerPubKey sets the RSA public key of the server, used to encrypt the password of 'caching_sha2_password' and
+// 'sha256_password' over an insecure connection instead of requesting the key from the server.
+// pass to options when connect
+func (c *Conn) SetServerPubKey(key *rsa.PublicKey) {
+	c.serverPubKey = key
+}
+
+// secureTransport reports whether the password can be sent in clear text: the connection uses TLS or a unix socket.
+func (c *Conn) secureTransport() bool {
+	return c.tlsConfig != nil || c.proto == "unix"
+}
+
+// SetProxyHeader sends a PROXY protocol header before the handshake, as a proxy in front of the server would.
+// If the header has a Source but no Destination, the address of the server is used.
+// pass to options when connect
+func (c *Conn) SetProxyHeader(h *ProxyHeader) {
+	c.proxyHeader = h
+}
+
+// SetTimeLocation makes the results return the DATE, DATETIME and TIMESTAMP values as time.Time in loc instead of
+// strings, see Resultset.TimeLocation. The time.Time arguments of the statements are sent in loc, in UTC if not set.
+// pass to options when connect
+func (c *Conn) SetTimeLocation(loc *time.Location) {
+	c.timeLocation = loc
+}
+
+func (c *Conn) writeProxyHeader(conn net.Conn) error {
+	h := *c.proxyHeader
+	if h.Source != nil && h.Destination == nil {
+		if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+			h.Destination = addr
+		}
+	}
+
+	data, err := h.Dump()
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(data)
+	return err
+}
+
 func (c *Conn) UseDB(dbName string) error {
 	if c.db == dbName {
 		return nil
 	}
 
-	if err := c.writeCommandStr(COM_INIT_DB, dbName); err != nil {
-		return errors.Trace(err)
-	}
+	_, err := c.intercept(context.Background(), COM_INIT_DB, dbName, nil, func() (*Result, error) {
+		return nil, c.withReconnect(true, func() error {
+			if err := c.writeCommandStr(COM_INIT_DB, dbName); err != nil {
+				return errors.Trace(err)
+			}
 
-	if _, err := c.readOK(); err != nil {
-		return errors.Trace(err)
-	}
+			if _, err := c.readOK(); err != nil {
+				return errors.Trace(err)
+			}
 
-	c.db = dbName
-	return nil
+			c.db = dbName
+			return nil
+		})
+	})
+	return err
+}
+
+func (c *Conn) GetUser() string {
+	return c.user
 }
 
 func (c *Conn) GetDB() string {
@@ -226,15 +430,38 @@ func (c *Conn) CompareServerVersion(v string) (int, error) {
 }
 
 func (c *Conn) Execute(command string, args ...interface{}) (*Result, error) {
+	return c.intercept(context.Background(), executeCommandType(args), command, args, func() (r *Result, err error) {
+		err = c.withReconnect(isIdempotent(command), func() error {
+			r, err = c.execute(command, args...)
+			return err
+		})
+		return r, err
+	})
+}
+
+// executeCommandType is the command run by Execute.
+func executeCommandType(args []interface{}) byte {
+	if len(args) == 0 {
+		return COM_QUERY
+	}
+	return COM_STMT_EXECUTE
+}
+
+func (c *Conn) execute(command string, args ...interface{}) (*Result, error) {
 	if len(args) == 0 {
 		return c.exec(command)
 	} else {
-		if s, err := c.Prepare(command); err != nil {
+		if s, err := c.prepareCached(command); err != nil {
 			return nil, errors.Trace(err)
 		} else {
 			var r *Result
-			r, err = s.Execute(args...)
-			s.Close()
+			r, err = s.executeOrReprepare(args...)
+			if err == nil && c.HasMoreResults() {
+				// closing it now would get in the middle of the remaining results
+				c.pendingStmt = s
+			} else {
+				s.Close()
+			}
 			return r, err
 		}
 	}
@@ -254,7 +481,13 @@ func (c *Conn) Execute(command string, args ...interface{}) (*Result, error) {
 // // Use the result as you want
 // })
 func (c *Conn) ExecuteMultiple(query string, perResultCallback ExecPerResultCallback) (*Result, error) {
-	if err := c.writeCommandStr(COM_QUERY, query); err != nil {
+	return c.intercept(context.Background(), COM_QUERY, query, nil, func() (*Result, error) {
+		return c.executeMultiple(query, perResultCallback)
+	})
+}
+
+func (c *Conn) executeMultiple(query string, perResultCallback ExecPerResultCallback) (*Result, error) {
+	if err := c.withReconnect(false, func() error { return c.writeCommandStr(COM_QUERY, query) }); err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -277,8 +510,7 @@ func (c *Conn) ExecuteMultiple(query string, perResultCallback ExecPerResultCall
 			err = c.handleErrorPacket(bytes.Repeat(bs.B, 1))
 			result = nil
 		case LocalInFile_HEADER:
-			err = ErrMalformPacket
-			result = nil
+			result, err = c.handleLocalInfile(bs.B)
 		default:
 			result, err = c.readResultset(bs.B, false)
 		}
@@ -306,6 +538,8 @@ func (c *Conn) ExecuteMultiple(query string, perResultCallback ExecPerResultCall
 // When given, perResultCallback will be called once per result
 //
 // ExecuteSelectStreaming should be used only for SELECT queries with a large response resultset for memory preserving.
+// If perRowCallback returns an error, the remaining rows are read and discarded, so that the connection can still
+// be used, and the error is returned.
 //
 // Example:
 //
@@ -316,11 +550,16 @@ func (c *Conn) ExecuteMultiple(query string, perResultCallback ExecPerResultCall
 // return nil
 // }, nil)
 func (c *Conn) ExecuteSelectStreaming(command string, result *Result, perRowCallback SelectPerRowCallback, perResultCallback SelectPerResultCallback) error {
-	if err := c.writeCommandStr(COM_QUERY, command); err != nil {
-		return errors.Trace(err)
-	}
+	_, err := c.intercept(context.Background(), COM_QUERY, command, nil, func() (*Result, error) {
+		return result, c.withReconnect(false, func() error {
+			if err := c.writeCommandStr(COM_QUERY, command); err != nil {
+				return errors.Trace(err)
+			}
 
-	return c.readResultStreaming(false, result, perRowCallback, perResultCallback)
+			return c.readResultStreaming(false, result, perRowCallback, perResultCallback)
+		})
+	})
+	return err
 }
 
 func (c *Conn) Begin() error {
@@ -338,12 +577,21 @@ func (c *Conn) Rollback() error {
 	return errors.Trace(err)
 }
 
+// SetAttributes adds connection attributes sent to the server in the handshake, shown by
+// performance_schema.session_connect_attrs. Attributes with an empty value are not sent, e.g. to remove a default one.
+// pass to options when connect
 func (c *Conn) SetAttributes(attributes map[string]string) {
 	for k, v := range attributes {
 		c.attributes[k] = v
 	}
 }
 
+// SetProgramName sets the program_name connection attribute, identifying the application to the server.
+// pass to options when connect
+func (c *Conn) SetProgramName(name string) {
+	c.attributes["program_name"] = name
+}
+
 func (c *Conn) SetCharset(charset string) error {
 	if c.charset == charset {
 		return nil
@@ -404,6 +652,37 @@ func (c *Conn) IsInTransaction() bool {
 	return c.status&SERVER_STATUS_IN_TRANS > 0
 }
 
+// HasMoreResults tells if the last command returned more results than the ones read so far,
+// they must be read with NextResult before sending another command.
+func (c *Conn) HasMoreResults() bool {
+	return c.status&SERVER_MORE_RESULTS_EXISTS > 0
+}
+
+// NextResult reads the next result of the last command, e.g. the next result set of a CALL statement,
+// its OUT parameters (with SERVER_PS_OUT_PARAMS in Status) or its final OK. It returns nil once all the
+// results are read. The results which are not read are discarded by the next command.
+//
+// Example:
+//
+// r, err := conn.Execute("CALL p(?)", 1)
+// for ; r != nil && err == nil; r, err = conn.NextResult() {
+// // Use the result as you want
+// }
+func (c *Conn) NextResult() (*Result, error) {
+	if !c.HasMoreResults() {
+		return nil, nil
+	}
+
+	r, err := c.readResult(c.binaryResult)
+	if err != nil || !c.HasMoreResults() {
+		if c.pendingStmt != nil {
+			c.pendingStmt.Close()
+			c.pendingStmt = nil
+		}
+	}
+	return r, errors.Trace(err)
+}
+
 func (c *Conn) GetCharset() string {
 	return c.charset
 }
@@ -0,0 +1,37 @@
+package client_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+)
+
+func TestClientConnectHosts(t *testing.T) {
+	// an address nobody listens on
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	downAddr := down.Addr().String()
+	down.Close()
+
+	addr := serveFake(t, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c, err := client.ConnectHosts(ctx, []string{downAddr, addr}, "root", "123", "", nil)
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.Ping())
+
+	// the reconnection dials the server which answered
+	require.NoError(t, c.Reconnect())
+	require.NoError(t, c.Ping())
+
+	_, err = client.ConnectHosts(ctx, []string{downAddr}, "root", "123", "", nil)
+	require.Error(t, err)
+}
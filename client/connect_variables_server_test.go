@@ -0,0 +1,63 @@
+package client_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// queryRecordHandler records the queries of a connection
+type queryRecordHandler struct {
+	server.EmptyHandler
+	mu      sync.Mutex
+	queries []string
+}
+
+func (h *queryRecordHandler) HandleQuery(query string) (*mysql.Result, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.queries = append(h.queries, query)
+	return nil, nil
+}
+
+func (h *queryRecordHandler) takeQueries() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	q := h.queries
+	h.queries = nil
+	return q
+}
+
+func TestClientConnectVariables(t *testing.T) {
+	h := &queryRecordHandler{}
+	attributes := make(chan map[string]string, 1)
+	addr := (&fakeServer{h: h, onConn: func(co *server.Conn, _ server.Handler, err error) {
+		if err == nil {
+			attributes <- co.Attributes()
+		}
+	}}).listen(t)
+
+	c, err := client.Connect(addr, "root", "123", "", func(c *client.Conn) {
+		c.SetProgramName("billing")
+		c.SetAttributes(map[string]string{"team": "payments"})
+		c.SetConnectVariables(map[string]interface{}{"sql_mode": "TRADITIONAL", "wait_timeout": 60})
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	attrs := <-attributes
+	require.Equal(t, "billing", attrs["program_name"])
+	require.Equal(t, "payments", attrs["team"])
+
+	set := []string{"SET SESSION sql_mode = 'TRADITIONAL'", "SET SESSION wait_timeout = 60"}
+	require.Equal(t, set, h.takeQueries())
+
+	// the variables are set again once the session is reset
+	require.NoError(t, c.ResetConnection())
+	require.Equal(t, set, h.takeQueries())
+}
@@ -0,0 +1,182 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// CancelMode is how a command run with a context is cancelled once the context is done.
+type CancelMode int
+
+const (
+	// CancelByClose closes the connection, it can not be used anymore
+	CancelByClose CancelMode = iota
+	// CancelByKill runs KILL QUERY over a new connection to the server, the command fails with ER_QUERY_INTERRUPTED
+	// and the connection can still be used. The connection is closed if the KILL fails.
+	CancelByKill
+)
+
+// killTimeout bounds the time spent connecting to the server and running the KILL QUERY of CancelByKill.
+var killTimeout = 5 * time.Second
+
+// SetCancelMode sets how the commands run with a context are cancelled, CancelByClose by default.
+// pass to options when connect
+func (c *Conn) SetCancelMode(m CancelMode) {
+	c.cancelMode = m
+}
+
+// ExecuteContext is Execute bound to ctx: the command is cancelled once ctx is done (see SetCancelMode) and
+// ctx.Err() is returned. With CancelByClose, the deadline of ctx is also set on the network connection.
+func (c *Conn) ExecuteContext(ctx context.Context, command string, args ...interface{}) (*Result, error) {
+	return c.intercept(ctx, executeCommandType(args), command, args, func() (*Result, error) {
+		stop, err := c.watchContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// the command is not retried after a reconnection, it may have been cancelled
+		var r *Result
+		err = c.withReconnect(false, func() error {
+			r, err = c.execute(command, args...)
+			return err
+		})
+		return r, stop(err)
+	})
+}
+
+// PrepareContext is Prepare bound to ctx, see ExecuteContext.
+func (c *Conn) PrepareContext(ctx context.Context, query string) (s *Stmt, err error) {
+	_, err = c.intercept(ctx, COM_STMT_PREPARE, query, nil, func() (*Result, error) {
+		stop, err := c.watchContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return nil, stop(c.withReconnect(false, func() error {
+			s, err = c.prepareCached(query)
+			return err
+		}))
+	})
+	return s, err
+}
+
+// ExecuteContext is Stmt.Execute bound to ctx, see Conn.ExecuteContext.
+func (s *Stmt) ExecuteContext(ctx context.Context, args ...interface{}) (*Result, error) {
+	return s.conn.intercept(ctx, COM_STMT_EXECUTE, s.query, args, func() (*Result, error) {
+		stop, err := s.conn.watchContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		r, err := s.executeOrReprepare(args...)
+		return r, stop(err)
+	})
+}
+
+// BeginContext is Begin bound to ctx, see ExecuteContext.
+func (c *Conn) BeginContext(ctx context.Context) error {
+	_, err := c.ExecuteContext(ctx, "BEGIN")
+	return err
+}
+
+// CommitContext is Commit bound to ctx, see ExecuteContext.
+func (c *Conn) CommitContext(ctx context.Context) error {
+	_, err := c.ExecuteContext(ctx, "COMMIT")
+	return err
+}
+
+// RollbackContext is Rollback bound to ctx, see ExecuteContext.
+func (c *Conn) RollbackContext(ctx context.Context) error {
+	_, err := c.ExecuteContext(ctx, "ROLLBACK")
+	return err
+}
+
+// PingContext is Ping bound to ctx, see ExecuteContext.
+func (c *Conn) PingContext(ctx context.Context) error {
+	_, err := c.intercept(ctx, COM_PING, "", nil, func() (*Result, error) {
+		stop, err := c.watchContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return nil, stop(c.withReconnect(false, c.ping))
+	})
+	return err
+}
+
+// watchContext cancels the command about to run once ctx is done, until the returned stop function is called with
+// the result of the command. stop returns ctx.Err() instead of the error of a cancelled command.
+func (c *Conn) watchContext(ctx context.Context) (stop func(error) error, err error) {
+	if ctx.Done() == nil {
+		return func(err error) error { return err }, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	deadline, hasDeadline := ctx.Deadline()
+	hasDeadline = hasDeadline && c.cancelMode == CancelByClose
+	if hasDeadline {
+		if err := c.SetDeadline(deadline); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		select {
+		case <-ctx.Done():
+			c.cancel()
+		case <-done:
+		}
+	}()
+
+	return func(err error) error {
+		close(done)
+		<-finished
+		if hasDeadline && c.Conn != nil {
+			_ = c.SetDeadline(time.Time{})
+		}
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}, nil
+}
+
+// cancel cancels the running command according to the cancel mode.
+func (c *Conn) cancel() {
+	if c.cancelMode == CancelByKill {
+		err := c.killQuery()
+		if err == nil {
+			return
+		}
+		c.logger.Errorf("cancel query of connection %d: %v", c.connectionID, err)
+	}
+	// wakes up the blocked read or write
+	_ = c.Conn.Conn.Close()
+}
+
+// killQuery runs KILL QUERY for the connection over a new connection with the same settings.
+func (c *Conn) killQuery() error {
+	ctx, cancel := context.WithTimeout(context.Background(), killTimeout)
+	defer cancel()
+
+	killer, err := ConnectWithDialer(ctx, c.proto, c.addr, c.user, c.password, "", c.dialer, func(k *Conn) {
+		k.tlsConfig = c.tlsConfig
+		k.logger = c.logger
+	})
+	if err != nil {
+		return err
+	}
+	defer killer.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = killer.SetDeadline(deadline)
+	}
+	_, err = killer.Execute(fmt.Sprintf("KILL QUERY %d", c.connectionID))
+	return err
+}
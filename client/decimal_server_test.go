@@ -0,0 +1,52 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// echoHandler returns the argument of the statements as it is decoded by the server
+type echoHandler struct {
+	server.EmptyHandler
+}
+
+func (h *echoHandler) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	return 1, 1, nil, nil
+}
+
+func (h *echoHandler) HandleStmtExecute(context interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	rs, err := mysql.BuildSimpleBinaryResultset([]string{"a"}, [][]interface{}{{args[0]}})
+	if err != nil {
+		return nil, err
+	}
+	return &mysql.Result{Resultset: rs}, nil
+}
+
+func TestClientDecimal(t *testing.T) {
+	c, err := client.Connect(serveFake(t, &echoHandler{}), "root", "123", "")
+	require.NoError(t, err)
+	defer c.Close()
+
+	// both decimal types are sent as a DECIMAL with their scale and decoded by the server as a mysql.Decimal
+	for _, arg := range []interface{}{
+		mysql.MustParseDecimal("-12345678901234567890.10"),
+		decimal.RequireFromString("-12345678901234567890.10"),
+	} {
+		r, err := c.Execute("SELECT ?", arg)
+		require.NoError(t, err)
+		require.Equal(t, uint8(mysql.MYSQL_TYPE_NEWDECIMAL), r.Fields[0].Type)
+
+		d, err := r.GetMysqlDecimal(0, 0)
+		require.NoError(t, err)
+		require.Equal(t, "-12345678901234567890.10", d.String())
+		dd, err := r.GetDecimal(0, 0)
+		require.NoError(t, err)
+		require.True(t, dd.Equal(decimal.RequireFromString("-12345678901234567890.1")))
+	}
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/errors"
+)
+
+// DefaultFallbackDelay is the delay after which ConnectHosts dials the next address if the previous one did not
+// answer yet, as net.Dialer does between IPv6 and IPv4.
+const DefaultFallbackDelay = 300 * time.Millisecond
+
+// DialHosts dials the addresses in turn and returns the first connection established, with its address. Like the
+// Happy Eyeballs algorithm, the next address is dialed once the previous one failed or did not answer within
+// fallbackDelay, without waiting for its timeout. The network of each address is guessed if network is empty.
+func DialHosts(ctx context.Context, network string, addrs []string, dialer Dialer, fallbackDelay time.Duration) (net.Conn, string, error) {
+	if len(addrs) == 0 {
+		return nil, "", errors.New("no address to dial")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type dialResult struct {
+		conn net.Conn
+		addr string
+		err  error
+	}
+	// buffered so that the dials ending after the first connection don't block
+	results := make(chan dialResult, len(addrs))
+	next, pending := 0, 0
+	dialNext := func() {
+		addr := addrs[next]
+		next++
+		pending++
+		go func() {
+			proto := network
+			if proto == "" {
+				proto = getNetProto(addr)
+			}
+			conn, err := dialer(ctx, proto, addr)
+			results <- dialResult{conn: conn, addr: addr, err: err}
+		}()
+	}
+
+	timer := time.NewTimer(fallbackDelay)
+	defer timer.Stop()
+	resetTimer := func() {
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(fallbackDelay)
+	}
+
+	var firstErr error
+	dialNext()
+	for {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// the connections established too late are closed
+				go func(pending int) {
+					for i := 0; i < pending; i++ {
+						if late := <-results; late.err == nil {
+							late.conn.Close()
+						}
+					}
+				}(pending)
+				return r.conn, r.addr, nil
+			}
+
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if next < len(addrs) {
+				dialNext()
+				resetTimer()
+			} else if pending == 0 {
+				return nil, "", errors.Trace(firstErr)
+			}
+		case <-timer.C:
+			if next < len(addrs) {
+				dialNext()
+				timer.Reset(fallbackDelay)
+			}
+		}
+	}
+}
+
+// ConnectHosts connects to the first server answering among addrs, see DialHosts, e.g. the replicas of a cluster
+// or the addresses returned by LookupSRV. The dialer can be nil. The reconnections and the connections cancelling
+// the queries dial the server which answered.
+func ConnectHosts(ctx context.Context, addrs []string, user string, password string, dbName string, dialer Dialer, options ...func(*Conn)) (*Conn, error) {
+	if dialer == nil {
+		d := &net.Dialer{}
+		dialer = d.DialContext
+	}
+
+	conn, addr, err := DialHosts(ctx, "", addrs, dialer, DefaultFallbackDelay)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	// the first dial returns the connection already established
+	var dialed int32
+	reuse := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if atomic.CompareAndSwapInt32(&dialed, 0, 1) {
+			return conn, nil
+		}
+		return dialer(ctx, network, address)
+	}
+	return ConnectWithDialer(ctx, "", addr, user, password, dbName, reuse, options...)
+}
+
+// LookupSRV discovers the addresses of the servers with the DNS SRV records of the service, e.g.
+// _mysql._tcp.example.com for LookupSRV(ctx, "mysql", "tcp", "example.com"). The addresses are sorted by priority
+// and randomized by weight, to be passed to ConnectHosts.
+func LookupSRV(ctx context.Context, service string, proto string, name string) ([]string, error) {
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, service, proto, name)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	addrs := make([]string, 0, len(records))
+	for _, r := range records {
+		addrs = append(addrs, net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port))))
+	}
+	return addrs, nil
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialHosts(t *testing.T) {
+	var mu sync.Mutex
+	var dialed []string
+	// "slow" never answers, "down" fails right away
+	dialer := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		mu.Lock()
+		dialed = append(dialed, addr)
+		mu.Unlock()
+		switch addr {
+		case "slow:3306":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		case "down:3306":
+			return nil, errors.New("connection refused")
+		}
+		c, _ := net.Pipe()
+		return c, nil
+	}
+
+	// the next address is dialed once the previous one did not answer within the fallback delay
+	start := time.Now()
+	conn, addr, err := DialHosts(context.Background(), "", []string{"slow:3306", "up:3306"}, dialer, 50*time.Millisecond)
+	require.NoError(t, err)
+	conn.Close()
+	require.Equal(t, "up:3306", addr)
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// or right away once it failed
+	mu.Lock()
+	dialed = nil
+	mu.Unlock()
+	start = time.Now()
+	conn, addr, err = DialHosts(context.Background(), "", []string{"down:3306", "up:3306", "other:3306"}, dialer, time.Minute)
+	require.NoError(t, err)
+	conn.Close()
+	require.Equal(t, "up:3306", addr)
+	require.Less(t, time.Since(start), time.Minute)
+	mu.Lock()
+	require.Equal(t, []string{"down:3306", "up:3306"}, dialed)
+	mu.Unlock()
+
+	_, _, err = DialHosts(context.Background(), "", []string{"down:3306", "down:3306"}, dialer, time.Millisecond)
+	require.ErrorContains(t, err, "connection refused")
+	_, _, err = DialHosts(context.Background(), "", nil, dialer, time.Millisecond)
+	require.Error(t, err)
+}
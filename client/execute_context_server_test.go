@@ -0,0 +1,64 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// sleepHandler runs every query until it is killed or its connection is closed
+type sleepHandler struct {
+	server.EmptyHandler
+	conn *server.Conn
+}
+
+func (h *sleepHandler) HandleQuery(query string) (*mysql.Result, error) {
+	ctx := h.conn.Context()
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestClientExecuteContext(t *testing.T) {
+	addr := (&fakeServer{
+		newHandler: func() server.Handler { return &sleepHandler{} },
+		onConn: func(co *server.Conn, h server.Handler, err error) {
+			if err == nil {
+				h.(*sleepHandler).conn = co
+			}
+		},
+	}).listen(t)
+
+	// the connection is closed by default
+	c, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.ExecuteContext(ctx, "SELECT SLEEP(10)")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Error(t, c.Ping())
+
+	// KILL QUERY keeps the connection usable
+	c, err = client.Connect(addr, "root", "123", "", func(c *client.Conn) {
+		c.SetCancelMode(client.CancelByKill)
+	})
+	require.NoError(t, err)
+	defer c.Close()
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	_, err = c.ExecuteContext(ctx, "SELECT SLEEP(10)")
+	require.ErrorIs(t, err, context.Canceled)
+	require.NoError(t, c.PingContext(context.Background()))
+
+	// a done context does not run the command
+	require.ErrorIs(t, c.BeginContext(ctx), context.Canceled)
+}
@@ -0,0 +1,204 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+)
+
+// Intent tells whether a connection is wanted to write or only to read, a reading connection can be opened to a
+// replica.
+type Intent int
+
+const (
+	IntentWrite Intent = iota
+	IntentRead
+)
+
+func (i Intent) String() string {
+	if i == IntentRead {
+		return "read"
+	}
+	return "write"
+}
+
+// QueryIntent returns IntentRead for the queries which only read (SELECT, SHOW, DESCRIBE and EXPLAIN) and can be run
+// by a replica, IntentWrite for the others, including the locking reads SELECT ... FOR UPDATE, FOR SHARE and LOCK IN
+// SHARE MODE.
+func QueryIntent(query string) Intent {
+	if !isIdempotent(query) {
+		return IntentWrite
+	}
+	upper := strings.ToUpper(query)
+	for _, lock := range []string{" FOR UPDATE", " FOR SHARE", " LOCK IN SHARE MODE"} {
+		if strings.Contains(upper, lock) {
+			return IntentWrite
+		}
+	}
+	return IntentRead
+}
+
+// DefaultEndpointRetryDelay is the delay during which a failing endpoint of a MultiHost is tried after the others.
+const DefaultEndpointRetryDelay = 5 * time.Second
+
+// Endpoint is an address of a MultiHost.
+type Endpoint struct {
+	Addr string
+	// Replica is set for a read-only replica, only connected to with IntentRead
+	Replica bool
+	// Weight spreads the connections among the replicas, the replicas with a greater weight being tried first more
+	// often, 1 if not set. The primaries are tried in the order of the list.
+	Weight int
+}
+
+// MultiHost connects to the first healthy server among a primary, its standby primaries and their replicas, failing
+// over to the next endpoint when a connection fails to be established, e.g. because the server is down or refuses
+// the connection. The endpoints which failed are tried after the others during the retry delay.
+//
+// The connections are connected to a single server: their reconnections, see SetReconnectPolicy, dial the same
+// endpoint, and a lost connection should be replaced by a new one from Connect to fail over.
+type MultiHost struct {
+	endpoints  []Endpoint
+	user       string
+	password   string
+	dbName     string
+	options    []func(*Conn)
+	dialer     Dialer
+	retryDelay time.Duration
+
+	mu        sync.Mutex
+	downUntil map[string]time.Time
+	rand      *rand.Rand
+}
+
+// NewMultiHost returns a MultiHost connecting to the endpoints with the user, the database and the options of
+// Connect.
+func NewMultiHost(endpoints []Endpoint, user string, password string, dbName string, options ...func(*Conn)) *MultiHost {
+	d := &net.Dialer{}
+	return &MultiHost{
+		endpoints:  endpoints,
+		user:       user,
+		password:   password,
+		dbName:     dbName,
+		options:    options,
+		dialer:     d.DialContext,
+		retryDelay: DefaultEndpointRetryDelay,
+		downUntil:  make(map[string]time.Time),
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SetDialer sets the dialer of the connections, net.Dialer by default.
+func (m *MultiHost) SetDialer(dialer Dialer) {
+	m.dialer = dialer
+}
+
+// SetRetryDelay sets the delay during which a failing endpoint is tried after the others, DefaultEndpointRetryDelay
+// by default.
+func (m *MultiHost) SetRetryDelay(d time.Duration) {
+	m.retryDelay = d
+}
+
+// Connect connects to the first endpoint answering for the intent: the primaries for IntentWrite, the replicas then
+// the primaries for IntentRead. It returns the connection with the endpoint it is connected to, or the error of the
+// first endpoint tried if none answered.
+func (m *MultiHost) Connect(ctx context.Context, intent Intent) (*Conn, Endpoint, error) {
+	candidates := m.candidates(intent)
+	if len(candidates) == 0 {
+		return nil, Endpoint{}, errors.Errorf("no endpoint to %s", intent)
+	}
+
+	var firstErr error
+	for _, e := range candidates {
+		c, err := ConnectWithDialer(ctx, "", e.Addr, m.user, m.password, m.dbName, m.dialer, m.options...)
+		if err == nil {
+			m.markUp(e.Addr)
+			return c, e, nil
+		}
+		m.markDown(e.Addr)
+		if firstErr == nil {
+			firstErr = errors.Annotatef(err, "connect to %s", e.Addr)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, Endpoint{}, firstErr
+}
+
+// candidates returns the endpoints to try for the intent in turn, the endpoints down last.
+func (m *MultiHost) candidates(intent Intent) []Endpoint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var primaries, replicas []Endpoint
+	for _, e := range m.endpoints {
+		if e.Replica {
+			replicas = append(replicas, e)
+		} else {
+			primaries = append(primaries, e)
+		}
+	}
+
+	candidates := primaries
+	if intent == IntentRead {
+		candidates = append(m.shuffleByWeight(replicas), primaries...)
+	}
+
+	now := time.Now()
+	up := make([]Endpoint, 0, len(candidates))
+	var down []Endpoint
+	for _, e := range candidates {
+		if until, ok := m.downUntil[e.Addr]; ok && now.Before(until) {
+			down = append(down, e)
+		} else {
+			up = append(up, e)
+		}
+	}
+	return append(up, down...)
+}
+
+// shuffleByWeight orders the endpoints randomly, each being picked next with a probability proportional to its
+// weight, as for the SRV records.
+func (m *MultiHost) shuffleByWeight(endpoints []Endpoint) []Endpoint {
+	weight := func(e Endpoint) int {
+		if e.Weight <= 0 {
+			return 1
+		}
+		return e.Weight
+	}
+
+	left := append([]Endpoint(nil), endpoints...)
+	shuffled := make([]Endpoint, 0, len(left))
+	for len(left) > 0 {
+		total := 0
+		for _, e := range left {
+			total += weight(e)
+		}
+		n := m.rand.Intn(total)
+		i := 0
+		for ; n >= weight(left[i]); i++ {
+			n -= weight(left[i])
+		}
+		shuffled = append(shuffled, left[i])
+		left = append(left[:i], left[i+1:]...)
+	}
+	return shuffled
+}
+
+func (m *MultiHost) markDown(addr string) {
+	m.mu.Lock()
+	m.downUntil[addr] = time.Now().Add(m.retryDelay)
+	m.mu.Unlock()
+}
+
+func (m *MultiHost) markUp(addr string) {
+	m.mu.Lock()
+	delete(m.downUntil, addr)
+	m.mu.Unlock()
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryIntent(t *testing.T) {
+	for query, intent := range map[string]Intent{
+		"SELECT * FROM t":                         IntentRead,
+		" (select 1)":                             IntentRead,
+		"SHOW TABLES":                             IntentRead,
+		"EXPLAIN SELECT 1":                        IntentRead,
+		"SELECT * FROM t FOR UPDATE":              IntentWrite,
+		"select * from t for share":               IntentWrite,
+		"SELECT * FROM t LOCK IN SHARE MODE":      IntentWrite,
+		"INSERT INTO t SELECT * FROM u":           IntentWrite,
+		"UPDATE t SET a = 1":                      IntentWrite,
+		"SELECTED":                                IntentWrite,
+		"DELETE FROM t WHERE a IN (SELECT 1)":     IntentWrite,
+		"SET SESSION transaction_isolation = 'x'": IntentWrite,
+	} {
+		require.Equal(t, intent, QueryIntent(query), query)
+	}
+}
+
+func TestMultiHostCandidates(t *testing.T) {
+	m := NewMultiHost([]Endpoint{
+		{Addr: "primary:3306"},
+		{Addr: "replica1:3306", Replica: true, Weight: 1},
+		{Addr: "standby:3306"},
+		{Addr: "replica2:3306", Replica: true, Weight: 3},
+	}, "root", "", "")
+
+	addrs := func(endpoints []Endpoint) []string {
+		var a []string
+		for _, e := range endpoints {
+			a = append(a, e.Addr)
+		}
+		return a
+	}
+
+	require.Equal(t, []string{"primary:3306", "standby:3306"}, addrs(m.candidates(IntentWrite)))
+
+	// the replicas are tried first for reading, the heaviest more often
+	first := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		c := addrs(m.candidates(IntentRead))
+		require.Len(t, c, 4)
+		require.Equal(t, []string{"primary:3306", "standby:3306"}, c[2:])
+		first[c[0]]++
+	}
+	require.Greater(t, first["replica2:3306"], first["replica1:3306"])
+
+	// a failing endpoint is tried last until the retry delay elapsed
+	m.SetRetryDelay(50 * time.Millisecond)
+	m.markDown("primary:3306")
+	require.Equal(t, []string{"standby:3306", "primary:3306"}, addrs(m.candidates(IntentWrite)))
+	time.Sleep(60 * time.Millisecond)
+	require.Equal(t, []string{"primary:3306", "standby:3306"}, addrs(m.candidates(IntentWrite)))
+	m.markDown("primary:3306")
+	m.markUp("primary:3306")
+	require.Equal(t, []string{"primary:3306", "standby:3306"}, addrs(m.candidates(IntentWrite)))
+}
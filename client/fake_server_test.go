@@ -0,0 +1,80 @@
+package client_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// fakeServer serves the connections accepted by a local listener with the server package until the end of the
+// test, each in its own goroutine. All its members are optional.
+type fakeServer struct {
+	// svr is a MySQL 8.0 server with mysql_native_password by default
+	svr *server.Server
+	// p knows the user root with the password 123 by default
+	p server.CredentialProvider
+	// h handles the commands of all the connections, newHandler gives each connection its own handler instead,
+	// server.EmptyHandler by default
+	h          server.Handler
+	newHandler func() server.Handler
+	// onConn is called with a connection and its handler once its handshake is done, co is nil and err is set
+	// if it failed
+	onConn func(co *server.Conn, h server.Handler, err error)
+}
+
+// serveFake serves the connections of a local listener with h, see fakeServer, and returns its address.
+func serveFake(t *testing.T, h server.Handler) string {
+	s := &fakeServer{h: h}
+	return s.listen(t)
+}
+
+// listen serves the connections of a local listener closed at the end of the test, and returns its address.
+func (s *fakeServer) listen(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	if s.svr == nil {
+		s.svr = server.NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	}
+	if s.p == nil {
+		p := server.NewInMemoryProvider()
+		p.AddUser("root", "123")
+		s.p = p
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serveConn(conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func (s *fakeServer) serveConn(conn net.Conn) {
+	h := s.h
+	switch {
+	case s.newHandler != nil:
+		h = s.newHandler()
+	case h == nil:
+		h = server.EmptyHandler{}
+	}
+
+	co, err := server.NewCustomizedConn(conn, s.svr, s.p, h)
+	if s.onConn != nil {
+		s.onConn(co, h, err)
+	}
+	if err != nil {
+		return
+	}
+	for co.HandleCommand() == nil {
+	}
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// Command is a command run by a connection, passed to its interceptors.
+type Command struct {
+	Conn *Conn
+	// Type is COM_QUERY (Execute without arguments, ExecuteMultiple and ExecuteSelectStreaming), COM_STMT_EXECUTE
+	// (Execute with arguments and the statements), COM_STMT_PREPARE, COM_INIT_DB (UseDB) or COM_PING
+	Type byte
+	// Query is the query, of the statement for COM_STMT_EXECUTE, or the database for COM_INIT_DB
+	Query string
+	Args  []interface{}
+}
+
+// CommandFunc runs a command, it is the rest of the interceptor chain passed to an Interceptor.
+type CommandFunc func(ctx context.Context, cmd *Command) (*Result, error)
+
+// Interceptor is called with the commands run by Execute, ExecuteContext, ExecuteMultiple, ExecuteSelectStreaming,
+// Prepare, the statements, UseDB, Ping and PingContext, and runs them by calling next. It can observe a command
+// before and after it runs, e.g. to record its duration, the number of rows of its Result and its error, or fail it
+// without calling next. The command must not be modified.
+//
+// ctx is the one of ExecuteContext and PingContext, context.Background() for the other commands. The Result is nil
+// for COM_STMT_PREPARE, COM_INIT_DB and COM_PING, and the one passed to the streaming functions.
+type Interceptor func(ctx context.Context, cmd *Command, next CommandFunc) (*Result, error)
+
+// AddInterceptors appends interceptors to the chain run for the commands of the connection, the first added is
+// called first.
+// pass to options when connect
+func (c *Conn) AddInterceptors(interceptors ...Interceptor) {
+	c.interceptors = append(c.interceptors, interceptors...)
+}
+
+// intercept runs a command through the interceptors of the connection, the last one calling run.
+func (c *Conn) intercept(ctx context.Context, typ byte, query string, args []interface{}, run func() (*Result, error)) (*Result, error) {
+	if len(c.interceptors) == 0 {
+		return run()
+	}
+	handle := func(context.Context, *Command) (*Result, error) {
+		return run()
+	}
+	return chainInterceptors(c.interceptors, handle)(ctx, &Command{Conn: c, Type: typ, Query: query, Args: args})
+}
+
+func chainInterceptors(interceptors []Interceptor, handle CommandFunc) CommandFunc {
+	if len(interceptors) == 0 {
+		return handle
+	}
+	next := chainInterceptors(interceptors[1:], handle)
+	return func(ctx context.Context, cmd *Command) (*Result, error) {
+		return interceptors[0](ctx, cmd, next)
+	}
+}
+
+// SlowCommandLogger returns an interceptor logging the commands taking longer than threshold with the logger of
+// the connection, with their duration and error.
+func SlowCommandLogger(threshold time.Duration) Interceptor {
+	return func(ctx context.Context, cmd *Command, next CommandFunc) (*Result, error) {
+		start := time.Now()
+		r, err := next(ctx, cmd)
+		if d := time.Since(start); d >= threshold {
+			cmd.Conn.logger.Warnf("slow command (%v) on connection %d: %s, err: %v", d, cmd.Conn.connectionID, cmd.Query, err)
+		}
+		return r, err
+	}
+}
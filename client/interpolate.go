@@ -0,0 +1,234 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/shopspring/decimal"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// multibyteUnsafeCharsets are the charsets in which the second byte of a character can be a backslash or a quote,
+// their strings are sent as hexadecimal literals so that the escaping can't be defeated.
+var multibyteUnsafeCharsets = map[string]bool{
+	"big5":    true,
+	"cp932":   true,
+	"gb18030": true,
+	"gbk":     true,
+	"sjis":    true,
+}
+
+// ExecuteInterpolated runs the query with its ? placeholders replaced by the arguments, escaped by the client, in
+// a single round trip without a prepared statement. This is meant for the servers and the proxies which don't
+// support the prepared statements, Execute should be preferred otherwise.
+//
+// The arguments can be nil, a bool, an integer, a float, a string, a []byte, a json.RawMessage or a time.Time,
+// which is sent in the location set with SetTimeLocation, in UTC if not set. The escaping follows the
+// NO_BACKSLASH_ESCAPES mode and the charset of the connection.
+func (c *Conn) ExecuteInterpolated(query string, args ...interface{}) (*Result, error) {
+	q, err := c.interpolate(query, args)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return c.Execute(q)
+}
+
+// ExecuteInterpolatedContext is ExecuteInterpolated bound to ctx, see ExecuteContext.
+func (c *Conn) ExecuteInterpolatedContext(ctx context.Context, query string, args ...interface{}) (*Result, error) {
+	q, err := c.interpolate(query, args)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return c.ExecuteContext(ctx, q)
+}
+
+// interpolate replaces the ? placeholders of query by the arguments, skipping the quoted strings, the quoted
+// identifiers and the comments.
+func (c *Conn) interpolate(query string, args []interface{}) (string, error) {
+	noBackslashEscapes := c.status&SERVER_STATUS_NO_BACKSLASH_ESCAPED != 0
+
+	buf := make([]byte, 0, len(query)+len(args)*16)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		switch ch := query[i]; {
+		case ch == '?':
+			if n >= len(args) {
+				return "", errors.Errorf("argument mismatch, need more than %d arguments", len(args))
+			}
+			var err error
+			if buf, err = c.appendArgument(buf, args[n], noBackslashEscapes); err != nil {
+				return "", err
+			}
+			n++
+			continue
+		case ch == '\'' || ch == '"' || ch == '`':
+			end := i + 1
+			for ; end < len(query) && query[end] != ch; end++ {
+				if query[end] == '\\' && ch != '`' && !noBackslashEscapes {
+					end++
+				}
+			}
+			if end < len(query) {
+				end++
+			}
+			buf = append(buf, query[i:end]...)
+			i = end - 1
+			continue
+		case ch == '#' || ch == '-' && i+2 < len(query) && query[i+1] == '-' && isSpace(query[i+2]):
+			end := i
+			for end < len(query) && query[end] != '\n' {
+				end++
+			}
+			buf = append(buf, query[i:end]...)
+			i = end - 1
+			continue
+		case ch == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := i + 2
+			for end+1 < len(query) && !(query[end] == '*' && query[end+1] == '/') {
+				end++
+			}
+			if end += 2; end > len(query) {
+				end = len(query)
+			}
+			buf = append(buf, query[i:end]...)
+			i = end - 1
+			continue
+		}
+		buf = append(buf, query[i])
+	}
+
+	if n != len(args) {
+		return "", errors.Errorf("argument mismatch, need %d but got %d", n, len(args))
+	}
+	return string(buf), nil
+}
+
+// appendArgument appends an argument of ExecuteInterpolated as an SQL literal.
+func (c *Conn) appendArgument(buf []byte, arg interface{}, noBackslashEscapes bool) ([]byte, error) {
+	switch v := arg.(type) {
+	case nil:
+		return append(buf, "NULL"...), nil
+	case bool:
+		if v {
+			return append(buf, '1'), nil
+		}
+		return append(buf, '0'), nil
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int8:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int16:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(buf, v, 10), nil
+	case uint:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint8:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint16:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint32:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint64:
+		return strconv.AppendUint(buf, v, 10), nil
+	case float32:
+		return appendFloat(buf, float64(v), 32)
+	case float64:
+		return appendFloat(buf, v, 64)
+	case Decimal:
+		// an exact-value literal, not a float
+		return append(buf, v.String()...), nil
+	case decimal.Decimal:
+		return c.appendArgument(buf, FromDecimal(v), noBackslashEscapes)
+	case Geometry:
+		if v.Shape == nil {
+			return append(buf, "NULL"...), nil
+		}
+		buf = append(buf, "X'"...)
+		buf = append(buf, hex.EncodeToString(v.Bytes())...)
+		return append(buf, '\''), nil
+	case string:
+		return c.appendString(buf, v, noBackslashEscapes), nil
+	case json.RawMessage:
+		if v == nil {
+			return append(buf, "NULL"...), nil
+		}
+		return c.appendString(buf, string(v), noBackslashEscapes), nil
+	case []byte:
+		if v == nil {
+			return append(buf, "NULL"...), nil
+		}
+		// a hexadecimal literal is a binary string whatever the charset and the SQL mode
+		buf = append(buf, "X'"...)
+		buf = append(buf, hex.EncodeToString(v)...)
+		return append(buf, '\''), nil
+	case time.Time:
+		if v.IsZero() {
+			return append(buf, "'0000-00-00'"...), nil
+		}
+		loc := c.timeLocation
+		if loc == nil {
+			loc = time.UTC
+		}
+		buf = append(buf, '\'')
+		buf = v.In(loc).AppendFormat(buf, "2006-01-02 15:04:05.999999")
+		return append(buf, '\''), nil
+	default:
+		return nil, errors.Errorf("invalid argument type %T", arg)
+	}
+}
+
+func appendFloat(buf []byte, f float64, bitSize int) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, errors.Errorf("invalid argument %v", f)
+	}
+	return strconv.AppendFloat(buf, f, 'g', -1, bitSize), nil
+}
+
+// appendString appends s as a quoted string literal, or as a hexadecimal literal introduced by the charset of the
+// connection if this charset is not safe to escape.
+func (c *Conn) appendString(buf []byte, s string, noBackslashEscapes bool) []byte {
+	if multibyteUnsafeCharsets[c.charset] && !isASCII(s) {
+		buf = append(buf, '_')
+		buf = append(buf, c.charset...)
+		buf = append(buf, " X'"...)
+		buf = append(buf, hex.EncodeToString([]byte(s))...)
+		return append(buf, '\'')
+	}
+
+	buf = append(buf, '\'')
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch == '\'':
+			buf = append(buf, '\'', '\'')
+		case noBackslashEscapes:
+			buf = append(buf, ch)
+		case EncodeMap[ch] != DONTESCAPE:
+			buf = append(buf, '\\', EncodeMap[ch])
+		default:
+			buf = append(buf, ch)
+		}
+	}
+	return append(buf, '\'')
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n'
+}
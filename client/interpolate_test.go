@@ -0,0 +1,62 @@
+package client
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnInterpolate(t *testing.T) {
+	c := &Conn{charset: mysql.DEFAULT_CHARSET}
+
+	tests := []struct {
+		query  string
+		args   []interface{}
+		expect string
+	}{
+		{"SELECT ?, ?, ?", []interface{}{nil, true, false}, "SELECT NULL, 1, 0"},
+		{"SELECT ?, ?, ?", []interface{}{int8(-1), uint64(math.MaxUint64), int64(math.MinInt64)}, "SELECT -1, 18446744073709551615, -9223372036854775808"},
+		{"SELECT ?, ?", []interface{}{float32(1.5), 1e21}, "SELECT 1.5, 1e+21"},
+		{"SELECT ?", []interface{}{"it's a \"test\"\\\n\r\x00\x1a"}, `SELECT 'it''s a \"test\"\\\n\r\0\Z'`},
+		{"SELECT ?", []interface{}{"héllo"}, "SELECT 'héllo'"},
+		{"SELECT ?, ?", []interface{}{mysql.MustParseDecimal("-12345678901234567890.10"), mysql.Decimal{}}, "SELECT -12345678901234567890.10, 0"},
+		{"SELECT ?", []interface{}{decimal.RequireFromString("12345678901234567890.50")}, "SELECT 12345678901234567890.50"},
+		{"SELECT ?, ?", []interface{}{mysql.NewGeometry(0, mysql.Point{X: 1, Y: 2}), mysql.Geometry{}},
+			"SELECT X'000000000101000000000000000000f03f0000000000000040', NULL"},
+		{"SELECT ?, ?", []interface{}{[]byte("a'\\"), []byte(nil)}, "SELECT X'61275c', NULL"},
+		{"SELECT ?", []interface{}{json.RawMessage(`{"a":"b'c"}`)}, `SELECT '{\"a\":\"b''c\"}'`},
+		{"SELECT ?, ?", []interface{}{time.Date(2023, 4, 5, 6, 7, 8, 123456789, time.FixedZone("UTC+2", 2*60*60)), time.Time{}},
+			"SELECT '2023-04-05 04:07:08.123456', '0000-00-00'"},
+		{"SELECT '?', \"?\", `?`, 'a\\'?', ? -- ?\n# ?\n/* ? */ + ?", []interface{}{1, 2},
+			"SELECT '?', \"?\", `?`, 'a\\'?', 1 -- ?\n# ?\n/* ? */ + 2"},
+		{"SELECT 1--?", []interface{}{3}, "SELECT 1--3"},
+	}
+	for _, test := range tests {
+		got, err := c.interpolate(test.query, test.args)
+		require.NoError(t, err, test.query)
+		require.Equal(t, test.expect, got)
+	}
+
+	for _, args := range [][]interface{}{{}, {1, 2}, {math.NaN()}, {struct{}{}}} {
+		_, err := c.interpolate("SELECT ?", args)
+		require.Error(t, err)
+	}
+
+	// only the quotes are doubled in the NO_BACKSLASH_ESCAPES mode, where the backslashes don't escape the quotes
+	c.status = mysql.SERVER_STATUS_NO_BACKSLASH_ESCAPED
+	got, err := c.interpolate("SELECT 'a\\', ?", []interface{}{"it's\\\n"})
+	require.NoError(t, err)
+	require.Equal(t, "SELECT 'a\\', 'it''s\\\n'", got)
+
+	// the non-ASCII strings are hexadecimal literals in the charsets whose characters can contain a backslash
+	c.status = 0
+	c.charset = "gbk"
+	got, err = c.interpolate("SELECT ?, ?", []interface{}{"\xbf\x27 OR 1=1", "abc"})
+	require.NoError(t, err)
+	require.Equal(t, "SELECT _gbk X'bf27204f5220313d31', 'abc'", got)
+}
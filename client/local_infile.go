@@ -0,0 +1,114 @@
+package client
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pingcap/errors"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// localInfileChunkSize is the size of the packets carrying the content of a file to the server.
+const localInfileChunkSize = 128 * 1024
+
+// InfileProvider opens the file requested by the server for a LOAD DATA LOCAL INFILE statement, the file is
+// closed once its content is sent.
+type InfileProvider func(filename string) (io.ReadCloser, error)
+
+// SetInfileProvider enables LOAD DATA LOCAL INFILE, the files requested by the server are opened by p, which must
+// check the server is allowed to read them. Combined with SetInfileAllowlist, p only opens the allowed files.
+// pass to options when connect
+func (c *Conn) SetInfileProvider(p InfileProvider) {
+	c.infileProvider = p
+	c.ccaps |= CLIENT_LOCAL_FILES
+}
+
+// SetInfileAllowlist enables LOAD DATA LOCAL INFILE for the files matching one of the patterns, as
+// filepath.Match does with the cleaned name of the file requested by the server, e.g. "/var/lib/import/*.csv".
+// The files are opened by the InfileProvider if set, from the local file system otherwise.
+// pass to options when connect
+func (c *Conn) SetInfileAllowlist(patterns ...string) {
+	c.infileAllowlist = append(c.infileAllowlist, patterns...)
+	c.ccaps |= CLIENT_LOCAL_FILES
+}
+
+// infileAllowed reports whether the server can read the file: the file matches the allowlist, or there is no
+// allowlist and the provider decides.
+func (c *Conn) infileAllowed(filename string) bool {
+	if len(c.infileAllowlist) == 0 {
+		return c.infileProvider != nil
+	}
+	filename = filepath.Clean(filename)
+	for _, pattern := range c.infileAllowlist {
+		if ok, _ := filepath.Match(pattern, filename); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Conn) openInfile(filename string) (io.ReadCloser, error) {
+	if !c.infileAllowed(filename) {
+		return nil, errors.Errorf("LOAD DATA LOCAL INFILE of %q is not allowed", filename)
+	}
+	if c.infileProvider != nil {
+		return c.infileProvider(filename)
+	}
+	return os.Open(filename)
+}
+
+// handleLocalInfile answers the LOCAL INFILE request of the server with the content of the file, then reads
+// the result of the statement. An empty file is sent if the file can not be read, and the error is returned
+// once the server has answered.
+// see: https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_query_response_local_infile_request.html
+func (c *Conn) handleLocalInfile(data []byte) (*Result, error) {
+	filename := string(data[1:])
+
+	sendErr := c.sendInfile(filename)
+	if sendErr != nil && errors.Cause(sendErr) == ErrBadConn {
+		return nil, sendErr
+	}
+
+	r, err := c.readOK()
+	if sendErr != nil {
+		return nil, sendErr
+	}
+	return r, err
+}
+
+// sendInfile sends the content of the file followed by the empty packet ending it.
+func (c *Conn) sendInfile(filename string) error {
+	f, err := c.openInfile(filename)
+	if err == nil {
+		err = c.writeInfile(f)
+		f.Close()
+	}
+	if errors.Cause(err) == ErrBadConn {
+		return err
+	}
+
+	if werr := c.WritePacket(make([]byte, 4)); werr != nil {
+		return werr
+	}
+	return err
+}
+
+func (c *Conn) writeInfile(r io.Reader) error {
+	buf := make([]byte, 4+localInfileChunkSize)
+	for {
+		n, err := r.Read(buf[4:])
+		if n > 0 {
+			if werr := c.WritePacket(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package client_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// loadDataHandler answers LOAD DATA LOCAL INFILE 'name' with a request for the file, the number of lines of the
+// file is returned as the number of affected rows
+type loadDataHandler struct {
+	server.EmptyHandler
+}
+
+func (h loadDataHandler) HandleQuery(query string) (*mysql.Result, error) {
+	parts := strings.Split(query, "'")
+	if len(parts) != 3 {
+		return &mysql.Result{}, nil
+	}
+	return &mysql.Result{LocalInfile: mysql.NewLocalInfileRequest(parts[1], func(r io.Reader) (*mysql.Result, error) {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return &mysql.Result{AffectedRows: uint64(strings.Count(string(content), "\n"))}, nil
+	})}, nil
+}
+
+func TestClientLocalInfile(t *testing.T) {
+	addr := serveFake(t, loadDataHandler{})
+
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "data.csv")
+	// larger than a packet of the file
+	require.NoError(t, os.WriteFile(allowed, []byte(strings.Repeat("a,b\n", 100000)), 0o600))
+	secret := filepath.Join(t.TempDir(), "secret.csv")
+	require.NoError(t, os.WriteFile(secret, []byte("x\n"), 0o600))
+
+	c, err := client.Connect(addr, "root", "123", "", func(c *client.Conn) {
+		c.SetInfileAllowlist(filepath.Join(dir, "*.csv"))
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	r, err := c.Execute("LOAD DATA LOCAL INFILE '" + allowed + "' INTO TABLE t")
+	require.NoError(t, err)
+	require.EqualValues(t, 100000, r.AffectedRows)
+
+	// the files out of the allowlist are not sent, the connection can still be used
+	_, err = c.Execute("LOAD DATA LOCAL INFILE '" + secret + "' INTO TABLE t")
+	require.ErrorContains(t, err, "not allowed")
+	_, err = c.Execute("LOAD DATA LOCAL INFILE '" + filepath.Join(dir, "..", filepath.Base(filepath.Dir(secret)), "secret.csv") + "' INTO TABLE t")
+	require.ErrorContains(t, err, "not allowed")
+	_, err = c.Execute("LOAD DATA LOCAL INFILE '" + filepath.Join(dir, "missing.csv") + "' INTO TABLE t")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = c.Execute("SELECT 1")
+	require.NoError(t, err)
+
+	// a provider serves files which are not on the file system
+	c2, err := client.Connect(addr, "root", "123", "", func(c *client.Conn) {
+		c.SetInfileProvider(func(filename string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("1\n2\n3\n")), nil
+		})
+	})
+	require.NoError(t, err)
+	defer c2.Close()
+
+	var result mysql.Result
+	err = c2.ExecuteSelectStreaming("LOAD DATA LOCAL INFILE 'Reader::numbers' INTO TABLE t", &result, nil, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, result.AffectedRows)
+
+	// LOCAL INFILE is disabled by default
+	c3, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+	defer c3.Close()
+	_, err = c3.Execute("LOAD DATA LOCAL INFILE '" + allowed + "' INTO TABLE t")
+	require.Error(t, err)
+}
@@ -0,0 +1,62 @@
+package client_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// serveVersion serves the connections with a server reporting version, to tell the endpoints apart
+func serveVersion(t *testing.T, version string) string {
+	svr := server.NewServer(version, mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	return (&fakeServer{svr: svr}).listen(t)
+}
+
+func TestClientMultiHost(t *testing.T) {
+	// an address nobody listens on
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	downAddr := down.Addr().String()
+	down.Close()
+
+	standby := serveVersion(t, "8.0.1-standby")
+	replica := serveVersion(t, "8.0.1-replica")
+
+	m := client.NewMultiHost([]client.Endpoint{
+		{Addr: downAddr},
+		{Addr: standby},
+		{Addr: replica, Replica: true},
+	}, "root", "123", "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the primary being down, the writes fail over to the standby
+	c, e, err := m.Connect(ctx, client.IntentWrite)
+	require.NoError(t, err)
+	require.Equal(t, standby, e.Addr)
+	require.Equal(t, "8.0.1-standby", c.GetServerVersion())
+	c.Close()
+
+	c, e, err = m.Connect(ctx, client.QueryIntent("SELECT 1"))
+	require.NoError(t, err)
+	require.True(t, e.Replica)
+	require.Equal(t, "8.0.1-replica", c.GetServerVersion())
+	c.Close()
+
+	// a wrong password fails on every endpoint, the error is the one of the first
+	m = client.NewMultiHost([]client.Endpoint{{Addr: standby}, {Addr: replica, Replica: true}}, "root", "bad", "")
+	_, _, err = m.Connect(ctx, client.IntentRead)
+	require.ErrorContains(t, err, replica)
+
+	m = client.NewMultiHost([]client.Endpoint{{Addr: replica, Replica: true}}, "root", "123", "")
+	_, _, err = m.Connect(ctx, client.IntentWrite)
+	require.ErrorContains(t, err, "no endpoint to write")
+}
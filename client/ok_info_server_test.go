@@ -0,0 +1,75 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// okInfoHandler answers the UPDATE statements as MySQL does, with the rows matched and changed in the info
+type okInfoHandler struct {
+	server.EmptyHandler
+}
+
+func (h *okInfoHandler) HandleQuery(query string) (*mysql.Result, error) {
+	switch query {
+	case "UPDATE t SET a = 1":
+		return &mysql.Result{
+			Status: mysql.SERVER_STATUS_AUTOCOMMIT | mysql.SERVER_STATUS_NO_INDEX_USED,
+			Info:   "Rows matched: 3  Changed: 0  Warnings: 0",
+		}, nil
+	case "UPDATE t SET a = 2":
+		return &mysql.Result{
+			AffectedRows: 2,
+			Info:         "Rows matched: 3  Changed: 2  Warnings: 0",
+			SessionStateChanges: []mysql.SessionStateChange{
+				mysql.NewGTIDsChange("3e11fa47-71ca-11e1-9e33-c80aa9429562:24"),
+			},
+		}, nil
+	}
+	return nil, nil
+}
+
+func TestClientOKInfo(t *testing.T) {
+	// the info is length encoded with session tracking, it ends the packet without it
+	for _, sessionTrack := range []bool{true, false} {
+		cfg := server.ServerConfig{Capability: server.DefaultServerCapability}
+		if !sessionTrack {
+			cfg.Capability &^= mysql.CLIENT_SESSION_TRACK
+		}
+		svr := server.NewServerWithConfig(cfg)
+		addr := (&fakeServer{svr: svr, h: &okInfoHandler{}}).listen(t)
+
+		c, err := client.Connect(addr, "root", "123", "")
+		require.NoError(t, err)
+
+		r, err := c.Execute("UPDATE t SET a = 1")
+		require.NoError(t, err)
+		require.Equal(t, "Rows matched: 3  Changed: 0  Warnings: 0", r.Info)
+		require.NotZero(t, r.Status&mysql.SERVER_STATUS_NO_INDEX_USED)
+		matched, changed, ok := r.MatchedRows()
+		require.True(t, ok)
+		require.EqualValues(t, 3, matched)
+		require.EqualValues(t, 0, changed)
+
+		r, err = c.Execute("UPDATE t SET a = 2")
+		require.NoError(t, err)
+		require.EqualValues(t, 2, r.AffectedRows)
+		require.Equal(t, "Rows matched: 3  Changed: 2  Warnings: 0", r.Info)
+		if sessionTrack {
+			require.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:24", r.SessionGTIDs())
+		}
+
+		r, err = c.Execute("SET a = 1")
+		require.NoError(t, err)
+		require.Empty(t, r.Info)
+		_, _, ok = r.MatchedRows()
+		require.False(t, ok)
+
+		c.Close()
+	}
+}
@@ -0,0 +1,93 @@
+// Package oteltrace records the commands of go-mysql client connections as OpenTelemetry spans.
+//
+//	conn, err := client.Connect(addr, user, password, db, func(c *client.Conn) {
+//		c.AddInterceptors(oteltrace.Interceptor(otel.GetTracerProvider()))
+//	})
+package oteltrace
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+const instrumentationName = "github.com/atoonk/go-mysql/client/oteltrace"
+
+// attributes of the results of the commands
+var (
+	affectedRowsKey = attribute.Key("db.mysql.affected_rows")
+	returnedRowsKey = attribute.Key("db.mysql.returned_rows")
+	errorCodeKey    = attribute.Key("db.mysql.error_code")
+)
+
+// Interceptor returns a client interceptor starting a client span for every command, as a child of the span of the
+// context of ExecuteContext and PingContext. The span is named after the operation of the statement, e.g. SELECT,
+// and has the database semantic attributes, the rows of the result and the MySQL error code.
+func Interceptor(tp trace.TracerProvider) client.Interceptor {
+	tracer := tp.Tracer(instrumentationName)
+
+	return func(ctx context.Context, cmd *client.Command, next client.CommandFunc) (*mysql.Result, error) {
+		operation := commandOperation(cmd)
+		attrs := []attribute.KeyValue{
+			semconv.DBSystemMySQL,
+			semconv.DBOperationKey.String(operation),
+			semconv.DBUserKey.String(cmd.Conn.GetUser()),
+		}
+		if db := cmd.Conn.GetDB(); db != "" {
+			attrs = append(attrs, semconv.DBNameKey.String(db))
+		}
+		if cmd.Query != "" && cmd.Type != mysql.COM_INIT_DB {
+			attrs = append(attrs, semconv.DBStatementKey.String(cmd.Query))
+		}
+
+		ctx, span := tracer.Start(ctx, operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+		defer span.End()
+
+		r, err := next(ctx, cmd)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			var m *mysql.MyError
+			if errors.As(err, &m) {
+				span.SetAttributes(errorCodeKey.Int(int(m.Code)))
+			}
+			return r, err
+		}
+		if r != nil {
+			span.SetAttributes(affectedRowsKey.Int64(int64(r.AffectedRows)))
+			if r.Resultset != nil && r.Streaming == mysql.StreamingNone {
+				span.SetAttributes(returnedRowsKey.Int(r.RowNumber()))
+			}
+		}
+		return r, nil
+	}
+}
+
+// commandOperation returns the first keyword of the query, or the name of the command.
+func commandOperation(cmd *client.Command) string {
+	switch cmd.Type {
+	case mysql.COM_PING:
+		return "PING"
+	case mysql.COM_INIT_DB:
+		return "USE"
+	case mysql.COM_STMT_PREPARE:
+		return "PREPARE"
+	}
+
+	query := strings.TrimLeft(cmd.Query, " \t\r\n(")
+	if i := strings.IndexAny(query, " \t\r\n(;"); i >= 0 {
+		query = query[:i]
+	}
+	if query == "" {
+		return "QUERY"
+	}
+	return strings.ToUpper(query)
+}
@@ -0,0 +1,105 @@
+package oteltrace
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+type testHandler struct {
+	server.EmptyHandler
+}
+
+func (h *testHandler) HandleQuery(query string) (*mysql.Result, error) {
+	switch query {
+	case "SELECT a FROM t":
+		rs, err := mysql.BuildSimpleTextResultset([]string{"a"}, [][]interface{}{{int64(1)}, {int64(2)}})
+		if err != nil {
+			return nil, err
+		}
+		return &mysql.Result{Resultset: rs}, nil
+	case "UPDATE t SET a = 1":
+		return &mysql.Result{AffectedRows: 3}, nil
+	}
+	return nil, mysql.NewDefaultError(mysql.ER_NO_SUCH_TABLE, "db", "u")
+}
+
+func TestInterceptor(t *testing.T) {
+	svr := server.NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	p := server.NewInMemoryProvider()
+	p.AddUser("root", "123")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		co, err := server.NewCustomizedConn(conn, svr, p, &testHandler{})
+		if err != nil {
+			return
+		}
+		for co.HandleCommand() == nil {
+		}
+	}()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	c, err := client.Connect(l.Addr().String(), "root", "123", "", func(c *client.Conn) {
+		c.AddInterceptors(Interceptor(tp))
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	_, err = c.ExecuteContext(ctx, "SELECT a FROM t")
+	require.NoError(t, err)
+	parent.End()
+	_, err = c.Execute("UPDATE t SET a = 1")
+	require.NoError(t, err)
+	_, err = c.Execute("select * from u")
+	require.Error(t, err)
+	require.NoError(t, c.Ping())
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 5)
+	attrs := func(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+		m := make(map[attribute.Key]attribute.Value)
+		for _, kv := range s.Attributes() {
+			m[kv.Key] = kv.Value
+		}
+		return m
+	}
+
+	// the span of the statement is a child of the span of the context
+	selectSpan := spans[0]
+	require.Equal(t, "SELECT", selectSpan.Name())
+	require.Equal(t, spans[1].SpanContext().SpanID(), selectSpan.Parent().SpanID())
+	require.Equal(t, "mysql", attrs(selectSpan)["db.system"].AsString())
+	require.Equal(t, "root", attrs(selectSpan)["db.user"].AsString())
+	require.Equal(t, "SELECT a FROM t", attrs(selectSpan)["db.statement"].AsString())
+	require.EqualValues(t, 2, attrs(selectSpan)["db.mysql.returned_rows"].AsInt64())
+
+	require.Equal(t, "UPDATE", spans[2].Name())
+	require.EqualValues(t, 3, attrs(spans[2])["db.mysql.affected_rows"].AsInt64())
+
+	require.Equal(t, "SELECT", spans[3].Name())
+	require.Equal(t, codes.Error, spans[3].Status().Code)
+	require.EqualValues(t, mysql.ER_NO_SUCH_TABLE, attrs(spans[3])["db.mysql.error_code"].AsInt64())
+
+	require.Equal(t, "PING", spans[4].Name())
+}
@@ -0,0 +1,56 @@
+package client_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// packetLimitsHandler answers SELECT @@max_allowed_packet, and SELECT SLEEP after a while
+type packetLimitsHandler struct {
+	server.EmptyHandler
+}
+
+func (h *packetLimitsHandler) HandleQuery(query string) (*mysql.Result, error) {
+	switch {
+	case query == "SELECT @@max_allowed_packet":
+		rs, err := mysql.BuildSimpleTextResultset([]string{"@@max_allowed_packet"}, [][]interface{}{{int64(1024)}})
+		if err != nil {
+			return nil, err
+		}
+		return &mysql.Result{Resultset: rs}, nil
+	case query == "SELECT SLEEP(1)":
+		time.Sleep(time.Second)
+	}
+	return &mysql.Result{}, nil
+}
+
+func TestClientPacketLimits(t *testing.T) {
+	addr := serveFake(t, &packetLimitsHandler{})
+
+	c, err := client.Connect(addr, "root", "123", "", func(c *client.Conn) {
+		c.SetMaxAllowedPacket(client.MaxAllowedPacketFromServer)
+		c.SetReadTimeout(100 * time.Millisecond)
+	})
+	require.NoError(t, err)
+	defer c.Close()
+	require.Equal(t, 1024, c.MaxAllowedPacket)
+
+	// a statement longer than max_allowed_packet is not sent, the connection can still be used
+	_, err = c.Execute("SELECT '" + strings.Repeat("a", 1024) + "'")
+	var myErr *mysql.MyError
+	require.ErrorAs(t, err, &myErr)
+	require.EqualValues(t, mysql.ER_NET_PACKET_TOO_LARGE, myErr.Code)
+	require.NoError(t, c.Ping())
+
+	// a server not answering in time fails the statement
+	_, err = c.Execute("SELECT SLEEP(1)")
+	require.ErrorIs(t, err, mysql.ErrBadConn)
+	require.ErrorContains(t, err, "read timed out")
+}
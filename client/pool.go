@@ -4,6 +4,7 @@ import (
 	"context"
 	"math"
 	"math/rand"
+	"net"
 	"sync"
 	"time"
 
@@ -33,6 +34,8 @@ type (
 		maxIdle          int
 		idleCloseTimeout Timestamp
 		idlePingTimeout  Timestamp
+		checkInterval    time.Duration
+		pingTimeout      time.Duration
 		connect          func() (*Conn, error)
 
 		synchro struct {
@@ -77,6 +80,11 @@ var (
 	MaxNewConnectionAtOnce = 5
 )
 
+const (
+	defaultHealthCheckInterval = 5 * time.Second
+	defaultPingTimeout         = 100 * time.Millisecond
+)
+
 // NewPool initializes new connection pool and uses params: addr, user, password, dbName and options.
 // minAlive specifies the minimum number of open connections that the pool will try to maintain.
 // maxAlive specifies the maximum number of open connections (for internal reasons,
@@ -93,6 +101,44 @@ func NewPool(
 	dbName string,
 	options ...func(conn *Conn),
 ) *Pool {
+	po := defaultPoolOptions(addr, user, password, dbName)
+	po.logFunc = logFunc
+	po.minAlive, po.maxAlive, po.maxIdle = minAlive, maxAlive, maxIdle
+	po.connOptions = options
+	return newPool(po)
+}
+
+// NewPoolWithOptions initializes new connection pool to addr with the options, e.g. WithPoolLimits or WithHealthCheck.
+// It only fails if WithNewPoolPingTimeout is set and the server can not be reached.
+func NewPoolWithOptions(addr, user, password, dbName string, options ...PoolOption) (*Pool, error) {
+	po := defaultPoolOptions(addr, user, password, dbName)
+	for _, o := range options {
+		o(&po)
+	}
+
+	if po.newPoolPingTimeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), po.newPoolPingTimeout)
+		defer cancel()
+		dialer := &net.Dialer{}
+		conn, err := ConnectWithDialer(ctx, "", addr, user, password, dbName, dialer.DialContext, po.connOptions...)
+		if err != nil {
+			return nil, errors.Errorf("could not connect to mysql: %s", err)
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = conn.SetDeadline(deadline)
+		}
+		err = conn.Ping()
+		_ = conn.Close()
+		if err != nil {
+			return nil, errors.Errorf("could not ping mysql: %s", err)
+		}
+	}
+
+	return newPool(po), nil
+}
+
+func newPool(po poolOptions) *Pool {
+	minAlive, maxAlive, maxIdle := po.minAlive, po.maxAlive, po.maxIdle
 	if minAlive > maxAlive {
 		minAlive = maxAlive
 	}
@@ -104,16 +150,18 @@ func NewPool(
 	}
 
 	pool := &Pool{
-		logFunc:  logFunc,
+		logFunc:  po.logFunc,
 		minAlive: minAlive,
 		maxAlive: maxAlive,
 		maxIdle:  maxIdle,
 
-		idleCloseTimeout: Timestamp(math.Ceil(DefaultIdleTimeout.Seconds())),
-		idlePingTimeout:  Timestamp(math.Ceil(MaxIdleTimeoutWithoutPing.Seconds())),
+		idleCloseTimeout: Timestamp(math.Ceil(po.idleTimeout.Seconds())),
+		idlePingTimeout:  Timestamp(math.Ceil(po.idlePingTimeout.Seconds())),
+		checkInterval:    po.healthCheckInterval,
+		pingTimeout:      po.pingTimeout,
 
 		connect: func() (*Conn, error) {
-			return Connect(addr, user, password, dbName, options...)
+			return Connect(po.addr, po.user, po.password, po.dbName, po.connOptions...)
 		},
 
 		readyConnection: make(chan Connection),
@@ -131,8 +179,10 @@ func NewPool(
 		pool.startNewConnections(pool.minAlive)
 	}
 
-	pool.wg.Add(1)
-	go pool.closeOldIdleConnections()
+	if pool.checkInterval > 0 {
+		pool.wg.Add(1)
+		go pool.closeOldIdleConnections()
+	}
 
 	return pool
 }
@@ -263,7 +313,11 @@ func (pool *Pool) newConnectionProducer() {
 				pool.synchro.stats.TotalCount-- // Bad luck, should try again
 				pool.synchro.Unlock()
 
-				time.Sleep(time.Duration(10+rand.Intn(90)) * time.Millisecond)
+				select {
+				case <-time.After(time.Duration(10+rand.Intn(90)) * time.Millisecond):
+				case <-pool.ctx.Done():
+					return
+				}
 				continue
 			}
 		}
@@ -311,7 +365,8 @@ func (pool *Pool) closeOldIdleConnections() {
 	defer pool.wg.Done()
 	var toPing []Connection
 
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(pool.checkInterval)
+	defer ticker.Stop()
 
 	for {
 		select {
@@ -438,21 +493,27 @@ func (pool *Pool) closeIdleConnectionsIfCan() {
 		return
 	}
 
-	closeFromIdx := idleCnt - canCloseCnt
-	if closeFromIdx < 0 {
-		// If there are enough requests in the "flight" now, then we can close all unnecessary
-		closeFromIdx = 0
-	}
-
-	toClose := append([]Connection{}, pool.synchro.idleConnections[closeFromIdx:]...)
+	// Only the connections idle for more than idleCloseTimeout are closed
+	closeBefore := pool.nowTs() - pool.idleCloseTimeout
+	var toClose []Connection
+	for i := idleCnt - 1; i >= 0 && len(toClose) < canCloseCnt; i-- {
+		if pool.synchro.idleConnections[i].lastUseAt > closeBefore {
+			continue
+		}
+		toClose = append(toClose, pool.synchro.idleConnections[i])
 
-	for i := closeFromIdx; i < idleCnt; i++ {
-		pool.synchro.idleConnections[i].conn = nil
+		last := len(pool.synchro.idleConnections) - 1
+		pool.synchro.idleConnections[i] = pool.synchro.idleConnections[last]
+		pool.synchro.idleConnections[last].conn = nil
+		pool.synchro.idleConnections = pool.synchro.idleConnections[:last]
 	}
-	pool.synchro.idleConnections = pool.synchro.idleConnections[:closeFromIdx]
 
 	pool.synchro.Unlock()
 
+	if len(toClose) == 0 {
+		return
+	}
+
 	pool.logFunc(`Pool: Close %d idle connections (in fly %d)`, len(toClose), inFly)
 	for _, connection := range toClose {
 		pool.closeConn(connection.conn)
@@ -486,7 +547,7 @@ func (pool *Pool) startNewConnections(count int) {
 }
 
 func (pool *Pool) ping(conn *Conn) error {
-	deadline := time.Now().Add(100 * time.Millisecond)
+	deadline := time.Now().Add(pool.pingTimeout)
 	_ = conn.SetDeadline(deadline)
 	err := conn.Ping()
 	if err != nil {
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"time"
+
+	"github.com/siddontang/go-log/log"
+)
+
+type (
+	poolOptions struct {
+		logFunc LogFunc
+
+		minAlive int
+		maxAlive int
+		maxIdle  int
+
+		addr     string
+		user     string
+		password string
+		dbName   string
+
+		connOptions []func(conn *Conn)
+
+		idleTimeout         time.Duration
+		idlePingTimeout     time.Duration
+		healthCheckInterval time.Duration
+		pingTimeout         time.Duration
+
+		newPoolPingTimeout time.Duration
+	}
+
+	// PoolOption configures a Pool created with NewPoolWithOptions.
+	PoolOption func(o *poolOptions)
+)
+
+// WithLogFunc sets the function logging the activity of the pool, log.Debugf by default.
+func WithLogFunc(f LogFunc) PoolOption {
+	return func(o *poolOptions) {
+		o.logFunc = f
+	}
+}
+
+// WithPoolLimits sets the minimum and maximum numbers of open connections and the maximum number of idle ones,
+// see NewPool. The defaults are 1, 10 and 2.
+func WithPoolLimits(minAlive, maxAlive, maxIdle int) PoolOption {
+	return func(o *poolOptions) {
+		o.minAlive = minAlive
+		o.maxAlive = maxAlive
+		o.maxIdle = maxIdle
+	}
+}
+
+// WithConnOptions sets the options applied to every new connection, as the options of Connect.
+func WithConnOptions(options ...func(conn *Conn)) PoolOption {
+	return func(o *poolOptions) {
+		o.connOptions = append(o.connOptions, options...)
+	}
+}
+
+// WithIdleTimeout sets the time after which an idle connection over minAlive can be closed, DefaultIdleTimeout by default.
+func WithIdleTimeout(d time.Duration) PoolOption {
+	return func(o *poolOptions) {
+		o.idleTimeout = d
+	}
+}
+
+// WithHealthCheck sets the health checks of the idle connections: every interval, the connections idle for more
+// than idleTime are pinged and closed if the ping fails in pingTimeout. A connection idle for more than idleTime is
+// also pinged by GetConn before being returned. The defaults are 5s, MaxIdleTimeoutWithoutPing and 100ms. An
+// interval of 0 or less disables the periodic checks, GetConn still pings the connections idle for too long.
+func WithHealthCheck(interval, idleTime, pingTimeout time.Duration) PoolOption {
+	return func(o *poolOptions) {
+		o.healthCheckInterval = interval
+		o.idlePingTimeout = idleTime
+		o.pingTimeout = pingTimeout
+	}
+}
+
+// WithNewPoolPingTimeout makes NewPoolWithOptions check the server can be reached by opening and pinging
+// a connection within timeout, the pool is not created if it fails.
+func WithNewPoolPingTimeout(timeout time.Duration) PoolOption {
+	return func(o *poolOptions) {
+		o.newPoolPingTimeout = timeout
+	}
+}
+
+func defaultPoolOptions(addr, user, password, dbName string) poolOptions {
+	return poolOptions{
+		logFunc: log.Debugf,
+
+		minAlive: 1,
+		maxAlive: 10,
+		maxIdle:  2,
+
+		addr:     addr,
+		user:     user,
+		password: password,
+		dbName:   dbName,
+
+		idleTimeout:         DefaultIdleTimeout,
+		idlePingTimeout:     MaxIdleTimeoutWithoutPing,
+		healthCheckInterval: defaultHealthCheckInterval,
+		pingTimeout:         defaultPingTimeout,
+	}
+}
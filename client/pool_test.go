@@ -3,8 +3,10 @@ package client
 import (
 	"context"
 	"fmt"
+	"net"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/atoonk/go-mysql/test_util"
 	"github.com/siddontang/go-log/log"
@@ -40,3 +42,63 @@ func (s *poolTestSuite) TestPool_Close() {
 	_, err = pool.GetConn(context.Background())
 	require.Error(s.T(), err)
 }
+
+func (s *poolTestSuite) TestPool_WithOptions() {
+	addr := fmt.Sprintf("%s:%s", *test_util.MysqlHost, s.port)
+	pool, err := NewPoolWithOptions(addr, *testUser, *testPassword, "",
+		WithLogFunc(log.Debugf),
+		WithPoolLimits(2, 5, 3),
+		WithIdleTimeout(time.Second),
+		WithHealthCheck(100*time.Millisecond, time.Second, time.Second),
+		WithNewPoolPingTimeout(time.Second),
+	)
+	require.NoError(s.T(), err)
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	conns := make([]*Conn, 0, 5)
+	for i := 0; i < 5; i++ {
+		conn, err := pool.GetConn(ctx)
+		require.NoError(s.T(), err)
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		pool.PutConn(conn)
+	}
+
+	// the idle connections over minAlive are closed by the health checks
+	require.Eventually(s.T(), func() bool {
+		var stats ConnectionStats
+		pool.GetStats(&stats)
+		return stats.TotalCount <= 3
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+func TestNewPoolWithOptionsPingTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	_, err = NewPoolWithOptions(l.Addr().String(), "root", "", "", WithNewPoolPingTimeout(time.Second))
+	require.Error(t, err)
+}
+
+func TestNewPoolWithOptionsNoHealthCheck(t *testing.T) {
+	// the periodic health checks are disabled instead of ticking every 0s
+	pool, err := NewPoolWithOptions("127.0.0.1:1", "root", "", "",
+		WithPoolLimits(0, 1, 1),
+		WithHealthCheck(0, time.Second, time.Second),
+	)
+	require.NoError(t, err)
+	pool.Close()
+}
@@ -0,0 +1,255 @@
+package client
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// ErrTransactionLost is returned by the first command run after a reconnection if the connection was lost in
+// the middle of a transaction: the transaction was rolled back by the server and must be run again.
+var ErrTransactionLost = errors.New("the connection was lost during a transaction, it was rolled back")
+
+// ReconnectPolicy makes a connection dial the server again once it is lost, see SetReconnectPolicy.
+type ReconnectPolicy struct {
+	// MaxAttempts is the number of dials tried by a reconnection, 1 if not set
+	MaxAttempts int
+	// InitialBackoff is the delay before the second dial, doubled after every failed dial up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// RetryIdempotent runs the statements failing because of the lost connection again once reconnected, if they
+	// only read (SELECT, SHOW, DESCRIBE and EXPLAIN) and the connection was not in a transaction
+	RetryIdempotent bool
+}
+
+// sessionVariable is a variable set with SetSessionVariable, set again on the new connection when reconnecting.
+type sessionVariable struct {
+	name  string
+	value string
+}
+
+var sessionVariableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SetReconnectPolicy enables the automatic reconnection: once the connection is lost (ErrBadConn or
+// ER_SERVER_SHUTDOWN), the next command dials the server again and restores the session state kept by the client,
+// i.e. the database, the charset, autocommit and the variables set with SetSessionVariable. The prepared statements
+// and the other session state are lost. The failed statement is retried right away with RetryIdempotent.
+// pass to options when connect
+func (c *Conn) SetReconnectPolicy(p *ReconnectPolicy) {
+	c.reconnectPolicy = p
+}
+
+// SetSessionVariable sets a session variable with SET SESSION, it is set again when reconnecting. The value
+// must be a string, a number, a bool or nil.
+func (c *Conn) SetSessionVariable(name string, value interface{}) error {
+	if !sessionVariableNameRegexp.MatchString(name) {
+		return errors.Errorf("invalid session variable name %q", name)
+	}
+
+	var literal string
+	switch v := value.(type) {
+	case nil:
+		literal = "NULL"
+	case string:
+		literal = "'" + Escape(v) + "'"
+	case []byte:
+		literal = "'" + Escape(string(v)) + "'"
+	case bool:
+		literal = "0"
+		if v {
+			literal = "1"
+		}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		literal = fmt.Sprint(v)
+	default:
+		return errors.Errorf("invalid type %T of the value of session variable %s", value, name)
+	}
+
+	if _, err := c.Execute(fmt.Sprintf("SET SESSION %s = %s", name, literal)); err != nil {
+		return errors.Trace(err)
+	}
+
+	for i := range c.sessionVariables {
+		if c.sessionVariables[i].name == name {
+			c.sessionVariables[i].value = literal
+			return nil
+		}
+	}
+	c.sessionVariables = append(c.sessionVariables, sessionVariable{name: name, value: literal})
+	return nil
+}
+
+// SetConnectVariables sets session variables once connected, e.g. sql_mode or time_zone, so that all the
+// connections of an application share the same session settings. They are set with SetSessionVariable, thus again
+// when reconnecting, and after ResetConnection and ChangeUser.
+// pass to options when connect
+func (c *Conn) SetConnectVariables(vars map[string]interface{}) {
+	c.connectVariables = vars
+}
+
+func (c *Conn) applyConnectVariables() error {
+	names := make([]string, 0, len(c.connectVariables))
+	for name := range c.connectVariables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := c.SetSessionVariable(name, c.connectVariables[name]); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// Reconnect closes the connection and dials the server again as described by SetReconnectPolicy, with a single
+// attempt if there is no policy.
+func (c *Conn) Reconnect() error {
+	attempts, backoff, maxBackoff := 1, time.Duration(0), time.Duration(0)
+	if p := c.reconnectPolicy; p != nil {
+		if p.MaxAttempts > 1 {
+			attempts = p.MaxAttempts
+		}
+		backoff, maxBackoff = p.InitialBackoff, p.MaxBackoff
+	}
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 && backoff > 0 {
+			time.Sleep(backoff)
+			if backoff *= 2; maxBackoff > 0 && backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+		if err = c.reconnect(); err == nil {
+			return nil
+		}
+		c.logger.Warnf("reconnect to %s (attempt %d/%d): %v", c.addr, i+1, attempts, err)
+	}
+	return errors.Trace(err)
+}
+
+// reconnect replaces the connection by a new one with the same settings and session state.
+func (c *Conn) reconnect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	nc, err := ConnectWithDialer(ctx, c.proto, c.addr, c.user, c.password, c.db, c.dialer, func(nc *Conn) {
+		nc.tlsConfig = c.tlsConfig
+		nc.sslMode = c.sslMode
+		nc.serverPubKey = c.serverPubKey
+		nc.proxyHeader = c.proxyHeader
+		nc.cancelMode = c.cancelMode
+		nc.ccaps = c.ccaps
+		nc.compressionLevel = c.compressionLevel
+		nc.compressionThreshold = c.compressionThreshold
+		nc.attributes = c.attributes
+		nc.infileProvider = c.infileProvider
+		nc.infileAllowlist = c.infileAllowlist
+		nc.reconnectPolicy = c.reconnectPolicy
+		nc.sessionVariables = c.sessionVariables
+		if c.stmtCache != nil {
+			nc.stmtCache = newStmtCache(c.stmtCache.size)
+		}
+		nc.timeLocation = c.timeLocation
+		nc.interceptors = c.interceptors
+		nc.readTimeout = c.readTimeout
+		nc.writeTimeout = c.writeTimeout
+		nc.maxAllowedPacket = c.maxAllowedPacket
+		nc.logger = c.logger
+	})
+	if err != nil {
+		return err
+	}
+	// the variables are set again with the others
+	nc.connectVariables = c.connectVariables
+
+	statements := make([]string, 0, len(c.sessionVariables)+2)
+	if c.charset != nc.charset {
+		statements = append(statements, fmt.Sprintf("SET NAMES %s", c.charset))
+	}
+	if !c.IsAutoCommit() && nc.IsAutoCommit() {
+		statements = append(statements, "SET AUTOCOMMIT = 0")
+	}
+	for _, v := range c.sessionVariables {
+		statements = append(statements, fmt.Sprintf("SET SESSION %s = %s", v.name, v.value))
+	}
+	for _, s := range statements {
+		if _, err := nc.exec(s); err != nil {
+			nc.Close()
+			return errors.Trace(err)
+		}
+	}
+	nc.charset = c.charset
+
+	if c.Conn != nil {
+		_ = c.Conn.Close()
+	}
+	*c = *nc
+	return nil
+}
+
+// withReconnect runs a command, reconnecting first if the connection was lost by the previous one, and retries it
+// once reconnected if it fails because of a lost connection and retry is true.
+func (c *Conn) withReconnect(retry bool, run func() error) error {
+	if c.reconnectPolicy == nil {
+		return run()
+	}
+
+	if c.connLost {
+		lostTransaction := c.IsInTransaction()
+		if err := c.Reconnect(); err != nil {
+			return err
+		}
+		if lostTransaction {
+			return ErrTransactionLost
+		}
+	}
+
+	inTransaction := c.IsInTransaction()
+	err := run()
+	if err == nil || !isConnLost(err) {
+		return err
+	}
+	c.connLost = true
+
+	if !retry || inTransaction || !c.reconnectPolicy.RetryIdempotent {
+		return err
+	}
+	if rerr := c.Reconnect(); rerr != nil {
+		c.logger.Errorf("retry after %v: %v", err, rerr)
+		return err
+	}
+	return run()
+}
+
+// isConnLost tells whether err is caused by a lost connection to the server.
+func isConnLost(err error) bool {
+	if errors.Cause(err) == ErrBadConn || stderrors.Is(err, ErrBadConn) {
+		return true
+	}
+	var m *MyError
+	return stderrors.As(err, &m) && m.Code == ER_SERVER_SHUTDOWN
+}
+
+// isIdempotent tells whether query only reads, so that it can be run again.
+func isIdempotent(query string) bool {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	for _, keyword := range []string{"SELECT", "SHOW", "DESCRIBE", "DESC", "EXPLAIN"} {
+		if len(query) > len(keyword) && strings.EqualFold(query[:len(keyword)], keyword) {
+			switch query[len(keyword)] {
+			case ' ', '\t', '\r', '\n', '(':
+				return true
+			}
+		}
+	}
+	return false
+}
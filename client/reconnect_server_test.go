@@ -0,0 +1,131 @@
+package client_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// reconnectHandler drops the connection on the next query once lose is set, and records the session state
+// of the connections
+type reconnectHandler struct {
+	server.EmptyHandler
+	conn *server.Conn
+	db   string
+	log  *reconnectLog
+}
+
+type reconnectLog struct {
+	mu      sync.Mutex
+	lose    int32
+	db      map[uint32]string
+	queries map[uint32][]string
+	charset map[uint32]string
+}
+
+// UseDB is also called by the handshake, before conn is set
+func (h *reconnectHandler) UseDB(dbName string) error {
+	h.db = dbName
+	return nil
+}
+
+func (h *reconnectHandler) HandleQuery(query string) (*mysql.Result, error) {
+	if atomic.CompareAndSwapInt32(&h.log.lose, 1, 0) {
+		h.conn.Close()
+		return nil, mysql.ErrBadConn
+	}
+
+	h.log.mu.Lock()
+	id := h.conn.ConnectionID()
+	h.log.queries[id] = append(h.log.queries[id], query)
+	h.log.charset[id] = h.conn.CharsetName()
+	h.log.db[id] = h.db
+	h.log.mu.Unlock()
+
+	switch query {
+	case "SET AUTOCOMMIT = 0":
+		h.conn.UnsetStatus(mysql.SERVER_STATUS_AUTOCOMMIT)
+	case "BEGIN":
+		h.conn.SetInTransaction()
+	case "COMMIT":
+		h.conn.ClearInTransaction()
+	case "SELECT 1":
+		rs, err := mysql.BuildSimpleTextResultset([]string{"1"}, [][]interface{}{{int64(1)}})
+		if err != nil {
+			return nil, err
+		}
+		return &mysql.Result{Resultset: rs}, nil
+	}
+	return &mysql.Result{}, nil
+}
+
+func TestClientReconnect(t *testing.T) {
+	svr := server.NewServerWithConfig(server.ServerConfig{StatusFlags: mysql.SERVER_STATUS_AUTOCOMMIT})
+	log := &reconnectLog{db: map[uint32]string{}, queries: map[uint32][]string{}, charset: map[uint32]string{}}
+	addr := (&fakeServer{
+		svr:        svr,
+		newHandler: func() server.Handler { return &reconnectHandler{log: log} },
+		onConn: func(co *server.Conn, h server.Handler, err error) {
+			if err == nil {
+				h.(*reconnectHandler).conn = co
+			}
+		},
+	}).listen(t)
+
+	c, err := client.Connect(addr, "root", "123", "", func(c *client.Conn) {
+		c.SetReconnectPolicy(&client.ReconnectPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, RetryIdempotent: true})
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.NoError(t, c.UseDB("db"))
+	require.NoError(t, c.SetCharset("latin1"))
+	require.NoError(t, c.SetSessionVariable("sql_mode", "ANSI"))
+	require.NoError(t, c.SetSessionVariable("sql_mode", "TRADITIONAL"))
+	require.Error(t, c.SetSessionVariable("a; DROP TABLE t", 1))
+	_, err = c.Execute("SET AUTOCOMMIT = 0")
+	require.NoError(t, err)
+	require.False(t, c.IsAutoCommit())
+
+	// an idempotent statement is run again on a new connection with the same session state
+	firstID := c.GetConnectionID()
+	atomic.StoreInt32(&log.lose, 1)
+	r, err := c.Execute("SELECT 1")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, r.RowNumber())
+	secondID := c.GetConnectionID()
+	require.NotEqual(t, firstID, secondID)
+	require.False(t, c.IsAutoCommit())
+	require.Equal(t, "latin1", c.GetCharset())
+
+	log.mu.Lock()
+	require.Equal(t, "db", log.db[secondID])
+	require.Equal(t, "latin1", log.charset[secondID])
+	require.Equal(t, []string{"SET AUTOCOMMIT = 0", "SET SESSION sql_mode = 'TRADITIONAL'", "SELECT 1"}, log.queries[secondID])
+	log.mu.Unlock()
+
+	// the other statements fail, the next command reconnects
+	atomic.StoreInt32(&log.lose, 1)
+	_, err = c.Execute("UPDATE t SET a = 1")
+	require.ErrorIs(t, err, mysql.ErrBadConn)
+	require.NoError(t, c.Ping())
+	require.NotEqual(t, secondID, c.GetConnectionID())
+
+	// a lost transaction is reported once reconnected
+	_, err = c.Execute("BEGIN")
+	require.NoError(t, err)
+	atomic.StoreInt32(&log.lose, 1)
+	_, err = c.Execute("SELECT 1")
+	require.ErrorIs(t, err, mysql.ErrBadConn)
+	_, err = c.Execute("COMMIT")
+	require.ErrorIs(t, err, client.ErrTransactionLost)
+	_, err = c.Execute("SELECT 1")
+	require.NoError(t, err)
+}
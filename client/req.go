@@ -1,10 +1,30 @@
 package client
 
 import (
+	"errors"
+
+	. "github.com/atoonk/go-mysql/mysql"
 	"github.com/atoonk/go-mysql/utils"
 )
 
+// discardPendingResults reads the results of the previous command which were not read with NextResult, they would
+// be taken for the response of the next command.
+func (c *Conn) discardPendingResults() error {
+	for c.HasMoreResults() {
+		if _, err := c.NextResult(); err != nil {
+			var m *MyError
+			if !errors.As(err, &m) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (c *Conn) writeCommand(command byte) error {
+	if err := c.discardPendingResults(); err != nil {
+		return err
+	}
 	c.ResetSequence()
 
 	return c.WritePacket([]byte{
@@ -17,6 +37,9 @@ func (c *Conn) writeCommand(command byte) error {
 }
 
 func (c *Conn) writeCommandBuf(command byte, arg []byte) error {
+	if err := c.discardPendingResults(); err != nil {
+		return err
+	}
 	c.ResetSequence()
 
 	length := len(arg) + 1
@@ -37,6 +60,9 @@ func (c *Conn) writeCommandStr(command byte, arg string) error {
 }
 
 func (c *Conn) writeCommandUint32(command byte, arg uint32) error {
+	if err := c.discardPendingResults(); err != nil {
+		return err
+	}
 	c.ResetSequence()
 
 	return c.WritePacket([]byte{
@@ -55,6 +81,9 @@ func (c *Conn) writeCommandUint32(command byte, arg uint32) error {
 }
 
 func (c *Conn) writeCommandStrStr(command byte, arg1 string, arg2 string) error {
+	if err := c.discardPendingResults(); err != nil {
+		return err
+	}
 	c.ResetSequence()
 
 	data := make([]byte, 4, 6+len(arg1)+len(arg2))
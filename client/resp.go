@@ -54,16 +54,38 @@ func (c *Conn) handleOKPacket(data []byte) (*Result, error) {
 
 		//todo:strict_mode, check warnings as error
 		r.Warnings = binary.LittleEndian.Uint16(data[pos:])
-		// pos += 2
+		pos += 2
 	} else if c.capability&CLIENT_TRANSACTIONS > 0 {
 		r.Status = binary.LittleEndian.Uint16(data[pos:])
 		c.status = r.Status
-		// pos += 2
+		pos += 2
 	}
 
-	// new ok package will check CLIENT_SESSION_TRACK too, but I don't support it now.
+	// the info is followed by the session state info with CLIENT_SESSION_TRACK, requested if the server has it
+	if c.capability&CLIENT_SESSION_TRACK > 0 && pos < len(data) {
+		info, _, n, err := LengthEncodedString(data[pos:])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		r.Info = string(info)
+		pos += n
+
+		if r.Status&SERVER_SESSION_STATE_CHANGED > 0 {
+			state, _, _, err := LengthEncodedString(data[pos:])
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if r.SessionStateChanges, err = ParseSessionStateChanges(state); err != nil {
+				return nil, errors.Trace(err)
+			}
+			if schema, ok := r.SessionSchema(); ok {
+				c.db = schema
+			}
+		}
+	} else if pos < len(data) {
+		r.Info = string(data[pos:])
+	}
 
-	// skip info
 	return r, nil
 }
 
@@ -84,6 +106,9 @@ func (c *Conn) handleErrorPacket(data []byte) error {
 
 	e.Message = hack.String(data[pos:])
 
+	// an error ends the results of a command
+	c.status &^= SERVER_MORE_RESULTS_EXISTS
+
 	return e
 }
 
@@ -94,11 +119,13 @@ func (c *Conn) handleAuthResult() error {
 	}
 	// handle auth switch, only support 'sha256_password', and 'caching_sha2_password'
 	if switchToPlugin != "" {
-		// fmt.Printf("now switching auth plugin to '%s'\n", switchToPlugin)
+		c.logger.Debugf("now switching auth plugin to '%s'", switchToPlugin)
 		if data == nil {
 			data = c.salt
 		} else {
-			copy(c.salt, data)
+			// the new scramble is NUL terminated
+			data = bytes.TrimRight(data, "\x00")
+			c.salt = append(c.salt[:0], data...)
 		}
 		c.authPluginName = switchToPlugin
 		auth, addNull, err := c.genAuthResponse(data)
@@ -132,12 +159,18 @@ func (c *Conn) handleAuthResult() error {
 			return err
 		} else if data[0] == CACHE_SHA2_FULL_AUTH {
 			// need full authentication
-			if c.tlsConfig != nil || c.proto == "unix" {
+			if c.secureTransport() {
 				if err = c.WriteClearAuthPacket(c.password); err != nil {
 					return err
 				}
 			} else {
-				if err = c.WritePublicKeyAuthPacket(c.password, c.salt); err != nil {
+				pub := c.serverPubKey
+				if pub == nil {
+					if pub, err = c.requestPublicKey(); err != nil {
+						return err
+					}
+				}
+				if err = c.WriteEncryptedPassword(c.password, c.salt, pub); err != nil {
 					return err
 				}
 			}
@@ -150,13 +183,12 @@ func (c *Conn) handleAuthResult() error {
 		if len(data) == 0 {
 			return nil // auth already succeeded
 		}
-		block, _ := pem.Decode(data)
-		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		pub, err := parsePublicKey(data)
 		if err != nil {
 			return err
 		}
 		// send encrypted password
-		err = c.WriteEncryptedPassword(c.password, c.salt, pub.(*rsa.PublicKey))
+		err = c.WriteEncryptedPassword(c.password, c.salt, pub)
 		if err != nil {
 			return err
 		}
@@ -166,6 +198,41 @@ func (c *Conn) handleAuthResult() error {
 	return nil
 }
 
+// requestPublicKey asks the server for the RSA public key encrypting the password of 'caching_sha2_password'.
+func (c *Conn) requestPublicKey() (*rsa.PublicKey, error) {
+	if err := c.WriteAuthSwitchPacket([]byte{CACHE_SHA2_REQUEST_PUBLIC_KEY}, false); err != nil {
+		return nil, err
+	}
+	data, err := c.ReadPacket()
+	if err != nil {
+		return nil, err
+	}
+	switch data[0] {
+	case MORE_DATE_HEADER:
+		return parsePublicKey(data[1:])
+	case ERR_HEADER:
+		return nil, c.handleErrorPacket(data)
+	default:
+		return nil, errors.Errorf("invalid public key packet %x", data[0])
+	}
+}
+
+func parsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid public key of the server: no PEM data")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("invalid public key of the server: not an RSA key")
+	}
+	return rsaPub, nil
+}
+
 func (c *Conn) readAuthResult() ([]byte, string, error) {
 	data, err := c.ReadPacket()
 	if err != nil {
@@ -217,6 +284,8 @@ func (c *Conn) readOK() (*Result, error) {
 }
 
 func (c *Conn) readResult(binary bool) (*Result, error) {
+	c.binaryResult = binary
+
 	bs := utils.ByteSliceGet(16)
 	defer utils.ByteSlicePut(bs)
 	var err error
@@ -231,7 +300,7 @@ func (c *Conn) readResult(binary bool) (*Result, error) {
 	case ERR_HEADER:
 		return nil, c.handleErrorPacket(bytes.Repeat(bs.B, 1))
 	case LocalInFile_HEADER:
-		return nil, ErrMalformPacket
+		return c.handleLocalInfile(bs.B)
 	default:
 		return c.readResultset(bs.B, binary)
 	}
@@ -247,12 +316,18 @@ func (c *Conn) readResultStreaming(binary bool, result *Result, perRowCb SelectP
 	}
 
 	switch bs.B[0] {
-	case OK_HEADER:
+	case OK_HEADER, LocalInFile_HEADER:
 		// https://dev.mysql.com/doc/internals/en/com-query-response.html
 		// 14.6.4.1 COM_QUERY Response
 		// If the number of columns in the resultset is 0, this is a OK_Packet.
+		// A LOCAL INFILE request is answered with the file, then with an OK_Packet.
 
-		okResult, err := c.handleOKPacket(bs.B)
+		var okResult *Result
+		if bs.B[0] == OK_HEADER {
+			okResult, err = c.handleOKPacket(bs.B)
+		} else {
+			okResult, err = c.handleLocalInfile(bs.B)
+		}
 		if err != nil {
 			return errors.Trace(err)
 		}
@@ -261,6 +336,8 @@ func (c *Conn) readResultStreaming(binary bool, result *Result, perRowCb SelectP
 		result.AffectedRows = okResult.AffectedRows
 		result.InsertId = okResult.InsertId
 		result.Warnings = okResult.Warnings
+		result.Info = okResult.Info
+		result.SessionStateChanges = okResult.SessionStateChanges
 		if result.Resultset == nil {
 			result.Resultset = NewResultset(0)
 		} else {
@@ -269,8 +346,6 @@ func (c *Conn) readResultStreaming(binary bool, result *Result, perRowCb SelectP
 		return nil
 	case ERR_HEADER:
 		return c.handleErrorPacket(bytes.Repeat(bs.B, 1))
-	case LocalInFile_HEADER:
-		return ErrMalformPacket
 	default:
 		return c.readResultsetStreaming(bs.B, binary, result, perRowCb, perResCb)
 	}
@@ -287,6 +362,7 @@ func (c *Conn) readResultset(data []byte, binary bool) (*Result, error) {
 	result := &Result{
 		Resultset: NewResultset(int(count)),
 	}
+	result.TimeLocation = c.timeLocation
 
 	if err := c.readResultColumns(result); err != nil {
 		return nil, errors.Trace(err)
@@ -315,6 +391,7 @@ func (c *Conn) readResultsetStreaming(data []byte, binary bool, result *Result,
 
 	// this is a streaming resultset
 	result.Resultset.Streaming = StreamingSelect
+	result.Resultset.TimeLocation = c.timeLocation
 
 	if err := c.readResultColumns(result); err != nil {
 		return errors.Trace(err)
@@ -462,9 +539,38 @@ func (c *Conn) readResultRowsStreaming(result *Result, isBinary bool, perRowCb S
 		// Send the row to "userland" code
 		err = perRowCb(row)
 		if err != nil {
+			// read the remaining rows so that the connection can still be used
+			if discardErr := c.discardResultRows(result, data); discardErr != nil {
+				return errors.Trace(discardErr)
+			}
 			return errors.Trace(err)
 		}
 	}
 
 	return nil
 }
+
+// discardResultRows reads the rows of a resultset up to its end, data is a buffer to reuse.
+func (c *Conn) discardResultRows(result *Result, data []byte) (err error) {
+	for {
+		data, err = c.ReadPacketReuseMem(data[:0])
+		if err != nil {
+			return err
+		}
+
+		if c.isEOFPacket(data) {
+			if c.capability&CLIENT_PROTOCOL_41 > 0 {
+				result.Warnings = binary.LittleEndian.Uint16(data[1:])
+				result.Status = binary.LittleEndian.Uint16(data[3:])
+				c.status = result.Status
+			}
+			return nil
+		}
+
+		if data[0] == ERR_HEADER {
+			// the resultset is ended by the error of the server, the error of the callback is reported instead
+			c.status &^= SERVER_MORE_RESULTS_EXISTS
+			return nil
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package client_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+type streamingHandler struct {
+	server.EmptyHandler
+}
+
+func (h streamingHandler) HandleQuery(query string) (*mysql.Result, error) {
+	if query != "SELECT * FROM big" {
+		return &mysql.Result{}, nil
+	}
+	fields := []*mysql.Field{{Name: []byte("id"), Type: mysql.MYSQL_TYPE_LONGLONG, Charset: 63}}
+	i := 0
+	return &mysql.Result{Streamer: mysql.NewResultStreamer(fields, func() ([]interface{}, error) {
+		if i == 1000 {
+			return nil, io.EOF
+		}
+		i++
+		return []interface{}{int64(i)}, nil
+	})}, nil
+}
+
+func TestClientExecuteSelectStreaming(t *testing.T) {
+	addr := serveFake(t, streamingHandler{})
+
+	c, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+	defer c.Close()
+
+	var result mysql.Result
+	var sum int64
+	err = c.ExecuteSelectStreaming("SELECT * FROM big", &result, func(row []mysql.FieldValue) error {
+		sum += row[0].AsInt64()
+		return nil
+	}, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 1000*1001/2, sum)
+	require.Empty(t, result.RowDatas)
+
+	// the rows following an error of the callback are discarded, the connection can still be used
+	stop := errors.New("stop")
+	rows := 0
+	err = c.ExecuteSelectStreaming("SELECT * FROM big", &result, func(row []mysql.FieldValue) error {
+		if rows++; rows == 3 {
+			return stop
+		}
+		return nil
+	}, nil)
+	require.ErrorIs(t, err, stop)
+	require.Equal(t, 3, rows)
+	_, err = c.Execute("SELECT 1")
+	require.NoError(t, err)
+}
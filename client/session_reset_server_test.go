@@ -0,0 +1,134 @@
+package client_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// sessionResetHandler records the prepared statements, with the charset of the connection, and the session resets
+type sessionResetHandler struct {
+	server.EmptyHandler
+	conn     *server.Conn
+	mu       sync.Mutex
+	prepared int
+	charset  string
+	user, db string
+	resets   int
+}
+
+func (h *sessionResetHandler) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.prepared++
+	h.charset = h.conn.CharsetName()
+	return 1, 1, nil, nil
+}
+
+func (h *sessionResetHandler) HandleStmtExecute(context interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	rs, err := mysql.BuildSimpleBinaryResultset([]string{"a"}, [][]interface{}{{args[0]}})
+	if err != nil {
+		return nil, err
+	}
+	return &mysql.Result{Resultset: rs}, nil
+}
+
+func (h *sessionResetHandler) HandleChangeUser(user string, db string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.user, h.db = user, db
+	return nil
+}
+
+func (h *sessionResetHandler) HandleResetConnection() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.resets++
+	return nil
+}
+
+func (h *sessionResetHandler) state() (prepared int, charset, user, db string, resets int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.prepared, h.charset, h.user, h.db, h.resets
+}
+
+// serveSessionReset serves the connections with h, for the users root and bob, and returns the address
+func serveSessionReset(t *testing.T, h *sessionResetHandler) string {
+	p := server.NewInMemoryProvider()
+	p.AddUser("root", "123")
+	p.AddUser("bob", "secret")
+
+	s := &fakeServer{p: p, h: h, onConn: func(co *server.Conn, _ server.Handler, err error) {
+		if err == nil {
+			h.conn = co
+		}
+	}}
+	return s.listen(t)
+}
+
+func TestClientChangeUser(t *testing.T) {
+	h := &sessionResetHandler{}
+	addr := serveSessionReset(t, h)
+
+	c, err := client.Connect(addr, "root", "123", "", func(c *client.Conn) {
+		c.SetStmtCacheSize(2)
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.NoError(t, c.SetCharset("latin1"))
+	_, err = c.Execute("SELECT ?", 1)
+	require.NoError(t, err)
+
+	require.NoError(t, c.ChangeUser("bob", "secret", "other"))
+	require.Equal(t, "bob", c.GetUser())
+	require.Equal(t, "other", c.GetDB())
+	require.Equal(t, "latin1", c.GetCharset())
+
+	// the statements closed by the server are prepared again, with the charset set again
+	_, err = c.Execute("SELECT ?", 2)
+	require.NoError(t, err)
+	prepared, charset, user, db, _ := h.state()
+	require.Equal(t, 2, prepared)
+	require.Equal(t, "latin1", charset)
+	require.Equal(t, "bob", user)
+	require.Equal(t, "other", db)
+
+	// a failed change keeps the previous user
+	require.Error(t, c.ChangeUser("root", "wrong", ""))
+	require.Equal(t, "bob", c.GetUser())
+	require.Equal(t, "other", c.GetDB())
+	require.NoError(t, c.Ping())
+}
+
+func TestClientResetConnection(t *testing.T) {
+	h := &sessionResetHandler{}
+	addr := serveSessionReset(t, h)
+
+	c, err := client.Connect(addr, "root", "123", "db", func(c *client.Conn) {
+		c.SetStmtCacheSize(2)
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.NoError(t, c.SetCharset("latin1"))
+	_, err = c.Execute("SELECT ?", 1)
+	require.NoError(t, err)
+
+	require.NoError(t, c.ResetConnection())
+	require.Equal(t, "root", c.GetUser())
+	require.Equal(t, "db", c.GetDB())
+
+	_, err = c.Execute("SELECT ?", 2)
+	require.NoError(t, err)
+	prepared, charset, _, _, resets := h.state()
+	require.Equal(t, 2, prepared)
+	require.Equal(t, "latin1", charset)
+	require.Equal(t, 1, resets)
+}
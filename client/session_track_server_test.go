@@ -0,0 +1,50 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// sessionTrackHandler reports the session state changes of a statement as MySQL does with session tracking
+type sessionTrackHandler struct {
+	server.EmptyHandler
+}
+
+func (h *sessionTrackHandler) HandleQuery(query string) (*mysql.Result, error) {
+	return &mysql.Result{
+		AffectedRows: 1,
+		SessionStateChanges: []mysql.SessionStateChange{
+			mysql.NewSchemaChange("db2"),
+			mysql.NewSystemVariableChange("autocommit", "OFF"),
+			mysql.NewStateChange(),
+			mysql.NewGTIDsChange("3e11fa47-71ca-11e1-9e33-c80aa9429562:23"),
+			{Type: mysql.SESSION_TRACK_TRANSACTION_STATE, Value: "T_______"},
+		},
+	}, nil
+}
+
+func TestClientSessionTrack(t *testing.T) {
+	addr := serveFake(t, &sessionTrackHandler{})
+
+	c, err := client.Connect(addr, "root", "123", "db1")
+	require.NoError(t, err)
+	defer c.Close()
+
+	r, err := c.Execute("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, r.AffectedRows)
+	require.Len(t, r.SessionStateChanges, 5)
+	require.Equal(t, mysql.SessionStateChange{Type: mysql.SESSION_TRACK_TRANSACTION_STATE, Value: "T_______"}, r.SessionStateChanges[4])
+
+	schema, ok := r.SessionSchema()
+	require.True(t, ok)
+	require.Equal(t, "db2", schema)
+	require.Equal(t, "db2", c.GetDB())
+	require.Equal(t, map[string]string{"autocommit": "OFF"}, r.SessionSystemVariables())
+	require.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:23", r.SessionGTIDs())
+}
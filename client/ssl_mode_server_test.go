@@ -0,0 +1,136 @@
+package client_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// newTestCert returns a certificate without host name and its key in PEM, signed by the parent certificate
+// and key, self signed as a CA if there is no parent
+func newTestCert(t *testing.T, parentPem, parentKeyPem []byte) ([]byte, []byte) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"go-mysql"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	parent, parentKey := template, interface{}(priv)
+	if parentPem == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		ca, err := tls.X509KeyPair(parentPem, parentKeyPem)
+		require.NoError(t, err)
+		parent, err = x509.ParseCertificate(ca.Certificate[0])
+		require.NoError(t, err)
+		parentKey = ca.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &priv.PublicKey, parentKey)
+	require.NoError(t, err)
+	key, err := x509.MarshalECPrivateKey(priv)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key})
+}
+
+func TestClientSSLMode(t *testing.T) {
+	caPem, caKey := newTestCert(t, nil, nil)
+	certPem, keyPem := newTestCert(t, caPem, caKey)
+	// the certificate has no host name, it can not pass VERIFY_IDENTITY
+	serverTLS := server.NewServerTLSConfig(caPem, certPem, keyPem, tls.VerifyClientCertIfGiven)
+	otherCaPem, _ := newTestCert(t, nil, nil)
+
+	dir := t.TempDir()
+	caFile, otherCaFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "other-ca.pem")
+	certFile, keyFile := filepath.Join(dir, "client-cert.pem"), filepath.Join(dir, "client-key.pem")
+	clientCertPem, clientKeyPem := newTestCert(t, caPem, caKey)
+	for name, data := range map[string][]byte{caFile: caPem, otherCaFile: otherCaPem, certFile: clientCertPem, keyFile: clientKeyPem} {
+		require.NoError(t, os.WriteFile(name, data, 0o600))
+	}
+
+	clientCerts := make(chan int, 10)
+	tlsServer := server.NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, serverTLS)
+	tlsServer.SetTLSVerifyHook(func(c *server.Conn, state *tls.ConnectionState) error {
+		if state == nil {
+			clientCerts <- -1
+		} else {
+			clientCerts <- len(state.PeerCertificates)
+		}
+		return nil
+	})
+	tlsAddr := (&fakeServer{svr: tlsServer}).listen(t)
+	plainAddr := serveFake(t, nil)
+
+	withCA, err := client.LoadClientTLSConfig(caFile, certFile, keyFile)
+	require.NoError(t, err)
+	withOtherCA, err := client.LoadClientTLSConfig(otherCaFile, "", "")
+	require.NoError(t, err)
+	_, err = client.LoadClientTLSConfig(filepath.Join(dir, "missing.pem"), "", "")
+	require.Error(t, err)
+
+	tests := []struct {
+		addr   string
+		mode   client.SSLMode
+		config *tls.Config
+		// the number of certificates sent by the client, -1 without TLS
+		clientCerts int
+		err         bool
+	}{
+		{tlsAddr, client.SSLDisabled, withCA, -1, false},
+		{tlsAddr, client.SSLPreferred, nil, 0, false},
+		{plainAddr, client.SSLPreferred, nil, 0, false},
+		{tlsAddr, client.SSLRequired, nil, 0, false},
+		{plainAddr, client.SSLRequired, nil, 0, true},
+		// REQUIRED verifies the CA if there is one
+		{tlsAddr, client.SSLRequired, withOtherCA, 0, true},
+		{tlsAddr, client.SSLVerifyCA, withCA, 1, false},
+		{tlsAddr, client.SSLVerifyCA, withOtherCA, 0, true},
+		{tlsAddr, client.SSLVerifyIdentity, withCA, 0, true},
+	}
+	for _, tt := range tests {
+		c, err := client.Connect(tt.addr, "root", "123", "", func(c *client.Conn) {
+			c.SetTLSConfig(tt.config)
+			c.SetSSLMode(tt.mode)
+		})
+		if tt.err {
+			require.Error(t, err, "%s %s", tt.addr, tt.mode)
+			continue
+		}
+		require.NoError(t, err, "%s %s", tt.addr, tt.mode)
+		require.NoError(t, c.Ping())
+		c.Close()
+		if tt.addr == tlsAddr {
+			require.Equal(t, tt.clientCerts, <-clientCerts, "%s", tt.mode)
+		}
+	}
+
+	mode, err := client.ParseSSLMode("verify_identity")
+	require.NoError(t, err)
+	require.Equal(t, client.SSLVerifyIdentity, mode)
+	require.Equal(t, "VERIFY_IDENTITY", mode.String())
+	_, err = client.ParseSSLMode("sometimes")
+	require.Error(t, err)
+}
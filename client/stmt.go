@@ -1,18 +1,25 @@
 package client
 
 import (
+	"context"
 	"encoding/binary"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"math"
+	"time"
 
 	. "github.com/atoonk/go-mysql/mysql"
 	"github.com/pingcap/errors"
+	"github.com/shopspring/decimal"
 )
 
 type Stmt struct {
-	conn *Conn
-	id   uint32
+	conn  *Conn
+	id    uint32
+	query string
+	// the statement is kept prepared by the statement cache of the connection, Close does nothing
+	cached bool
 
 	params   int
 	columns  int
@@ -31,7 +38,25 @@ func (s *Stmt) WarningsNum() int {
 	return s.warnings
 }
 
+// Execute executes the statement. The statement is prepared again if the server does not know it anymore
+// (ER_UNKNOWN_STMT_HANDLER), e.g. after a failover or a reconnection.
 func (s *Stmt) Execute(args ...interface{}) (*Result, error) {
+	return s.conn.intercept(context.Background(), COM_STMT_EXECUTE, s.query, args, func() (*Result, error) {
+		return s.executeOrReprepare(args...)
+	})
+}
+
+func (s *Stmt) executeOrReprepare(args ...interface{}) (*Result, error) {
+	r, err := s.execute(args...)
+	if isUnknownStmtError(err) {
+		if err = s.reprepare(); err == nil {
+			r, err = s.execute(args...)
+		}
+	}
+	return r, err
+}
+
+func (s *Stmt) execute(args ...interface{}) (*Result, error) {
 	if err := s.write(args...); err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -39,7 +64,22 @@ func (s *Stmt) Execute(args ...interface{}) (*Result, error) {
 	return s.conn.readResult(true)
 }
 
+// ExecuteSelectStreaming is Execute calling perRowCb for every row instead of storing them, see
+// Conn.ExecuteSelectStreaming.
 func (s *Stmt) ExecuteSelectStreaming(result *Result, perRowCb SelectPerRowCallback, perResCb SelectPerResultCallback, args ...interface{}) error {
+	_, err := s.conn.intercept(context.Background(), COM_STMT_EXECUTE, s.query, args, func() (*Result, error) {
+		err := s.executeSelectStreaming(result, perRowCb, perResCb, args...)
+		if isUnknownStmtError(err) {
+			if err = s.reprepare(); err == nil {
+				err = s.executeSelectStreaming(result, perRowCb, perResCb, args...)
+			}
+		}
+		return result, err
+	})
+	return err
+}
+
+func (s *Stmt) executeSelectStreaming(result *Result, perRowCb SelectPerRowCallback, perResCb SelectPerResultCallback, args ...interface{}) error {
 	if err := s.write(args...); err != nil {
 		return errors.Trace(err)
 	}
@@ -47,7 +87,26 @@ func (s *Stmt) ExecuteSelectStreaming(result *Result, perRowCb SelectPerRowCallb
 	return s.conn.readResultStreaming(true, result, perRowCb, perResCb)
 }
 
+// reprepare prepares the query of the statement again, the statement gets the new id.
+func (s *Stmt) reprepare() error {
+	ns, err := s.conn.prepare(s.query)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	s.id, s.params, s.columns, s.warnings = ns.id, ns.params, ns.columns, ns.warnings
+	return nil
+}
+
+func isUnknownStmtError(err error) bool {
+	var m *MyError
+	return stderrors.As(err, &m) && m.Code == ER_UNKNOWN_STMT_HANDLER
+}
+
+// Close closes the statement on the server, unless it is kept by the statement cache of the connection.
 func (s *Stmt) Close() error {
+	if s.cached {
+		return nil
+	}
 	if err := s.conn.writeCommandUint32(COM_STMT_CLOSE, s.id); err != nil {
 		return errors.Trace(err)
 	}
@@ -81,7 +140,13 @@ func (s *Stmt) write(args ...interface{}) error {
 
 		newParamBoundFlag = 1
 
-		switch v := args[i].(type) {
+		arg := args[i]
+		if d, ok := arg.(decimal.Decimal); ok {
+			// sent as a DECIMAL with its scale
+			arg = FromDecimal(d)
+		}
+
+		switch v := arg.(type) {
 		case int8:
 			paramTypes[i<<1] = MYSQL_TYPE_TINY
 			paramValues[i] = []byte{byte(v)}
@@ -139,6 +204,24 @@ func (s *Stmt) write(args ...interface{}) error {
 		case json.RawMessage:
 			paramTypes[i<<1] = MYSQL_TYPE_STRING
 			paramValues[i] = append(PutLengthEncodedInt(uint64(len(v))), v...)
+		case Geometry:
+			b := v.Bytes()
+			paramTypes[i<<1] = MYSQL_TYPE_STRING
+			paramValues[i] = append(PutLengthEncodedInt(uint64(len(b))), b...)
+		case Decimal:
+			d := v.String()
+			paramTypes[i<<1] = MYSQL_TYPE_NEWDECIMAL
+			paramValues[i] = append(PutLengthEncodedInt(uint64(len(d))), d...)
+		case time.Time:
+			loc := s.conn.timeLocation
+			if loc == nil {
+				loc = time.UTC
+			}
+			if !v.IsZero() {
+				v = v.In(loc)
+			}
+			paramTypes[i<<1] = MYSQL_TYPE_DATETIME
+			paramValues[i] = AppendBinaryDateTime(nil, v)
 		default:
 			return fmt.Errorf("invalid argument type %T", args[i])
 		}
@@ -146,6 +229,10 @@ func (s *Stmt) write(args ...interface{}) error {
 		length += len(paramValues[i])
 	}
 
+	if err := s.conn.discardPendingResults(); err != nil {
+		return err
+	}
+
 	data := make([]byte, 4, 4+length)
 
 	data = append(data, COM_STMT_EXECUTE)
@@ -179,7 +266,17 @@ func (s *Stmt) write(args ...interface{}) error {
 	return s.conn.WritePacket(data)
 }
 
-func (c *Conn) Prepare(query string) (*Stmt, error) {
+func (c *Conn) Prepare(query string) (s *Stmt, err error) {
+	_, err = c.intercept(context.Background(), COM_STMT_PREPARE, query, nil, func() (*Result, error) {
+		return nil, c.withReconnect(true, func() error {
+			s, err = c.prepareCached(query)
+			return err
+		})
+	})
+	return s, err
+}
+
+func (c *Conn) prepare(query string) (*Stmt, error) {
 	if err := c.writeCommandStr(COM_STMT_PREPARE, query); err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -197,6 +294,7 @@ func (c *Conn) Prepare(query string) (*Stmt, error) {
 
 	s := new(Stmt)
 	s.conn = c
+	s.query = query
 
 	pos := 1
 
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"container/list"
+)
+
+// stmtCache keeps the statements prepared by a connection, keyed by their query, evicting the least recently
+// used one once full.
+type stmtCache struct {
+	size  int
+	lru   *list.List // of *Stmt, the most recently used first
+	stmts map[string]*list.Element
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:  size,
+		lru:   list.New(),
+		stmts: make(map[string]*list.Element),
+	}
+}
+
+// get returns the statement prepared for query, nil if it is not cached.
+func (sc *stmtCache) get(query string) *Stmt {
+	e, ok := sc.stmts[query]
+	if !ok {
+		return nil
+	}
+	sc.lru.MoveToFront(e)
+	return e.Value.(*Stmt)
+}
+
+// put adds a statement and returns the one evicted to make room for it, if any.
+func (sc *stmtCache) put(s *Stmt) (evicted *Stmt) {
+	s.cached = true
+	sc.stmts[s.query] = sc.lru.PushFront(s)
+	if sc.lru.Len() <= sc.size {
+		return nil
+	}
+
+	evicted = sc.lru.Remove(sc.lru.Back()).(*Stmt)
+	delete(sc.stmts, evicted.query)
+	evicted.cached = false
+	return evicted
+}
+
+// reset forgets the statements, e.g. once they are closed by the server.
+func (sc *stmtCache) reset() {
+	for e := sc.lru.Front(); e != nil; e = e.Next() {
+		e.Value.(*Stmt).cached = false
+	}
+	sc.lru.Init()
+	sc.stmts = make(map[string]*list.Element)
+}
+
+// SetStmtCacheSize enables the cache of the prepared statements: Prepare and Execute with arguments reuse the
+// statement prepared for the same query, up to size statements are kept prepared on the server. Closing a cached
+// statement keeps it prepared, it is closed once evicted by the other statements.
+// pass to options when connect
+func (c *Conn) SetStmtCacheSize(size int) {
+	if size <= 0 {
+		c.stmtCache = nil
+		return
+	}
+	c.stmtCache = newStmtCache(size)
+}
+
+// prepareCached prepares the statement, or returns the cached one for the query.
+func (c *Conn) prepareCached(query string) (*Stmt, error) {
+	if c.stmtCache == nil {
+		return c.prepare(query)
+	}
+	if s := c.stmtCache.get(query); s != nil {
+		return s, nil
+	}
+
+	s, err := c.prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	if evicted := c.stmtCache.put(s); evicted != nil {
+		if err := evicted.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,89 @@
+package client_test
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// stmtCountHandler counts the statements prepared and closed, its statements are forgotten once when forget is set
+// as after a failover
+type stmtCountHandler struct {
+	server.EmptyHandler
+	prepared int32
+	closed   int32
+	forget   int32
+}
+
+func (h *stmtCountHandler) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	atomic.AddInt32(&h.prepared, 1)
+	return strings.Count(query, "?"), 1, nil, nil
+}
+
+func (h *stmtCountHandler) HandleStmtExecute(context interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	if atomic.CompareAndSwapInt32(&h.forget, 1, 0) {
+		return nil, mysql.NewDefaultError(mysql.ER_UNKNOWN_STMT_HANDLER, "1", "stmt_execute")
+	}
+	rs, err := mysql.BuildSimpleBinaryResultset([]string{"a"}, [][]interface{}{{args[0]}})
+	if err != nil {
+		return nil, err
+	}
+	return &mysql.Result{Resultset: rs}, nil
+}
+
+func (h *stmtCountHandler) HandleStmtClose(context interface{}) error {
+	atomic.AddInt32(&h.closed, 1)
+	return nil
+}
+
+func TestClientStmtCache(t *testing.T) {
+	h := &stmtCountHandler{}
+
+	addr := serveFake(t, h)
+
+	c, err := client.Connect(addr, "root", "123", "", func(c *client.Conn) {
+		c.SetStmtCacheSize(2)
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	execute := func(query string, arg int64) {
+		r, err := c.Execute(query, arg)
+		require.NoError(t, err)
+		v, err := r.GetInt(0, 0)
+		require.NoError(t, err)
+		require.Equal(t, arg, v)
+	}
+
+	for i := int64(0); i < 3; i++ {
+		execute("SELECT ?", i)
+	}
+	s, err := c.Prepare("SELECT ?")
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+	execute("SELECT ?", 4)
+	require.EqualValues(t, 1, atomic.LoadInt32(&h.prepared))
+	require.EqualValues(t, 0, atomic.LoadInt32(&h.closed))
+
+	// the least recently used statement is evicted
+	execute("SELECT ? + 1", 5)
+	execute("SELECT ? + 2", 6)
+	require.EqualValues(t, 3, atomic.LoadInt32(&h.prepared))
+	require.EqualValues(t, 1, atomic.LoadInt32(&h.closed))
+	execute("SELECT ? + 2", 7)
+	execute("SELECT ?", 8)
+	require.EqualValues(t, 4, atomic.LoadInt32(&h.prepared))
+
+	// a statement unknown to the server is prepared again
+	atomic.StoreInt32(&h.forget, 1)
+	execute("SELECT ?", 9)
+	require.EqualValues(t, 5, atomic.LoadInt32(&h.prepared))
+	execute("SELECT ?", 10)
+	require.EqualValues(t, 5, atomic.LoadInt32(&h.prepared))
+}
@@ -0,0 +1,90 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// timeHandler returns a DATETIME column, and the time.Time arguments of the statements as strings
+type timeHandler struct {
+	server.EmptyHandler
+}
+
+func (h *timeHandler) HandleQuery(query string) (*mysql.Result, error) {
+	rs, err := mysql.BuildSimpleTextResultset([]string{"d", "n"}, [][]interface{}{{"2023-04-05 06:07:08.000123", nil}})
+	if err != nil {
+		return nil, err
+	}
+	rs.Fields[0].Type = mysql.MYSQL_TYPE_DATETIME
+	rs.Fields[1].Type = mysql.MYSQL_TYPE_DATETIME
+	return &mysql.Result{Resultset: rs}, nil
+}
+
+func (h *timeHandler) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	return 1, 1, nil, nil
+}
+
+func (h *timeHandler) HandleStmtExecute(context interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	rs, err := mysql.BuildSimpleBinaryResultset([]string{"a"}, [][]interface{}{{args[0].(time.Time).Format("2006-01-02 15:04:05.999999")}})
+	if err != nil {
+		return nil, err
+	}
+	return &mysql.Result{Resultset: rs}, nil
+}
+
+func TestClientTimeLocation(t *testing.T) {
+	addr := serveFake(t, &timeHandler{})
+
+	// the values are strings by default
+	c, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+	defer c.Close()
+
+	r, err := c.Execute("SELECT d, n FROM t")
+	require.NoError(t, err)
+	v, err := r.GetValue(0, 0)
+	require.NoError(t, err)
+	require.Equal(t, []byte("2023-04-05 06:07:08.000123"), v)
+	d, err := r.GetTime(0, 0)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2023, 4, 5, 6, 7, 8, 123000, time.UTC), d)
+
+	// time.Time arguments are sent in UTC
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	r, err = c.Execute("SELECT ?", time.Date(2023, 4, 5, 6, 7, 8, 123456789, loc))
+	require.NoError(t, err)
+	s, err := r.GetString(0, 0)
+	require.NoError(t, err)
+	require.Equal(t, "2023-04-05 04:07:08.123456", s)
+
+	// with a location, the values are time.Time in the location
+	c2, err := client.Connect(addr, "root", "123", "", func(c *client.Conn) {
+		c.SetTimeLocation(loc)
+	})
+	require.NoError(t, err)
+	defer c2.Close()
+
+	r, err = c2.Execute("SELECT d, n FROM t")
+	require.NoError(t, err)
+	v, err = r.GetValue(0, 0)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2023, 4, 5, 6, 7, 8, 123000, loc), v)
+	s, err = r.GetStringByName(0, "d")
+	require.NoError(t, err)
+	require.Equal(t, "2023-04-05 06:07:08.000123", s)
+	d, err = r.GetTimeByName(0, "n")
+	require.NoError(t, err)
+	require.True(t, d.IsZero())
+
+	r, err = c2.Execute("SELECT ?", time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC))
+	require.NoError(t, err)
+	s, err = r.GetString(0, 0)
+	require.NoError(t, err)
+	require.Equal(t, "2023-04-05 08:07:08", s)
+}
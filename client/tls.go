@@ -3,6 +3,11 @@ package client
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pingcap/errors"
 )
 
 // NewClientTLSConfig: generate TLS config for client side
@@ -38,3 +43,136 @@ func NewClientTLSConfig(caPem, certPem, keyPem []byte, insecureSkipVerify bool,
 
 	return config
 }
+
+// SSLMode is the security level of the connection, as the --ssl-mode option of the mysql client.
+type SSLMode int
+
+const (
+	// SSLModeUnset uses TLS if a TLS config is set with SetTLSConfig or UseSSL, it is required then.
+	SSLModeUnset SSLMode = iota
+	// SSLDisabled never uses TLS.
+	SSLDisabled
+	// SSLPreferred uses TLS if the server supports it, the certificate of the server is not verified.
+	SSLPreferred
+	// SSLRequired requires TLS, the certificate of the server is only verified if the TLS config has RootCAs,
+	// as with SSLVerifyCA.
+	SSLRequired
+	// SSLVerifyCA requires TLS and a certificate of the server signed by one of the RootCAs of the TLS config,
+	// or of the system if not set. The host name of the server is not verified.
+	SSLVerifyCA
+	// SSLVerifyIdentity is SSLVerifyCA also verifying the host name of the server, the ServerName of the TLS
+	// config or the host of the address connected to.
+	SSLVerifyIdentity
+)
+
+var sslModeNames = map[SSLMode]string{
+	SSLDisabled:       "DISABLED",
+	SSLPreferred:      "PREFERRED",
+	SSLRequired:       "REQUIRED",
+	SSLVerifyCA:       "VERIFY_CA",
+	SSLVerifyIdentity: "VERIFY_IDENTITY",
+}
+
+func (m SSLMode) String() string {
+	if name, ok := sslModeNames[m]; ok {
+		return name
+	}
+	return ""
+}
+
+// ParseSSLMode parses the value of the --ssl-mode option of the mysql client, e.g. "VERIFY_CA", case insensitively.
+func ParseSSLMode(s string) (SSLMode, error) {
+	for m, name := range sslModeNames {
+		if strings.EqualFold(s, name) {
+			return m, nil
+		}
+	}
+	return SSLModeUnset, errors.Errorf("invalid ssl mode %q", s)
+}
+
+// SetSSLMode sets the security level of the connection, with the TLS config set by SetTLSConfig if any.
+// pass to options when connect
+func (c *Conn) SetSSLMode(m SSLMode) {
+	c.sslMode = m
+}
+
+// LoadClientTLSConfig creates a TLS config from PEM files as the --ssl-ca, --ssl-cert and --ssl-key options of
+// the mysql client: the certificate of the server must be signed by the CA of caFile and the client authenticates
+// with the certificate of certFile. Every file is optional.
+func LoadClientTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{}
+	if caFile != "" {
+		caPem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caPem) {
+			return nil, errors.Errorf("no certificate found in %s", caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// applySSLMode sets the TLS config used by the handshake according to the SSL mode, nil if TLS is disabled.
+func (c *Conn) applySSLMode() {
+	mode := c.sslMode
+	if mode == SSLModeUnset {
+		return
+	}
+	if mode == SSLDisabled {
+		c.tlsConfig = nil
+		return
+	}
+
+	var config *tls.Config
+	if c.tlsConfig != nil {
+		config = c.tlsConfig.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+	if mode == SSLRequired && config.RootCAs != nil {
+		mode = SSLVerifyCA
+	}
+
+	switch mode {
+	case SSLPreferred, SSLRequired:
+		config.InsecureSkipVerify = true
+	case SSLVerifyCA:
+		// the chain is verified without the host name
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = verifyCA(config.RootCAs)
+	case SSLVerifyIdentity:
+		config.InsecureSkipVerify = false
+		if config.ServerName == "" {
+			if host, _, err := net.SplitHostPort(c.addr); err == nil {
+				config.ServerName = host
+			}
+		}
+	}
+	c.tlsConfig = config
+}
+
+func verifyCA(roots *x509.CertPool) func(cs tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return errors.New("the MySQL server sent no certificate")
+		}
+		opts := x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, cert := range cs.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		_, err := cs.PeerCertificates[0].Verify(opts)
+		return err
+	}
+}
@@ -3,13 +3,16 @@ package main
 import (
 	"flag"
 	"os"
+	"path/filepath"
 
+	"github.com/atoonk/go-mysql/mysql"
 	"github.com/atoonk/go-mysql/replication"
 )
 
 var (
 	name   = flag.String("name", "", "binlog file name")
 	offset = flag.Int64("offset", 0, "parse start offset")
+	all    = flag.Bool("all", false, "parse the next binlog files of the directory too")
 )
 
 func main() {
@@ -22,7 +25,13 @@ func main() {
 		return nil
 	}
 
-	err := p.ParseFile(*name, *offset, f)
+	var err error
+	if *all {
+		pos := mysql.Position{Name: filepath.Base(*name), Pos: uint32(*offset)}
+		err = p.ParseDir(filepath.Dir(*name), pos, f)
+	} else {
+		err = p.ParseFile(*name, *offset, f)
+	}
 
 	if err != nil {
 		println(err.Error())
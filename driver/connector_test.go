@@ -0,0 +1,216 @@
+package driver
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/server"
+)
+
+// recordHandler records the queries and returns the arguments of the statements as a row
+type recordHandler struct {
+	server.EmptyHandler
+
+	mu         sync.Mutex
+	queries    []string
+	attributes map[string]string
+}
+
+func (h *recordHandler) HandleQuery(query string) (*mysql.Result, error) {
+	h.mu.Lock()
+	h.queries = append(h.queries, query)
+	h.mu.Unlock()
+
+	if query == "SELECT dt" {
+		rs, err := mysql.BuildSimpleTextResultset([]string{"dt"}, [][]interface{}{{"2023-04-05 06:07:08"}})
+		if err != nil {
+			return nil, err
+		}
+		rs.Fields[0].Type = mysql.MYSQL_TYPE_DATETIME
+		return &mysql.Result{Resultset: rs}, nil
+	}
+	return nil, nil
+}
+
+func (h *recordHandler) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	return strings.Count(query, "?"), 1, nil, nil
+}
+
+func (h *recordHandler) HandleStmtExecute(context interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	rs, err := mysql.BuildSimpleBinaryResultset([]string{"a"}, [][]interface{}{{args[0]}})
+	if err != nil {
+		return nil, err
+	}
+	return &mysql.Result{Resultset: rs}, nil
+}
+
+func (h *recordHandler) takeQueries() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	q := h.queries
+	h.queries = nil
+	return q
+}
+
+// serveRecord serves the connections of the user root with the password 123, returning the address listened on
+func serveRecord(t *testing.T, h *recordHandler) string {
+	svr := server.NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	p := server.NewInMemoryProvider()
+	p.AddUser("root", "123")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				co, err := server.NewCustomizedConn(conn, svr, p, h)
+				if err != nil {
+					return
+				}
+				h.mu.Lock()
+				h.attributes = co.Attributes()
+				h.mu.Unlock()
+				for co.HandleCommand() == nil {
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestConnector(t *testing.T) {
+	h := &recordHandler{}
+	addr := serveRecord(t, h)
+
+	var optionCalled bool
+	db := sql.OpenDB(NewConnector(addr, "root", "123", "", func(c *client.Conn) {
+		optionCalled = true
+	}))
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	require.NoError(t, db.PingContext(ctx))
+	require.True(t, optionCalled)
+
+	// the uint64 greater than math.MaxInt64 are passed as they are
+	var u uint64
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT ?", uint64(math.MaxUint64)).Scan(&u))
+	require.Equal(t, uint64(math.MaxUint64), u)
+
+	_, err := db.ExecContext(ctx, "SELECT ?", sql.Named("a", 1))
+	require.ErrorContains(t, err, "named argument a is not supported")
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted, ReadOnly: true})
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	require.Equal(t, []string{"SET TRANSACTION ISOLATION LEVEL READ COMMITTED", "START TRANSACTION READ ONLY", "COMMIT"}, h.takeQueries())
+
+	tx, err = db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+	require.Equal(t, []string{"START TRANSACTION", "ROLLBACK"}, h.takeQueries())
+
+	_, err = db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSnapshot})
+	require.ErrorContains(t, err, "isolation level Snapshot is not supported")
+
+	// a cancelled command fails with the error of the context
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = db.ExecContext(cctx, "DO 1")
+	require.ErrorIs(t, err, context.Canceled)
+
+	// the DSN is parsed once by the connector
+	db2, err := sql.Open("mysql", "root:123@"+addr+"/test")
+	require.NoError(t, err)
+	defer db2.Close()
+	_, err = db2.Exec("DO 1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"DO 1"}, h.takeQueries())
+}
+
+func TestDSNParams(t *testing.T) {
+	h := &recordHandler{}
+	addr := serveRecord(t, h)
+
+	db, err := sql.Open("mysql", "root:123@tcp("+addr+")/?parseTime=true&loc=Local&interpolateParams=true&timeout=1s&readTimeout=5s&maxAllowedPacket=1024")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var dt time.Time
+	require.NoError(t, db.QueryRow("SELECT dt").Scan(&dt))
+	require.Equal(t, time.Date(2023, 4, 5, 6, 7, 8, 0, time.Local), dt)
+
+	// the arguments are interpolated instead of being sent to a prepared statement
+	_, err = db.Exec("DO ?, ?", "it's", dt)
+	require.NoError(t, err)
+	require.Equal(t, []string{"SELECT dt", `DO 'it''s', '2023-04-05 06:07:08'`}, h.takeQueries())
+
+	_, err = db.Exec("DO '" + strings.Repeat("a", 2048) + "'")
+	require.ErrorContains(t, err, "max_allowed_packet")
+
+	// without parseTime, the values are the raw strings
+	db2, err := sql.Open("mysql", "root:123@tcp("+addr+")/")
+	require.NoError(t, err)
+	defer db2.Close()
+	var raw string
+	require.NoError(t, db2.QueryRow("SELECT dt").Scan(&raw))
+	require.Equal(t, "2023-04-05 06:07:08", raw)
+
+	for _, dsn := range []string{
+		"root@/?tls=unknown",
+		"root@/?parseTime=maybe",
+		"root@/?loc=Nowhere/Nothing",
+		"root@/?timeout=5",
+		"root@/?maxAllowedPacket=-1",
+	} {
+		_, err = sql.Open("mysql", dsn)
+		require.Error(t, err, dsn)
+	}
+
+	require.Error(t, RegisterTLSConfig("skip-verify", nil))
+	require.NoError(t, RegisterTLSConfig("custom-tls", &tls.Config{}))
+	db3, err := sql.Open("mysql", "root@/?tls=custom-tls")
+	require.NoError(t, err)
+	db3.Close()
+	DeregisterTLSConfig("custom-tls")
+}
+
+func TestDSNSessionSettings(t *testing.T) {
+	h := &recordHandler{}
+	addr := serveRecord(t, h)
+
+	db, err := sql.Open("mysql", "root:123@tcp("+addr+")/?sql_mode=%27ANSI_QUOTES%27&autocommit=0&allowNativePasswords=true"+
+		"&connectionAttributes=program_name:billing,team:payments")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("DO 1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"SET SESSION autocommit = 0", "SET SESSION sql_mode = 'ANSI_QUOTES'", "DO 1"}, h.takeQueries())
+
+	h.mu.Lock()
+	require.Equal(t, "billing", h.attributes["program_name"])
+	require.Equal(t, "payments", h.attributes["team"])
+	h.mu.Unlock()
+
+	_, err = sql.Open("mysql", "root@/?connectionAttributes=program_name")
+	require.Error(t, err)
+}
@@ -3,21 +3,31 @@
 package driver
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
 	sqldriver "database/sql/driver"
+	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io"
+	"net"
 	"net/url"
 	"regexp"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/atoonk/go-mysql/client"
 	"github.com/atoonk/go-mysql/mysql"
 	"github.com/pingcap/errors"
+	"github.com/shopspring/decimal"
 	"github.com/siddontang/go/hack"
 )
 
+// networkDSNRegexp matches the DSN of go-sql-driver/mysql, [user[:password]@][network[(addr)]]/db[?params]
+var networkDSNRegexp = regexp.MustCompile(`^(?:(.*)@)?(?:(\w+)\(([^)]*)\))?/([^/?]*)(?:\?(.*))?$`)
+
 var customTLSMutex sync.Mutex
 
 // Map of dsn address (makes more sense than full dsn?) to tls Config
@@ -28,6 +38,7 @@ type driver struct {
 
 type connInfo struct {
 	standardDSN bool
+	network     string
 	addr        string
 	user        string
 	password    string
@@ -43,12 +54,35 @@ type connInfo struct {
 //
 // Legacy form uses a `?` is used as the path separator: user:password@addr[?db]
 // Standard form uses a `/`: user:password@addr/db?param=value
+// The form of go-sql-driver/mysql is also supported: user:password@tcp(addr)/db?param=value, the password being
+// taken as is, the address can be omitted (user:password@/db for 127.0.0.1:3306)
 //
-// Optional parameters are supported in the standard DSN form
+// Optional parameters are supported in the standard DSN form, see parseParams
 func parseDSN(dsn string) (connInfo, error) {
 	var matchErr error
 	ci := connInfo{}
 
+	if m := networkDSNRegexp.FindStringSubmatch(dsn); m != nil {
+		params, err := url.ParseQuery(m[5])
+		if err != nil {
+			return ci, errors.Errorf("invalid dsn parameters %q", m[5])
+		}
+		ci.standardDSN = true
+		ci.user, ci.password, _ = strings.Cut(m[1], ":")
+		ci.network, ci.addr = m[2], m[3]
+		ci.db = m[4]
+		ci.params = params
+		if ci.addr == "" {
+			switch ci.network {
+			case "unix":
+				ci.addr = "/tmp/mysql.sock"
+			default:
+				ci.addr = "127.0.0.1:3306"
+			}
+		}
+		return ci, nil
+	}
+
 	// If a "/" occurs after "@" and then no more "@" or "/" occur after that
 	ci.standardDSN, matchErr = regexp.MatchString("@[^@]+/[^@/]+", dsn)
 	if matchErr != nil {
@@ -80,61 +114,113 @@ func parseDSN(dsn string) (connInfo, error) {
 	return ci, nil
 }
 
-// Open takes a supplied DSN string and opens a connection
+// OpenConnector parses the DSN once for all the connections of a sql.DB.
 // See ParseDSN for more information on the form of the DSN
-func (d driver) Open(dsn string) (sqldriver.Conn, error) {
-	var c *client.Conn
-
+func (d driver) OpenConnector(dsn string) (sqldriver.Connector, error) {
 	ci, err := parseDSN(dsn)
-
 	if err != nil {
 		return nil, err
 	}
 
+	c := &connector{network: ci.network, addr: ci.addr, user: ci.user, password: ci.password, db: ci.db}
+	// No more processing for the legacy DSN. Let's only support url parameters with the newer style DSN
 	if ci.standardDSN {
-		if ci.params["ssl"] != nil {
-			tlsConfigName := ci.params.Get("ssl")
-			switch tlsConfigName {
-			case "true":
-				// This actually does insecureSkipVerify
-				// But not even sure if it makes sense to handle false? According to
-				// client_test.go it doesn't - it'd result in an error
-				c, err = client.Connect(ci.addr, ci.user, ci.password, ci.db, func(c *client.Conn) { c.UseSSL(true) })
-			case "custom":
-				// I was too concerned about mimicking what go-sql-driver/mysql does which will
-				// allow any name for a custom tls profile and maps the query parameter value to
-				// that TLSConfig variable... there is no need to be that clever.
-				// Instead of doing that, let's store required custom TLSConfigs in a map that
-				// uses the DSN address as the key
-				c, err = client.Connect(ci.addr, ci.user, ci.password, ci.db, func(c *client.Conn) { c.SetTLSConfig(customTLSConfigMap[ci.addr]) })
-			default:
-				return nil, errors.Errorf("Supported options are ssl=true or ssl=custom")
-			}
-		} else {
-			c, err = client.Connect(ci.addr, ci.user, ci.password, ci.db)
+		if err = c.parseParams(ci); err != nil {
+			return nil, err
 		}
-	} else {
-		// No more processing here. Let's only support url parameters with the newer style DSN
-		c, err = client.Connect(ci.addr, ci.user, ci.password, ci.db)
 	}
+
+	return c, nil
+}
+
+// Open takes a supplied DSN string and opens a connection
+// See ParseDSN for more information on the form of the DSN
+func (d driver) Open(dsn string) (sqldriver.Conn, error) {
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return c.Connect(context.Background())
+}
+
+type connector struct {
+	network  string
+	addr     string
+	user     string
+	password string
+	db       string
+	options  []func(*client.Conn)
+
+	// the settings of the DSN parameters which are not options of the client, see parseParams
+	dialTimeout       time.Duration
+	charsets          []string
+	rawTime           bool
+	interpolateParams bool
+}
+
+// NewConnector returns a connector to open a sql.DB with sql.OpenDB, configuring its connections with the options
+// of client.Connect instead of the parameters of a DSN, e.g.
+//
+//	db := sql.OpenDB(driver.NewConnector("127.0.0.1:3306", "root", "", "test", func(c *client.Conn) {
+//		c.SetTLSConfig(tlsConfig)
+//	}))
+func NewConnector(addr string, user string, password string, dbName string, options ...func(*client.Conn)) sqldriver.Connector {
+	return &connector{addr: addr, user: user, password: password, db: dbName, options: options}
+}
+
+// Connect opens a connection, within 10 seconds as client.Connect if ctx has no deadline.
+func (c *connector) Connect(ctx context.Context) (sqldriver.Conn, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Second*10)
+		defer cancel()
+	}
+
+	dialer := &net.Dialer{Timeout: c.dialTimeout}
+	cc, err := client.ConnectWithDialer(ctx, c.network, c.addr, c.user, c.password, c.db, dialer.DialContext, c.options...)
 	if err != nil {
 		return nil, err
 	}
 
-	return &conn{c}, nil
+	// the first charset supported by the server is used
+	for i, charset := range c.charsets {
+		if err = cc.SetCharset(charset); err == nil {
+			break
+		} else if i == len(c.charsets)-1 {
+			cc.Close()
+			return nil, err
+		}
+	}
+
+	return &conn{Conn: cc, rawTime: c.rawTime, interpolateParams: c.interpolateParams}, nil
+}
+
+func (c *connector) Driver() sqldriver.Driver {
+	return driver{}
 }
 
 type conn struct {
 	*client.Conn
+
+	// bad is set once the connection is lost or a command was cancelled, database/sql then discards it
+	bad bool
+	// rawTime returns the DATE, DATETIME and TIMESTAMP values as []byte even if the client parses them
+	rawTime bool
+	// interpolateParams runs the queries with arguments with ExecuteInterpolated instead of a prepared statement
+	interpolateParams bool
 }
 
 func (c *conn) Prepare(query string) (sqldriver.Stmt, error) {
-	st, err := c.Conn.Prepare(query)
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (sqldriver.Stmt, error) {
+	st, err := c.Conn.PrepareContext(ctx, query)
 	if err != nil {
-		return nil, errors.Trace(err)
+		return nil, c.replyError(ctx, err)
 	}
 
-	return &stmt{st}, nil
+	return &stmt{Stmt: st, conn: c}, nil
 }
 
 func (c *conn) Close() error {
@@ -142,52 +228,152 @@ func (c *conn) Close() error {
 }
 
 func (c *conn) Begin() (sqldriver.Tx, error) {
-	err := c.Conn.Begin()
-	if err != nil {
-		return nil, errors.Trace(err)
+	return c.BeginTx(context.Background(), sqldriver.TxOptions{})
+}
+
+// BeginTx starts a transaction with the isolation level and the access mode of opts, the isolation level only
+// applies to this transaction.
+func (c *conn) BeginTx(ctx context.Context, opts sqldriver.TxOptions) (sqldriver.Tx, error) {
+	if level := sql.IsolationLevel(opts.Isolation); level != sql.LevelDefault {
+		var name string
+		switch level {
+		case sql.LevelReadUncommitted:
+			name = "READ UNCOMMITTED"
+		case sql.LevelReadCommitted:
+			name = "READ COMMITTED"
+		case sql.LevelRepeatableRead:
+			name = "REPEATABLE READ"
+		case sql.LevelSerializable:
+			name = "SERIALIZABLE"
+		default:
+			return nil, errors.Errorf("isolation level %s is not supported", level)
+		}
+		if _, err := c.Conn.ExecuteContext(ctx, "SET TRANSACTION ISOLATION LEVEL "+name); err != nil {
+			return nil, c.replyError(ctx, err)
+		}
 	}
 
-	return &tx{c.Conn}, nil
+	query := "START TRANSACTION"
+	if opts.ReadOnly {
+		query = "START TRANSACTION READ ONLY"
+	}
+	if _, err := c.Conn.ExecuteContext(ctx, query); err != nil {
+		return nil, c.replyError(ctx, err)
+	}
+
+	return &tx{c}, nil
 }
 
-func buildArgs(args []sqldriver.Value) []interface{} {
+// buildArgs returns the values of the arguments, MySQL only has positional ? placeholders.
+func buildArgs(args []sqldriver.NamedValue) ([]interface{}, error) {
 	a := make([]interface{}, len(args))
 
 	for i, arg := range args {
-		a[i] = arg
+		if arg.Name != "" {
+			return nil, errors.Errorf("named argument %s is not supported, use ? placeholders", arg.Name)
+		}
+		a[i] = arg.Value
+	}
+
+	return a, nil
+}
+
+func namedValues(args []sqldriver.Value) []sqldriver.NamedValue {
+	named := make([]sqldriver.NamedValue, len(args))
+
+	for i, arg := range args {
+		named[i] = sqldriver.NamedValue{Ordinal: i + 1, Value: arg}
 	}
 
-	return a
+	return named
 }
 
-func replyError(err error) error {
-	if mysql.ErrorEqual(err, mysql.ErrBadConn) {
+// replyError returns sqldriver.ErrBadConn if the connection was lost, so that database/sql opens another one, and
+// marks the connection as bad if it was lost or ctx was cancelled.
+func (c *conn) replyError(ctx context.Context, err error) error {
+	if mysql.ErrorEqual(err, mysql.ErrBadConn) || stderrors.Is(err, mysql.ErrBadConn) {
+		c.bad = true
 		return sqldriver.ErrBadConn
-	} else {
-		return errors.Trace(err)
 	}
+	if ctx.Err() != nil {
+		c.bad = true
+		return err
+	}
+	return errors.Trace(err)
 }
 
-func (c *conn) Exec(query string, args []sqldriver.Value) (sqldriver.Result, error) {
-	a := buildArgs(args)
-	r, err := c.Conn.Execute(query, a...)
+func (c *conn) ExecContext(ctx context.Context, query string, args []sqldriver.NamedValue) (sqldriver.Result, error) {
+	a, err := buildArgs(args)
 	if err != nil {
-		return nil, replyError(err)
+		return nil, err
+	}
+	r, err := c.execute(ctx, query, a)
+	if err != nil {
+		return nil, c.replyError(ctx, err)
 	}
 	return &result{r}, nil
 }
 
-func (c *conn) Query(query string, args []sqldriver.Value) (sqldriver.Rows, error) {
-	a := buildArgs(args)
-	r, err := c.Conn.Execute(query, a...)
+func (c *conn) QueryContext(ctx context.Context, query string, args []sqldriver.NamedValue) (sqldriver.Rows, error) {
+	a, err := buildArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.execute(ctx, query, a)
+	if err != nil {
+		return nil, c.replyError(ctx, err)
+	}
+	return newRows(r.Resultset, c.rawTime)
+}
+
+func (c *conn) execute(ctx context.Context, query string, args []interface{}) (*mysql.Result, error) {
+	if c.interpolateParams && len(args) > 0 {
+		return c.Conn.ExecuteInterpolatedContext(ctx, query, args...)
+	}
+	return c.Conn.ExecuteContext(ctx, query, args...)
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if err := c.Conn.PingContext(ctx); err != nil {
+		return c.replyError(ctx, err)
+	}
+	return nil
+}
+
+// CheckNamedValue passes the arguments supported by the client as they are, e.g. the uint64 greater than
+// math.MaxInt64, json.RawMessage, mysql.Decimal and decimal.Decimal, sent as a DECIMAL, mysql.Geometry and time.Time,
+// which is sent in the location set with client.Conn.SetTimeLocation. The other arguments are converted by
+// sqldriver.DefaultParameterConverter.
+func (c *conn) CheckNamedValue(nv *sqldriver.NamedValue) error {
+	switch nv.Value.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64,
+		string, []byte, json.RawMessage, mysql.Decimal, decimal.Decimal, mysql.Geometry, time.Time:
+		return nil
+	}
+
+	v, err := sqldriver.DefaultParameterConverter.ConvertValue(nv.Value)
 	if err != nil {
-		return nil, replyError(err)
+		return err
+	}
+	nv.Value = v
+	return nil
+}
+
+// ResetSession discards the connection if it is bad before it is reused, the session state is kept.
+func (c *conn) ResetSession(ctx context.Context) error {
+	if c.bad {
+		return sqldriver.ErrBadConn
 	}
-	return newRows(r.Resultset)
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	return !c.bad
 }
 
 type stmt struct {
 	*client.Stmt
+	conn *conn
 }
 
 func (s *stmt) Close() error {
@@ -199,33 +385,53 @@ func (s *stmt) NumInput() int {
 }
 
 func (s *stmt) Exec(args []sqldriver.Value) (sqldriver.Result, error) {
-	a := buildArgs(args)
-	r, err := s.Stmt.Execute(a...)
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) Query(args []sqldriver.Value) (sqldriver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []sqldriver.NamedValue) (sqldriver.Result, error) {
+	a, err := buildArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.Stmt.ExecuteContext(ctx, a...)
 	if err != nil {
-		return nil, replyError(err)
+		return nil, s.conn.replyError(ctx, err)
 	}
 	return &result{r}, nil
 }
 
-func (s *stmt) Query(args []sqldriver.Value) (sqldriver.Rows, error) {
-	a := buildArgs(args)
-	r, err := s.Stmt.Execute(a...)
+func (s *stmt) QueryContext(ctx context.Context, args []sqldriver.NamedValue) (sqldriver.Rows, error) {
+	a, err := buildArgs(args)
 	if err != nil {
-		return nil, replyError(err)
+		return nil, err
 	}
-	return newRows(r.Resultset)
+	r, err := s.Stmt.ExecuteContext(ctx, a...)
+	if err != nil {
+		return nil, s.conn.replyError(ctx, err)
+	}
+	return newRows(r.Resultset, s.conn.rawTime)
 }
 
 type tx struct {
-	*client.Conn
+	conn *conn
 }
 
 func (t *tx) Commit() error {
-	return t.Conn.Commit()
+	if err := t.conn.Conn.Commit(); err != nil {
+		return t.conn.replyError(context.Background(), err)
+	}
+	return nil
 }
 
 func (t *tx) Rollback() error {
-	return t.Conn.Rollback()
+	if err := t.conn.Conn.Rollback(); err != nil {
+		return t.conn.replyError(context.Background(), err)
+	}
+	return nil
 }
 
 type result struct {
@@ -245,9 +451,10 @@ type rows struct {
 
 	columns []string
 	step    int
+	rawTime bool
 }
 
-func newRows(r *mysql.Resultset) (*rows, error) {
+func newRows(r *mysql.Resultset, rawTime bool) (*rows, error) {
 	if r == nil {
 		return nil, fmt.Errorf("invalid mysql query, no correct result")
 	}
@@ -261,6 +468,7 @@ func newRows(r *mysql.Resultset) (*rows, error) {
 		rs.columns[i] = hack.String(f.Name)
 	}
 	rs.step = 0
+	rs.rawTime = rawTime
 
 	return rs, nil
 }
@@ -287,6 +495,10 @@ func (r *rows) Next(dest []sqldriver.Value) error {
 			return err
 		}
 
+		if _, ok := value.(time.Time); ok && r.rawTime {
+			value = r.Resultset.Values[r.step][i].AsString()
+		}
+
 		dest[i] = sqldriver.Value(value)
 	}
 
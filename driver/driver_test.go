@@ -96,4 +96,18 @@ func TestParseDSN(t *testing.T) {
 		// Compare that with expected
 		require.Equal(t, expected, actual)
 	}
+
+	// The DSNs of go-sql-driver/mysql, with the network of the address
+	networkDSNs := map[string]connInfo{
+		"user:p@ss:w/rd@tcp(6.domain.com:3306)/db?parseTime=true": {standardDSN: true, network: "tcp", addr: "6.domain.com:3306", user: "user", password: "p@ss:w/rd", db: "db", params: url.Values{"parseTime": []string{"true"}}},
+		"user@unix(/var/run/mysqld.sock)/db":                      {standardDSN: true, network: "unix", addr: "/var/run/mysqld.sock", user: "user", password: "", db: "db", params: url.Values{}},
+		"user:password@/db":                                       {standardDSN: true, network: "", addr: "127.0.0.1:3306", user: "user", password: "password", db: "db", params: url.Values{}},
+		"/db?loc=Local":                                           {standardDSN: true, network: "", addr: "127.0.0.1:3306", user: "", password: "", db: "db", params: url.Values{"loc": []string{"Local"}}},
+	}
+
+	for supplied, expected := range networkDSNs {
+		actual, err := parseDSN(supplied)
+		require.NoError(t, err)
+		require.Equal(t, expected, actual)
+	}
 }
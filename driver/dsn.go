@@ -0,0 +1,233 @@
+package driver
+
+import (
+	"crypto/tls"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+// Map of the names of the TLS configs registered with RegisterTLSConfig, for the tls parameter of the DSN
+var tlsConfigRegistry = make(map[string]*tls.Config)
+
+// RegisterTLSConfig registers a TLS config used by the DSNs with the parameter tls=name, as in go-sql-driver/mysql.
+// The names true, false, skip-verify and preferred are reserved.
+func RegisterTLSConfig(name string, config *tls.Config) error {
+	switch strings.ToLower(name) {
+	case "true", "false", "skip-verify", "preferred":
+		return errors.Errorf("tls config name %s is reserved", name)
+	}
+
+	customTLSMutex.Lock()
+	tlsConfigRegistry[name] = config
+	customTLSMutex.Unlock()
+	return nil
+}
+
+// DeregisterTLSConfig removes a TLS config registered with RegisterTLSConfig.
+func DeregisterTLSConfig(name string) {
+	customTLSMutex.Lock()
+	delete(tlsConfigRegistry, name)
+	customTLSMutex.Unlock()
+}
+
+// parseParams configures the connector with the parameters of a standard DSN, named as in go-sql-driver/mysql:
+//
+//	ssl=true|custom: TLS without verifying the server, or with the config set by SetCustomTLSConfig
+//	tls=true|false|skip-verify|preferred|<name>: TLS verifying the server, disabled, without verifying the server,
+//	  if the server supports it, or with the config registered with RegisterTLSConfig
+//	timeout, readTimeout, writeTimeout: the timeouts of the dial and the network reads and writes, e.g. 30s
+//	charset: the charset of the connection, or a comma-separated list of charsets, the first supported is used
+//	parseTime: the DATE, DATETIME and TIMESTAMP values are time.Time instead of []byte
+//	loc: the location of the time.Time values, UTC by default, Local for time.Local
+//	multiStatements: several statements can be sent in a query
+//	compress: the packets are compressed with zlib
+//	interpolateParams: the arguments of the queries are interpolated by the client, see client.ExecuteInterpolated
+//	maxAllowedPacket: the size of the largest packet written, 0 for the max_allowed_packet of the server
+//	clientFoundRows: the rows affected by an UPDATE are the rows matched instead of the rows changed
+//	connectionAttributes: the connection attributes, e.g. program_name:myapp,team:payments
+//
+// The other parameters of go-sql-driver/mysql are ignored, and the unknown parameters are session variables set
+// once connected, e.g. sql_mode=TRADITIONAL or time_zone='%2B00:00', see client.Conn.SetConnectVariables.
+func (c *connector) parseParams(ci connInfo) error {
+	c.rawTime = true
+	loc := time.UTC
+	vars := make(map[string]interface{})
+
+	for name := range ci.params {
+		value := ci.params.Get(name)
+		switch name {
+		case "ssl":
+			switch value {
+			case "true":
+				// This actually does insecureSkipVerify
+				// But not even sure if it makes sense to handle false? According to
+				// client_test.go it doesn't - it'd result in an error
+				c.options = append(c.options, func(c *client.Conn) { c.UseSSL(true) })
+			case "custom":
+				// I was too concerned about mimicking what go-sql-driver/mysql does which will
+				// allow any name for a custom tls profile and maps the query parameter value to
+				// that TLSConfig variable... there is no need to be that clever.
+				// Instead of doing that, let's store required custom TLSConfigs in a map that
+				// uses the DSN address as the key
+				c.options = append(c.options, func(c *client.Conn) {
+					customTLSMutex.Lock()
+					c.SetTLSConfig(customTLSConfigMap[ci.addr])
+					customTLSMutex.Unlock()
+				})
+			default:
+				return errors.Errorf("Supported options are ssl=true or ssl=custom")
+			}
+		case "tls":
+			option, err := tlsOption(value)
+			if err != nil {
+				return err
+			}
+			c.options = append(c.options, option)
+		case "timeout":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return errors.Errorf("invalid timeout %q", value)
+			}
+			c.dialTimeout = d
+		case "readTimeout", "writeTimeout":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return errors.Errorf("invalid %s %q", name, value)
+			}
+			if name == "readTimeout" {
+				c.options = append(c.options, func(c *client.Conn) { c.SetReadTimeout(d) })
+			} else {
+				c.options = append(c.options, func(c *client.Conn) { c.SetWriteTimeout(d) })
+			}
+		case "charset":
+			c.charsets = strings.Split(value, ",")
+		case "parseTime":
+			parseTime, err := strconv.ParseBool(value)
+			if err != nil {
+				return errors.Errorf("invalid parseTime %q", value)
+			}
+			c.rawTime = !parseTime
+		case "loc":
+			if value == "Local" {
+				loc = time.Local
+			} else {
+				var err error
+				if loc, err = time.LoadLocation(value); err != nil {
+					return errors.Errorf("invalid loc %q", value)
+				}
+			}
+		case "multiStatements", "compress":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return errors.Errorf("invalid %s %q", name, value)
+			}
+			if enabled {
+				capability := mysql.CLIENT_MULTI_STATEMENTS
+				if name == "compress" {
+					capability = mysql.CLIENT_COMPRESS
+				}
+				c.options = append(c.options, func(c *client.Conn) { c.SetCapability(capability) })
+			}
+		case "interpolateParams":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return errors.Errorf("invalid interpolateParams %q", value)
+			}
+			c.interpolateParams = enabled
+		case "maxAllowedPacket":
+			size, err := strconv.Atoi(value)
+			if err != nil || size < 0 {
+				return errors.Errorf("invalid maxAllowedPacket %q", value)
+			}
+			if size == 0 {
+				size = client.MaxAllowedPacketFromServer
+			}
+			c.options = append(c.options, func(c *client.Conn) { c.SetMaxAllowedPacket(size) })
+		case "clientFoundRows":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return errors.Errorf("invalid clientFoundRows %q", value)
+			}
+			if enabled {
+				c.options = append(c.options, func(c *client.Conn) { c.SetCapability(mysql.CLIENT_FOUND_ROWS) })
+			}
+		case "connectionAttributes":
+			attributes := make(map[string]string)
+			for _, attribute := range strings.Split(value, ",") {
+				k, v, ok := strings.Cut(attribute, ":")
+				if !ok || k == "" {
+					return errors.Errorf("invalid connectionAttributes %q", value)
+				}
+				attributes[k] = v
+			}
+			c.options = append(c.options, func(c *client.Conn) { c.SetAttributes(attributes) })
+		default:
+			if !ignoredParams[name] {
+				vars[name] = sessionVariableValue(value)
+			}
+		}
+	}
+
+	// the arguments are sent in loc even without parseTime
+	c.options = append(c.options, func(c *client.Conn) { c.SetTimeLocation(loc) })
+	if len(vars) > 0 {
+		c.options = append(c.options, func(c *client.Conn) { c.SetConnectVariables(vars) })
+	}
+	return nil
+}
+
+// ignoredParams are the parameters of go-sql-driver/mysql which are not supported, or always enabled
+var ignoredParams = map[string]bool{
+	"allowAllFiles":            true,
+	"allowCleartextPasswords":  true,
+	"allowFallbackToPlaintext": true,
+	"allowNativePasswords":     true,
+	"allowOldPasswords":        true,
+	"checkConnLiveness":        true,
+	"collation":                true,
+	"columnsWithAlias":         true,
+	"rejectReadOnly":           true,
+	"serverPubKey":             true,
+}
+
+// sessionVariableValue returns the value of a session variable of a DSN, an integer or a string, quoted or not as
+// in go-sql-driver/mysql which sends it as is.
+func sessionVariableValue(value string) interface{} {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n
+	}
+	if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// tlsOption returns the option setting the TLS config of the tls parameter of a DSN.
+func tlsOption(value string) (func(*client.Conn), error) {
+	var mode client.SSLMode
+	switch strings.ToLower(value) {
+	case "true":
+		mode = client.SSLVerifyIdentity
+	case "false":
+		mode = client.SSLDisabled
+	case "skip-verify":
+		mode = client.SSLRequired
+	case "preferred":
+		mode = client.SSLPreferred
+	default:
+		customTLSMutex.Lock()
+		config, ok := tlsConfigRegistry[value]
+		customTLSMutex.Unlock()
+		if !ok {
+			return nil, errors.Errorf("tls config %s is not registered", value)
+		}
+		return func(c *client.Conn) { c.SetTLSConfig(config) }, nil
+	}
+	return func(c *client.Conn) { c.SetSSLMode(mode) }, nil
+}
@@ -0,0 +1,85 @@
+package dump
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/pingcap/errors"
+)
+
+// Progress is the progress of the dump or the load of a table, passed to the progress callbacks.
+type Progress struct {
+	Schema string
+	Table  string
+	// Rows is the number of rows of the table dumped or loaded so far, including the ones of an interrupted run
+	Rows uint64
+	// Done is set once all the rows of the table are dumped, never by the Loader
+	Done bool
+}
+
+// tableCheckpoint is the progress of the dump of a table.
+type tableCheckpoint struct {
+	// Last is the primary key of the last row written, as literals, nil if the table has no primary key
+	Last []string `json:"last,omitempty"`
+	Rows uint64   `json:"rows"`
+	Done bool     `json:"done,omitempty"`
+}
+
+// dumpCheckpoint is the progress of a dump, saved after each chunk written.
+type dumpCheckpoint struct {
+	Tables map[string]*tableCheckpoint `json:"tables"`
+}
+
+// loadCheckpoint is the progress of a load, saved after each batch applied.
+type loadCheckpoint struct {
+	// Offset is the number of bytes of the dump applied, and DB the database in use there
+	Offset int64             `json:"offset"`
+	DB     string            `json:"db,omitempty"`
+	Rows   map[string]uint64 `json:"rows"`
+}
+
+// readCheckpoint reads a checkpoint file into v, and returns false if there is none.
+func readCheckpoint(path string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Trace(err)
+	}
+	return true, errors.Trace(json.Unmarshal(data, v))
+}
+
+// writeCheckpoint writes v in a checkpoint file, replaced by renaming a new file.
+func writeCheckpoint(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(os.Rename(f.Name(), path))
+}
+
+// removeCheckpoint removes a checkpoint file once the dump or the load is complete.
+func removeCheckpoint(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Trace(err)
+	}
+	return nil
+}
@@ -16,6 +16,7 @@ import (
 // Unlick mysqldump, Dumper is designed for parsing and syning data easily.
 type Dumper struct {
 	// mysqldump execution path, like mysqldump or /usr/bin/mysqldump, etc...
+	// Empty for a native dumper, see NewNativeDumper.
 	ExecutionPath string
 
 	Addr     string
@@ -44,6 +45,12 @@ type Dumper struct {
 
 	// see detectColumnStatisticsParamSupported
 	isColumnStatisticsParamSupported bool
+
+	// workers, chunkSize, checkpoint and progress are only used by a native dumper
+	workers    int
+	chunkSize  int
+	checkpoint string
+	progress   func(Progress)
 }
 
 func NewDumper(executionPath string, addr string, user string, password string) (*Dumper, error) {
@@ -150,6 +157,10 @@ func (d *Dumper) Reset() {
 }
 
 func (d *Dumper) Dump(w io.Writer) error {
+	if len(d.ExecutionPath) == 0 {
+		return d.dumpNative(w)
+	}
+
 	args := make([]string, 0, 16)
 
 	// Common args
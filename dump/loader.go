@@ -0,0 +1,255 @@
+package dump
+
+import (
+	"bufio"
+	"hash/fnv"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/atoonk/go-mysql/client"
+	. "github.com/atoonk/go-mysql/mysql"
+	"github.com/pingcap/errors"
+	"github.com/siddontang/go-log/log"
+)
+
+const (
+	defaultLoaderWorkers   = 4
+	defaultLoaderBatchSize = 1000
+)
+
+// Loader applies the rows of a dump, written by mysqldump with the options of Dumper or by a native dumper, to a
+// MySQL server. The rows are applied in batches, the ones of each table by the same worker in a transaction, so that
+// the rows of a table are applied in the order of the dump, with the foreign key checks disabled.
+type Loader struct {
+	Addr     string
+	User     string
+	Password string
+	Charset  string
+
+	workers    int
+	batchSize  int
+	replace    bool
+	checkpoint string
+	progress   func(Progress)
+}
+
+func NewLoader(addr string, user string, password string) *Loader {
+	l := new(Loader)
+	l.Addr = addr
+	l.User = user
+	l.Password = password
+	l.Charset = DEFAULT_CHARSET
+	l.workers = defaultLoaderWorkers
+	l.batchSize = defaultLoaderBatchSize
+	return l
+}
+
+func (l *Loader) SetCharset(charset string) {
+	l.Charset = charset
+}
+
+// SetWorkers sets the number of connections the rows are applied with.
+func (l *Loader) SetWorkers(n int) {
+	l.workers = n
+}
+
+// SetBatchSize sets the number of rows applied between two checkpoints.
+func (l *Loader) SetBatchSize(n int) {
+	l.batchSize = n
+}
+
+// SetReplace applies the rows with REPLACE instead of INSERT, so that the rows existing are overwritten.
+func (l *Loader) SetReplace(v bool) {
+	l.replace = v
+}
+
+// SetCheckpoint sets the file the progress of the load is saved in after each batch applied, removed once the load is
+// complete. An interrupted load is resumed from it, the same dump being read again from its start, and the batch
+// interrupted applied again, set SetReplace so that its rows already applied are overwritten.
+func (l *Loader) SetCheckpoint(path string) {
+	l.checkpoint = path
+}
+
+// SetProgress sets the callback called with the rows of each table applied so far after each batch.
+func (l *Loader) SetProgress(f func(Progress)) {
+	l.progress = f
+}
+
+// loadStatement is a statement of the dump with the database it is applied to.
+type loadStatement struct {
+	db    string
+	query string
+}
+
+// Load applies the rows of a dump read from r, the statements but the ones inserting the rows and selecting the
+// database being skipped, e.g. the binlog position.
+func (l *Loader) Load(r io.Reader) error {
+	workers := l.workers
+	if workers <= 0 {
+		workers = 1
+	}
+	batchSize := l.batchSize
+	if batchSize <= 0 {
+		batchSize = defaultLoaderBatchSize
+	}
+
+	conns := make([]*client.Conn, 0, workers)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 0; i < workers; i++ {
+		conn, err := client.Connect(l.Addr, l.User, l.Password, "")
+		if err != nil {
+			return errors.Trace(err)
+		}
+		conns = append(conns, conn)
+		if len(l.Charset) != 0 {
+			if err := conn.SetCharset(l.Charset); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if _, err := conn.Execute("SET SESSION foreign_key_checks = 0"); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	checkpoint := &loadCheckpoint{Rows: make(map[string]uint64)}
+	if l.checkpoint != "" {
+		found, err := readCheckpoint(l.checkpoint, checkpoint)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if checkpoint.Rows == nil {
+			checkpoint.Rows = make(map[string]uint64)
+		}
+		if found {
+			log.Infof("resume load from checkpoint %s at offset %d", l.checkpoint, checkpoint.Offset)
+			if _, err := io.CopyN(io.Discard, r, checkpoint.Offset); err != nil {
+				return errors.Annotatef(err, "skip %d bytes of the dump applied", checkpoint.Offset)
+			}
+		}
+	}
+
+	rb := bufio.NewReaderSize(r, 1024*16)
+	batches := make([][]*loadStatement, workers)
+	tables := make(map[string]bool)
+	offset, db, rows := checkpoint.Offset, checkpoint.DB, 0
+
+	apply := func() error {
+		if err := l.applyBatches(conns, batches); err != nil {
+			return errors.Trace(err)
+		}
+		for i := range batches {
+			batches[i] = batches[i][:0]
+		}
+		rows = 0
+
+		checkpoint.Offset, checkpoint.DB = offset, db
+		if l.checkpoint != "" {
+			if err := writeCheckpoint(l.checkpoint, checkpoint); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if l.progress != nil {
+			for key := range tables {
+				seps := strings.SplitN(key, ".", 2)
+				l.progress(Progress{Schema: seps[0], Table: seps[1], Rows: checkpoint.Rows[key]})
+			}
+		}
+		tables = make(map[string]bool)
+		return nil
+	}
+
+	for {
+		line, err := rb.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return errors.Trace(err)
+		} else if len(line) == 0 && err == io.EOF {
+			break
+		}
+		offset += int64(len(line))
+
+		stmt := strings.TrimRightFunc(line, func(c rune) bool {
+			return c == '\r' || c == '\n'
+		})
+		if m := useExp.FindStringSubmatch(stmt); m != nil {
+			db = m[1]
+		} else if m := valuesExp.FindStringSubmatch(stmt); m != nil {
+			if l.replace {
+				stmt = "REPLACE" + strings.TrimPrefix(stmt, "INSERT")
+			}
+			key := db + "." + m[1]
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(key))
+			i := int(h.Sum32() % uint32(workers))
+			batches[i] = append(batches[i], &loadStatement{db: db, query: stmt})
+			checkpoint.Rows[key]++
+			tables[key] = true
+			rows++
+		}
+
+		if rows >= batchSize {
+			if err := apply(); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if err := apply(); err != nil {
+		return errors.Trace(err)
+	}
+	if l.checkpoint != "" {
+		return errors.Trace(removeCheckpoint(l.checkpoint))
+	}
+	return nil
+}
+
+// applyBatches applies the batch of each worker in a transaction, concurrently.
+func (l *Loader) applyBatches(conns []*client.Conn, batches [][]*loadStatement) error {
+	errs := make([]error, len(batches))
+	var wg sync.WaitGroup
+	for i := range batches {
+		if len(batches[i]) == 0 {
+			continue
+		}
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = applyBatch(conns[i], batches[i])
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+func applyBatch(conn *client.Conn, stmts []*loadStatement) error {
+	if err := conn.Begin(); err != nil {
+		return errors.Trace(err)
+	}
+	for _, stmt := range stmts {
+		if stmt.db != "" && stmt.db != conn.GetDB() {
+			if err := conn.UseDB(stmt.db); err != nil {
+				_ = conn.Rollback()
+				return errors.Trace(err)
+			}
+		}
+		if _, err := conn.Execute(stmt.query); err != nil {
+			_ = conn.Rollback()
+			return errors.Annotatef(err, "apply rows to %s", stmt.db)
+		}
+	}
+	return errors.Trace(conn.Commit())
+}
@@ -0,0 +1,508 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/atoonk/go-mysql/client"
+	. "github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/schema"
+	"github.com/pingcap/errors"
+	"github.com/siddontang/go-log/log"
+)
+
+const (
+	defaultNativeWorkers   = 4
+	defaultNativeChunkSize = 1000
+
+	// binaryCharset is the character set number of the binary strings.
+	binaryCharset = 63
+)
+
+// nativeSystemDatabases are not dumped with all the databases, as with mysqldump --all-databases.
+var nativeSystemDatabases = map[string]bool{
+	"information_schema": true,
+	"performance_schema": true,
+	"sys":                true,
+}
+
+// NewNativeDumper creates a Dumper which dumps MySQL by itself, without the mysqldump binary. The rows of the tables
+// are read in a consistent snapshot shared by several workers, one table per worker at a time, in chunks ordered by
+// their primary key, and written in the format of mysqldump, so that they can be parsed by Parse and DumpAndParse.
+// ExtraOptions and Protocol are only used by mysqldump.
+func NewNativeDumper(addr string, user string, password string) (*Dumper, error) {
+	d := new(Dumper)
+	d.Addr = addr
+	d.User = user
+	d.Password = password
+	d.Tables = make([]string, 0, 16)
+	d.Databases = make([]string, 0, 16)
+	d.Charset = DEFAULT_CHARSET
+	d.IgnoreTables = make(map[string][]string)
+	d.ExtraOptions = make([]string, 0, 5)
+	d.masterDataSkipped = false
+	d.workers = defaultNativeWorkers
+	d.chunkSize = defaultNativeChunkSize
+
+	d.ErrOut = os.Stderr
+
+	return d, nil
+}
+
+// SetWorkers sets the number of tables dumped concurrently by a native dumper, each of them with its own connection.
+func (d *Dumper) SetWorkers(n int) {
+	d.workers = n
+}
+
+// SetChunkSize sets the number of rows of a table read by a query of a native dumper.
+func (d *Dumper) SetChunkSize(n int) {
+	d.chunkSize = n
+}
+
+// SetCheckpoint sets the file the progress of a native dumper is saved in after each chunk written, removed once the
+// dump is complete. An interrupted dump is resumed from it, the rows written then appended to the ones of the dump
+// interrupted, without the binlog position written again. The rows are read in a new snapshot, more recent than the
+// binlog position. The tables without a primary key can't be resumed, their rows are buffered and written at once,
+// and they are dumped again from their first row if the dump is interrupted before.
+func (d *Dumper) SetCheckpoint(path string) {
+	d.checkpoint = path
+}
+
+// SetProgress sets the callback of a native dumper called after each chunk written, by one worker at a time.
+func (d *Dumper) SetProgress(f func(Progress)) {
+	d.progress = f
+}
+
+type nativeTable struct {
+	db    string
+	table string
+}
+
+func (t *nativeTable) key() string {
+	return t.db + "." + t.table
+}
+
+// nativeWriter writes the chunks of the workers, with the database of each chunk, so that they can be interleaved,
+// and saves the checkpoint of the dump after each of them.
+type nativeWriter struct {
+	m   sync.Mutex
+	w   io.Writer
+	err error
+
+	checkpointPath string
+	checkpoint     *dumpCheckpoint
+	progress       func(Progress)
+}
+
+// table returns the checkpoint of a table.
+func (w *nativeWriter) table(t *nativeTable) tableCheckpoint {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if c := w.checkpoint.Tables[t.key()]; c != nil {
+		return *c
+	}
+	return tableCheckpoint{}
+}
+
+// writeChunk writes a chunk of the rows of a table, and the state of the table once they are written.
+func (w *nativeWriter) writeChunk(b []byte, t *nativeTable, state tableCheckpoint) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	if len(b) > 0 {
+		if _, w.err = w.w.Write(b); w.err != nil {
+			return w.err
+		}
+	}
+
+	w.checkpoint.Tables[t.key()] = &state
+	if w.checkpointPath != "" {
+		if w.err = writeCheckpoint(w.checkpointPath, w.checkpoint); w.err != nil {
+			return w.err
+		}
+	}
+	if w.progress != nil {
+		w.progress(Progress{Schema: t.db, Table: t.table, Rows: state.Rows, Done: state.Done})
+	}
+	return nil
+}
+
+func (w *nativeWriter) fail(err error) {
+	w.m.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.m.Unlock()
+}
+
+// dumpNative dumps the tables in a consistent snapshot, started by all the workers while the tables are locked by
+// FLUSH TABLES WITH READ LOCK, as mysqldump --single-transaction --master-data does. The snapshots of the workers
+// may differ if SkipMasterData is set, the tables not being locked, set one worker for a consistent dump then.
+func (d *Dumper) dumpNative(w io.Writer) error {
+	workers := d.workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	conns := make([]*client.Conn, 0, workers)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 0; i < workers; i++ {
+		conn, err := client.Connect(d.Addr, d.User, d.Password, "")
+		if err != nil {
+			return errors.Trace(err)
+		}
+		conns = append(conns, conn)
+		if len(d.Charset) != 0 {
+			if err := conn.SetCharset(d.Charset); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+
+	checkpoint := &dumpCheckpoint{Tables: make(map[string]*tableCheckpoint)}
+	resumed := false
+	if d.checkpoint != "" {
+		var err error
+		if resumed, err = readCheckpoint(d.checkpoint, checkpoint); err != nil {
+			return errors.Trace(err)
+		}
+		if checkpoint.Tables == nil {
+			checkpoint.Tables = make(map[string]*tableCheckpoint)
+		}
+	}
+
+	if err := d.startSnapshot(w, conns, !resumed); err != nil {
+		return errors.Trace(err)
+	}
+
+	tables, err := d.nativeTables(conns[0])
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if resumed {
+		log.Infof("resume dump from checkpoint %s", d.checkpoint)
+	}
+	log.Infof("dump %d tables natively with %d workers", len(tables), workers)
+
+	nw := &nativeWriter{w: w, checkpointPath: d.checkpoint, checkpoint: checkpoint, progress: d.progress}
+	ch := make(chan *nativeTable, len(tables))
+	for _, t := range tables {
+		if c := checkpoint.Tables[t.key()]; c != nil && c.Done {
+			continue
+		}
+		ch <- t
+	}
+	close(ch)
+
+	var wg sync.WaitGroup
+	for _, conn := range conns {
+		conn := conn
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range ch {
+				if err := d.dumpNativeTable(nw, conn, t); err != nil {
+					nw.fail(errors.Annotatef(err, "dump table %s.%s", t.db, t.table))
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if nw.err != nil {
+		return errors.Trace(nw.err)
+	}
+	if d.checkpoint != "" {
+		return errors.Trace(removeCheckpoint(d.checkpoint))
+	}
+	return nil
+}
+
+// startSnapshot starts the snapshot of all the connections, and writes the binlog position and the GTID set of the
+// snapshot if writePosition is set, unless SkipMasterData is set.
+func (d *Dumper) startSnapshot(w io.Writer, conns []*client.Conn, writePosition bool) error {
+	for _, conn := range conns {
+		if _, err := conn.Execute("SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	if !d.masterDataSkipped {
+		if _, err := conns[0].Execute("FLUSH TABLES WITH READ LOCK"); err != nil {
+			return errors.Trace(err)
+		}
+		defer func() {
+			_, _ = conns[0].Execute("UNLOCK TABLES")
+		}()
+	}
+
+	for _, conn := range conns {
+		if _, err := conn.Execute("START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	if d.masterDataSkipped || !writePosition {
+		return nil
+	}
+
+	r, err := conns[0].Execute("SHOW MASTER STATUS")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if r.RowNumber() == 0 {
+		return errors.New("no binlog position, the binlog may be disabled")
+	}
+	name, _ := r.GetString(0, 0)
+	pos, _ := r.GetUint(0, 1)
+
+	var buf bytes.Buffer
+	// MariaDB has no Executed_Gtid_Set, as mysqldump the GTID set is only written for MySQL
+	if gset, _ := r.GetStringByName(0, "Executed_Gtid_Set"); gset != "" {
+		sets := strings.Split(strings.ReplaceAll(gset, "\n", ""), ",")
+		fmt.Fprintf(&buf, "SET @@GLOBAL.GTID_PURGED='%s';\n", strings.Join(sets, ",\n"))
+	}
+	fmt.Fprintf(&buf, "CHANGE MASTER TO MASTER_LOG_FILE='%s', MASTER_LOG_POS=%d;\n", name, pos)
+	_, err = w.Write(buf.Bytes())
+	return errors.Trace(err)
+}
+
+// nativeTables returns the base tables dumped: the Tables of TableDB, the tables of the Databases, or the ones of all
+// the databases but the system ones, except the IgnoreTables.
+func (d *Dumper) nativeTables(conn *client.Conn) ([]*nativeTable, error) {
+	ignored := make(map[string]bool)
+	for db, tables := range d.IgnoreTables {
+		for _, table := range tables {
+			ignored[db+"."+table] = true
+		}
+	}
+
+	var tables []*nativeTable
+	if len(d.Tables) > 0 {
+		for _, table := range d.Tables {
+			tables = append(tables, &nativeTable{db: d.TableDB, table: table})
+		}
+	} else {
+		dbs := append([]string(nil), d.Databases...)
+		if len(dbs) == 0 {
+			r, err := conn.Execute("SHOW DATABASES")
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			for i := 0; i < r.RowNumber(); i++ {
+				db, err := r.GetString(i, 0)
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+				if !nativeSystemDatabases[strings.ToLower(db)] {
+					dbs = append(dbs, db)
+				}
+			}
+		}
+
+		for _, db := range dbs {
+			r, err := conn.Execute(fmt.Sprintf("SHOW FULL TABLES FROM %s WHERE Table_type = 'BASE TABLE'", quoteName(db)))
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			for i := 0; i < r.RowNumber(); i++ {
+				table, err := r.GetString(i, 0)
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+				tables = append(tables, &nativeTable{db: db, table: table})
+			}
+		}
+	}
+
+	n := 0
+	for _, t := range tables {
+		if !ignored[t.db+"."+t.table] {
+			tables[n] = t
+			n++
+		}
+	}
+	return tables[:n], nil
+}
+
+// dumpNativeTable writes the rows of a table in chunks of chunkSize rows, read after the primary key of the last row
+// of the previous chunk, from the checkpoint of the table if the dump is resumed, or by a single query if the table
+// has no primary key. The rows of a table without a primary key are written in chunks without a checkpoint only, as
+// the ones written would be written again on resume.
+func (d *Dumper) dumpNativeTable(w *nativeWriter, conn *client.Conn, t *nativeTable) error {
+	ta, err := schema.NewTable(conn, t.db, t.table)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	chunkSize := d.chunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultNativeChunkSize
+	}
+
+	columns := make([]string, len(ta.Columns))
+	for i, column := range ta.Columns {
+		columns[i] = quoteName(column.Name)
+	}
+	pks := make([]string, len(ta.PKColumns))
+	for i, index := range ta.PKColumns {
+		pks[i] = columns[index]
+	}
+
+	// the state of the table once the rows buffered are written
+	state := w.table(t)
+	if len(pks) == 0 || state.Last == nil {
+		state = tableCheckpoint{}
+	}
+
+	var buf bytes.Buffer
+	var rows int
+	flush := func(done bool) error {
+		state.Done = done
+		err := w.writeChunk(buf.Bytes(), t, state)
+		rows = 0
+		buf.Reset()
+		return err
+	}
+
+	for {
+		query := fmt.Sprintf("SELECT %s FROM %s.%s", strings.Join(columns, ", "), quoteName(t.db), quoteName(t.table))
+		var conds []string
+		if len(d.Where) != 0 {
+			conds = append(conds, "("+d.Where+")")
+		}
+		if state.Last != nil {
+			conds = append(conds, fmt.Sprintf("(%s) > (%s)", strings.Join(pks, ", "), strings.Join(state.Last, ", ")))
+		}
+		if len(conds) != 0 {
+			query += " WHERE " + strings.Join(conds, " AND ")
+		}
+		if len(pks) != 0 {
+			query += fmt.Sprintf(" ORDER BY %s LIMIT %d", strings.Join(pks, ", "), chunkSize)
+		}
+
+		var result Result
+		read := 0
+		err := conn.ExecuteSelectStreaming(query, &result, func(row []FieldValue) error {
+			if rows == 0 {
+				fmt.Fprintf(&buf, "USE %s;\n", quoteName(t.db))
+			}
+			buf.WriteString("INSERT INTO ")
+			buf.WriteString(quoteName(t.table))
+			buf.WriteString(" VALUES (")
+			for i := range row {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				buf.WriteString(d.nativeValue(result.Fields[i], &row[i]))
+			}
+			buf.WriteString(");\n")
+
+			rows++
+			read++
+			state.Rows++
+			if len(pks) != 0 {
+				last := make([]string, len(ta.PKColumns))
+				for i, index := range ta.PKColumns {
+					last[i] = d.nativeValue(result.Fields[index], &row[index])
+				}
+				state.Last = last
+			} else if rows >= chunkSize && w.checkpointPath == "" {
+				return flush(false)
+			}
+			return nil
+		}, nil)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		if len(pks) == 0 || read < chunkSize {
+			return flush(true)
+		}
+		if err := flush(false); err != nil {
+			return errors.Trace(err)
+		}
+	}
+}
+
+// nativeValue returns the literal of a value as written by mysqldump: the numbers unquoted, the bits and, with
+// SetHexBlob, the binary strings in hex, and the other strings quoted and escaped.
+func (d *Dumper) nativeValue(f *Field, v *FieldValue) string {
+	switch v.Type {
+	case FieldValueTypeNull:
+		return "NULL"
+	case FieldValueTypeUnsigned, FieldValueTypeSigned, FieldValueTypeFloat:
+		return v.String()
+	}
+
+	s := v.AsString()
+	switch f.Type {
+	case MYSQL_TYPE_DECIMAL, MYSQL_TYPE_NEWDECIMAL, MYSQL_TYPE_TINY, MYSQL_TYPE_SHORT, MYSQL_TYPE_INT24,
+		MYSQL_TYPE_LONG, MYSQL_TYPE_LONGLONG, MYSQL_TYPE_FLOAT, MYSQL_TYPE_DOUBLE, MYSQL_TYPE_YEAR:
+		return string(s)
+	case MYSQL_TYPE_BIT:
+		if d.hexBlob {
+			return "0x" + hex.EncodeToString(s)
+		}
+		var bits strings.Builder
+		for _, b := range s {
+			bits.WriteString(fmt.Sprintf("%08b", b))
+		}
+		trimmed := strings.TrimLeft(bits.String(), "0")
+		if trimmed == "" {
+			trimmed = "0"
+		}
+		return "b'" + trimmed + "'"
+	case MYSQL_TYPE_STRING, MYSQL_TYPE_VAR_STRING, MYSQL_TYPE_VARCHAR, MYSQL_TYPE_TINY_BLOB, MYSQL_TYPE_MEDIUM_BLOB,
+		MYSQL_TYPE_LONG_BLOB, MYSQL_TYPE_BLOB, MYSQL_TYPE_GEOMETRY:
+		if d.hexBlob && f.Charset == binaryCharset && len(s) > 0 {
+			return "0x" + hex.EncodeToString(s)
+		}
+	}
+	return quoteValue(s)
+}
+
+// quoteValue quotes a string, escaped as by mysql_real_escape_string.
+func quoteValue(s []byte) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('\'')
+	for _, c := range s {
+		switch c {
+		case 0:
+			b.WriteString(`\0`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '"':
+			b.WriteString(`\"`)
+		case 26:
+			b.WriteString(`\Z`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+func quoteName(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
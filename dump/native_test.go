@@ -0,0 +1,53 @@
+package dump
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+func TestNativeValue(t *testing.T) {
+	str := func(s string) *mysql.FieldValue {
+		return &mysql.FieldValue{Type: mysql.FieldValueTypeString, Str: []byte(s)}
+	}
+	varchar := &mysql.Field{Type: mysql.MYSQL_TYPE_VAR_STRING, Charset: 33}
+	blob := &mysql.Field{Type: mysql.MYSQL_TYPE_BLOB, Charset: binaryCharset}
+	bit := &mysql.Field{Type: mysql.MYSQL_TYPE_BIT, Charset: binaryCharset}
+	decimal := &mysql.Field{Type: mysql.MYSQL_TYPE_NEWDECIMAL}
+
+	d := new(Dumper)
+	tbls := []struct {
+		f        *mysql.Field
+		v        *mysql.FieldValue
+		hexBlob  bool
+		expected string
+	}{
+		{varchar, &mysql.FieldValue{Type: mysql.FieldValueTypeNull}, false, "NULL"},
+		{varchar, &mysql.FieldValue{Type: mysql.FieldValueTypeSigned, Val: 12}, false, "12"},
+		{decimal, str("-1.50"), false, "-1.50"},
+		{varchar, str("a'b\"c\\d\ne\r\x00\x1a"), false, `'a\'b\"c\\d\ne\r\0\Z'`},
+		{blob, str("\x01\xff"), true, "0x01ff"},
+		{blob, str(""), true, "''"},
+		{blob, str("ab"), false, "'ab'"},
+		{bit, str("\x00\x05"), true, "0x0005"},
+		{bit, str("\x00\x05"), false, "b'101'"},
+		{bit, str("\x00"), false, "b'0'"},
+	}
+	for _, tt := range tbls {
+		d.hexBlob = tt.hexBlob
+		require.Equal(t, tt.expected, d.nativeValue(tt.f, tt.v))
+	}
+
+	// the values are parsed back as the values of mysqldump
+	values := []string{
+		d.nativeValue(varchar, str("x,y'z\n")),
+		d.nativeValue(varchar, &mysql.FieldValue{Type: mysql.FieldValueTypeNull}),
+		d.nativeValue(varchar, str("")),
+	}
+	parsed, err := parseValues(strings.Join(values, ","))
+	require.NoError(t, err)
+	require.Equal(t, []string{"'x,y'z\n'", "NULL", "''"}, parsed)
+}
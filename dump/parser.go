@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/atoonk/go-mysql/mysql"
 	"github.com/pingcap/errors"
@@ -92,13 +93,15 @@ func Parse(r io.Reader, h ParseHandler, parseBinlogPos bool) error {
 		if m := valuesExp.FindAllStringSubmatch(line, -1); len(m) == 1 {
 			table := m[0][1]
 
-			values, err := parseValues(m[0][2])
+			rows, err := parseRows("(" + m[0][2] + ")")
 			if err != nil {
 				return errors.Errorf("parse values %v err", line)
 			}
 
-			if err = h.Data(db, table, values); err != nil && err != ErrSkip {
-				return errors.Trace(err)
+			for _, values := range rows {
+				if err = h.Data(db, table, values); err != nil && err != ErrSkip {
+					return errors.Trace(err)
+				}
 			}
 		}
 	}
@@ -107,58 +110,119 @@ func Parse(r io.Reader, h ParseHandler, parseBinlogPos bool) error {
 }
 
 func parseValues(str string) ([]string, error) {
-	// values are separated by comma, but we can not split using comma directly
-	// string is enclosed by single quote
+	values, n, err := scanValues(str + ")")
+	if err != nil {
+		return nil, err
+	}
+	if n != len(str)+1 {
+		return nil, fmt.Errorf("parse values error, unexpected %q", str[n-1:])
+	}
+	return values, nil
+}
 
-	// a simple implementation, may be more robust later.
+// parseRows parses the rows of the VALUES of an INSERT statement, tuples separated by commas as mysqldump writes them
+// with --extended-insert.
+func parseRows(str string) ([][]string, error) {
+	rows := make([][]string, 0, 1)
+
+	i := 0
+	for {
+		i = skipSpaces(str, i)
+		if i >= len(str) || str[i] != '(' {
+			return nil, fmt.Errorf("parse rows error, ( expected at %d", i)
+		}
+		values, n, err := scanValues(str[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, values)
 
+		i = skipSpaces(str, i+1+n)
+		if i == len(str) {
+			return rows, nil
+		}
+		if str[i] != ',' {
+			return nil, fmt.Errorf("parse rows error, comma expected at %d", i)
+		}
+		i++
+	}
+}
+
+// scanValues scans the values of a tuple until its closing parenthesis, and returns them with the length scanned.
+// The strings are enclosed by single quotes, un-escaped, the other values are returned as they are, e.g. NULL,
+// numbers, and the binary literals 0x..., X'...', b'...' and _binary '...'.
+func scanValues(str string) ([]string, int, error) {
 	values := make([]string, 0, 8)
 
 	i := 0
-	for i < len(str) {
-		if str[i] != '\'' {
-			// no string, read until comma
-			j := i + 1
-			for ; j < len(str) && str[j] != ','; j++ {
-			}
-			values = append(values, str[i:j])
-			// skip ,
-			i = j + 1
-		} else {
-			// read string until another single quote
-			j := i + 1
+	for {
+		value, n, err := scanValue(str[i:])
+		if err != nil {
+			return nil, 0, err
+		}
+		values = append(values, value)
+		i += n
 
-			escaped := false
-			for j < len(str) {
-				if str[j] == '\\' {
-					// skip escaped character
-					j += 2
-					escaped = true
-					continue
-				} else if str[j] == '\'' {
-					break
-				} else {
-					j++
-				}
-			}
+		if i >= len(str) {
+			return nil, 0, fmt.Errorf("parse values error, ) expected")
+		}
+		if str[i] == ')' {
+			return values, i + 1, nil
+		}
+		// skip ,
+		i++
+	}
+}
 
-			if j >= len(str) {
-				return nil, fmt.Errorf("parse quote values error")
-			}
+// scanValue scans a value until the comma or the parenthesis following it.
+func scanValue(str string) (string, int, error) {
+	start := skipSpaces(str, 0)
 
-			value := str[i : j+1]
-			if escaped {
-				value = unescapeString(value)
+	i := start
+	quoted := -1
+	escaped := false
+	for i < len(str) && str[i] != ',' && str[i] != ')' {
+		if str[i] != '\'' {
+			i++
+			continue
+		}
+
+		// read string until another single quote
+		if quoted < 0 {
+			quoted = i
+		}
+		j := i + 1
+		for j < len(str) {
+			if str[j] == '\\' {
+				// skip escaped character
+				j += 2
+				escaped = true
+				continue
+			} else if str[j] == '\'' {
+				break
 			}
-			values = append(values, value)
-			// skip ' and ,
-			i = j + 2
+			j++
+		}
+		if j >= len(str) {
+			return "", 0, fmt.Errorf("parse quote values error")
 		}
+		i = j + 1
+	}
 
-		// need skip blank???
+	value := strings.TrimRightFunc(str[start:i], unicode.IsSpace)
+	if escaped && quoted >= 0 {
+		// un-escape the string, but not its prefix, e.g. _binary
+		prefix := quoted - start
+		value = value[:prefix] + unescapeString(value[prefix:])
 	}
+	return value, i, nil
+}
 
-	return values, nil
+func skipSpaces(str string, i int) int {
+	for i < len(str) && unicode.IsSpace(rune(str[i])) {
+		i++
+	}
+	return i
 }
 
 // unescapeString un-escapes the string.
@@ -176,6 +240,10 @@ func unescapeString(s string) string {
 				break
 			}
 
+			// \% and \_ keep their backslash, as in MySQL
+			if s[j] == '%' || s[j] == '_' {
+				value = append(value, '\\')
+			}
 			value = append(value, unescapeChar(s[j]))
 			i += 2
 		} else {
@@ -149,3 +149,39 @@ func TestParseLine(t *testing.T) {
 		require.Equal(t, te.expected, m[0][2])
 	}
 }
+
+func TestParseRows(t *testing.T) {
+	rows, err := parseRows(`(1,'a),(b',NULL),(2,0x00ff,_binary 'x\'y'),(3,b'101',X'ab',''), (4,'\%\n',-1.5e3)`)
+	require.NoError(t, err)
+	require.Equal(t, [][]string{
+		{"1", "'a),(b'", "NULL"},
+		{"2", "0x00ff", `_binary 'x'y'`},
+		{"3", "b'101'", "X'ab'", "''"},
+		{"4", "'\\%\n'", "-1.5e3"},
+	}, rows)
+
+	_, err = parseRows(`(1,'a'),`)
+	require.Error(t, err)
+	_, err = parseRows(`(1,'a')(2,'b')`)
+	require.Error(t, err)
+	_, err = parseRows(`(1,'a`)
+	require.Error(t, err)
+}
+
+func TestParseExtendedInsert(t *testing.T) {
+	dump := "USE `test`;\nINSERT INTO `t` VALUES (1,'a'),(2,NULL);\n"
+	h := new(testRowsParseHandler)
+	err := Parse(strings.NewReader(dump), h, false)
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"test", "t", "1", "'a'"}, {"test", "t", "2", "NULL"}}, h.rows)
+}
+
+type testRowsParseHandler struct {
+	testParseHandler
+	rows [][]string
+}
+
+func (h *testRowsParseHandler) Data(schema string, table string, values []string) error {
+	h.rows = append(h.rows, append([]string{schema, table}, values...))
+	return nil
+}
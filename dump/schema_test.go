@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -121,3 +124,129 @@ func (s *schemaTestSuite) TestParse() {
 	err = Parse(&buf, new(testParseHandler), true)
 	require.NoError(s.T(), err)
 }
+
+func (s *schemaTestSuite) TestNativeDump() {
+	d, err := NewNativeDumper(s.d.Addr, "root", "")
+	require.NoError(s.T(), err)
+	d.SetCharset("utf8")
+	d.SetChunkSize(3)
+	d.AddDatabases("test1", "test2")
+	d.AddIgnoreTables("test1", "t2")
+
+	var buf bytes.Buffer
+	err = d.Dump(&buf)
+	require.NoError(s.T(), err)
+
+	h := new(testNativeParseHandler)
+	err = Parse(&buf, h, true)
+	require.NoError(s.T(), err)
+	require.NotEmpty(s.T(), h.binlog)
+	require.Len(s.T(), h.rows["test1.t1"], 4)
+	require.Len(s.T(), h.rows["test2.t1"], 4)
+	require.Len(s.T(), h.rows["test2.t2"], 4)
+	require.NotContains(s.T(), h.rows, "test1.t2")
+	require.Equal(s.T(), []string{"3", `'\'`}, h.rows["test1.t1"][2])
+}
+
+func (s *schemaTestSuite) TestNativeDumpCheckpoint() {
+	d, err := NewNativeDumper(s.d.Addr, "root", "")
+	require.NoError(s.T(), err)
+	d.SetChunkSize(1)
+	d.AddDatabases("test1", "test2")
+
+	// the dump interrupted after test1.t1 and the first two rows of test2.t1
+	path := filepath.Join(s.T().TempDir(), "dump.checkpoint")
+	err = writeCheckpoint(path, &dumpCheckpoint{Tables: map[string]*tableCheckpoint{
+		"test1.t1": {Rows: 4, Done: true},
+		"test2.t1": {Last: []string{"2"}, Rows: 2},
+	}})
+	require.NoError(s.T(), err)
+	d.SetCheckpoint(path)
+
+	var m sync.Mutex
+	progress := make(map[string]Progress)
+	d.SetProgress(func(p Progress) {
+		m.Lock()
+		progress[p.Schema+"."+p.Table] = p
+		m.Unlock()
+	})
+
+	var buf bytes.Buffer
+	err = d.Dump(&buf)
+	require.NoError(s.T(), err)
+	require.NoFileExists(s.T(), path)
+
+	h := new(testNativeParseHandler)
+	err = Parse(&buf, h, true)
+	require.NoError(s.T(), err)
+	require.Empty(s.T(), h.binlog)
+	require.NotContains(s.T(), h.rows, "test1.t1")
+	require.Len(s.T(), h.rows["test2.t1"], 2)
+	require.Equal(s.T(), "3", h.rows["test2.t1"][0][0])
+	require.Equal(s.T(), Progress{Schema: "test2", Table: "t1", Rows: 4, Done: true}, progress["test2.t1"])
+	require.Equal(s.T(), Progress{Schema: "test1", Table: "t2", Rows: 4, Done: true}, progress["test1.t2"])
+}
+
+func (s *schemaTestSuite) TestLoad() {
+	d, err := NewNativeDumper(s.d.Addr, "root", "")
+	require.NoError(s.T(), err)
+	d.AddTables("test1", "t1")
+
+	var buf bytes.Buffer
+	err = d.Dump(&buf)
+	require.NoError(s.T(), err)
+
+	_, err = s.conn.Execute("CREATE TABLE IF NOT EXISTS test1.t1_copy LIKE test1.t1")
+	require.NoError(s.T(), err)
+	defer func() {
+		_, _ = s.conn.Execute("DROP TABLE IF EXISTS test1.t1_copy")
+	}()
+	dump := strings.ReplaceAll(buf.String(), "INSERT INTO `t1`", "INSERT INTO `t1_copy`")
+
+	l := NewLoader(s.d.Addr, "root", "")
+	l.SetBatchSize(3)
+	var rows uint64
+	l.SetProgress(func(p Progress) {
+		rows = p.Rows
+	})
+	err = l.Load(strings.NewReader(dump))
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), uint64(4), rows)
+
+	r, err := s.conn.Execute("SELECT name FROM test1.t1_copy ORDER BY id")
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 4, r.RowNumber())
+	name, _ := r.GetString(2, 0)
+	require.Equal(s.T(), `\`, name)
+
+	// an interrupted load is resumed from its checkpoint, the rows of the batch interrupted replaced
+	path := filepath.Join(s.T().TempDir(), "load.checkpoint")
+	lines := strings.SplitAfter(dump, "\n")
+	offset := len(lines[0]) + len(lines[1]) + len(lines[2])
+	err = writeCheckpoint(path, &loadCheckpoint{Offset: int64(offset), DB: "test1"})
+	require.NoError(s.T(), err)
+	l.SetCheckpoint(path)
+	l.SetReplace(true)
+	err = l.Load(strings.NewReader(dump))
+	require.NoError(s.T(), err)
+	require.NoFileExists(s.T(), path)
+}
+
+type testNativeParseHandler struct {
+	testParseHandler
+	binlog string
+	rows   map[string][][]string
+}
+
+func (h *testNativeParseHandler) BinLog(name string, pos uint64) error {
+	h.binlog = name
+	return nil
+}
+
+func (h *testNativeParseHandler) Data(schema string, table string, values []string) error {
+	if h.rows == nil {
+		h.rows = make(map[string][][]string)
+	}
+	h.rows[schema+"."+table] = append(h.rows[schema+"."+table], values)
+	return nil
+}
@@ -1,8 +1,8 @@
 // Failover supports to promote a new master and let other slaves
 // replicate from it automatically.
 //
-// Failover does not support monitoring whether a master is alive or not,
-// and will think the master is down.
+// Failover thinks the master is down, GTIDFailover can check whether a
+// MySQL master is alive before failing it over.
 //
 // This package is still in development and could not be used in production environment.
 package failover
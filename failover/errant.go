@@ -0,0 +1,86 @@
+package failover
+
+import (
+	"strings"
+
+	. "github.com/atoonk/go-mysql/mysql"
+	"github.com/pingcap/errors"
+)
+
+// ErrantTransactions are the transactions executed by a replica but by neither its master nor the other replicas,
+// e.g. written to it directly, which would be replicated to the other replicas once it is promoted.
+type ErrantTransactions struct {
+	// GTIDSet is the set of the GTIDs of the errant transactions
+	GTIDSet *MysqlGTIDSet
+	// UUIDs are the UUIDs of the servers which originated them, sorted
+	UUIDs []string
+}
+
+func (e *ErrantTransactions) String() string {
+	return e.GTIDSet.String()
+}
+
+// FindErrantTransactions compares the gtid_executed of a candidate replica with the ones of its master and of the
+// other replicas, returning the errant transactions of the candidate, nil if it has none. The master may be nil when
+// it is down, the transactions it originated, by the Master_UUID of the candidate, are then not errant.
+func FindErrantTransactions(candidate *Server, master *Server, replicas []*Server) (*ErrantTransactions, error) {
+	set, err := candidate.ExecutedGTIDSet()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	var masterUUID string
+	var others []GTIDSet
+	if master != nil {
+		masterSet, err := master.ExecutedGTIDSet()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		others = append(others, masterSet)
+	} else {
+		if masterUUID, err = candidate.MasterUUID(); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	for _, replica := range replicas {
+		if replica == candidate {
+			continue
+		}
+		replicaSet, err := replica.ExecutedGTIDSet()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		others = append(others, replicaSet)
+	}
+
+	return errantTransactions(set, masterUUID, others)
+}
+
+// errantTransactions returns the transactions of set in none of the others, but the ones of the master UUID if not
+// empty, nil if there are none.
+func errantTransactions(set GTIDSet, masterUUID string, others []GTIDSet) (*ErrantTransactions, error) {
+	s, ok := set.Clone().(*MysqlGTIDSet)
+	if !ok {
+		return nil, errors.Errorf("invalid MySQL GTID set %s", set)
+	}
+	for _, other := range others {
+		errant, err := s.Subtract(other)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		s = errant.(*MysqlGTIDSet)
+	}
+
+	if masterUUID != "" {
+		delete(s.Sets, strings.ToLower(masterUUID))
+	}
+	if s.IsEmpty() {
+		return nil, nil
+	}
+
+	e := &ErrantTransactions{GTIDSet: s}
+	for _, uuidSet := range s.UUIDSets() {
+		e.UUIDs = append(e.UUIDs, uuidSet.SID.String())
+	}
+	return e, nil
+}
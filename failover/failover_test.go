@@ -3,11 +3,13 @@ package failover
 import (
 	"flag"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
+	"github.com/atoonk/go-mysql/mysql"
 	"github.com/atoonk/go-mysql/test_util"
 )
 
@@ -174,3 +176,72 @@ func (s *failoverTestSuite) checkInsert(m *Server, name string) uint64 {
 
 	return r.InsertId
 }
+
+func TestMostAdvanced(t *testing.T) {
+	parse := func(strs ...string) []mysql.GTIDSet {
+		sets := make([]mysql.GTIDSet, len(strs))
+		for i, str := range strs {
+			set, err := mysql.ParseMysqlGTIDSet(str)
+			require.NoError(t, err)
+			sets[i] = set
+		}
+		return sets
+	}
+
+	master := "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	masters := []string{master, master, master}
+
+	best, errant, err := mostAdvanced(parse(
+		master+":1-10",
+		master+":1-12,4e11fa47-71ca-11e1-9e33-c80aa9429562:1-2",
+		master+":1-12,4e11fa47-71ca-11e1-9e33-c80aa9429562:1",
+	), masters)
+	require.NoError(t, err)
+	require.Equal(t, 1, best)
+	require.Equal(t, "4e11fa47-71ca-11e1-9e33-c80aa9429562:2", errant.String())
+
+	// the errant transactions of a replica don't prevent a more advanced one from being elected
+	best, errant, err = mostAdvanced(parse(
+		master+":1-12",
+		master+":1-11,4e11fa47-71ca-11e1-9e33-c80aa9429562:1",
+	), masters[:2])
+	require.NoError(t, err)
+	require.Equal(t, 0, best)
+	require.Nil(t, errant)
+
+	// the replicas executed transactions of the master missed by the others
+	_, _, err = mostAdvanced(parse(
+		master+":1-10:12",
+		master+":1-11",
+	), masters[:2])
+	require.ErrorContains(t, err, "no replica executed all the transactions of the others")
+}
+
+func TestErrantTransactions(t *testing.T) {
+	parse := func(str string) mysql.GTIDSet {
+		set, err := mysql.ParseMysqlGTIDSet(str)
+		require.NoError(t, err)
+		return set
+	}
+
+	master := "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	candidate := parse(master + ":1-12,4e11fa47-71ca-11e1-9e33-c80aa9429562:1-3,5e11fa47-71ca-11e1-9e33-c80aa9429562:7")
+	others := []mysql.GTIDSet{
+		parse(master + ":1-10,4e11fa47-71ca-11e1-9e33-c80aa9429562:1"),
+		parse(master + ":1-11"),
+	}
+
+	// the transactions of the master missed by the other replicas are not errant once it is down
+	errant, err := errantTransactions(candidate, strings.ToUpper(master), others)
+	require.NoError(t, err)
+	require.Equal(t, "4e11fa47-71ca-11e1-9e33-c80aa9429562:2-3,5e11fa47-71ca-11e1-9e33-c80aa9429562:7", errant.String())
+	require.Equal(t, []string{"4e11fa47-71ca-11e1-9e33-c80aa9429562", "5e11fa47-71ca-11e1-9e33-c80aa9429562"}, errant.UUIDs)
+
+	errant, err = errantTransactions(candidate, "", others)
+	require.NoError(t, err)
+	require.Equal(t, master+":12,4e11fa47-71ca-11e1-9e33-c80aa9429562:2-3,5e11fa47-71ca-11e1-9e33-c80aa9429562:7", errant.String())
+
+	errant, err = errantTransactions(parse(master+":1-10"), "", others)
+	require.NoError(t, err)
+	require.Nil(t, errant)
+}
@@ -0,0 +1,205 @@
+package failover
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	. "github.com/atoonk/go-mysql/mysql"
+	"github.com/pingcap/errors"
+)
+
+// GTIDFailover fails over a MySQL 5.7 or 8.0 master with GTID mode on to the most advanced of its replicas:
+// 1. Wait for the replicas to apply their relay log, their IO_THREAD stopped
+// 2. Elect the replica whose gtid_executed contains the ones of all the others, their errant transactions aside,
+// without errant transactions if CheckErrantTransactions is set
+// 3. Promote it to master, with its replication reset and read_only off
+// 4. Change the other replicas to the new master with MASTER_AUTO_POSITION = 1
+//
+// The master can be watched by WaitMasterDown, Run waiting for it to be down before failing it over.
+type GTIDFailover struct {
+	Master   *Server
+	Replicas []*Server
+
+	// CheckInterval is the interval of the checks of the master, CheckTimeout the time a check may take and
+	// MaxFailures the number of checks failed in a row after which the master is down
+	CheckInterval time.Duration
+	CheckTimeout  time.Duration
+	MaxFailures   int
+
+	// RelayLogTimeout bounds the time the replicas may take to apply their relay log if not zero
+	RelayLogTimeout time.Duration
+
+	// CheckErrantTransactions aborts the failover if the replica elected has errant transactions, see
+	// FindErrantTransactions
+	CheckErrantTransactions bool
+
+	// PreFailover is called with the replica elected before it is promoted, the failover is aborted if it fails
+	PreFailover func(master *Server, candidate *Server) error
+	// PostFailover is called once the other replicas replicate from the new master
+	PostFailover func(master *Server, newMaster *Server, replicas []*Server) error
+}
+
+// NewGTIDFailover creates the failover of a master, checked every second within 3 seconds and down after 3 checks
+// failed in a row.
+func NewGTIDFailover(master *Server, replicas []*Server) *GTIDFailover {
+	return &GTIDFailover{
+		Master:        master,
+		Replicas:      replicas,
+		CheckInterval: time.Second,
+		CheckTimeout:  3 * time.Second,
+		MaxFailures:   3,
+	}
+}
+
+// WaitMasterDown checks the master until it is down, or the context is done.
+func (f *GTIDFailover) WaitMasterDown(ctx context.Context) error {
+	ticker := time.NewTicker(f.CheckInterval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		if err := f.Master.CheckAlive(f.CheckTimeout); err != nil {
+			failures++
+			if failures >= f.MaxFailures {
+				return nil
+			}
+		} else {
+			failures = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run waits for the master to be down, then fails it over, returning the new master.
+func (f *GTIDFailover) Run(ctx context.Context) (*Server, error) {
+	if err := f.WaitMasterDown(ctx); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return f.Failover()
+}
+
+// Failover promotes the most advanced replica to master and changes the other replicas to it, returning the new
+// master. Master and Replicas are updated to the new topology, without the old master.
+func (f *GTIDFailover) Failover() (*Server, error) {
+	if len(f.Replicas) == 0 {
+		return nil, errors.Errorf("no replica of %s to fail over to", f.Master.Addr)
+	}
+
+	h := &MysqlGTIDHandler{RelayLogTimeout: f.RelayLogTimeout}
+	if err := h.CheckGTIDMode(f.Replicas); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	sets := make([]GTIDSet, len(f.Replicas))
+	masterUUIDs := make([]string, len(f.Replicas))
+	for i, replica := range f.Replicas {
+		if err := h.WaitRelayLogDone(replica); err != nil {
+			return nil, errors.Annotatef(err, "apply relay log of %s", replica.Addr)
+		}
+		set, err := replica.ExecutedGTIDSet()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		sets[i] = set
+		if masterUUIDs[i], err = replica.MasterUUID(); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	best, errant, err := mostAdvanced(sets, masterUUIDs)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	newMaster := f.Replicas[best]
+
+	if f.CheckErrantTransactions && errant != nil {
+		return nil, errors.Errorf("%s has errant transactions %s", newMaster.Addr, errant)
+	}
+
+	if f.PreFailover != nil {
+		if err := f.PreFailover(f.Master, newMaster); err != nil {
+			return nil, errors.Annotatef(err, "pre failover to %s", newMaster.Addr)
+		}
+	}
+
+	if err := f.promote(newMaster); err != nil {
+		return nil, errors.Annotatef(err, "promote %s", newMaster.Addr)
+	}
+
+	replicas := make([]*Server, 0, len(f.Replicas)-1)
+	for _, replica := range f.Replicas {
+		if replica == newMaster {
+			continue
+		}
+		if err := h.ChangeMasterTo(replica, newMaster); err != nil {
+			return nil, errors.Annotatef(err, "change master of %s to %s", replica.Addr, newMaster.Addr)
+		}
+		replicas = append(replicas, replica)
+	}
+
+	if f.PostFailover != nil {
+		if err := f.PostFailover(f.Master, newMaster, replicas); err != nil {
+			return nil, errors.Annotatef(err, "post failover to %s", newMaster.Addr)
+		}
+	}
+
+	f.Master, f.Replicas = newMaster, replicas
+	return newMaster, nil
+}
+
+func (f *GTIDFailover) promote(s *Server) error {
+	if err := s.StopSlave(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := s.ResetSlaveALL(); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(s.SetReadonly(false))
+}
+
+// mostAdvanced returns the index of the first GTID set containing all the others, with its errant transactions.
+// The errant transactions of the sets, see errantTransactions, are left out of the comparison: a replica written to
+// directly is not more advanced than the others, nor does it prevent them from being elected. masterUUIDs are the
+// Master_UUID of the replicas of the sets, whose transactions are never errant.
+func mostAdvanced(sets []GTIDSet, masterUUIDs []string) (int, *ErrantTransactions, error) {
+	known := make([]GTIDSet, len(sets))
+	errants := make([]*ErrantTransactions, len(sets))
+	for i, set := range sets {
+		others := append(append([]GTIDSet(nil), sets[:i]...), sets[i+1:]...)
+		errant, err := errantTransactions(set, masterUUIDs[i], others)
+		if err != nil {
+			return -1, nil, errors.Trace(err)
+		}
+		known[i], errants[i] = set, errant
+		if errant != nil {
+			if known[i], err = set.(*MysqlGTIDSet).Subtract(errant.GTIDSet); err != nil {
+				return -1, nil, errors.Trace(err)
+			}
+		}
+	}
+
+	for i, set := range known {
+		all := true
+		for _, other := range known {
+			if !set.Contain(other) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return i, errants[i], nil
+		}
+	}
+
+	strs := make([]string, len(sets))
+	for i, set := range sets {
+		strs[i] = set.String()
+	}
+	return -1, nil, errors.Errorf("no replica executed all the transactions of the others: %s", strings.Join(strs, ", "))
+}
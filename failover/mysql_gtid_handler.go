@@ -3,6 +3,8 @@ package failover
 import (
 	"fmt"
 	"net"
+	"strings"
+	"time"
 
 	. "github.com/atoonk/go-mysql/mysql"
 	"github.com/pingcap/errors"
@@ -10,6 +12,9 @@ import (
 
 type MysqlGTIDHandler struct {
 	Handler
+
+	// RelayLogTimeout bounds the time WaitRelayLogDone waits for the relay log to be applied if not zero
+	RelayLogTimeout time.Duration
 }
 
 func (h *MysqlGTIDHandler) Promote(s *Server) error {
@@ -105,7 +110,14 @@ func (h *MysqlGTIDHandler) WaitRelayLogDone(s *Server) error {
 	}
 
 	retrieved, _ := r.GetStringByName(0, "Retrieved_Gtid_Set")
+	retrieved = strings.ReplaceAll(retrieved, "\n", "")
+	if retrieved == "" {
+		return nil
+	}
 
+	if h.RelayLogTimeout > 0 {
+		return errors.Trace(s.WaitExecutedGTIDSet(retrieved, h.RelayLogTimeout))
+	}
 	// may only support MySQL version >= 5.6.9
 	// see http://dev.mysql.com/doc/refman/5.6/en/gtid-functions.html
 	return h.waitUntilAfterGTIDs(s, retrieved)
@@ -1,7 +1,14 @@
 package failover
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
 
 	"github.com/atoonk/go-mysql/client"
 	. "github.com/atoonk/go-mysql/mysql"
@@ -172,3 +179,52 @@ func (s *Server) MasterPosWait(pos Position, timeout int) error {
 	_, err := s.Execute(fmt.Sprintf("SELECT MASTER_POS_WAIT('%s', %d, %d)", pos.Name, pos.Pos, timeout))
 	return err
 }
+
+// CheckAlive connects to the server with a new connection and pings it within the timeout.
+func (s *Server) CheckAlive(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	conn, err := client.ConnectWithDialer(ctx, "", s.Addr, s.User.Name, s.User.Password, "", dialer.DialContext)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.PingContext(ctx)
+}
+
+// ExecutedGTIDSet gets the GTID set executed by the server, @@GLOBAL.gtid_executed
+func (s *Server) ExecutedGTIDSet() (GTIDSet, error) {
+	r, err := s.Execute("SELECT @@GLOBAL.gtid_executed")
+	if err != nil {
+		return nil, err
+	}
+	str, _ := r.GetString(0, 0)
+	return ParseMysqlGTIDSet(strings.ReplaceAll(str, "\n", ""))
+}
+
+// WaitExecutedGTIDSet waits until the server executed the GTID set, or the timeout passed if not zero,
+// MySQL 5.7.5 and later.
+func (s *Server) WaitExecutedGTIDSet(gtids string, timeout time.Duration) error {
+	seconds := int64(math.Ceil(timeout.Seconds()))
+	r, err := s.Execute(fmt.Sprintf("SELECT WAIT_FOR_EXECUTED_GTID_SET('%s', %d)", gtids, seconds))
+	if err != nil {
+		return err
+	}
+	if n, _ := r.GetInt(0, 0); n != 0 {
+		return errors.Errorf("wait %s to execute %s timeout", s.Addr, gtids)
+	}
+	return nil
+}
+
+// MasterUUID gets the server UUID of the master the server replicates from
+func (s *Server) MasterUUID() (string, error) {
+	r, err := s.SlaveStatus()
+	if err != nil {
+		return "", err
+	}
+	uuid, _ := r.GetStringByName(0, "Master_UUID")
+	return uuid, nil
+}
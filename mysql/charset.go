@@ -0,0 +1,475 @@
+package mysql
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
+)
+
+// Charset is a character set of MySQL, of a connection or of a column.
+type Charset struct {
+	Name string
+	// DefaultCollationID is the collation used when none is given, e.g. by SET NAMES without COLLATE
+	DefaultCollationID uint8
+	// MaxLen is the maximum length of a character in bytes
+	MaxLen int
+	// Encoding converts the UTF-8 strings to the character set and back, nil if they are the same (utf8, utf8mb4,
+	// ascii and binary) or if no conversion is known (armscii8, dec8, geostd8, hp8, keybcs2, macce and swe7)
+	Encoding encoding.Encoding
+}
+
+// Collation is a collation of a Charset. The handshake only uses the collations with an id below 256, SET NAMES,
+// the column definitions and the table map events use them all.
+type Collation struct {
+	ID      uint16
+	Name    string
+	Charset string
+}
+
+var charsets = map[string]*Charset{
+	"armscii8": {"armscii8", 32, 1, nil},
+	"ascii":    {"ascii", 11, 1, nil},
+	"big5":     {"big5", 1, 2, traditionalchinese.Big5},
+	"binary":   {"binary", 63, 1, nil},
+	"cp1250":   {"cp1250", 26, 1, charmap.Windows1250},
+	"cp1251":   {"cp1251", 51, 1, charmap.Windows1251},
+	"cp1256":   {"cp1256", 57, 1, charmap.Windows1256},
+	"cp1257":   {"cp1257", 59, 1, charmap.Windows1257},
+	"cp850":    {"cp850", 4, 1, charmap.CodePage850},
+	"cp852":    {"cp852", 40, 1, charmap.CodePage852},
+	"cp866":    {"cp866", 36, 1, charmap.CodePage866},
+	"cp932":    {"cp932", 95, 2, japanese.ShiftJIS},
+	"dec8":     {"dec8", 3, 1, nil},
+	"eucjpms":  {"eucjpms", 97, 3, japanese.EUCJP},
+	"euckr":    {"euckr", 19, 2, korean.EUCKR},
+	"gb18030":  {"gb18030", 248, 4, simplifiedchinese.GB18030},
+	"gb2312":   {"gb2312", 24, 2, simplifiedchinese.GBK},
+	"gbk":      {"gbk", 28, 2, simplifiedchinese.GBK},
+	"geostd8":  {"geostd8", 92, 1, nil},
+	"greek":    {"greek", 25, 1, charmap.ISO8859_7},
+	"hebrew":   {"hebrew", 16, 1, charmap.ISO8859_8},
+	"hp8":      {"hp8", 6, 1, nil},
+	"keybcs2":  {"keybcs2", 37, 1, nil},
+	"koi8r":    {"koi8r", 7, 1, charmap.KOI8R},
+	"koi8u":    {"koi8u", 22, 1, charmap.KOI8U},
+	"latin1":   {"latin1", 8, 1, charmap.Windows1252}, // the latin1 of MySQL is cp1252
+	"latin2":   {"latin2", 9, 1, charmap.ISO8859_2},
+	"latin5":   {"latin5", 30, 1, charmap.ISO8859_9},
+	"latin7":   {"latin7", 41, 1, charmap.ISO8859_13},
+	"macce":    {"macce", 38, 1, nil},
+	"macroman": {"macroman", 39, 1, charmap.Macintosh},
+	"sjis":     {"sjis", 13, 2, japanese.ShiftJIS},
+	"swe7":     {"swe7", 10, 1, nil},
+	"tis620":   {"tis620", 18, 1, charmap.Windows874},
+	"ucs2":     {"ucs2", 35, 2, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)},
+	"ujis":     {"ujis", 12, 3, japanese.EUCJP},
+	"utf16":    {"utf16", 54, 4, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)},
+	"utf16le":  {"utf16le", 56, 4, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)},
+	"utf32":    {"utf32", 60, 4, utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM)},
+	"utf8":     {"utf8", 33, 3, nil},
+	"utf8mb4":  {"utf8mb4", 255, 4, nil},
+}
+
+var collations = []Collation{
+	{1, "big5_chinese_ci", "big5"},
+	{2, "latin2_czech_cs", "latin2"},
+	{3, "dec8_swedish_ci", "dec8"},
+	{4, "cp850_general_ci", "cp850"},
+	{5, "latin1_german1_ci", "latin1"},
+	{6, "hp8_english_ci", "hp8"},
+	{7, "koi8r_general_ci", "koi8r"},
+	{8, "latin1_swedish_ci", "latin1"},
+	{9, "latin2_general_ci", "latin2"},
+	{10, "swe7_swedish_ci", "swe7"},
+	{11, "ascii_general_ci", "ascii"},
+	{12, "ujis_japanese_ci", "ujis"},
+	{13, "sjis_japanese_ci", "sjis"},
+	{14, "cp1251_bulgarian_ci", "cp1251"},
+	{15, "latin1_danish_ci", "latin1"},
+	{16, "hebrew_general_ci", "hebrew"},
+	{18, "tis620_thai_ci", "tis620"},
+	{19, "euckr_korean_ci", "euckr"},
+	{20, "latin7_estonian_cs", "latin7"},
+	{21, "latin2_hungarian_ci", "latin2"},
+	{22, "koi8u_general_ci", "koi8u"},
+	{23, "cp1251_ukrainian_ci", "cp1251"},
+	{24, "gb2312_chinese_ci", "gb2312"},
+	{25, "greek_general_ci", "greek"},
+	{26, "cp1250_general_ci", "cp1250"},
+	{27, "latin2_croatian_ci", "latin2"},
+	{28, "gbk_chinese_ci", "gbk"},
+	{29, "cp1257_lithuanian_ci", "cp1257"},
+	{30, "latin5_turkish_ci", "latin5"},
+	{31, "latin1_german2_ci", "latin1"},
+	{32, "armscii8_general_ci", "armscii8"},
+	{33, "utf8_general_ci", "utf8"},
+	{34, "cp1250_czech_cs", "cp1250"},
+	{35, "ucs2_general_ci", "ucs2"},
+	{36, "cp866_general_ci", "cp866"},
+	{37, "keybcs2_general_ci", "keybcs2"},
+	{38, "macce_general_ci", "macce"},
+	{39, "macroman_general_ci", "macroman"},
+	{40, "cp852_general_ci", "cp852"},
+	{41, "latin7_general_ci", "latin7"},
+	{42, "latin7_general_cs", "latin7"},
+	{43, "macce_bin", "macce"},
+	{44, "cp1250_croatian_ci", "cp1250"},
+	{45, "utf8mb4_general_ci", "utf8mb4"},
+	{46, "utf8mb4_bin", "utf8mb4"},
+	{47, "latin1_bin", "latin1"},
+	{48, "latin1_general_ci", "latin1"},
+	{49, "latin1_general_cs", "latin1"},
+	{50, "cp1251_bin", "cp1251"},
+	{51, "cp1251_general_ci", "cp1251"},
+	{52, "cp1251_general_cs", "cp1251"},
+	{53, "macroman_bin", "macroman"},
+	{54, "utf16_general_ci", "utf16"},
+	{55, "utf16_bin", "utf16"},
+	{56, "utf16le_general_ci", "utf16le"},
+	{57, "cp1256_general_ci", "cp1256"},
+	{58, "cp1257_bin", "cp1257"},
+	{59, "cp1257_general_ci", "cp1257"},
+	{60, "utf32_general_ci", "utf32"},
+	{61, "utf32_bin", "utf32"},
+	{62, "utf16le_bin", "utf16le"},
+	{63, "binary", "binary"},
+	{64, "armscii8_bin", "armscii8"},
+	{65, "ascii_bin", "ascii"},
+	{66, "cp1250_bin", "cp1250"},
+	{67, "cp1256_bin", "cp1256"},
+	{68, "cp866_bin", "cp866"},
+	{69, "dec8_bin", "dec8"},
+	{70, "greek_bin", "greek"},
+	{71, "hebrew_bin", "hebrew"},
+	{72, "hp8_bin", "hp8"},
+	{73, "keybcs2_bin", "keybcs2"},
+	{74, "koi8r_bin", "koi8r"},
+	{75, "koi8u_bin", "koi8u"},
+	{76, "utf8_tolower_ci", "utf8"},
+	{77, "latin2_bin", "latin2"},
+	{78, "latin5_bin", "latin5"},
+	{79, "latin7_bin", "latin7"},
+	{80, "cp850_bin", "cp850"},
+	{81, "cp852_bin", "cp852"},
+	{82, "swe7_bin", "swe7"},
+	{83, "utf8_bin", "utf8"},
+	{84, "big5_bin", "big5"},
+	{85, "euckr_bin", "euckr"},
+	{86, "gb2312_bin", "gb2312"},
+	{87, "gbk_bin", "gbk"},
+	{88, "sjis_bin", "sjis"},
+	{89, "tis620_bin", "tis620"},
+	{90, "ucs2_bin", "ucs2"},
+	{91, "ujis_bin", "ujis"},
+	{92, "geostd8_general_ci", "geostd8"},
+	{93, "geostd8_bin", "geostd8"},
+	{94, "latin1_spanish_ci", "latin1"},
+	{95, "cp932_japanese_ci", "cp932"},
+	{96, "cp932_bin", "cp932"},
+	{97, "eucjpms_japanese_ci", "eucjpms"},
+	{98, "eucjpms_bin", "eucjpms"},
+	{99, "cp1250_polish_ci", "cp1250"},
+	{101, "utf16_unicode_ci", "utf16"},
+	{102, "utf16_icelandic_ci", "utf16"},
+	{103, "utf16_latvian_ci", "utf16"},
+	{104, "utf16_romanian_ci", "utf16"},
+	{105, "utf16_slovenian_ci", "utf16"},
+	{106, "utf16_polish_ci", "utf16"},
+	{107, "utf16_estonian_ci", "utf16"},
+	{108, "utf16_spanish_ci", "utf16"},
+	{109, "utf16_swedish_ci", "utf16"},
+	{110, "utf16_turkish_ci", "utf16"},
+	{111, "utf16_czech_ci", "utf16"},
+	{112, "utf16_danish_ci", "utf16"},
+	{113, "utf16_lithuanian_ci", "utf16"},
+	{114, "utf16_slovak_ci", "utf16"},
+	{115, "utf16_spanish2_ci", "utf16"},
+	{116, "utf16_roman_ci", "utf16"},
+	{117, "utf16_persian_ci", "utf16"},
+	{118, "utf16_esperanto_ci", "utf16"},
+	{119, "utf16_hungarian_ci", "utf16"},
+	{120, "utf16_sinhala_ci", "utf16"},
+	{121, "utf16_german2_ci", "utf16"},
+	{122, "utf16_croatian_ci", "utf16"},
+	{123, "utf16_unicode_520_ci", "utf16"},
+	{124, "utf16_vietnamese_ci", "utf16"},
+	{128, "ucs2_unicode_ci", "ucs2"},
+	{129, "ucs2_icelandic_ci", "ucs2"},
+	{130, "ucs2_latvian_ci", "ucs2"},
+	{131, "ucs2_romanian_ci", "ucs2"},
+	{132, "ucs2_slovenian_ci", "ucs2"},
+	{133, "ucs2_polish_ci", "ucs2"},
+	{134, "ucs2_estonian_ci", "ucs2"},
+	{135, "ucs2_spanish_ci", "ucs2"},
+	{136, "ucs2_swedish_ci", "ucs2"},
+	{137, "ucs2_turkish_ci", "ucs2"},
+	{138, "ucs2_czech_ci", "ucs2"},
+	{139, "ucs2_danish_ci", "ucs2"},
+	{140, "ucs2_lithuanian_ci", "ucs2"},
+	{141, "ucs2_slovak_ci", "ucs2"},
+	{142, "ucs2_spanish2_ci", "ucs2"},
+	{143, "ucs2_roman_ci", "ucs2"},
+	{144, "ucs2_persian_ci", "ucs2"},
+	{145, "ucs2_esperanto_ci", "ucs2"},
+	{146, "ucs2_hungarian_ci", "ucs2"},
+	{147, "ucs2_sinhala_ci", "ucs2"},
+	{148, "ucs2_german2_ci", "ucs2"},
+	{149, "ucs2_croatian_ci", "ucs2"},
+	{150, "ucs2_unicode_520_ci", "ucs2"},
+	{151, "ucs2_vietnamese_ci", "ucs2"},
+	{159, "ucs2_general_mysql500_ci", "ucs2"},
+	{160, "utf32_unicode_ci", "utf32"},
+	{161, "utf32_icelandic_ci", "utf32"},
+	{162, "utf32_latvian_ci", "utf32"},
+	{163, "utf32_romanian_ci", "utf32"},
+	{164, "utf32_slovenian_ci", "utf32"},
+	{165, "utf32_polish_ci", "utf32"},
+	{166, "utf32_estonian_ci", "utf32"},
+	{167, "utf32_spanish_ci", "utf32"},
+	{168, "utf32_swedish_ci", "utf32"},
+	{169, "utf32_turkish_ci", "utf32"},
+	{170, "utf32_czech_ci", "utf32"},
+	{171, "utf32_danish_ci", "utf32"},
+	{172, "utf32_lithuanian_ci", "utf32"},
+	{173, "utf32_slovak_ci", "utf32"},
+	{174, "utf32_spanish2_ci", "utf32"},
+	{175, "utf32_roman_ci", "utf32"},
+	{176, "utf32_persian_ci", "utf32"},
+	{177, "utf32_esperanto_ci", "utf32"},
+	{178, "utf32_hungarian_ci", "utf32"},
+	{179, "utf32_sinhala_ci", "utf32"},
+	{180, "utf32_german2_ci", "utf32"},
+	{181, "utf32_croatian_ci", "utf32"},
+	{182, "utf32_unicode_520_ci", "utf32"},
+	{183, "utf32_vietnamese_ci", "utf32"},
+	{192, "utf8_unicode_ci", "utf8"},
+	{193, "utf8_icelandic_ci", "utf8"},
+	{194, "utf8_latvian_ci", "utf8"},
+	{195, "utf8_romanian_ci", "utf8"},
+	{196, "utf8_slovenian_ci", "utf8"},
+	{197, "utf8_polish_ci", "utf8"},
+	{198, "utf8_estonian_ci", "utf8"},
+	{199, "utf8_spanish_ci", "utf8"},
+	{200, "utf8_swedish_ci", "utf8"},
+	{201, "utf8_turkish_ci", "utf8"},
+	{202, "utf8_czech_ci", "utf8"},
+	{203, "utf8_danish_ci", "utf8"},
+	{204, "utf8_lithuanian_ci", "utf8"},
+	{205, "utf8_slovak_ci", "utf8"},
+	{206, "utf8_spanish2_ci", "utf8"},
+	{207, "utf8_roman_ci", "utf8"},
+	{208, "utf8_persian_ci", "utf8"},
+	{209, "utf8_esperanto_ci", "utf8"},
+	{210, "utf8_hungarian_ci", "utf8"},
+	{211, "utf8_sinhala_ci", "utf8"},
+	{212, "utf8_german2_ci", "utf8"},
+	{213, "utf8_croatian_ci", "utf8"},
+	{214, "utf8_unicode_520_ci", "utf8"},
+	{215, "utf8_vietnamese_ci", "utf8"},
+	{223, "utf8_general_mysql500_ci", "utf8"},
+	{224, "utf8mb4_unicode_ci", "utf8mb4"},
+	{225, "utf8mb4_icelandic_ci", "utf8mb4"},
+	{226, "utf8mb4_latvian_ci", "utf8mb4"},
+	{227, "utf8mb4_romanian_ci", "utf8mb4"},
+	{228, "utf8mb4_slovenian_ci", "utf8mb4"},
+	{229, "utf8mb4_polish_ci", "utf8mb4"},
+	{230, "utf8mb4_estonian_ci", "utf8mb4"},
+	{231, "utf8mb4_spanish_ci", "utf8mb4"},
+	{232, "utf8mb4_swedish_ci", "utf8mb4"},
+	{233, "utf8mb4_turkish_ci", "utf8mb4"},
+	{234, "utf8mb4_czech_ci", "utf8mb4"},
+	{235, "utf8mb4_danish_ci", "utf8mb4"},
+	{236, "utf8mb4_lithuanian_ci", "utf8mb4"},
+	{237, "utf8mb4_slovak_ci", "utf8mb4"},
+	{238, "utf8mb4_spanish2_ci", "utf8mb4"},
+	{239, "utf8mb4_roman_ci", "utf8mb4"},
+	{240, "utf8mb4_persian_ci", "utf8mb4"},
+	{241, "utf8mb4_esperanto_ci", "utf8mb4"},
+	{242, "utf8mb4_hungarian_ci", "utf8mb4"},
+	{243, "utf8mb4_sinhala_ci", "utf8mb4"},
+	{244, "utf8mb4_german2_ci", "utf8mb4"},
+	{245, "utf8mb4_croatian_ci", "utf8mb4"},
+	{246, "utf8mb4_unicode_520_ci", "utf8mb4"},
+	{247, "utf8mb4_vietnamese_ci", "utf8mb4"},
+	{248, "gb18030_chinese_ci", "gb18030"},
+	{249, "gb18030_bin", "gb18030"},
+	{250, "gb18030_unicode_520_ci", "gb18030"},
+	{255, "utf8mb4_0900_ai_ci", "utf8mb4"},
+	{256, "utf8mb4_de_pb_0900_ai_ci", "utf8mb4"},
+	{257, "utf8mb4_is_0900_ai_ci", "utf8mb4"},
+	{258, "utf8mb4_lv_0900_ai_ci", "utf8mb4"},
+	{259, "utf8mb4_ro_0900_ai_ci", "utf8mb4"},
+	{260, "utf8mb4_sl_0900_ai_ci", "utf8mb4"},
+	{261, "utf8mb4_pl_0900_ai_ci", "utf8mb4"},
+	{262, "utf8mb4_et_0900_ai_ci", "utf8mb4"},
+	{263, "utf8mb4_es_0900_ai_ci", "utf8mb4"},
+	{264, "utf8mb4_sv_0900_ai_ci", "utf8mb4"},
+	{265, "utf8mb4_tr_0900_ai_ci", "utf8mb4"},
+	{266, "utf8mb4_cs_0900_ai_ci", "utf8mb4"},
+	{267, "utf8mb4_da_0900_ai_ci", "utf8mb4"},
+	{268, "utf8mb4_lt_0900_ai_ci", "utf8mb4"},
+	{269, "utf8mb4_sk_0900_ai_ci", "utf8mb4"},
+	{270, "utf8mb4_es_trad_0900_ai_ci", "utf8mb4"},
+	{271, "utf8mb4_la_0900_ai_ci", "utf8mb4"},
+	{273, "utf8mb4_eo_0900_ai_ci", "utf8mb4"},
+	{274, "utf8mb4_hu_0900_ai_ci", "utf8mb4"},
+	{275, "utf8mb4_hr_0900_ai_ci", "utf8mb4"},
+	{277, "utf8mb4_vi_0900_ai_ci", "utf8mb4"},
+	{278, "utf8mb4_0900_as_cs", "utf8mb4"},
+	{279, "utf8mb4_de_pb_0900_as_cs", "utf8mb4"},
+	{280, "utf8mb4_is_0900_as_cs", "utf8mb4"},
+	{281, "utf8mb4_lv_0900_as_cs", "utf8mb4"},
+	{282, "utf8mb4_ro_0900_as_cs", "utf8mb4"},
+	{283, "utf8mb4_sl_0900_as_cs", "utf8mb4"},
+	{284, "utf8mb4_pl_0900_as_cs", "utf8mb4"},
+	{285, "utf8mb4_et_0900_as_cs", "utf8mb4"},
+	{286, "utf8mb4_es_0900_as_cs", "utf8mb4"},
+	{287, "utf8mb4_sv_0900_as_cs", "utf8mb4"},
+	{288, "utf8mb4_tr_0900_as_cs", "utf8mb4"},
+	{289, "utf8mb4_cs_0900_as_cs", "utf8mb4"},
+	{290, "utf8mb4_da_0900_as_cs", "utf8mb4"},
+	{291, "utf8mb4_lt_0900_as_cs", "utf8mb4"},
+	{292, "utf8mb4_sk_0900_as_cs", "utf8mb4"},
+	{293, "utf8mb4_es_trad_0900_as_cs", "utf8mb4"},
+	{294, "utf8mb4_la_0900_as_cs", "utf8mb4"},
+	{296, "utf8mb4_eo_0900_as_cs", "utf8mb4"},
+	{297, "utf8mb4_hu_0900_as_cs", "utf8mb4"},
+	{298, "utf8mb4_hr_0900_as_cs", "utf8mb4"},
+	{300, "utf8mb4_vi_0900_as_cs", "utf8mb4"},
+	{303, "utf8mb4_ja_0900_as_cs", "utf8mb4"},
+	{304, "utf8mb4_ja_0900_as_cs_ks", "utf8mb4"},
+	{305, "utf8mb4_0900_as_ci", "utf8mb4"},
+	{306, "utf8mb4_ru_0900_ai_ci", "utf8mb4"},
+	{307, "utf8mb4_ru_0900_as_cs", "utf8mb4"},
+	{308, "utf8mb4_zh_0900_as_cs", "utf8mb4"},
+	{309, "utf8mb4_0900_bin", "utf8mb4"},
+	{310, "utf8mb4_nb_0900_ai_ci", "utf8mb4"},
+	{311, "utf8mb4_nb_0900_as_cs", "utf8mb4"},
+	{312, "utf8mb4_nn_0900_ai_ci", "utf8mb4"},
+	{313, "utf8mb4_nn_0900_as_cs", "utf8mb4"},
+	{314, "utf8mb4_sr_latn_0900_ai_ci", "utf8mb4"},
+	{315, "utf8mb4_sr_latn_0900_as_cs", "utf8mb4"},
+	{316, "utf8mb4_bs_0900_ai_ci", "utf8mb4"},
+	{317, "utf8mb4_bs_0900_as_cs", "utf8mb4"},
+	{318, "utf8mb4_bg_0900_ai_ci", "utf8mb4"},
+	{319, "utf8mb4_bg_0900_as_cs", "utf8mb4"},
+	{320, "utf8mb4_gl_0900_ai_ci", "utf8mb4"},
+	{321, "utf8mb4_gl_0900_as_cs", "utf8mb4"},
+	{322, "utf8mb4_mn_cyrl_0900_ai_ci", "utf8mb4"},
+	{323, "utf8mb4_mn_cyrl_0900_as_cs", "utf8mb4"},
+}
+
+var (
+	collationsByID   = make(map[uint16]*Collation, len(collations))
+	collationsByName = make(map[string]*Collation, len(collations))
+)
+
+func init() {
+	for i := range collations {
+		c := &collations[i]
+		collationsByID[c.ID] = c
+		collationsByName[c.Name] = c
+	}
+}
+
+// normalizeCharsetName lower cases a character set or collation name and replaces the utf8mb3 alias by utf8.
+func normalizeCharsetName(name string) string {
+	name = strings.ToLower(name)
+	if strings.HasPrefix(name, "utf8mb3") {
+		name = "utf8" + name[len("utf8mb3"):]
+	}
+	return name
+}
+
+// CharsetByName returns the character set named name, e.g. "latin1".
+func CharsetByName(name string) (*Charset, bool) {
+	cs, ok := charsets[normalizeCharsetName(name)]
+	return cs, ok
+}
+
+// CollationByName returns the collation named name, e.g. "latin1_swedish_ci".
+func CollationByName(name string) (*Collation, bool) {
+	c, ok := collationsByName[normalizeCharsetName(name)]
+	return c, ok
+}
+
+// CollationByID returns the collation of id, e.g. the charset of a Field or a collation of a table map event.
+func CollationByID(id uint16) (*Collation, bool) {
+	c, ok := collationsByID[id]
+	return c, ok
+}
+
+// CharsetByCollationID returns the character set of a collation id, as sent in the handshake response.
+func CharsetByCollationID(id uint8) (*Charset, bool) {
+	return CharsetOfCollation(uint16(id))
+}
+
+// CharsetOfCollation returns the character set of a collation id of any size, e.g. the charset of a Field.
+func CharsetOfCollation(id uint16) (*Charset, bool) {
+	if c, ok := collationsByID[id]; ok {
+		return charsets[c.Charset], true
+	}
+	return nil, false
+}
+
+// Encode converts a UTF-8 string to the character set, the characters it cannot represent are replaced by '?' as MySQL does.
+func (cs *Charset) Encode(s []byte) []byte {
+	if cs.Encoding == nil {
+		return s
+	}
+	enc := cs.Encoding.NewEncoder()
+	if b, err := enc.Bytes(s); err == nil {
+		return b
+	}
+
+	data := make([]byte, 0, len(s))
+	for len(s) > 0 {
+		_, n := utf8.DecodeRune(s)
+		b, err := enc.Bytes(s[:n])
+		if err != nil {
+			b = []byte{'?'}
+		}
+		data = append(data, b...)
+		s = s[n:]
+	}
+	return data
+}
+
+// Decode converts a string of the character set to UTF-8, the invalid sequences are replaced by U+FFFD.
+func (cs *Charset) Decode(s []byte) []byte {
+	if cs.Encoding == nil {
+		return s
+	}
+	// the decoders of x/text replace the invalid sequences instead of failing
+	b, err := cs.Encoding.NewDecoder().Bytes(s)
+	if err != nil {
+		return []byte(strings.ToValidUTF8(string(s), string(utf8.RuneError)))
+	}
+	return b
+}
+
+// EncodeString converts a UTF-8 string to the character set of the collation id, see Charset.Encode. The string
+// is returned as is if the collation is unknown.
+func EncodeString(collationID uint16, s string) string {
+	if cs, ok := CharsetOfCollation(collationID); ok && cs.Encoding != nil {
+		return string(cs.Encode([]byte(s)))
+	}
+	return s
+}
+
+// DecodeString converts a string of the character set of the collation id to UTF-8, see Charset.Decode. The
+// string is returned as is if the collation is unknown.
+func DecodeString(collationID uint16, s string) string {
+	if cs, ok := CharsetOfCollation(collationID); ok && cs.Encoding != nil {
+		return string(cs.Decode([]byte(s)))
+	}
+	return s
+}
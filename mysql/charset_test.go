@@ -0,0 +1,93 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCharsetLookup(t *testing.T) {
+	cs, ok := CharsetByName("LATIN1")
+	require.True(t, ok)
+	require.Equal(t, uint8(8), cs.DefaultCollationID)
+
+	cs, ok = CharsetByName("utf8mb3")
+	require.True(t, ok)
+	require.Equal(t, "utf8", cs.Name)
+
+	_, ok = CharsetByName("klingon")
+	require.False(t, ok)
+
+	co, ok := CollationByName("utf8mb3_bin")
+	require.True(t, ok)
+	require.Equal(t, Collation{83, "utf8_bin", "utf8"}, *co)
+
+	for id, name := range map[uint8]string{8: "latin1", 33: "utf8", 200: "utf8", 235: "utf8mb4", 255: "utf8mb4", 63: "binary"} {
+		cs, ok := CharsetByCollationID(id)
+		require.True(t, ok)
+		require.Equal(t, name, cs.Name)
+	}
+	_, ok = CharsetByCollationID(0)
+	require.False(t, ok)
+
+	co, ok = CollationByID(309)
+	require.True(t, ok)
+	require.Equal(t, Collation{309, "utf8mb4_0900_bin", "utf8mb4"}, *co)
+	cs, ok = CharsetOfCollation(co.ID)
+	require.True(t, ok)
+	require.Equal(t, 4, cs.MaxLen)
+
+	// every collation has a charset, and every default collation is of its charset
+	for _, co := range collations {
+		_, ok := charsets[co.Charset]
+		require.True(t, ok, co.Name)
+	}
+	for name, cs := range charsets {
+		co, ok := CollationByID(uint16(cs.DefaultCollationID))
+		require.True(t, ok, name)
+		require.Equal(t, name, co.Charset)
+		require.Equal(t, name, cs.Name)
+	}
+}
+
+func TestCharsetEncode(t *testing.T) {
+	cs, _ := CharsetByName("utf8mb4")
+	require.Equal(t, []byte("hé ☃"), cs.Encode([]byte("hé ☃")))
+
+	cs, _ = CharsetByName("latin1")
+	require.Equal(t, []byte("h\xe9 \x80"), cs.Encode([]byte("hé €")))
+	// not representable in latin1
+	require.Equal(t, []byte("h\xe9 ?"), cs.Encode([]byte("hé ☃")))
+
+	cs, _ = CharsetByName("gbk")
+	require.Equal(t, []byte("\xd6\xd0"), cs.Encode([]byte("中")))
+}
+
+func TestCharsetDecode(t *testing.T) {
+	for _, test := range []struct {
+		charset string
+		s       string
+		data    []byte
+	}{
+		{"latin1", "hé €", []byte("h\xe9 \x80")},
+		{"gbk", "中文", []byte("\xd6\xd0\xce\xc4")},
+		{"sjis", "日本", []byte("\x93\xfa\x96\x7b")},
+		{"cp1251", "Привет", []byte("\xcf\xf0\xe8\xe2\xe5\xf2")},
+		{"utf16", "h☃", []byte("\x00h\x26\x03")},
+		{"utf8mb4", "h☃", []byte("h\xe2\x98\x83")},
+	} {
+		cs, ok := CharsetByName(test.charset)
+		require.True(t, ok, test.charset)
+		require.Equal(t, test.data, cs.Encode([]byte(test.s)), test.charset)
+		require.Equal(t, []byte(test.s), cs.Decode(test.data), test.charset)
+	}
+
+	// an invalid sequence
+	cs, _ := CharsetByName("gbk")
+	require.Equal(t, []byte("a\ufffd"), cs.Decode([]byte("a\xd6")))
+
+	require.Equal(t, "\xe9t\xe9", EncodeString(8, "été"))
+	require.Equal(t, "été", DecodeString(8, "\xe9t\xe9"))
+	require.Equal(t, "\xe9t\xe9", DecodeString(63, "\xe9t\xe9"))
+	require.Equal(t, "été", DecodeString(0, "été"))
+}
@@ -13,8 +13,9 @@ const (
 	EOF_HEADER         byte = 0xfe
 	LocalInFile_HEADER byte = 0xfb
 
-	CACHE_SHA2_FAST_AUTH byte = 0x03
-	CACHE_SHA2_FULL_AUTH byte = 0x04
+	CACHE_SHA2_FAST_AUTH          byte = 0x03
+	CACHE_SHA2_FULL_AUTH          byte = 0x04
+	CACHE_SHA2_REQUEST_PUBLIC_KEY byte = 0x02
 )
 
 const (
@@ -23,6 +24,7 @@ const (
 	AUTH_CLEAR_PASSWORD        = "mysql_clear_password"
 	AUTH_CACHING_SHA2_PASSWORD = "caching_sha2_password"
 	AUTH_SHA256_PASSWORD       = "sha256_password"
+	AUTH_SOCKET                = "auth_socket"
 )
 
 const (
@@ -38,6 +40,18 @@ const (
 	SERVER_STATUS_METADATA_CHANGED     uint16 = 0x0400
 	SERVER_QUERY_WAS_SLOW              uint16 = 0x0800
 	SERVER_PS_OUT_PARAMS               uint16 = 0x1000
+	SERVER_STATUS_IN_TRANS_READONLY    uint16 = 0x2000
+	SERVER_SESSION_STATE_CHANGED       uint16 = 0x4000
+)
+
+// types of the session state changes sent in OK packets with CLIENT_SESSION_TRACK
+const (
+	SESSION_TRACK_SYSTEM_VARIABLES byte = iota
+	SESSION_TRACK_SCHEMA
+	SESSION_TRACK_STATE_CHANGE
+	SESSION_TRACK_GTIDS
+	SESSION_TRACK_TRANSACTION_CHARACTERISTICS
+	SESSION_TRACK_TRANSACTION_STATE
 )
 
 const (
@@ -194,6 +208,15 @@ const (
 	MYSQL_OPTION_MULTI_STATEMENTS_OFF
 )
 
+// flags of COM_STMT_EXECUTE
+// see: https://dev.mysql.com/doc/dev/mysql-server/latest/mysql__com_8h.html#a3e5e9e744ff6f7b989a604fd669977da
+const (
+	CURSOR_TYPE_NO_CURSOR  byte = 0x00
+	CURSOR_TYPE_READ_ONLY  byte = 0x01
+	CURSOR_TYPE_FOR_UPDATE byte = 0x02
+	CURSOR_TYPE_SCROLLABLE byte = 0x04
+)
+
 const (
 	MYSQL_COMPRESS_NONE = iota
 	MYSQL_COMPRESS_ZLIB
@@ -0,0 +1,261 @@
+package mysql
+
+import (
+	"database/sql/driver"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/shopspring/decimal"
+)
+
+// Decimal is a DECIMAL value, exact and with its scale, the number of digits after its decimal point, as MySQL
+// formats it: 1.50 in a DECIMAL(5,2) column is not 1.5. It is decoded by Resultset.GetMysqlDecimal, the rows events
+// with RowsDecodeOptions.MysqlDecimal and the parameters of the prepared statements by the server, and sent as a
+// DECIMAL by the prepared statements, so that a DECIMAL never passes through a float64. A decimal.Decimal is
+// accepted wherever a Decimal is, see FromDecimal, and the decimal.Decimal values returned by Resultset.GetDecimal
+// and the rows events with UseDecimal are decoded as Decimal first. The zero value is 0.
+type Decimal struct {
+	value decimal.Decimal
+	scale int32
+}
+
+// ParseDecimal parses a decimal number, e.g. -12.340, keeping its scale. The exponents, e.g. 1.2e3, are accepted.
+func ParseDecimal(s string) (Decimal, error) {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return Decimal{}, errors.Errorf("invalid decimal %q", s)
+	}
+	scale := -d.Exponent()
+	if scale < 0 {
+		scale = 0
+	}
+	return Decimal{value: d, scale: scale}, nil
+}
+
+// MustParseDecimal is ParseDecimal, panicking on an invalid number.
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// NewDecimal returns the decimal of d with a scale, d being rounded to it.
+func NewDecimal(d decimal.Decimal, scale int32) Decimal {
+	if scale < 0 {
+		scale = 0
+	}
+	return Decimal{value: d.Round(scale), scale: scale}
+}
+
+// FromDecimal returns d with the number of digits after its decimal point as its scale, e.g. 1.50 for
+// decimal.RequireFromString("1.50").
+func FromDecimal(d decimal.Decimal) Decimal {
+	return NewDecimal(d, -d.Exponent())
+}
+
+// NewDecimalFromInt returns the decimal of an integer, with a scale of 0.
+func NewDecimalFromInt(n int64) Decimal {
+	return Decimal{value: decimal.NewFromInt(n)}
+}
+
+// Decimal returns the value of d, as a decimal.Decimal.
+func (d Decimal) Decimal() decimal.Decimal {
+	return d.value
+}
+
+// Scale returns the number of digits after the decimal point of d.
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// Rescale returns d with another scale, rounded half away from zero as MySQL does when the scale is lower.
+func (d Decimal) Rescale(scale int32) Decimal {
+	return NewDecimal(d.value, scale)
+}
+
+// Cmp returns -1, 0 or +1 if d is lower than, equal to or greater than o, whatever their scale.
+func (d Decimal) Cmp(o Decimal) int {
+	return d.value.Cmp(o.value)
+}
+
+// Equal returns whether d and o are the same number, whatever their scale: 1.5 equals 1.50.
+func (d Decimal) Equal(o Decimal) bool {
+	return d.value.Equal(o.value)
+}
+
+// Sign returns -1, 0 or +1 if d is negative, zero or positive.
+func (d Decimal) Sign() int {
+	return d.value.Sign()
+}
+
+func (d Decimal) IsZero() bool {
+	return d.value.IsZero()
+}
+
+// String formats d with its scale, without exponent.
+func (d Decimal) String() string {
+	return d.value.StringFixed(d.scale)
+}
+
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Decimal) UnmarshalText(text []byte) error {
+	v, err := ParseDecimal(string(text))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// MarshalJSON encodes d as a JSON number, exact.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON decodes a JSON number or string.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	return d.UnmarshalText([]byte(s))
+}
+
+// Value implements driver.Valuer, d is passed to database/sql drivers as its string.
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// decimalDigitsBytes is the number of bytes of a group of digits in the binary format of DECIMAL.
+var decimalDigitsBytes = [10]int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// decimalBinarySize returns the size of a DECIMAL(precision, scale) in binary format: groups of 9 digits in 4 bytes
+// from the decimal point, the remaining digits in fewer bytes.
+func decimalBinarySize(precision, scale int) int {
+	intg := precision - scale
+	return intg/9*4 + decimalDigitsBytes[intg%9] + scale/9*4 + decimalDigitsBytes[scale%9]
+}
+
+// DecodeDecimalBinary decodes a DECIMAL(precision, scale) in the binary format of MySQL, as logged in the rows events
+// and stored in the JSON documents, and returns its size.
+func DecodeDecimalBinary(data []byte, precision, scale int) (Decimal, int, error) {
+	if scale < 0 || precision < scale || precision > 65 {
+		return Decimal{}, 0, errors.Errorf("invalid decimal precision %d and scale %d", precision, scale)
+	}
+	size := decimalBinarySize(precision, scale)
+	if len(data) < size {
+		return Decimal{}, 0, errors.Errorf("decimal data len %d < expected %d", len(data), size)
+	}
+
+	// the sign is the inverted first bit, and the negative numbers have all their bits inverted
+	b := append([]byte(nil), data[:size]...)
+	negative := b[0]&0x80 == 0
+	b[0] ^= 0x80
+	if negative {
+		for i := range b {
+			b[i] ^= 0xff
+		}
+	}
+
+	pos := 0
+	group := func(digits int) uint64 {
+		var v uint64
+		for _, c := range b[pos : pos+decimalDigitsBytes[digits]] {
+			v = v<<8 | uint64(c)
+		}
+		pos += decimalDigitsBytes[digits]
+		return v
+	}
+
+	var sb strings.Builder
+	if negative {
+		sb.WriteByte('-')
+	}
+	intg := precision - scale
+	sb.WriteString(strconv.FormatUint(group(intg%9), 10))
+	for i := 0; i < intg/9; i++ {
+		appendDecimalGroup(&sb, group(9), 9)
+	}
+	if scale > 0 {
+		sb.WriteByte('.')
+		for i := 0; i < scale/9; i++ {
+			appendDecimalGroup(&sb, group(9), 9)
+		}
+		if scale%9 > 0 {
+			appendDecimalGroup(&sb, group(scale%9), scale%9)
+		}
+	}
+	d, err := ParseDecimal(sb.String())
+	return d, size, err
+}
+
+func appendDecimalGroup(sb *strings.Builder, v uint64, digits int) {
+	s := strconv.FormatUint(v, 10)
+	for i := len(s); i < digits; i++ {
+		sb.WriteByte('0')
+	}
+	sb.WriteString(s)
+}
+
+// appendDecimalBinary appends d as a DECIMAL(precision, scale) in binary format, see DecodeDecimalBinary.
+func appendDecimalBinary(buf []byte, d Decimal, precision, scale int) ([]byte, error) {
+	digits := strings.TrimPrefix(d.Rescale(int32(scale)).String(), "-")
+	intDigits, fracDigits := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		intDigits, fracDigits = digits[:i], digits[i+1:]
+	}
+	intDigits = strings.TrimLeft(intDigits, "0")
+	intg := precision - scale
+	if scale < 0 || precision < 1 || intg < 0 || len(intDigits) > intg {
+		return nil, errors.Errorf("decimal %s out of the range of DECIMAL(%d,%d)", d, precision, scale)
+	}
+	intDigits = strings.Repeat("0", intg-len(intDigits)) + intDigits
+
+	start := len(buf)
+	group := func(s string) {
+		v, _ := strconv.ParseUint(s, 10, 32)
+		for i := decimalDigitsBytes[len(s)] - 1; i >= 0; i-- {
+			buf = append(buf, byte(v>>(8*uint(i))))
+		}
+	}
+	if n := intg % 9; n > 0 {
+		group(intDigits[:n])
+	}
+	for i := intg % 9; i < intg; i += 9 {
+		group(intDigits[i : i+9])
+	}
+	for i := 0; i < scale; i += 9 {
+		if i+9 > scale {
+			group(fracDigits[i:])
+			break
+		}
+		group(fracDigits[i : i+9])
+	}
+
+	if d.Sign() < 0 {
+		for i := start; i < len(buf); i++ {
+			buf[i] ^= 0xff
+		}
+	}
+	buf[start] ^= 0x80
+	return buf, nil
+}
+
+// decimalPrecision returns the number of digits of d with its scale, at least 1.
+func decimalPrecision(d Decimal) int {
+	digits := strings.TrimPrefix(d.String(), "-")
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		digits = digits[:i]
+	}
+	precision := len(strings.TrimLeft(digits, "0")) + int(d.Scale())
+	if precision == 0 {
+		precision = 1
+	}
+	return precision
+}
@@ -0,0 +1,90 @@
+package mysql
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecimal(t *testing.T) {
+	for _, test := range []struct {
+		s      string
+		expect string
+		scale  int32
+	}{
+		{"0", "0", 0},
+		{"1.50", "1.50", 2},
+		{"-12345678901234567890.123456789012345678", "-12345678901234567890.123456789012345678", 18},
+		{"0.000", "0.000", 3},
+		{"1.2e3", "1200", 0},
+		{"1.25e-1", "0.125", 3},
+	} {
+		d, err := ParseDecimal(test.s)
+		require.NoError(t, err, test.s)
+		require.Equal(t, test.expect, d.String())
+		require.Equal(t, test.scale, d.Scale())
+	}
+	for _, s := range []string{"", "abc", "1.2.3", "0x10"} {
+		_, err := ParseDecimal(s)
+		require.Error(t, err, s)
+	}
+
+	// the zero value is 0
+	var zero Decimal
+	require.Equal(t, "0", zero.String())
+	require.True(t, zero.IsZero())
+	require.True(t, zero.Equal(MustParseDecimal("0.00")))
+
+	// the comparisons ignore the scale
+	a, b := MustParseDecimal("1.5"), MustParseDecimal("1.50")
+	require.True(t, a.Equal(b))
+	require.Zero(t, a.Cmp(b))
+	require.NotEqual(t, a.String(), b.String())
+	require.Equal(t, -1, MustParseDecimal("-0.01").Cmp(zero))
+	require.Equal(t, 1, MustParseDecimal("100000000000000000000.1").Cmp(MustParseDecimal("100000000000000000000")))
+	require.Equal(t, -1, MustParseDecimal("-2").Sign())
+
+	require.Equal(t, "2.35", MustParseDecimal("2.345").Rescale(2).String())
+	require.Equal(t, "-2.35", MustParseDecimal("-2.345").Rescale(2).String())
+	require.Equal(t, "2.3450", MustParseDecimal("2.345").Rescale(4).String())
+	require.Equal(t, "3.10", NewDecimal(decimal.RequireFromString("3.1"), 2).String())
+	require.Equal(t, "-42", NewDecimalFromInt(-42).String())
+	require.Equal(t, "1.50", FromDecimal(decimal.RequireFromString("1.50")).String())
+	require.Equal(t, "1200", FromDecimal(decimal.New(12, 2)).String())
+
+	// JSON numbers keep their precision, strings are accepted
+	data, err := json.Marshal(map[string]Decimal{"d": MustParseDecimal("12345678901234567890.10")})
+	require.NoError(t, err)
+	require.Equal(t, `{"d":12345678901234567890.10}`, string(data))
+	var v map[string]Decimal
+	require.NoError(t, json.Unmarshal(data, &v))
+	require.Equal(t, "12345678901234567890.10", v["d"].String())
+	require.NoError(t, json.Unmarshal([]byte(`{"d":"-0.5"}`), &v))
+	require.Equal(t, "-0.5", v["d"].String())
+	require.Error(t, json.Unmarshal([]byte(`{"d":"x"}`), &v))
+
+	dv, err := MustParseDecimal("1.10").Value()
+	require.NoError(t, err)
+	require.Equal(t, "1.10", dv)
+}
+
+func TestBuildDecimalResultset(t *testing.T) {
+	for _, binary := range []bool{false, true} {
+		for _, d := range []interface{}{MustParseDecimal("-1.50"), decimal.RequireFromString("-1.50")} {
+			r, err := BuildSimpleResultset([]string{"d"}, [][]interface{}{{d}}, binary)
+			require.NoError(t, err)
+			require.Equal(t, uint8(MYSQL_TYPE_NEWDECIMAL), r.Fields[0].Type)
+			require.Equal(t, uint8(2), r.Fields[0].Decimal)
+
+			values, err := r.RowDatas[0].Parse(r.Fields, binary, nil)
+			require.NoError(t, err)
+			require.Equal(t, "-1.50", string(values[0].AsString()))
+
+			v, err := (&Resultset{Fields: r.Fields, Values: [][]FieldValue{values}}).GetMysqlDecimal(0, 0)
+			require.NoError(t, err)
+			require.Equal(t, "-1.50", v.String())
+		}
+	}
+}
@@ -154,24 +154,34 @@ func (f *Field) Dump() []byte {
 
 	l := len(f.Schema) + len(f.Table) + len(f.OrgTable) + len(f.Name) + len(f.OrgName) + len(f.DefaultValue) + 48
 
-	data := make([]byte, 0, l)
+	return f.AppendDump(make([]byte, 0, l))
+}
+
+// AppendDump appends the column definition encoded by Dump to data.
+func (f *Field) AppendDump(data []byte) []byte {
+	if f == nil {
+		f = &Field{}
+	}
+	if f.Data != nil {
+		return append(data, f.Data...)
+	}
 
-	data = append(data, PutLengthEncodedString([]byte("def"))...)
+	data = AppendLengthEncodedString(data, []byte("def"))
 
-	data = append(data, PutLengthEncodedString(f.Schema)...)
+	data = AppendLengthEncodedString(data, f.Schema)
 
-	data = append(data, PutLengthEncodedString(f.Table)...)
-	data = append(data, PutLengthEncodedString(f.OrgTable)...)
+	data = AppendLengthEncodedString(data, f.Table)
+	data = AppendLengthEncodedString(data, f.OrgTable)
 
-	data = append(data, PutLengthEncodedString(f.Name)...)
-	data = append(data, PutLengthEncodedString(f.OrgName)...)
+	data = AppendLengthEncodedString(data, f.Name)
+	data = AppendLengthEncodedString(data, f.OrgName)
 
 	data = append(data, 0x0c)
 
-	data = append(data, Uint16ToBytes(f.Charset)...)
-	data = append(data, Uint32ToBytes(f.ColumnLength)...)
+	data = append(data, byte(f.Charset), byte(f.Charset>>8))
+	data = append(data, byte(f.ColumnLength), byte(f.ColumnLength>>8), byte(f.ColumnLength>>16), byte(f.ColumnLength>>24))
 	data = append(data, f.Type)
-	data = append(data, Uint16ToBytes(f.Flag)...)
+	data = append(data, byte(f.Flag), byte(f.Flag>>8))
 	data = append(data, f.Decimal)
 	data = append(data, 0, 0)
 
@@ -0,0 +1,769 @@
+package mysql
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// the geometry types of WKB
+const (
+	wkbPoint uint32 = iota + 1
+	wkbLineString
+	wkbPolygon
+	wkbMultiPoint
+	wkbMultiLineString
+	wkbMultiPolygon
+	wkbGeometryCollection
+)
+
+// Geometry is a value of a spatial column, GEOMETRY, POINT, POLYGON, etc., with its spatial reference system. MySQL
+// stores and sends it as the SRID, 4 bytes little endian, followed by the WKB of the shape, see ParseGeometry and
+// Bytes. It is decoded by Resultset.GetGeometry and the rows events with RowsDecodeOptions.Geometry, and sent as these
+// bytes by the prepared statements. The zero value, without shape, is NULL.
+type Geometry struct {
+	SRID  uint32
+	Shape Shape
+}
+
+// Shape is the shape of a geometry: Point, LineString, Polygon, MultiPoint, MultiLineString, MultiPolygon or
+// GeometryCollection.
+type Shape interface {
+	appendWKB(buf []byte) []byte
+	appendWKT(buf []byte) []byte
+	geoJSON() map[string]interface{}
+}
+
+// Point is a point, X being the longitude and Y the latitude in a geographic spatial reference system.
+type Point struct {
+	X, Y float64
+}
+
+// LineString is a curve of points.
+type LineString []Point
+
+// Polygon is a polygon, its exterior ring followed by its interior rings, closed line strings.
+type Polygon []LineString
+
+type MultiPoint []Point
+
+type MultiLineString []LineString
+
+type MultiPolygon []Polygon
+
+type GeometryCollection []Shape
+
+// NewGeometry returns the geometry of a shape in a spatial reference system, 0 for the Cartesian plane.
+func NewGeometry(srid uint32, shape Shape) Geometry {
+	return Geometry{SRID: srid, Shape: shape}
+}
+
+// ParseGeometry parses a geometry as stored by MySQL, its SRID followed by its WKB.
+func ParseGeometry(data []byte) (Geometry, error) {
+	if len(data) < 4 {
+		return Geometry{}, errors.Errorf("invalid geometry of %d bytes", len(data))
+	}
+	shape, err := ParseWKB(data[4:])
+	if err != nil {
+		return Geometry{}, err
+	}
+	return Geometry{SRID: binary.LittleEndian.Uint32(data), Shape: shape}, nil
+}
+
+// Bytes returns g as stored by MySQL, its SRID followed by its WKB, nil without shape.
+func (g Geometry) Bytes() []byte {
+	if g.Shape == nil {
+		return nil
+	}
+	return g.Shape.appendWKB(Uint32ToBytes(g.SRID))
+}
+
+// WKB returns the well-known binary of the shape of g, in little endian.
+func (g Geometry) WKB() []byte {
+	if g.Shape == nil {
+		return nil
+	}
+	return g.Shape.appendWKB(nil)
+}
+
+// WKT returns the well-known text of the shape of g as formatted by MySQL 8.0, e.g. POINT(1 2) or
+// MULTIPOINT((0 0),(1 1)).
+func (g Geometry) WKT() string {
+	if g.Shape == nil {
+		return ""
+	}
+	return string(g.Shape.appendWKT(nil))
+}
+
+func (g Geometry) String() string {
+	return g.WKT()
+}
+
+// GeoJSON returns the GeoJSON geometry of the shape of g, null without shape.
+func (g Geometry) GeoJSON() (json.RawMessage, error) {
+	if g.Shape == nil {
+		return json.RawMessage("null"), nil
+	}
+	data, err := json.Marshal(g.Shape.geoJSON())
+	return data, errors.Trace(err)
+}
+
+func (g Geometry) MarshalJSON() ([]byte, error) {
+	return g.GeoJSON()
+}
+
+// UnmarshalJSON sets the shape of g to a GeoJSON geometry, its SRID is kept.
+func (g *Geometry) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		g.Shape = nil
+		return nil
+	}
+	shape, err := ParseGeoJSON(data)
+	if err != nil {
+		return err
+	}
+	g.Shape = shape
+	return nil
+}
+
+// Value implements driver.Valuer, g is passed to database/sql drivers as its bytes.
+func (g Geometry) Value() (driver.Value, error) {
+	if g.Shape == nil {
+		return nil, nil
+	}
+	return g.Bytes(), nil
+}
+
+// Scan implements sql.Scanner for the values of the spatial columns.
+func (g *Geometry) Scan(src interface{}) error {
+	var err error
+	switch v := src.(type) {
+	case nil:
+		*g = Geometry{}
+	case []byte:
+		*g, err = ParseGeometry(v)
+	case string:
+		*g, err = ParseGeometry([]byte(v))
+	default:
+		err = errors.Errorf("cannot scan %T into a geometry", src)
+	}
+	return err
+}
+
+func appendWKBHeader(buf []byte, tp uint32, n int) []byte {
+	buf = append(buf, 1)
+	buf = append(buf, Uint32ToBytes(tp)...)
+	if tp != wkbPoint {
+		buf = append(buf, Uint32ToBytes(uint32(n))...)
+	}
+	return buf
+}
+
+func appendWKBPoints(buf []byte, points []Point) []byte {
+	for _, p := range points {
+		buf = append(buf, Uint64ToBytes(math.Float64bits(p.X))...)
+		buf = append(buf, Uint64ToBytes(math.Float64bits(p.Y))...)
+	}
+	return buf
+}
+
+func appendWKBRings(buf []byte, rings []LineString) []byte {
+	for _, ring := range rings {
+		buf = append(buf, Uint32ToBytes(uint32(len(ring)))...)
+		buf = appendWKBPoints(buf, ring)
+	}
+	return buf
+}
+
+func (p Point) appendWKB(buf []byte) []byte {
+	return appendWKBPoints(appendWKBHeader(buf, wkbPoint, 0), []Point{p})
+}
+
+func (l LineString) appendWKB(buf []byte) []byte {
+	return appendWKBPoints(appendWKBHeader(buf, wkbLineString, len(l)), l)
+}
+
+func (p Polygon) appendWKB(buf []byte) []byte {
+	return appendWKBRings(appendWKBHeader(buf, wkbPolygon, len(p)), p)
+}
+
+func (m MultiPoint) appendWKB(buf []byte) []byte {
+	buf = appendWKBHeader(buf, wkbMultiPoint, len(m))
+	for _, p := range m {
+		buf = p.appendWKB(buf)
+	}
+	return buf
+}
+
+func (m MultiLineString) appendWKB(buf []byte) []byte {
+	buf = appendWKBHeader(buf, wkbMultiLineString, len(m))
+	for _, l := range m {
+		buf = l.appendWKB(buf)
+	}
+	return buf
+}
+
+func (m MultiPolygon) appendWKB(buf []byte) []byte {
+	buf = appendWKBHeader(buf, wkbMultiPolygon, len(m))
+	for _, p := range m {
+		buf = p.appendWKB(buf)
+	}
+	return buf
+}
+
+func (c GeometryCollection) appendWKB(buf []byte) []byte {
+	buf = appendWKBHeader(buf, wkbGeometryCollection, len(c))
+	for _, s := range c {
+		buf = s.appendWKB(buf)
+	}
+	return buf
+}
+
+// ParseWKB parses the well-known binary of a shape, in little or big endian.
+func ParseWKB(data []byte) (Shape, error) {
+	r := wkbReader{data: data}
+	shape := r.shape(0)
+	if r.err == nil && r.pos != len(data) {
+		r.err = errors.Errorf("invalid WKB, %d bytes after the geometry", len(data)-r.pos)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return shape, nil
+}
+
+type wkbReader struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+	err   error
+}
+
+func (r *wkbReader) read(n int) []byte {
+	if r.err == nil && len(r.data)-r.pos < n {
+		r.err = errors.Errorf("invalid WKB, data len %d < expected %d", len(r.data)-r.pos, n)
+	}
+	if r.err != nil {
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *wkbReader) uint32() uint32 {
+	if b := r.read(4); b != nil {
+		return r.order.Uint32(b)
+	}
+	return 0
+}
+
+// count reads a number of elements of at least size bytes each.
+func (r *wkbReader) count(size int) int {
+	n := int(r.uint32())
+	if r.err == nil && n > (len(r.data)-r.pos)/size {
+		r.err = errors.Errorf("invalid WKB, %d elements in %d bytes", n, len(r.data)-r.pos)
+	}
+	if r.err != nil {
+		return 0
+	}
+	return n
+}
+
+func (r *wkbReader) points(n int) []Point {
+	points := make([]Point, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		if b := r.read(16); b != nil {
+			points = append(points, Point{
+				X: math.Float64frombits(r.order.Uint64(b)),
+				Y: math.Float64frombits(r.order.Uint64(b[8:])),
+			})
+		}
+	}
+	return points
+}
+
+func (r *wkbReader) rings() []LineString {
+	n := r.count(4)
+	rings := make([]LineString, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		rings = append(rings, r.points(r.count(16)))
+	}
+	return rings
+}
+
+// shape reads a shape, of the type expected in a multi geometry if not 0.
+func (r *wkbReader) shape(expected uint32) Shape {
+	b := r.read(1)
+	if b == nil {
+		return nil
+	}
+	switch b[0] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		r.err = errors.Errorf("invalid WKB byte order %d", b[0])
+		return nil
+	}
+
+	tp := r.uint32()
+	if r.err == nil && expected != 0 && tp != expected {
+		r.err = errors.Errorf("invalid WKB, geometry type %d in a multi geometry of type %d", tp, expected)
+	}
+	if r.err != nil {
+		return nil
+	}
+
+	switch tp {
+	case wkbPoint:
+		if points := r.points(1); len(points) == 1 {
+			return points[0]
+		}
+		return nil
+	case wkbLineString:
+		return LineString(r.points(r.count(16)))
+	case wkbPolygon:
+		return Polygon(r.rings())
+	case wkbMultiPoint:
+		n := r.count(21)
+		m := make(MultiPoint, 0, n)
+		for i := 0; i < n && r.err == nil; i++ {
+			if p, ok := r.shape(wkbPoint).(Point); ok {
+				m = append(m, p)
+			}
+		}
+		return m
+	case wkbMultiLineString:
+		n := r.count(9)
+		m := make(MultiLineString, 0, n)
+		for i := 0; i < n && r.err == nil; i++ {
+			if l, ok := r.shape(wkbLineString).(LineString); ok {
+				m = append(m, l)
+			}
+		}
+		return m
+	case wkbMultiPolygon:
+		n := r.count(9)
+		m := make(MultiPolygon, 0, n)
+		for i := 0; i < n && r.err == nil; i++ {
+			if p, ok := r.shape(wkbPolygon).(Polygon); ok {
+				m = append(m, p)
+			}
+		}
+		return m
+	case wkbGeometryCollection:
+		n := r.count(9)
+		c := make(GeometryCollection, 0, n)
+		for i := 0; i < n && r.err == nil; i++ {
+			if s := r.shape(0); s != nil {
+				c = append(c, s)
+			}
+		}
+		return c
+	}
+	r.err = errors.Errorf("invalid WKB geometry type %d", tp)
+	return nil
+}
+
+func appendWKTPoints(buf []byte, points []Point) []byte {
+	buf = append(buf, '(')
+	for i, p := range points {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = strconv.AppendFloat(buf, p.X, 'g', -1, 64)
+		buf = append(buf, ' ')
+		buf = strconv.AppendFloat(buf, p.Y, 'g', -1, 64)
+	}
+	return append(buf, ')')
+}
+
+func appendWKTRings(buf []byte, rings []LineString) []byte {
+	buf = append(buf, '(')
+	for i, ring := range rings {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendWKTPoints(buf, ring)
+	}
+	return append(buf, ')')
+}
+
+func (p Point) appendWKT(buf []byte) []byte {
+	return appendWKTPoints(append(buf, "POINT"...), []Point{p})
+}
+
+func (l LineString) appendWKT(buf []byte) []byte {
+	return appendWKTPoints(append(buf, "LINESTRING"...), l)
+}
+
+func (p Polygon) appendWKT(buf []byte) []byte {
+	return appendWKTRings(append(buf, "POLYGON"...), p)
+}
+
+func (m MultiPoint) appendWKT(buf []byte) []byte {
+	buf = append(buf, "MULTIPOINT("...)
+	for i, p := range m {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendWKTPoints(buf, []Point{p})
+	}
+	return append(buf, ')')
+}
+
+func (m MultiLineString) appendWKT(buf []byte) []byte {
+	return appendWKTRings(append(buf, "MULTILINESTRING"...), m)
+}
+
+func (m MultiPolygon) appendWKT(buf []byte) []byte {
+	buf = append(buf, "MULTIPOLYGON("...)
+	for i, p := range m {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendWKTRings(buf, p)
+	}
+	return append(buf, ')')
+}
+
+func (c GeometryCollection) appendWKT(buf []byte) []byte {
+	if len(c) == 0 {
+		return append(buf, "GEOMETRYCOLLECTION EMPTY"...)
+	}
+	buf = append(buf, "GEOMETRYCOLLECTION("...)
+	for i, s := range c {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = s.appendWKT(buf)
+	}
+	return append(buf, ')')
+}
+
+// ParseWKT parses the well-known text of a shape, e.g. POINT(1 2), case-insensitive. The multipoints are accepted with
+// or without parentheses around their points, as formatted by MySQL 8.0 and 5.7, and the empty collections as
+// GEOMETRYCOLLECTION EMPTY or GEOMETRYCOLLECTION().
+func ParseWKT(s string) (Shape, error) {
+	p := wktParser{s: s}
+	shape := p.shape()
+	if p.err == nil {
+		p.skipSpaces()
+		if p.pos != len(p.s) {
+			p.fail("end of text")
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return shape, nil
+}
+
+type wktParser struct {
+	s   string
+	pos int
+	err error
+}
+
+func (p *wktParser) fail(expected string) {
+	if p.err == nil {
+		p.err = errors.Errorf("invalid WKT %q, %s expected at offset %d", p.s, expected, p.pos)
+	}
+}
+
+func (p *wktParser) skipSpaces() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// peek returns whether the next character is c, after the spaces.
+func (p *wktParser) peek(c byte) bool {
+	p.skipSpaces()
+	return p.err == nil && p.pos < len(p.s) && p.s[p.pos] == c
+}
+
+func (p *wktParser) expect(c byte) {
+	if !p.peek(c) {
+		p.fail(strconv.Quote(string(c)))
+		return
+	}
+	p.pos++
+}
+
+func (p *wktParser) word() string {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.s) && (p.s[p.pos] >= 'a' && p.s[p.pos] <= 'z' || p.s[p.pos] >= 'A' && p.s[p.pos] <= 'Z') {
+		p.pos++
+	}
+	return strings.ToUpper(p.s[start:p.pos])
+}
+
+// empty reads EMPTY if it is next.
+func (p *wktParser) empty() bool {
+	pos := p.pos
+	if p.word() == "EMPTY" {
+		return true
+	}
+	p.pos = pos
+	return false
+}
+
+func (p *wktParser) number() float64 {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte("+-.0123456789eE", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	f, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		p.fail("number")
+	}
+	return f
+}
+
+func (p *wktParser) point() Point {
+	return Point{X: p.number(), Y: p.number()}
+}
+
+// list reads a list in parentheses, calling item for each of its items.
+func (p *wktParser) list(item func()) {
+	p.expect('(')
+	for p.err == nil {
+		item()
+		if !p.peek(',') {
+			break
+		}
+		p.pos++
+	}
+	p.expect(')')
+}
+
+func (p *wktParser) points() []Point {
+	var points []Point
+	p.list(func() { points = append(points, p.point()) })
+	return points
+}
+
+func (p *wktParser) rings() []LineString {
+	var rings []LineString
+	p.list(func() { rings = append(rings, p.points()) })
+	return rings
+}
+
+func (p *wktParser) shape() Shape {
+	tag := p.word()
+	if tag == "POINT" {
+		var point Point
+		p.list(func() { point = p.point() })
+		return point
+	}
+
+	empty := p.empty()
+	switch tag {
+	case "LINESTRING":
+		if empty {
+			return LineString{}
+		}
+		return LineString(p.points())
+	case "POLYGON":
+		if empty {
+			return Polygon{}
+		}
+		return Polygon(p.rings())
+	case "MULTIPOINT":
+		m := MultiPoint{}
+		if !empty {
+			p.list(func() {
+				if p.peek('(') {
+					p.list(func() { m = append(m, p.point()) })
+				} else {
+					m = append(m, p.point())
+				}
+			})
+		}
+		return m
+	case "MULTILINESTRING":
+		if empty {
+			return MultiLineString{}
+		}
+		return MultiLineString(p.rings())
+	case "MULTIPOLYGON":
+		m := MultiPolygon{}
+		if !empty {
+			p.list(func() { m = append(m, p.rings()) })
+		}
+		return m
+	case "GEOMETRYCOLLECTION":
+		c := GeometryCollection{}
+		if empty {
+			return c
+		}
+		start := p.pos
+		if p.expect('('); p.peek(')') {
+			p.pos++
+			return c
+		}
+		p.pos = start
+		p.list(func() {
+			if s := p.shape(); s != nil {
+				c = append(c, s)
+			}
+		})
+		return c
+	}
+	p.fail("geometry type")
+	return nil
+}
+
+func geoJSONPoints(points []Point) [][]float64 {
+	coordinates := make([][]float64, len(points))
+	for i, p := range points {
+		coordinates[i] = []float64{p.X, p.Y}
+	}
+	return coordinates
+}
+
+func geoJSONRings(rings []LineString) [][][]float64 {
+	coordinates := make([][][]float64, len(rings))
+	for i, ring := range rings {
+		coordinates[i] = geoJSONPoints(ring)
+	}
+	return coordinates
+}
+
+func (p Point) geoJSON() map[string]interface{} {
+	return map[string]interface{}{"type": "Point", "coordinates": []float64{p.X, p.Y}}
+}
+
+func (l LineString) geoJSON() map[string]interface{} {
+	return map[string]interface{}{"type": "LineString", "coordinates": geoJSONPoints(l)}
+}
+
+func (p Polygon) geoJSON() map[string]interface{} {
+	return map[string]interface{}{"type": "Polygon", "coordinates": geoJSONRings(p)}
+}
+
+func (m MultiPoint) geoJSON() map[string]interface{} {
+	return map[string]interface{}{"type": "MultiPoint", "coordinates": geoJSONPoints(m)}
+}
+
+func (m MultiLineString) geoJSON() map[string]interface{} {
+	return map[string]interface{}{"type": "MultiLineString", "coordinates": geoJSONRings(m)}
+}
+
+func (m MultiPolygon) geoJSON() map[string]interface{} {
+	coordinates := make([][][][]float64, len(m))
+	for i, p := range m {
+		coordinates[i] = geoJSONRings(p)
+	}
+	return map[string]interface{}{"type": "MultiPolygon", "coordinates": coordinates}
+}
+
+func (c GeometryCollection) geoJSON() map[string]interface{} {
+	geometries := make([]map[string]interface{}, len(c))
+	for i, s := range c {
+		geometries[i] = s.geoJSON()
+	}
+	return map[string]interface{}{"type": "GeometryCollection", "geometries": geometries}
+}
+
+// ParseGeoJSON parses a GeoJSON geometry, the altitudes of its positions are ignored.
+func ParseGeoJSON(data []byte) (Shape, error) {
+	var g struct {
+		Type        string            `json:"type"`
+		Coordinates json.RawMessage   `json:"coordinates"`
+		Geometries  []json.RawMessage `json:"geometries"`
+	}
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	var err error
+	switch g.Type {
+	case "Point":
+		var c []float64
+		if err = json.Unmarshal(g.Coordinates, &c); err == nil {
+			var points []Point
+			if points, err = geoJSONToPoints([][]float64{c}); err == nil {
+				return points[0], nil
+			}
+		}
+	case "LineString", "MultiPoint":
+		var c [][]float64
+		if err = json.Unmarshal(g.Coordinates, &c); err == nil {
+			var points []Point
+			if points, err = geoJSONToPoints(c); err == nil {
+				if g.Type == "MultiPoint" {
+					return MultiPoint(points), nil
+				}
+				return LineString(points), nil
+			}
+		}
+	case "Polygon", "MultiLineString":
+		var c [][][]float64
+		if err = json.Unmarshal(g.Coordinates, &c); err == nil {
+			var rings []LineString
+			if rings, err = geoJSONToRings(c); err == nil {
+				if g.Type == "MultiLineString" {
+					return MultiLineString(rings), nil
+				}
+				return Polygon(rings), nil
+			}
+		}
+	case "MultiPolygon":
+		var c [][][][]float64
+		if err = json.Unmarshal(g.Coordinates, &c); err == nil {
+			m := make(MultiPolygon, len(c))
+			for i := 0; i < len(c) && err == nil; i++ {
+				m[i], err = geoJSONToRings(c[i])
+			}
+			if err == nil {
+				return m, nil
+			}
+		}
+	case "GeometryCollection":
+		c := make(GeometryCollection, len(g.Geometries))
+		for i, data := range g.Geometries {
+			if c[i], err = ParseGeoJSON(data); err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+	default:
+		return nil, errors.Errorf("invalid GeoJSON geometry type %q", g.Type)
+	}
+	return nil, errors.Annotatef(err, "invalid GeoJSON %s", g.Type)
+}
+
+func geoJSONToPoints(coordinates [][]float64) ([]Point, error) {
+	points := make([]Point, len(coordinates))
+	for i, c := range coordinates {
+		if len(c) < 2 {
+			return nil, errors.Errorf("invalid GeoJSON position %v", c)
+		}
+		points[i] = Point{X: c[0], Y: c[1]}
+	}
+	return points, nil
+}
+
+func geoJSONToRings(coordinates [][][]float64) ([]LineString, error) {
+	rings := make([]LineString, len(coordinates))
+	for i, c := range coordinates {
+		points, err := geoJSONToPoints(c)
+		if err != nil {
+			return nil, err
+		}
+		rings[i] = points
+	}
+	return rings, nil
+}
@@ -0,0 +1,125 @@
+package mysql
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeometry(t *testing.T) {
+	// SELECT HEX(ST_GeomFromText('POINT(1 2)', 4326, 'axis-order=long-lat'))
+	data, err := hex.DecodeString("E61000000101000000000000000000F03F0000000000000040")
+	require.NoError(t, err)
+	g, err := ParseGeometry(data)
+	require.NoError(t, err)
+	require.Equal(t, NewGeometry(4326, Point{X: 1, Y: 2}), g)
+	require.Equal(t, data, g.Bytes())
+	require.Equal(t, data[4:], g.WKB())
+	require.Equal(t, "POINT(1 2)", g.String())
+
+	for _, wkt := range []string{
+		"POINT(-1.5 2e+20)",
+		"LINESTRING(0 0,1 1,2 0)",
+		"POLYGON((0 0,10 0,10 10,0 10,0 0),(2 2,3 2,3 3,2 2))",
+		"MULTIPOINT((0 0),(1 1))",
+		"MULTILINESTRING((0 0,1 1),(2 2,3 3))",
+		"MULTIPOLYGON(((0 0,1 0,1 1,0 0)),((5 5,6 5,6 6,5 5)))",
+		"GEOMETRYCOLLECTION(POINT(1 1),LINESTRING(0 0,1 1),GEOMETRYCOLLECTION(POINT(2 2)))",
+		"GEOMETRYCOLLECTION EMPTY",
+	} {
+		shape, err := ParseWKT(wkt)
+		require.NoError(t, err, wkt)
+		g := NewGeometry(0, shape)
+		require.Equal(t, wkt, g.WKT())
+
+		parsed, err := ParseGeometry(g.Bytes())
+		require.NoError(t, err, wkt)
+		require.Equal(t, g, parsed)
+
+		data, err := json.Marshal(g)
+		require.NoError(t, err, wkt)
+		var fromJSON Geometry
+		require.NoError(t, json.Unmarshal(data, &fromJSON), wkt)
+		require.Equal(t, g, fromJSON)
+	}
+
+	// the WKT of MySQL 5.7 and other spellings
+	for wkt, expected := range map[string]string{
+		"multipoint(0 0, 1 1)":             "MULTIPOINT((0 0),(1 1))",
+		" Point ( 1  2 ) ":                 "POINT(1 2)",
+		"GEOMETRYCOLLECTION()":             "GEOMETRYCOLLECTION EMPTY",
+		"GEOMETRYCOLLECTION( POINT(1 1) )": "GEOMETRYCOLLECTION(POINT(1 1))",
+	} {
+		shape, err := ParseWKT(wkt)
+		require.NoError(t, err, wkt)
+		require.Equal(t, expected, NewGeometry(0, shape).WKT())
+	}
+	for _, wkt := range []string{"", "POINT(1)", "POINT(1 2", "CIRCLE(1 2)", "POINT(1 2) x", "LINESTRING(0 0,)"} {
+		_, err := ParseWKT(wkt)
+		require.Error(t, err, wkt)
+	}
+
+	data, err = NewGeometry(0, Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}).GeoJSON()
+	require.NoError(t, err)
+	require.Equal(t, `{"coordinates":[[[0,0],[1,0],[1,1],[0,0]]],"type":"Polygon"}`, string(data))
+	shape, err := ParseGeoJSON([]byte(`{"type":"Point","coordinates":[1,2,3]}`))
+	require.NoError(t, err)
+	require.Equal(t, Point{X: 1, Y: 2}, shape)
+	_, err = ParseGeoJSON([]byte(`{"type":"Point","coordinates":[1]}`))
+	require.Error(t, err)
+	_, err = ParseGeoJSON([]byte(`{"type":"Feature"}`))
+	require.Error(t, err)
+
+	// big endian WKB
+	shape, err = ParseWKB([]byte{0, 0, 0, 0, 1, 0x3f, 0xf0, 0, 0, 0, 0, 0, 0, 0x40, 0, 0, 0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	require.Equal(t, Point{X: 1, Y: 2}, shape)
+
+	for _, data := range [][]byte{
+		nil,
+		{1, 2, 0, 0},
+		data[:10],
+		append(append([]byte(nil), data...), 0),
+		// a linestring of 1000000 points in 4 bytes
+		{0, 0, 0, 0, 1, 2, 0, 0, 0, 0x40, 0x42, 0x0f, 0},
+		// a multipoint of a linestring
+		{0, 0, 0, 0, 1, 4, 0, 0, 0, 1, 0, 0, 0, 1, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+	} {
+		_, err := ParseGeometry(data)
+		require.Error(t, err, "%x", data)
+	}
+
+	var scanned Geometry
+	require.NoError(t, scanned.Scan(g.Bytes()))
+	require.Equal(t, g, scanned)
+	require.NoError(t, scanned.Scan(nil))
+	require.Equal(t, Geometry{}, scanned)
+	v, err := scanned.Value()
+	require.NoError(t, err)
+	require.Nil(t, v)
+}
+
+func TestGeometryResultset(t *testing.T) {
+	g := NewGeometry(4326, LineString{{1, 2}, {3, 4}})
+	for _, binary := range []bool{false, true} {
+		r, err := BuildSimpleResultset([]string{"g"}, [][]interface{}{{g}, {nil}}, binary)
+		require.NoError(t, err)
+		require.Equal(t, uint8(MYSQL_TYPE_GEOMETRY), r.Fields[0].Type)
+
+		r.Values = make([][]FieldValue, len(r.RowDatas))
+		for i, row := range r.RowDatas {
+			r.Values[i], err = row.Parse(r.Fields, binary, nil)
+			require.NoError(t, err)
+		}
+		r.FieldNames = map[string]int{"g": 0}
+
+		got, err := r.GetGeometryByName(0, "g")
+		require.NoError(t, err)
+		require.Equal(t, g, got)
+		got, err = r.GetGeometry(1, 0)
+		require.NoError(t, err)
+		require.Nil(t, got.Shape)
+	}
+}
@@ -19,6 +19,39 @@ type GTIDSet interface {
 	Clone() GTIDSet
 }
 
+// GTIDSetOperations is implemented by the GTID sets supporting the set operations, as the MySQL and MariaDB GTID sets
+// of this package. It is checked with a type assertion, so that the other implementations of GTIDSet keep working.
+type GTIDSetOperations interface {
+	GTIDSet
+
+	// IsEmpty returns true if the set has no GTID
+	IsEmpty() bool
+
+	// Union, Subtract and Intersect return a new set with the GTIDs of the set or o, of the set not in o, and of
+	// both the set and o. o must be of the same flavor
+	Union(o GTIDSet) (GTIDSet, error)
+
+	Subtract(o GTIDSet) (GTIDSet, error)
+
+	Intersect(o GTIDSet) (GTIDSet, error)
+}
+
+var (
+	_ GTIDSetOperations = (*MysqlGTIDSet)(nil)
+	_ GTIDSetOperations = (*MariadbGTIDSet)(nil)
+)
+
+// IsEmptyGTIDSet returns true if s is nil or has no GTID.
+func IsEmptyGTIDSet(s GTIDSet) bool {
+	if s == nil {
+		return true
+	}
+	if ops, ok := s.(GTIDSetOperations); ok {
+		return ops.IsEmpty()
+	}
+	return s.String() == ""
+}
+
 func ParseGTIDSet(flavor string, s string) (GTIDSet, error) {
 	switch flavor {
 	case MySQLFlavor:
@@ -0,0 +1,737 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pingcap/errors"
+	"github.com/shopspring/decimal"
+)
+
+// the types of the values of the binary JSON format, see mysql-server/sql-common/json_binary.h
+const (
+	jsonbSmallObject byte = iota
+	jsonbLargeObject
+	jsonbSmallArray
+	jsonbLargeArray
+	jsonbLiteral
+	jsonbInt16
+	jsonbUint16
+	jsonbInt32
+	jsonbUint32
+	jsonbInt64
+	jsonbUint64
+	jsonbDouble
+	jsonbString
+	jsonbOpaque byte = 0x0f
+)
+
+const (
+	jsonbNullLiteral  byte = 0x00
+	jsonbTrueLiteral  byte = 0x01
+	jsonbFalseLiteral byte = 0x02
+)
+
+// JSONBinary is a JSON document in the binary format of MySQL, as stored in the JSON columns and logged in the rows
+// events, see DecodeJSONBinary.
+type JSONBinary []byte
+
+// Decode decodes b as the Go values listed on DecodeJSONBinary.
+func (b JSONBinary) Decode() (interface{}, error) {
+	return DecodeJSONBinary(b)
+}
+
+// JSON returns b as JSON text, see JSONBinaryToJSON.
+func (b JSONBinary) JSON() (json.RawMessage, error) {
+	return JSONBinaryToJSON(b)
+}
+
+func (b JSONBinary) MarshalJSON() ([]byte, error) {
+	return JSONBinaryToJSON(b)
+}
+
+// JSONOpaque is a value of a MySQL type without JSON equivalent in a JSON document, e.g. a DATETIME or a BLOB, with
+// its type and its data. The DECIMAL values are decoded as Decimal.
+type JSONOpaque struct {
+	Type uint8
+	Data []byte
+}
+
+// NewJSONTime returns the opaque value of t as a MYSQL_TYPE_DATE, MYSQL_TYPE_DATETIME or MYSQL_TYPE_TIMESTAMP, with
+// its microseconds.
+func NewJSONTime(tp uint8, t time.Time) JSONOpaque {
+	var packed int64
+	if !t.IsZero() {
+		ymd := int64(t.Year()*13+int(t.Month()))<<5 | int64(t.Day())
+		var hms int64
+		if tp != MYSQL_TYPE_DATE {
+			hms = int64(t.Hour()<<12 | t.Minute()<<6 | t.Second())
+			packed = int64(t.Nanosecond() / 1000)
+		}
+		packed |= (ymd<<17 | hms) << 24
+	}
+	return JSONOpaque{Type: tp, Data: Uint64ToBytes(uint64(packed))}
+}
+
+// NewJSONDuration returns the opaque value of d as a MYSQL_TYPE_TIME, with its microseconds.
+func NewJSONDuration(d time.Duration) JSONOpaque {
+	negative := d < 0
+	if negative {
+		d = -d
+	}
+	sec := int64(d / time.Second)
+	hms := (sec/3600)<<12 | (sec/60%60)<<6 | sec%60
+	packed := hms<<24 | int64(d%time.Second/time.Microsecond)
+	if negative {
+		packed = -packed
+	}
+	return JSONOpaque{Type: MYSQL_TYPE_TIME, Data: Uint64ToBytes(uint64(packed))}
+}
+
+func (o JSONOpaque) packed() (int64, error) {
+	if len(o.Data) < 8 {
+		return 0, errors.Errorf("invalid temporal value of %d bytes", len(o.Data))
+	}
+	return int64(binary.LittleEndian.Uint64(o.Data)), nil
+}
+
+// Time returns the value of a DATE, DATETIME or TIMESTAMP in UTC, the zero date is the zero time.Time.
+func (o JSONOpaque) Time() (time.Time, error) {
+	switch o.Type {
+	case MYSQL_TYPE_DATE, MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIMESTAMP:
+	default:
+		return time.Time{}, errors.Errorf("opaque type %d is not a date", o.Type)
+	}
+	v, err := o.packed()
+	if err != nil || v == 0 {
+		return time.Time{}, err
+	}
+	if v < 0 {
+		v = -v
+	}
+	intPart := v >> 24
+	ymd, hms := intPart>>17, intPart%(1<<17)
+	ym := ymd >> 5
+	return time.Date(int(ym/13), time.Month(ym%13), int(ymd%(1<<5)),
+		int(hms>>12), int((hms>>6)%(1<<6)), int(hms%(1<<6)), int(v%(1<<24))*1000, time.UTC), nil
+}
+
+// Duration returns the value of a TIME.
+func (o JSONOpaque) Duration() (time.Duration, error) {
+	if o.Type != MYSQL_TYPE_TIME {
+		return 0, errors.Errorf("opaque type %d is not a time", o.Type)
+	}
+	v, err := o.packed()
+	if err != nil {
+		return 0, err
+	}
+	negative := v < 0
+	if negative {
+		v = -v
+	}
+	intPart := v >> 24
+	d := time.Duration((intPart>>12)%(1<<10))*time.Hour + time.Duration((intPart>>6)%(1<<6))*time.Minute +
+		time.Duration(intPart%(1<<6))*time.Second + time.Duration(v%(1<<24))*time.Microsecond
+	if negative {
+		d = -d
+	}
+	return d, nil
+}
+
+// String formats o as MySQL does in the JSON text: the dates and times with their microseconds, the other values in
+// base64 prefixed by their type, e.g. "base64:type252:AAE=".
+func (o JSONOpaque) String() string {
+	switch o.Type {
+	case MYSQL_TYPE_DATE, MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIMESTAMP:
+		t, err := o.Time()
+		if err != nil {
+			break
+		}
+		if t.IsZero() {
+			if o.Type == MYSQL_TYPE_DATE {
+				return "0000-00-00"
+			}
+			return "0000-00-00 00:00:00.000000"
+		}
+		if o.Type == MYSQL_TYPE_DATE {
+			return t.Format("2006-01-02")
+		}
+		return t.Format("2006-01-02 15:04:05.000000")
+	case MYSQL_TYPE_TIME:
+		d, err := o.Duration()
+		if err != nil {
+			break
+		}
+		sign := ""
+		if d < 0 {
+			sign, d = "-", -d
+		}
+		return fmt.Sprintf("%s%02d:%02d:%02d.%06d", sign, d/time.Hour, d/time.Minute%60, d/time.Second%60,
+			d%time.Second/time.Microsecond)
+	}
+	return fmt.Sprintf("base64:type%d:%s", o.Type, base64.StdEncoding.EncodeToString(o.Data))
+}
+
+func (o JSONOpaque) MarshalJSON() ([]byte, error) {
+	return appendJSONString(nil, o.String()), nil
+}
+
+// DecodeJSONBinary decodes a JSON document in the binary format of MySQL, the values being decoded as
+//
+//   - object: map[string]interface{}
+//   - array: []interface{}
+//   - null, true and false: nil, true and false
+//   - integers: int64, or uint64 for the unsigned ones
+//   - double: float64
+//   - string: string
+//   - DECIMAL: Decimal
+//   - the values of the other MySQL types: JSONOpaque
+//
+// The objects and the arrays of more than 64KB are in the large format, and the documents updated in place by MySQL
+// 8.0 have unused space between their values, both are decoded.
+func DecodeJSONBinary(data []byte) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty JSON binary document")
+	}
+	return decodeJSONBinaryValue(data[0], data[1:])
+}
+
+func decodeJSONBinaryValue(tp byte, data []byte) (interface{}, error) {
+	need := func(n int) error {
+		if len(data) < n {
+			return errors.Errorf("JSON data len %d < expected %d", len(data), n)
+		}
+		return nil
+	}
+
+	switch tp {
+	case jsonbSmallObject, jsonbLargeObject, jsonbSmallArray, jsonbLargeArray:
+		return decodeJSONBinaryContainer(tp, data)
+	case jsonbLiteral:
+		if err := need(1); err != nil {
+			return nil, err
+		}
+		switch data[0] {
+		case jsonbNullLiteral:
+			return nil, nil
+		case jsonbTrueLiteral:
+			return true, nil
+		case jsonbFalseLiteral:
+			return false, nil
+		}
+		return nil, errors.Errorf("invalid JSON literal %d", data[0])
+	case jsonbInt16:
+		if err := need(2); err != nil {
+			return nil, err
+		}
+		return int64(int16(binary.LittleEndian.Uint16(data))), nil
+	case jsonbUint16:
+		if err := need(2); err != nil {
+			return nil, err
+		}
+		return uint64(binary.LittleEndian.Uint16(data)), nil
+	case jsonbInt32:
+		if err := need(4); err != nil {
+			return nil, err
+		}
+		return int64(int32(binary.LittleEndian.Uint32(data))), nil
+	case jsonbUint32:
+		if err := need(4); err != nil {
+			return nil, err
+		}
+		return uint64(binary.LittleEndian.Uint32(data)), nil
+	case jsonbInt64:
+		if err := need(8); err != nil {
+			return nil, err
+		}
+		return int64(binary.LittleEndian.Uint64(data)), nil
+	case jsonbUint64:
+		if err := need(8); err != nil {
+			return nil, err
+		}
+		return binary.LittleEndian.Uint64(data), nil
+	case jsonbDouble:
+		if err := need(8); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+	case jsonbString:
+		s, err := decodeJSONBinaryBytes(data)
+		return string(s), err
+	case jsonbOpaque:
+		if err := need(1); err != nil {
+			return nil, err
+		}
+		b, err := decodeJSONBinaryBytes(data[1:])
+		if err != nil {
+			return nil, err
+		}
+		if data[0] == MYSQL_TYPE_NEWDECIMAL {
+			if len(b) < 2 {
+				return nil, errors.New("invalid JSON decimal")
+			}
+			d, _, err := DecodeDecimalBinary(b[2:], int(b[0]), int(b[1]))
+			return d, err
+		}
+		return JSONOpaque{Type: data[0], Data: append([]byte(nil), b...)}, nil
+	}
+	return nil, errors.Errorf("invalid JSON type %d", tp)
+}
+
+// decodeJSONBinaryBytes decodes the bytes of a string or an opaque value, after their variable length.
+func decodeJSONBinaryBytes(data []byte) ([]byte, error) {
+	var length uint64
+	for i := 0; i < 5 && i < len(data); i++ {
+		length |= uint64(data[i]&0x7f) << (7 * uint(i))
+		if data[i]&0x80 == 0 {
+			if length > uint64(len(data)-i-1) {
+				return nil, errors.Errorf("JSON data len %d < expected %d", len(data)-i-1, length)
+			}
+			return data[i+1 : i+1+int(length)], nil
+		}
+	}
+	return nil, errors.New("invalid JSON variable length")
+}
+
+func decodeJSONBinaryContainer(tp byte, data []byte) (interface{}, error) {
+	isSmall := tp == jsonbSmallObject || tp == jsonbSmallArray
+	isObject := tp == jsonbSmallObject || tp == jsonbLargeObject
+	offsetSize := 4
+	if isSmall {
+		offsetSize = 2
+	}
+	offset := func(b []byte) int {
+		if isSmall {
+			return int(binary.LittleEndian.Uint16(b))
+		}
+		return int(binary.LittleEndian.Uint32(b))
+	}
+
+	if len(data) < 2*offsetSize {
+		return nil, errors.Errorf("JSON data len %d < expected %d", len(data), 2*offsetSize)
+	}
+	count, size := offset(data), offset(data[offsetSize:])
+	if len(data) < size {
+		return nil, errors.Errorf("JSON data len %d < expected %d", len(data), size)
+	}
+	data = data[:size]
+
+	keyEntrySize, valueEntrySize := offsetSize+2, offsetSize+1
+	headerSize := 2*offsetSize + count*valueEntrySize
+	if isObject {
+		headerSize += count * keyEntrySize
+	}
+	if headerSize > size {
+		return nil, errors.Errorf("JSON header size %d > size %d", headerSize, size)
+	}
+
+	keys := make([]string, 0, count)
+	for i := 0; isObject && i < count; i++ {
+		entry := 2*offsetSize + keyEntrySize*i
+		keyOffset, keyLength := offset(data[entry:]), int(binary.LittleEndian.Uint16(data[entry+offsetSize:]))
+		if keyOffset < headerSize || keyOffset+keyLength > size {
+			return nil, errors.Errorf("invalid JSON key offset %d", keyOffset)
+		}
+		keys = append(keys, string(data[keyOffset:keyOffset+keyLength]))
+	}
+
+	values := make([]interface{}, count)
+	for i := range values {
+		entry := 2*offsetSize + valueEntrySize*i
+		if isObject {
+			entry += keyEntrySize * count
+		}
+		valueType := data[entry]
+		var err error
+		if jsonBinaryInline(valueType, isSmall) {
+			values[i], err = decodeJSONBinaryValue(valueType, data[entry+1:entry+valueEntrySize])
+		} else if valueOffset := offset(data[entry+1:]); valueOffset < headerSize || valueOffset >= size {
+			err = errors.Errorf("invalid JSON value offset %d", valueOffset)
+		} else {
+			values[i], err = decodeJSONBinaryValue(valueType, data[valueOffset:])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !isObject {
+		return values, nil
+	}
+	m := make(map[string]interface{}, count)
+	for i, key := range keys {
+		m[key] = values[i]
+	}
+	return m, nil
+}
+
+// jsonBinaryInline returns whether a value of the type is stored in its entry rather than at an offset.
+func jsonBinaryInline(tp byte, isSmall bool) bool {
+	switch tp {
+	case jsonbLiteral, jsonbInt16, jsonbUint16:
+		return true
+	case jsonbInt32, jsonbUint32:
+		return !isSmall
+	}
+	return false
+}
+
+// JSONBinaryToJSON decodes a JSON document in the binary format of MySQL as compact JSON text. The keys of the objects
+// are in the order of MySQL, by length then bytes, the DECIMAL values are exact numbers, the doubles keep a decimal
+// point, and the other opaque values are strings formatted as by JSONOpaque.String.
+func JSONBinaryToJSON(data []byte) (json.RawMessage, error) {
+	v, err := DecodeJSONBinary(data)
+	if err != nil {
+		return nil, err
+	}
+	return appendJSONValue(nil, v)
+}
+
+func appendJSONValue(buf []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, "null"...), nil
+	case bool:
+		return strconv.AppendBool(buf, v), nil
+	case int64:
+		return strconv.AppendInt(buf, v, 10), nil
+	case uint64:
+		return strconv.AppendUint(buf, v, 10), nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, errors.Errorf("invalid JSON double %v", v)
+		}
+		start := len(buf)
+		buf = strconv.AppendFloat(buf, v, 'g', -1, 64)
+		if bytes.IndexAny(buf[start:], ".e") < 0 {
+			buf = append(buf, ".0"...)
+		}
+		return buf, nil
+	case string:
+		return appendJSONString(buf, v), nil
+	case Decimal:
+		return append(buf, v.String()...), nil
+	case JSONOpaque:
+		return appendJSONString(buf, v.String()), nil
+	case []interface{}:
+		buf = append(buf, '[')
+		for i, e := range v {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			var err error
+			if buf, err = appendJSONValue(buf, e); err != nil {
+				return nil, err
+			}
+		}
+		return append(buf, ']'), nil
+	case map[string]interface{}:
+		buf = append(buf, '{')
+		for i, key := range jsonBinaryKeys(v) {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONString(buf, key)
+			buf = append(buf, ':')
+			var err error
+			if buf, err = appendJSONValue(buf, v[key]); err != nil {
+				return nil, err
+			}
+		}
+		return append(buf, '}'), nil
+	}
+	return nil, errors.Errorf("invalid JSON value type %T", v)
+}
+
+func appendJSONString(buf []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	buf = append(buf, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, n := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && n == 1 {
+				buf = append(buf, `�`...)
+			} else {
+				buf = append(buf, s[i:i+n]...)
+			}
+			i += n
+			continue
+		}
+		switch c {
+		case '"', '\\':
+			buf = append(buf, '\\', c)
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case '\r':
+			buf = append(buf, '\\', 'r')
+		case '\t':
+			buf = append(buf, '\\', 't')
+		case '\b':
+			buf = append(buf, '\\', 'b')
+		case '\f':
+			buf = append(buf, '\\', 'f')
+		default:
+			if c < 0x20 {
+				buf = append(buf, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			} else {
+				buf = append(buf, c)
+			}
+		}
+		i++
+	}
+	return append(buf, '"')
+}
+
+// jsonBinaryKeys returns the keys of an object in the order of MySQL, by length then bytes, which looks them up by
+// binary search.
+func jsonBinaryKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) < len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// errJSONBinaryTooLarge is returned when an object or an array doesn't fit in the small format.
+var errJSONBinaryTooLarge = errors.New("JSON value too large for the small format")
+
+// EncodeJSONBinary encodes a JSON document in the binary format of MySQL, to be compared with or written as the value
+// of a JSON column, e.g. in the rows events of a binlog. The document is a json.RawMessage, a JSONBinary, or the Go
+// values listed on DecodeJSONBinary, the Go integers, float32, json.Number, time.Time, encoded as a DATETIME, and
+// time.Duration, encoded as a TIME. The integers are stored in their smallest type and the objects and the arrays in
+// the large format when they are larger than 64KB, as MySQL does.
+func EncodeJSONBinary(v interface{}) ([]byte, error) {
+	tp, data, err := encodeJSONBinaryValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{tp}, data...), nil
+}
+
+func encodeJSONBinaryValue(v interface{}) (byte, []byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return jsonbLiteral, []byte{jsonbNullLiteral}, nil
+	case bool:
+		if v {
+			return jsonbLiteral, []byte{jsonbTrueLiteral}, nil
+		}
+		return jsonbLiteral, []byte{jsonbFalseLiteral}, nil
+	case int:
+		return encodeJSONBinaryInt(int64(v))
+	case int8:
+		return encodeJSONBinaryInt(int64(v))
+	case int16:
+		return encodeJSONBinaryInt(int64(v))
+	case int32:
+		return encodeJSONBinaryInt(int64(v))
+	case int64:
+		return encodeJSONBinaryInt(v)
+	case uint:
+		return encodeJSONBinaryUint(uint64(v))
+	case uint8:
+		return encodeJSONBinaryUint(uint64(v))
+	case uint16:
+		return encodeJSONBinaryUint(uint64(v))
+	case uint32:
+		return encodeJSONBinaryUint(uint64(v))
+	case uint64:
+		return encodeJSONBinaryUint(v)
+	case float32:
+		return encodeJSONBinaryValue(float64(v))
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return 0, nil, errors.Errorf("invalid JSON double %v", v)
+		}
+		return jsonbDouble, Uint64ToBytes(math.Float64bits(v)), nil
+	case json.Number:
+		// as the JSON parser of MySQL: an integer if it fits, a double otherwise
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return encodeJSONBinaryInt(n)
+		}
+		if n, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return encodeJSONBinaryUint(n)
+		}
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return 0, nil, errors.Errorf("invalid JSON number %q", v)
+		}
+		return encodeJSONBinaryValue(f)
+	case string:
+		return jsonbString, appendJSONBinaryBytes(nil, []byte(v)), nil
+	case Decimal:
+		precision, scale := decimalPrecision(v), int(v.Scale())
+		data, err := appendDecimalBinary([]byte{byte(precision), byte(scale)}, v, precision, scale)
+		if err != nil {
+			return 0, nil, err
+		}
+		return jsonbOpaque, appendJSONBinaryBytes([]byte{MYSQL_TYPE_NEWDECIMAL}, data), nil
+	case decimal.Decimal:
+		d, err := ParseDecimal(v.String())
+		if err != nil {
+			return 0, nil, err
+		}
+		return encodeJSONBinaryValue(d)
+	case JSONOpaque:
+		return jsonbOpaque, appendJSONBinaryBytes([]byte{v.Type}, v.Data), nil
+	case time.Time:
+		return encodeJSONBinaryValue(NewJSONTime(MYSQL_TYPE_DATETIME, v))
+	case time.Duration:
+		return encodeJSONBinaryValue(NewJSONDuration(v))
+	case JSONBinary:
+		if len(v) == 0 {
+			return 0, nil, errors.New("empty JSON binary document")
+		}
+		return v[0], v[1:], nil
+	case json.RawMessage:
+		d := json.NewDecoder(bytes.NewReader(v))
+		d.UseNumber()
+		var doc interface{}
+		if err := d.Decode(&doc); err != nil {
+			return 0, nil, errors.Trace(err)
+		}
+		if d.More() {
+			return 0, nil, errors.New("invalid JSON document, data after the value")
+		}
+		return encodeJSONBinaryValue(doc)
+	case []interface{}:
+		return encodeJSONBinaryContainer(nil, v)
+	case map[string]interface{}:
+		keys := jsonBinaryKeys(v)
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			values[i] = v[key]
+		}
+		return encodeJSONBinaryContainer(keys, values)
+	}
+	return 0, nil, errors.Errorf("invalid JSON value type %T", v)
+}
+
+func encodeJSONBinaryInt(v int64) (byte, []byte, error) {
+	switch {
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return jsonbInt16, Uint16ToBytes(uint16(v)), nil
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return jsonbInt32, Uint32ToBytes(uint32(v)), nil
+	}
+	return jsonbInt64, Uint64ToBytes(uint64(v)), nil
+}
+
+func encodeJSONBinaryUint(v uint64) (byte, []byte, error) {
+	switch {
+	case v <= math.MaxUint16:
+		return jsonbUint16, Uint16ToBytes(uint16(v)), nil
+	case v <= math.MaxUint32:
+		return jsonbUint32, Uint32ToBytes(uint32(v)), nil
+	}
+	return jsonbUint64, Uint64ToBytes(v), nil
+}
+
+// appendJSONBinaryBytes appends b after its variable length, 7 bits per byte.
+func appendJSONBinaryBytes(buf []byte, b []byte) []byte {
+	n := uint64(len(b))
+	for n >= 0x80 {
+		buf = append(buf, byte(n)|0x80)
+		n >>= 7
+	}
+	return append(append(buf, byte(n)), b...)
+}
+
+// encodeJSONBinaryContainer encodes an object, or an array if keys is nil, in the small format if possible.
+func encodeJSONBinaryContainer(keys []string, values []interface{}) (byte, []byte, error) {
+	encoded := make([]struct {
+		tp   byte
+		data []byte
+	}, len(values))
+	for i, value := range values {
+		var err error
+		if encoded[i].tp, encoded[i].data, err = encodeJSONBinaryValue(value); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	for _, isSmall := range []bool{true, false} {
+		offsetSize, maxOffset := 2, math.MaxUint16
+		if !isSmall {
+			offsetSize, maxOffset = 4, math.MaxUint32
+		}
+		putOffset := func(b []byte, v int) {
+			if isSmall {
+				binary.LittleEndian.PutUint16(b, uint16(v))
+			} else {
+				binary.LittleEndian.PutUint32(b, uint32(v))
+			}
+		}
+
+		keyEntrySize, valueEntrySize := offsetSize+2, offsetSize+1
+		count := len(values)
+		headerSize := 2*offsetSize + count*valueEntrySize
+		if keys != nil {
+			headerSize += count * keyEntrySize
+		}
+		data := make([]byte, headerSize)
+		putOffset(data, count)
+
+		err := error(nil)
+		for i, key := range keys {
+			if len(key) > math.MaxUint16 {
+				return 0, nil, errors.Errorf("JSON key of %d bytes too long", len(key))
+			}
+			entry := 2*offsetSize + keyEntrySize*i
+			putOffset(data[entry:], len(data))
+			binary.LittleEndian.PutUint16(data[entry+offsetSize:], uint16(len(key)))
+			data = append(data, key...)
+		}
+		for i, value := range encoded {
+			entry := 2*offsetSize + valueEntrySize*i
+			if keys != nil {
+				entry += keyEntrySize * count
+			}
+			data[entry] = value.tp
+			if jsonBinaryInline(value.tp, isSmall) {
+				copy(data[entry+1:entry+valueEntrySize], value.data)
+				continue
+			}
+			putOffset(data[entry+1:], len(data))
+			data = append(data, value.data...)
+			if len(data) > maxOffset {
+				err = errJSONBinaryTooLarge
+				break
+			}
+		}
+		if count > maxOffset || len(data) > maxOffset {
+			err = errJSONBinaryTooLarge
+		}
+		if err == errJSONBinaryTooLarge && isSmall {
+			continue
+		} else if err != nil {
+			return 0, nil, err
+		}
+		putOffset(data[offsetSize:], len(data))
+
+		tp := jsonbSmallArray
+		if keys != nil {
+			tp = jsonbSmallObject
+		}
+		if !isSmall {
+			tp++
+		}
+		return tp, data, nil
+	}
+	return 0, nil, errJSONBinaryTooLarge
+}
@@ -0,0 +1,173 @@
+package mysql
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONBinaryGolden(t *testing.T) {
+	// logged by MySQL for '{"a":1234}' and '{"key1": "value1", "key2": "value2"}'
+	for _, test := range []struct {
+		doc  string
+		data []byte
+	}{
+		{`{"a":1234}`, []byte("\x00\x01\x00\x0c\x00\x0b\x00\x01\x00\x05\xd2\x04a")},
+		{`{"key1":"value1","key2":"value2"}`,
+			[]byte("\x00\x02\x00(\x00\x12\x00\x04\x00\x16\x00\x04\x00\f\x1a\x00\f!\x00key1key2\x06value1\x06value2")},
+	} {
+		text, err := JSONBinaryToJSON(test.data)
+		require.NoError(t, err)
+		require.Equal(t, test.doc, string(text))
+
+		data, err := EncodeJSONBinary(json.RawMessage(test.doc))
+		require.NoError(t, err)
+		require.Equal(t, test.data, data)
+	}
+}
+
+func TestJSONBinaryRoundTrip(t *testing.T) {
+	for _, doc := range []string{
+		`null`,
+		`true`,
+		`"a \"quoted\"\n\u0001 ünicode"`,
+		`[]`,
+		`{}`,
+		`[1,-1,32768,-32769,2147483648,-2147483649,9223372036854775807,18446744073709551615,1.5,1.0,1e+300]`,
+		`{"b":[true,false,null],"aa":{"c":"d"},"a":-12}`,
+	} {
+		data, err := EncodeJSONBinary(json.RawMessage(doc))
+		require.NoError(t, err, doc)
+		text, err := JSONBinaryToJSON(data)
+		require.NoError(t, err, doc)
+		require.JSONEq(t, doc, string(text))
+	}
+
+	// the keys are sorted by length then bytes
+	data, err := EncodeJSONBinary(map[string]interface{}{"bb": 1, "c": 2, "a": 3})
+	require.NoError(t, err)
+	text, err := JSONBinary(data).JSON()
+	require.NoError(t, err)
+	require.Equal(t, `{"a":3,"c":2,"bb":1}`, string(text))
+
+	// the integers keep their signedness, and the doubles their decimal point
+	data, err = EncodeJSONBinary([]interface{}{uint8(1), int8(-1), 2.0, MustParseDecimal("-12345678901234567890.50")})
+	require.NoError(t, err)
+	v, err := DecodeJSONBinary(data)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{uint64(1), int64(-1), 2.0, MustParseDecimal("-12345678901234567890.50")}, v)
+	text, err = JSONBinaryToJSON(data)
+	require.NoError(t, err)
+	require.Equal(t, `[1,-1,2.0,-12345678901234567890.50]`, string(text))
+}
+
+func TestJSONBinaryLarge(t *testing.T) {
+	long := strings.Repeat("x", 70000)
+	doc := map[string]interface{}{"long": long, "n": int64(100000), "a": []interface{}{int64(1), long}}
+	data, err := EncodeJSONBinary(doc)
+	require.NoError(t, err)
+	require.Equal(t, jsonbLargeObject, data[0])
+
+	v, err := DecodeJSONBinary(data)
+	require.NoError(t, err)
+	require.Equal(t, doc, v)
+
+	// the small values of a large document stay small
+	data, err = EncodeJSONBinary([]interface{}{long, []interface{}{"y"}})
+	require.NoError(t, err)
+	require.Equal(t, jsonbLargeArray, data[0])
+	v, err = DecodeJSONBinary(data)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{long, []interface{}{"y"}}, v)
+}
+
+func TestJSONBinaryOpaque(t *testing.T) {
+	tm := time.Date(2023, 4, 5, 6, 7, 8, 123456000, time.UTC)
+	d := -(26*time.Hour + 3*time.Minute + 4*time.Second + 500*time.Microsecond)
+	data, err := EncodeJSONBinary([]interface{}{
+		tm,
+		NewJSONTime(MYSQL_TYPE_DATE, tm),
+		NewJSONTime(MYSQL_TYPE_DATETIME, time.Time{}),
+		d,
+		JSONOpaque{Type: MYSQL_TYPE_BLOB, Data: []byte{0, 1}},
+	})
+	require.NoError(t, err)
+
+	text, err := JSONBinaryToJSON(data)
+	require.NoError(t, err)
+	require.Equal(t, `["2023-04-05 06:07:08.123456","2023-04-05","0000-00-00 00:00:00.000000","-26:03:04.000500",`+
+		`"base64:type252:AAE="]`, string(text))
+
+	v, err := DecodeJSONBinary(data)
+	require.NoError(t, err)
+	values := v.([]interface{})
+	got, err := values[0].(JSONOpaque).Time()
+	require.NoError(t, err)
+	require.Equal(t, tm, got)
+	got, err = values[1].(JSONOpaque).Time()
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC), got)
+	got, err = values[2].(JSONOpaque).Time()
+	require.NoError(t, err)
+	require.True(t, got.IsZero())
+	dur, err := values[3].(JSONOpaque).Duration()
+	require.NoError(t, err)
+	require.Equal(t, d, dur)
+	require.Equal(t, JSONOpaque{Type: MYSQL_TYPE_BLOB, Data: []byte{0, 1}}, values[4])
+	_, err = values[4].(JSONOpaque).Time()
+	require.Error(t, err)
+}
+
+func TestDecimalBinary(t *testing.T) {
+	// logged by MySQL for DECIMAL(40,16) and DECIMAL(30,30)
+	for _, test := range []struct {
+		num              string
+		data             []byte
+		precision, scale int
+	}{
+		{"123.4560000000000000", []byte{128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 123, 27, 46, 2, 0, 0, 0, 0, 0}, 40, 16},
+		{"-123456234234234757655.1234567890123456",
+			[]byte{127, 255, 132, 228, 206, 107, 5, 242, 1, 225, 232, 248, 164, 50, 234, 255, 254, 29, 191}, 40, 16},
+		{"0.0000000000000000", []byte{128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 40, 16},
+		{"0.100000000000000000000000000000", []byte{133, 245, 225, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 30, 30},
+	} {
+		d, n, err := DecodeDecimalBinary(test.data, test.precision, test.scale)
+		require.NoError(t, err, test.num)
+		require.Equal(t, test.num, d.String())
+		require.Equal(t, len(test.data), n)
+
+		data, err := appendDecimalBinary(nil, d, test.precision, test.scale)
+		require.NoError(t, err, test.num)
+		require.Equal(t, test.data, data)
+	}
+
+	_, err := appendDecimalBinary(nil, MustParseDecimal("1000"), 5, 2)
+	require.Error(t, err)
+	_, _, err = DecodeDecimalBinary([]byte{128}, 10, 2)
+	require.Error(t, err)
+}
+
+func TestJSONBinaryInvalid(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		{0x10},
+		{jsonbLiteral, 0x03},
+		{jsonbInt64, 1, 2},
+		{jsonbString, 0x05, 'a'},
+		// the size is larger than the data
+		{jsonbSmallArray, 0x01, 0x00, 0x20, 0x00},
+		// the value is before the header
+		{jsonbSmallArray, 0x01, 0x00, 0x07, 0x00, jsonbString, 0x01, 0x00},
+	} {
+		_, err := DecodeJSONBinary(data)
+		require.Error(t, err, "%x", data)
+	}
+
+	_, err := EncodeJSONBinary(json.RawMessage(`{"a":`))
+	require.Error(t, err)
+	_, err = EncodeJSONBinary(struct{}{})
+	require.Error(t, err)
+}
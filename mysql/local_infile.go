@@ -0,0 +1,19 @@
+package mysql
+
+import "io"
+
+// LocalInfileRequest asks the client for the content of one of its files, as a MySQL server does for
+// 'LOAD DATA LOCAL INFILE'.
+//
+// Set it as Result.LocalInfile in the result returned by a server handler for a query, the server sends the
+// request to the client and calls Handler with a reader returning the file content. The result returned by
+// Handler (affected rows, warnings, etc.) or its error is then sent to the client.
+type LocalInfileRequest struct {
+	Filename string
+	Handler  func(r io.Reader) (*Result, error)
+}
+
+// NewLocalInfileRequest creates a request for the file named filename on the client side.
+func NewLocalInfileRequest(filename string, handler func(r io.Reader) (*Result, error)) *LocalInfileRequest {
+	return &LocalInfileRequest{Filename: filename, Handler: handler}
+}
@@ -0,0 +1,75 @@
+package mysql
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/siddontang/go-log/log"
+	"github.com/siddontang/go-log/loggers"
+)
+
+// NewDefaultLogger returns the logger used by the client, server and replication packages when none is set,
+// it writes the messages of level info and above to stdout.
+func NewDefaultLogger() loggers.Advanced {
+	streamHandler, _ := log.NewStreamHandler(os.Stdout)
+	return log.NewDefault(streamHandler)
+}
+
+// LoggerWithFields returns a logger adding the key/value pairs in fields to every message.
+// The fields are passed to loggers implementing loggers.Contextual, otherwise they are appended
+// to the messages as key=value.
+func LoggerWithFields(l loggers.Advanced, fields ...interface{}) loggers.Advanced {
+	if len(fields) == 0 {
+		return l
+	}
+	if cl, ok := l.(loggers.Contextual); ok {
+		return cl.WithFields(fields...)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(fields); i += 2 {
+		if i+1 < len(fields) {
+			fmt.Fprintf(&b, " %v=%v", fields[i], fields[i+1])
+		} else {
+			fmt.Fprintf(&b, " %v", fields[i])
+		}
+	}
+	return &fieldsLogger{l: l, fields: b.String()}
+}
+
+type fieldsLogger struct {
+	l      loggers.Advanced
+	fields string
+}
+
+func (f *fieldsLogger) sprint(args []interface{}) string {
+	return fmt.Sprint(args...) + f.fields
+}
+
+func (f *fieldsLogger) sprintf(format string, args []interface{}) string {
+	return fmt.Sprintf(format, args...) + f.fields
+}
+
+func (f *fieldsLogger) Fatal(args ...interface{})   { f.l.Fatal(f.sprint(args)) }
+func (f *fieldsLogger) Fatalln(args ...interface{}) { f.l.Fatalln(f.sprint(args)) }
+func (f *fieldsLogger) Panic(args ...interface{})   { f.l.Panic(f.sprint(args)) }
+func (f *fieldsLogger) Panicln(args ...interface{}) { f.l.Panicln(f.sprint(args)) }
+func (f *fieldsLogger) Print(args ...interface{})   { f.l.Print(f.sprint(args)) }
+func (f *fieldsLogger) Println(args ...interface{}) { f.l.Println(f.sprint(args)) }
+func (f *fieldsLogger) Debug(args ...interface{})   { f.l.Debug(f.sprint(args)) }
+func (f *fieldsLogger) Debugln(args ...interface{}) { f.l.Debugln(f.sprint(args)) }
+func (f *fieldsLogger) Error(args ...interface{})   { f.l.Error(f.sprint(args)) }
+func (f *fieldsLogger) Errorln(args ...interface{}) { f.l.Errorln(f.sprint(args)) }
+func (f *fieldsLogger) Info(args ...interface{})    { f.l.Info(f.sprint(args)) }
+func (f *fieldsLogger) Infoln(args ...interface{})  { f.l.Infoln(f.sprint(args)) }
+func (f *fieldsLogger) Warn(args ...interface{})    { f.l.Warn(f.sprint(args)) }
+func (f *fieldsLogger) Warnln(args ...interface{})  { f.l.Warnln(f.sprint(args)) }
+
+func (f *fieldsLogger) Fatalf(format string, args ...interface{}) { f.l.Fatal(f.sprintf(format, args)) }
+func (f *fieldsLogger) Panicf(format string, args ...interface{}) { f.l.Panic(f.sprintf(format, args)) }
+func (f *fieldsLogger) Printf(format string, args ...interface{}) { f.l.Print(f.sprintf(format, args)) }
+func (f *fieldsLogger) Debugf(format string, args ...interface{}) { f.l.Debug(f.sprintf(format, args)) }
+func (f *fieldsLogger) Errorf(format string, args ...interface{}) { f.l.Error(f.sprintf(format, args)) }
+func (f *fieldsLogger) Infof(format string, args ...interface{})  { f.l.Info(f.sprintf(format, args)) }
+func (f *fieldsLogger) Warnf(format string, args ...interface{})  { f.l.Warn(f.sprintf(format, args)) }
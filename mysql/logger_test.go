@@ -0,0 +1,24 @@
+package mysql
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/siddontang/go-log/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggerWithFields(t *testing.T) {
+	var buf bytes.Buffer
+	handler, err := log.NewStreamHandler(&buf)
+	require.NoError(t, err)
+	l := log.New(handler, 0)
+
+	require.Equal(t, l, LoggerWithFields(l))
+
+	fl := LoggerWithFields(l, "conn_id", 1, "user", "root")
+	fl.Infof("command %d", 3)
+	fl.Debug("hidden")
+	fl.Warn("closed")
+	require.Equal(t, "command 3 conn_id=1 user=root\nclosed conn_id=1 user=root\n", buf.String())
+}
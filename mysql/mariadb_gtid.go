@@ -1,7 +1,6 @@
 package mysql
 
 import (
-	"bytes"
 	"fmt"
 	"sort"
 	"strconv"
@@ -168,15 +167,7 @@ func (s *MariadbGTIDSet) String() string {
 
 // Encode encodes mariadb gtid set
 func (s *MariadbGTIDSet) Encode() []byte {
-	var buf bytes.Buffer
-	sep := ""
-	for _, gtid := range s.Sets {
-		buf.WriteString(sep)
-		buf.WriteString(gtid.String())
-		sep = ","
-	}
-
-	return buf.Bytes()
+	return []byte(s.String())
 }
 
 // Clone clones a mariadb gtid set
@@ -236,3 +227,64 @@ func (s *MariadbGTIDSet) Contain(o GTIDSet) bool {
 
 	return true
 }
+
+// IsEmpty returns true if the mariadb gtid set has no gtid
+func (s *MariadbGTIDSet) IsEmpty() bool {
+	return len(s.Sets) == 0
+}
+
+// Union returns a new mariadb gtid set with the latest gtid of each domain of s or o, o must be a mariadb gtid set
+func (s *MariadbGTIDSet) Union(o GTIDSet) (GTIDSet, error) {
+	other, ok := o.(*MariadbGTIDSet)
+	if !ok {
+		return nil, errors.Errorf("invalid MariaDB GTID set %T", o)
+	}
+
+	n := s.Clone().(*MariadbGTIDSet)
+	for domainID, gtid := range other.Sets {
+		if o, ok := n.Sets[domainID]; !ok || !o.Contain(gtid) {
+			n.Sets[domainID] = gtid.Clone()
+		}
+	}
+	return n, nil
+}
+
+// Subtract returns a new mariadb gtid set with the gtids of the domains of s not covered by o, o must be a mariadb
+// gtid set
+func (s *MariadbGTIDSet) Subtract(o GTIDSet) (GTIDSet, error) {
+	other, ok := o.(*MariadbGTIDSet)
+	if !ok {
+		return nil, errors.Errorf("invalid MariaDB GTID set %T", o)
+	}
+
+	n := &MariadbGTIDSet{Sets: make(map[uint32]*MariadbGTID)}
+	for domainID, gtid := range s.Sets {
+		if o, ok := other.Sets[domainID]; !ok || !o.Contain(gtid) {
+			n.Sets[domainID] = gtid.Clone()
+		}
+	}
+	return n, nil
+}
+
+// Intersect returns a new mariadb gtid set with the earliest gtid of each domain of both s and o, o must be a
+// mariadb gtid set
+func (s *MariadbGTIDSet) Intersect(o GTIDSet) (GTIDSet, error) {
+	other, ok := o.(*MariadbGTIDSet)
+	if !ok {
+		return nil, errors.Errorf("invalid MariaDB GTID set %T", o)
+	}
+
+	n := &MariadbGTIDSet{Sets: make(map[uint32]*MariadbGTID)}
+	for domainID, gtid := range s.Sets {
+		o, ok := other.Sets[domainID]
+		if !ok {
+			continue
+		}
+		if gtid.Contain(o) {
+			n.Sets[domainID] = o.Clone()
+		} else {
+			n.Sets[domainID] = gtid.Clone()
+		}
+	}
+	return n, nil
+}
@@ -233,3 +233,31 @@ func TestMariaDBGTIDSetSortedString(t *testing.T) {
 		require.Equal(t, strs[1], gtidSet.String())
 	}
 }
+
+func TestMariaDBGTIDSetAlgebra(t *testing.T) {
+	parse := func(s string) GTIDSetOperations {
+		g, err := ParseMariadbGTIDSet(s)
+		require.NoError(t, err)
+		return g.(GTIDSetOperations)
+	}
+
+	left, right := parse("0-1-10,1-2-5,3-1-1"), parse("0-2-12,1-2-3,2-1-7")
+
+	union, err := left.Union(right)
+	require.NoError(t, err)
+	require.Equal(t, "0-2-12,1-2-5,2-1-7,3-1-1", union.String())
+	subtract, err := left.Subtract(right)
+	require.NoError(t, err)
+	require.Equal(t, "1-2-5,3-1-1", subtract.String())
+	intersection, err := left.Intersect(right)
+	require.NoError(t, err)
+	require.Equal(t, "0-1-10,1-2-3", intersection.String())
+	require.Equal(t, "0-1-10,1-2-5,3-1-1", left.String())
+
+	subtract, err = right.Subtract(union)
+	require.NoError(t, err)
+	require.True(t, IsEmptyGTIDSet(subtract))
+
+	_, err = left.Intersect(&MysqlGTIDSet{})
+	require.Error(t, err)
+}
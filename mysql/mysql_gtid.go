@@ -176,6 +176,34 @@ func (s IntervalSlice) Contain(sub IntervalSlice) bool {
 	return true
 }
 
+// Intersect returns the intervals of both s and o, which must be normalized
+func (s IntervalSlice) Intersect(o IntervalSlice) IntervalSlice {
+	var n IntervalSlice
+	for i, j := 0, 0; i < len(s) && j < len(o); {
+		start := max(s[i].Start, o[j].Start)
+		stop := min(s[i].Stop, o[j].Stop)
+		if start < stop {
+			n = append(n, Interval{start, stop})
+		}
+		if s[i].Stop < o[j].Stop {
+			i++
+		} else {
+			j++
+		}
+	}
+	return n
+}
+
+// ContainGNO returns true if the GNO gno is in s
+func (s IntervalSlice) ContainGNO(gno int64) bool {
+	for _, in := range s {
+		if gno >= in.Start && gno < in.Stop {
+			return true
+		}
+	}
+	return false
+}
+
 func (s IntervalSlice) Equal(o IntervalSlice) bool {
 	if len(s) != len(o) {
 		return false
@@ -504,6 +532,80 @@ func (s *MysqlGTIDSet) Minus(subtrahend MysqlGTIDSet) error {
 	return nil
 }
 
+// Union returns a new set with the GTIDs of s or o, o must be a MysqlGTIDSet
+func (s *MysqlGTIDSet) Union(o GTIDSet) (GTIDSet, error) {
+	other, ok := o.(*MysqlGTIDSet)
+	if !ok {
+		return nil, errors.Errorf("invalid MySQL GTID set %T", o)
+	}
+
+	n := s.Clone().(*MysqlGTIDSet)
+	for _, set := range other.Sets {
+		n.AddSet(set.Clone())
+	}
+	return n, nil
+}
+
+// Subtract returns a new set with the GTIDs of s not in o, e.g. the errant transactions of a replica with the
+// gtid_executed of the replica minus the one of its source. o must be a MysqlGTIDSet
+func (s *MysqlGTIDSet) Subtract(o GTIDSet) (GTIDSet, error) {
+	other, ok := o.(*MysqlGTIDSet)
+	if !ok {
+		return nil, errors.Errorf("invalid MySQL GTID set %T", o)
+	}
+
+	n := s.Clone().(*MysqlGTIDSet)
+	for _, set := range other.Sets {
+		n.MinusSet(set)
+	}
+	return n, nil
+}
+
+// Intersect returns a new set with the GTIDs of both s and o, o must be a MysqlGTIDSet
+func (s *MysqlGTIDSet) Intersect(o GTIDSet) (GTIDSet, error) {
+	other, ok := o.(*MysqlGTIDSet)
+	if !ok {
+		return nil, errors.Errorf("invalid MySQL GTID set %T", o)
+	}
+
+	n := &MysqlGTIDSet{Sets: make(map[string]*UUIDSet)}
+	for sid, set := range s.Sets {
+		o, ok := other.Sets[sid]
+		if !ok {
+			continue
+		}
+		if in := set.Intervals.Intersect(o.Intervals); len(in) > 0 {
+			n.Sets[sid] = &UUIDSet{set.SID, in}
+		}
+	}
+	return n, nil
+}
+
+// ContainGTID returns true if the GTID uuid:gno is in s
+func (s *MysqlGTIDSet) ContainGTID(uuid uuid.UUID, gno int64) bool {
+	set, ok := s.Sets[uuid.String()]
+	return ok && set.Intervals.ContainGNO(gno)
+}
+
+// IsEmpty returns true if s has no GTID
+func (s *MysqlGTIDSet) IsEmpty() bool {
+	return len(s.UUIDSets()) == 0
+}
+
+// UUIDSets returns the UUID sets of s sorted by UUID, as in gtid_executed, without the empty ones
+func (s *MysqlGTIDSet) UUIDSets() []*UUIDSet {
+	sets := make([]*UUIDSet, 0, len(s.Sets))
+	for _, set := range s.Sets {
+		if len(set.Intervals) > 0 {
+			sets = append(sets, set)
+		}
+	}
+	sort.Slice(sets, func(i, j int) bool {
+		return bytes.Compare(sets[i].SID[:], sets[j].SID[:]) < 0
+	})
+	return sets
+}
+
 func (s *MysqlGTIDSet) Contain(o GTIDSet) bool {
 	sub, ok := o.(*MysqlGTIDSet)
 	if !ok {
@@ -549,26 +651,12 @@ func (s *MysqlGTIDSet) Equal(o GTIDSet) bool {
 }
 
 func (s *MysqlGTIDSet) String() string {
-	// there is only one element in gtid set
-	if len(s.Sets) == 1 {
-		for _, set := range s.Sets {
-			return set.String()
-		}
-	}
-
-	// sort multi set
 	var buf bytes.Buffer
-	sets := make([]string, 0, len(s.Sets))
-	for _, set := range s.Sets {
-		sets = append(sets, set.String())
-	}
-	sort.Strings(sets)
-
-	sep := ""
-	for _, set := range sets {
-		buf.WriteString(sep)
-		buf.WriteString(set)
-		sep = ","
+	for i, set := range s.UUIDSets() {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.Write(set.Bytes())
 	}
 
 	return hack.String(buf.Bytes())
@@ -577,10 +665,11 @@ func (s *MysqlGTIDSet) String() string {
 func (s *MysqlGTIDSet) Encode() []byte {
 	var buf bytes.Buffer
 
-	_ = binary.Write(&buf, binary.LittleEndian, uint64(len(s.Sets)))
+	sets := s.UUIDSets()
+	_ = binary.Write(&buf, binary.LittleEndian, uint64(len(sets)))
 
-	for i := range s.Sets {
-		s.Sets[i].encode(&buf)
+	for _, set := range sets {
+		set.encode(&buf)
 	}
 
 	return buf.Bytes()
@@ -316,6 +316,70 @@ func TestMysqlNullDecode(t *testing.T) {
 	require.Equal(t, 1, n)
 }
 
+func TestMysqlGTIDSetAlgebra(t *testing.T) {
+	const (
+		sid1 = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+		sid2 = "519ce70f-a893-11e9-a95a-b32dc65a7026"
+	)
+	parse := func(s string) GTIDSetOperations {
+		g, err := ParseMysqlGTIDSet(s)
+		require.NoError(t, err)
+		return g.(GTIDSetOperations)
+	}
+
+	testCases := []struct {
+		left, right                   string
+		union, subtract, intersection string
+	}{
+		{sid1 + ":1-10", sid1 + ":5-20", sid1 + ":1-20", sid1 + ":1-4", sid1 + ":5-10"},
+		{sid1 + ":1-10:20-30", sid1 + ":5-25", sid1 + ":1-30", sid1 + ":1-4:26-30", sid1 + ":5-10:20-25"},
+		{sid1 + ":1-10", sid2 + ":1-10", sid1 + ":1-10," + sid2 + ":1-10", sid1 + ":1-10", ""},
+		{sid2 + ":1-10," + sid1 + ":1-5", sid1 + ":1-5", sid1 + ":1-5," + sid2 + ":1-10", sid2 + ":1-10", sid1 + ":1-5"},
+		{"", sid1 + ":1-5", sid1 + ":1-5", "", ""},
+	}
+	for _, tc := range testCases {
+		left, right := parse(tc.left), parse(tc.right)
+
+		union, err := left.Union(right)
+		require.NoError(t, err)
+		require.Equal(t, tc.union, union.String())
+		subtract, err := left.Subtract(right)
+		require.NoError(t, err)
+		require.Equal(t, tc.subtract, subtract.String())
+		require.Equal(t, tc.subtract == "", IsEmptyGTIDSet(subtract))
+		intersection, err := left.Intersect(right)
+		require.NoError(t, err)
+		require.Equal(t, tc.intersection, intersection.String())
+
+		// the operands are unchanged
+		require.True(t, left.Equal(parse(tc.left)))
+		require.True(t, right.Equal(parse(tc.right)))
+	}
+
+	_, err := parse(sid1 + ":1").Union(&MariadbGTIDSet{})
+	require.Error(t, err)
+	require.True(t, IsEmptyGTIDSet(nil))
+	require.False(t, IsEmptyGTIDSet(parse(sid1+":1")))
+
+	g := parse(sid2 + ":1-3:7," + sid1 + ":2").(*MysqlGTIDSet)
+	u, err := uuid.Parse(sid2)
+	require.NoError(t, err)
+	require.True(t, g.ContainGTID(u, 7))
+	require.False(t, g.ContainGTID(u, 5))
+
+	sets := g.UUIDSets()
+	require.Len(t, sets, 2)
+	require.Equal(t, sid1, sets[0].SID.String())
+	require.Equal(t, IntervalSlice{{1, 4}, {7, 8}}, sets[1].Intervals)
+
+	// the encoding is canonical, the UUID sets are sorted and the empty ones left out
+	g.Sets[sid2].Intervals = nil
+	require.Equal(t, sid1+":2", g.String())
+	require.Equal(t, parse(sid1+":2").Encode(), g.Encode())
+	g.AddSet(NewUUIDSet(u, Interval{1, 2}))
+	require.Equal(t, g.Encode(), parse(sid2+":1,"+sid1+":2").Encode())
+}
+
 func TestMysqlUUIDClone(t *testing.T) {
 	us, err := ParseUUIDSet("de278ad0-2106-11e4-9f8e-6edd0ca20947:1-2")
 	require.NoError(t, err)
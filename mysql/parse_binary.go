@@ -3,6 +3,9 @@ package mysql
 import (
 	"encoding/binary"
 	"math"
+	"time"
+
+	"github.com/pingcap/errors"
 )
 
 func ParseBinaryInt8(data []byte) int8 {
@@ -51,3 +54,51 @@ func ParseBinaryFloat32(data []byte) float32 {
 func ParseBinaryFloat64(data []byte) float64 {
 	return math.Float64frombits(binary.LittleEndian.Uint64(data))
 }
+
+// ParseBinaryDateTime parses a DATE, DATETIME or TIMESTAMP value of the binary protocol, n is its length.
+// The zero date '0000-00-00' is returned as the zero time.Time, the other values are in UTC.
+func ParseBinaryDateTime(n int, data []byte) (time.Time, error) {
+	var year, month, day, hour, min, sec, usec int
+	switch n {
+	case 11:
+		usec = int(binary.LittleEndian.Uint32(data[7:11]))
+		fallthrough
+	case 7:
+		hour, min, sec = int(data[4]), int(data[5]), int(data[6])
+		fallthrough
+	case 4:
+		year, month, day = int(binary.LittleEndian.Uint16(data[:2])), int(data[2]), int(data[3])
+	case 0:
+		return time.Time{}, nil
+	default:
+		return time.Time{}, errors.Errorf("invalid datetime packet length %d", n)
+	}
+
+	if year == 0 && month == 0 && day == 0 {
+		return time.Time{}, nil
+	}
+	return time.Date(year, time.Month(month), day, hour, min, sec, usec*int(time.Microsecond), time.UTC), nil
+}
+
+// ParseBinaryTime parses a TIME value of the binary protocol, n is its length.
+func ParseBinaryTime(n int, data []byte) (time.Duration, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	if n != 8 && n != 12 {
+		return 0, errors.Errorf("invalid time packet length %d", n)
+	}
+
+	d := time.Duration(binary.LittleEndian.Uint32(data[1:5]))*24*time.Hour +
+		time.Duration(data[5])*time.Hour +
+		time.Duration(data[6])*time.Minute +
+		time.Duration(data[7])*time.Second
+	if n == 12 {
+		d += time.Duration(binary.LittleEndian.Uint32(data[8:12])) * time.Microsecond
+	}
+
+	if data[0] == 1 {
+		d = -d
+	}
+	return d, nil
+}
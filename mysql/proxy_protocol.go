@@ -0,0 +1,230 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// see: https://www.haproxy.org/download/2.8/doc/proxy-protocol.txt
+var (
+	proxyProtocolV1Prefix  = []byte("PROXY ")
+	proxyProtocolSignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	ErrBadProxyHeader = errors.New("invalid PROXY protocol header")
+)
+
+const (
+	// longest v1 header, "PROXY TCP6 " followed by two IPv6 addresses, two ports and the separators
+	proxyProtocolV1MaxLen = 107
+
+	proxyProtocolV2Local = 0x20
+	proxyProtocolV2Proxy = 0x21
+
+	proxyProtocolV2TCP4 = 0x11
+	proxyProtocolV2TCP6 = 0x21
+)
+
+// ProxyHeader is the header of the PROXY protocol (v1 or v2) sent by a proxy such as HAProxy or an AWS NLB
+// at the start of a connection, before the MySQL handshake. It tells the address of the client connected to the proxy.
+type ProxyHeader struct {
+	// Version is 1 (text) or 2 (binary)
+	Version byte
+	// Source is the address of the client, Destination the one it connected to. Both are nil when the proxy
+	// did not proxy the connection, e.g. a health check, the addresses of the connection are then the real ones.
+	Source      *net.TCPAddr
+	Destination *net.TCPAddr
+}
+
+// NewProxyHeader creates a header telling the connection was proxied from src to dst.
+func NewProxyHeader(version byte, src, dst *net.TCPAddr) *ProxyHeader {
+	return &ProxyHeader{Version: version, Source: src, Destination: dst}
+}
+
+// ReadProxyHeader reads a v1 or v2 PROXY protocol header from r. It reads nothing past the header.
+func ReadProxyHeader(r io.Reader) (*ProxyHeader, error) {
+	first := make([]byte, 1)
+	if _, err := io.ReadFull(r, first); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	switch first[0] {
+	case proxyProtocolV1Prefix[0]:
+		return readProxyHeaderV1(r)
+	case proxyProtocolSignature[0]:
+		return readProxyHeaderV2(r)
+	default:
+		return nil, ErrBadProxyHeader
+	}
+}
+
+func readProxyHeaderV1(r io.Reader) (*ProxyHeader, error) {
+	// read byte by byte, the header length is only known once its CRLF is found
+	line := []byte{proxyProtocolV1Prefix[0]}
+	b := make([]byte, 1)
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) == proxyProtocolV1MaxLen {
+			return nil, ErrBadProxyHeader
+		}
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, errors.Trace(err)
+		}
+		line = append(line, b[0])
+	}
+
+	if !bytes.HasPrefix(line, proxyProtocolV1Prefix) {
+		return nil, ErrBadProxyHeader
+	}
+
+	h := &ProxyHeader{Version: 1}
+	fields := strings.Split(string(line[len(proxyProtocolV1Prefix):len(line)-2]), " ")
+	switch fields[0] {
+	case "UNKNOWN":
+		// the rest of the line is ignored
+		return h, nil
+	case "TCP4", "TCP6":
+		if len(fields) != 5 {
+			return nil, ErrBadProxyHeader
+		}
+	default:
+		return nil, ErrBadProxyHeader
+	}
+
+	var err error
+	if h.Source, err = parseProxyAddr(fields[1], fields[3]); err != nil {
+		return nil, err
+	}
+	if h.Destination, err = parseProxyAddr(fields[2], fields[4]); err != nil {
+		return nil, err
+	}
+	if (h.Source.IP.To4() != nil) != (fields[0] == "TCP4") {
+		return nil, ErrBadProxyHeader
+	}
+	return h, nil
+}
+
+func parseProxyAddr(ip string, port string) (*net.TCPAddr, error) {
+	addr := &net.TCPAddr{IP: net.ParseIP(ip)}
+	if addr.IP == nil {
+		return nil, ErrBadProxyHeader
+	}
+	if ip4 := addr.IP.To4(); ip4 != nil {
+		addr.IP = ip4
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, ErrBadProxyHeader
+	}
+	addr.Port = int(p)
+	return addr, nil
+}
+
+func readProxyHeaderV2(r io.Reader) (*ProxyHeader, error) {
+	// the rest of the signature, version and command, address family, address length
+	data := make([]byte, len(proxyProtocolSignature)+3)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if !bytes.Equal(data[:len(proxyProtocolSignature)-1], proxyProtocolSignature[1:]) {
+		return nil, ErrBadProxyHeader
+	}
+	data = data[len(proxyProtocolSignature)-1:]
+
+	command, family := data[0], data[1]
+	addrs := make([]byte, binary.BigEndian.Uint16(data[2:]))
+	if _, err := io.ReadFull(r, addrs); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	h := &ProxyHeader{Version: 2}
+	switch command {
+	case proxyProtocolV2Local:
+		return h, nil
+	case proxyProtocolV2Proxy:
+	default:
+		return nil, ErrBadProxyHeader
+	}
+
+	var ipLen int
+	switch family {
+	case proxyProtocolV2TCP4:
+		ipLen = net.IPv4len
+	case proxyProtocolV2TCP6:
+		ipLen = net.IPv6len
+	default:
+		// UDP and unix sockets are not used by MySQL clients, the connection addresses are kept
+		return h, nil
+	}
+
+	// the addresses can be followed by TLVs, they are ignored
+	if len(addrs) < 2*ipLen+4 {
+		return nil, ErrBadProxyHeader
+	}
+	h.Source = &net.TCPAddr{
+		IP:   net.IP(addrs[:ipLen]),
+		Port: int(binary.BigEndian.Uint16(addrs[2*ipLen:])),
+	}
+	h.Destination = &net.TCPAddr{
+		IP:   net.IP(addrs[ipLen : 2*ipLen]),
+		Port: int(binary.BigEndian.Uint16(addrs[2*ipLen+2:])),
+	}
+	return h, nil
+}
+
+// Dump encodes the header to be sent at the start of a connection.
+func (h *ProxyHeader) Dump() ([]byte, error) {
+	switch h.Version {
+	case 1:
+		return h.dumpV1()
+	case 2:
+		return h.dumpV2()
+	default:
+		return nil, errors.Errorf("unsupported PROXY protocol version %d", h.Version)
+	}
+}
+
+func (h *ProxyHeader) dumpV1() ([]byte, error) {
+	if h.Source == nil || h.Destination == nil {
+		return []byte("PROXY UNKNOWN\r\n"), nil
+	}
+
+	family := "TCP4"
+	if h.Source.IP.To4() == nil {
+		family = "TCP6"
+	}
+	if (h.Destination.IP.To4() == nil) != (family == "TCP6") {
+		return nil, errors.Errorf("source %s and destination %s of a PROXY header must have the same IP version", h.Source, h.Destination)
+	}
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", family, h.Source.IP, h.Destination.IP, h.Source.Port, h.Destination.Port)), nil
+}
+
+func (h *ProxyHeader) dumpV2() ([]byte, error) {
+	data := append([]byte{}, proxyProtocolSignature...)
+	if h.Source == nil || h.Destination == nil {
+		return append(data, proxyProtocolV2Local, 0, 0, 0), nil
+	}
+
+	family := byte(proxyProtocolV2TCP4)
+	src, dst := h.Source.IP.To4(), h.Destination.IP.To4()
+	if src == nil {
+		family = proxyProtocolV2TCP6
+		src, dst = h.Source.IP.To16(), h.Destination.IP.To16()
+	}
+	if src == nil || dst == nil || (family == proxyProtocolV2TCP6 && h.Destination.IP.To4() != nil) {
+		return nil, errors.Errorf("source %s and destination %s of a PROXY header must have the same IP version", h.Source, h.Destination)
+	}
+
+	data = append(data, proxyProtocolV2Proxy, family)
+	addrsLen := 2*len(src) + 4
+	data = append(data, byte(addrsLen>>8), byte(addrsLen))
+	data = append(data, src...)
+	data = append(data, dst...)
+	data = append(data, byte(h.Source.Port>>8), byte(h.Source.Port))
+	return append(data, byte(h.Destination.Port>>8), byte(h.Destination.Port)), nil
+}
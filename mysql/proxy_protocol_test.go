@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyHeader(t *testing.T) {
+	v4Src := &net.TCPAddr{IP: net.ParseIP("192.0.2.1").To4(), Port: 56324}
+	v4Dst := &net.TCPAddr{IP: net.ParseIP("192.0.2.2").To4(), Port: 3306}
+	v6Src := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 56324}
+	v6Dst := &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 3306}
+
+	tests := []struct {
+		header *ProxyHeader
+		dump   []byte
+	}{
+		{NewProxyHeader(1, v4Src, v4Dst), []byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 3306\r\n")},
+		{NewProxyHeader(1, v6Src, v6Dst), []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 3306\r\n")},
+		{NewProxyHeader(1, nil, nil), []byte("PROXY UNKNOWN\r\n")},
+		{NewProxyHeader(2, v4Src, v4Dst), append([]byte("\r\n\r\n\x00\r\nQUIT\n\x21\x11\x00\x0c"),
+			192, 0, 2, 1, 192, 0, 2, 2, 0xdc, 0x04, 0x0c, 0xea)},
+		{NewProxyHeader(2, v6Src, v6Dst), nil},
+		{NewProxyHeader(2, nil, nil), []byte("\r\n\r\n\x00\r\nQUIT\n\x20\x00\x00\x00")},
+	}
+
+	for _, tt := range tests {
+		data, err := tt.header.Dump()
+		require.NoError(t, err)
+		if tt.dump != nil {
+			require.Equal(t, tt.dump, data)
+		}
+
+		// the header is read without consuming the data following it
+		r := bytes.NewReader(append(data, "next"...))
+		h, err := ReadProxyHeader(r)
+		require.NoError(t, err)
+		require.Equal(t, tt.header, h)
+		require.Equal(t, 4, r.Len())
+	}
+
+	_, err := NewProxyHeader(1, v4Src, v6Dst).Dump()
+	require.Error(t, err)
+}
+
+func TestReadProxyHeaderV2TLVs(t *testing.T) {
+	// the addresses are followed by a PP2_TYPE_AUTHORITY TLV
+	data := append([]byte("\r\n\r\n\x00\r\nQUIT\n\x21\x11\x00\x13"), 192, 0, 2, 1, 192, 0, 2, 2, 0xdc, 0x04, 0x0c, 0xea)
+	data = append(data, 0x02, 0x00, 0x04, 'h', 'o', 's', 't')
+
+	h, err := ReadProxyHeader(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, "192.0.2.1:56324", h.Source.String())
+	require.Equal(t, "192.0.2.2:3306", h.Destination.String())
+}
+
+func TestReadBadProxyHeader(t *testing.T) {
+	for _, data := range []string{
+		"\x0a\x00\x00\x00\x0a8.0.12",
+		"PROXY TCP4 192.0.2.1 192.0.2.2 56324\r\n",
+		"PROXY TCP4 2001:db8::1 2001:db8::2 56324 3306\r\n",
+		"PROXY TCP4 192.0.2.1 192.0.2.2 56324 65536\r\n",
+		"PROXY UDP4 192.0.2.1 192.0.2.2 56324 3306\r\n",
+		"PROXY TCP4 192.0.2.1 192.0.2.2 56324 3306 " + string(bytes.Repeat([]byte{'a'}, 100)) + "\r\n",
+		"\r\n\r\n\x00\r\nQUIT\n\x22\x11\x00\x00",
+		"\r\n\r\n\x00\r\nQUIT\n\x21\x11\x00\x02\x00\x00",
+	} {
+		_, err := ReadProxyHeader(bytes.NewReader([]byte(data)))
+		require.ErrorIs(t, err, ErrBadProxyHeader, data)
+	}
+}
@@ -0,0 +1,254 @@
+package mysql
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/siddontang/go/hack"
+)
+
+// ReplicaStatusFormat is the format of the resultset of SHOW SLAVE STATUS or SHOW REPLICA STATUS, with the columns of
+// a MySQL version.
+type ReplicaStatusFormat int
+
+const (
+	// SlaveStatus57 is SHOW SLAVE STATUS of MySQL 5.7, 57 columns
+	SlaveStatus57 ReplicaStatusFormat = iota
+	// SlaveStatus80 is SHOW SLAVE STATUS of MySQL 8.0, 60 columns
+	SlaveStatus80
+	// ReplicaStatus80 is SHOW REPLICA STATUS of MySQL 8.0.22+, the columns of SlaveStatus80 with Source and Replica
+	// in their names in place of Master and Slave
+	ReplicaStatus80
+)
+
+// ReplicaStatus is the status of a replication channel of a replica, a row of SHOW SLAVE STATUS. The fields are named
+// after the columns of SHOW SLAVE STATUS, see NewReplicaStatus for their usual values.
+type ReplicaStatus struct {
+	SlaveIOState              string
+	MasterHost                string
+	MasterUser                string
+	MasterPort                uint16
+	ConnectRetry              uint32
+	MasterLogFile             string
+	ReadMasterLogPos          uint64
+	RelayLogFile              string
+	RelayLogPos               uint64
+	RelayMasterLogFile        string
+	SlaveIORunning            string
+	SlaveSQLRunning           string
+	ReplicateDoDB             string
+	ReplicateIgnoreDB         string
+	ReplicateDoTable          string
+	ReplicateIgnoreTable      string
+	ReplicateWildDoTable      string
+	ReplicateWildIgnoreTable  string
+	LastErrno                 uint16
+	LastError                 string
+	SkipCounter               uint32
+	ExecMasterLogPos          uint64
+	RelayLogSpace             uint64
+	UntilCondition            string
+	UntilLogFile              string
+	UntilLogPos               uint64
+	MasterSSLAllowed          string
+	MasterSSLCAFile           string
+	MasterSSLCAPath           string
+	MasterSSLCert             string
+	MasterSSLCipher           string
+	MasterSSLKey              string
+	SecondsBehindMaster       *uint64 // nil is NULL, when the SQL thread or the IO thread is not running
+	MasterSSLVerifyServerCert string
+	LastIOErrno               uint16
+	LastIOError               string
+	LastSQLErrno              uint16
+	LastSQLError              string
+	ReplicateIgnoreServerIDs  string
+	MasterServerID            uint32
+	MasterUUID                string
+	MasterInfoFile            string
+	SQLDelay                  uint32
+	SQLRemainingDelay         *uint32 // nil is NULL, unless the SQL thread waits for SQLDelay
+	SlaveSQLRunningState      string
+	MasterRetryCount          uint64
+	MasterBind                string
+	LastIOErrorTimestamp      string
+	LastSQLErrorTimestamp     string
+	MasterSSLCrl              string
+	MasterSSLCrlpath          string
+	RetrievedGTIDSet          string
+	ExecutedGTIDSet           string
+	AutoPosition              bool
+	ReplicateRewriteDB        string
+	ChannelName               string
+	MasterTLSVersion          string
+	MasterPublicKeyPath       string // 8.0 only
+	GetMasterPublicKey        bool   // 8.0 only
+	NetworkNamespace          string // 8.0 only
+}
+
+// NewReplicaStatus returns the status of a replica replicating from a master, with its threads running and the
+// default values of MySQL.
+func NewReplicaStatus(host string, port uint16, user string) *ReplicaStatus {
+	return &ReplicaStatus{
+		SlaveIOState:              "Waiting for master to send event",
+		MasterHost:                host,
+		MasterUser:                user,
+		MasterPort:                port,
+		ConnectRetry:              60,
+		SlaveIORunning:            "Yes",
+		SlaveSQLRunning:           "Yes",
+		UntilCondition:            "None",
+		MasterSSLAllowed:          "No",
+		MasterSSLVerifyServerCert: "No",
+		MasterInfoFile:            "mysql.slave_master_info",
+		SlaveSQLRunningState:      "Slave has read all relay log; waiting for more updates",
+		MasterRetryCount:          86400,
+	}
+}
+
+// replicaStatusColumn is a column of SHOW SLAVE STATUS, with its name in SHOW REPLICA STATUS.
+type replicaStatusColumn struct {
+	slaveName   string
+	replicaName string
+	// mysql80 is set for the columns of 8.0 only
+	mysql80 bool
+	value   func(s *ReplicaStatus) interface{}
+}
+
+var replicaStatusColumns = []replicaStatusColumn{
+	{"Slave_IO_State", "Replica_IO_State", false, func(s *ReplicaStatus) interface{} { return s.SlaveIOState }},
+	{"Master_Host", "Source_Host", false, func(s *ReplicaStatus) interface{} { return s.MasterHost }},
+	{"Master_User", "Source_User", false, func(s *ReplicaStatus) interface{} { return s.MasterUser }},
+	{"Master_Port", "Source_Port", false, func(s *ReplicaStatus) interface{} { return uint64(s.MasterPort) }},
+	{"Connect_Retry", "Connect_Retry", false, func(s *ReplicaStatus) interface{} { return uint64(s.ConnectRetry) }},
+	{"Master_Log_File", "Source_Log_File", false, func(s *ReplicaStatus) interface{} { return s.MasterLogFile }},
+	{"Read_Master_Log_Pos", "Read_Source_Log_Pos", false, func(s *ReplicaStatus) interface{} { return s.ReadMasterLogPos }},
+	{"Relay_Log_File", "Relay_Log_File", false, func(s *ReplicaStatus) interface{} { return s.RelayLogFile }},
+	{"Relay_Log_Pos", "Relay_Log_Pos", false, func(s *ReplicaStatus) interface{} { return s.RelayLogPos }},
+	{"Relay_Master_Log_File", "Relay_Source_Log_File", false, func(s *ReplicaStatus) interface{} { return s.RelayMasterLogFile }},
+	{"Slave_IO_Running", "Replica_IO_Running", false, func(s *ReplicaStatus) interface{} { return s.SlaveIORunning }},
+	{"Slave_SQL_Running", "Replica_SQL_Running", false, func(s *ReplicaStatus) interface{} { return s.SlaveSQLRunning }},
+	{"Replicate_Do_DB", "Replicate_Do_DB", false, func(s *ReplicaStatus) interface{} { return s.ReplicateDoDB }},
+	{"Replicate_Ignore_DB", "Replicate_Ignore_DB", false, func(s *ReplicaStatus) interface{} { return s.ReplicateIgnoreDB }},
+	{"Replicate_Do_Table", "Replicate_Do_Table", false, func(s *ReplicaStatus) interface{} { return s.ReplicateDoTable }},
+	{"Replicate_Ignore_Table", "Replicate_Ignore_Table", false, func(s *ReplicaStatus) interface{} { return s.ReplicateIgnoreTable }},
+	{"Replicate_Wild_Do_Table", "Replicate_Wild_Do_Table", false, func(s *ReplicaStatus) interface{} { return s.ReplicateWildDoTable }},
+	{"Replicate_Wild_Ignore_Table", "Replicate_Wild_Ignore_Table", false, func(s *ReplicaStatus) interface{} { return s.ReplicateWildIgnoreTable }},
+	{"Last_Errno", "Last_Errno", false, func(s *ReplicaStatus) interface{} { return uint64(s.LastErrno) }},
+	{"Last_Error", "Last_Error", false, func(s *ReplicaStatus) interface{} { return s.LastError }},
+	{"Skip_Counter", "Skip_Counter", false, func(s *ReplicaStatus) interface{} { return uint64(s.SkipCounter) }},
+	{"Exec_Master_Log_Pos", "Exec_Source_Log_Pos", false, func(s *ReplicaStatus) interface{} { return s.ExecMasterLogPos }},
+	{"Relay_Log_Space", "Relay_Log_Space", false, func(s *ReplicaStatus) interface{} { return s.RelayLogSpace }},
+	{"Until_Condition", "Until_Condition", false, func(s *ReplicaStatus) interface{} { return s.UntilCondition }},
+	{"Until_Log_File", "Until_Log_File", false, func(s *ReplicaStatus) interface{} { return s.UntilLogFile }},
+	{"Until_Log_Pos", "Until_Log_Pos", false, func(s *ReplicaStatus) interface{} { return s.UntilLogPos }},
+	{"Master_SSL_Allowed", "Source_SSL_Allowed", false, func(s *ReplicaStatus) interface{} { return s.MasterSSLAllowed }},
+	{"Master_SSL_CA_File", "Source_SSL_CA_File", false, func(s *ReplicaStatus) interface{} { return s.MasterSSLCAFile }},
+	{"Master_SSL_CA_Path", "Source_SSL_CA_Path", false, func(s *ReplicaStatus) interface{} { return s.MasterSSLCAPath }},
+	{"Master_SSL_Cert", "Source_SSL_Cert", false, func(s *ReplicaStatus) interface{} { return s.MasterSSLCert }},
+	{"Master_SSL_Cipher", "Source_SSL_Cipher", false, func(s *ReplicaStatus) interface{} { return s.MasterSSLCipher }},
+	{"Master_SSL_Key", "Source_SSL_Key", false, func(s *ReplicaStatus) interface{} { return s.MasterSSLKey }},
+	{"Seconds_Behind_Master", "Seconds_Behind_Source", false, func(s *ReplicaStatus) interface{} {
+		if s.SecondsBehindMaster == nil {
+			return nil
+		}
+		return *s.SecondsBehindMaster
+	}},
+	{"Master_SSL_Verify_Server_Cert", "Source_SSL_Verify_Server_Cert", false, func(s *ReplicaStatus) interface{} { return s.MasterSSLVerifyServerCert }},
+	{"Last_IO_Errno", "Last_IO_Errno", false, func(s *ReplicaStatus) interface{} { return uint64(s.LastIOErrno) }},
+	{"Last_IO_Error", "Last_IO_Error", false, func(s *ReplicaStatus) interface{} { return s.LastIOError }},
+	{"Last_SQL_Errno", "Last_SQL_Errno", false, func(s *ReplicaStatus) interface{} { return uint64(s.LastSQLErrno) }},
+	{"Last_SQL_Error", "Last_SQL_Error", false, func(s *ReplicaStatus) interface{} { return s.LastSQLError }},
+	{"Replicate_Ignore_Server_Ids", "Replicate_Ignore_Server_Ids", false, func(s *ReplicaStatus) interface{} { return s.ReplicateIgnoreServerIDs }},
+	{"Master_Server_Id", "Source_Server_Id", false, func(s *ReplicaStatus) interface{} { return uint64(s.MasterServerID) }},
+	{"Master_UUID", "Source_UUID", false, func(s *ReplicaStatus) interface{} { return s.MasterUUID }},
+	{"Master_Info_File", "Source_Info_File", false, func(s *ReplicaStatus) interface{} { return s.MasterInfoFile }},
+	{"SQL_Delay", "SQL_Delay", false, func(s *ReplicaStatus) interface{} { return uint64(s.SQLDelay) }},
+	{"SQL_Remaining_Delay", "SQL_Remaining_Delay", false, func(s *ReplicaStatus) interface{} {
+		if s.SQLRemainingDelay == nil {
+			return nil
+		}
+		return uint64(*s.SQLRemainingDelay)
+	}},
+	{"Slave_SQL_Running_State", "Replica_SQL_Running_State", false, func(s *ReplicaStatus) interface{} { return s.SlaveSQLRunningState }},
+	{"Master_Retry_Count", "Source_Retry_Count", false, func(s *ReplicaStatus) interface{} { return s.MasterRetryCount }},
+	{"Master_Bind", "Source_Bind", false, func(s *ReplicaStatus) interface{} { return s.MasterBind }},
+	{"Last_IO_Error_Timestamp", "Last_IO_Error_Timestamp", false, func(s *ReplicaStatus) interface{} { return s.LastIOErrorTimestamp }},
+	{"Last_SQL_Error_Timestamp", "Last_SQL_Error_Timestamp", false, func(s *ReplicaStatus) interface{} { return s.LastSQLErrorTimestamp }},
+	{"Master_SSL_Crl", "Source_SSL_Crl", false, func(s *ReplicaStatus) interface{} { return s.MasterSSLCrl }},
+	{"Master_SSL_Crlpath", "Source_SSL_Crlpath", false, func(s *ReplicaStatus) interface{} { return s.MasterSSLCrlpath }},
+	{"Retrieved_Gtid_Set", "Retrieved_Gtid_Set", false, func(s *ReplicaStatus) interface{} { return s.RetrievedGTIDSet }},
+	{"Executed_Gtid_Set", "Executed_Gtid_Set", false, func(s *ReplicaStatus) interface{} { return s.ExecutedGTIDSet }},
+	{"Auto_Position", "Auto_Position", false, func(s *ReplicaStatus) interface{} { return boolNumber(s.AutoPosition) }},
+	{"Replicate_Rewrite_DB", "Replicate_Rewrite_DB", false, func(s *ReplicaStatus) interface{} { return s.ReplicateRewriteDB }},
+	{"Channel_Name", "Channel_Name", false, func(s *ReplicaStatus) interface{} { return s.ChannelName }},
+	{"Master_TLS_Version", "Source_TLS_Version", false, func(s *ReplicaStatus) interface{} { return s.MasterTLSVersion }},
+	{"Master_public_key_path", "Source_public_key_path", true, func(s *ReplicaStatus) interface{} { return s.MasterPublicKeyPath }},
+	{"Get_master_public_key", "Get_Source_public_key", true, func(s *ReplicaStatus) interface{} { return boolNumber(s.GetMasterPublicKey) }},
+	{"Network_Namespace", "Network_Namespace", true, func(s *ReplicaStatus) interface{} { return s.NetworkNamespace }},
+}
+
+func boolNumber(v bool) uint64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// ReplicaStatusNames returns the names of the columns of a format of SHOW SLAVE STATUS or SHOW REPLICA STATUS.
+func ReplicaStatusNames(format ReplicaStatusFormat) []string {
+	names := make([]string, 0, len(replicaStatusColumns))
+	for _, column := range replicaStatusColumns {
+		if column.mysql80 && format == SlaveStatus57 {
+			continue
+		}
+		if format == ReplicaStatus80 {
+			names = append(names, column.replicaName)
+		} else {
+			names = append(names, column.slaveName)
+		}
+	}
+	return names
+}
+
+// BuildReplicaStatusResultset builds the text resultset answering SHOW SLAVE STATUS or SHOW REPLICA STATUS, with a
+// row for each replication channel, none if the server is not a replica. The numbers are unsigned BIGINT columns,
+// the other columns strings, as MySQL returns them.
+func BuildReplicaStatusResultset(format ReplicaStatusFormat, statuses ...*ReplicaStatus) (*Resultset, error) {
+	if format < SlaveStatus57 || format > ReplicaStatus80 {
+		return nil, errors.Errorf("invalid replica status format %d", format)
+	}
+
+	names := ReplicaStatusNames(format)
+	columns := replicaStatusColumns[:len(names)]
+
+	r := new(Resultset)
+	r.Fields = make([]*Field, len(names))
+	for i, column := range columns {
+		field := &Field{Name: hack.Slice(names[i]), Type: MYSQL_TYPE_VAR_STRING, Charset: 33}
+		if _, ok := column.value(new(ReplicaStatus)).(string); !ok {
+			field.Type = MYSQL_TYPE_LONGLONG
+			field.Charset = 63
+			field.Flag = BINARY_FLAG | UNSIGNED_FLAG
+		}
+		r.Fields[i] = field
+	}
+
+	for _, s := range statuses {
+		var row []byte
+		for _, column := range columns {
+			value := column.value(s)
+			b, err := FormatTextValue(value)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if value == nil {
+				// NULL value is encoded as 0xfb here (without additional info about length)
+				row = append(row, 0xfb)
+			} else {
+				row = AppendLengthEncodedString(row, b)
+			}
+		}
+		r.RowDatas = append(r.RowDatas, row)
+	}
+
+	return r, nil
+}
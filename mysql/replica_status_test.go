@@ -0,0 +1,72 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildReplicaStatusResultset(t *testing.T) {
+	require.Len(t, ReplicaStatusNames(SlaveStatus57), 57)
+	require.Len(t, ReplicaStatusNames(SlaveStatus80), 60)
+	require.Len(t, ReplicaStatusNames(ReplicaStatus80), 60)
+	require.Equal(t, "Master_TLS_Version", ReplicaStatusNames(SlaveStatus57)[56])
+	require.Equal(t, "Network_Namespace", ReplicaStatusNames(SlaveStatus80)[59])
+	require.Equal(t, "Seconds_Behind_Source", ReplicaStatusNames(ReplicaStatus80)[32])
+
+	s := NewReplicaStatus("10.0.0.1", 3306, "repl")
+	s.MasterLogFile = "mysql-bin.000002"
+	s.ReadMasterLogPos = 1234
+	delay := uint64(3)
+	s.SecondsBehindMaster = &delay
+	s.MasterUUID = "5c9cc6ee-1b4f-11ee-8f0d-0242ac110002"
+	s.AutoPosition = true
+
+	stopped := NewReplicaStatus("10.0.0.2", 3306, "repl")
+	stopped.SlaveIORunning = "No"
+	stopped.ChannelName = "other"
+
+	for _, format := range []ReplicaStatusFormat{SlaveStatus57, SlaveStatus80, ReplicaStatus80} {
+		r, err := BuildReplicaStatusResultset(format, s, stopped)
+		require.NoError(t, err)
+		names := ReplicaStatusNames(format)
+		require.Len(t, r.Fields, len(names))
+		require.Len(t, r.RowDatas, 2)
+
+		values, err := r.RowDatas[0].Parse(r.Fields, false, nil)
+		require.NoError(t, err)
+		row := make(map[string]*FieldValue)
+		for i, name := range names {
+			row[name] = &values[i]
+		}
+		if format == ReplicaStatus80 {
+			require.Equal(t, "10.0.0.1", string(row["Source_Host"].AsString()))
+			require.Equal(t, uint64(1234), row["Read_Source_Log_Pos"].AsUint64())
+			require.Equal(t, uint64(3), row["Seconds_Behind_Source"].AsUint64())
+			require.Equal(t, "Yes", string(row["Replica_IO_Running"].AsString()))
+		} else {
+			require.Equal(t, "10.0.0.1", string(row["Master_Host"].AsString()))
+			require.Equal(t, uint64(1234), row["Read_Master_Log_Pos"].AsUint64())
+			require.Equal(t, uint64(3), row["Seconds_Behind_Master"].AsUint64())
+			require.Equal(t, s.MasterUUID, string(row["Master_UUID"].AsString()))
+		}
+		require.Equal(t, uint64(1), row["Auto_Position"].AsUint64())
+		require.Equal(t, "", string(row["Last_Error"].AsString()))
+		require.Equal(t, FieldValueType(FieldValueTypeNull), row["SQL_Remaining_Delay"].Type)
+
+		values, err = r.RowDatas[1].Parse(r.Fields, false, nil)
+		require.NoError(t, err)
+		require.Equal(t, FieldValueType(FieldValueTypeNull), values[32].Type)
+		require.Equal(t, "No", string(values[10].AsString()))
+		require.Equal(t, "other", string(values[55].AsString()))
+	}
+
+	// not a replica
+	r, err := BuildReplicaStatusResultset(SlaveStatus80)
+	require.NoError(t, err)
+	require.Len(t, r.Fields, 60)
+	require.Empty(t, r.RowDatas)
+
+	_, err = BuildReplicaStatusResultset(ReplicaStatusFormat(5))
+	require.Error(t, err)
+}
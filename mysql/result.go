@@ -1,19 +1,61 @@
 package mysql
 
+import (
+	"fmt"
+)
+
 type Result struct {
-	Status   uint16
+	Status uint16
+	// Warnings is the number of warnings of the statement, the length of WarningList if it is zero
 	Warnings uint16
+	// WarningList, if set, is stored by the server and returned to the client asking SHOW WARNINGS
+	WarningList []Warning
 
 	InsertId     uint64
 	AffectedRows uint64
+	// Info is the human readable information of the OK packet, e.g. "Rows matched: 1  Changed: 0  Warnings: 0"
+	// for an UPDATE, see MatchedRows
+	Info string
 
 	*Resultset
+
+	// Streamer, if set, is used by the server to write the resultset row by row instead of Resultset
+	Streamer *ResultStreamer
+	// LocalInfile, if set, makes the server request a file from the client instead of sending a result
+	LocalInfile *LocalInfileRequest
+	// Next, if set, is written by the server right after this result, e.g. for the result sets of a CALL
+	// statement followed by its OUT parameters (with SERVER_PS_OUT_PARAMS in Status) and the final OK
+	Next *Result
+	// SessionStateChanges, if set, are sent by the server in the OK packet to a CLIENT_SESSION_TRACK client
+	SessionStateChanges []SessionStateChange
+}
+
+// Warning is a row of SHOW WARNINGS, Level is "Note", "Warning" or "Error".
+type Warning struct {
+	Level   string
+	Code    uint16
+	Message string
 }
 
 type Executer interface {
 	Execute(query string, args ...interface{}) (*Result, error)
 }
 
+// HasMoreResults tells if the command returned more results after this one, e.g. the results of the next
+// statement of a multi-statement query or the final OK of a CALL statement.
+func (r *Result) HasMoreResults() bool {
+	return r != nil && r.Status&SERVER_MORE_RESULTS_EXISTS > 0
+}
+
+// MatchedRows returns the number of rows matched and changed by an UPDATE, read from its Info. AffectedRows is the
+// number of rows changed, unless the client sets CLIENT_FOUND_ROWS, so an UPDATE changing nothing can be detected.
+func (r *Result) MatchedRows() (matched uint64, changed uint64, ok bool) {
+	if _, err := fmt.Sscanf(r.Info, "Rows matched: %d Changed: %d", &matched, &changed); err != nil {
+		return 0, 0, false
+	}
+	return matched, changed, true
+}
+
 func (r *Result) Close() {
 	if r.Resultset != nil {
 		r.Resultset.returnToPool()
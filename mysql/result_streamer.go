@@ -0,0 +1,205 @@
+package mysql
+
+import (
+	"io"
+	"math"
+
+	"github.com/pingcap/errors"
+)
+
+// ResultStreamer produces the rows of a resultset one at a time, so that a server can write huge resultsets to
+// the wire incrementally instead of materializing them in a Resultset first.
+//
+// Set it as Result.Streamer in the result returned by a server handler, the server writes the column
+// definitions in Fields and then every row returned by the row function until it returns io.EOF.
+type ResultStreamer struct {
+	Fields []*Field
+
+	next func() ([]interface{}, error)
+}
+
+// NewResultStreamer creates a streamer calling next for every row, next must return io.EOF once all
+// rows have been produced. Any other error aborts the resultset and is sent to the client.
+func NewResultStreamer(fields []*Field, next func() ([]interface{}, error)) *ResultStreamer {
+	return &ResultStreamer{Fields: fields, next: next}
+}
+
+// NewChanResultStreamer creates a streamer reading the rows from the channel until it is closed.
+func NewChanResultStreamer(fields []*Field, rows <-chan []interface{}) *ResultStreamer {
+	return NewResultStreamer(fields, func() ([]interface{}, error) {
+		row, ok := <-rows
+		if !ok {
+			return nil, io.EOF
+		}
+		return row, nil
+	})
+}
+
+// Next returns the values of the next row, or io.EOF if there are no more rows.
+func (s *ResultStreamer) Next() ([]interface{}, error) {
+	row, err := s.next()
+	if err != nil {
+		return nil, err
+	}
+	if len(row) != len(s.Fields) {
+		return nil, errors.Errorf("row has %d column not equal %d", len(row), len(s.Fields))
+	}
+	return row, nil
+}
+
+// NextRowData returns the next row encoded with the text or binary protocol, or io.EOF if there are no more rows.
+func (s *ResultStreamer) NextRowData(binary bool) (RowData, error) {
+	row, err := s.Next()
+	if err != nil {
+		return nil, err
+	}
+	if binary {
+		return EncodeBinaryRow(s.Fields, row)
+	}
+	return EncodeTextRow(row)
+}
+
+// EncodeTextRow encodes the values of a row with the text protocol.
+// see: https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_query_response_text_resultset_row.html
+func EncodeTextRow(values []interface{}) (RowData, error) {
+	var row []byte
+	for _, value := range values {
+		b, err := FormatTextValue(value)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		if b == nil {
+			// NULL value is encoded as 0xfb here (without additional info about length)
+			row = append(row, 0xfb)
+		} else {
+			row = AppendLengthEncodedString(row, b)
+		}
+	}
+	return row, nil
+}
+
+// EncodeBinaryRow encodes the values of a row with the binary protocol, according to the type of each field.
+// see: https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_binary_resultset.html
+func EncodeBinaryRow(fields []*Field, values []interface{}) (RowData, error) {
+	if len(fields) != len(values) {
+		return nil, errors.Errorf("row has %d column not equal %d", len(values), len(fields))
+	}
+
+	bitmapLen := (len(fields) + 7 + 2) >> 3
+
+	row := make([]byte, 1+bitmapLen, 1+bitmapLen+8*len(fields))
+	nullBitmap := row[1:]
+
+	for i, value := range values {
+		if value == nil {
+			nullBitmap[(i+2)/8] |= 1 << (uint(i+2) % 8)
+			continue
+		}
+
+		switch fields[i].Type {
+		case MYSQL_TYPE_NULL:
+			nullBitmap[(i+2)/8] |= 1 << (uint(i+2) % 8)
+
+		case MYSQL_TYPE_TINY:
+			n, err := binaryInteger(value)
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, byte(n))
+
+		case MYSQL_TYPE_SHORT, MYSQL_TYPE_YEAR:
+			n, err := binaryInteger(value)
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, Uint16ToBytes(uint16(n))...)
+
+		case MYSQL_TYPE_INT24, MYSQL_TYPE_LONG:
+			n, err := binaryInteger(value)
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, Uint32ToBytes(uint32(n))...)
+
+		case MYSQL_TYPE_LONGLONG:
+			n, err := binaryInteger(value)
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, Uint64ToBytes(n)...)
+
+		case MYSQL_TYPE_FLOAT:
+			f, err := binaryFloat(value)
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, Uint32ToBytes(math.Float32bits(float32(f)))...)
+
+		case MYSQL_TYPE_DOUBLE:
+			f, err := binaryFloat(value)
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, Uint64ToBytes(math.Float64bits(f))...)
+
+		case MYSQL_TYPE_DECIMAL, MYSQL_TYPE_NEWDECIMAL, MYSQL_TYPE_VARCHAR,
+			MYSQL_TYPE_BIT, MYSQL_TYPE_ENUM, MYSQL_TYPE_SET, MYSQL_TYPE_TINY_BLOB,
+			MYSQL_TYPE_MEDIUM_BLOB, MYSQL_TYPE_LONG_BLOB, MYSQL_TYPE_BLOB,
+			MYSQL_TYPE_VAR_STRING, MYSQL_TYPE_STRING, MYSQL_TYPE_GEOMETRY, MYSQL_TYPE_JSON:
+			b, err := FormatTextValue(value)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			row = AppendLengthEncodedString(row, b)
+
+		default:
+			return nil, errors.Errorf("unsupport field type %d for binary row", fields[i].Type)
+		}
+	}
+
+	return row, nil
+}
+
+func binaryInteger(value interface{}) (uint64, error) {
+	switch v := value.(type) {
+	case int8:
+		return uint64(v), nil
+	case int16:
+		return uint64(v), nil
+	case int32:
+		return uint64(v), nil
+	case int64:
+		return uint64(v), nil
+	case int:
+		return uint64(v), nil
+	case uint8:
+		return uint64(v), nil
+	case uint16:
+		return uint64(v), nil
+	case uint32:
+		return uint64(v), nil
+	case uint64:
+		return v, nil
+	case uint:
+		return uint64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return 0, errors.Errorf("invalid type %T for integer field", value)
+	}
+}
+
+func binaryFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	default:
+		return 0, errors.Errorf("invalid type %T for float field", value)
+	}
+}
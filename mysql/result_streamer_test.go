@@ -0,0 +1,53 @@
+package mysql
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultStreamerBinaryRow(t *testing.T) {
+	fields := []*Field{
+		{Name: []byte("a"), Type: MYSQL_TYPE_TINY},
+		{Name: []byte("b"), Type: MYSQL_TYPE_LONG, Flag: UNSIGNED_FLAG},
+		{Name: []byte("c"), Type: MYSQL_TYPE_DOUBLE},
+		{Name: []byte("d"), Type: MYSQL_TYPE_VAR_STRING},
+		{Name: []byte("e"), Type: MYSQL_TYPE_LONGLONG},
+	}
+	values := [][]interface{}{
+		{int8(-1), uint32(42), 1.5, "hello", nil},
+	}
+
+	i := 0
+	s := NewResultStreamer(fields, func() ([]interface{}, error) {
+		if i == len(values) {
+			return nil, io.EOF
+		}
+		i++
+		return values[i-1], nil
+	})
+
+	row, err := s.NextRowData(true)
+	require.NoError(t, err)
+
+	fv, err := row.ParseBinary(fields, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), fv[0].AsInt64())
+	require.Equal(t, uint64(42), fv[1].AsUint64())
+	require.Equal(t, 1.5, fv[2].AsFloat64())
+	require.Equal(t, "hello", string(fv[3].AsString()))
+	require.Nil(t, fv[4].Value())
+
+	_, err = s.NextRowData(true)
+	require.Equal(t, io.EOF, err)
+}
+
+func TestResultStreamerColumnMismatch(t *testing.T) {
+	s := NewResultStreamer([]*Field{{Name: []byte("a")}}, func() ([]interface{}, error) {
+		return []interface{}{1, 2}, nil
+	})
+
+	_, err := s.NextRowData(false)
+	require.Error(t, err)
+}
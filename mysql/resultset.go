@@ -1,11 +1,14 @@
 package mysql
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/pingcap/errors"
+	"github.com/shopspring/decimal"
 	"github.com/siddontang/go/hack"
 )
 
@@ -33,6 +36,10 @@ type Resultset struct {
 
 	Streaming     StreamingType
 	StreamingDone bool
+
+	// TimeLocation makes GetValue return the DATE, DATETIME and TIMESTAMP values as time.Time in this location
+	// instead of strings, if set
+	TimeLocation *time.Location
 }
 
 var (
@@ -59,6 +66,7 @@ func (r *Resultset) Reset(fieldsCount int) {
 	r.Fields = r.Fields[:0]
 	r.Values = r.Values[:0]
 	r.RowDatas = r.RowDatas[:0]
+	r.TimeLocation = nil
 
 	if r.FieldNames != nil {
 		for k := range r.FieldNames {
@@ -99,6 +107,13 @@ func (r *Resultset) GetValue(row, column int) (interface{}, error) {
 		return nil, errors.Errorf("invalid column index %d", column)
 	}
 
+	if r.TimeLocation != nil && r.Values[row][column].Type == FieldValueTypeString {
+		switch r.Fields[column].Type {
+		case MYSQL_TYPE_DATE, MYSQL_TYPE_NEWDATE, MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIMESTAMP:
+			return ParseDateTime(string(r.Values[row][column].AsString()), r.TimeLocation)
+		}
+	}
+
 	return r.Values[row][column].Value(), nil
 }
 
@@ -271,6 +286,8 @@ func (r *Resultset) GetString(row, column int) (string, error) {
 		return strconv.FormatFloat(float64(v), 'f', -1, 64), nil
 	case float64:
 		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return string(r.Values[row][column].AsString()), nil
 	case nil:
 		return "", nil
 	default:
@@ -285,3 +302,198 @@ func (r *Resultset) GetStringByName(row int, name string) (string, error) {
 		return r.GetString(row, column)
 	}
 }
+
+// GetUTF8String returns a string value converted to UTF-8 from the character set of its column definition, the
+// one of the connection, or of the column with character_set_results=NULL. The binary values are returned as
+// GetString does.
+func (r *Resultset) GetUTF8String(row, column int) (string, error) {
+	s, err := r.GetString(row, column)
+	if err != nil {
+		return "", err
+	}
+	return DecodeString(r.Fields[column].Charset, s), nil
+}
+
+func (r *Resultset) GetUTF8StringByName(row int, name string) (string, error) {
+	if column, err := r.NameIndex(name); err != nil {
+		return "", err
+	} else {
+		return r.GetUTF8String(row, column)
+	}
+}
+
+// GetTime returns a DATE, DATETIME or TIMESTAMP value in TimeLocation, in UTC if not set. NULL and the zero date
+// are returned as the zero time.Time.
+func (r *Resultset) GetTime(row, column int) (time.Time, error) {
+	d, err := r.GetValue(row, column)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	loc := r.TimeLocation
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	switch v := d.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return ParseDateTime(v, loc)
+	case []byte:
+		return ParseDateTime(string(v), loc)
+	case nil:
+		return time.Time{}, nil
+	default:
+		return time.Time{}, errors.Errorf("data type is %T", v)
+	}
+}
+
+func (r *Resultset) GetTimeByName(row int, name string) (time.Time, error) {
+	if column, err := r.NameIndex(name); err != nil {
+		return time.Time{}, err
+	} else {
+		return r.GetTime(row, column)
+	}
+}
+
+// GetDuration returns a TIME value, which can be negative and longer than a day. NULL is returned as 0.
+func (r *Resultset) GetDuration(row, column int) (time.Duration, error) {
+	d, err := r.GetValue(row, column)
+	if err != nil {
+		return 0, err
+	}
+
+	switch v := d.(type) {
+	case string:
+		return ParseDuration(v)
+	case []byte:
+		return ParseDuration(string(v))
+	case nil:
+		return 0, nil
+	default:
+		return 0, errors.Errorf("data type is %T", v)
+	}
+}
+
+func (r *Resultset) GetDurationByName(row int, name string) (time.Duration, error) {
+	if column, err := r.NameIndex(name); err != nil {
+		return 0, err
+	} else {
+		return r.GetDuration(row, column)
+	}
+}
+
+// GetDecimal returns a DECIMAL value without losing its precision, or a number of another type, as GetMysqlDecimal.
+// NULL is returned as zero.
+func (r *Resultset) GetDecimal(row, column int) (decimal.Decimal, error) {
+	d, err := r.GetMysqlDecimal(row, column)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return d.Decimal(), nil
+}
+
+func (r *Resultset) GetDecimalByName(row int, name string) (decimal.Decimal, error) {
+	if column, err := r.NameIndex(name); err != nil {
+		return decimal.Zero, err
+	} else {
+		return r.GetDecimal(row, column)
+	}
+}
+
+// GetMysqlDecimal returns a DECIMAL value as a Decimal, exact and with the scale of the column, or a number of
+// another type. NULL is returned as zero.
+func (r *Resultset) GetMysqlDecimal(row, column int) (Decimal, error) {
+	d, err := r.GetValue(row, column)
+	if err != nil {
+		return Decimal{}, err
+	}
+
+	switch v := d.(type) {
+	case int64:
+		return NewDecimalFromInt(v), nil
+	case uint64:
+		return ParseDecimal(strconv.FormatUint(v, 10))
+	case float64:
+		if r.Fields[column].Type == MYSQL_TYPE_FLOAT {
+			return ParseDecimal(strconv.FormatFloat(v, 'f', -1, 32))
+		}
+		return ParseDecimal(strconv.FormatFloat(v, 'f', -1, 64))
+	case string:
+		return ParseDecimal(v)
+	case []byte:
+		return ParseDecimal(string(v))
+	case nil:
+		return Decimal{}, nil
+	default:
+		return Decimal{}, errors.Errorf("data type is %T", v)
+	}
+}
+
+func (r *Resultset) GetMysqlDecimalByName(row int, name string) (Decimal, error) {
+	if column, err := r.NameIndex(name); err != nil {
+		return Decimal{}, err
+	} else {
+		return r.GetMysqlDecimal(row, column)
+	}
+}
+
+// GetGeometry returns the value of a spatial column, its SRID and its shape. NULL is returned as the zero Geometry.
+func (r *Resultset) GetGeometry(row, column int) (Geometry, error) {
+	d, err := r.GetValue(row, column)
+	if err != nil {
+		return Geometry{}, err
+	}
+
+	switch v := d.(type) {
+	case string:
+		return ParseGeometry([]byte(v))
+	case []byte:
+		return ParseGeometry(v)
+	case nil:
+		return Geometry{}, nil
+	default:
+		return Geometry{}, errors.Errorf("data type is %T", v)
+	}
+}
+
+func (r *Resultset) GetGeometryByName(row int, name string) (Geometry, error) {
+	if column, err := r.NameIndex(name); err != nil {
+		return Geometry{}, err
+	} else {
+		return r.GetGeometry(row, column)
+	}
+}
+
+// GetJSON returns a JSON value, checking that it is valid. NULL is returned as nil.
+func (r *Resultset) GetJSON(row, column int) (json.RawMessage, error) {
+	d, err := r.GetValue(row, column)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	switch v := d.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = append([]byte(nil), v...)
+	case nil:
+		return nil, nil
+	default:
+		return nil, errors.Errorf("data type is %T", v)
+	}
+	if !json.Valid(data) {
+		return nil, errors.Errorf("invalid JSON value %q", data)
+	}
+	return data, nil
+}
+
+func (r *Resultset) GetJSONByName(row int, name string) (json.RawMessage, error) {
+	if column, err := r.NameIndex(name); err != nil {
+		return nil, err
+	} else {
+		return r.GetJSON(row, column)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/pingcap/errors"
+	"github.com/shopspring/decimal"
 	"github.com/siddontang/go/hack"
 )
 
@@ -38,6 +39,12 @@ func FormatTextValue(value interface{}) ([]byte, error) {
 		return v, nil
 	case string:
 		return hack.Slice(v), nil
+	case Decimal:
+		return []byte(v.String()), nil
+	case decimal.Decimal:
+		return []byte(FromDecimal(v).String()), nil
+	case Geometry:
+		return v.Bytes(), nil
 	case nil:
 		return nil, nil
 	default:
@@ -75,6 +82,12 @@ func formatBinaryValue(value interface{}) ([]byte, error) {
 		return v, nil
 	case string:
 		return hack.Slice(v), nil
+	case Decimal:
+		return []byte(v.String()), nil
+	case decimal.Decimal:
+		return []byte(FromDecimal(v).String()), nil
+	case Geometry:
+		return v.Bytes(), nil
 	default:
 		return nil, errors.Errorf("invalid type %T", value)
 	}
@@ -88,6 +101,10 @@ func fieldType(value interface{}) (typ uint8, err error) {
 		typ = MYSQL_TYPE_LONGLONG
 	case float32, float64:
 		typ = MYSQL_TYPE_DOUBLE
+	case Decimal, decimal.Decimal:
+		typ = MYSQL_TYPE_NEWDECIMAL
+	case Geometry:
+		typ = MYSQL_TYPE_GEOMETRY
 	case string, []byte:
 		typ = MYSQL_TYPE_VAR_STRING
 	case nil:
@@ -99,7 +116,7 @@ func fieldType(value interface{}) (typ uint8, err error) {
 }
 
 func formatField(field *Field, value interface{}) error {
-	switch value.(type) {
+	switch v := value.(type) {
 	case int8, int16, int32, int64, int:
 		field.Charset = 63
 		field.Flag = BINARY_FLAG | NOT_NULL_FLAG
@@ -109,6 +126,17 @@ func formatField(field *Field, value interface{}) error {
 	case float32, float64:
 		field.Charset = 63
 		field.Flag = BINARY_FLAG | NOT_NULL_FLAG
+	case Decimal:
+		field.Charset = 63
+		field.Flag = BINARY_FLAG | NOT_NULL_FLAG
+		field.Decimal = uint8(v.Scale())
+	case decimal.Decimal:
+		field.Charset = 63
+		field.Flag = BINARY_FLAG | NOT_NULL_FLAG
+		field.Decimal = uint8(FromDecimal(v).Scale())
+	case Geometry:
+		field.Charset = 63
+		field.Flag = BINARY_FLAG | BLOB_FLAG
 	case string, []byte:
 		field.Charset = 33
 	case nil:
@@ -175,7 +203,7 @@ func BuildSimpleTextResultset(names []string, values [][]interface{}) (*Resultse
 				// NULL value is encoded as 0xfb here (without additional info about length)
 				row = append(row, 0xfb)
 			} else {
-				row = append(row, PutLengthEncodedString(b)...)
+				row = AppendLengthEncodedString(row, b)
 			}
 		}
 
@@ -230,9 +258,10 @@ func BuildSimpleBinaryResultset(names []string, values [][]interface{}) (*Result
 				return nil, errors.Trace(err)
 			}
 
-			if r.Fields[j].Type == MYSQL_TYPE_VAR_STRING {
-				row = append(row, PutLengthEncodedString(b)...)
-			} else {
+			switch r.Fields[j].Type {
+			case MYSQL_TYPE_VAR_STRING, MYSQL_TYPE_NEWDECIMAL, MYSQL_TYPE_GEOMETRY:
+				row = AppendLengthEncodedString(row, b)
+			default:
 				row = append(row, b...)
 			}
 		}
@@ -0,0 +1,128 @@
+package mysql
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/utils"
+)
+
+func TestResultsetTypedGetters(t *testing.T) {
+	r := NewResultset(6)
+	for i, f := range []struct {
+		name string
+		typ  uint8
+	}{
+		{"d", MYSQL_TYPE_NEWDECIMAL},
+		{"f", MYSQL_TYPE_FLOAT},
+		{"i", MYSQL_TYPE_LONGLONG},
+		{"j", MYSQL_TYPE_JSON},
+		{"t", MYSQL_TYPE_TIME},
+		{"dt", MYSQL_TYPE_DATETIME},
+	} {
+		r.Fields[i] = &Field{Name: []byte(f.name), Type: f.typ}
+		r.FieldNames[f.name] = i
+	}
+	r.Values = [][]FieldValue{
+		{
+			{Type: FieldValueTypeString, Str: []byte("12345678901234567890.123456789")},
+			{Type: FieldValueTypeFloat, Val: utils.Float64ToUint64(float64(float32(1.1)))},
+			{Type: FieldValueTypeSigned, Val: uint64(0xffffffffffffffff)},
+			{Type: FieldValueTypeString, Str: []byte(`{"a": [1, 2]}`)},
+			{Type: FieldValueTypeString, Str: []byte("-25:00:00.5")},
+			{Type: FieldValueTypeString, Str: []byte("2023-04-05 06:07:08")},
+		},
+		{
+			{Type: FieldValueTypeNull},
+			{Type: FieldValueTypeNull},
+			{Type: FieldValueTypeNull},
+			{Type: FieldValueTypeNull},
+			{Type: FieldValueTypeNull},
+			{Type: FieldValueTypeNull},
+		},
+	}
+
+	d, err := r.GetDecimalByName(0, "d")
+	require.NoError(t, err)
+	require.Equal(t, "12345678901234567890.123456789", d.String())
+	d, err = r.GetDecimalByName(0, "f")
+	require.NoError(t, err)
+	require.Equal(t, "1.1", d.String())
+	d, err = r.GetDecimalByName(0, "i")
+	require.NoError(t, err)
+	require.Equal(t, "-1", d.String())
+
+	md, err := r.GetMysqlDecimalByName(0, "d")
+	require.NoError(t, err)
+	require.Equal(t, "12345678901234567890.123456789", md.String())
+	require.Equal(t, int32(9), md.Scale())
+	md, err = r.GetMysqlDecimalByName(0, "f")
+	require.NoError(t, err)
+	require.Equal(t, "1.1", md.String())
+	md, err = r.GetMysqlDecimalByName(0, "i")
+	require.NoError(t, err)
+	require.Equal(t, "-1", md.String())
+
+	j, err := r.GetJSONByName(0, "j")
+	require.NoError(t, err)
+	var v map[string][]int
+	require.NoError(t, json.Unmarshal(j, &v))
+	require.Equal(t, []int{1, 2}, v["a"])
+
+	dur, err := r.GetDurationByName(0, "t")
+	require.NoError(t, err)
+	require.Equal(t, -(25*time.Hour + 500*time.Millisecond), dur)
+
+	tm, err := r.GetTimeByName(0, "dt")
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC), tm)
+
+	// NULL is the zero value
+	d, err = r.GetDecimal(1, 0)
+	require.NoError(t, err)
+	require.True(t, d.IsZero())
+	md, err = r.GetMysqlDecimal(1, 0)
+	require.NoError(t, err)
+	require.True(t, md.IsZero())
+	j, err = r.GetJSON(1, 3)
+	require.NoError(t, err)
+	require.Nil(t, j)
+	dur, err = r.GetDuration(1, 4)
+	require.NoError(t, err)
+	require.Zero(t, dur)
+	tm, err = r.GetTime(1, 5)
+	require.NoError(t, err)
+	require.True(t, tm.IsZero())
+
+	// the values of the wrong type are errors
+	_, err = r.GetJSON(0, 2)
+	require.Error(t, err)
+	_, err = r.GetDecimal(0, 3)
+	require.Error(t, err)
+	_, err = r.GetMysqlDecimal(0, 3)
+	require.Error(t, err)
+	r.Values[0][3].Str = []byte("{")
+	_, err = r.GetJSON(0, 3)
+	require.Error(t, err)
+}
+
+func TestResultsetGetUTF8String(t *testing.T) {
+	r := NewResultset(2)
+	r.Fields[0] = &Field{Name: []byte("s"), Type: MYSQL_TYPE_VAR_STRING, Charset: 28}
+	r.Fields[1] = &Field{Name: []byte("b"), Type: MYSQL_TYPE_BLOB, Charset: 63}
+	r.FieldNames = map[string]int{"s": 0, "b": 1}
+	r.Values = [][]FieldValue{{
+		{Type: FieldValueTypeString, Str: []byte("\xd6\xd0")},
+		{Type: FieldValueTypeString, Str: []byte("\xd6\xd0")},
+	}}
+
+	s, err := r.GetUTF8StringByName(0, "s")
+	require.NoError(t, err)
+	require.Equal(t, "中", s)
+	s, err = r.GetUTF8StringByName(0, "b")
+	require.NoError(t, err)
+	require.Equal(t, "\xd6\xd0", s)
+}
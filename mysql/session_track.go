@@ -0,0 +1,136 @@
+package mysql
+
+import (
+	"github.com/pingcap/errors"
+)
+
+// SessionStateChange is a change of the session state reported in an OK packet, as a MySQL 5.7+ server
+// does for clients negotiating CLIENT_SESSION_TRACK.
+//
+// Type is one of the SESSION_TRACK_* constants. Name is only used by SESSION_TRACK_SYSTEM_VARIABLES.
+type SessionStateChange struct {
+	Type  byte
+	Name  string
+	Value string
+}
+
+// NewSystemVariableChange reports the new value of a session system variable.
+func NewSystemVariableChange(name string, value string) SessionStateChange {
+	return SessionStateChange{Type: SESSION_TRACK_SYSTEM_VARIABLES, Name: name, Value: value}
+}
+
+// NewSchemaChange reports the new default schema.
+func NewSchemaChange(schema string) SessionStateChange {
+	return SessionStateChange{Type: SESSION_TRACK_SCHEMA, Value: schema}
+}
+
+// NewStateChange reports that the session state changed, e.g. a user variable was set.
+func NewStateChange() SessionStateChange {
+	return SessionStateChange{Type: SESSION_TRACK_STATE_CHANGE, Value: "1"}
+}
+
+// NewGTIDsChange reports the GTIDs of the transactions committed by the statement.
+func NewGTIDsChange(gtids string) SessionStateChange {
+	return SessionStateChange{Type: SESSION_TRACK_GTIDS, Value: gtids}
+}
+
+// Dump encodes the change as an entry of the session state info of an OK packet.
+func (s SessionStateChange) Dump() []byte {
+	var data []byte
+	switch s.Type {
+	case SESSION_TRACK_SYSTEM_VARIABLES:
+		data = append(PutLengthEncodedString([]byte(s.Name)), PutLengthEncodedString([]byte(s.Value))...)
+	case SESSION_TRACK_GTIDS:
+		// the only encoding specification defined is 0, a list of GTIDs as text
+		data = append([]byte{0}, PutLengthEncodedString([]byte(s.Value))...)
+	default:
+		data = PutLengthEncodedString([]byte(s.Value))
+	}
+
+	return append(append([]byte{s.Type}, PutLengthEncodedInt(uint64(len(data)))...), data...)
+}
+
+// ParseSessionStateChanges decodes the session state info of an OK packet.
+func ParseSessionStateChanges(data []byte) ([]SessionStateChange, error) {
+	var changes []SessionStateChange
+	for len(data) > 0 {
+		typ := data[0]
+		entry, _, n, err := LengthEncodedString(data[1:])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		data = data[1+n:]
+
+		change := SessionStateChange{Type: typ}
+		switch typ {
+		case SESSION_TRACK_SYSTEM_VARIABLES:
+			name, _, n, err := LengthEncodedString(entry)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			value, _, _, err := LengthEncodedString(entry[n:])
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			change.Name, change.Value = string(name), string(value)
+		case SESSION_TRACK_GTIDS:
+			if len(entry) == 0 {
+				return nil, ErrMalformPacket
+			}
+			// skip the encoding specification
+			value, _, _, err := LengthEncodedString(entry[1:])
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			change.Value = string(value)
+		case SESSION_TRACK_SCHEMA, SESSION_TRACK_STATE_CHANGE,
+			SESSION_TRACK_TRANSACTION_CHARACTERISTICS, SESSION_TRACK_TRANSACTION_STATE:
+			value, _, _, err := LengthEncodedString(entry)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			change.Value = string(value)
+		default:
+			// unknown types are kept undecoded
+			change.Value = string(entry)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// SessionSchema returns the default schema set by the statement, reported to a CLIENT_SESSION_TRACK client.
+func (r *Result) SessionSchema() (string, bool) {
+	for i := len(r.SessionStateChanges) - 1; i >= 0; i-- {
+		if r.SessionStateChanges[i].Type == SESSION_TRACK_SCHEMA {
+			return r.SessionStateChanges[i].Value, true
+		}
+	}
+	return "", false
+}
+
+// SessionSystemVariables returns the session system variables changed by the statement, as tracked by the
+// session_track_system_variables of the server.
+func (r *Result) SessionSystemVariables() map[string]string {
+	var variables map[string]string
+	for _, change := range r.SessionStateChanges {
+		if change.Type == SESSION_TRACK_SYSTEM_VARIABLES {
+			if variables == nil {
+				variables = make(map[string]string)
+			}
+			variables[change.Name] = change.Value
+		}
+	}
+	return variables
+}
+
+// SessionGTIDs returns the GTIDs of the transactions committed by the statement, reported with
+// session_track_gtids, e.g. for a router to send the next reads to a replica which has applied them.
+func (r *Result) SessionGTIDs() string {
+	for i := len(r.SessionStateChanges) - 1; i >= 0; i-- {
+		if r.SessionStateChanges[i].Type == SESSION_TRACK_GTIDS {
+			return r.SessionStateChanges[i].Value
+		}
+	}
+	return ""
+}
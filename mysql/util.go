@@ -12,6 +12,7 @@ import (
 	"io"
 	mrand "math/rand"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -246,6 +247,12 @@ func PutLengthEncodedString(b []byte) []byte {
 	return data
 }
 
+// AppendLengthEncodedString appends the length-encoded string s to b, without the allocation of PutLengthEncodedString.
+func AppendLengthEncodedString(b []byte, s []byte) []byte {
+	b = AppendLengthEncodedInteger(b, uint64(len(s)))
+	return append(b, s...)
+}
+
 func Uint16ToBytes(n uint16) []byte {
 	return []byte{
 		byte(n),
@@ -322,30 +329,121 @@ func FormatBinaryDateTime(n int, data []byte) ([]byte, error) {
 	}
 }
 
+// ParseDateTime parses a DATE, DATETIME or TIMESTAMP value of the text protocol, with the fractional seconds if
+// any, in loc. The zero date '0000-00-00' is returned as the zero time.Time.
+func ParseDateTime(str string, loc *time.Location) (time.Time, error) {
+	if strings.HasPrefix(str, "0000-00-00") {
+		return time.Time{}, nil
+	}
+
+	layout := "2006-01-02 15:04:05"
+	if len(str) == len("2006-01-02") {
+		layout = "2006-01-02"
+	}
+	t, err := time.ParseInLocation(layout, str, loc)
+	if err != nil {
+		return time.Time{}, errors.Trace(err)
+	}
+	return t, nil
+}
+
+// ParseDuration parses a TIME value, [-]HHH:MM:SS with the fractional seconds if any.
+func ParseDuration(str string) (time.Duration, error) {
+	s := str
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+
+	var frac time.Duration
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		digits := s[i+1:]
+		if len(digits) == 0 || len(digits) > 9 {
+			return 0, errors.Errorf("invalid time %q", str)
+		}
+		n, err := strconv.ParseUint(digits, 10, 64)
+		if err != nil {
+			return 0, errors.Errorf("invalid time %q", str)
+		}
+		frac = time.Duration(n)
+		for j := len(digits); j < 9; j++ {
+			frac *= 10
+		}
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, errors.Errorf("invalid time %q", str)
+	}
+	var hms [3]uint64
+	for i, p := range parts {
+		v, err := strconv.ParseUint(p, 10, 32)
+		if err != nil || i > 0 && (len(p) != 2 || v > 59) {
+			return 0, errors.Errorf("invalid time %q", str)
+		}
+		hms[i] = v
+	}
+
+	d := time.Duration(hms[0])*time.Hour + time.Duration(hms[1])*time.Minute + time.Duration(hms[2])*time.Second + frac
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// AppendBinaryDateTime appends t as a length-prefixed DATETIME value of the binary protocol, with the
+// microseconds if any. The zero time.Time is the zero date '0000-00-00'.
+func AppendBinaryDateTime(data []byte, t time.Time) []byte {
+	if t.IsZero() {
+		return append(data, 0)
+	}
+
+	year, month, day := t.Date()
+	hour, min, sec := t.Clock()
+	usec := t.Nanosecond() / int(time.Microsecond)
+
+	n := byte(7)
+	if usec != 0 {
+		n = 11
+	} else if hour == 0 && min == 0 && sec == 0 {
+		n = 4
+	}
+
+	data = append(data, n, byte(year), byte(year>>8), byte(month), byte(day))
+	if n > 4 {
+		data = append(data, byte(hour), byte(min), byte(sec))
+	}
+	if n > 7 {
+		data = append(data, byte(usec), byte(usec>>8), byte(usec>>16), byte(usec>>24))
+	}
+	return data
+}
+
 func FormatBinaryTime(n int, data []byte) ([]byte, error) {
 	if n == 0 {
-		return []byte("0000-00-00"), nil
+		return []byte("00:00:00"), nil
 	}
 
-	var sign byte
+	sign := ""
 	if data[0] == 1 {
-		sign = byte('-')
+		sign = "-"
 	}
 
 	switch n {
 	case 8:
 		return []byte(fmt.Sprintf(
-			"%c%02d:%02d:%02d",
+			"%s%02d:%02d:%02d",
 			sign,
-			uint16(data[1])*24+uint16(data[5]),
+			binary.LittleEndian.Uint32(data[1:5])*24+uint32(data[5]),
 			data[6],
 			data[7],
 		)), nil
 	case 12:
 		return []byte(fmt.Sprintf(
-			"%c%02d:%02d:%02d.%06d",
+			"%s%02d:%02d:%02d.%06d",
 			sign,
-			uint16(data[1])*24+uint16(data[5]),
+			binary.LittleEndian.Uint32(data[1:5])*24+uint32(data[5]),
 			data[6],
 			data[7],
 			binary.LittleEndian.Uint32(data[8:12]),
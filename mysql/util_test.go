@@ -2,6 +2,7 @@ package mysql
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -23,3 +24,69 @@ func TestCompareServerVersions(t *testing.T) {
 		require.Equal(t, test.Expect, got)
 	}
 }
+
+func TestAppendLengthEncodedString(t *testing.T) {
+	for _, s := range [][]byte{nil, []byte("abc"), make([]byte, 300), make([]byte, 70000)} {
+		require.Equal(t, PutLengthEncodedString(s), AppendLengthEncodedString(nil, s))
+	}
+	require.Equal(t, []byte{'x', 3, 'a', 'b', 'c'}, AppendLengthEncodedString([]byte{'x'}, []byte("abc")))
+
+	f := &Field{Schema: []byte("db"), Table: []byte("t"), Name: []byte("c"), Charset: 33, ColumnLength: 0x01020304,
+		Type: MYSQL_TYPE_VAR_STRING, Flag: NOT_NULL_FLAG, DefaultValue: []byte("x"), DefaultValueLength: 1}
+	require.Equal(t, append([]byte{'x'}, f.Dump()...), f.AppendDump([]byte{'x'}))
+}
+
+func TestDateTime(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	for str, expect := range map[string]time.Time{
+		"2023-04-05":                 time.Date(2023, 4, 5, 0, 0, 0, 0, loc),
+		"2023-04-05 06:07:08":        time.Date(2023, 4, 5, 6, 7, 8, 0, loc),
+		"2023-04-05 06:07:08.000123": time.Date(2023, 4, 5, 6, 7, 8, 123000, loc),
+		"0000-00-00 00:00:00":        {},
+	} {
+		got, err := ParseDateTime(str, loc)
+		require.NoError(t, err)
+		require.True(t, expect.Equal(got), str)
+
+		data := AppendBinaryDateTime(nil, got)
+		back, err := ParseBinaryDateTime(int(data[0]), data[1:])
+		require.NoError(t, err)
+		require.Equal(t, expect.IsZero(), back.IsZero())
+		if !expect.IsZero() {
+			require.Equal(t, got.Format("2006-01-02 15:04:05.999999"), back.Format("2006-01-02 15:04:05.999999"))
+		}
+	}
+
+	_, err := ParseDateTime("2023-04-05T06:07:08", time.UTC)
+	require.Error(t, err)
+}
+
+func TestDuration(t *testing.T) {
+	for str, expect := range map[string]time.Duration{
+		"00:00:00":         0,
+		"12:34:56":         12*time.Hour + 34*time.Minute + 56*time.Second,
+		"-838:59:59":       -(838*time.Hour + 59*time.Minute + 59*time.Second),
+		"01:02:03.5":       time.Hour + 2*time.Minute + 3*time.Second + 500*time.Millisecond,
+		"-00:00:01.000001": -(time.Second + time.Microsecond),
+	} {
+		got, err := ParseDuration(str)
+		require.NoError(t, err)
+		require.Equal(t, expect, got, str)
+	}
+
+	for _, str := range []string{"", "12:34", "12:60:00", "1:2:3", "12:34:56.", "aa:00:00"} {
+		_, err := ParseDuration(str)
+		require.Error(t, err, str)
+	}
+
+	// 1 day 02:03:04.000005 and -00:00:01
+	s, err := FormatBinaryTime(12, []byte{0, 1, 0, 0, 0, 2, 3, 4, 5, 0, 0, 0})
+	require.NoError(t, err)
+	require.Equal(t, "26:03:04.000005", string(s))
+	s, err = FormatBinaryTime(8, []byte{1, 0, 0, 0, 0, 0, 0, 1})
+	require.NoError(t, err)
+	require.Equal(t, "-00:00:01", string(s))
+	s, err = FormatBinaryTime(0, nil)
+	require.NoError(t, err)
+	require.Equal(t, "00:00:00", string(s))
+}
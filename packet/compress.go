@@ -0,0 +1,175 @@
+package packet
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+
+	. "github.com/atoonk/go-mysql/mysql"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pingcap/errors"
+)
+
+// payloads smaller than this are not worth compressing, they are sent as is, unless CompressionThreshold is set
+const minCompressLength = 50
+
+// compressedReader reads the packets wrapped in the compressed packets of the connection
+type compressedReader struct {
+	c *Conn
+}
+
+func (r compressedReader) Read(p []byte) (int, error) {
+	c := r.c
+	for len(c.compressedBuf) == 0 {
+		data, err := c.readCompressed()
+		if err != nil {
+			return 0, err
+		}
+		c.compressedBuf = data
+	}
+
+	n := copy(p, c.compressedBuf)
+	c.compressedBuf = c.compressedBuf[n:]
+	return n, nil
+}
+
+// readCompressed reads one compressed packet and returns its uncompressed payload.
+// see: https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_basic_compression_packet.html
+func (c *Conn) readCompressed() ([]byte, error) {
+	if _, err := io.ReadFull(c.reader, c.compressedHeader[:7]); err != nil {
+		return nil, errors.Wrapf(ErrBadConn, "io.ReadFull(compressedHeader) failed. err %v", err)
+	}
+
+	compressedLength := int(uint32(c.compressedHeader[0]) | uint32(c.compressedHeader[1])<<8 | uint32(c.compressedHeader[2])<<16)
+	compressedSequence := c.compressedHeader[3]
+	uncompressedLength := int(uint32(c.compressedHeader[4]) | uint32(c.compressedHeader[5])<<8 | uint32(c.compressedHeader[6])<<16)
+	if compressedSequence != c.CompressedSequence {
+		return nil, errors.Errorf("invalid compressed sequence %d != %d",
+			compressedSequence, c.CompressedSequence)
+	}
+	c.CompressedSequence++
+
+	payload := make([]byte, compressedLength)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return nil, errors.Wrapf(ErrBadConn, "io.ReadFull(compressedPayload) failed. err %v", err)
+	}
+
+	// the payload was too small to be compressed
+	if uncompressedLength == 0 {
+		return payload, nil
+	}
+
+	data := make([]byte, uncompressedLength)
+	switch c.Compression {
+	case MYSQL_COMPRESS_ZLIB:
+		r, err := zlib.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, errors.Trace(err)
+		}
+	case MYSQL_COMPRESS_ZSTD:
+		if c.zstdDecoder == nil {
+			d, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			c.zstdDecoder = d
+		}
+		var err error
+		if data, err = c.zstdDecoder.DecodeAll(payload, data[:0]); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if len(data) != uncompressedLength {
+		return nil, errors.Errorf("invalid uncompressed length %d != %d", len(data), uncompressedLength)
+	}
+	return data, nil
+}
+
+// writeCompressed wraps data in compressed packets of at most MaxPayloadLen uncompressed bytes.
+func (c *Conn) writeCompressed(data []byte) error {
+	for len(data) > 0 {
+		chunk := data
+		if len(chunk) > MaxPayloadLen {
+			chunk = chunk[:MaxPayloadLen]
+		}
+		data = data[len(chunk):]
+
+		threshold := c.CompressionThreshold
+		if threshold == 0 {
+			threshold = minCompressLength
+		}
+
+		payload, uncompressedLength := chunk, 0
+		if len(chunk) > threshold {
+			compressed, err := c.compress(chunk)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			// send the payload as is if compressing it does not make it smaller
+			if len(compressed) < len(chunk) {
+				payload, uncompressedLength = compressed, len(chunk)
+			}
+		}
+
+		packet := make([]byte, 7, 7+len(payload))
+		packet[0] = byte(len(payload))
+		packet[1] = byte(len(payload) >> 8)
+		packet[2] = byte(len(payload) >> 16)
+		packet[3] = c.CompressedSequence
+		packet[4] = byte(uncompressedLength)
+		packet[5] = byte(uncompressedLength >> 8)
+		packet[6] = byte(uncompressedLength >> 16)
+		packet = append(packet, payload...)
+
+		if err := c.write(packet); err != nil {
+			return err
+		}
+		c.CompressedSequence++
+	}
+	return nil
+}
+
+func (c *Conn) compress(data []byte) ([]byte, error) {
+	switch c.Compression {
+	case MYSQL_COMPRESS_ZLIB:
+		var buf bytes.Buffer
+		if c.zlibWriter == nil {
+			level := zlib.DefaultCompression
+			if c.CompressionLevel != 0 {
+				level = c.CompressionLevel
+			}
+			w, err := zlib.NewWriterLevel(&buf, level)
+			if err != nil {
+				return nil, err
+			}
+			c.zlibWriter = w
+		} else {
+			c.zlibWriter.Reset(&buf)
+		}
+		if _, err := c.zlibWriter.Write(data); err != nil {
+			return nil, err
+		}
+		if err := c.zlibWriter.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case MYSQL_COMPRESS_ZSTD:
+		if c.zstdEncoder == nil {
+			opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+			if c.CompressionLevel != 0 {
+				opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(c.CompressionLevel)))
+			}
+			e, err := zstd.NewWriter(nil, opts...)
+			if err != nil {
+				return nil, err
+			}
+			c.zstdEncoder = e
+		}
+		return c.zstdEncoder.EncodeAll(data, nil), nil
+	default:
+		return nil, errors.New("Unsuppored compression algorithm set")
+	}
+}
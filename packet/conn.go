@@ -9,9 +9,12 @@ import (
 	"crypto/sha1"
 	"crypto/x509"
 	"encoding/pem"
+	stderrors "errors"
+	"fmt"
 	"io"
 	"net"
 	"sync"
+	"time"
 
 	. "github.com/atoonk/go-mysql/mysql"
 	"github.com/atoonk/go-mysql/utils"
@@ -60,14 +63,32 @@ type Conn struct {
 	Sequence uint8
 
 	Compression uint8
+	// CompressionLevel is the level of the compression, the default of the algorithm if zero
+	CompressionLevel int
+	// CompressionThreshold is the length under which the payloads are not compressed, 50 bytes if zero
+	CompressionThreshold int
+
+	// ReadTimeout and WriteTimeout, if not zero, bound the time spent reading or writing every packet
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// MaxAllowedPacket, if not zero, is the maximum length of the packets written, WritePacket fails with
+	// ER_NET_PACKET_TOO_LARGE without writing anything for the longer ones
+	MaxAllowedPacket int
 
 	CompressedSequence uint8
 
 	compressedHeader [7]byte
 
-	compressedReaderActive bool
+	// uncompressed data of the last compressed packet read, not consumed yet
+	compressedBuf []byte
 
-	compressedReader io.Reader
+	// packets written while batching, sent at once by Flush, see StartBatch
+	batching bool
+	wbuf     []byte
+
+	zlibWriter  *zlib.Writer
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
 }
 
 func NewConn(conn net.Conn) *Conn {
@@ -106,43 +127,19 @@ func (c *Conn) ReadPacketReuseMem(dst []byte) ([]byte, error) {
 		utils.BytesBufferPut(buf)
 	}()
 
-	if c.Compression != MYSQL_COMPRESS_NONE {
-		if !c.compressedReaderActive {
-			if _, err := io.ReadFull(c.reader, c.compressedHeader[:7]); err != nil {
-				return nil, errors.Wrapf(ErrBadConn, "io.ReadFull(compressedHeader) failed. err %v", err)
-			}
-
-			compressedSequence := c.compressedHeader[3]
-			uncompressedLength := int(uint32(c.compressedHeader[4]) | uint32(c.compressedHeader[5])<<8 | uint32(c.compressedHeader[6])<<16)
-			if compressedSequence != c.CompressedSequence {
-				return nil, errors.Errorf("invalid compressed sequence %d != %d",
-					compressedSequence, c.CompressedSequence)
-			}
-
-			if uncompressedLength > 0 {
-				var err error
-				switch c.Compression {
-				case MYSQL_COMPRESS_ZLIB:
-					c.compressedReader, err = zlib.NewReader(c.reader)
-				case MYSQL_COMPRESS_ZSTD:
-					c.compressedReader, err = zstd.NewReader(c.reader)
-				}
-				if err != nil {
-					return nil, err
-				}
-			}
-			c.compressedReaderActive = true
+	if c.ReadTimeout != 0 {
+		if err := c.SetReadDeadline(time.Now().Add(c.ReadTimeout)); err != nil {
+			return nil, errors.Trace(err)
 		}
 	}
 
-	if c.compressedReader != nil {
-		if err := c.ReadPacketTo(buf, c.compressedReader); err != nil {
-			return nil, errors.Trace(err)
-		}
-	} else {
-		if err := c.ReadPacketTo(buf, c.reader); err != nil {
-			return nil, errors.Trace(err)
-		}
+	r := c.reader
+	if c.Compression != MYSQL_COMPRESS_NONE {
+		r = compressedReader{c}
+	}
+
+	if err := c.ReadPacketTo(buf, r); err != nil {
+		return nil, errors.Trace(err)
 	}
 
 	readBytes := buf.Bytes()
@@ -167,6 +164,24 @@ func (c *Conn) ReadPacketReuseMem(dst []byte) ([]byte, error) {
 	return result, nil
 }
 
+// ReadUnsequencedPacket reads a packet like ReadPacket but without checking nor updating Sequence, so that
+// packets can be read by another goroutine than the one writing. It returns the sequence of the packet and
+// the one expected for the next packet. It does not set a read deadline and does not support compression.
+func (c *Conn) ReadUnsequencedPacket() (data []byte, sequence uint8, next uint8, err error) {
+	if c.Compression != MYSQL_COMPRESS_NONE {
+		return nil, 0, 0, errors.New("ReadUnsequencedPacket does not support compression")
+	}
+
+	var buf bytes.Buffer
+	if err := c.readPacketTo(&buf, c.reader, &next, false); err != nil {
+		return nil, 0, 0, errors.Trace(err)
+	}
+	data = buf.Bytes()
+	// every chunk but the last one has the max length
+	sequence = next - uint8(len(data)/MaxPayloadLen+1)
+	return data, sequence, next, nil
+}
+
 func (c *Conn) copyN(dst io.Writer, src io.Reader, n int64) (written int64, err error) {
 	for n > 0 {
 		bcap := cap(c.copyNBuf)
@@ -193,18 +208,26 @@ func (c *Conn) copyN(dst io.Writer, src io.Reader, n int64) (written int64, err
 }
 
 func (c *Conn) ReadPacketTo(w io.Writer, r io.Reader) error {
+	return c.readPacketTo(w, r, &c.Sequence, true)
+}
+
+// readPacketTo reads a packet checking its sequence against *sequence if check is set, *sequence is
+// then the sequence expected for the next packet.
+func (c *Conn) readPacketTo(w io.Writer, r io.Reader, sequence *uint8, check bool) error {
 	if _, err := io.ReadFull(r, c.header[:4]); err != nil {
+		if isTimeout(err) {
+			return errors.Wrapf(ErrBadConn, "read timed out (read timeout %v): %v", c.ReadTimeout, err)
+		}
 		return errors.Wrapf(ErrBadConn, "io.ReadFull(header) failed. err %v", err)
 	}
 
 	length := int(uint32(c.header[0]) | uint32(c.header[1])<<8 | uint32(c.header[2])<<16)
-	sequence := c.header[3]
 
-	if sequence != c.Sequence {
-		return errors.Errorf("invalid sequence %d != %d", sequence, c.Sequence)
+	if check && c.header[3] != *sequence {
+		return errors.Errorf("invalid sequence %d != %d", c.header[3], *sequence)
 	}
 
-	c.Sequence++
+	*sequence = c.header[3] + 1
 
 	if buf, ok := w.(*bytes.Buffer); ok {
 		// Allocate the buffer with expected length directly instead of call `grow` and migrate data many times.
@@ -212,6 +235,9 @@ func (c *Conn) ReadPacketTo(w io.Writer, r io.Reader) error {
 	}
 
 	if n, err := c.copyN(w, r, int64(length)); err != nil {
+		if isTimeout(err) {
+			return errors.Wrapf(ErrBadConn, "read timed out after %v of %v bytes (read timeout %v): %v", n, length, c.ReadTimeout, err)
+		}
 		return errors.Wrapf(ErrBadConn, "io.CopyN failed. err %v, copied %v, expected %v", err, n, length)
 	} else if n != int64(length) {
 		return errors.Wrapf(ErrBadConn, "io.CopyN failed(n != int64(length)). %v bytes copied, while %v expected", n, length)
@@ -220,7 +246,7 @@ func (c *Conn) ReadPacketTo(w io.Writer, r io.Reader) error {
 			return nil
 		}
 
-		if err = c.ReadPacketTo(w, r); err != nil {
+		if err = c.readPacketTo(w, r, sequence, true); err != nil {
 			return errors.Wrap(err, "ReadPacketTo failed")
 		}
 	}
@@ -231,6 +257,16 @@ func (c *Conn) ReadPacketTo(w io.Writer, r io.Reader) error {
 // WritePacket: data already has 4 bytes header
 // will modify data inplace
 func (c *Conn) WritePacket(data []byte) error {
+	if c.MaxAllowedPacket > 0 && len(data)-4 > c.MaxAllowedPacket {
+		return NewError(ER_NET_PACKET_TOO_LARGE, fmt.Sprintf("packet of %d bytes is bigger than max_allowed_packet (%d bytes)", len(data)-4, c.MaxAllowedPacket))
+	}
+
+	if c.WriteTimeout != 0 {
+		if err := c.SetWriteDeadline(time.Now().Add(c.WriteTimeout)); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
 	length := len(data) - 4
 
 	for length >= MaxPayloadLen {
@@ -240,10 +276,8 @@ func (c *Conn) WritePacket(data []byte) error {
 
 		data[3] = c.Sequence
 
-		if n, err := c.Write(data[:4+MaxPayloadLen]); err != nil {
-			return errors.Wrapf(ErrBadConn, "Write(payload portion) failed. err %v", err)
-		} else if n != (4 + MaxPayloadLen) {
-			return errors.Wrapf(ErrBadConn, "Write(payload portion) failed. only %v bytes written, while %v expected", n, 4+MaxPayloadLen)
+		if err := c.writeRaw(data[:4+MaxPayloadLen]); err != nil {
+			return c.writeError("Write(payload portion) failed", err)
 		} else {
 			c.Sequence++
 			length -= MaxPayloadLen
@@ -256,93 +290,83 @@ func (c *Conn) WritePacket(data []byte) error {
 	data[2] = byte(length >> 16)
 	data[3] = c.Sequence
 
-	switch c.Compression {
-	case MYSQL_COMPRESS_NONE:
-		if n, err := c.Write(data); err != nil {
-			return errors.Wrapf(ErrBadConn, "Write failed. err %v", err)
-		} else if n != len(data) {
-			return errors.Wrapf(ErrBadConn, "Write failed. only %v bytes written, while %v expected", n, len(data))
-		}
-	case MYSQL_COMPRESS_ZLIB, MYSQL_COMPRESS_ZSTD:
-		if n, err := c.writeCompressed(data); err != nil {
-			return errors.Wrapf(ErrBadConn, "Write failed. err %v", err)
-		} else if n != len(data) {
-			return errors.Wrapf(ErrBadConn, "Write failed. only %v bytes written, while %v expected", n, len(data))
-		}
-		c.compressedReader = nil
-		c.compressedReaderActive = false
-	default:
-		return errors.Wrapf(ErrBadConn, "Write failed. Unsuppored compression algorithm set")
+	if err := c.writeRaw(data); err != nil {
+		return c.writeError("Write failed", err)
 	}
 
 	c.Sequence++
 	return nil
 }
 
-func (c *Conn) writeCompressed(data []byte) (n int, err error) {
-	var compressedLength, uncompressedLength int
-	var payload, compressedPacket bytes.Buffer
-	var w io.WriteCloser
-	minCompressLength := 50
-	compressedHeader := make([]byte, 7)
+// writeError wraps an error writing to the connection in ErrBadConn.
+func (c *Conn) writeError(msg string, err error) error {
+	if isTimeout(err) {
+		return errors.Wrapf(ErrBadConn, "write timed out (write timeout %v): %v", c.WriteTimeout, err)
+	}
+	return errors.Wrapf(ErrBadConn, "%s. err %v", msg, err)
+}
 
+// isTimeout tells whether err is caused by the deadline of the connection.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return stderrors.As(err, &netErr) && netErr.Timeout()
+}
+
+// writeRaw writes the packets in data to the connection, wrapping them in compressed packets if
+// the compression is enabled
+func (c *Conn) writeRaw(data []byte) error {
 	switch c.Compression {
-	case MYSQL_COMPRESS_ZLIB:
-		w, err = zlib.NewWriterLevel(&payload, zlib.HuffmanOnly)
-	case MYSQL_COMPRESS_ZSTD:
-		w, err = zstd.NewWriter(&payload)
-	}
-	if err != nil {
-		return 0, err
+	case MYSQL_COMPRESS_NONE:
+		return c.write(data)
+	case MYSQL_COMPRESS_ZLIB, MYSQL_COMPRESS_ZSTD:
+		return c.writeCompressed(data)
+	default:
+		return errors.New("Unsuppored compression algorithm set")
 	}
+}
 
-	if len(data) > minCompressLength {
-		uncompressedLength = len(data)
-		n, err = w.Write(data)
-		if err != nil {
-			return 0, err
-		}
-		err = w.Close()
-		if err != nil {
-			return 0, err
-		}
+// write writes data to the connection, or buffers it while batching.
+func (c *Conn) write(data []byte) error {
+	if c.batching {
+		c.wbuf = append(c.wbuf, data...)
+		return nil
 	}
-
-	if len(data) > minCompressLength {
-		compressedLength = len(payload.Bytes())
-	} else {
-		compressedLength = len(data)
+	if n, err := c.Write(data); err != nil {
+		return err
+	} else if n != len(data) {
+		return errors.Errorf("only %v bytes written, while %v expected", n, len(data))
 	}
+	return nil
+}
 
-	c.CompressedSequence = 0
-	compressedHeader[0] = byte(compressedLength)
-	compressedHeader[1] = byte(compressedLength >> 8)
-	compressedHeader[2] = byte(compressedLength >> 16)
-	compressedHeader[3] = c.CompressedSequence
-	compressedHeader[4] = byte(uncompressedLength)
-	compressedHeader[5] = byte(uncompressedLength >> 8)
-	compressedHeader[6] = byte(uncompressedLength >> 16)
-	_, err = compressedPacket.Write(compressedHeader)
-	if err != nil {
-		return 0, err
-	}
-	c.CompressedSequence++
+// StartBatch makes the following packets buffered instead of written, until Flush writes them to the connection
+// at once. It saves a system call per packet when many small packets are written in a row, e.g. a binlog stream.
+func (c *Conn) StartBatch() {
+	c.batching = true
+}
 
-	if len(data) > minCompressLength {
-		_, err = compressedPacket.Write(payload.Bytes())
-	} else {
-		n, err = compressedPacket.Write(data)
+// Buffered returns the number of bytes of the packets buffered since StartBatch.
+func (c *Conn) Buffered() int {
+	return len(c.wbuf)
+}
+
+// Flush writes the packets buffered since StartBatch and ends the batch.
+func (c *Conn) Flush() error {
+	c.batching = false
+	if len(c.wbuf) == 0 {
+		return nil
 	}
-	if err != nil {
-		return 0, err
+	if c.WriteTimeout != 0 {
+		if err := c.SetWriteDeadline(time.Now().Add(c.WriteTimeout)); err != nil {
+			return errors.Trace(err)
+		}
 	}
-
-	_, err = c.Write(compressedPacket.Bytes())
+	err := c.write(c.wbuf)
+	c.wbuf = c.wbuf[:0]
 	if err != nil {
-		return 0, err
+		return c.writeError("Write failed", err)
 	}
-
-	return n, nil
+	return nil
 }
 
 // WriteClearAuthPacket: Client clear text authentication packet
@@ -420,10 +444,16 @@ func (c *Conn) WriteAuthSwitchPacket(authData []byte, addNUL bool) error {
 
 func (c *Conn) ResetSequence() {
 	c.Sequence = 0
+	c.CompressedSequence = 0
 }
 
 func (c *Conn) Close() error {
 	c.Sequence = 0
+	c.CompressedSequence = 0
+	if c.zstdDecoder != nil {
+		c.zstdDecoder.Close()
+		c.zstdDecoder = nil
+	}
 	if c.Conn != nil {
 		return errors.Wrap(c.Conn.Close(), "Conn.Close failed")
 	}
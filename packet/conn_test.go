@@ -0,0 +1,138 @@
+package packet
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/atoonk/go-mysql/mysql"
+	mockconn "github.com/atoonk/go-mysql/test_util/conn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressedPackets(t *testing.T) {
+	for _, compression := range []uint8{mysql.MYSQL_COMPRESS_ZLIB, mysql.MYSQL_COMPRESS_ZSTD} {
+		client, server := net.Pipe()
+		cc, sc := NewConn(client), NewConn(server)
+		cc.Compression, sc.Compression = compression, compression
+
+		payloads := [][]byte{
+			[]byte("small"),
+			bytes.Repeat([]byte("compressible "), 1000),
+			bytes.Repeat([]byte{0xff}, mysql.MaxPayloadLen+10),
+		}
+
+		errc := make(chan error, 1)
+		go func() {
+			for _, p := range payloads {
+				if err := cc.WritePacket(append(make([]byte, 4), p...)); err != nil {
+					errc <- err
+					return
+				}
+			}
+			errc <- nil
+		}()
+
+		for _, p := range payloads {
+			data, err := sc.ReadPacket()
+			require.NoError(t, err)
+			require.Equal(t, p, data)
+		}
+		require.NoError(t, <-errc)
+
+		// the sequences are shared by both directions
+		require.Equal(t, cc.Sequence, sc.Sequence)
+		require.Equal(t, cc.CompressedSequence, sc.CompressedSequence)
+		cc.ResetSequence()
+		require.Equal(t, uint8(0), cc.CompressedSequence)
+
+		cc.Close()
+		sc.Close()
+	}
+}
+
+func TestCompressionSettings(t *testing.T) {
+	payload := append(make([]byte, 4), bytes.Repeat([]byte("compressible "), 100)...)
+	// uncompressedLength returns the uncompressed length of the last compressed packet written, 0 if not compressed
+	uncompressedLength := func(conn *mockconn.MockConn) int {
+		h := conn.WriteBuffered
+		return int(h[4]) | int(h[5])<<8 | int(h[6])<<16
+	}
+
+	for _, compression := range []uint8{mysql.MYSQL_COMPRESS_ZLIB, mysql.MYSQL_COMPRESS_ZSTD} {
+		var sizes []int
+		for _, level := range []int{1, 9} {
+			conn := &mockconn.MockConn{}
+			c := NewConn(conn)
+			c.Compression, c.CompressionLevel = compression, level
+			require.NoError(t, c.WritePacket(payload))
+			require.Equal(t, len(payload), uncompressedLength(conn))
+			sizes = append(sizes, len(conn.WriteBuffered))
+		}
+		require.LessOrEqual(t, sizes[1], sizes[0])
+
+		conn := &mockconn.MockConn{}
+		c := NewConn(conn)
+		c.Compression, c.CompressionThreshold = compression, 10000
+		require.NoError(t, c.WritePacket(payload))
+		require.Zero(t, uncompressedLength(conn))
+	}
+
+	c := NewConn(&mockconn.MockConn{})
+	c.Compression, c.CompressionLevel = mysql.MYSQL_COMPRESS_ZLIB, 42
+	require.Error(t, c.WritePacket(payload))
+}
+
+func TestBatchedPackets(t *testing.T) {
+	client, server := net.Pipe()
+	cc, sc := NewConn(client), NewConn(server)
+	defer cc.Close()
+	defer sc.Close()
+
+	// net.Pipe is synchronous, a write would block until it is read
+	cc.StartBatch()
+	require.NoError(t, cc.WritePacket(append(make([]byte, 4), "first"...)))
+	require.NoError(t, cc.WritePacket(append(make([]byte, 4), "second"...)))
+	require.Equal(t, 2*4+len("first")+len("second"), cc.Buffered())
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- cc.Flush()
+	}()
+
+	for _, p := range []string{"first", "second"} {
+		data, err := sc.ReadPacket()
+		require.NoError(t, err)
+		require.Equal(t, []byte(p), data)
+	}
+	require.NoError(t, <-errc)
+	require.Equal(t, 0, cc.Buffered())
+	require.Equal(t, cc.Sequence, sc.Sequence)
+}
+
+func TestPacketLimits(t *testing.T) {
+	client, server := net.Pipe()
+	cc, sc := NewConn(client), NewConn(server)
+	defer cc.Close()
+	defer sc.Close()
+
+	// a packet bigger than MaxAllowedPacket is not written
+	cc.MaxAllowedPacket = 10
+	err := cc.WritePacket(append(make([]byte, 4), "0123456789A"...))
+	var myErr *mysql.MyError
+	require.ErrorAs(t, err, &myErr)
+	require.EqualValues(t, mysql.ER_NET_PACKET_TOO_LARGE, myErr.Code)
+	require.Zero(t, cc.Sequence)
+
+	// a read timeout is reported as such
+	sc.ReadTimeout = 10 * time.Millisecond
+	_, err = sc.ReadPacket()
+	require.ErrorIs(t, err, mysql.ErrBadConn)
+	require.ErrorContains(t, err, "read timed out")
+
+	cc.WriteTimeout = 10 * time.Millisecond
+	err = cc.WritePacket(append(make([]byte, 4), "0123456789"...))
+	require.ErrorIs(t, err, mysql.ErrBadConn)
+	require.ErrorContains(t, err, "write timed out")
+}
@@ -22,25 +22,17 @@ func (b *BinlogSyncer) StartBackup(backupDir string, p Position, timeout time.Du
 	// Force use raw mode
 	b.parser.SetRawMode(true)
 
-	if err := os.MkdirAll(backupDir, 0755); err != nil {
+	w, err := NewBinlogWriter(backupDir)
+	if err != nil {
 		return errors.Trace(err)
 	}
+	defer w.Close()
 
 	s, err := b.StartSync(p)
 	if err != nil {
 		return errors.Trace(err)
 	}
 
-	var filename string
-	var offset uint32
-
-	var f *os.File
-	defer func() {
-		if f != nil {
-			f.Close()
-		}
-	}()
-
 	for {
 		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		e, err := s.GetEvent(ctx)
@@ -54,42 +46,105 @@ func (b *BinlogSyncer) StartBackup(backupDir string, p Position, timeout time.Du
 			return errors.Trace(err)
 		}
 
-		offset = e.Header.LogPos
+		if err = w.WriteEvent(e); err != nil {
+			return errors.Trace(err)
+		}
+	}
+}
+
+// BinlogWriter writes the events of a binlog stream, as received with RawModeEnabled, to binlog files named after
+// the binlog files of the master, like mysqlbinlog --read-from-remote-server --raw. A file is created on each format
+// description event, with the binlog magic header, and the fake rotate and heartbeat events are not written.
+//
+// When the stream starts in the middle of a binlog file, the file begins with the format description event followed
+// by the events from the start position, as with mysqlbinlog.
+type BinlogWriter struct {
+	dir string
+
+	// the name of the binlog file of the stream, from the last rotate event
+	filename string
+	f        *os.File
+	// the name of the open file and its size
+	name string
+	pos  uint32
+}
+
+// NewBinlogWriter returns a BinlogWriter writing the binlog files in dir, created if it does not exist.
+func NewBinlogWriter(dir string) (*BinlogWriter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &BinlogWriter{dir: dir}, nil
+}
+
+// WriteEvent writes an event to the current binlog file.
+func (w *BinlogWriter) WriteEvent(e *BinlogEvent) error {
+	switch e.Header.EventType {
+	case ROTATE_EVENT:
+		w.filename = string(e.Event.(*RotateEvent).NextLogName)
+		if e.Header.Timestamp == 0 || e.Header.LogPos == 0 {
+			// fake rotate event
+			return nil
+		}
+	case FORMAT_DESCRIPTION_EVENT:
+		// the format description event sent again when the sync is resumed, with no position
+		if e.Header.LogPos == 0 && w.f != nil && w.name == w.filename {
+			return nil
+		}
+		if err := w.create(); err != nil {
+			return errors.Trace(err)
+		}
+	case HEARTBEAT_EVENT, HEARTBEAT_LOG_EVENT_V2:
+		return nil
+	}
 
-		if e.Header.EventType == ROTATE_EVENT {
-			rotateEvent := e.Event.(*RotateEvent)
-			filename = string(rotateEvent.NextLogName)
+	if w.f == nil {
+		return errors.Errorf("no binlog file for %s event, the format description event is missing", e.Header.EventType)
+	}
+	if n, err := w.f.Write(e.RawData); err != nil {
+		return errors.Trace(err)
+	} else if n != len(e.RawData) {
+		return errors.Trace(io.ErrShortWrite)
+	}
+	w.pos += uint32(len(e.RawData))
+	return nil
+}
 
-			if e.Header.Timestamp == 0 || offset == 0 {
-				// fake rotate event
-				continue
-			}
-		} else if e.Header.EventType == FORMAT_DESCRIPTION_EVENT {
-			// FormateDescriptionEvent is the first event in binlog, we will close old one and create a new
+// create closes the current binlog file and creates the next one.
+func (w *BinlogWriter) create() error {
+	if err := w.Close(); err != nil {
+		return errors.Trace(err)
+	}
 
-			if f != nil {
-				f.Close()
-			}
+	if len(w.filename) == 0 {
+		return errors.Errorf("empty binlog filename for FormateDescriptionEvent")
+	}
 
-			if len(filename) == 0 {
-				return errors.Errorf("empty binlog filename for FormateDescriptionEvent")
-			}
+	f, err := os.OpenFile(path.Join(w.dir, w.filename), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	w.f, w.name, w.pos = f, w.filename, 0
 
-			f, err = os.OpenFile(path.Join(backupDir, filename), os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				return errors.Trace(err)
-			}
+	// write binlog header fe'bin'
+	if _, err = f.Write(BinLogFileHeader); err != nil {
+		return errors.Trace(err)
+	}
+	w.pos = uint32(len(BinLogFileHeader))
+	return nil
+}
 
-			// write binlog header fe'bin'
-			if _, err = f.Write(BinLogFileHeader); err != nil {
-				return errors.Trace(err)
-			}
-		}
+// Position returns the name and the size of the binlog file written.
+func (w *BinlogWriter) Position() Position {
+	return Position{Name: w.name, Pos: w.pos}
+}
 
-		if n, err := f.Write(e.RawData); err != nil {
-			return errors.Trace(err)
-		} else if n != len(e.RawData) {
-			return errors.Trace(io.ErrShortWrite)
-		}
+// Close closes the current binlog file.
+func (w *BinlogWriter) Close() error {
+	if w.f == nil {
+		return nil
 	}
+	err := w.f.Close()
+	w.f = nil
+	return errors.Trace(err)
 }
@@ -3,6 +3,8 @@ package replication
 import (
 	"context"
 	"os"
+	"path"
+	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
@@ -41,3 +43,62 @@ func (t *testSyncerSuite) TestStartBackupEndInGivenTime() {
 		t.T().Fatal("time out error")
 	}
 }
+
+func TestBinlogWriter(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewBinlogWriter(dir)
+	require.NoError(t, err)
+
+	p := NewBinlogParser()
+	p.SetRawMode(true)
+	var events []*BinlogEvent
+	for _, data := range mysql57Events {
+		e, err := p.Parse(data)
+		require.NoError(t, err)
+		events = append(events, e)
+	}
+	rotate := func(name string, timestamp uint32, logPos uint32) *BinlogEvent {
+		return &BinlogEvent{
+			RawData: []byte(name),
+			Header:  &EventHeader{EventType: ROTATE_EVENT, Timestamp: timestamp, LogPos: logPos},
+			Event:   &RotateEvent{Position: 4, NextLogName: []byte(name)},
+		}
+	}
+	heartbeat := &BinlogEvent{RawData: []byte{1, 2, 3}, Header: &EventHeader{EventType: HEARTBEAT_EVENT}}
+	resentFDE := &BinlogEvent{RawData: events[0].RawData, Header: &EventHeader{EventType: FORMAT_DESCRIPTION_EVENT}}
+
+	stream := []*BinlogEvent{
+		rotate("mysql-bin.000001", 0, 0), events[0], events[1], heartbeat,
+		// the sync is resumed
+		rotate("mysql-bin.000001", 0, 0), resentFDE, events[2],
+		rotate("mysql-bin.000002", 1, 0x121), rotate("mysql-bin.000002", 0, 0), events[0], events[3],
+	}
+	for _, e := range stream {
+		require.NoError(t, w.WriteEvent(e))
+	}
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000002", Pos: 4 + uint32(len(events[0].RawData)+len(events[3].RawData))}, w.Position())
+	require.NoError(t, w.Close())
+
+	file1 := append(append([]byte{}, BinLogFileHeader...), mysql57Events[0]...)
+	file1 = append(append(append(file1, mysql57Events[1]...), mysql57Events[2]...), "mysql-bin.000002"...)
+	data, err := os.ReadFile(path.Join(dir, "mysql-bin.000001"))
+	require.NoError(t, err)
+	require.Equal(t, file1, data)
+
+	data, err = os.ReadFile(path.Join(dir, "mysql-bin.000002"))
+	require.NoError(t, err)
+	require.Equal(t, append(append(append([]byte{}, BinLogFileHeader...), mysql57Events[0]...), mysql57Events[3]...), data)
+
+	// the binlog files can be parsed
+	n := 0
+	err = NewBinlogParser().ParseFile(path.Join(dir, "mysql-bin.000002"), 0, func(e *BinlogEvent) error {
+		n++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	w, err = NewBinlogWriter(dir)
+	require.NoError(t, err)
+	require.Error(t, w.WriteEvent(events[1]))
+}
@@ -67,6 +67,16 @@ func (s *BinlogStreamer) DumpEvents() []*BinlogEvent {
 	return events
 }
 
+// DumpEvent returns the next queued event without waiting, nil if there is none.
+func (s *BinlogStreamer) DumpEvent() *BinlogEvent {
+	select {
+	case ev := <-s.ch:
+		return ev
+	default:
+		return nil
+	}
+}
+
 func (s *BinlogStreamer) close() {
 	s.closeWithError(nil)
 }
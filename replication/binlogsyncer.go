@@ -8,6 +8,7 @@ import (
 	"net"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,7 +16,6 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/pingcap/errors"
-	"github.com/siddontang/go-log/log"
 
 	"github.com/atoonk/go-mysql/client"
 	. "github.com/atoonk/go-mysql/mysql"
@@ -51,7 +51,12 @@ type BinlogSyncerConfig struct {
 	// SemiSyncEnabled enables semi-sync or not.
 	SemiSyncEnabled bool
 
-	// RawModeEnabled is for not parsing binlog event.
+	// SemiSyncManualAck acknowledges the events to the semi-sync master only when the caller confirms they are
+	// durable with BinlogSyncer.AckSemiSync, instead of once they are sent to the streamer.
+	SemiSyncManualAck bool
+
+	// RawModeEnabled is for not parsing binlog event. The events but the format description and rotate events are
+	// received as GenericEvent, with their RawData, e.g. to be written to binlog files by a BinlogWriter.
 	RawModeEnabled bool
 
 	// If not nil, use the provided tls.Config to connect to the database using TLS/SSL.
@@ -75,16 +80,25 @@ type BinlogSyncerConfig struct {
 	// strings obtained from MySQL.
 	TimestampStringLocation *time.Location
 
-	// Use decimal.Decimal structure for decimals.
+	// Use decimal.Decimal structure for decimals, RowsDecodeOptions.MysqlDecimal decodes them as mysql.Decimal
+	// instead.
 	UseDecimal bool
 
+	// RowsDecodeOptions choose the Go types of the unsigned integer, DECIMAL, BIT, ENUM and SET values of the rows events.
+	RowsDecodeOptions RowsDecodeOptions
+
+	// ApplyPartialJSON decodes the partial updates of the JSON columns as the updated documents instead of
+	// *JsonDiff, see BinlogParser.SetApplyPartialJSON.
+	ApplyPartialJSON bool
+
 	// RecvBufferSize sets the size in bytes of the operating system's receive buffer associated with the connection.
 	RecvBufferSize int
 
-	// master heartbeat period
+	// master heartbeat period, the master sends a heartbeat event when no event was sent for this period
 	HeartbeatPeriod time.Duration
 
-	// read timeout
+	// read timeout, the connection is considered broken and re-established when no packet is read for this
+	// duration. Twice HeartbeatPeriod by default, so that the missing heartbeats of a stalled master are detected.
 	ReadTimeout time.Duration
 
 	// maximum number of attempts to re-establish a broken connection, zero or negative number means infinite retry.
@@ -94,6 +108,11 @@ type BinlogSyncerConfig struct {
 	// whether disable re-sync for broken connection
 	DisableRetrySync bool
 
+	// OnReconnect is called once the sync is resumed after a broken connection, with the position it is resumed
+	// from and the GTID set if it is resumed by GTID, nil otherwise. The events read before may still be queued in
+	// the streamer. When resumed by GTID, the events of the interrupted transaction already sent are skipped.
+	OnReconnect func(pos Position, gset GTIDSet)
+
 	// Only works when MySQL/MariaDB variable binlog_checksum=CRC32.
 	// For MySQL, binlog_checksum was introduced since 5.6.2, but CRC32 was set as default value since 5.6.6 .
 	// https://dev.mysql.com/doc/refman/5.6/en/replication-options-binary-log.html#option_mysqld_binlog-checksum
@@ -101,6 +120,10 @@ type BinlogSyncerConfig struct {
 	// https://mariadb.com/kb/en/library/replication-and-binary-log-server-system-variables/#binlog_checksum
 	VerifyChecksum bool
 
+	// WarnOnChecksumMismatch logs the events whose checksum does not match with VerifyChecksum, instead of failing
+	// the sync with ErrChecksumMismatch.
+	WarnOnChecksumMismatch bool
+
 	// DumpCommandFlag is used to send binglog dump command. Default 0, aka BINLOG_DUMP_NEVER_STOP.
 	// For MySQL, BINLOG_DUMP_NEVER_STOP and BINLOG_DUMP_NON_BLOCK are available.
 	// https://dev.mysql.com/doc/internals/en/com-binlog-dump.html#binlog-dump-non-block
@@ -113,7 +136,7 @@ type BinlogSyncerConfig struct {
 	//For MariaDB: slave_gtid_ignore_duplicates、skip_replication、slave_until_gtid
 	Option func(*client.Conn) error
 
-	// Set Logger
+	// Set Logger, it is also used by the client connections of the syncer
 	Logger loggers.Advanced
 
 	// Set Dialer
@@ -126,6 +149,24 @@ type BinlogSyncerConfig struct {
 	DiscardGTIDSet bool
 
 	EventCacheCount int
+
+	// ParseWorkers enables the parsing pipeline if greater than 0: the packets are read from the network, parsed and
+	// sent to the streamer by separate goroutines, and the rows of the row events are decoded by ParseWorkers
+	// workers, the events of a table always by the same one. The events are sent in the order they were read. With
+	// RowsEventDecodeFunc, the rows are decoded by the parsing goroutine.
+	ParseWorkers int
+
+	// KeepTransactionPayloadEvent sends the TransactionPayloadEvents of the compressed transactions to the streamer
+	// as they are. By default, the events of their payload are sent in their place, with their log position.
+	KeepTransactionPayloadEvent bool
+
+	// EventFilter filters the events sent to the streamer, the events filtered out are not decoded, see
+	// EventFilter. The position and the GTID sets of the syncer still follow them.
+	EventFilter *EventFilter
+
+	// ParseQueueSize bounds the number of packets and events queued by each stage of the parsing pipeline, 1024 by
+	// default.
+	ParseQueueSize int
 }
 
 // BinlogSyncer syncs binlog event from server.
@@ -144,6 +185,10 @@ type BinlogSyncer struct {
 
 	prevGset, currGset GTIDSet
 
+	// the position of the last event read before the sync was resumed by GTID, the events up to it are sent again
+	// by the master and skipped, see isResent
+	resumePos Position
+
 	// instead of GTIDSet.Clone, use this to speed up calculate prevGset
 	prevMySQLGTIDEvent *GTIDEvent
 
@@ -155,13 +200,16 @@ type BinlogSyncer struct {
 	lastConnectionID uint32
 
 	retryCount int
+
+	// the last position acknowledged with AckSemiSync
+	ackMu  sync.Mutex
+	ackPos Position
 }
 
 // NewBinlogSyncer creates the BinlogSyncer with cfg.
 func NewBinlogSyncer(cfg BinlogSyncerConfig) *BinlogSyncer {
 	if cfg.Logger == nil {
-		streamHandler, _ := log.NewStreamHandler(os.Stdout)
-		cfg.Logger = log.NewDefault(streamHandler)
+		cfg.Logger = NewDefaultLogger()
 	}
 	if cfg.ServerID == 0 {
 		cfg.Logger.Fatal("can't use 0 as the server ID")
@@ -173,6 +221,12 @@ func NewBinlogSyncer(cfg BinlogSyncerConfig) *BinlogSyncer {
 	if cfg.EventCacheCount == 0 {
 		cfg.EventCacheCount = 10240
 	}
+	if cfg.ParseWorkers > 0 && cfg.ParseQueueSize == 0 {
+		cfg.ParseQueueSize = 1024
+	}
+	if cfg.HeartbeatPeriod > 0 && cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = 2 * cfg.HeartbeatPeriod
+	}
 
 	// Clear the Password to avoid outputing it in log.
 	pass := cfg.Password
@@ -189,9 +243,18 @@ func NewBinlogSyncer(cfg BinlogSyncerConfig) *BinlogSyncer {
 	b.parser.SetParseTime(b.cfg.ParseTime)
 	b.parser.SetTimestampStringLocation(b.cfg.TimestampStringLocation)
 	b.parser.SetUseDecimal(b.cfg.UseDecimal)
+	b.parser.SetRowsDecodeOptions(b.cfg.RowsDecodeOptions)
+	b.parser.SetApplyPartialJSON(b.cfg.ApplyPartialJSON)
 	b.parser.SetVerifyChecksum(b.cfg.VerifyChecksum)
+	if b.cfg.WarnOnChecksumMismatch {
+		b.parser.SetChecksumMismatchFunc(func(h *EventHeader, err error) error {
+			b.cfg.Logger.Warnf("%v: %s with log position %d", err, h.EventType, h.LogPos)
+			return nil
+		})
+	}
 	b.parser.SetRowsEventDecodeFunc(b.cfg.RowsEventDecodeFunc)
 	b.parser.SetTableMapOptionalMetaDecodeFunc(b.cfg.TableMapOptionalMetaDecodeFunc)
+	b.parser.SetEventFilter(b.cfg.EventFilter)
 	b.running = false
 	b.ctx, b.cancel = context.WithCancel(context.Background())
 
@@ -363,19 +426,33 @@ func (b *BinlogSyncer) enableSemiSync() error {
 		return nil
 	}
 
-	if r, err := b.c.Execute("SHOW VARIABLES LIKE 'rpl_semi_sync_master_enabled';"); err != nil {
+	// rpl_semi_sync_source_enabled since the semisync_source plugin of MySQL 8.0.26, which expects
+	// @rpl_semi_sync_replica instead of @rpl_semi_sync_slave
+	r, err := b.c.Execute("SHOW VARIABLES LIKE 'rpl_semi_sync_%_enabled';")
+	if err != nil {
 		return errors.Trace(err)
-	} else {
-		s, _ := r.GetString(0, 1)
-		if s != "ON" {
-			b.cfg.Logger.Errorf("master does not support semi synchronous replication, use no semi-sync")
-			b.cfg.SemiSyncEnabled = false
-			return nil
+	}
+	variable := ""
+	for i := 0; i < r.RowNumber(); i++ {
+		name, _ := r.GetString(i, 0)
+		value, _ := r.GetString(i, 1)
+		if value != "ON" {
+			continue
+		}
+		switch strings.ToLower(name) {
+		case "rpl_semi_sync_master_enabled":
+			variable = "rpl_semi_sync_slave"
+		case "rpl_semi_sync_source_enabled":
+			variable = "rpl_semi_sync_replica"
 		}
 	}
+	if variable == "" {
+		b.cfg.Logger.Errorf("master does not support semi synchronous replication, use no semi-sync")
+		b.cfg.SemiSyncEnabled = false
+		return nil
+	}
 
-	_, err := b.c.Execute(`SET @rpl_semi_sync_slave = 1;`)
-	if err != nil {
+	if _, err = b.c.Execute(fmt.Sprintf("SET @%s = 1;", variable)); err != nil {
 		return errors.Trace(err)
 	}
 
@@ -445,6 +522,14 @@ func (b *BinlogSyncer) StartSyncGTID(gset GTIDSet) (*BinlogStreamer, error) {
 		return nil, errors.Trace(errSyncRunning)
 	}
 
+	// a MariaDB GTID set can only be synced from a MariaDB master, the flavor tells the master the syncer
+	// understands the MariaDB GTIDs
+	if _, ok := gset.(*MariadbGTIDSet); ok && b.cfg.Flavor != MariaDBFlavor {
+		b.cfg.Logger.Infof("sync MariaDB GTID set, use flavor %s", MariaDBFlavor)
+		b.cfg.Flavor = MariaDBFlavor
+		b.parser.SetFlavor(MariaDBFlavor)
+	}
+
 	// establishing network connection here and will start getting binlog events from "gset + 1", thus until first
 	// MariadbGTIDEvent/GTIDEvent event is received - we effectively do not have a "current GTID"
 	b.currGset = nil
@@ -627,6 +712,43 @@ func (b *BinlogSyncer) replySemiSyncACK(p Position) error {
 	return nil
 }
 
+// skipSemiSyncACK prepares the connection to read the events following an event to acknowledge, without replying
+// the ACK: the master resets the sequence of its packets after such an event, as if it read the ACK.
+func (b *BinlogSyncer) skipSemiSyncACK() {
+	b.c.ResetSequence()
+	b.c.Sequence++
+}
+
+// AckSemiSync acknowledges to the semi-sync master that the events up to pos are durable, with SemiSyncManualAck.
+// pos is the position following the last durable event, i.e. its log position in the file of the last rotate
+// event. It may be called by any goroutine, the positions already acknowledged are ignored.
+func (b *BinlogSyncer) AckSemiSync(pos Position) error {
+	b.m.RLock()
+	defer b.m.RUnlock()
+	b.ackMu.Lock()
+	defer b.ackMu.Unlock()
+
+	if !b.cfg.SemiSyncEnabled || b.c == nil {
+		return nil
+	}
+	if pos.Compare(b.ackPos) <= 0 {
+		return nil
+	}
+
+	// written at once, without the sequence of the connection used to read the events
+	data := make([]byte, 4+1+8+len(pos.Name))
+	length := len(data) - 4
+	data[0], data[1], data[2] = byte(length), byte(length>>8), byte(length>>16)
+	data[4] = SemiSyncIndicator
+	binary.LittleEndian.PutUint64(data[5:], uint64(pos.Pos))
+	copy(data[13:], pos.Name)
+	if _, err := b.c.Conn.Conn.Write(data); err != nil {
+		return errors.Trace(err)
+	}
+	b.ackPos = pos
+	return nil
+}
+
 func (b *BinlogSyncer) retrySync() error {
 	b.m.Lock()
 	defer b.m.Unlock()
@@ -644,6 +766,7 @@ func (b *BinlogSyncer) retrySync() error {
 		if err := b.prepareSyncGTID(b.prevGset); err != nil {
 			return errors.Trace(err)
 		}
+		b.resumePos = b.nextPos
 	} else {
 		b.cfg.Logger.Infof("begin to re-sync from %s", b.nextPos)
 		if err := b.prepareSyncPos(b.nextPos); err != nil {
@@ -704,42 +827,62 @@ func (b *BinlogSyncer) onStream(s *BinlogStreamer) {
 		b.wg.Done()
 	}()
 
+	// with the pipeline, the events read are sent before the streamer is closed
+	var p *parsePipeline
+	if b.cfg.ParseWorkers > 0 {
+		p = newParsePipeline(b, s)
+	}
+	closeStream := func(err error) {
+		if p != nil {
+			p.close(err)
+		} else {
+			s.closeWithError(err)
+		}
+	}
+
 	for {
 		data, err := b.c.ReadPacket()
 		select {
 		case <-b.ctx.Done():
-			s.close()
+			closeStream(nil)
 			return
 		default:
 		}
 
 		if err != nil {
 			b.cfg.Logger.Error(err)
+			if p != nil {
+				// the position is the one of the last event parsed
+				if err := p.sync(); err != nil {
+					closeStream(err)
+					return
+				}
+			}
 			// we meet connection error, should re-connect again with
 			// last nextPos or nextGTID we got.
 			if len(b.nextPos.Name) == 0 && b.prevGset == nil {
 				// we can't get the correct position, close.
-				s.closeWithError(err)
+				closeStream(err)
 				return
 			}
 
 			if b.cfg.DisableRetrySync {
 				b.cfg.Logger.Warn("retry sync is disabled")
-				s.closeWithError(err)
+				closeStream(err)
 				return
 			}
 
 			for {
 				select {
 				case <-b.ctx.Done():
-					s.close()
+					closeStream(nil)
 					return
 				case <-time.After(time.Second):
 					b.retryCount++
 					if err = b.retrySync(); err != nil {
 						if b.cfg.MaxReconnectAttempts > 0 && b.retryCount >= b.cfg.MaxReconnectAttempts {
 							b.cfg.Logger.Errorf("retry sync err: %v, exceeded max retries (%d)", err, b.cfg.MaxReconnectAttempts)
-							s.closeWithError(err)
+							closeStream(err)
 							return
 						}
 
@@ -751,6 +894,14 @@ func (b *BinlogSyncer) onStream(s *BinlogStreamer) {
 				break
 			}
 
+			if b.cfg.OnReconnect != nil {
+				var gset GTIDSet
+				if b.prevGset != nil {
+					gset = b.prevGset.Clone()
+				}
+				b.cfg.OnReconnect(b.nextPos, gset)
+			}
+
 			// we connect the server and begin to re-sync again.
 			continue
 		}
@@ -765,13 +916,18 @@ func (b *BinlogSyncer) onStream(s *BinlogStreamer) {
 
 		switch data[0] {
 		case OK_HEADER:
-			if err = b.parseEvent(s, data); err != nil {
-				s.closeWithError(err)
+			if p != nil {
+				err = p.push(data)
+			} else {
+				err = b.parseEvent(s, data)
+			}
+			if err != nil {
+				closeStream(err)
 				return
 			}
 		case ERR_HEADER:
 			err = b.c.HandleErrorPacket(data)
-			s.closeWithError(err)
+			closeStream(err)
 			return
 		case EOF_HEADER:
 			// refer to https://dev.mysql.com/doc/internals/en/com-binlog-dump.html#binlog-dump-non-block
@@ -787,6 +943,51 @@ func (b *BinlogSyncer) onStream(s *BinlogStreamer) {
 }
 
 func (b *BinlogSyncer) parseEvent(s *BinlogStreamer, data []byte) error {
+	data, needACK := b.stripStreamHeader(data)
+
+	e, err := b.parser.Parse(data)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	needStop := false
+	for _, e := range b.streamEvents(e) {
+		resent := b.isResent(e)
+		if err = b.trackEvent(e); err != nil {
+			return errors.Trace(err)
+		}
+		if resent || e.filtered {
+			continue
+		}
+
+		select {
+		case s.ch <- e:
+		case <-b.ctx.Done():
+			needStop = true
+		}
+		if needStop {
+			break
+		}
+	}
+
+	if needACK {
+		if b.cfg.SemiSyncManualAck {
+			b.skipSemiSyncACK()
+		} else if err := b.replySemiSyncACK(b.nextPos); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	if needStop {
+		return errors.New("sync is been closing...")
+	}
+
+	return nil
+}
+
+// stripStreamHeader returns the event of a packet of the binlog stream, and whether it must be acknowledged to a
+// semi-sync master.
+func (b *BinlogSyncer) stripStreamHeader(data []byte) ([]byte, bool) {
 	//skip OK byte, 0x00
 	data = data[1:]
 
@@ -796,12 +997,44 @@ func (b *BinlogSyncer) parseEvent(s *BinlogStreamer, data []byte) error {
 		//skip semi sync header
 		data = data[2:]
 	}
+	return data, needACK
+}
 
-	e, err := b.parser.Parse(data)
-	if err != nil {
-		return errors.Trace(err)
+// streamEvents returns the events sent to the streamer for a parsed event: the events of the payload of a
+// TransactionPayloadEvent with its log position, unless KeepTransactionPayloadEvent is set, or the event itself.
+func (b *BinlogSyncer) streamEvents(e *BinlogEvent) []*BinlogEvent {
+	payload, ok := e.Event.(*TransactionPayloadEvent)
+	if !ok || b.cfg.KeepTransactionPayloadEvent || len(payload.Events) == 0 {
+		return []*BinlogEvent{e}
+	}
+
+	for _, pe := range payload.Events {
+		pe.Header.LogPos = e.Header.LogPos
 	}
+	return payload.Events
+}
 
+// isResent tells whether an event was already sent to the streamer before the sync was resumed by GTID: the master
+// sends the interrupted transaction again from its beginning. It must be called before trackEvent.
+func (b *BinlogSyncer) isResent(e *BinlogEvent) bool {
+	if len(b.resumePos.Name) == 0 || e.Header.LogPos == 0 {
+		return false
+	}
+	switch e.Event.(type) {
+	case *RotateEvent, *FormatDescriptionEvent:
+		// describe the stream, sent by the master on each dump
+		return false
+	}
+
+	if b.nextPos.Name == b.resumePos.Name && e.Header.LogPos <= b.resumePos.Pos {
+		return true
+	}
+	b.resumePos = Position{}
+	return false
+}
+
+// trackEvent updates the next position and the GTID sets of the syncer with a parsed event.
+func (b *BinlogSyncer) trackEvent(e *BinlogEvent) error {
 	if e.Header.LogPos > 0 {
 		// Some events like FormatDescriptionEvent return 0, ignore.
 		b.nextPos.Pos = e.Header.LogPos
@@ -841,8 +1074,7 @@ func (b *BinlogSyncer) parseEvent(s *BinlogStreamer, data []byte) error {
 			b.currGset = b.prevGset.Clone()
 		}
 		prev := b.currGset.Clone()
-		err = b.currGset.(*MariadbGTIDSet).AddSet(&event.GTID)
-		if err != nil {
+		if err := b.currGset.(*MariadbGTIDSet).AddSet(&event.GTID); err != nil {
 			return errors.Trace(err)
 		}
 		// right after reconnect we will see same gtid as we saw before, thus currGset will not get changed
@@ -859,24 +1091,6 @@ func (b *BinlogSyncer) parseEvent(s *BinlogStreamer, data []byte) error {
 		}
 	}
 
-	needStop := false
-	select {
-	case s.ch <- e:
-	case <-b.ctx.Done():
-		needStop = true
-	}
-
-	if needACK {
-		err := b.replySemiSyncACK(b.nextPos)
-		if err != nil {
-			return errors.Trace(err)
-		}
-	}
-
-	if needStop {
-		return errors.New("sync is been closing...")
-	}
-
 	return nil
 }
 
@@ -900,6 +1114,7 @@ func (b *BinlogSyncer) newConnection(ctx context.Context) (*client.Conn, error)
 		"", b.cfg.Dialer, func(c *client.Conn) {
 			c.SetTLSConfig(b.cfg.TLSConfig)
 			c.SetAttributes(map[string]string{"_client_role": "binary_log_listener"})
+			c.SetLogger(b.cfg.Logger)
 		})
 }
 
@@ -0,0 +1,52 @@
+package replication
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+func TestSyncerReadTimeout(t *testing.T) {
+	b := NewBinlogSyncer(BinlogSyncerConfig{ServerID: 100, HeartbeatPeriod: time.Second})
+	require.Equal(t, 2*time.Second, b.cfg.ReadTimeout)
+
+	b = NewBinlogSyncer(BinlogSyncerConfig{ServerID: 100, HeartbeatPeriod: time.Second, ReadTimeout: time.Minute})
+	require.Equal(t, time.Minute, b.cfg.ReadTimeout)
+}
+
+func TestSyncerSkipResentEvents(t *testing.T) {
+	for _, workers := range []int{0, 2} {
+		b := NewBinlogSyncer(BinlogSyncerConfig{ServerID: 100, ParseWorkers: workers})
+		// resumed by GTID after the rows event of tbl was read
+		b.nextPos = mysql.Position{Name: "mysql-bin.000001", Pos: 0xcf}
+		b.resumePos = b.nextPos
+
+		s := NewBinlogStreamer()
+		var p *parsePipeline
+		if workers > 0 {
+			p = newParsePipeline(b, s)
+		}
+		for _, data := range mysql57Events {
+			data = append([]byte{mysql.OK_HEADER}, data...)
+			if p != nil {
+				require.NoError(t, p.push(data))
+			} else {
+				require.NoError(t, b.parseEvent(s, data))
+			}
+		}
+		if p != nil {
+			p.close(nil)
+		}
+
+		// the table map and the rows events of tbl are skipped
+		events := s.DumpEvents()
+		require.Len(t, events, 2)
+		require.Equal(t, mysql57Events[0], events[0].RawData)
+		require.Equal(t, mysql57Events[3], events[1].RawData)
+		require.Equal(t, mysql.Position{Name: "mysql-bin.000001", Pos: 0xfd}, b.GetNextPosition())
+		require.Empty(t, b.resumePos.Name)
+	}
+}
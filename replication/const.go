@@ -46,6 +46,22 @@ const (
 	BINLOG_MARIADB_FL_ALLOW_PARALLEL              /*8  - FL_ALLOW_PARALLEL reflects the (negation of the) value of @@SESSION.skip_parallel_replication at the time of commit*/
 	BINLOG_MARIADB_FL_WAITED                      /*16 = FL_WAITED is set if a row lock wait (or other wait) is detected during the execution of the transaction*/
 	BINLOG_MARIADB_FL_DDL                         /*32 - FL_DDL is set for event group containing DDL*/
+	BINLOG_MARIADB_FL_PREPARED_XA                 /*64 - FL_PREPARED_XA is set for XA transaction*/
+	BINLOG_MARIADB_FL_COMPLETED_XA                /*128 - FL_COMPLETED_XA is set for XA transaction*/
+)
+
+// the extra flags of MariadbGTIDEvent, since MariaDB 10.6
+const (
+	BINLOG_MARIADB_FL_EXTRA_MULTI_ENGINE = 1 << iota /*1 - the transaction modified several engines*/
+	BINLOG_MARIADB_FL_START_ALTER                    /*2 - the first part of a 2-phase ALTER TABLE*/
+	BINLOG_MARIADB_FL_COMMIT_ALTER                   /*4 - the commit of a 2-phase ALTER TABLE*/
+	BINLOG_MARIADB_FL_ROLLBACK_ALTER                 /*8 - the rollback of a 2-phase ALTER TABLE*/
+)
+
+// the flags of MariadbGTIDListEvent
+const (
+	BINLOG_MARIADB_GTID_LIST_FL_UNTIL_REACHED = 1 << iota /*1 - the slave reached the position of START SLAVE UNTIL*/
+	BINLOG_MARIADB_GTID_LIST_FL_IGN_GTIDS                 /*2 - the GTIDs of the list were skipped by the slave*/
 )
 
 type EventType byte
@@ -106,6 +122,9 @@ const (
 	MARIADB_WRITE_ROWS_COMPRESSED_EVENT_V1
 	MARIADB_UPDATE_ROWS_COMPRESSED_EVENT_V1
 	MARIADB_DELETE_ROWS_COMPRESSED_EVENT_V1
+	MARIADB_WRITE_ROWS_COMPRESSED_EVENT
+	MARIADB_UPDATE_ROWS_COMPRESSED_EVENT
+	MARIADB_DELETE_ROWS_COMPRESSED_EVENT
 )
 
 func (e EventType) String() string {
@@ -212,6 +231,12 @@ func (e EventType) String() string {
 		return "MariadbUpdateRowsCompressedEventV1"
 	case MARIADB_DELETE_ROWS_COMPRESSED_EVENT_V1:
 		return "MariadbDeleteRowsCompressedEventV1"
+	case MARIADB_WRITE_ROWS_COMPRESSED_EVENT:
+		return "MariadbWriteRowsCompressedEvent"
+	case MARIADB_UPDATE_ROWS_COMPRESSED_EVENT:
+		return "MariadbUpdateRowsCompressedEvent"
+	case MARIADB_DELETE_ROWS_COMPRESSED_EVENT:
+		return "MariadbDeleteRowsCompressedEvent"
 
 	default:
 		return "UnknownEvent"
@@ -31,6 +31,9 @@ type BinlogEvent struct {
 
 	Header *EventHeader
 	Event  Event
+
+	// set if the event is filtered out by the EventFilter of the parser
+	filtered bool
 }
 
 func (e *BinlogEvent) Dump(w io.Writer) {
@@ -100,9 +103,15 @@ func (h *EventHeader) Decode(data []byte) error {
 	return nil
 }
 
+// Time returns the time the statement of the event started on its originating server, ServerID.
+func (h *EventHeader) Time() time.Time {
+	return time.Unix(int64(h.Timestamp), 0)
+}
+
 func (h *EventHeader) Dump(w io.Writer) {
 	fmt.Fprintf(w, "=== %s ===\n", h.EventType)
-	fmt.Fprintf(w, "Date: %s\n", time.Unix(int64(h.Timestamp), 0).Format(TimeFormat))
+	fmt.Fprintf(w, "Date: %s\n", h.Time().Format(TimeFormat))
+	fmt.Fprintf(w, "Server ID: %d\n", h.ServerID)
 	fmt.Fprintf(w, "Log position: %d\n", h.LogPos)
 	fmt.Fprintf(w, "Event size: %d\n", h.EventSize)
 }
@@ -580,15 +589,27 @@ func (e *MariadbAnnotateRowsEvent) Dump(w io.Writer) {
 
 type MariadbBinlogCheckPointEvent struct {
 	Info []byte
+	// BinlogFile is the oldest binlog file the master needs for crash recovery, its transactions before it
+	// are durable in the storage engines
+	BinlogFile string
 }
 
 func (e *MariadbBinlogCheckPointEvent) Decode(data []byte) error {
 	e.Info = data
+	if len(data) < 4 {
+		return errors.Errorf("invalid binlog checkpoint event length %d", len(data))
+	}
+	n := int(binary.LittleEndian.Uint32(data))
+	if len(data) < 4+n {
+		return errors.Errorf("invalid binlog checkpoint event length %d, file name length %d", len(data), n)
+	}
+	e.BinlogFile = string(data[4 : 4+n])
 	return nil
 }
 
 func (e *MariadbBinlogCheckPointEvent) Dump(w io.Writer) {
 	fmt.Fprintf(w, "Info: %s\n", e.Info)
+	fmt.Fprintf(w, "Binlog file: %s\n", e.BinlogFile)
 	fmt.Fprintln(w)
 }
 
@@ -596,6 +617,28 @@ type MariadbGTIDEvent struct {
 	GTID     MariadbGTID
 	Flags    byte
 	CommitID uint64
+
+	// XID is the id of the XA transaction, if IsXA
+	XID XID
+
+	// FlagsExtra are the BINLOG_MARIADB_FL_EXTRA_* and BINLOG_MARIADB_FL_*_ALTER flags of MariaDB 10.6+
+	FlagsExtra byte
+	// ExtraEngines is the number of engines modified by the transaction but one, with
+	// BINLOG_MARIADB_FL_EXTRA_MULTI_ENGINE
+	ExtraEngines byte
+	// StartAlterSeqNo is the sequence number of the start of the ALTER TABLE committed or rolled back
+	StartAlterSeqNo uint64
+}
+
+// XID is the id of an XA transaction, see XA START.
+type XID struct {
+	FormatID uint32
+	Gtrid    []byte
+	Bqual    []byte
+}
+
+func (x XID) String() string {
+	return fmt.Sprintf("X'%x',X'%x',%d", x.Gtrid, x.Bqual, x.FormatID)
 }
 
 func (e *MariadbGTIDEvent) IsDDL() bool {
@@ -610,6 +653,11 @@ func (e *MariadbGTIDEvent) IsGroupCommit() bool {
 	return (e.Flags & BINLOG_MARIADB_FL_GROUP_COMMIT_ID) != 0
 }
 
+// IsXA tells whether the event starts the XA PREPARE or the XA COMMIT/ROLLBACK of an XA transaction.
+func (e *MariadbGTIDEvent) IsXA() bool {
+	return (e.Flags & (BINLOG_MARIADB_FL_PREPARED_XA | BINLOG_MARIADB_FL_COMPLETED_XA)) != 0
+}
+
 func (e *MariadbGTIDEvent) Decode(data []byte) error {
 	pos := 0
 	e.GTID.SequenceNumber = binary.LittleEndian.Uint64(data)
@@ -621,6 +669,33 @@ func (e *MariadbGTIDEvent) Decode(data []byte) error {
 
 	if (e.Flags & BINLOG_MARIADB_FL_GROUP_COMMIT_ID) > 0 {
 		e.CommitID = binary.LittleEndian.Uint64(data[pos:])
+		pos += 8
+	}
+
+	// the fields below are only read if present, as MariaDB does
+	if e.IsXA() && len(data) >= pos+6 {
+		e.XID.FormatID = binary.LittleEndian.Uint32(data[pos:])
+		gtridLen, bqualLen := int(data[pos+4]), int(data[pos+5])
+		pos += 6
+		if len(data) < pos+gtridLen+bqualLen {
+			return errors.Errorf("invalid MariaDB GTID event length %d, XID length %d", len(data), gtridLen+bqualLen)
+		}
+		e.XID.Gtrid = data[pos : pos+gtridLen]
+		e.XID.Bqual = data[pos+gtridLen : pos+gtridLen+bqualLen]
+		pos += gtridLen + bqualLen
+	}
+
+	// without commit id, the event is padded with zeros, i.e. no extra flags
+	if pos < len(data) {
+		e.FlagsExtra = data[pos]
+		pos++
+		if e.FlagsExtra&BINLOG_MARIADB_FL_EXTRA_MULTI_ENGINE != 0 && pos < len(data) {
+			e.ExtraEngines = data[pos]
+			pos++
+		}
+		if e.FlagsExtra&(BINLOG_MARIADB_FL_COMMIT_ALTER|BINLOG_MARIADB_FL_ROLLBACK_ALTER) != 0 && len(data) >= pos+8 {
+			e.StartAlterSeqNo = binary.LittleEndian.Uint64(data[pos:])
+		}
 	}
 
 	return nil
@@ -630,6 +705,14 @@ func (e *MariadbGTIDEvent) Dump(w io.Writer) {
 	fmt.Fprintf(w, "GTID: %v\n", e.GTID)
 	fmt.Fprintf(w, "Flags: %v\n", e.Flags)
 	fmt.Fprintf(w, "CommitID: %v\n", e.CommitID)
+	if e.IsXA() {
+		fmt.Fprintf(w, "XID: %s\n", e.XID)
+	}
+	if e.FlagsExtra != 0 {
+		fmt.Fprintf(w, "Flags extra: %v\n", e.FlagsExtra)
+		fmt.Fprintf(w, "Extra engines: %v\n", e.ExtraEngines)
+		fmt.Fprintf(w, "Start ALTER sequence number: %v\n", e.StartAlterSeqNo)
+	}
 	fmt.Fprintln(w)
 }
 
@@ -639,14 +722,23 @@ func (e *MariadbGTIDEvent) GTIDNext() (GTIDSet, error) {
 
 type MariadbGTIDListEvent struct {
 	GTIDs []MariadbGTID
+	// Flags are the BINLOG_MARIADB_GTID_LIST_FL_* flags
+	Flags byte
 }
 
 func (e *MariadbGTIDListEvent) Decode(data []byte) error {
+	if len(data) < 4 {
+		return errors.Errorf("invalid GTID list event length %d", len(data))
+	}
 	pos := 0
 	v := binary.LittleEndian.Uint32(data[pos:])
 	pos += 4
 
 	count := v & uint32((1<<28)-1)
+	e.Flags = byte(v >> 28)
+	if uint64(len(data)) < 4+16*uint64(count) {
+		return errors.Errorf("invalid GTID list event length %d, %d GTIDs", len(data), count)
+	}
 
 	e.GTIDs = make([]MariadbGTID, count)
 
@@ -662,8 +754,25 @@ func (e *MariadbGTIDListEvent) Decode(data []byte) error {
 	return nil
 }
 
+// GTIDSet returns the GTID set of the GTIDs of the list, the binlog state of the master at the start of the binlog
+// file: the last GTID of each domain.
+func (e *MariadbGTIDListEvent) GTIDSet() *MariadbGTIDSet {
+	s := &MariadbGTIDSet{Sets: make(map[uint32]*MariadbGTID)}
+	for i := range e.GTIDs {
+		gtid := e.GTIDs[i]
+		// the list has the last GTID of each server of a domain
+		if o, ok := s.Sets[gtid.DomainID]; !ok || gtid.SequenceNumber > o.SequenceNumber {
+			s.Sets[gtid.DomainID] = &gtid
+		}
+	}
+	return s
+}
+
 func (e *MariadbGTIDListEvent) Dump(w io.Writer) {
 	fmt.Fprintf(w, "Lists: %v\n", e.GTIDs)
+	if e.Flags != 0 {
+		fmt.Fprintf(w, "Flags: %v\n", e.Flags)
+	}
 	fmt.Fprintln(w)
 }
 
@@ -6,6 +6,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	. "github.com/atoonk/go-mysql/mysql"
 )
 
 func TestMariadbGTIDListEvent(t *testing.T) {
@@ -56,6 +58,68 @@ func TestMariadbGTIDEvent(t *testing.T) {
 	require.Equal(t, "70975786-0-578437695752307201", set.String())
 }
 
+func TestMariadbGTIDEventXA(t *testing.T) {
+	data := []byte{
+		1, 0, 0, 0, 0, 0, 0, 0, // SequenceNumber
+		2, 0, 0, 0, // DomainID
+		BINLOG_MARIADB_FL_PREPARED_XA | BINLOG_MARIADB_FL_TRANSACTIONAL, // Flags
+		1, 0, 0, 0, // formatID
+		2, 1, // gtrid and bqual lengths
+		'a', 'b', 'c', // gtrid and bqual
+		BINLOG_MARIADB_FL_EXTRA_MULTI_ENGINE | BINLOG_MARIADB_FL_COMMIT_ALTER, // FlagsExtra
+		1,                      // ExtraEngines
+		9, 0, 0, 0, 0, 0, 0, 0, // StartAlterSeqNo
+	}
+	ev := MariadbGTIDEvent{}
+	require.NoError(t, ev.Decode(data))
+	require.True(t, ev.IsXA())
+	require.False(t, ev.IsGroupCommit())
+	require.Equal(t, XID{FormatID: 1, Gtrid: []byte("ab"), Bqual: []byte("c")}, ev.XID)
+	require.Equal(t, "X'6162',X'63',1", ev.XID.String())
+	require.Equal(t, byte(1), ev.ExtraEngines)
+	require.Equal(t, uint64(9), ev.StartAlterSeqNo)
+
+	// without commit id, the padding is not taken for extra flags
+	data = []byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, BINLOG_MARIADB_FL_STANDALONE, 0, 0, 0, 0, 0, 0}
+	ev = MariadbGTIDEvent{}
+	require.NoError(t, ev.Decode(data))
+	require.False(t, ev.IsXA())
+	require.Equal(t, byte(0), ev.FlagsExtra)
+
+	ev = MariadbGTIDEvent{}
+	require.Error(t, ev.Decode([]byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, BINLOG_MARIADB_FL_COMPLETED_XA, 1, 0, 0, 0, 8, 8, 'a'}))
+}
+
+func TestMariadbGTIDListEventFlags(t *testing.T) {
+	// 0-1-5,0-2-7,1-1-3 with FL_IGN_GTIDS
+	data := []byte{3, 0, 0, 0x20}
+	for _, gtid := range []MariadbGTID{
+		{DomainID: 0, ServerID: 1, SequenceNumber: 5},
+		{DomainID: 0, ServerID: 2, SequenceNumber: 7},
+		{DomainID: 1, ServerID: 1, SequenceNumber: 3},
+	} {
+		data = append(data, Uint32ToBytes(gtid.DomainID)...)
+		data = append(data, Uint32ToBytes(gtid.ServerID)...)
+		data = append(data, Uint64ToBytes(gtid.SequenceNumber)...)
+	}
+	ev := MariadbGTIDListEvent{}
+	require.NoError(t, ev.Decode(data))
+	require.Len(t, ev.GTIDs, 3)
+	require.Equal(t, byte(BINLOG_MARIADB_GTID_LIST_FL_IGN_GTIDS), ev.Flags)
+	require.Equal(t, "0-2-7,1-1-3", ev.GTIDSet().String())
+
+	require.Error(t, ev.Decode(data[:len(data)-1]))
+}
+
+func TestMariadbBinlogCheckPointEvent(t *testing.T) {
+	data := append([]byte{16, 0, 0, 0}, "mysql-bin.000002"...)
+	ev := MariadbBinlogCheckPointEvent{}
+	require.NoError(t, ev.Decode(data))
+	require.Equal(t, "mysql-bin.000002", ev.BinlogFile)
+
+	require.Error(t, ev.Decode(data[:10]))
+}
+
 func TestGTIDEventMysql8NewFields(t *testing.T) {
 	testcases := []struct {
 		data                           []byte
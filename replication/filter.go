@@ -0,0 +1,103 @@
+package replication
+
+import (
+	"regexp"
+	"sync"
+)
+
+// EventFilter selects the events decoded by a BinlogParser and sent by a BinlogSyncer, see
+// BinlogParser.SetEventFilter. The events filtered out are not decoded, but the ones the parser and the syncer
+// need: the format description, rotate, table map, GTID and transaction payload events. The rows events of the
+// tables filtered out are only decoded up to their table id.
+//
+// The filtered events are not passed to the OnEventFunc of ParseReader nor sent by the syncer. Parse returns them
+// as GenericEvent, or as RowsEvent without Rows for the rows events; the Events of a TransactionPayloadEvent kept
+// with KeepTransactionPayloadEvent include them too.
+type EventFilter struct {
+	// IncludeTables and ExcludeTables match the tables of the rows events as "db.table": only the tables matching
+	// an IncludeTables regexp, if any, and no ExcludeTables regexp are decoded, e.g. IncludeTables
+	// [`^shop\.orders$`] or ExcludeTables [`^mysql\.`].
+	IncludeTables []*regexp.Regexp
+	ExcludeTables []*regexp.Regexp
+
+	// IncludeEventTypes, if not empty, are the types of the events decoded, ExcludeEventTypes the ones not decoded.
+	IncludeEventTypes []EventType
+	ExcludeEventTypes []EventType
+
+	mu sync.Mutex
+	// whether the tables are filtered out, by "db.table"
+	tables map[string]bool
+}
+
+func (f *EventFilter) includesType(t EventType) bool {
+	for _, excluded := range f.ExcludeEventTypes {
+		if t == excluded {
+			return false
+		}
+	}
+	if len(f.IncludeEventTypes) == 0 {
+		return true
+	}
+	for _, included := range f.IncludeEventTypes {
+		if t == included {
+			return true
+		}
+	}
+	return false
+}
+
+// decodes tells whether the events of a type are decoded, the events the parser and the syncer need always are.
+func (f *EventFilter) decodes(t EventType) bool {
+	switch t {
+	case FORMAT_DESCRIPTION_EVENT, ROTATE_EVENT, TABLE_MAP_EVENT, GTID_EVENT, MARIADB_GTID_EVENT, TRANSACTION_PAYLOAD_EVENT:
+		return true
+	}
+	return f.includesType(t)
+}
+
+// excludesTable tells whether the rows events of a table are filtered out.
+func (f *EventFilter) excludesTable(schema, table string) bool {
+	if len(f.IncludeTables) == 0 && len(f.ExcludeTables) == 0 {
+		return false
+	}
+
+	key := schema + "." + table
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if excluded, ok := f.tables[key]; ok {
+		return excluded
+	}
+
+	excluded := len(f.IncludeTables) > 0
+	for _, r := range f.IncludeTables {
+		if r.MatchString(key) {
+			excluded = false
+			break
+		}
+	}
+	for _, r := range f.ExcludeTables {
+		if !excluded && r.MatchString(key) {
+			excluded = true
+		}
+	}
+
+	if f.tables == nil {
+		f.tables = make(map[string]bool)
+	}
+	f.tables[key] = excluded
+	return excluded
+}
+
+// filters tells whether a parsed event is filtered out.
+func (f *EventFilter) filters(h *EventHeader, e Event) bool {
+	if !f.includesType(h.EventType) {
+		return true
+	}
+	switch e := e.(type) {
+	case *TableMapEvent:
+		return e.filtered
+	case *RowsEvent:
+		return e.Table != nil && e.Table.filtered
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package replication
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+func TestEventFilter(t *testing.T) {
+	var binlog []byte
+	for _, data := range mysql57Events {
+		binlog = append(binlog, data...)
+	}
+
+	parse := func(filter *EventFilter) []*BinlogEvent {
+		p := NewBinlogParser()
+		p.SetEventFilter(filter)
+		var events []*BinlogEvent
+		err := p.ParseReader(bytes.NewReader(binlog), func(e *BinlogEvent) error {
+			events = append(events, e)
+			return nil
+		})
+		require.NoError(t, err)
+		return events
+	}
+
+	// the table map and the rows events of db.tbl are filtered out
+	events := parse(&EventFilter{ExcludeTables: []*regexp.Regexp{regexp.MustCompile(`^db\.`)}})
+	require.Len(t, events, 2)
+	require.Equal(t, FORMAT_DESCRIPTION_EVENT, events[0].Header.EventType)
+	require.Equal(t, []byte("tbl1"), events[1].Event.(*TableMapEvent).Table)
+
+	events = parse(&EventFilter{IncludeTables: []*regexp.Regexp{regexp.MustCompile(`^db\.tbl$`)}})
+	require.Len(t, events, 3)
+	require.Equal(t, [][]interface{}{{int32(1)}}, events[2].Event.(*RowsEvent).Rows)
+
+	// the table maps are still decoded for the rows events
+	events = parse(&EventFilter{ExcludeEventTypes: []EventType{TABLE_MAP_EVENT}})
+	require.Len(t, events, 2)
+	require.Equal(t, [][]interface{}{{int32(1)}}, events[1].Event.(*RowsEvent).Rows)
+
+	events = parse(&EventFilter{IncludeEventTypes: []EventType{WRITE_ROWS_EVENTv2}})
+	require.Len(t, events, 1)
+	require.Equal(t, []byte("tbl"), events[0].Event.(*RowsEvent).Table.Table)
+
+	// the filtered rows events are not decoded
+	p := NewBinlogParser()
+	p.SetEventFilter(&EventFilter{IncludeTables: []*regexp.Regexp{regexp.MustCompile(`^db1\.`)}})
+	for _, data := range mysql57Events[:2] {
+		_, err := p.Parse(data)
+		require.NoError(t, err)
+	}
+	e, err := p.Parse(mysql57Events[2])
+	require.NoError(t, err)
+	require.True(t, e.filtered)
+	require.Equal(t, uint64(1), e.Event.(*RowsEvent).ColumnCount)
+	require.Empty(t, e.Event.(*RowsEvent).Rows)
+}
+
+func TestSyncerEventFilter(t *testing.T) {
+	for _, workers := range []int{0, 2} {
+		b := NewBinlogSyncer(BinlogSyncerConfig{
+			ServerID:     100,
+			ParseWorkers: workers,
+			EventFilter:  &EventFilter{ExcludeTables: []*regexp.Regexp{regexp.MustCompile(`^db\.tbl$`)}},
+		})
+		b.nextPos = mysql.Position{Name: "mysql-bin.000001", Pos: 4}
+
+		s := NewBinlogStreamer()
+		var p *parsePipeline
+		if workers > 0 {
+			p = newParsePipeline(b, s)
+		}
+		for _, data := range mysql57Events {
+			data = append([]byte{mysql.OK_HEADER}, data...)
+			if p != nil {
+				require.NoError(t, p.push(data))
+			} else {
+				require.NoError(t, b.parseEvent(s, data))
+			}
+		}
+		if p != nil {
+			p.close(nil)
+		}
+
+		events := s.DumpEvents()
+		require.Len(t, events, 2)
+		require.Equal(t, mysql57Events[0], events[0].RawData)
+		require.Equal(t, mysql57Events[3], events[1].RawData)
+		// the position follows the events filtered out
+		require.Equal(t, mysql.Position{Name: "mysql-bin.000001", Pos: 0xfd}, b.GetNextPosition())
+	}
+}
@@ -0,0 +1,218 @@
+package replication
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// ApplyJsonDiffs applies the diffs of a partial update of a JSON column to doc, the document of the column in the
+// before image, and returns the updated document, encoded as the JSON values of the rows events.
+func ApplyJsonDiffs(doc string, diffs []*JsonDiff) (string, error) {
+	v, err := unmarshalJsonValue(doc)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	for _, diff := range diffs {
+		if v, err = diff.apply(v); err != nil {
+			return "", errors.Annotatef(err, "apply %s", diff)
+		}
+	}
+
+	// the strings are kept as they are, without escaping <, > and &
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err = enc.Encode(v); err != nil {
+		return "", errors.Trace(err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// unmarshalJsonValue decodes a JSON value, keeping the text of its numbers.
+func unmarshalJsonValue(s string) (interface{}, error) {
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// apply applies the diff to the value v decoded by unmarshalJsonValue, and returns the updated value.
+func (jd *JsonDiff) apply(v interface{}) (interface{}, error) {
+	legs, err := parseJsonPath(jd.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if jd.Op != JsonDiffOperationRemove {
+		if value, err = unmarshalJsonValue(jd.Value); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	if len(legs) == 0 {
+		// only the whole document can be replaced
+		if jd.Op != JsonDiffOperationReplace {
+			return nil, ErrCorruptedJSONDiff
+		}
+		return value, nil
+	}
+	return jd.applyAt(v, legs, value)
+}
+
+// applyAt applies the diff to the value of the path legs in v, and returns v updated.
+func (jd *JsonDiff) applyAt(v interface{}, legs []jsonPathLeg, value interface{}) (interface{}, error) {
+	leg := legs[0]
+
+	switch c := v.(type) {
+	case map[string]interface{}:
+		if leg.isIndex {
+			break
+		}
+		member, ok := c[leg.key]
+		if len(legs) > 1 {
+			if !ok {
+				break
+			}
+			member, err := jd.applyAt(member, legs[1:], value)
+			if err != nil {
+				return nil, err
+			}
+			c[leg.key] = member
+			return c, nil
+		}
+
+		switch jd.Op {
+		case JsonDiffOperationReplace, JsonDiffOperationInsert:
+			c[leg.key] = value
+			return c, nil
+		case JsonDiffOperationRemove:
+			if ok {
+				delete(c, leg.key)
+				return c, nil
+			}
+		}
+	case []interface{}:
+		if !leg.isIndex {
+			break
+		}
+		i := leg.index
+		if leg.last {
+			i = len(c) - 1
+		}
+		if len(legs) > 1 {
+			if i < 0 || i >= len(c) {
+				break
+			}
+			element, err := jd.applyAt(c[i], legs[1:], value)
+			if err != nil {
+				return nil, err
+			}
+			c[i] = element
+			return c, nil
+		}
+
+		switch jd.Op {
+		case JsonDiffOperationReplace:
+			if i >= 0 && i < len(c) {
+				c[i] = value
+				return c, nil
+			}
+		case JsonDiffOperationInsert:
+			// as JSON_ARRAY_INSERT, the value is appended past the end of the array
+			if i < 0 {
+				i = 0
+			} else if i > len(c) {
+				i = len(c)
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+			return c, nil
+		case JsonDiffOperationRemove:
+			if i >= 0 && i < len(c) {
+				return append(c[:i], c[i+1:]...), nil
+			}
+		}
+	}
+
+	return nil, errors.Errorf("JSON path %s not found", jd.Path)
+}
+
+// jsonPathLeg is a member or an array element of a JSON path.
+type jsonPathLeg struct {
+	key     string
+	isIndex bool
+	index   int
+	// the last element of the array
+	last bool
+}
+
+// parseJsonPath parses the JSON path of a diff, e.g. $.a."b c"[2], with the member and array legs written by MySQL.
+func parseJsonPath(path string) ([]jsonPathLeg, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, errors.Errorf("invalid JSON path %q", path)
+	}
+
+	var legs []jsonPathLeg
+	for p := path[1:]; len(p) > 0; {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			if strings.HasPrefix(p, `"`) {
+				// the quoted keys are JSON strings
+				end := 1
+				for ; end < len(p) && p[end] != '"'; end++ {
+					if p[end] == '\\' {
+						end++
+					}
+				}
+				var key string
+				if end >= len(p) || json.Unmarshal([]byte(p[:end+1]), &key) != nil {
+					return nil, errors.Errorf("invalid JSON path %q", path)
+				}
+				legs = append(legs, jsonPathLeg{key: key})
+				p = p[end+1:]
+			} else {
+				end := strings.IndexAny(p, ".[")
+				if end < 0 {
+					end = len(p)
+				}
+				if end == 0 {
+					return nil, errors.Errorf("invalid JSON path %q", path)
+				}
+				legs = append(legs, jsonPathLeg{key: p[:end]})
+				p = p[end:]
+			}
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, errors.Errorf("invalid JSON path %q", path)
+			}
+			leg := jsonPathLeg{isIndex: true}
+			if index := strings.TrimSpace(p[1:end]); index == "last" {
+				leg.last = true
+			} else {
+				n, err := strconv.Atoi(index)
+				if err != nil || n < 0 {
+					return nil, errors.Errorf("invalid JSON path %q", path)
+				}
+				leg.index = n
+			}
+			legs = append(legs, leg)
+			p = p[end+1:]
+		default:
+			return nil, errors.Errorf("invalid JSON path %q", path)
+		}
+	}
+	return legs, nil
+}
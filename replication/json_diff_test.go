@@ -0,0 +1,86 @@
+package replication
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+func TestApplyJsonDiffs(t *testing.T) {
+	testcases := []struct {
+		doc   string
+		diffs []*JsonDiff
+		want  string
+	}{
+		{`{"a":1,"b":2}`, []*JsonDiff{{Op: JsonDiffOperationReplace, Path: "$.a", Value: `"x"`}}, `{"a":"x","b":2}`},
+		{`{"a":1}`, []*JsonDiff{{Op: JsonDiffOperationInsert, Path: "$.c", Value: `[1.50]`}}, `{"a":1,"c":[1.50]}`},
+		{`{"a":1,"b":2}`, []*JsonDiff{{Op: JsonDiffOperationRemove, Path: "$.b"}}, `{"a":1}`},
+		{`{"a b":{"c":[1,2,3]}}`, []*JsonDiff{
+			{Op: JsonDiffOperationReplace, Path: `$."a b".c[0]`, Value: `10`},
+			{Op: JsonDiffOperationInsert, Path: `$."a b".c[1]`, Value: `15`},
+			{Op: JsonDiffOperationRemove, Path: `$."a b".c[last]`},
+			{Op: JsonDiffOperationInsert, Path: `$."a b".c[9]`, Value: `null`},
+		}, `{"a b":{"c":[10,15,2,null]}}`},
+		{`[1,2]`, []*JsonDiff{{Op: JsonDiffOperationReplace, Path: "$", Value: `{"a":true}`}}, `{"a":true}`},
+		// the HTML characters are not escaped
+		{`{"a":"<b>"}`, []*JsonDiff{{Op: JsonDiffOperationInsert, Path: "$.c", Value: `"x & y"`}}, `{"a":"<b>","c":"x & y"}`},
+	}
+
+	for _, tc := range testcases {
+		doc, err := ApplyJsonDiffs(tc.doc, tc.diffs)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, doc)
+	}
+
+	_, err := ApplyJsonDiffs(`{"a":1}`, []*JsonDiff{{Op: JsonDiffOperationRemove, Path: "$.b"}})
+	require.Error(t, err)
+	_, err = ApplyJsonDiffs(`{"a":[1]}`, []*JsonDiff{{Op: JsonDiffOperationReplace, Path: "$.a[3]", Value: "1"}})
+	require.Error(t, err)
+	_, err = ApplyJsonDiffs(`{"a":1}`, []*JsonDiff{{Op: JsonDiffOperationReplace, Path: "a", Value: "1"}})
+	require.Error(t, err)
+}
+
+func TestDecodePartialJSONUpdate(t *testing.T) {
+	// JSON_SET(j, '$.a', 5), JSON_REMOVE(j, '$.b')
+	diffs := []byte{
+		byte(JsonDiffOperationReplace), 3, '$', '.', 'a', 3, JSONB_INT16, 5, 0,
+		byte(JsonDiffOperationRemove), 3, '$', '.', 'b',
+	}
+	// binlog_row_value_options, the partial bitmap of the JSON columns, the NULL bitmap and the length of the value
+	data := []byte{byte(EnumBinlogRowValueOptionsPartialJsonUpdates), 0x01, 0x00, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(data[3:], uint32(len(diffs)))
+	data = append(data, diffs...)
+
+	table := &TableMapEvent{
+		ColumnType: []byte{mysql.MYSQL_TYPE_JSON},
+		ColumnMeta: []uint16{4},
+	}
+	for _, apply := range []bool{false, true} {
+		e := RowsEvent{
+			eventType:        PARTIAL_UPDATE_ROWS_EVENT,
+			Table:            table,
+			ColumnCount:      1,
+			applyPartialJSON: apply,
+			// the before image
+			Rows: [][]interface{}{{`{"a":1,"b":2}`}},
+		}
+		n, err := e.decodeImage(data, []byte{0x01}, EnumRowImageTypeUpdateAI)
+		require.NoError(t, err)
+		require.Len(t, data, n)
+		require.Len(t, e.Rows, 2)
+
+		if apply {
+			require.Equal(t, `{"a":5}`, e.Rows[1][0])
+		} else {
+			require.Equal(t, &JsonDiff{Op: JsonDiffOperationReplace, Path: "$.a", Value: "5"}, e.Rows[1][0])
+		}
+		require.Equal(t, []*JsonDiff{
+			{Op: JsonDiffOperationReplace, Path: "$.a", Value: "5"},
+			{Op: JsonDiffOperationRemove, Path: "$.b"},
+		}, e.JsonDiffs(1, 0))
+		require.Nil(t, e.JsonDiffs(0, 0))
+	}
+}
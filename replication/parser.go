@@ -7,11 +7,16 @@ import (
 	"hash/crc32"
 	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/pingcap/errors"
 
+	. "github.com/atoonk/go-mysql/mysql"
 	"github.com/atoonk/go-mysql/utils"
 )
 
@@ -40,10 +45,27 @@ type BinlogParser struct {
 	useDecimal          bool
 	ignoreJSONDecodeErr bool
 	verifyChecksum      bool
+	applyPartialJSON    bool
+	rowsDecodeOptions   RowsDecodeOptions
+
+	checksumMismatchFunc func(*EventHeader, error) error
+
+	filter *EventFilter
 
 	rowsEventDecodeFunc func(*RowsEvent, []byte) error
 
 	tableMapOptionalMetaDecodeFunc func([]byte) error
+
+	// set by parseDeferringRows, decodeRows decodes the rows of the last rows event parsed
+	deferRows  bool
+	decodeRows func() error
+
+	// the position in the file parsed by ParseFile, past the last event read
+	position Position
+
+	// the statement of the next rows events and the thread of their transaction, see RowsEvent.Query and ThreadID
+	rowsQuery []byte
+	threadID  uint32
 }
 
 func NewBinlogParser() *BinlogParser {
@@ -66,6 +88,12 @@ func (p *BinlogParser) Reset() {
 	p.format = nil
 }
 
+// Position returns the position of the file parsed by ParseFile or ParseDir past the last event read, i.e. the end of
+// the event passed to the OnEventFunc while it runs. The parsing can be resumed from it once stopped or failed.
+func (p *BinlogParser) Position() Position {
+	return p.position
+}
+
 type OnEventFunc func(*BinlogEvent) error
 
 func (p *BinlogParser) ParseFile(name string, offset int64, onEvent OnEventFunc) error {
@@ -82,6 +110,7 @@ func (p *BinlogParser) ParseFile(name string, offset int64, onEvent OnEventFunc)
 		return errors.Errorf("%s is not a valid binlog file, head 4 bytes must fe'bin' ", name)
 	}
 
+	p.position = Position{Name: filepath.Base(name), Pos: 4}
 	if offset < 4 {
 		offset = 4
 	} else if offset > 4 {
@@ -90,7 +119,12 @@ func (p *BinlogParser) ParseFile(name string, offset int64, onEvent OnEventFunc)
 			return errors.Errorf("seek %s to %d error %v", name, offset, err)
 		}
 
-		if err = p.parseFormatDescriptionEvent(f, onEvent); err != nil {
+		// read again, the position is still the offset
+		err = p.parseFormatDescriptionEvent(f, func(e *BinlogEvent) error {
+			p.position.Pos = uint32(offset)
+			return onEvent(e)
+		})
+		if err != nil {
 			return errors.Annotatef(err, "parse FormatDescriptionEvent")
 		}
 	}
@@ -98,10 +132,101 @@ func (p *BinlogParser) ParseFile(name string, offset int64, onEvent OnEventFunc)
 	if _, err = f.Seek(offset, io.SeekStart); err != nil {
 		return errors.Errorf("seek %s to %d error %v", name, offset, err)
 	}
+	p.position.Pos = uint32(offset)
 
 	return p.ParseReader(f, onEvent)
 }
 
+// ParseDir parses the binlog or relay log files of a directory from pos, as the events streamed from a master: the
+// files named like pos.Name, e.g. mysql-bin.000001, mysql-bin.000002..., are parsed in the order of their sequence
+// numbers, pos.Name from pos.Pos and the next ones from their beginning. If pos.Name is empty, the files of the
+// directory are parsed from the first one. Use Position to resume the parsing once stopped or failed.
+func (p *BinlogParser) ParseDir(dir string, pos Position, onEvent OnEventFunc) error {
+	files, err := binlogFiles(dir, pos.Name)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	for _, name := range files {
+		if atomic.LoadUint32(&p.stopProcessing) == 1 {
+			break
+		}
+
+		offset := int64(4)
+		if name == pos.Name {
+			offset = int64(pos.Pos)
+		}
+		if err = p.ParseFile(filepath.Join(dir, name), offset, onEvent); err != nil {
+			return errors.Annotatef(err, "parse %s", name)
+		}
+	}
+	return nil
+}
+
+// binlogFiles returns the names of the binlog files of dir named like name, from name, sorted by sequence number.
+// If name is empty, the binlog files must all have the same base name.
+func binlogFiles(dir string, name string) ([]string, error) {
+	splitName := func(name string) (string, uint64, bool) {
+		i := strings.LastIndexByte(name, '.')
+		if i <= 0 {
+			return "", 0, false
+		}
+		seq, err := strconv.ParseUint(name[i+1:], 10, 64)
+		return name[:i], seq, err == nil
+	}
+
+	var base string
+	var from uint64
+	if len(name) > 0 {
+		var ok bool
+		if base, from, ok = splitName(name); !ok {
+			return nil, errors.Errorf("invalid binlog file name %s", name)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	type binlogFile struct {
+		name string
+		seq  uint64
+	}
+	var files []binlogFile
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		b, seq, ok := splitName(entry.Name())
+		if !ok {
+			// the index files
+			continue
+		}
+		if len(base) == 0 {
+			base = b
+		} else if b != base {
+			if len(name) == 0 {
+				return nil, errors.Errorf("binlog files %s.* and %s.* in %s, the file name must be set", base, b, dir)
+			}
+			continue
+		}
+		if seq >= from {
+			files = append(files, binlogFile{name: entry.Name(), seq: seq})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].seq < files[j].seq })
+	if len(name) > 0 && (len(files) == 0 || files[0].name != name) {
+		return nil, errors.Errorf("binlog file %s not found in %s", name, dir)
+	}
+
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.name)
+	}
+	return names, nil
+}
+
 func (p *BinlogParser) parseFormatDescriptionEvent(r io.Reader, onEvent OnEventFunc) error {
 	_, err := p.parseSingleEvent(r, onEvent)
 	return err
@@ -141,6 +266,7 @@ func (p *BinlogParser) parseSingleEvent(r io.Reader, onEvent OnEventFunc) (bool,
 	if buf.Len() != int(h.EventSize) {
 		return false, errors.Errorf("invalid raw data size in event %s, need %d but got %d", h.EventType, h.EventSize, buf.Len())
 	}
+	p.position.Pos += h.EventSize
 
 	var rawData []byte
 	rawData = append(rawData, buf.Bytes()...)
@@ -159,6 +285,10 @@ func (p *BinlogParser) parseSingleEvent(r io.Reader, onEvent OnEventFunc) (bool,
 		return false, errors.Trace(err)
 	}
 
+	if p.filter != nil && p.filter.filters(h, e) {
+		return false, nil
+	}
+
 	if err = onEvent(&BinlogEvent{RawData: rawData, Header: h, Event: e}); err != nil {
 		return false, errors.Trace(err)
 	}
@@ -204,14 +334,42 @@ func (p *BinlogParser) SetUseDecimal(useDecimal bool) {
 	p.useDecimal = useDecimal
 }
 
+// SetRowsDecodeOptions chooses the Go types of the integer, DECIMAL, BIT, ENUM and SET values of the rows events.
+func (p *BinlogParser) SetRowsDecodeOptions(opts RowsDecodeOptions) {
+	p.rowsDecodeOptions = opts
+}
+
 func (p *BinlogParser) SetIgnoreJSONDecodeError(ignoreJSONDecodeErr bool) {
 	p.ignoreJSONDecodeErr = ignoreJSONDecodeErr
 }
 
+// SetApplyPartialJSON makes the partial updates of the JSON columns (binlog_row_value_options=PARTIAL_JSON) be
+// decoded as the updated documents, applying their diffs to the documents of the before images. Otherwise, or if the
+// before image doesn't have the document (binlog_row_image=MINIMAL), they are decoded as *JsonDiff, see
+// RowsEvent.JsonDiffs.
+func (p *BinlogParser) SetApplyPartialJSON(apply bool) {
+	p.applyPartialJSON = apply
+}
+
+// SetVerifyChecksum verifies the CRC32 checksums of the events, Parse fails with ErrChecksumMismatch on a mismatch
+// unless the function set by SetChecksumMismatchFunc ignores it.
 func (p *BinlogParser) SetVerifyChecksum(verify bool) {
 	p.verifyChecksum = verify
 }
 
+// SetChecksumMismatchFunc sets the function called with the header of an event the checksum of which does not
+// match and ErrChecksumMismatch. The event is parsed as usual if it returns nil, e.g. once the mismatch is logged,
+// Parse fails with the error returned otherwise.
+func (p *BinlogParser) SetChecksumMismatchFunc(f func(h *EventHeader, err error) error) {
+	p.checksumMismatchFunc = f
+}
+
+// SetEventFilter filters the events parsed, the ones filtered out are not decoded, see EventFilter. The rows
+// events are decoded by the function set by SetRowsEventDecodeFunc, if any, whatever their table.
+func (p *BinlogParser) SetEventFilter(filter *EventFilter) {
+	p.filter = filter
+}
+
 func (p *BinlogParser) SetFlavor(flavor string) {
 	p.flavor = flavor
 }
@@ -241,9 +399,8 @@ func (p *BinlogParser) parseEvent(h *EventHeader, data []byte, rawData []byte) (
 		p.format = &FormatDescriptionEvent{}
 		e = p.format
 	} else {
-		if p.format != nil && p.format.ChecksumAlgorithm == BINLOG_CHECKSUM_ALG_CRC32 {
-			err := p.verifyCrc32Checksum(rawData)
-			if err != nil {
+		if p.format != nil && hasChecksum(p.format.ChecksumAlgorithm) {
+			if err := p.verifyEventChecksum(h, rawData); err != nil {
 				return nil, err
 			}
 			data = data[0 : len(data)-BinlogChecksumLength]
@@ -251,6 +408,8 @@ func (p *BinlogParser) parseEvent(h *EventHeader, data []byte, rawData []byte) (
 
 		if h.EventType == ROTATE_EVENT {
 			e = &RotateEvent{}
+		} else if p.filter != nil && !p.filter.decodes(h.EventType) {
+			e = &GenericEvent{}
 		} else if !p.rawMode {
 			switch h.EventType {
 			case QUERY_EVENT:
@@ -284,6 +443,9 @@ func (p *BinlogParser) parseEvent(h *EventHeader, data []byte, rawData []byte) (
 				MARIADB_WRITE_ROWS_COMPRESSED_EVENT_V1,
 				MARIADB_UPDATE_ROWS_COMPRESSED_EVENT_V1,
 				MARIADB_DELETE_ROWS_COMPRESSED_EVENT_V1,
+				MARIADB_WRITE_ROWS_COMPRESSED_EVENT,
+				MARIADB_UPDATE_ROWS_COMPRESSED_EVENT,
+				MARIADB_DELETE_ROWS_COMPRESSED_EVENT,
 				PARTIAL_UPDATE_ROWS_EVENT: // Extension of UPDATE_ROWS_EVENT, allowing partial values according to binlog_row_value_options
 
 				e = p.newRowsEvent(h)
@@ -324,6 +486,20 @@ func (p *BinlogParser) parseEvent(h *EventHeader, data []byte, rawData []byte) (
 	var err error
 	if re, ok := e.(*RowsEvent); ok && p.rowsEventDecodeFunc != nil {
 		err = p.rowsEventDecodeFunc(re, data)
+	} else if ok && (p.deferRows || p.filter != nil) {
+		var pos int
+		if pos, err = re.DecodeHeader(data); err == nil && (re.Table == nil || !re.Table.filtered) {
+			if p.deferRows {
+				p.decodeRows = func() error {
+					if err := re.DecodeData(pos, data); err != nil {
+						return &EventError{h, err.Error(), data}
+					}
+					return nil
+				}
+			} else {
+				err = re.DecodeData(pos, data)
+			}
+		}
 	} else {
 		err = e.Decode(data)
 	}
@@ -331,15 +507,36 @@ func (p *BinlogParser) parseEvent(h *EventHeader, data []byte, rawData []byte) (
 		return nil, &EventError{h, err.Error(), data}
 	}
 
+	// the format description event has the checksum of the algorithm it describes
+	if e == p.format && hasChecksum(p.format.ChecksumAlgorithm) {
+		if err := p.verifyEventChecksum(h, rawData); err != nil {
+			return nil, err
+		}
+	}
+
 	if te, ok := e.(*TableMapEvent); ok {
+		if p.filter != nil {
+			te.filtered = p.filter.excludesTable(string(te.Schema), string(te.Table))
+		}
 		p.tables[te.TableID] = te
 	}
 
-	if re, ok := e.(*RowsEvent); ok {
-		if (re.Flags & RowsEventStmtEndFlag) > 0 {
+	switch e := e.(type) {
+	case *RowsEvent:
+		if (e.Flags & RowsEventStmtEndFlag) > 0 {
 			// Refer https://github.com/alibaba/canal/blob/38cc81b7dab29b51371096fb6763ca3a8432ffee/dbsync/src/main/java/com/taobao/tddl/dbsync/binlog/event/RowsLogEvent.java#L176
 			p.tables = make(map[uint64]*TableMapEvent)
+			p.rowsQuery = nil
 		}
+	case *RowsQueryEvent:
+		p.rowsQuery = e.Query
+	case *MariadbAnnotateRowsEvent:
+		p.rowsQuery = e.Query
+	case *QueryEvent:
+		p.threadID = e.SlaveProxyID
+	case *GTIDEvent, *MariadbGTIDEvent, *XIDEvent:
+		p.rowsQuery = nil
+		p.threadID = 0
 	}
 
 	return e, nil
@@ -372,14 +569,44 @@ func (p *BinlogParser) Parse(data []byte) (*BinlogEvent, error) {
 		return nil, err
 	}
 
-	return &BinlogEvent{RawData: rawData, Header: h, Event: e}, nil
+	return &BinlogEvent{RawData: rawData, Header: h, Event: e, filtered: p.filter != nil && p.filter.filters(h, e)}, nil
 }
 
-func (p *BinlogParser) verifyCrc32Checksum(rawData []byte) error {
-	if !p.verifyChecksum {
+// parseDeferringRows parses an event as Parse but only decodes the header of a rows event, the table of which is
+// resolved, and returns the function decoding its rows. It can be called while the next events are parsed.
+func (p *BinlogParser) parseDeferringRows(data []byte) (*BinlogEvent, func() error, error) {
+	p.deferRows = true
+	defer func() {
+		p.deferRows = false
+		p.decodeRows = nil
+	}()
+
+	e, err := p.Parse(data)
+	return e, p.decodeRows, err
+}
+
+// hasChecksum tells whether the events of a binlog have a checksum with the checksum algorithm of its format
+// description event, the algorithms but CRC32 have no implementation yet but their checksum has the same length.
+func hasChecksum(alg byte) bool {
+	return alg != BINLOG_CHECKSUM_ALG_OFF && alg != BINLOG_CHECKSUM_ALG_UNDEF
+}
+
+func (p *BinlogParser) verifyEventChecksum(h *EventHeader, rawData []byte) error {
+	if len(rawData) < EventHeaderSize+BinlogChecksumLength {
+		return errors.Errorf("invalid %s length %d, no checksum", h.EventType, len(rawData))
+	}
+	if !p.verifyChecksum || p.format.ChecksumAlgorithm != BINLOG_CHECKSUM_ALG_CRC32 {
 		return nil
 	}
 
+	err := p.verifyCrc32Checksum(rawData)
+	if err != nil && p.checksumMismatchFunc != nil {
+		err = p.checksumMismatchFunc(h, err)
+	}
+	return err
+}
+
+func (p *BinlogParser) verifyCrc32Checksum(rawData []byte) error {
 	calculatedPart := rawData[0 : len(rawData)-BinlogChecksumLength]
 	expectedChecksum := rawData[len(rawData)-BinlogChecksumLength:]
 
@@ -412,6 +639,10 @@ func (p *BinlogParser) newRowsEvent(h *EventHeader) *RowsEvent {
 	e.timestampStringLocation = p.timestampStringLocation
 	e.useDecimal = p.useDecimal
 	e.ignoreJSONDecodeErr = p.ignoreJSONDecodeErr
+	e.applyPartialJSON = p.applyPartialJSON
+	e.decodeOptions = p.rowsDecodeOptions
+	e.Query = p.rowsQuery
+	e.ThreadID = p.threadID
 
 	switch h.EventType {
 	case WRITE_ROWS_EVENTv0:
@@ -437,6 +668,16 @@ func (p *BinlogParser) newRowsEvent(h *EventHeader) *RowsEvent {
 		e.Version = 1
 		e.compressed = true
 		e.needBitmap2 = true
+	case MARIADB_WRITE_ROWS_COMPRESSED_EVENT:
+		e.Version = 2
+		e.compressed = true
+	case MARIADB_DELETE_ROWS_COMPRESSED_EVENT:
+		e.Version = 2
+		e.compressed = true
+	case MARIADB_UPDATE_ROWS_COMPRESSED_EVENT:
+		e.Version = 2
+		e.compressed = true
+		e.needBitmap2 = true
 	case WRITE_ROWS_EVENTv2:
 		e.Version = 2
 	case UPDATE_ROWS_EVENTv2:
@@ -455,6 +696,19 @@ func (p *BinlogParser) newRowsEvent(h *EventHeader) *RowsEvent {
 func (p *BinlogParser) newTransactionPayloadEvent() *TransactionPayloadEvent {
 	e := &TransactionPayloadEvent{}
 	e.format = *p.format
+	e.parser = &BinlogParser{
+		flavor:                         p.flavor,
+		tables:                         make(map[uint64]*TableMapEvent),
+		parseTime:                      p.parseTime,
+		timestampStringLocation:        p.timestampStringLocation,
+		useDecimal:                     p.useDecimal,
+		ignoreJSONDecodeErr:            p.ignoreJSONDecodeErr,
+		applyPartialJSON:               p.applyPartialJSON,
+		rowsDecodeOptions:              p.rowsDecodeOptions,
+		filter:                         p.filter,
+		rowsEventDecodeFunc:            p.rowsEventDecodeFunc,
+		tableMapOptionalMetaDecodeFunc: p.tableMapOptionalMetaDecodeFunc,
+	}
 
 	return e
 }
@@ -2,9 +2,15 @@ package replication
 
 import (
 	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"os"
+	"path"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
 )
 
 func TestIndexOutOfRange(t *testing.T) {
@@ -140,3 +146,199 @@ func TestRowsEventDecodeImageWithEmptyJSON(t *testing.T) {
 	require.Equal(t, []byte{}, row[4]) // empty json
 	require.Equal(t, int32(4404), row[7])
 }
+
+func TestMariadbCompressedRowsEvent(t *testing.T) {
+	parser := NewBinlogParser()
+	parser.SetFlavor(mysql.MariaDBFlavor)
+	parser.format = &FormatDescriptionEvent{
+		Version:                4,
+		EventHeaderLength:      EventHeaderSize,
+		EventTypeHeaderLengths: make([]byte, MARIADB_DELETE_ROWS_COMPRESSED_EVENT),
+	}
+	parser.format.EventTypeHeaderLengths[MARIADB_WRITE_ROWS_COMPRESSED_EVENT-1] = 10
+	parser.tables[1] = &TableMapEvent{
+		tableIDSize: 6,
+		TableID:     1,
+		Schema:      []byte("db"),
+		Table:       []byte("tbl"),
+		ColumnCount: 1,
+		ColumnType:  []byte{mysql.MYSQL_TYPE_LONG},
+		ColumnMeta:  []uint16{0},
+		NullBitmap:  []byte{0},
+	}
+
+	// the row (1), without NULL
+	var rows bytes.Buffer
+	w := zlib.NewWriter(&rows)
+	_, err := w.Write([]byte{0x00, 0x01, 0x00, 0x00, 0x00})
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	data := make([]byte, EventHeaderSize)
+	data[4] = byte(MARIADB_WRITE_ROWS_COMPRESSED_EVENT)
+	// table id, flags, extra data length, column count and columns present
+	data = append(data, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x02, 0x00, 0x01, 0xff)
+	// the 1-byte uncompressed size of the rows and the rows compressed
+	data = append(data, 0x81, 0x05)
+	data = append(data, rows.Bytes()...)
+	binary.LittleEndian.PutUint32(data[9:], uint32(len(data)))
+
+	e, err := parser.Parse(data)
+	require.NoError(t, err)
+	require.Equal(t, "MariadbWriteRowsCompressedEvent", e.Header.EventType.String())
+	re := e.Event.(*RowsEvent)
+	require.Equal(t, []byte("tbl"), re.Table.Table)
+	require.Equal(t, [][]interface{}{{int32(1)}}, re.Rows)
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	corrupt := func(data []byte) []byte {
+		data = append([]byte(nil), data...)
+		data[len(data)-1] ^= 0xff
+		return data
+	}
+
+	p := NewBinlogParser()
+	p.SetVerifyChecksum(true)
+	_, err := p.Parse(corrupt(mysql57Events[0]))
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+	_, err = p.Parse(mysql57Events[0])
+	require.NoError(t, err)
+	_, err = p.Parse(corrupt(mysql57Events[1]))
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+
+	var mismatches []EventType
+	p.SetChecksumMismatchFunc(func(h *EventHeader, err error) error {
+		mismatches = append(mismatches, h.EventType)
+		return nil
+	})
+	e, err := p.Parse(corrupt(mysql57Events[1]))
+	require.NoError(t, err)
+	require.Equal(t, []byte("tbl"), e.Event.(*TableMapEvent).Table)
+	require.Equal(t, []EventType{TABLE_MAP_EVENT}, mismatches)
+
+	// the checksums of an unknown algorithm are stripped but not verified
+	fde := append([]byte(nil), mysql57Events[0]...)
+	fde[len(fde)-5] = 2
+	p = NewBinlogParser()
+	p.SetVerifyChecksum(true)
+	_, err = p.Parse(fde)
+	require.NoError(t, err)
+	e, err = p.Parse(corrupt(mysql57Events[1]))
+	require.NoError(t, err)
+	require.Equal(t, []byte("tbl"), e.Event.(*TableMapEvent).Table)
+}
+
+func TestParseDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name string, events ...[]byte) {
+		data := append([]byte{}, BinLogFileHeader...)
+		for _, e := range events {
+			data = append(data, e...)
+		}
+		require.NoError(t, os.WriteFile(path.Join(dir, name), data, 0644))
+	}
+	writeFile("mysql-bin.000001", mysql57Events[:3]...)
+	writeFile("mysql-bin.000002", mysql57Events[0], mysql57Events[3])
+	require.NoError(t, os.WriteFile(path.Join(dir, "mysql-bin.index"), []byte("./mysql-bin.000001\n./mysql-bin.000002\n"), 0644))
+
+	type parsed struct {
+		eventType EventType
+		pos       mysql.Position
+	}
+	parse := func(p *BinlogParser, pos mysql.Position, stopAt EventType) ([]parsed, error) {
+		var events []parsed
+		err := p.ParseDir(dir, pos, func(e *BinlogEvent) error {
+			events = append(events, parsed{e.Header.EventType, p.Position()})
+			if e.Header.EventType == stopAt {
+				p.Stop()
+			}
+			return nil
+		})
+		return events, err
+	}
+
+	p := NewBinlogParser()
+	events, err := parse(p, mysql.Position{}, UNKNOWN_EVENT)
+	require.NoError(t, err)
+	require.Equal(t, []parsed{
+		{FORMAT_DESCRIPTION_EVENT, mysql.Position{Name: "mysql-bin.000001", Pos: 0x7b}},
+		{TABLE_MAP_EVENT, mysql.Position{Name: "mysql-bin.000001", Pos: 0xa7}},
+		{WRITE_ROWS_EVENTv2, mysql.Position{Name: "mysql-bin.000001", Pos: 0xcf}},
+		{FORMAT_DESCRIPTION_EVENT, mysql.Position{Name: "mysql-bin.000002", Pos: 0x7b}},
+		{TABLE_MAP_EVENT, mysql.Position{Name: "mysql-bin.000002", Pos: 0xa9}},
+	}, events)
+
+	// resume from the position of the event the parsing is stopped at
+	p = NewBinlogParser()
+	events, err = parse(p, mysql.Position{Name: "mysql-bin.000001", Pos: 4}, TABLE_MAP_EVENT)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000001", Pos: 0xa7}, p.Position())
+
+	p.Resume()
+	events, err = parse(p, p.Position(), UNKNOWN_EVENT)
+	require.NoError(t, err)
+	require.Equal(t, []parsed{
+		{FORMAT_DESCRIPTION_EVENT, mysql.Position{Name: "mysql-bin.000001", Pos: 0xa7}},
+		{WRITE_ROWS_EVENTv2, mysql.Position{Name: "mysql-bin.000001", Pos: 0xcf}},
+		{FORMAT_DESCRIPTION_EVENT, mysql.Position{Name: "mysql-bin.000002", Pos: 0x7b}},
+		{TABLE_MAP_EVENT, mysql.Position{Name: "mysql-bin.000002", Pos: 0xa9}},
+	}, events)
+
+	events, err = parse(NewBinlogParser(), mysql.Position{Name: "mysql-bin.000002", Pos: 4}, UNKNOWN_EVENT)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	_, err = parse(NewBinlogParser(), mysql.Position{Name: "mysql-bin.000003", Pos: 4}, UNKNOWN_EVENT)
+	require.ErrorContains(t, err, "not found")
+
+	// the relay log files must be told apart from the binlog files
+	writeFile("relay-bin.000001", mysql57Events[:2]...)
+	_, err = parse(NewBinlogParser(), mysql.Position{}, UNKNOWN_EVENT)
+	require.ErrorContains(t, err, "the file name must be set")
+	events, err = parse(NewBinlogParser(), mysql.Position{Name: "relay-bin.000001", Pos: 4}, UNKNOWN_EVENT)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+}
+
+func TestRowsEventQuery(t *testing.T) {
+	// an event of the binlog of mysql57Events, its checksum is not verified
+	event := func(eventType EventType, body []byte) []byte {
+		data := make([]byte, EventHeaderSize, EventHeaderSize+len(body)+BinlogChecksumLength)
+		data[4] = byte(eventType)
+		binary.LittleEndian.PutUint32(data[5:], 11)
+		binary.LittleEndian.PutUint32(data[9:], uint32(cap(data)))
+		data = append(data, body...)
+		return append(data, 0, 0, 0, 0)
+	}
+	// thread id 42, the BEGIN statement
+	begin := event(QUERY_EVENT, append([]byte{42, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 'd', 'b', 0}, "BEGIN"...))
+	rowsQuery := event(ROWS_QUERY_EVENT, append([]byte{26}, "INSERT INTO tbl VALUES (1)"...))
+	xid := event(XID_EVENT, []byte{1, 0, 0, 0, 0, 0, 0, 0})
+
+	p := NewBinlogParser()
+	parse := func(events ...[]byte) *RowsEvent {
+		var e *BinlogEvent
+		var err error
+		for _, data := range events {
+			e, err = p.Parse(data)
+			require.NoError(t, err)
+		}
+		return e.Event.(*RowsEvent)
+	}
+
+	rows := parse(mysql57Events[0], begin, rowsQuery, mysql57Events[1], mysql57Events[2])
+	require.Equal(t, []byte("INSERT INTO tbl VALUES (1)"), rows.Query)
+	require.Equal(t, uint32(42), rows.ThreadID)
+	require.Equal(t, [][]interface{}{{int32(1)}}, rows.Rows)
+
+	// the statement ended with the rows event
+	rows = parse(mysql57Events[1], mysql57Events[2])
+	require.Nil(t, rows.Query)
+	require.Equal(t, uint32(42), rows.ThreadID)
+
+	rows = parse(xid, mysql57Events[1], mysql57Events[2])
+	require.Nil(t, rows.Query)
+	require.Zero(t, rows.ThreadID)
+}
@@ -0,0 +1,229 @@
+package replication
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pingcap/errors"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// parsePipeline parses the packets read by BinlogSyncer.onStream in a goroutine and sends the events to the
+// streamer from another one, see BinlogSyncerConfig.ParseWorkers. The rows of the row events are decoded by a pool
+// of workers, the events of a table by the same worker, and the events are sent in the order they were read.
+type parsePipeline struct {
+	b *BinlogSyncer
+	s *BinlogStreamer
+
+	packets chan pipelinePacket
+	events  chan *pipelineEvent
+	workers []chan func()
+
+	failOnce sync.Once
+	// closed once an event failed to be parsed or sent, err is the error
+	failed chan struct{}
+	err    error
+	// closed once the events are all sent
+	finished chan struct{}
+}
+
+// pipelinePacket is a packet pushed to the pipeline, or a barrier closing parsed once the previous packets are
+// parsed if data is nil.
+type pipelinePacket struct {
+	data []byte
+	// closed once the event is sent, for the events acknowledged to a semi-sync master
+	delivered chan struct{}
+	parsed    chan struct{}
+}
+
+// pipelineEvent is a parsed event, waiting for its rows to be decoded if decoded is set.
+type pipelineEvent struct {
+	e *BinlogEvent
+	// the events sent to the streamer, see BinlogSyncer.streamEvents
+	events    []*BinlogEvent
+	err       error
+	decoded   chan error
+	delivered chan struct{}
+}
+
+func newParsePipeline(b *BinlogSyncer, s *BinlogStreamer) *parsePipeline {
+	size := b.cfg.ParseQueueSize
+	p := &parsePipeline{
+		b:        b,
+		s:        s,
+		packets:  make(chan pipelinePacket, size),
+		events:   make(chan *pipelineEvent, size),
+		workers:  make([]chan func(), b.cfg.ParseWorkers),
+		failed:   make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	for i := range p.workers {
+		p.workers[i] = make(chan func(), size)
+		go func(decodes chan func()) {
+			for decode := range decodes {
+				decode()
+			}
+		}(p.workers[i])
+	}
+
+	go p.parse()
+	go p.deliver()
+	return p
+}
+
+// push queues a packet of the binlog stream. It returns once the event is sent and acknowledged if the master
+// waits for a semi-sync ACK, unless SemiSyncManualAck is set, or the error which stopped the pipeline.
+func (p *parsePipeline) push(data []byte) error {
+	data, needACK := p.b.stripStreamHeader(data)
+	if needACK && p.b.cfg.SemiSyncManualAck {
+		p.b.skipSemiSyncACK()
+		needACK = false
+	}
+
+	pkt := pipelinePacket{data: data}
+	if needACK {
+		pkt.delivered = make(chan struct{})
+	}
+	select {
+	case p.packets <- pkt:
+	case <-p.failed:
+		return p.err
+	}
+
+	if !needACK {
+		return nil
+	}
+	select {
+	case <-pkt.delivered:
+	case <-p.failed:
+		return p.err
+	}
+	// the next packets are not read yet, nextPos is the position of the event
+	return errors.Trace(p.b.replySemiSyncACK(p.b.nextPos))
+}
+
+// sync waits for the packets pushed to be parsed, so that the position and the GTID sets of the syncer are the ones
+// of the last packet, and the parser can be reset.
+func (p *parsePipeline) sync() error {
+	parsed := make(chan struct{})
+	select {
+	case p.packets <- pipelinePacket{parsed: parsed}:
+	case <-p.failed:
+		return p.err
+	}
+
+	select {
+	case <-parsed:
+		return nil
+	case <-p.failed:
+		return p.err
+	}
+}
+
+// close stops the pipeline once the events pushed are sent, and closes the streamer with err unless an event failed.
+func (p *parsePipeline) close(err error) {
+	close(p.packets)
+	<-p.finished
+	p.fail(err)
+}
+
+func (p *parsePipeline) fail(err error) {
+	p.failOnce.Do(func() {
+		p.err = err
+		close(p.failed)
+		p.s.closeWithError(err)
+	})
+}
+
+func (p *parsePipeline) isFailed() bool {
+	select {
+	case <-p.failed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *parsePipeline) parse() {
+	defer func() {
+		for _, decodes := range p.workers {
+			close(decodes)
+		}
+		close(p.events)
+	}()
+	defer func() {
+		if e := recover(); e != nil {
+			p.fail(fmt.Errorf("Err: %v\n Stack: %s", e, Pstack()))
+			// let close return
+			for range p.packets {
+			}
+		}
+	}()
+
+	for pkt := range p.packets {
+		if pkt.parsed != nil {
+			close(pkt.parsed)
+			continue
+		}
+		if p.isFailed() {
+			continue
+		}
+
+		ev := &pipelineEvent{delivered: pkt.delivered}
+		var decode func() error
+		ev.e, decode, ev.err = p.b.parser.parseDeferringRows(pkt.data)
+		if ev.err == nil {
+			for _, e := range p.b.streamEvents(ev.e) {
+				resent := p.b.isResent(e)
+				if ev.err = p.b.trackEvent(e); ev.err != nil {
+					break
+				}
+				if !resent && !e.filtered {
+					ev.events = append(ev.events, e)
+				}
+			}
+		}
+		if ev.err == nil && decode != nil {
+			ev.decoded = make(chan error, 1)
+			tableID := ev.e.Event.(*RowsEvent).TableID
+			p.workers[tableID%uint64(len(p.workers))] <- func() {
+				ev.decoded <- decode()
+			}
+		}
+		p.events <- ev
+	}
+}
+
+func (p *parsePipeline) deliver() {
+	defer close(p.finished)
+
+	for ev := range p.events {
+		if p.isFailed() {
+			continue
+		}
+
+		err := ev.err
+		if err == nil && ev.decoded != nil {
+			err = <-ev.decoded
+		}
+		if err != nil {
+			p.fail(errors.Trace(err))
+			continue
+		}
+
+		for _, e := range ev.events {
+			select {
+			case p.s.ch <- e:
+			case <-p.b.ctx.Done():
+				p.fail(errors.New("sync is been closing..."))
+			}
+			if p.isFailed() {
+				break
+			}
+		}
+		if ev.delivered != nil && !p.isFailed() {
+			close(ev.delivered)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package replication
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+// mysql57Events are the events of a MySQL 5.7 binlog, with CRC32 checksums
+var mysql57Events = [][]byte{
+	// FORMAT_DESCRIPTION_EVENT
+	{0x64, 0x61, 0x72, 0x63, 0xf, 0xb, 0x0, 0x0, 0x0, 0x77, 0x0, 0x0, 0x0, 0x7b, 0x0, 0x0, 0x0, 0x1, 0x0, 0x4, 0x0, 0x35, 0x2e, 0x37, 0x2e, 0x32, 0x32, 0x2d, 0x6c, 0x6f, 0x67, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x64, 0x61, 0x72, 0x63, 0x13, 0x38, 0xd, 0x0, 0x8, 0x0, 0x12, 0x0, 0x4, 0x4, 0x4, 0x4, 0x12, 0x0, 0x0, 0x5f, 0x0, 0x4, 0x1a, 0x8, 0x0, 0x0, 0x0, 0x8, 0x8, 0x8, 0x2, 0x0, 0x0, 0x0, 0xa, 0xa, 0xa, 0x2a, 0x2a, 0x0, 0x12, 0x34, 0x0, 0x1, 0xb8, 0x78, 0x9d, 0xfe},
+	// TABLE MAP EVENT tb(INT)
+	{0x8d, 0x61, 0x72, 0x63, 0x13, 0xb, 0x0, 0x0, 0x0, 0x2c, 0x0, 0x0, 0x0, 0xa7, 0x0, 0x0, 0x0, 0x1, 0x0, 0x6c, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x2, 0x64, 0x62, 0x0, 0x3, 0x74, 0x62, 0x6c, 0x0, 0x1, 0x3, 0x0, 0x0, 0x63, 0x17, 0xe6, 0xf0},
+	// rows INT(1)
+	{0xb6, 0x61, 0x72, 0x63, 0x1e, 0xb, 0x0, 0x0, 0x0, 0x28, 0x0, 0x0, 0x0, 0xcf, 0x0, 0x0, 0x0, 0x1, 0x0, 0x6c, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x2, 0x0, 0x1, 0xff, 0x0, 0x1, 0x0, 0x0, 0x0, 0xf9, 0xf7, 0x89, 0x2a},
+	// TABLE MAP EVENT tb(TINY)
+	{0x22, 0x6c, 0x72, 0x63, 0x13, 0xb, 0x0, 0x0, 0x0, 0x2e, 0x0, 0x0, 0x0, 0xfd, 0x0, 0x0, 0x0, 0x1, 0x0, 0x76, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x3, 0x64, 0x62, 0x31, 0x0, 0x4, 0x74, 0x62, 0x6c, 0x31, 0x0, 0x1, 0x1, 0x0, 0x0, 0x32, 0xec, 0x2f, 0x4},
+}
+
+func TestParsePipeline(t *testing.T) {
+	events := mysql57Events
+	// rows LONG(1), not matching its table
+	invalid := []byte{0xeb, 0x64, 0x72, 0x63, 0x1e, 0xb, 0x0, 0x0, 0x0, 0x2d, 0x0, 0x0, 0x0, 0x2a, 0x1, 0x0, 0x0, 0x1, 0x0, 0x76, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x2, 0x0, 0x1, 0xff, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x6e, 0xef, 0xb2, 0xb1}
+
+	run := func(packets [][]byte) (*BinlogSyncer, []*BinlogEvent, error) {
+		b := NewBinlogSyncer(BinlogSyncerConfig{ServerID: 100, ParseWorkers: 2, ParseQueueSize: 1})
+		s := NewBinlogStreamer()
+		p := newParsePipeline(b, s)
+		for _, data := range packets {
+			if err := p.push(append([]byte{mysql.OK_HEADER}, data...)); err != nil {
+				break
+			}
+		}
+		p.close(nil)
+
+		parsed := s.DumpEvents()
+		_, err := s.GetEvent(context.Background())
+		return b, parsed, err
+	}
+
+	b, parsed, err := run(events)
+	require.ErrorIs(t, err, ErrSyncClosed)
+	require.Len(t, parsed, len(events))
+	for i, e := range parsed {
+		require.Equal(t, events[i], e.RawData)
+	}
+	rows := parsed[2].Event.(*RowsEvent)
+	require.Equal(t, []byte("tbl"), rows.Table.Table)
+	require.Equal(t, [][]interface{}{{int32(1)}}, rows.Rows)
+	require.Equal(t, uint32(0xfd), b.GetNextPosition().Pos)
+
+	_, parsed, err = run(append(events, invalid, events[1]))
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrSyncClosed)
+	require.Len(t, parsed, len(events))
+}
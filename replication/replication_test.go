@@ -403,6 +403,53 @@ func (t *testSyncerSuite) TestMysqlSemiPositionSync() {
 	t.testPositionSync()
 }
 
+func (t *testSyncerSuite) TestMysqlSyncFromTimestamp() {
+	t.setupTest(mysql.MySQLFlavor)
+
+	t.testExecute("CREATE TABLE IF NOT EXISTS test_timestamp (id INT)")
+	t.testExecute("FLUSH LOGS")
+
+	r, err := t.c.Execute("SHOW MASTER STATUS")
+	require.NoError(t.T(), err)
+	binFile, _ := r.GetString(0, 0)
+	binPos, _ := r.GetInt(0, 1)
+
+	// the binlog events have the time to the second
+	start := time.Now().Truncate(time.Second).Add(time.Second)
+	time.Sleep(time.Until(start))
+	t.testExecute("INSERT INTO test_timestamp VALUES (1)")
+	t.testExecute("INSERT INTO test_timestamp VALUES (2)")
+
+	pos, err := t.b.FindPositionByTimestamp(start)
+	require.NoError(t.T(), err)
+	require.Equal(t.T(), mysql.Position{Name: binFile, Pos: uint32(binPos)}, pos)
+
+	pos, err = t.b.FindPositionByTimestamp(time.Unix(0, 0))
+	require.NoError(t.T(), err)
+	require.Equal(t.T(), uint32(4), pos.Pos)
+
+	r, err = t.c.Execute("SHOW MASTER STATUS")
+	require.NoError(t.T(), err)
+	binPos, _ = r.GetInt(0, 1)
+	pos, err = t.b.FindPositionByTimestamp(start.Add(time.Hour))
+	require.NoError(t.T(), err)
+	require.Equal(t.T(), mysql.Position{Name: binFile, Pos: uint32(binPos)}, pos)
+
+	s, err := t.b.StartSyncFromTimestamp(start)
+	require.NoError(t.T(), err)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		e, err := s.GetEvent(ctx)
+		cancel()
+		require.NoError(t.T(), err)
+		if rows, ok := e.Event.(*RowsEvent); ok {
+			require.Equal(t.T(), []byte("test_timestamp"), rows.Table.Table)
+			require.Equal(t.T(), int32(1), rows.Rows[0][0])
+			break
+		}
+	}
+}
+
 func (t *testSyncerSuite) TestMysqlBinlogCodec() {
 	t.setupTest(mysql.MySQLFlavor)
 
@@ -5,12 +5,9 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/pingcap/errors"
-	"github.com/shopspring/decimal"
 	"github.com/siddontang/go/hack"
 
 	. "github.com/atoonk/go-mysql/mysql"
@@ -86,6 +83,9 @@ type TableMapEvent struct {
 	VisibilityBitmap []byte
 
 	optionalMetaDecodeFunc func(data []byte) (err error)
+
+	// set if the rows events of the table are filtered out, see EventFilter
+	filtered bool
 }
 
 func (e *TableMapEvent) Decode(data []byte) error {
@@ -258,6 +258,9 @@ func (e *TableMapEvent) decodeOptionalMeta(data []byte) (err error) {
 
 		l, _, n := LengthEncodedInt(data[pos:])
 		pos += n
+		if n == 0 || uint64(len(data)-pos) < l {
+			return errors.Errorf("invalid optional metadata %d length %d, %d bytes left", t, l, len(data)-pos)
+		}
 
 		v := data[pos : pos+int(l)]
 		pos += int(l)
@@ -361,6 +364,9 @@ func (e *TableMapEvent) decodeColumnNames(v []byte) error {
 	for p < len(v) {
 		n := int(v[p])
 		p++
+		if p+n > len(v) {
+			return errors.Errorf("invalid column name length %d, %d bytes left", n, len(v)-p)
+		}
 		e.ColumnName = append(e.ColumnName, v[p:p+n])
 		p += n
 	}
@@ -863,6 +869,107 @@ func (e *TableMapEvent) JsonColumnCount() uint64 {
 	return count
 }
 
+// TableColumn is the metadata of a column of a table, see TableMapEvent.Columns.
+type TableColumn struct {
+	// Name is empty if the column names are not available
+	Name string
+	// Type is the real type of the column, e.g. MYSQL_TYPE_ENUM rather than MYSQL_TYPE_STRING
+	Type byte
+	Meta uint16
+
+	Nullable bool
+	// Unsigned is set for the unsigned numeric columns
+	Unsigned bool
+	// Collation is the collation id of the character, enum and set columns, 0 if not available
+	Collation uint64
+	// EnumValues and SetValues are the values of the enum and set columns
+	EnumValues []string
+	SetValues  []string
+	// GeometryType is the type of the geometry columns, e.g. 1 for POINT
+	GeometryType uint64
+	// Invisible is set for the invisible columns of MySQL 8.0.23+
+	Invisible bool
+
+	// PrimaryKey is set for the columns of the primary key, PrimaryKeyPrefix is the length of the prefix of the
+	// column in the key, 0 for the whole column
+	PrimaryKey       bool
+	PrimaryKeyPrefix uint64
+}
+
+// Columns returns the metadata of the columns of the table. Since MySQL 8.0.1 and MariaDB 10.5, the signedness, the
+// collations and the geometry types are available with binlog_row_metadata=MINIMAL, the names, the enum and set
+// values, the primary key and the visibility with binlog_row_metadata=FULL.
+func (e *TableMapEvent) Columns() []TableColumn {
+	columns := make([]TableColumn, e.ColumnCount)
+
+	names := e.ColumnNameString()
+	unsigned := e.UnsignedMap()
+	collations := e.CollationMap()
+	enumSetCollations := e.EnumSetCollationMap()
+	enumValues := e.EnumStrValueMap()
+	setValues := e.SetStrValueMap()
+	geometryTypes := e.GeometryTypeMap()
+	visibility := e.VisibilityMap()
+	for i := range columns {
+		c := &columns[i]
+		if i < len(names) {
+			c.Name = names[i]
+		}
+		c.Type = e.realType(i)
+		c.Meta = e.ColumnMeta[i]
+		_, c.Nullable = e.Nullable(i)
+		c.Unsigned = unsigned[i]
+		c.Collation = collations[i]
+		if collation, ok := enumSetCollations[i]; ok {
+			c.Collation = collation
+		}
+		c.EnumValues = enumValues[i]
+		c.SetValues = setValues[i]
+		c.GeometryType = geometryTypes[i]
+		if visible, ok := visibility[i]; ok {
+			c.Invisible = !visible
+		}
+	}
+
+	for i, column := range e.PrimaryKey {
+		if column < e.ColumnCount {
+			columns[column].PrimaryKey = true
+			columns[column].PrimaryKeyPrefix = e.PrimaryKeyPrefix[i]
+		}
+	}
+
+	return columns
+}
+
+// RowsDecodeOptions choose other Go types for some values of the rows events than the ones listed on RowsEvent, see
+// BinlogParser.SetRowsDecodeOptions. The DECIMAL values are decoded as decimal.Decimal with SetUseDecimal.
+type RowsDecodeOptions struct {
+	// UnsignedIntegers decodes the values of the unsigned TINYINT, SMALLINT, MEDIUMINT, INT and BIGINT columns as
+	// uint8, uint16, uint32, uint32 and uint64, and the BIT values as uint64. The signedness of the columns is
+	// logged in the table map events by MySQL 8.0.1+ and MariaDB 10.5+ (binlog_row_metadata), the values of the
+	// columns of unknown signedness are decoded as signed.
+	UnsignedIntegers bool
+	// BitAsBytes decodes the BIT(M) values as their (M+7)/8 bytes, the most significant first.
+	BitAsBytes bool
+	// EnumSetNames decodes the ENUM values as the string of their name, "" for the error value 0, and the SET
+	// values as the []string of the names of their members, with the values logged in the table map events with
+	// binlog_row_metadata=FULL. They are decoded as int64 if the values are not logged.
+	EnumSetNames bool
+	// MysqlDecimal decodes the DECIMAL values as mysql.Decimal, with the scale of their column, and formats the
+	// DECIMAL values of the JSON documents with their scale, whether SetUseDecimal is set or not.
+	MysqlDecimal bool
+	// JSONBinary keeps the JSON values as mysql.JSONBinary, their binary encoding, rather than as their JSON text,
+	// both being decoded by mysql.DecodeJSONBinary. The partial updates are still *JsonDiff.
+	JSONBinary bool
+	// Geometry decodes the values of the spatial columns as mysql.Geometry, their SRID and their shape. A value
+	// which isn't a valid geometry fails the decoding of its event.
+	Geometry bool
+	// ConvertCharset converts the CHAR, VARCHAR and TEXT values of the columns of another character set than
+	// utf8, utf8mb4, ascii and binary to UTF-8, and the names of EnumSetNames, with their collations logged in the
+	// table map events with binlog_row_metadata=FULL. The values keep their Go type.
+	ConvertCharset bool
+}
+
 // RowsEventStmtEndFlag is set in the end of the statement.
 const RowsEventStmtEndFlag = 0x01
 
@@ -876,7 +983,7 @@ const RowsEventStmtEndFlag = 0x01
 // - MYSQL_TYPE_SHORT: int16
 // - MYSQL_TYPE_INT24: int32
 // - MYSQL_TYPE_LONGLONG: int64
-// - MYSQL_TYPE_NEWDECIMAL: string / "github.com/shopspring/decimal".Decimal
+// - MYSQL_TYPE_NEWDECIMAL: string / "github.com/shopspring/decimal".Decimal / mysql.Decimal
 // - MYSQL_TYPE_FLOAT: float32
 // - MYSQL_TYPE_DOUBLE: float64
 // - MYSQL_TYPE_BIT: int64
@@ -894,8 +1001,10 @@ const RowsEventStmtEndFlag = 0x01
 // - MYSQL_TYPE_VARCHAR: string
 // - MYSQL_TYPE_VAR_STRING: string
 // - MYSQL_TYPE_STRING: string
-// - MYSQL_TYPE_JSON: []byte / *replication.JsonDiff
-// - MYSQL_TYPE_GEOMETRY: []byte
+// - MYSQL_TYPE_JSON: []byte / *replication.JsonDiff, see RowsEvent.JsonDiffs and BinlogParser.SetApplyPartialJSON
+// - MYSQL_TYPE_GEOMETRY: []byte / mysql.Geometry
+//
+// see RowsDecodeOptions for the other types of the integer, DECIMAL, BIT, ENUM, SET, JSON and spatial values.
 type RowsEvent struct {
 	// 0, 1, 2
 	Version int
@@ -946,10 +1055,36 @@ type RowsEvent struct {
 	Rows           [][]interface{}
 	SkippedColumns [][]int
 
+	// Query is the statement of the rows, from the ROWS_QUERY_EVENT (binlog_rows_query_log_events=ON) or the
+	// MariaDB ANNOTATE_ROWS_EVENT (binlog_annotate_row_events=ON) logged before its table map events
+	Query []byte
+	// ThreadID is the id of the connection which executed the transaction of the rows, from its BEGIN query event
+	ThreadID uint32
+
 	parseTime               bool
 	timestampStringLocation *time.Location
 	useDecimal              bool
 	ignoreJSONDecodeErr     bool
+	applyPartialJSON        bool
+	decodeOptions           RowsDecodeOptions
+
+	// the diffs of the partial updates of the JSON columns, by row and column index
+	jsonDiffs map[[2]int][]*JsonDiff
+
+	// the metadata of the table used by decodeOptions, by column index
+	unsignedMap map[int]bool
+	enumValues  map[int][]string
+	setValues   map[int][]string
+	charsets    map[int]*Charset
+}
+
+// Columns returns the metadata of the columns of the table of the event, see TableMapEvent.Columns. It is nil if the
+// table is unknown.
+func (e *RowsEvent) Columns() []TableColumn {
+	if e.Table == nil {
+		return nil
+	}
+	return e.Table.Columns()
 }
 
 // EnumRowImageType is allowed types for every row in mysql binlog.
@@ -1062,6 +1197,7 @@ func (e *RowsEvent) DecodeData(pos int, data []byte) (err2 error) {
 			//nolint:nakedret
 			return
 		}
+		pos = 0
 	}
 
 	// Rows_log_event::print_verbose()
@@ -1084,12 +1220,26 @@ func (e *RowsEvent) DecodeData(pos int, data []byte) (err2 error) {
 	}
 	e.SkippedColumns = make([][]int, 0, rowsLen)
 	e.Rows = make([][]interface{}, 0, rowsLen)
+	e.jsonDiffs = nil
+
+	if e.Table != nil {
+		if e.decodeOptions.UnsignedIntegers {
+			e.unsignedMap = e.Table.UnsignedMap()
+		}
+		if e.decodeOptions.EnumSetNames {
+			e.enumValues = e.Table.EnumStrValueMap()
+			e.setValues = e.Table.SetStrValueMap()
+		}
+		if e.decodeOptions.ConvertCharset {
+			e.prepareCharsets()
+		}
+	}
 
 	var rowImageType EnumRowImageType
 	switch e.eventType {
-	case WRITE_ROWS_EVENTv0, WRITE_ROWS_EVENTv1, WRITE_ROWS_EVENTv2, MARIADB_WRITE_ROWS_COMPRESSED_EVENT_V1:
+	case WRITE_ROWS_EVENTv0, WRITE_ROWS_EVENTv1, WRITE_ROWS_EVENTv2, MARIADB_WRITE_ROWS_COMPRESSED_EVENT_V1, MARIADB_WRITE_ROWS_COMPRESSED_EVENT:
 		rowImageType = EnumRowImageTypeWriteAI
-	case DELETE_ROWS_EVENTv0, DELETE_ROWS_EVENTv1, DELETE_ROWS_EVENTv2, MARIADB_DELETE_ROWS_COMPRESSED_EVENT_V1:
+	case DELETE_ROWS_EVENTv0, DELETE_ROWS_EVENTv1, DELETE_ROWS_EVENTv2, MARIADB_DELETE_ROWS_COMPRESSED_EVENT_V1, MARIADB_DELETE_ROWS_COMPRESSED_EVENT:
 		rowImageType = EnumRowImageTypeDeleteBI
 	default:
 		rowImageType = EnumRowImageTypeUpdateBI
@@ -1132,6 +1282,12 @@ func isBitSetIncr(bitmap []byte, i *int) bool {
 	return v
 }
 
+// JsonDiffs returns the diffs of the partial update of the JSON column of Rows[row][column], nil if it is not a
+// partial update. The value of the row is only the first diff, as a *JsonDiff, unless the diffs were applied.
+func (e *RowsEvent) JsonDiffs(row, column int) []*JsonDiff {
+	return e.jsonDiffs[[2]int{row, column}]
+}
+
 func (e *RowsEvent) decodeImage(data []byte, bitmap []byte, rowImageType EnumRowImageType) (int, error) {
 	// Rows_log_event::print_verbose_one_row()
 
@@ -1198,6 +1354,30 @@ func (e *RowsEvent) decodeImage(data []byte, bitmap []byte, rowImageType EnumRow
 			return 0, err
 		}
 		pos += n
+
+		if e.decodeOptions != (RowsDecodeOptions{}) {
+			if row[i], err = e.convertValue(i, row[i]); err != nil {
+				return 0, err
+			}
+		}
+
+		if diffs, ok := row[i].([]*JsonDiff); ok {
+			// the row has the first diff, JsonDiffs returns all of them
+			row[i] = diffs[0]
+			if e.jsonDiffs == nil {
+				e.jsonDiffs = make(map[[2]int][]*JsonDiff)
+			}
+			e.jsonDiffs[[2]int{len(e.Rows), i}] = diffs
+
+			// the diffs are kept if the before image doesn't have the document, or they don't apply to it
+			if e.applyPartialJSON && len(e.Rows) > 0 {
+				if before, ok := e.Rows[len(e.Rows)-1][i].(string); ok {
+					if doc, err := ApplyJsonDiffs(before, diffs); err == nil {
+						row[i] = doc
+					}
+				}
+			}
+		}
 	}
 
 	e.Rows = append(e.Rows, row)
@@ -1205,6 +1385,126 @@ func (e *RowsEvent) decodeImage(data []byte, bitmap []byte, rowImageType EnumRow
 	return pos, nil
 }
 
+// prepareCharsets fills charsets with the character sets to convert to UTF-8, and converts the names of the enum
+// and set values. The names of the table map event are shared by its rows events, they are copied.
+func (e *RowsEvent) prepareCharsets() {
+	convertible := func(id uint64) (*Charset, bool) {
+		if id > 0xFFFF {
+			return nil, false
+		}
+		cs, ok := CharsetOfCollation(uint16(id))
+		return cs, ok && cs.Encoding != nil
+	}
+
+	e.charsets = make(map[int]*Charset)
+	for i, id := range e.Table.CollationMap() {
+		if cs, ok := convertible(id); ok {
+			e.charsets[i] = cs
+		}
+	}
+
+	for i, id := range e.Table.EnumSetCollationMap() {
+		cs, ok := convertible(id)
+		if !ok {
+			continue
+		}
+		decodeNames := func(names []string) []string {
+			decoded := make([]string, len(names))
+			for j, name := range names {
+				decoded[j] = string(cs.Decode([]byte(name)))
+			}
+			return decoded
+		}
+		if names, ok := e.enumValues[i]; ok {
+			e.enumValues[i] = decodeNames(names)
+		}
+		if names, ok := e.setValues[i]; ok {
+			e.setValues[i] = decodeNames(names)
+		}
+	}
+}
+
+// convertValue converts the value decoded of the column i to the type chosen by decodeOptions.
+func (e *RowsEvent) convertValue(i int, v interface{}) (interface{}, error) {
+	switch tp := e.Table.realType(i); tp {
+	case MYSQL_TYPE_TINY, MYSQL_TYPE_SHORT, MYSQL_TYPE_INT24, MYSQL_TYPE_LONG, MYSQL_TYPE_LONGLONG:
+		if !e.decodeOptions.UnsignedIntegers || !e.unsignedMap[i] {
+			break
+		}
+		switch v := v.(type) {
+		case int8:
+			return uint8(v), nil
+		case int16:
+			return uint16(v), nil
+		case int32:
+			if tp == MYSQL_TYPE_INT24 {
+				return uint32(v) & 0xFFFFFF, nil
+			}
+			return uint32(v), nil
+		case int64:
+			return uint64(v), nil
+		}
+	case MYSQL_TYPE_BIT:
+		bit, ok := v.(int64)
+		if !ok {
+			break
+		}
+		if e.decodeOptions.BitAsBytes {
+			meta := e.Table.ColumnMeta[i]
+			n := int(((meta>>8)*8)+(meta&0xFF)+7) / 8
+			b := make([]byte, 8)
+			binary.BigEndian.PutUint64(b, uint64(bit))
+			return b[8-n:], nil
+		}
+		if e.decodeOptions.UnsignedIntegers {
+			return uint64(bit), nil
+		}
+	case MYSQL_TYPE_ENUM:
+		names, ok := e.enumValues[i]
+		index, isInt := v.(int64)
+		if !ok || !isInt || index > int64(len(names)) {
+			break
+		}
+		if index == 0 {
+			return "", nil
+		}
+		return names[index-1], nil
+	case MYSQL_TYPE_SET:
+		names, ok := e.setValues[i]
+		bits, isInt := v.(int64)
+		if !ok || !isInt {
+			break
+		}
+		members := make([]string, 0, len(names))
+		for j, name := range names {
+			if uint64(bits)&(1<<uint(j)) != 0 {
+				members = append(members, name)
+			}
+		}
+		return members, nil
+	case MYSQL_TYPE_GEOMETRY:
+		if data, ok := v.([]byte); ok && e.decodeOptions.Geometry {
+			g, err := ParseGeometry(data)
+			if err != nil {
+				return nil, errors.Annotatef(err, "column %d", i)
+			}
+			return g, nil
+		}
+	case MYSQL_TYPE_STRING, MYSQL_TYPE_VAR_STRING, MYSQL_TYPE_VARCHAR, MYSQL_TYPE_BLOB:
+		cs, ok := e.charsets[i]
+		if !ok {
+			break
+		}
+		switch v := v.(type) {
+		case string:
+			return string(cs.Decode([]byte(v))), nil
+		case []byte:
+			return cs.Decode(v), nil
+		}
+	}
+	return v, nil
+}
+
 func (e *RowsEvent) parseFracTime(t interface{}) interface{} {
 	v, ok := t.(fracTime)
 	if !ok {
@@ -1262,7 +1562,11 @@ func (e *RowsEvent) decodeValue(data []byte, tp byte, meta uint16, isPartial boo
 	case MYSQL_TYPE_NEWDECIMAL:
 		prec := uint8(meta >> 8)
 		scale := uint8(meta & 0xFF)
-		v, n, err = decodeDecimal(data, int(prec), int(scale), e.useDecimal)
+		if e.decodeOptions.MysqlDecimal {
+			v, n, err = DecodeDecimalBinary(data, int(prec), int(scale))
+		} else {
+			v, n, err = decodeDecimal(data, int(prec), int(scale), e.useDecimal)
+		}
 	case MYSQL_TYPE_FLOAT:
 		n = 4
 		v = ParseBinaryFloat32(data)
@@ -1388,13 +1692,13 @@ func (e *RowsEvent) decodeValue(data []byte, tp byte, meta uint16, isPartial boo
 			v = []byte{}
 		} else {
 			if isPartial {
-				var diff *JsonDiff
-				diff, err = e.decodeJsonPartialBinary(data[meta:n])
+				var diffs []*JsonDiff
+				diffs, err = e.decodeJsonPartialBinary(data[meta:n])
 				if err == nil {
-					v = diff
-				} else {
-					fmt.Printf("decodeJsonPartialBinary(%q) fail: %s\n", data[meta:n], err)
+					v = diffs
 				}
+			} else if e.decodeOptions.JSONBinary {
+				v = JSONBinary(append([]byte(nil), data[meta:n]...))
 			} else {
 				var d []byte
 				d, err = e.decodeJsonBinary(data[meta:n])
@@ -1433,116 +1737,16 @@ func decodeString(data []byte, length int) (v string, n int) {
 	return
 }
 
-// ref: https://github.com/mysql/mysql-server/blob/a9b0c712de3509d8d08d3ba385d41a4df6348775/strings/decimal.c#L137
-const digitsPerInteger int = 9
-
-var compressedBytes = []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
-
-func decodeDecimalDecompressValue(compIndx int, data []byte, mask uint8) (size int, value uint32) {
-	size = compressedBytes[compIndx]
-	switch size {
-	case 0:
-	case 1:
-		value = uint32(data[0] ^ mask)
-	case 2:
-		value = uint32(data[1]^mask) | uint32(data[0]^mask)<<8
-	case 3:
-		value = uint32(data[2]^mask) | uint32(data[1]^mask)<<8 | uint32(data[0]^mask)<<16
-	case 4:
-		value = uint32(data[3]^mask) | uint32(data[2]^mask)<<8 | uint32(data[1]^mask)<<16 | uint32(data[0]^mask)<<24
-	}
-	return
-}
-
-var zeros = [digitsPerInteger]byte{48, 48, 48, 48, 48, 48, 48, 48, 48}
-
+// decodeDecimal decodes a DECIMAL(precision, decimals) as its string, or as a decimal.Decimal with useDecimal.
 func decodeDecimal(data []byte, precision int, decimals int, useDecimal bool) (interface{}, int, error) {
-	// see python mysql replication and https://github.com/jeremycole/mysql_binlog
-	integral := precision - decimals
-	uncompIntegral := integral / digitsPerInteger
-	uncompFractional := decimals / digitsPerInteger
-	compIntegral := integral - (uncompIntegral * digitsPerInteger)
-	compFractional := decimals - (uncompFractional * digitsPerInteger)
-
-	binSize := uncompIntegral*4 + compressedBytes[compIntegral] +
-		uncompFractional*4 + compressedBytes[compFractional]
-
-	buf := make([]byte, binSize)
-	copy(buf, data[:binSize])
-
-	// must copy the data for later change
-	data = buf
-
-	// Support negative
-	// The sign is encoded in the high bit of the the byte
-	// But this bit can also be used in the value
-	value := uint32(data[0])
-	var res strings.Builder
-	res.Grow(precision + 2)
-	var mask uint32 = 0
-	if value&0x80 == 0 {
-		mask = uint32((1 << 32) - 1)
-		res.WriteString("-")
-	}
-
-	// clear sign
-	data[0] ^= 0x80
-
-	zeroLeading := true
-
-	pos, value := decodeDecimalDecompressValue(compIntegral, data, uint8(mask))
-	if value != 0 {
-		zeroLeading = false
-		res.WriteString(strconv.FormatUint(uint64(value), 10))
-	}
-
-	for i := 0; i < uncompIntegral; i++ {
-		value = binary.BigEndian.Uint32(data[pos:]) ^ mask
-		pos += 4
-		if zeroLeading {
-			if value != 0 {
-				zeroLeading = false
-				res.WriteString(strconv.FormatUint(uint64(value), 10))
-			}
-		} else {
-			toWrite := strconv.FormatUint(uint64(value), 10)
-			res.Write(zeros[:digitsPerInteger-len(toWrite)])
-			res.WriteString(toWrite)
-		}
-	}
-
-	if zeroLeading {
-		res.WriteString("0")
-	}
-
-	if pos < len(data) {
-		res.WriteString(".")
-
-		for i := 0; i < uncompFractional; i++ {
-			value = binary.BigEndian.Uint32(data[pos:]) ^ mask
-			pos += 4
-			toWrite := strconv.FormatUint(uint64(value), 10)
-			res.Write(zeros[:digitsPerInteger-len(toWrite)])
-			res.WriteString(toWrite)
-		}
-
-		if size, value := decodeDecimalDecompressValue(compFractional, data[pos:], uint8(mask)); size > 0 {
-			toWrite := strconv.FormatUint(uint64(value), 10)
-			padding := compFractional - len(toWrite)
-			if padding > 0 {
-				res.Write(zeros[:padding])
-			}
-			res.WriteString(toWrite)
-			pos += size
-		}
+	d, n, err := DecodeDecimalBinary(data, precision, decimals)
+	if err != nil {
+		return nil, 0, err
 	}
-
 	if useDecimal {
-		f, err := decimal.NewFromString(res.String())
-		return f, pos, err
+		return d.Decimal(), n, nil
 	}
-
-	return res.String(), pos, nil
+	return d.String(), n, nil
 }
 
 func decodeBit(data []byte, nbits int, length int) (value int64, err error) {
@@ -1814,16 +2018,22 @@ func (e *RowsEvent) Dump(w io.Writer) {
 	fmt.Fprintf(w, "Flags: %d\n", e.Flags)
 	fmt.Fprintf(w, "Column count: %d\n", e.ColumnCount)
 	fmt.Fprintf(w, "NDB data: %s\n", e.NdbData)
+	if e.ThreadID != 0 {
+		fmt.Fprintf(w, "Thread ID: %d\n", e.ThreadID)
+	}
+	if len(e.Query) > 0 {
+		fmt.Fprintf(w, "Query: %s\n", e.Query)
+	}
 
 	fmt.Fprintf(w, "Values:\n")
-	for _, rows := range e.Rows {
+	for i, rows := range e.Rows {
 		fmt.Fprintf(w, "--\n")
 		for j, d := range rows {
 			switch dt := d.(type) {
 			case []byte:
 				fmt.Fprintf(w, "%d:%q\n", j, dt)
 			case *JsonDiff:
-				fmt.Fprintf(w, "%d:%s\n", j, dt)
+				fmt.Fprintf(w, "%d:%s\n", j, e.JsonDiffs(i, j))
 			default:
 				fmt.Fprintf(w, "%d:%#v\n", j, d)
 			}
@@ -1837,7 +2047,10 @@ type RowsQueryEvent struct {
 }
 
 func (e *RowsQueryEvent) Decode(data []byte) error {
-	// ignore length byte 1
+	if len(data) < 1 {
+		return errors.Errorf("invalid rows query event length %d", len(data))
+	}
+	// ignore length byte 1, the length of the query truncated to 255
 	e.Query = data[1:]
 	return nil
 }
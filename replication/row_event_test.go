@@ -2,6 +2,7 @@ package replication
 
 import (
 	"testing"
+	"time"
 
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/require"
@@ -666,6 +667,63 @@ func TestJsonCompatibility(t *testing.T) {
 	require.Equal(t, "{}", rows.Rows[2][2])
 }
 
+func TestJsonBinaryOption(t *testing.T) {
+	tableMapEvent := new(TableMapEvent)
+	tableMapEvent.tableIDSize = 6
+	require.NoError(t, tableMapEvent.Decode([]byte("l\x00\x00\x00\x00\x00\x01\x00\x04test\x00\x03t11\x00\x04\x03\x0f\xf5\x03\x03d\x00\x04\x0f")))
+
+	rows := &RowsEvent{decodeOptions: RowsDecodeOptions{JSONBinary: true}}
+	rows.tableIDSize = 6
+	rows.tables = map[uint64]*TableMapEvent{tableMapEvent.TableID: tableMapEvent}
+	rows.Version = 2
+
+	data := []byte("l\x00\x00\x00\x00\x00\x01\x00\x02\x00\x04\xff\xff\xf8\x01\x00\x00\x00\x02{}\x05\x00\x00\x00\x00\x00\x00\x04\x00\xf8\x01\x00\x00\x00\n{\"a\":1234}\r\x00\x00\x00\x00\x01\x00\x0c\x00\x0b\x00\x01\x00\x05\xd2\x04a")
+	require.NoError(t, rows.Decode(data))
+	require.Equal(t, mysql.JSONBinary("\x00\x00\x00\x04\x00"), rows.Rows[1][2])
+
+	doc, ok := rows.Rows[2][2].(mysql.JSONBinary)
+	require.True(t, ok)
+	v, err := doc.Decode()
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"a": int64(1234)}, v)
+	text, err := doc.JSON()
+	require.NoError(t, err)
+	require.Equal(t, `{"a":1234}`, string(text))
+}
+
+func TestDecodeJsonBinary(t *testing.T) {
+	data, err := mysql.EncodeJSONBinary([]interface{}{
+		mysql.MustParseDecimal("1.50"),
+		time.Date(2023, 5, 6, 7, 8, 9, 123000, time.UTC),
+		mysql.NewJSONDuration(-time.Hour),
+		mysql.JSONOpaque{Type: mysql.MYSQL_TYPE_BLOB, Data: []byte("ab")},
+		map[string]interface{}{"b": 2.5, "a": nil},
+	})
+	require.NoError(t, err)
+
+	e := &RowsEvent{}
+	text, err := e.decodeJsonBinary(data)
+	require.NoError(t, err)
+	require.Equal(t, `["1.50","2023-05-06 07:08:09.000123","-01:00:00.000000","ab",{"a":null,"b":2.5}]`, string(text))
+
+	e.useDecimal = true
+	text, err = e.decodeJsonBinary(data)
+	require.NoError(t, err)
+	require.Equal(t, `["1.5","2023-05-06 07:08:09.000123","-01:00:00.000000","ab",{"a":null,"b":2.5}]`, string(text))
+
+	e.decodeOptions.MysqlDecimal = true
+	text, err = e.decodeJsonBinary(data)
+	require.NoError(t, err)
+	require.Equal(t, `["1.50","2023-05-06 07:08:09.000123","-01:00:00.000000","ab",{"a":null,"b":2.5}]`, string(text))
+
+	_, err = e.decodeJsonBinary(data[:10])
+	require.Error(t, err)
+	e.ignoreJSONDecodeErr = true
+	text, err = e.decodeJsonBinary(data[:10])
+	require.NoError(t, err)
+	require.Equal(t, "null", string(text))
+}
+
 func TestDecodeDatetime2(t *testing.T) {
 	testcases := []struct {
 		data        []byte
@@ -939,6 +997,32 @@ func TestTableMapOptMetaPrimaryKey(t *testing.T) {
 	}
 }
 
+func TestTableMapColumns(t *testing.T) {
+	/*
+		create table _prim2 (col1 int, id1 char(10), col2 int, id2 varchar(20), primary key (id1, id2(10)));
+	*/
+	tableMapEvent := new(TableMapEvent)
+	tableMapEvent.tableIDSize = 6
+	// mysql 8.0
+	err := tableMapEvent.Decode([]byte("m\x00\x00\x00\x00\x00\x01\x00\x04test\x00\x06_prim2\x00\x04\x03\xfe\x03\x0f\x04\xfe(P\x00\x05\x01\x01\x00\x02\x01\xe0\x04\x12\x04col1\x03id1\x04col2\x03id2\t\x04\x01\x00\x03\n"))
+	require.NoError(t, err)
+
+	columns := (&RowsEvent{Table: tableMapEvent}).Columns()
+	require.Len(t, columns, 4)
+	require.Equal(t, TableColumn{Name: "col1", Type: mysql.MYSQL_TYPE_LONG, Nullable: true}, columns[0])
+	require.Equal(t, TableColumn{Name: "id1", Type: mysql.MYSQL_TYPE_STRING, Meta: tableMapEvent.ColumnMeta[1], Collation: 224, PrimaryKey: true}, columns[1])
+	require.Equal(t, "col2", columns[2].Name)
+	require.Equal(t, TableColumn{Name: "id2", Type: mysql.MYSQL_TYPE_VARCHAR, Meta: 80, Collation: 224, PrimaryKey: true, PrimaryKeyPrefix: 10}, columns[3])
+
+	require.Nil(t, (&RowsEvent{}).Columns())
+
+	// the optional metadata must fit in the event
+	data := []byte("m\x00\x00\x00\x00\x00\x01\x00\x04test\x00\x06_prim2\x00\x04\x03\xfe\x03\x0f\x04\xfe(P\x00\x05\x01\x01\x00\x04\x12\x04col1")
+	tableMapEvent = new(TableMapEvent)
+	tableMapEvent.tableIDSize = 6
+	require.ErrorContains(t, tableMapEvent.Decode(data), "invalid optional metadata")
+}
+
 func TestTableMapOptMetaVisibility(t *testing.T) {
 	/*
 		SET GLOBAL binlog_row_image = FULL;
@@ -1515,6 +1599,151 @@ func TestDecimal(t *testing.T) {
 	}
 }
 
+func TestMysqlDecimal(t *testing.T) {
+	for _, useDecimal := range []bool{false, true} {
+		for _, d := range decimalData {
+			e := &RowsEvent{
+				eventType: WRITE_ROWS_EVENTv2,
+				Table: &TableMapEvent{
+					ColumnCount: 1,
+					ColumnType:  []byte{mysql.MYSQL_TYPE_NEWDECIMAL},
+					ColumnMeta:  []uint16{d.meta},
+				},
+				ColumnCount:   1,
+				ColumnBitmap1: []byte{0x01},
+				useDecimal:    useDecimal,
+				decodeOptions: RowsDecodeOptions{MysqlDecimal: true},
+			}
+			require.NoError(t, e.DecodeData(0, append([]byte{0x00}, d.dumpData...)))
+			v, ok := e.Rows[0][0].(mysql.Decimal)
+			require.True(t, ok)
+			// the trailing zeros of the scale are kept
+			require.Equal(t, d.num, v.String())
+			require.Equal(t, int32(d.meta&0xFF), v.Scale())
+		}
+	}
+}
+
+func TestRowsDecodeOptions(t *testing.T) {
+	table := &TableMapEvent{
+		ColumnCount: 7,
+		ColumnType: []byte{
+			mysql.MYSQL_TYPE_TINY, mysql.MYSQL_TYPE_INT24, mysql.MYSQL_TYPE_LONGLONG, mysql.MYSQL_TYPE_LONG,
+			mysql.MYSQL_TYPE_BIT, mysql.MYSQL_TYPE_STRING, mysql.MYSQL_TYPE_STRING,
+		},
+		// BIT(10), ENUM and SET of 1 byte
+		ColumnMeta: []uint16{0, 0, 0, 0, 1<<8 | 2, uint16(mysql.MYSQL_TYPE_ENUM)<<8 | 1, uint16(mysql.MYSQL_TYPE_SET)<<8 | 1},
+		// the INT column is signed
+		SignednessBitmap: []byte{0xe0},
+		EnumStrValue:     [][][]byte{{[]byte("a"), []byte("b")}},
+		SetStrValue:      [][][]byte{{[]byte("a"), []byte("b"), []byte("c")}},
+	}
+	data := []byte{
+		0x00,
+		0xff,
+		0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff,
+		0x02, 0x01,
+		0x02,
+		0x05,
+	}
+
+	// the values of the enum and set columns are not logged
+	noValues := *table
+	noValues.EnumStrValue, noValues.SetStrValue = nil, nil
+
+	decode := func(table *TableMapEvent, opts RowsDecodeOptions) []interface{} {
+		e := &RowsEvent{
+			eventType:     WRITE_ROWS_EVENTv2,
+			Table:         table,
+			ColumnCount:   7,
+			ColumnBitmap1: []byte{0x7f},
+			decodeOptions: opts,
+		}
+		require.NoError(t, e.DecodeData(0, data))
+		require.Len(t, e.Rows, 1)
+		return e.Rows[0]
+	}
+
+	require.Equal(t, []interface{}{int8(-1), int32(-1), int64(-1), int32(-1), int64(513), int64(2), int64(5)}, decode(table, RowsDecodeOptions{}))
+	require.Equal(t, []interface{}{
+		uint8(0xff), uint32(0xffffff), uint64(0xffffffffffffffff), int32(-1), uint64(513), "b", []string{"a", "c"},
+	}, decode(table, RowsDecodeOptions{UnsignedIntegers: true, EnumSetNames: true}))
+	require.Equal(t, []byte{0x02, 0x01}, decode(table, RowsDecodeOptions{BitAsBytes: true})[4])
+	require.Equal(t, []interface{}{int64(2), int64(5)}, decode(&noValues, RowsDecodeOptions{EnumSetNames: true})[5:])
+}
+
+func TestRowsDecodeGeometry(t *testing.T) {
+	g := mysql.NewGeometry(4326, mysql.Point{X: 1, Y: 2})
+	data := append(mysql.Uint32ToBytes(uint32(len(g.Bytes()))), g.Bytes()...)
+
+	for _, opts := range []RowsDecodeOptions{{}, {Geometry: true}} {
+		e := &RowsEvent{
+			eventType: WRITE_ROWS_EVENTv2,
+			Table: &TableMapEvent{
+				ColumnCount: 1,
+				ColumnType:  []byte{mysql.MYSQL_TYPE_GEOMETRY},
+				ColumnMeta:  []uint16{4},
+			},
+			ColumnCount:   1,
+			ColumnBitmap1: []byte{0x01},
+			decodeOptions: opts,
+		}
+		require.NoError(t, e.DecodeData(0, append([]byte{0x00}, data...)))
+		if opts.Geometry {
+			require.Equal(t, g, e.Rows[0][0])
+		} else {
+			require.Equal(t, g.Bytes(), e.Rows[0][0])
+		}
+	}
+
+	// a value which isn't a geometry fails the event
+	e := &RowsEvent{
+		eventType: WRITE_ROWS_EVENTv2,
+		Table: &TableMapEvent{
+			ColumnCount: 1,
+			ColumnType:  []byte{mysql.MYSQL_TYPE_GEOMETRY},
+			ColumnMeta:  []uint16{4},
+		},
+		ColumnCount:   1,
+		ColumnBitmap1: []byte{0x01},
+		decodeOptions: RowsDecodeOptions{Geometry: true},
+	}
+	require.Error(t, e.DecodeData(0, []byte{0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03}))
+}
+
+func TestRowsDecodeConvertCharset(t *testing.T) {
+	// latin1 VARCHAR(10), gbk VARCHAR(10), utf8mb4 TEXT and latin1 ENUM('é') with binlog_row_metadata=FULL
+	table := &TableMapEvent{
+		ColumnCount:          4,
+		ColumnType:           []byte{mysql.MYSQL_TYPE_VARCHAR, mysql.MYSQL_TYPE_VARCHAR, mysql.MYSQL_TYPE_BLOB, mysql.MYSQL_TYPE_STRING},
+		ColumnMeta:           []uint16{10, 20, 2, uint16(mysql.MYSQL_TYPE_ENUM)<<8 | 1},
+		ColumnCharset:        []uint64{8, 28, 255},
+		EnumSetColumnCharset: []uint64{8},
+		EnumStrValue:         [][][]byte{{{0xe9}}},
+	}
+	data := []byte{0x00, 0x01, 0xe9, 0x02, 0xd6, 0xd0, 0x03, 0x00, 'h', 0xc3, 0xa9, 0x01}
+
+	for _, opts := range []RowsDecodeOptions{{EnumSetNames: true}, {EnumSetNames: true, ConvertCharset: true}} {
+		e := &RowsEvent{
+			eventType:     WRITE_ROWS_EVENTv2,
+			Table:         table,
+			ColumnCount:   4,
+			ColumnBitmap1: []byte{0x0f},
+			decodeOptions: opts,
+		}
+		require.NoError(t, e.DecodeData(0, data))
+		if opts.ConvertCharset {
+			require.Equal(t, []interface{}{"é", "中", []byte("hé"), "é"}, e.Rows[0])
+		} else {
+			require.Equal(t, []interface{}{"\xe9", "\xd6\xd0", []byte("hé"), "\xe9"}, e.Rows[0])
+		}
+	}
+	// the names of the table map event are kept
+	require.Equal(t, []string{"\xe9"}, table.EnumStrValueString()[0])
+}
+
 var intData = [][]byte{
 	{1, 0, 0, 0},
 	{2, 0, 0, 0},
@@ -0,0 +1,212 @@
+package replication
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// StartSyncFromTimestamp starts syncing from the first transaction started at or after t, see
+// FindPositionByTimestamp.
+func (b *BinlogSyncer) StartSyncFromTimestamp(t time.Time) (*BinlogStreamer, error) {
+	pos, err := b.FindPositionByTimestamp(t)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return b.StartSync(pos)
+}
+
+// FindPositionByTimestamp returns the position of the first transaction of the binlog of the master started at or
+// after t, to the second, or the end of the last binlog file if there is none yet. The binlog files listed by
+// SHOW BINARY LOGS are binary searched by the time of their format description event, then the events of the file
+// the transaction is in are read from its beginning, each file read with a new connection.
+func (b *BinlogSyncer) FindPositionByTimestamp(t time.Time) (Position, error) {
+	files, err := b.binaryLogs()
+	if err != nil {
+		return Position{}, errors.Trace(err)
+	}
+	if len(files) == 0 {
+		return Position{}, errors.New("no binlog file, the binlog may be disabled")
+	}
+
+	timestamp := t.Unix()
+
+	// the first binlog file created after t
+	lo, hi := 0, len(files)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		created, err := b.binlogCreateTime(files[mid])
+		if err != nil {
+			return Position{}, errors.Trace(err)
+		}
+		if int64(created) > timestamp {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	if lo == 0 {
+		return Position{Name: files[0], Pos: 4}, nil
+	}
+
+	pos, found, err := b.findTransaction(files[lo-1], timestamp)
+	if err != nil {
+		return Position{}, errors.Trace(err)
+	}
+	if !found && lo < len(files) {
+		pos = Position{Name: files[lo], Pos: 4}
+	}
+	b.cfg.Logger.Infof("found position %s for timestamp %s", pos, t)
+	return pos, nil
+}
+
+// binaryLogs returns the names of the binlog files of the master, the oldest first.
+func (b *BinlogSyncer) binaryLogs() ([]string, error) {
+	c, err := b.newConnection(b.ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer c.Close()
+
+	r, err := c.Execute("SHOW BINARY LOGS")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	files := make([]string, 0, r.RowNumber())
+	for i := 0; i < r.RowNumber(); i++ {
+		name, err := r.GetString(i, 0)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		files = append(files, name)
+	}
+	return files, nil
+}
+
+// binlogCreateTime returns the timestamp of the format description event of a binlog file, when it was created.
+func (b *BinlogSyncer) binlogCreateTime(name string) (uint32, error) {
+	var created uint32
+	err := b.scanBinlog(name, func(e *BinlogEvent) bool {
+		if e.Header.EventType != FORMAT_DESCRIPTION_EVENT {
+			return true
+		}
+		created = e.Header.Timestamp
+		return false
+	})
+	return created, errors.Trace(err)
+}
+
+// findTransaction returns the position of the first transaction of a binlog file started at or after timestamp, or
+// the end of the file if there is none.
+func (b *BinlogSyncer) findTransaction(name string, timestamp int64) (Position, bool, error) {
+	pos := Position{Name: name, Pos: 4}
+	found := false
+
+	var trx trxTracker
+	err := b.scanBinlog(name, func(e *BinlogEvent) bool {
+		if trx.starts(e) && int64(e.Header.Timestamp) >= timestamp {
+			pos.Pos = e.Header.LogPos - e.Header.EventSize
+			found = true
+			return false
+		}
+		if e.Header.LogPos > 0 {
+			pos.Pos = e.Header.LogPos
+		}
+		return true
+	})
+	return pos, found, errors.Trace(err)
+}
+
+// scanBinlog reads the events of a binlog file with a new connection and passes them to fn, until fn returns
+// false or the end of the file.
+func (b *BinlogSyncer) scanBinlog(name string, fn func(e *BinlogEvent) bool) error {
+	cfg := b.cfg
+	// an EOF packet is sent at the end of the last file
+	cfg.DumpCommandFlag = BINLOG_DUMP_NON_BLOCK
+	cfg.SemiSyncEnabled = false
+	cfg.RawModeEnabled = false
+	cfg.EventFilter = nil
+	s := NewBinlogSyncer(cfg)
+	defer s.Close()
+
+	// the statements are enough to tell the transactions
+	s.parser.SetEventFilter(&EventFilter{
+		IncludeEventTypes: []EventType{QUERY_EVENT, MARIADB_QUERY_COMPRESSED_EVENT, XID_EVENT},
+	})
+
+	if err := s.prepareSyncPos(Position{Name: name, Pos: 4}); err != nil {
+		return errors.Trace(err)
+	}
+
+	for {
+		data, err := s.c.ReadPacket()
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		switch data[0] {
+		case OK_HEADER:
+			e, err := s.parser.Parse(data[1:])
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if e.Header.EventType == ROTATE_EVENT && e.Header.LogPos != 0 {
+				// the next file
+				return nil
+			}
+			if !fn(e) {
+				return nil
+			}
+		case ERR_HEADER:
+			return errors.Trace(s.c.HandleErrorPacket(data))
+		case EOF_HEADER:
+			return nil
+		}
+	}
+}
+
+// trxTracker tells the events starting a transaction in a binlog file read from its beginning, the positions a
+// sync can start from.
+type trxTracker struct {
+	inTrx bool
+	// the transaction is ended by a XID or COMMIT event, not by its statement
+	begun bool
+}
+
+func (t *trxTracker) starts(e *BinlogEvent) bool {
+	start := false
+	switch ev := e.Event.(type) {
+	case *GTIDEvent:
+		start = true
+		t.inTrx, t.begun = true, false
+	case *MariadbGTIDEvent:
+		start = true
+		t.inTrx, t.begun = true, !ev.IsStandalone()
+	case *QueryEvent:
+		start = !t.inTrx
+		query := strings.ToUpper(strings.TrimSpace(string(ev.Query)))
+		switch {
+		case query == "BEGIN" || strings.HasPrefix(query, "XA START"):
+			t.inTrx, t.begun = true, true
+		case query == "COMMIT" || query == "ROLLBACK":
+			t.inTrx, t.begun = false, false
+		default:
+			// a DDL, or a statement of a transaction
+			if !t.begun {
+				t.inTrx = false
+			}
+		}
+	case *XIDEvent:
+		t.inTrx, t.begun = false, false
+	default:
+		if e.Header.EventType == XA_PREPARE_LOG_EVENT {
+			t.inTrx, t.begun = false, false
+		}
+	}
+	return start
+}
@@ -0,0 +1,57 @@
+package replication
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrxTrackerStarts(t *testing.T) {
+	query := func(q string) *BinlogEvent {
+		return &BinlogEvent{Header: &EventHeader{EventType: QUERY_EVENT}, Event: &QueryEvent{Query: []byte(q)}}
+	}
+	gtid := &BinlogEvent{Header: &EventHeader{EventType: GTID_EVENT}, Event: &GTIDEvent{}}
+	mariadbGTID := &BinlogEvent{Header: &EventHeader{EventType: MARIADB_GTID_EVENT}, Event: &MariadbGTIDEvent{}}
+	mariadbDDL := &BinlogEvent{
+		Header: &EventHeader{EventType: MARIADB_GTID_EVENT},
+		Event:  &MariadbGTIDEvent{Flags: BINLOG_MARIADB_FL_STANDALONE},
+	}
+	tableMap := &BinlogEvent{Header: &EventHeader{EventType: TABLE_MAP_EVENT}, Event: &GenericEvent{}}
+	rows := &BinlogEvent{Header: &EventHeader{EventType: WRITE_ROWS_EVENTv2}, Event: &GenericEvent{}}
+	xid := &BinlogEvent{Header: &EventHeader{EventType: XID_EVENT}, Event: &XIDEvent{}}
+	xaPrepare := &BinlogEvent{Header: &EventHeader{EventType: XA_PREPARE_LOG_EVENT}, Event: &GenericEvent{}}
+
+	testcases := []struct {
+		events []*BinlogEvent
+		starts []bool
+	}{
+		// MySQL with GTIDs
+		{
+			[]*BinlogEvent{gtid, query("BEGIN"), tableMap, rows, xid, gtid, query("CREATE TABLE t (id INT)"), gtid},
+			[]bool{true, false, false, false, false, true, false, true},
+		},
+		// MySQL without GTIDs
+		{
+			[]*BinlogEvent{query("BEGIN"), query("INSERT INTO t VALUES (1)"), query("COMMIT"), query("DROP TABLE t"), query("BEGIN")},
+			[]bool{true, false, false, true, true},
+		},
+		{
+			[]*BinlogEvent{gtid, query("XA START X'01'"), tableMap, rows, query("XA END X'01'"), xaPrepare, gtid, query("XA COMMIT X'01'"), gtid},
+			[]bool{true, false, false, false, false, false, true, false, true},
+		},
+		// MariaDB
+		{
+			[]*BinlogEvent{mariadbGTID, query("INSERT INTO t VALUES (1)"), query("INSERT INTO t VALUES (2)"), query("COMMIT"), mariadbDDL, query("DROP TABLE t"), mariadbGTID},
+			[]bool{true, false, false, false, true, false, true},
+		},
+	}
+
+	for _, tc := range testcases {
+		var trx trxTracker
+		starts := make([]bool, 0, len(tc.events))
+		for _, e := range tc.events {
+			starts = append(starts, trx.starts(e))
+		}
+		require.Equal(t, tc.starts, starts)
+	}
+}
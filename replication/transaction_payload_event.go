@@ -34,6 +34,9 @@ type TransactionPayloadEvent struct {
 	CompressionType  uint64
 	Payload          []byte
 	Events           []*BinlogEvent
+
+	// parses the events of the payload with the settings of the parser of the event, without checksums
+	parser *BinlogParser
 }
 
 func (e *TransactionPayloadEvent) compressionType() string {
@@ -99,27 +102,34 @@ func (e *TransactionPayloadEvent) decodeFields(data []byte) error {
 }
 
 func (e *TransactionPayloadEvent) decodePayload() error {
-	if e.CompressionType != ZSTD {
+	var payloadUncompressed []byte
+	switch e.CompressionType {
+	case ZSTD:
+		var decoder, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+		if err != nil {
+			return err
+		}
+		defer decoder.Close()
+
+		payloadUncompressed, err = decoder.DecodeAll(e.Payload, nil)
+		if err != nil {
+			return err
+		}
+	case NONE:
+		payloadUncompressed = e.Payload
+	default:
 		return fmt.Errorf("TransactionPayloadEvent has compression type %d (%s)",
 			e.CompressionType, e.compressionType())
 	}
 
-	var decoder, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
-	if err != nil {
-		return err
-	}
-	defer decoder.Close()
-
-	payloadUncompressed, err := decoder.DecodeAll(e.Payload, nil)
-	if err != nil {
-		return err
-	}
-
 	// The uncompressed data needs to be split up into individual events for Parse()
-	// to work on them. We can't use e.parser directly as we need to disable checksums
-	// but we still need the initialization from the FormatDescriptionEvent. We can't
-	// modify e.parser as it is used elsewhere.
-	parser := NewBinlogParser()
+	// to work on them. We can't use the parser of the event directly as we need to
+	// disable checksums but we still need the initialization from the
+	// FormatDescriptionEvent.
+	parser := e.parser
+	if parser == nil {
+		parser = NewBinlogParser()
+	}
 	parser.format = &FormatDescriptionEvent{
 		Version:                e.format.Version,
 		ServerVersion:          e.format.ServerVersion,
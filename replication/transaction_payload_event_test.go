@@ -0,0 +1,110 @@
+package replication
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+// transactionPayloadEvent returns a TRANSACTION_PAYLOAD_EVENT ending at logPos with the events, without their
+// checksums, in its payload.
+func transactionPayloadEvent(t *testing.T, compressionType byte, logPos uint32, events ...[]byte) []byte {
+	var payload []byte
+	for _, ev := range events {
+		ev = append([]byte(nil), ev[:len(ev)-BinlogChecksumLength]...)
+		binary.LittleEndian.PutUint32(ev[9:], uint32(len(ev)))
+		payload = append(payload, ev...)
+	}
+	uncompressedSize := len(payload)
+	if compressionType == ZSTD {
+		encoder, err := zstd.NewWriter(nil)
+		require.NoError(t, err)
+		payload = encoder.EncodeAll(payload, nil)
+		require.NoError(t, encoder.Close())
+	}
+
+	data := make([]byte, EventHeaderSize)
+	data = append(data, OTW_PAYLOAD_COMPRESSION_TYPE_FIELD, 1, compressionType)
+	data = append(data, OTW_PAYLOAD_UNCOMPRESSED_SIZE_FIELD, 4, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(data[len(data)-4:], uint32(uncompressedSize))
+	data = append(data, OTW_PAYLOAD_SIZE_FIELD, 4, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(data[len(data)-4:], uint32(len(payload)))
+	data = append(data, OTW_PAYLOAD_HEADER_END_MARK)
+	data = append(data, payload...)
+	// the checksum, not verified
+	data = append(data, 0, 0, 0, 0)
+
+	data[4] = byte(TRANSACTION_PAYLOAD_EVENT)
+	binary.LittleEndian.PutUint32(data[9:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(data[13:], logPos)
+	return data
+}
+
+func TestTransactionPayloadEvent(t *testing.T) {
+	for _, compressionType := range []byte{ZSTD, NONE} {
+		parser := NewBinlogParser()
+		decoded := 0
+		parser.SetRowsEventDecodeFunc(func(e *RowsEvent, data []byte) error {
+			decoded++
+			return e.Decode(data)
+		})
+		_, err := parser.Parse(mysql57Events[0])
+		require.NoError(t, err)
+
+		e, err := parser.Parse(transactionPayloadEvent(t, compressionType, 0x200, mysql57Events[1], mysql57Events[2]))
+		require.NoError(t, err)
+
+		payload := e.Event.(*TransactionPayloadEvent)
+		require.Equal(t, uint64(compressionType), payload.CompressionType)
+		require.Len(t, payload.Events, 2)
+		require.Equal(t, TABLE_MAP_EVENT, payload.Events[0].Header.EventType)
+		rows := payload.Events[1].Event.(*RowsEvent)
+		require.Equal(t, [][]interface{}{{int32(1)}}, rows.Rows)
+		// the events of the payload are parsed with the settings of the parser
+		require.Equal(t, 1, decoded)
+	}
+}
+
+func TestSyncerTransactionPayloadEvent(t *testing.T) {
+	packets := [][]byte{mysql57Events[0], transactionPayloadEvent(t, ZSTD, 0x200, mysql57Events[1], mysql57Events[2])}
+
+	for _, keep := range []bool{false, true} {
+		for _, parseWorkers := range []int{0, 2} {
+			b := NewBinlogSyncer(BinlogSyncerConfig{ServerID: 100, ParseWorkers: parseWorkers, KeepTransactionPayloadEvent: keep})
+			s := NewBinlogStreamer()
+			var p *parsePipeline
+			if parseWorkers > 0 {
+				p = newParsePipeline(b, s)
+			}
+			for _, data := range packets {
+				data = append([]byte{mysql.OK_HEADER}, data...)
+				if p != nil {
+					require.NoError(t, p.push(data))
+				} else {
+					require.NoError(t, b.parseEvent(s, data))
+				}
+			}
+			if p != nil {
+				p.close(nil)
+			}
+
+			events := s.DumpEvents()
+			if keep {
+				require.Len(t, events, 2)
+				require.IsType(t, &TransactionPayloadEvent{}, events[1].Event)
+			} else {
+				require.Len(t, events, 3)
+				require.Equal(t, TABLE_MAP_EVENT, events[1].Header.EventType)
+				require.Equal(t, [][]interface{}{{int32(1)}}, events[2].Event.(*RowsEvent).Rows)
+				for _, e := range events[1:] {
+					require.Equal(t, uint32(0x200), e.Header.LogPos)
+				}
+			}
+			require.Equal(t, uint32(0x200), b.GetNextPosition().Pos)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// StatisticsHandler is an optional extension of Handler answering COM_STATISTICS, e.g. sent by mysqladmin status.
+// Without it, the server answers with its own uptime, connections and questions.
+type StatisticsHandler interface {
+	//handle COM_STATISTICS, returning the human-readable status string, e.g. "Uptime: 10  Threads: 1  Questions: 5"
+	HandleStatistics() (string, error)
+}
+
+// ProcessInfoHandler is an optional extension of Handler answering COM_PROCESS_INFO, the deprecated command form of
+// SHOW PROCESSLIST. Without it, the server answers with its process list if the emulation is enabled, see
+// SetProcessListEmulation, and passes the command to HandleOtherCommand otherwise.
+type ProcessInfoHandler interface {
+	//handle COM_PROCESS_INFO, returning the resultset of SHOW PROCESSLIST
+	HandleProcessInfo() (*Result, error)
+}
+
+// DebugHandler is an optional extension of Handler answering COM_DEBUG, asking the server to dump debug information
+// to its log, e.g. sent by mysqladmin debug. Without it, the command does nothing and succeeds.
+type DebugHandler interface {
+	//handle COM_DEBUG
+	HandleDebug() error
+}
+
+// statisticsResponse is the string answering COM_STATISTICS, sent as is without any header.
+type statisticsResponse string
+
+func (c *Conn) handleStatistics() (statisticsResponse, error) {
+	if h, ok := c.h.(StatisticsHandler); ok {
+		s, err := h.HandleStatistics()
+		return statisticsResponse(s), err
+	}
+	if c.serverConf == nil {
+		return "", nil
+	}
+	return statisticsResponse(c.serverConf.statistics()), nil
+}
+
+// statistics returns the status string of MySQL, the counters the server does not have are zero.
+func (s *Server) statistics() string {
+	threads := 0
+	s.conns.Range(func(_, _ interface{}) bool {
+		threads++
+		return true
+	})
+
+	uptime := int64(time.Since(s.started).Seconds())
+	questions := s.questions.Get()
+	qps := 0.0
+	if uptime > 0 {
+		qps = float64(questions) / float64(uptime)
+	}
+	return fmt.Sprintf("Uptime: %d  Threads: %d  Questions: %d  Slow queries: 0  Opens: 0  Flush tables: 0  "+
+		"Open tables: 0  Queries per second avg: %.3f", uptime, threads, questions, qps)
+}
+
+func (c *Conn) handleProcessInfo(data []byte) interface{} {
+	if h, ok := c.h.(ProcessInfoHandler); ok {
+		if r, err := h.HandleProcessInfo(); err != nil {
+			return err
+		} else {
+			return r
+		}
+	}
+	if c.processListEnabled() {
+		if r, err := c.serverConf.processListResult([]string{"Id", "User", "Host", "db", "Command", "Time", "State", "Info"}, c.processListUser(), false); err != nil {
+			return err
+		} else {
+			return r
+		}
+	}
+	return c.handler().HandleOtherCommandContext(c.Context(), COM_PROCESS_INFO, data)
+}
+
+func (c *Conn) writeStatistics(s statisticsResponse) error {
+	data := make([]byte, 4, 4+len(s))
+	data = append(data, s...)
+	return c.WritePacket(data)
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/packet"
+	mockconn "github.com/atoonk/go-mysql/test_util/conn"
+)
+
+type testAdminHandler struct {
+	EmptyHandler
+	debugged bool
+}
+
+func (h *testAdminHandler) HandleStatistics() (string, error) {
+	return "Uptime: 42", nil
+}
+
+func (h *testAdminHandler) HandleDebug() error {
+	h.debugged = true
+	return nil
+}
+
+func (h *testAdminHandler) HandleProcessInfo() (*mysql.Result, error) {
+	return nil, errors.New("denied")
+}
+
+func TestAdminCommandDefaults(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	clientConn := &mockconn.MockConn{}
+	c := &Conn{Conn: packet.NewConn(clientConn), serverConf: svr, h: EmptyHandler{}, logger: mysql.NewDefaultLogger()}
+	c.SetCapability(mysql.CLIENT_PROTOCOL_41)
+	svr.registerConn(c)
+
+	require.NoError(t, c.handlePacket([]byte{mysql.COM_STATISTICS}))
+	stats := string(clientConn.WriteBuffered[4:])
+	require.True(t, strings.HasPrefix(stats, "Uptime: "), stats)
+	require.Contains(t, stats, "Threads: 1  Questions: 1  ")
+
+	require.Nil(t, c.dispatch([]byte{mysql.COM_DEBUG}))
+
+	// the process list is only known with the emulation
+	v := c.dispatch([]byte{mysql.COM_PROCESS_INFO})
+	require.EqualValues(t, mysql.ER_UNKNOWN_ERROR, v.(*mysql.MyError).Code)
+	svr.SetProcessListEmulation(true)
+	r := c.dispatch([]byte{mysql.COM_PROCESS_INFO}).(*mysql.Result)
+	require.Len(t, r.RowDatas, 1)
+}
+
+func TestAdminCommandHandlers(t *testing.T) {
+	h := &testAdminHandler{}
+	c := &Conn{Conn: packet.NewConn(&mockconn.MockConn{}), h: h}
+
+	require.Equal(t, statisticsResponse("Uptime: 42"), c.dispatch([]byte{mysql.COM_STATISTICS}))
+	require.Nil(t, c.dispatch([]byte{mysql.COM_DEBUG}))
+	require.True(t, h.debugged)
+	require.EqualError(t, c.dispatch([]byte{mysql.COM_PROCESS_INFO}).(error), "denied")
+}
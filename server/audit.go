@@ -0,0 +1,280 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// AuditEventType is the kind of an AuditEvent.
+type AuditEventType string
+
+const (
+	// AuditConnect is the authentication of a connection, ErrorCode is set if it failed
+	AuditConnect AuditEventType = "connect"
+	// AuditStatement is a statement run by COM_QUERY or COM_STMT_EXECUTE
+	AuditStatement AuditEventType = "statement"
+)
+
+// AuditEvent is an entry of the audit trail of a Server.
+type AuditEvent struct {
+	Time         time.Time      `json:"time"`
+	Type         AuditEventType `json:"type"`
+	ConnectionID uint32         `json:"connection_id"`
+	User         string         `json:"user"`
+	// SourceIP is the IP address of the client, the one sent in the PROXY protocol header if it is enabled
+	SourceIP string `json:"source_ip"`
+	DB       string `json:"db,omitempty"`
+	// Command is the name of the command, e.g. "Query" or "Execute", see CommandName
+	Command string `json:"command,omitempty"`
+	Query   string `json:"query,omitempty"`
+	// Digest identifies the statements differing only by their literals and comments, see QueryDigest
+	Digest       string        `json:"digest,omitempty"`
+	RowsAffected uint64        `json:"rows_affected"`
+	Duration     time.Duration `json:"-"`
+	// ErrorCode is the MySQL error code sent to the client, 0 on success
+	ErrorCode uint16 `json:"error_code"`
+}
+
+// MarshalJSON encodes the duration in microseconds.
+func (e *AuditEvent) MarshalJSON() ([]byte, error) {
+	type event AuditEvent
+	return json.Marshal(&struct {
+		*event
+		Duration int64 `json:"duration_us"`
+	}{(*event)(e), e.Duration.Microseconds()})
+}
+
+// Auditor receives the audit events of the connections of a Server, see JSONLinesAuditor. Audit is called from the
+// goroutines using the connections once the response has been written, it must be safe for concurrent use.
+type Auditor interface {
+	Audit(e *AuditEvent)
+}
+
+// SetAuditor sets the auditor of the authentications and the statements of the server connections.
+func (s *Server) SetAuditor(a Auditor) {
+	s.auditor = a
+}
+
+func (c *Conn) auditor() Auditor {
+	if c.serverConf == nil {
+		return nil
+	}
+	return c.serverConf.auditor
+}
+
+// auditEvent returns an event with the current state of the connection.
+func (c *Conn) auditEvent(t AuditEventType) *AuditEvent {
+	e := &AuditEvent{
+		Time:         time.Now(),
+		Type:         t,
+		ConnectionID: c.connectionID,
+		User:         c.user,
+		DB:           c.db,
+	}
+	if addr := c.RemoteAddr(); addr != nil {
+		e.SourceIP = addr.String()
+		if host, _, err := net.SplitHostPort(e.SourceIP); err == nil {
+			e.SourceIP = host
+		}
+	}
+	return e
+}
+
+// auditConnect audits the result of the authentication of the connection.
+func (c *Conn) auditConnect(err error) {
+	a := c.auditor()
+	if a == nil {
+		return
+	}
+	e := c.auditEvent(AuditConnect)
+	e.ErrorCode = auditErrorCode(err)
+	a.Audit(e)
+}
+
+// auditStatement audits the statement run by a command once its response v has been written, query is the
+// statement of the command, nil for the commands not audited.
+func (c *Conn) auditStatement(cmd byte, query *string, v interface{}, start time.Time) {
+	a := c.auditor()
+	if a == nil || query == nil {
+		return
+	}
+	e := c.auditEvent(AuditStatement)
+	e.Command = CommandName(cmd)
+	e.Query = *query
+	_, e.Digest = QueryDigest(*query)
+	e.Duration = e.Time.Sub(start)
+
+	for _, v := range auditResponses(v) {
+		switch v := v.(type) {
+		case *Result:
+			if v != nil {
+				e.RowsAffected += v.AffectedRows
+			}
+		case error:
+			e.ErrorCode = auditErrorCode(v)
+		}
+	}
+	a.Audit(e)
+}
+
+// auditQuery returns the statement of the commands audited as statements.
+func (c *Conn) auditQuery(data []byte) *string {
+	if c.auditor() == nil {
+		return nil
+	}
+	switch data[0] {
+	case COM_QUERY, COM_STMT_EXECUTE:
+		return c.commandInfo(data)
+	}
+	return nil
+}
+
+// auditResponses flattens the responses of the statements of a multi-statement query.
+func auditResponses(v interface{}) []interface{} {
+	if m, ok := v.(multiResponse); ok {
+		return m
+	}
+	return []interface{}{v}
+}
+
+func auditErrorCode(err error) uint16 {
+	if err == nil {
+		return 0
+	}
+	var e *MyError
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ER_UNKNOWN_ERROR
+}
+
+// QueryDigest returns the normalized text of a query, with its literals replaced by "?", its comments removed and its
+// tokens separated by a single space, and the hex SHA-256 of the text identifying the statements of the same shape.
+func QueryDigest(query string) (text, digest string) {
+	var b strings.Builder
+	write := func(token string) {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(token)
+	}
+
+	for i := 0; i < len(query); i++ {
+		switch ch := query[i]; {
+		case ch == '\'' || ch == '"':
+			i = skipQuoted(query, i)
+			write("?")
+		case ch == '`':
+			start := i
+			if i = skipQuoted(query, i); i < len(query) {
+				write(query[start : i+1])
+			} else {
+				write(query[start:])
+			}
+		case ch == '#' || ch == '-' && strings.HasPrefix(query[i:], "--") && (i+2 == len(query) || query[i+2] <= ' '):
+			i = skipLineComment(query, i)
+		case ch == '/' && strings.HasPrefix(query[i:], "/*"):
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(query)
+			}
+		case ch <= ' ':
+		case isDigit(ch) && (i == 0 || !isIdentByte(query[i-1])):
+			for i+1 < len(query) && (isIdentByte(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			write("?")
+		case isIdentByte(ch):
+			start := i
+			for i+1 < len(query) && isIdentByte(query[i+1]) {
+				i++
+			}
+			write(strings.ToUpper(query[start : i+1]))
+		default:
+			write(query[i : i+1])
+		}
+	}
+
+	text = strings.TrimSuffix(b.String(), " ;")
+	sum := sha256.Sum256([]byte(text))
+	return text, hex.EncodeToString(sum[:])
+}
+
+// skipQuoted returns the index of the closing quote of the quoted string starting at i, a doubled quote or a backslash
+// escapes it (except for identifiers).
+func skipQuoted(query string, i int) int {
+	ch := query[i]
+	for i++; i < len(query); i++ {
+		if query[i] == '\\' && ch != '`' {
+			i++
+		} else if query[i] == ch {
+			if i+1 < len(query) && query[i+1] == ch {
+				i++
+			} else {
+				break
+			}
+		}
+	}
+	return i
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isIdentByte(ch byte) bool {
+	return isDigit(ch) || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_' || ch == '$' || ch >= 0x80
+}
+
+// JSONLinesAuditor is an Auditor writing the events as lines of JSON objects.
+type JSONLinesAuditor struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+	// ErrorHandler is called with the errors writing the events, they are dropped if it is nil
+	ErrorHandler func(err error)
+}
+
+// NewJSONLinesAuditor returns an auditor writing the events to w.
+func NewJSONLinesAuditor(w io.Writer) *JSONLinesAuditor {
+	return &JSONLinesAuditor{w: w, enc: json.NewEncoder(w)}
+}
+
+// OpenJSONLinesAuditor returns an auditor appending the events to the file at path, created if it does not exist.
+func OpenJSONLinesAuditor(path string) (*JSONLinesAuditor, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONLinesAuditor(f), nil
+}
+
+func (a *JSONLinesAuditor) Audit(e *AuditEvent) {
+	a.mu.Lock()
+	err := a.enc.Encode(e)
+	a.mu.Unlock()
+	if err != nil && a.ErrorHandler != nil {
+		a.ErrorHandler(err)
+	}
+}
+
+// Close closes the writer of the auditor if it is an io.Closer, e.g. the file opened by OpenJSONLinesAuditor.
+func (a *JSONLinesAuditor) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c, ok := a.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
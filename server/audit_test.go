@@ -0,0 +1,139 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+type testAuditor struct {
+	mu     sync.Mutex
+	events []AuditEvent
+}
+
+func (a *testAuditor) Audit(e *AuditEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, *e)
+}
+
+type auditHandler struct {
+	EmptyHandler
+}
+
+func (h auditHandler) HandleQuery(query string) (*mysql.Result, error) {
+	if query == "DELETE FROM t WHERE id = 1" {
+		return &mysql.Result{AffectedRows: 1}, nil
+	}
+	return nil, mysql.NewDefaultError(mysql.ER_NO_SUCH_TABLE, "db", "t")
+}
+
+func TestAudit(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	a := &testAuditor{}
+	svr.SetAuditor(a)
+
+	addr := serveTestConns(t, svr, nil, auditHandler{})
+
+	c, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+	require.NoError(t, c.Ping())
+	_, err = c.Execute("DELETE FROM t WHERE id = 1")
+	require.NoError(t, err)
+	_, err = c.Execute("SELECT * FROM t")
+	require.Error(t, err)
+	c.Close()
+
+	_, err = client.Connect(addr, "root", "wrong", "")
+	require.Error(t, err)
+
+	require.Eventually(t, func() bool {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		return len(a.events) == 4
+	}, time.Second, 10*time.Millisecond)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// the ping is not audited
+	connect, del, sel, denied := a.events[0], a.events[1], a.events[2], a.events[3]
+	require.Equal(t, AuditConnect, connect.Type)
+	require.Equal(t, "root", connect.User)
+	require.Equal(t, "127.0.0.1", connect.SourceIP)
+	require.Zero(t, connect.ErrorCode)
+
+	require.Equal(t, AuditStatement, del.Type)
+	require.Equal(t, connect.ConnectionID, del.ConnectionID)
+	require.Equal(t, "Query", del.Command)
+	require.Equal(t, "DELETE FROM t WHERE id = 1", del.Query)
+	_, digest := QueryDigest("delete from t where id = 2")
+	require.Equal(t, digest, del.Digest)
+	require.EqualValues(t, 1, del.RowsAffected)
+	require.Zero(t, del.ErrorCode)
+
+	require.EqualValues(t, mysql.ER_NO_SUCH_TABLE, sel.ErrorCode)
+
+	require.Equal(t, AuditConnect, denied.Type)
+	require.EqualValues(t, mysql.ER_ACCESS_DENIED_ERROR, denied.ErrorCode)
+}
+
+func TestQueryDigest(t *testing.T) {
+	tests := []struct {
+		query string
+		text  string
+	}{
+		{"SELECT 1", "SELECT ?"},
+		{"select  *\n from t1 where a = 'x''y' and b=\"z\" -- comment", "SELECT * FROM T1 WHERE A = ? AND B = ?"},
+		{"SELECT /* hint */ `a b` FROM t WHERE c IN (1, 2.5, 0x1F);", "SELECT `a b` FROM T WHERE C IN ( ? , ? , ? )"},
+		{"INSERT INTO t VALUES ('a\\'b') # comment", "INSERT INTO T VALUES ( ? )"},
+	}
+	for _, tt := range tests {
+		text, digest := QueryDigest(tt.query)
+		require.Equal(t, tt.text, text, tt.query)
+		require.Len(t, digest, 64)
+	}
+
+	_, d1 := QueryDigest("SELECT * FROM t WHERE id = 1")
+	_, d2 := QueryDigest("select * from t where id=42")
+	_, d3 := QueryDigest("SELECT * FROM u WHERE id = 1")
+	require.Equal(t, d1, d2)
+	require.NotEqual(t, d1, d3)
+}
+
+func TestJSONLinesAuditor(t *testing.T) {
+	var buf bytes.Buffer
+	a := NewJSONLinesAuditor(&buf)
+	a.Audit(&AuditEvent{Type: AuditStatement, User: "root", Query: "SELECT 1", Duration: 1500 * time.Microsecond})
+	a.Audit(&AuditEvent{Type: AuditConnect, User: "bob", ErrorCode: mysql.ER_ACCESS_DENIED_ERROR})
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	var e map[string]interface{}
+	require.NoError(t, json.Unmarshal(lines[0], &e))
+	require.Equal(t, "statement", e["type"])
+	require.Equal(t, "SELECT 1", e["query"])
+	require.EqualValues(t, 1500, e["duration_us"])
+	require.NoError(t, json.Unmarshal(lines[1], &e))
+	require.EqualValues(t, mysql.ER_ACCESS_DENIED_ERROR, e["error_code"])
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	for i := 0; i < 2; i++ {
+		a, err := OpenJSONLinesAuditor(path)
+		require.NoError(t, err)
+		a.Audit(&AuditEvent{Type: AuditConnect, User: "root"})
+		require.NoError(t, a.Close())
+	}
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	// the file is appended to
+	require.Equal(t, 2, bytes.Count(data, []byte("\n")))
+}
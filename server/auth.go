@@ -49,12 +49,22 @@ func (c *Conn) compareAuthData(authPluginName string, clientAuthData []byte) err
 		return c.compareSha256PasswordAuthData(clientAuthData, c.password)
 
 	default:
+		if p, ok := getAuthPlugin(authPluginName); ok {
+			return p.Authenticate(c, clientAuthData)
+		}
 		return errors.Errorf("unknown authentication plugin name '%s'", authPluginName)
 	}
 }
 
 func (c *Conn) acquirePassword() error {
-	password, found, err := c.credentialProvider.GetCredential(c.user)
+	var password string
+	var found bool
+	var err error
+	if p, ok := c.credentialProvider.(RemoteAddrCredentialProvider); ok {
+		password, found, err = p.GetCredentialFrom(c.user, c.RemoteAddr())
+	} else {
+		password, found, err = c.credentialProvider.GetCredential(c.user)
+	}
 	if err != nil {
 		return err
 	}
@@ -100,6 +110,18 @@ func (c *Conn) compareNativePasswordAuthData(clientAuthData []byte, password str
 	return errAccessDenied(password)
 }
 
+// secureTransport reports whether the client can send its password in clear text: the connection uses TLS or a
+// unix socket, as the clients do.
+func (c *Conn) secureTransport() (bool, error) {
+	if tlsConn, ok := c.Conn.Conn.(*tls.Conn); ok {
+		if !tlsConn.ConnectionState().HandshakeComplete {
+			return false, errors.New("incomplete TSL handshake")
+		}
+		return true, nil
+	}
+	return c.isUnixSocket(), nil
+}
+
 func (c *Conn) compareSha256PasswordAuthData(clientAuthData []byte, password string) error {
 	// Empty passwords are not hashed, but sent as empty string
 	if len(clientAuthData) == 0 {
@@ -108,11 +130,12 @@ func (c *Conn) compareSha256PasswordAuthData(clientAuthData []byte, password str
 		}
 		return ErrAccessDenied
 	}
-	if tlsConn, ok := c.Conn.Conn.(*tls.Conn); ok {
-		if !tlsConn.ConnectionState().HandshakeComplete {
-			return errors.New("incomplete TSL handshake")
-		}
-		// connection is SSL/TLS, client should send plain password
+	secure, err := c.secureTransport()
+	if err != nil {
+		return err
+	}
+	if secure {
+		// connection is SSL/TLS or a unix socket, client should send plain password
 		// deal with the trailing \NUL added for plain text password received
 		if l := len(clientAuthData); l != 0 && clientAuthData[l-1] == 0x00 {
 			clientAuthData = clientAuthData[:l-1]
@@ -124,7 +147,11 @@ func (c *Conn) compareSha256PasswordAuthData(clientAuthData []byte, password str
 	} else {
 		// client should send encrypted password
 		// decrypt
-		dbytes, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, (c.serverConf.tlsConfig.Certificates[0].PrivateKey).(*rsa.PrivateKey), clientAuthData, nil)
+		key, err := c.rsaPrivateKey()
+		if err != nil {
+			return err
+		}
+		dbytes, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, key, clientAuthData, nil)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"fmt"
+	"sync"
+
+	. "github.com/atoonk/go-mysql/mysql"
+	"github.com/pingcap/errors"
+)
+
+// AuthPlugin implements a server side authentication method.
+//
+// The built-in 'mysql_native_password', 'caching_sha2_password' and 'sha256_password' methods are always available,
+// other methods can be provided by registering an AuthPlugin with RegisterAuthPlugin and using its name as the
+// default auth method of the Server.
+type AuthPlugin interface {
+	// Name returns the name of the plugin as sent in the handshake, e.g. 'mysql_clear_password'
+	Name() string
+	// Authenticate checks the auth data sent by the client in the handshake response or the auth switch response.
+	// It can exchange more packets with the client through the connection (AuthMoreData, etc.) before returning.
+	// Returning nil authenticates the client, ErrAccessDenied (or a wrapping error) rejects it.
+	Authenticate(c *Conn, authData []byte) error
+}
+
+// AnyClientMethodAuthPlugin is an optional extension of AuthPlugin for the methods ignoring the auth data, e.g.
+// 'auth_socket': the client answering the handshake with another method is not asked to switch to it.
+type AnyClientMethodAuthPlugin interface {
+	AcceptsAnyClientMethod() bool
+}
+
+var authPlugins sync.Map // name -> AuthPlugin
+
+// RegisterAuthPlugin makes an authentication method available to all servers.
+// It panics if the name is empty or one of the built-in methods.
+func RegisterAuthPlugin(p AuthPlugin) {
+	name := p.Name()
+	if name == "" || isBuiltinAuthMethod(name) {
+		panic(fmt.Sprintf("can not register authentication method '%s'", name))
+	}
+	authPlugins.Store(name, p)
+}
+
+func getAuthPlugin(name string) (AuthPlugin, bool) {
+	p, ok := authPlugins.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return p.(AuthPlugin), true
+}
+
+func acceptsAnyClientMethod(name string) bool {
+	p, ok := getAuthPlugin(name)
+	if !ok {
+		return false
+	}
+	a, ok := p.(AnyClientMethodAuthPlugin)
+	return ok && a.AcceptsAnyClientMethod()
+}
+
+func isBuiltinAuthMethod(authMethod string) bool {
+	return authMethod == AUTH_NATIVE_PASSWORD || authMethod == AUTH_CACHING_SHA2_PASSWORD || authMethod == AUTH_SHA256_PASSWORD
+}
+
+// Salt returns the scramble sent to the client in the initial handshake or the last auth switch request.
+func (c *Conn) Salt() []byte {
+	return c.salt
+}
+
+// GetCredential returns the password of the connecting user from the credential provider.
+func (c *Conn) GetCredential() (string, error) {
+	if err := c.acquirePassword(); err != nil {
+		return "", err
+	}
+	return c.password, nil
+}
+
+// rsaPrivateKey returns the key used to decrypt the passwords sent by 'sha256_password' and 'caching_sha2_password'
+// clients over an insecure connection.
+func (c *Conn) rsaPrivateKey() (*rsa.PrivateKey, error) {
+	if c.serverConf.tlsConfig == nil || len(c.serverConf.tlsConfig.Certificates) == 0 {
+		return nil, errors.New("no RSA private key available for password exchange: server TLS config has no certificate")
+	}
+	key, ok := c.serverConf.tlsConfig.Certificates[0].PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("no RSA private key available for password exchange: server certificate key is not RSA")
+	}
+	return key, nil
+}
+
+// ClearPasswordAuthPlugin implements 'mysql_clear_password', the client sends the password in clear text, so it
+// should only be used over TLS connections or unix sockets.
+type ClearPasswordAuthPlugin struct{}
+
+func (ClearPasswordAuthPlugin) Name() string {
+	return AUTH_CLEAR_PASSWORD
+}
+
+func (ClearPasswordAuthPlugin) Authenticate(c *Conn, authData []byte) error {
+	password, err := c.GetCredential()
+	if err != nil {
+		return err
+	}
+	// deal with the trailing \NUL added for plain text password received
+	if l := len(authData); l != 0 && authData[l-1] == 0x00 {
+		authData = authData[:l-1]
+	}
+	if bytes.Equal(authData, []byte(password)) {
+		return nil
+	}
+	return errAccessDenied(password)
+}
+
+func init() {
+	RegisterAuthPlugin(ClearPasswordAuthPlugin{})
+	RegisterAuthPlugin(SocketAuthPlugin{})
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/packet"
+	mockconn "github.com/atoonk/go-mysql/test_util/conn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterAuthPlugin(t *testing.T) {
+	require.True(t, isAuthMethodSupported(mysql.AUTH_CLEAR_PASSWORD))
+	require.False(t, isAuthMethodSupported("unknown_password"))
+
+	require.Panics(t, func() {
+		RegisterAuthPlugin(testAuthPlugin{mysql.AUTH_NATIVE_PASSWORD})
+	})
+
+	RegisterAuthPlugin(testAuthPlugin{"test_password"})
+	require.True(t, isAuthMethodSupported("test_password"))
+	require.NotPanics(t, func() {
+		NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, "test_password", nil, nil)
+	})
+}
+
+func TestClearPasswordAuthPlugin(t *testing.T) {
+	p := NewInMemoryProvider()
+	p.AddUser("root", "secret")
+	c := &Conn{Conn: packet.NewConn(&mockconn.MockConn{}), credentialProvider: p, user: "root"}
+
+	require.NoError(t, c.compareAuthData(mysql.AUTH_CLEAR_PASSWORD, []byte("secret\x00")))
+	require.True(t, errors.Is(c.compareAuthData(mysql.AUTH_CLEAR_PASSWORD, []byte("wrong\x00")), ErrAccessDenied))
+}
+
+type testAuthPlugin struct {
+	name string
+}
+
+func (p testAuthPlugin) Name() string {
+	return p.name
+}
+
+func (p testAuthPlugin) Authenticate(c *Conn, authData []byte) error {
+	return nil
+}
+
+type testAuthMethodProvider struct {
+	*InMemoryProvider
+	methods map[string]string
+}
+
+func (p testAuthMethodProvider) GetAuthMethod(username string) (string, error) {
+	return p.methods[username], nil
+}
+
+func TestAuthMethodCredentialProvider(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_CACHING_SHA2_PASSWORD, nil, nil)
+	p := testAuthMethodProvider{NewInMemoryProvider(), map[string]string{"old": mysql.AUTH_NATIVE_PASSWORD}}
+	p.AddUser("old", "secret")
+
+	methods := make(chan string, 4)
+	addr := (&testServer{svr: svr, p: p, onConn: func(co *Conn, _ Handler, err error) {
+		if err != nil {
+			methods <- err.Error()
+		} else {
+			methods <- co.authPluginName
+		}
+	}}).listen(t)
+
+	// the client answers with the default method of the server and is asked to switch
+	c, err := client.Connect(addr, "old", "secret", "")
+	require.NoError(t, err)
+	require.NoError(t, c.Ping())
+	c.Close()
+	require.Equal(t, mysql.AUTH_NATIVE_PASSWORD, <-methods)
+
+	_, err = client.Connect(addr, "old", "wrong", "")
+	require.Error(t, err)
+	<-methods
+}
@@ -6,13 +6,27 @@ import (
 	"crypto/rsa"
 	"crypto/sha1"
 	"crypto/sha256"
-	"crypto/tls"
 	"fmt"
 
 	. "github.com/atoonk/go-mysql/mysql"
 	"github.com/pingcap/errors"
 )
 
+// SwitchAuthMethod asks the client to authenticate again with another method through an AuthSwitchRequest, with a new
+// scramble, and checks its AuthSwitchResponse. An AuthPlugin can use it to fall back to a method supported by old
+// clients, e.g. 'mysql_native_password'.
+func (c *Conn) SwitchAuthMethod(authMethod string) error {
+	if !isAuthMethodSupported(authMethod) {
+		return errors.Errorf("unknown authentication plugin name '%s'", authMethod)
+	}
+	if err := c.writeAuthSwitchRequest(authMethod); err != nil {
+		return err
+	}
+	c.authPluginName = authMethod
+	c.cachingSha2FullAuth = false
+	return c.handleAuthSwitchResponse()
+}
+
 func (c *Conn) handleAuthSwitchResponse() error {
 	authData, err := c.readAuthSwitchRequestResponse()
 	if err != nil {
@@ -59,6 +73,9 @@ func (c *Conn) handleAuthSwitchResponse() error {
 		return c.compareSha256PasswordAuthData(authData, c.password)
 
 	default:
+		if p, ok := getAuthPlugin(c.authPluginName); ok {
+			return p.Authenticate(c, authData)
+		}
 		return errors.Errorf("unknown authentication plugin name '%s'", c.authPluginName)
 	}
 }
@@ -67,11 +84,12 @@ func (c *Conn) handleCachingSha2PasswordFullAuth(authData []byte) error {
 	if err := c.acquirePassword(); err != nil {
 		return err
 	}
-	if tlsConn, ok := c.Conn.Conn.(*tls.Conn); ok {
-		if !tlsConn.ConnectionState().HandshakeComplete {
-			return errors.New("incomplete TSL handshake")
-		}
-		// connection is SSL/TLS, client should send plain password
+	secure, err := c.secureTransport()
+	if err != nil {
+		return err
+	}
+	if secure {
+		// connection is SSL/TLS or a unix socket, client should send plain password
 		// deal with the trailing \NUL added for plain text password received
 		if l := len(authData); l != 0 && authData[l-1] == 0x00 {
 			authData = authData[:l-1]
@@ -95,7 +113,11 @@ func (c *Conn) handleCachingSha2PasswordFullAuth(authData []byte) error {
 		}
 		// the encrypted password
 		// decrypt
-		dbytes, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, (c.serverConf.tlsConfig.Certificates[0].PrivateKey).(*rsa.PrivateKey), authData, nil)
+		key, err := c.rsaPrivateKey()
+		if err != nil {
+			return err
+		}
+		dbytes, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, key, authData, nil)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,410 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/replication"
+)
+
+// BinlogHandler is a Handler serving the replication commands.
+type BinlogHandler interface {
+	Handler
+	ReplicationHandler
+}
+
+// Replica is a replica registered with COM_REGISTER_SLAVE.
+type Replica struct {
+	ServerID uint32
+	Host     string
+	User     string
+	Port     uint16
+	UUID     string
+}
+
+// BinlogServer emulates the parts of a MySQL master a replica relies on, on top of the binlog events provided by a
+// BinlogHandler, so that a MySQL replica or mysqlbinlog can attach to the server:
+//
+//   - the queries the replica sends before the binlog dump, e.g. SELECT @@GLOBAL.SERVER_ID or
+//     SET @master_heartbeat_period, are answered with the settings of the BinlogServer
+//   - the replicas are registered by their server id, listed by SHOW SLAVE HOSTS and SHOW REPLICAS
+//   - a dump from a binlog position starts with an artificial rotate event to that position, as MySQL sends
+//   - heartbeat events are sent when the dump is idle for the heartbeat period requested by the replica
+//
+// The events of the BinlogHandler are sent as is, they must have a checksum if Checksum is set, as the replicas
+// since MySQL 5.6 accept. A dump is ended by adding replication.ErrSyncClosed to the streamer of the handler, the
+// replica then gets an EOF packet, or any other error to send it to the replica. A BinlogServer is shared by the
+// connections, see NewHandler.
+type BinlogServer struct {
+	ServerID   uint32
+	ServerUUID string
+	// GTIDMode is the value of @@GLOBAL.GTID_MODE
+	GTIDMode bool
+	// Checksum tells the events have a CRC32 checksum, @@GLOBAL.BINLOG_CHECKSUM is then CRC32
+	Checksum bool
+	// HeartbeatPeriod is used when the replica does not set @master_heartbeat_period, zero disables the heartbeats
+	HeartbeatPeriod time.Duration
+
+	mu sync.Mutex
+	// replicas with a running dump, by server id
+	replicas map[uint32]registeredReplica
+}
+
+type registeredReplica struct {
+	Replica
+	h *binlogConnHandler
+}
+
+// NewBinlogServer creates a BinlogServer with the id and the UUID of the emulated master.
+func NewBinlogServer(serverID uint32, serverUUID string) *BinlogServer {
+	return &BinlogServer{
+		ServerID:   serverID,
+		ServerUUID: serverUUID,
+		replicas:   make(map[uint32]registeredReplica),
+	}
+}
+
+// NewHandler returns the handler of a connection, it emulates the master on top of h. Only the methods of
+// Handler and ReplicationHandler are forwarded to h.
+func (b *BinlogServer) NewHandler(h BinlogHandler) BinlogHandler {
+	return &binlogConnHandler{BinlogHandler: h, server: b, vars: make(map[string]string)}
+}
+
+// Replicas returns the replicas with a running binlog dump, sorted by server id.
+func (b *BinlogServer) Replicas() []Replica {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	replicas := make([]Replica, 0, len(b.replicas))
+	for _, r := range b.replicas {
+		replicas = append(replicas, r.Replica)
+	}
+	sort.Slice(replicas, func(i, j int) bool { return replicas[i].ServerID < replicas[j].ServerID })
+	return replicas
+}
+
+func (b *BinlogServer) checksumName() string {
+	if b.Checksum {
+		return "CRC32"
+	}
+	return "NONE"
+}
+
+func (b *BinlogServer) globalVariables() map[string]string {
+	gtidMode := "OFF"
+	if b.GTIDMode {
+		gtidMode = "ON"
+	}
+	return map[string]string{
+		"server_id":       strconv.FormatUint(uint64(b.ServerID), 10),
+		"server_uuid":     b.ServerUUID,
+		"gtid_mode":       gtidMode,
+		"binlog_checksum": b.checksumName(),
+	}
+}
+
+var (
+	setUserVarRegexp      = regexp.MustCompile(`(?i)^\s*SET\s+@(\w+)\s*=\s*(.+?)\s*;?\s*$`)
+	selectVarRegexp       = regexp.MustCompile(`(?i)^\s*SELECT\s+(@@(?:GLOBAL\.)?(\w+)|@(\w+)|UNIX_TIMESTAMP\(\))\s*;?\s*$`)
+	showVariablesRegexp   = regexp.MustCompile(`(?i)^\s*SHOW\s+(?:GLOBAL\s+)?VARIABLES\s+LIKE\s+'([^']*)'\s*;?\s*$`)
+	showReplicaHostsRegex = regexp.MustCompile(`(?i)^\s*SHOW\s+(SLAVE\s+HOSTS|REPLICAS)\s*;?\s*$`)
+)
+
+type binlogConnHandler struct {
+	BinlogHandler
+	server *BinlogServer
+
+	// user variables set by the replica
+	vars    map[string]string
+	replica Replica
+	// stopRelay stops the relay of the running dump
+	stopRelay context.CancelFunc
+}
+
+func (h *binlogConnHandler) HandleQuery(query string) (*Result, error) {
+	if m := setUserVarRegexp.FindStringSubmatch(query); m != nil {
+		name, value := strings.ToLower(m[1]), m[2]
+		if v, ok := h.server.globalVariables()[strings.TrimPrefix(strings.ToLower(value), "@@global.")]; ok && strings.HasPrefix(value, "@@") {
+			value = v
+		}
+		h.vars[name] = strings.Trim(value, `'"`)
+		return &Result{}, nil
+	}
+
+	if m := selectVarRegexp.FindStringSubmatch(query); m != nil {
+		var value interface{}
+		switch {
+		case m[2] != "":
+			v, ok := h.server.globalVariables()[strings.ToLower(m[2])]
+			if !ok {
+				return h.BinlogHandler.HandleQuery(query)
+			}
+			value = v
+		case m[3] != "":
+			if v, ok := h.vars[strings.ToLower(m[3])]; ok {
+				value = v
+			}
+		default:
+			value = time.Now().Unix()
+		}
+		return simpleResult([]string{m[1]}, [][]interface{}{{value}})
+	}
+
+	if m := showVariablesRegexp.FindStringSubmatch(query); m != nil {
+		vars := h.server.globalVariables()
+		names := make([]string, 0, len(vars))
+		like := likeRegexp(m[1])
+		for name := range vars {
+			if like.MatchString(name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		rows := make([][]interface{}, 0, len(names))
+		for _, name := range names {
+			rows = append(rows, []interface{}{name, vars[name]})
+		}
+		return simpleResult([]string{"Variable_name", "Value"}, rows)
+	}
+
+	if m := showReplicaHostsRegex.FindStringSubmatch(query); m != nil {
+		names := []string{"Server_id", "Host", "Port", "Master_id", "Slave_UUID"}
+		if strings.EqualFold(m[1], "REPLICAS") {
+			names = []string{"Server_Id", "Host", "Port", "Source_Id", "Replica_UUID"}
+		}
+		var rows [][]interface{}
+		for _, r := range h.server.Replicas() {
+			rows = append(rows, []interface{}{r.ServerID, r.Host, r.Port, h.server.ServerID, r.UUID})
+		}
+		return simpleResult(names, rows)
+	}
+
+	return h.BinlogHandler.HandleQuery(query)
+}
+
+func simpleResult(names []string, rows [][]interface{}) (*Result, error) {
+	rs, err := BuildSimpleTextResultset(names, rows)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Resultset: rs}, nil
+}
+
+func (h *binlogConnHandler) HandleRegisterSlave(data []byte) error {
+	r, err := parseRegisterSlave(data)
+	if err != nil {
+		return err
+	}
+	if err := h.BinlogHandler.HandleRegisterSlave(data); err != nil {
+		return err
+	}
+	h.replica = r
+	return nil
+}
+
+// parseRegisterSlave decodes COM_REGISTER_SLAVE: server id, host, user, password, port, rank and master id.
+func parseRegisterSlave(data []byte) (r Replica, err error) {
+	// prevent 'panic: runtime error: index out of range' error
+	defer func() {
+		if recover() != nil {
+			err = ErrMalformPacket
+		}
+	}()
+
+	r.ServerID = binary.LittleEndian.Uint32(data)
+	pos := 4
+	readString := func() string {
+		n := int(data[pos])
+		s := string(data[pos+1 : pos+1+n])
+		pos += 1 + n
+		return s
+	}
+	r.Host = readString()
+	r.User = readString()
+	_ = readString()
+	r.Port = binary.LittleEndian.Uint16(data[pos:])
+	return r, nil
+}
+
+func (h *binlogConnHandler) HandleBinlogDump(pos Position) (*replication.BinlogStreamer, error) {
+	s, err := h.BinlogHandler.HandleBinlogDump(pos)
+	if err != nil {
+		return nil, err
+	}
+	if pos.Pos < 4 {
+		pos.Pos = 4
+	}
+	return h.relay(s, pos), nil
+}
+
+func (h *binlogConnHandler) HandleBinlogDumpGTID(gtidSet *MysqlGTIDSet) (*replication.BinlogStreamer, error) {
+	s, err := h.BinlogHandler.HandleBinlogDumpGTID(gtidSet)
+	if err != nil {
+		return nil, err
+	}
+	// the position is only known once the handler sends its first rotate event
+	return h.relay(s, Position{}), nil
+}
+
+func (h *binlogConnHandler) heartbeatPeriod() time.Duration {
+	v, ok := h.vars["master_heartbeat_period"]
+	if !ok {
+		v, ok = h.vars["source_heartbeat_period"]
+	}
+	if !ok {
+		return h.server.HeartbeatPeriod
+	}
+	// in nanoseconds
+	ns, _ := strconv.ParseFloat(v, 64)
+	return time.Duration(ns)
+}
+
+// relay streams the events of up, starting with a fake rotate to pos, and sends heartbeats while it is idle.
+func (h *binlogConnHandler) relay(up *replication.BinlogStreamer, pos Position) *replication.BinlogStreamer {
+	down := replication.NewBinlogStreamer()
+
+	// a replica connecting again replaces its previous connection
+	if h.replica.ServerID != 0 {
+		h.replica.UUID = h.replicaUUID()
+		h.server.mu.Lock()
+		h.server.replicas[h.replica.ServerID] = registeredReplica{h.replica, h}
+		h.server.mu.Unlock()
+	}
+
+	// the relay stops once the replica stops reading, even if up has no event to send
+	dumpCtx, stop := context.WithCancel(context.Background())
+	h.stopRelay = stop
+
+	checksum, period := h.server.Checksum, h.heartbeatPeriod()
+	go func() {
+		defer h.unregister()
+		defer stop()
+
+		if pos.Name != "" {
+			if err := down.AddEventToStreamer(h.rotateEvent(pos, checksum)); err != nil {
+				up.AddErrorToStreamer(err)
+				return
+			}
+		}
+
+		for {
+			ctx, cancel := dumpCtx, context.CancelFunc(func() {})
+			if period > 0 {
+				ctx, cancel = context.WithTimeout(ctx, period)
+			}
+			ev, err := up.GetEvent(ctx)
+			cancel()
+
+			if dumpCtx.Err() != nil {
+				// the dump is over
+				up.AddErrorToStreamer(replication.ErrSyncClosed)
+				return
+			} else if err == context.DeadlineExceeded {
+				ev = h.heartbeatEvent(pos, checksum)
+			} else if err != nil {
+				down.AddErrorToStreamer(err)
+				return
+			} else {
+				pos = nextBinlogPosition(pos, ev, checksum)
+			}
+
+			if err := down.AddEventToStreamer(ev); err != nil {
+				// the dump is over, e.g. the replica is gone
+				up.AddErrorToStreamer(err)
+				return
+			}
+		}
+	}()
+	return down
+}
+
+func (h *binlogConnHandler) replicaUUID() string {
+	if v, ok := h.vars["slave_uuid"]; ok {
+		return v
+	}
+	return h.vars["replica_uuid"]
+}
+
+func (h *binlogConnHandler) binlogDumpEnded() {
+	if h.stopRelay != nil {
+		h.stopRelay()
+		h.stopRelay = nil
+	}
+}
+
+func (h *binlogConnHandler) unregister() {
+	h.server.mu.Lock()
+	defer h.server.mu.Unlock()
+	if h.server.replicas[h.replica.ServerID].h == h {
+		delete(h.server.replicas, h.replica.ServerID)
+	}
+}
+
+// nextBinlogPosition returns the position following an event sent to the replica.
+func nextBinlogPosition(pos Position, ev *replication.BinlogEvent, checksum bool) Position {
+	data := ev.RawData
+	if len(data) < replication.EventHeaderSize {
+		return pos
+	}
+	if replication.EventType(data[4]) == replication.ROTATE_EVENT {
+		body := data[replication.EventHeaderSize:]
+		if checksum && len(body) >= 8+replication.BinlogChecksumLength {
+			body = body[:len(body)-replication.BinlogChecksumLength]
+		}
+		if len(body) >= 8 {
+			return Position{Name: string(body[8:]), Pos: uint32(binary.LittleEndian.Uint64(body))}
+		}
+	}
+	if logPos := binary.LittleEndian.Uint32(data[13:]); logPos != 0 {
+		pos.Pos = logPos
+	}
+	return pos
+}
+
+func (h *binlogConnHandler) rotateEvent(pos Position, checksum bool) *replication.BinlogEvent {
+	body := make([]byte, 8, 8+len(pos.Name))
+	binary.LittleEndian.PutUint64(body, uint64(pos.Pos))
+	body = append(body, pos.Name...)
+	return h.artificialEvent(replication.ROTATE_EVENT, 0, body, checksum)
+}
+
+func (h *binlogConnHandler) heartbeatEvent(pos Position, checksum bool) *replication.BinlogEvent {
+	return h.artificialEvent(replication.HEARTBEAT_EVENT, pos.Pos, []byte(pos.Name), checksum)
+}
+
+// artificialEvent builds an event generated by the master for the replica only, it is not in the binlog.
+func (h *binlogConnHandler) artificialEvent(tp replication.EventType, logPos uint32, body []byte, checksum bool) *replication.BinlogEvent {
+	size := replication.EventHeaderSize + len(body)
+	if checksum {
+		size += replication.BinlogChecksumLength
+	}
+
+	header := &replication.EventHeader{
+		EventType: tp,
+		ServerID:  h.server.ServerID,
+		EventSize: uint32(size),
+		LogPos:    logPos,
+		Flags:     replication.LOG_EVENT_ARTIFICIAL_F,
+	}
+
+	data := make([]byte, replication.EventHeaderSize, size)
+	// the timestamp is 0 for the artificial events
+	data[4] = byte(tp)
+	binary.LittleEndian.PutUint32(data[5:], header.ServerID)
+	binary.LittleEndian.PutUint32(data[9:], header.EventSize)
+	binary.LittleEndian.PutUint32(data[13:], header.LogPos)
+	binary.LittleEndian.PutUint16(data[17:], header.Flags)
+	data = append(data, body...)
+	if checksum {
+		data = append(data, Uint32ToBytes(crc32.ChecksumIEEE(data))...)
+	}
+
+	return &replication.BinlogEvent{RawData: data, Header: header}
+}
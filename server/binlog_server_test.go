@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+	"time"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/packet"
+	"github.com/atoonk/go-mysql/replication"
+	mockconn "github.com/atoonk/go-mysql/test_util/conn"
+	"github.com/stretchr/testify/require"
+)
+
+type testBinlogHandler struct {
+	EmptyReplicationHandler
+	s *replication.BinlogStreamer
+}
+
+func (h testBinlogHandler) HandleRegisterSlave(data []byte) error {
+	return nil
+}
+
+func (h testBinlogHandler) HandleBinlogDump(pos mysql.Position) (*replication.BinlogStreamer, error) {
+	return h.s, nil
+}
+
+func registerSlavePacket(serverID uint32, host, user string, port uint16) []byte {
+	data := mysql.Uint32ToBytes(serverID)
+	data = append(data, byte(len(host)))
+	data = append(data, host...)
+	data = append(data, byte(len(user)))
+	data = append(data, user...)
+	data = append(data, 0)
+	data = append(data, byte(port), byte(port>>8))
+	// rank and master id
+	return append(data, 0, 0, 0, 0, 0, 0, 0, 0)
+}
+
+func TestBinlogServerQueries(t *testing.T) {
+	b := NewBinlogServer(1, "3e11fa47-71ca-11e1-9e33-c80aa9429562")
+	b.Checksum = true
+	h := b.NewHandler(testBinlogHandler{})
+
+	value := func(query string) interface{} {
+		r, err := h.HandleQuery(query)
+		require.NoError(t, err, query)
+		require.Len(t, r.RowDatas, 1)
+		v, err := r.RowDatas[0].ParseText(r.Fields, nil)
+		require.NoError(t, err)
+		return v[0].Value()
+	}
+
+	require.Equal(t, []byte("1"), value("SELECT @@GLOBAL.SERVER_ID"))
+	require.Equal(t, []byte(b.ServerUUID), value("SELECT @@global.server_uuid"))
+	require.Equal(t, []byte("OFF"), value("SELECT @@GLOBAL.GTID_MODE"))
+	require.Nil(t, value("SELECT @master_binlog_checksum"))
+
+	r, err := h.HandleQuery("SET @master_binlog_checksum= @@global.binlog_checksum")
+	require.NoError(t, err)
+	require.Equal(t, &mysql.Result{}, r)
+	require.Equal(t, []byte("CRC32"), value("SELECT @master_binlog_checksum"))
+
+	r, err = h.HandleQuery("SHOW VARIABLES LIKE 'SERVER_%'")
+	require.NoError(t, err)
+	require.Len(t, r.RowDatas, 2)
+	v, err := r.RowDatas[0].ParseText(r.Fields, nil)
+	require.NoError(t, err)
+	require.Equal(t, []byte("server_id"), v[0].Value())
+
+	_, err = h.HandleQuery("SELECT 1")
+	require.Error(t, err)
+}
+
+func TestBinlogServerDump(t *testing.T) {
+	b := NewBinlogServer(1, "3e11fa47-71ca-11e1-9e33-c80aa9429562")
+	b.Checksum = true
+	up := replication.NewBinlogStreamer()
+	h := b.NewHandler(testBinlogHandler{s: up})
+
+	require.NoError(t, h.HandleRegisterSlave(registerSlavePacket(2, "replica", "repl", 3306)))
+	_, err := h.HandleQuery("SET @master_heartbeat_period= 50000000")
+	require.NoError(t, err)
+
+	down, err := h.HandleBinlogDump(mysql.Position{Name: "mysql-bin.000002", Pos: 120})
+	require.NoError(t, err)
+	require.Equal(t, []Replica{{ServerID: 2, Host: "replica", User: "repl", Port: 3306}}, b.Replicas())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the artificial rotate to the dump position
+	ev, err := down.GetEvent(ctx)
+	require.NoError(t, err)
+	data := ev.RawData
+	require.Equal(t, byte(replication.ROTATE_EVENT), data[4])
+	require.Equal(t, uint32(len(data)), binary.LittleEndian.Uint32(data[9:]))
+	require.Equal(t, replication.LOG_EVENT_ARTIFICIAL_F, binary.LittleEndian.Uint16(data[17:]))
+	require.Equal(t, uint64(120), binary.LittleEndian.Uint64(data[19:]))
+	require.Equal(t, "mysql-bin.000002", string(data[27:len(data)-4]))
+	require.Equal(t, crc32.ChecksumIEEE(data[:len(data)-4]), binary.LittleEndian.Uint32(data[len(data)-4:]))
+
+	// an event of the handler ending at 200, then a heartbeat at this position
+	raw := make([]byte, 23)
+	raw[4] = byte(replication.QUERY_EVENT)
+	binary.LittleEndian.PutUint32(raw[13:], 200)
+	require.NoError(t, up.AddEventToStreamer(&replication.BinlogEvent{RawData: raw}))
+	ev, err = down.GetEvent(ctx)
+	require.NoError(t, err)
+	require.Equal(t, raw, ev.RawData)
+
+	ev, err = down.GetEvent(ctx)
+	require.NoError(t, err)
+	data = ev.RawData
+	require.Equal(t, byte(replication.HEARTBEAT_EVENT), data[4])
+	require.Equal(t, uint32(200), binary.LittleEndian.Uint32(data[13:]))
+	require.Equal(t, "mysql-bin.000002", string(data[19:len(data)-4]))
+
+	// the end of the dump is passed on
+	up.AddErrorToStreamer(replication.ErrSyncClosed)
+	for {
+		if _, err = down.GetEvent(ctx); err != nil {
+			break
+		}
+	}
+	require.Equal(t, replication.ErrSyncClosed, err)
+	require.Eventually(t, func() bool { return len(b.Replicas()) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestBinlogServerDumpDisconnect(t *testing.T) {
+	b := NewBinlogServer(1, "3e11fa47-71ca-11e1-9e33-c80aa9429562")
+	up := replication.NewBinlogStreamer()
+	h := b.NewHandler(testBinlogHandler{s: up})
+	require.NoError(t, h.HandleRegisterSlave(registerSlavePacket(2, "replica", "repl", 3306)))
+
+	down, err := h.HandleBinlogDump(mysql.Position{Name: "mysql-bin.000002", Pos: 120})
+	require.NoError(t, err)
+
+	// the replica is gone while up has nothing to send, without heartbeats
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := &Conn{Conn: packet.NewConn(&mockconn.MockConn{MultiWrite: true}), h: h, ctx: ctx}
+	c.SetCapability(mysql.CLIENT_PROTOCOL_41)
+	require.Error(t, c.WriteValue(down))
+
+	// the relay stops and tells up
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = up.GetEvent(ctx)
+	require.Equal(t, replication.ErrSyncClosed, err)
+	require.Eventually(t, func() bool { return len(b.Replicas()) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestConnWriteBinlogDumpEnd(t *testing.T) {
+	clientConn := &mockconn.MockConn{}
+	c := &Conn{Conn: packet.NewConn(clientConn)}
+	c.SetCapability(mysql.CLIENT_PROTOCOL_41)
+
+	require.NoError(t, c.writeBinlogDumpEnd(replication.ErrSyncClosed))
+	require.Equal(t, byte(mysql.EOF_HEADER), clientConn.WriteBuffered[4])
+
+	require.NoError(t, c.writeBinlogDumpEnd(errors.New("purged")))
+	require.Equal(t, byte(mysql.ERR_HEADER), clientConn.WriteBuffered[4])
+	require.Equal(t, uint16(mysql.ER_MASTER_FATAL_ERROR_READING_BINLOG), binary.LittleEndian.Uint16(clientConn.WriteBuffered[5:]))
+}
+
+func TestParseBinlogDumpFlags(t *testing.T) {
+	data := mysql.Uint32ToBytes(4)
+	data = append(data, byte(replication.BINLOG_DUMP_NON_BLOCK), 0)
+	data = append(data, mysql.Uint32ToBytes(2)...)
+	data = append(data, "mysql-bin.000001"...)
+	pos, flags, err := parseBinlogDump(data)
+	require.NoError(t, err)
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000001", Pos: 4}, pos)
+	require.Equal(t, replication.BINLOG_DUMP_NON_BLOCK, flags)
+
+	gset, err := mysql.ParseMysqlGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5")
+	require.NoError(t, err)
+	gtidData := gset.Encode()
+	data = []byte{byte(replication.BINLOG_DUMP_NON_BLOCK), 0}
+	data = append(data, mysql.Uint32ToBytes(2)...)
+	data = append(data, mysql.Uint32ToBytes(0)...)
+	data = append(data, mysql.Uint64ToBytes(4)...)
+	data = append(data, mysql.Uint32ToBytes(uint32(len(gtidData)))...)
+	data = append(data, gtidData...)
+	gtidSet, flags, err := parseBinlogDumpGTID(data)
+	require.NoError(t, err)
+	require.Equal(t, gset.String(), gtidSet.String())
+	require.Equal(t, replication.BINLOG_DUMP_NON_BLOCK, flags)
+}
+
+func TestConnWriteNonBlockingBinlogDump(t *testing.T) {
+	clientConn := &mockconn.MockConn{MultiWrite: true}
+	c := &Conn{Conn: packet.NewConn(clientConn)}
+	c.SetCapability(mysql.CLIENT_PROTOCOL_41)
+
+	s := replication.NewBinlogStreamer()
+	raw := make([]byte, 19)
+	raw[4] = byte(replication.QUERY_EVENT)
+	require.NoError(t, s.AddEventToStreamer(&replication.BinlogEvent{RawData: raw}))
+
+	// the dump ends once the queued events are sent, instead of waiting for more
+	require.NoError(t, c.WriteValue(binlogDumpResponse(s, nil, true)))
+	require.Equal(t, append([]byte{mysql.OK_HEADER}, raw...), clientConn.WriteBuffered[4:24])
+	require.Equal(t, byte(mysql.EOF_HEADER), clientConn.WriteBuffered[28])
+}
+
+type countingConn struct {
+	mockconn.MockConn
+	writes int
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	c.writes++
+	return c.MockConn.Write(p)
+}
+
+func TestConnWriteBinlogBatch(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	svr.SetBinlogBatch(BinlogBatch{Size: 1024})
+	clientConn := &countingConn{MockConn: mockconn.MockConn{MultiWrite: true}}
+	c := &Conn{Conn: packet.NewConn(clientConn), serverConf: svr}
+	c.SetCapability(mysql.CLIENT_PROTOCOL_41)
+
+	s := replication.NewBinlogStreamer()
+	var want []byte
+	for i := 0; i < 3; i++ {
+		raw := make([]byte, 19)
+		raw[4] = byte(replication.QUERY_EVENT)
+		require.NoError(t, s.AddEventToStreamer(&replication.BinlogEvent{RawData: raw}))
+		want = append(want, 20, 0, 0, byte(i+1), mysql.OK_HEADER)
+		want = append(want, raw...)
+	}
+	s.AddErrorToStreamer(replication.ErrSyncClosed)
+
+	// the queued events are written at once, then the end of the dump
+	c.Sequence = 1
+	require.NoError(t, c.WriteValue(s))
+	require.Equal(t, 2, clientConn.writes)
+	require.Equal(t, want, clientConn.WriteBuffered[:len(want)])
+	require.Equal(t, byte(mysql.EOF_HEADER), clientConn.WriteBuffered[len(want)+4])
+}
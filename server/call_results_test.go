@@ -0,0 +1,112 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+type testCallHandler struct {
+	EmptyHandler
+}
+
+func (h testCallHandler) callResults(binary bool, args []interface{}) (*mysql.Result, error) {
+	rows, err := mysql.BuildSimpleResultset([]string{"id"}, [][]interface{}{{int64(1)}, {int64(2)}}, binary)
+	if err != nil {
+		return nil, err
+	}
+	r := &mysql.Result{Resultset: rows}
+	last := r
+	if binary {
+		params, err := mysql.BuildSimpleBinaryResultset([]string{"out"}, [][]interface{}{{args[0]}})
+		if err != nil {
+			return nil, err
+		}
+		last.Next = &mysql.Result{Status: mysql.SERVER_PS_OUT_PARAMS, Resultset: params}
+		last = last.Next
+	}
+	last.Next = &mysql.Result{AffectedRows: 3}
+	return r, nil
+}
+
+func (h testCallHandler) HandleQuery(query string) (*mysql.Result, error) {
+	return h.callResults(false, nil)
+}
+
+func (h testCallHandler) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	return 1, 0, nil, nil
+}
+
+func (h testCallHandler) HandleStmtExecute(context interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	return h.callResults(true, args)
+}
+
+func TestCallResults(t *testing.T) {
+	p := NewInMemoryProvider()
+	p.AddUser("root", "123")
+
+	addr := serveTestConns(t, nil, p, testCallHandler{})
+
+	c, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+	defer c.Close()
+
+	// text protocol: a result set and the final OK
+	r, err := c.Execute("CALL p()")
+	require.NoError(t, err)
+	require.Equal(t, 2, r.RowNumber())
+	require.True(t, c.HasMoreResults())
+
+	r, err = c.NextResult()
+	require.NoError(t, err)
+	require.Nil(t, r.Resultset)
+	require.Equal(t, uint64(3), r.AffectedRows)
+	require.False(t, c.HasMoreResults())
+
+	r, err = c.NextResult()
+	require.NoError(t, err)
+	require.Nil(t, r)
+
+	// binary protocol: a result set, the OUT parameters and the final OK
+	r, err = c.Execute("CALL p(?)", int64(42))
+	require.NoError(t, err)
+	require.Equal(t, 2, r.RowNumber())
+	require.Zero(t, r.Status&mysql.SERVER_PS_OUT_PARAMS)
+
+	r, err = c.NextResult()
+	require.NoError(t, err)
+	require.NotZero(t, r.Status&mysql.SERVER_PS_OUT_PARAMS)
+	v, err := r.GetInt(0, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), v)
+
+	r, err = c.NextResult()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), r.AffectedRows)
+	require.Zero(t, r.Status&mysql.SERVER_PS_OUT_PARAMS)
+
+	r, err = c.NextResult()
+	require.NoError(t, err)
+	require.Nil(t, r)
+
+	// the statement is closed once all its results are read, the connection is usable again
+	r, err = c.Execute("CALL p()")
+	require.NoError(t, err)
+	require.Equal(t, 2, r.RowNumber())
+	require.True(t, r.HasMoreResults())
+
+	// the results left unread are discarded by the next command
+	r, err = c.Execute("CALL p(?)", int64(7))
+	require.NoError(t, err)
+	require.Equal(t, 2, r.RowNumber())
+	require.True(t, r.HasMoreResults())
+	require.NoError(t, c.Ping())
+	require.False(t, c.HasMoreResults())
+
+	r, err = c.Execute("CALL p()")
+	require.NoError(t, err)
+	require.Equal(t, 2, r.RowNumber())
+}
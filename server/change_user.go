@@ -0,0 +1,117 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+type ChangeUserHandler interface {
+	//handle COM_CHANGE_USER, called once the new user is authenticated and the database is selected,
+	//the handler should reset any state bound to the previous session
+	HandleChangeUser(user string, db string) error
+}
+
+// handleChangeUser re-authenticates the client as the user of the COM_CHANGE_USER packet.
+// If it fails, the previous user stays logged in, as MySQL does.
+func (c *Conn) handleChangeUser(data []byte) error {
+	user, password, db := c.user, c.password, c.db
+	charset, authPluginName, attributes := c.charset, c.authPluginName, c.attributes
+
+	if err := c.changeUser(data); err != nil {
+		err = c.accessDeniedError(err)
+		c.user, c.password, c.db = user, password, db
+		c.charset, c.authPluginName, c.attributes = charset, authPluginName, attributes
+		return err
+	}
+
+	c.setOwner()
+
+	// like a new session, the prepared statements of the previous user are closed
+	if err := c.closeStmts(); err != nil {
+		return err
+	}
+
+	if h, ok := c.h.(ChangeUserHandler); ok {
+		return h.HandleChangeUser(c.user, c.db)
+	}
+	return nil
+}
+
+func (c *Conn) changeUser(data []byte) error {
+	authData, db, err := c.decodeChangeUser(data)
+	if err != nil {
+		return err
+	}
+
+	c.password = ""
+	c.cachingSha2FullAuth = false
+
+	cont, err := c.handleAuthMatch()
+	if err != nil {
+		return err
+	}
+	if cont {
+		if err := c.compareAuthData(c.authPluginName, authData); err != nil {
+			return err
+		}
+	}
+
+	if err := c.reserveConnection(); err != nil {
+		return err
+	}
+	if db != "" {
+		if err := c.handler().UseDBContext(c.Context(), db); err != nil {
+			c.abortConnection()
+			return err
+		}
+	}
+	c.commitConnection()
+	c.db = db
+	return nil
+}
+
+func (c *Conn) decodeChangeUser(data []byte) (authData []byte, db string, err error) {
+	// prevent 'panic: runtime error: index out of range' error
+	defer func() {
+		if recover() != nil {
+			err = NewDefaultError(ER_MALFORMED_PACKET)
+		}
+	}()
+
+	pos, _ := c.readUserName(data, 0)
+
+	// unlike the handshake response, the auth data is never length encoded
+	if c.capability&CLIENT_SECURE_CONNECTION != 0 {
+		authLen := int(data[pos])
+		pos++
+		authData = data[pos : pos+authLen]
+		pos += authLen
+	} else {
+		authLen := bytes.IndexByte(data[pos:], 0x00)
+		authData = data[pos : pos+authLen]
+		pos += authLen + 1
+	}
+
+	db = string(data[pos : pos+bytes.IndexByte(data[pos:], 0x00)])
+	pos += len(db) + 1
+
+	// character set, auth plugin name and attributes are only sent by recent clients
+	c.authPluginName = AUTH_NATIVE_PASSWORD
+	if pos+2 <= len(data) {
+		c.charset = binary.LittleEndian.Uint16(data[pos:])
+		pos += 2
+		if pos < len(data) {
+			pos = c.readPluginName(data, pos)
+		}
+	}
+
+	if c.capability&CLIENT_CONNECT_ATTRS > 0 && pos < len(data) {
+		if _, err = c.readAttributes(data, pos); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return authData, db, nil
+}
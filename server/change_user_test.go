@@ -0,0 +1,77 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/packet"
+	mockconn "github.com/atoonk/go-mysql/test_util/conn"
+	"github.com/stretchr/testify/require"
+)
+
+type testChangeUserHandler struct {
+	EmptyHandler
+	user, db string
+	closed   int
+}
+
+func (h *testChangeUserHandler) HandleStmtClose(context interface{}) error {
+	h.closed++
+	return nil
+}
+
+func (h *testChangeUserHandler) HandleChangeUser(user string, db string) error {
+	h.user, h.db = user, db
+	return nil
+}
+
+func changeUserCommand(user string, authData []byte, db string) []byte {
+	data := append([]byte{mysql.COM_CHANGE_USER}, user...)
+	data = append(data, 0, byte(len(authData)))
+	data = append(data, authData...)
+	data = append(data, db...)
+	data = append(data, 0)
+	// charset, auth plugin name
+	data = append(data, mysql.DEFAULT_COLLATION_ID, 0)
+	return append(append(data, mysql.AUTH_NATIVE_PASSWORD...), 0)
+}
+
+func TestChangeUser(t *testing.T) {
+	p := NewInMemoryProvider()
+	p.AddUser("root", "")
+	p.AddUser("bob", "secret")
+
+	h := &testChangeUserHandler{}
+	c := &Conn{
+		Conn:               packet.NewConn(&mockconn.MockConn{}),
+		serverConf:         NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil),
+		credentialProvider: p,
+		h:                  h,
+		user:               "root",
+		db:                 "test",
+		salt:               mysql.RandomBuf(20),
+		stmts:              map[uint32]*Stmt{1: {ID: 1}},
+	}
+	c.SetCapability(mysql.CLIENT_PROTOCOL_41 | mysql.CLIENT_SECURE_CONNECTION | mysql.CLIENT_PLUGIN_AUTH)
+
+	// wrong password, the previous user is kept
+	v := c.dispatch(changeUserCommand("bob", mysql.CalcPassword(c.salt, []byte("wrong")), "other"))
+	require.EqualValues(t, mysql.ER_ACCESS_DENIED_ERROR, v.(*mysql.MyError).Code)
+	require.Equal(t, "root", c.GetUser())
+	require.Equal(t, "test", c.GetDB())
+	require.Len(t, c.stmts, 1)
+	require.Empty(t, h.user)
+
+	v = c.dispatch(changeUserCommand("bob", mysql.CalcPassword(c.salt, []byte("secret")), "other"))
+	require.Nil(t, v)
+	require.Equal(t, "bob", c.GetUser())
+	require.Equal(t, "other", c.GetDB())
+	require.Empty(t, c.stmts)
+	require.Equal(t, 1, h.closed)
+	require.Equal(t, "bob", h.user)
+	require.Equal(t, "other", h.db)
+
+	v = c.dispatch([]byte{mysql.COM_CHANGE_USER, 'b'})
+	require.EqualValues(t, mysql.ER_MALFORMED_PACKET, v.(*mysql.MyError).Code)
+	require.Equal(t, "bob", c.GetUser())
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+var setCharsetRegexp = regexp.MustCompile(
+	"(?i)^\\s*SET\\s+(NAMES|CHARACTER\\s+SET|CHARSET)\\s+['\"`]?(\\w+)['\"`]?(?:\\s+COLLATE\\s+['\"`]?(\\w+)['\"`]?)?\\s*;?\\s*$")
+
+type CharsetHandler interface {
+	//handle SET NAMES and SET CHARACTER SET, called once the character set and collation are validated,
+	//the connection keeps its previous character set if an error is returned
+	HandleSetCharset(charset string, collationID uint16) error
+}
+
+// nonClientCharsets are the character sets MySQL refuses for the connections, their ASCII characters aren't
+// single bytes.
+var nonClientCharsets = map[string]bool{
+	"ucs2":    true,
+	"utf16":   true,
+	"utf16le": true,
+	"utf32":   true,
+}
+
+// CharsetName returns the name of the character set negotiated by the client in the handshake or with SET NAMES,
+// or "" if the server does not know it. The column names and error messages are converted to it by the server,
+// the queries and the values of the rows are passed as is between the client and the handler, see DecodeString.
+func (c *Conn) CharsetName() string {
+	if cs, ok := CharsetOfCollation(c.charset); ok {
+		return cs.Name
+	}
+	return ""
+}
+
+// handleSetCharsetQuery switches the connection to the character set of SET NAMES and SET CHARACTER SET,
+// ok is false for the other queries.
+func (c *Conn) handleSetCharsetQuery(query string) (r *Result, ok bool, err error) {
+	m := setCharsetRegexp.FindStringSubmatch(query)
+	if m == nil {
+		return nil, false, nil
+	}
+	if m[3] != "" && !strings.EqualFold(m[1], "NAMES") {
+		// COLLATE is only allowed by SET NAMES, let the handler report it
+		return nil, false, nil
+	}
+
+	var cs *Charset
+	if strings.EqualFold(m[2], "DEFAULT") {
+		id := DEFAULT_COLLATION_ID
+		if c.serverConf != nil {
+			id = c.serverConf.collationId
+		}
+		cs, _ = CharsetByCollationID(id)
+	} else {
+		cs, _ = CharsetByName(m[2])
+	}
+	if cs == nil {
+		return nil, true, NewDefaultError(ER_UNKNOWN_CHARACTER_SET, m[2])
+	}
+	if nonClientCharsets[cs.Name] {
+		return nil, true, NewDefaultError(ER_WRONG_VALUE_FOR_VAR, "character_set_client", cs.Name)
+	}
+
+	collationID := uint16(cs.DefaultCollationID)
+	if m[3] != "" {
+		co, ok := CollationByName(m[3])
+		if !ok {
+			return nil, true, NewDefaultError(ER_UNKNOWN_COLLATION, m[3])
+		}
+		if co.Charset != cs.Name {
+			return nil, true, NewDefaultError(ER_COLLATION_CHARSET_MISMATCH, co.Name, cs.Name)
+		}
+		collationID = co.ID
+	}
+
+	if h, ok := c.h.(CharsetHandler); ok {
+		if err := h.HandleSetCharset(cs.Name, collationID); err != nil {
+			return nil, true, err
+		}
+	}
+	c.charset = collationID
+	return &Result{}, true, nil
+}
+
+// encodeString converts a UTF-8 string of the server, e.g. an error message, to the character set of the client.
+func (c *Conn) encodeString(s []byte) []byte {
+	if cs, ok := CharsetOfCollation(c.charset); ok {
+		return cs.Encode(s)
+	}
+	return s
+}
+
+// DecodeString converts a string sent by the client, e.g. a query or a parameter of a statement, from the character
+// set of the connection to UTF-8. It is returned as is if the character set is unknown.
+func (c *Conn) DecodeString(s []byte) []byte {
+	if cs, ok := CharsetOfCollation(c.charset); ok {
+		return cs.Decode(s)
+	}
+	return s
+}
+
+// appendField appends a column definition to data with its names in the character set of the client.
+func (c *Conn) appendField(data []byte, f *Field) []byte {
+	cs, ok := CharsetOfCollation(c.charset)
+	if f == nil || f.Data != nil || !ok || cs.Encoding == nil {
+		return f.AppendDump(data)
+	}
+
+	e := *f
+	e.Schema = cs.Encode(f.Schema)
+	e.Table = cs.Encode(f.Table)
+	e.OrgTable = cs.Encode(f.OrgTable)
+	e.Name = cs.Encode(f.Name)
+	e.OrgName = cs.Encode(f.OrgName)
+	return e.AppendDump(data)
+}
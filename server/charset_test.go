@@ -0,0 +1,88 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/packet"
+	mockconn "github.com/atoonk/go-mysql/test_util/conn"
+	"github.com/stretchr/testify/require"
+)
+
+type testCharsetHandler struct {
+	EmptyHandler
+	charset     string
+	collationID uint16
+	err         error
+}
+
+func (h *testCharsetHandler) HandleSetCharset(charset string, collationID uint16) error {
+	h.charset, h.collationID = charset, collationID
+	return h.err
+}
+
+func TestSetCharset(t *testing.T) {
+	h := &testCharsetHandler{}
+	c := &Conn{h: h, charset: uint16(mysql.DEFAULT_COLLATION_ID)}
+
+	cases := []struct {
+		query       string
+		charset     string
+		collationID uint16
+	}{
+		{"SET NAMES latin1", "latin1", 8},
+		{"set names 'utf8mb4' collate 'utf8mb4_bin';", "utf8mb4", 46},
+		{"SET CHARACTER SET cp1251", "cp1251", 51},
+		{"SET NAMES utf8mb4 COLLATE utf8mb4_0900_ai_ci", "utf8mb4", 255},
+		{"SET NAMES utf8mb4 COLLATE utf8mb4_0900_as_cs", "utf8mb4", 278},
+		{"SET NAMES DEFAULT", "utf8", uint16(mysql.DEFAULT_COLLATION_ID)},
+	}
+	for _, tc := range cases {
+		r, err := c.handleQuery(tc.query)
+		require.NoError(t, err, tc.query)
+		require.Equal(t, &mysql.Result{}, r)
+		require.Equal(t, tc.charset, h.charset)
+		require.Equal(t, tc.collationID, h.collationID)
+		require.Equal(t, tc.collationID, c.Charset())
+		require.Equal(t, tc.charset, c.CharsetName())
+	}
+
+	for query, code := range map[string]uint16{
+		"SET NAMES klingon":                    mysql.ER_UNKNOWN_CHARACTER_SET,
+		"SET NAMES latin1 COLLATE klingon_ci":  mysql.ER_UNKNOWN_COLLATION,
+		"SET NAMES latin1 COLLATE utf8mb4_bin": mysql.ER_COLLATION_CHARSET_MISMATCH,
+		"SET NAMES utf16":                      mysql.ER_WRONG_VALUE_FOR_VAR,
+	} {
+		_, err := c.handleQuery(query)
+		require.EqualValues(t, code, err.(*mysql.MyError).Code, query)
+	}
+
+	// COLLATE is only valid with SET NAMES, the query is passed to the handler
+	h.charset = ""
+	_, err := c.handleQuery("SET CHARACTER SET latin1 COLLATE latin1_bin")
+	require.Error(t, err)
+	require.Empty(t, h.charset)
+
+	// an error of the handler keeps the character set
+	h.err = errors.New("unsupported")
+	_, err = c.handleQuery("SET NAMES latin1")
+	require.Equal(t, h.err, err)
+	require.Equal(t, "utf8", c.CharsetName())
+}
+
+func TestConnWriteCharset(t *testing.T) {
+	clientConn := &mockconn.MockConn{}
+	c := &Conn{Conn: packet.NewConn(clientConn), charset: 8}
+
+	require.NoError(t, c.writeError(mysql.NewError(mysql.ER_UNKNOWN_ERROR, "café")))
+	require.Equal(t, []byte{7, 0, 0, 0, mysql.ERR_HEADER, 0x51, 0x04, 'c', 'a', 'f', 0xe9}, clientConn.WriteBuffered)
+
+	clientConn.MultiWrite = true
+	clientConn.WriteBuffered = nil
+	require.NoError(t, c.writeFieldList([]*mysql.Field{{Name: []byte("é")}}))
+	field := (&mysql.Field{Name: []byte{0xe9}}).Dump()
+	require.Equal(t, field, clientConn.WriteBuffered[4:4+len(field)])
+
+	require.Equal(t, []byte("café"), c.DecodeString([]byte{'c', 'a', 'f', 0xe9}))
+}
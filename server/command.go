@@ -2,7 +2,10 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
 	"fmt"
+	"time"
 
 	. "github.com/atoonk/go-mysql/mysql"
 	"github.com/atoonk/go-mysql/replication"
@@ -21,7 +24,9 @@ type Handler interface {
 	//context will be used later for statement execute
 	HandleStmtPrepare(query string) (params int, columns int, context interface{}, err error)
 	//handle COM_STMT_EXECUTE, context is the previous one set in prepare
-	//query is the statement prepare query, and args is the params for this statement
+	//query is the statement prepare query, and args is the params for this statement: integers (unsigned ones
+	//as uint types), float32/float64, mysql.Decimal with its scale, uint64 for BIT, time.Time for dates (UTC, zero
+	//for '0000-00-00'), time.Duration for TIME, json.RawMessage for JSON, []byte for strings and blobs, nil for NULL
 	HandleStmtExecute(context interface{}, query string, args []interface{}) (*Result, error)
 	//handle COM_STMT_CLOSE, context is the previous one set in prepare
 	//this handler has no response
@@ -50,25 +55,75 @@ func (c *Conn) HandleCommand() error {
 		return err
 	}
 
-	fmt.Println("going to dispatch")
+	return c.handlePacket(data)
+}
+
+// handlePacket dispatches the command read by HandleCommand and writes its response.
+func (c *Conn) handlePacket(data []byte) error {
+	c.logger.Debugf("dispatch command %d", data[0])
+	if c.metrics != nil {
+		c.metrics.CommandDispatched(data[0])
+		defer func(start time.Time) { c.metrics.CommandLatency(data[0], time.Since(start)) }(time.Now())
+	}
+	start := time.Now()
+	query := c.auditQuery(data)
+	ctx, done := c.startCommand()
+	c.startProcess(data)
 	v := c.dispatch(data)
-	fmt.Println("dispatched")
+	if _, ok := v.(error); ok {
+		if ctx.Err() == context.DeadlineExceeded {
+			v = NewDefaultError(ER_QUERY_TIMEOUT)
+		} else if ctx.Err() == context.Canceled && !c.Closed() {
+			v = NewDefaultError(ER_QUERY_INTERRUPTED)
+		}
+	}
 
-	fmt.Println("going to write value")
-	err = c.WriteValue(v)
-	fmt.Println("wrote value")
+	err := c.WriteValue(v)
+	done()
+	c.endProcess()
+	c.auditStatement(data[0], query, v, start)
 
 	if c.Conn != nil {
 		c.ResetSequence()
 	}
 
 	if err != nil {
+		c.logger.Errorf("write response of command %d failed: %v", data[0], err)
 		c.Close()
 		c.Conn = nil
 	}
 	return err
 }
 
+// handleQuery runs a statement of COM_QUERY, the KILL statements and the ones of the process list emulation
+// are handled by the server itself. SHOW WARNINGS is answered with the warnings of the previous statement,
+// without reaching the query interceptors.
+func (c *Conn) handleQuery(query string) (*Result, error) {
+	if r, ok, err := c.handleShowWarningsQuery(query); ok {
+		return r, err
+	}
+	c.clearWarnings()
+	r, err := c.interceptQuery(query, c.dispatchQuery)
+	c.recordWarnings(r, err)
+	return r, err
+}
+
+func (c *Conn) dispatchQuery(ctx context.Context, query string) (*Result, error) {
+	if connID, queryOnly, ok := parseKillQuery(query); ok {
+		return nil, c.handleKill(connID, queryOnly)
+	}
+	if r, ok, err := c.handleProcessListQuery(query); ok {
+		return r, err
+	}
+	if r, ok, err := c.handleSetCharsetQuery(query); ok {
+		return r, err
+	}
+	if r, ok, err := c.handleSemiSyncQuery(query); ok {
+		return r, err
+	}
+	return c.handler().HandleQueryContext(ctx, query)
+}
+
 func (c *Conn) dispatch(data []byte) interface{} {
 	cmd := data[0]
 	data = data[1:]
@@ -79,7 +134,15 @@ func (c *Conn) dispatch(data []byte) interface{} {
 		c.Conn = nil
 		return noResponse{}
 	case COM_QUERY:
-		if r, err := c.h.HandleQuery(hack.String(data)); err != nil {
+		if err := c.countQuery(); err != nil {
+			return err
+		}
+		if c.HasCapability(CLIENT_MULTI_STATEMENTS) {
+			if queries := splitMultiStatements(hack.String(data)); len(queries) > 1 {
+				return c.handleMultiStatements(queries)
+			}
+		}
+		if r, err := c.handleQuery(hack.String(data)); err != nil {
 			return err
 		} else {
 			return r
@@ -87,9 +150,11 @@ func (c *Conn) dispatch(data []byte) interface{} {
 	case COM_PING:
 		return nil
 	case COM_INIT_DB:
-		if err := c.h.UseDB(hack.String(data)); err != nil {
+		dbName := string(data)
+		if err := c.handler().UseDBContext(c.Context(), dbName); err != nil {
 			return err
 		} else {
+			c.db = dbName
 			return nil
 		}
 	case COM_FIELD_LIST:
@@ -97,26 +162,40 @@ func (c *Conn) dispatch(data []byte) interface{} {
 		table := hack.String(data[0:index])
 		wildcard := hack.String(data[index+1:])
 
-		if fs, err := c.h.HandleFieldList(table, wildcard); err != nil {
+		if fs, err := c.handler().HandleFieldListContext(c.Context(), table, wildcard); err != nil {
 			return err
 		} else {
 			return fs
 		}
 	case COM_STMT_PREPARE:
-		c.stmtID++
+		if err := c.reserveStmt(); err != nil {
+			return err
+		}
 		st := new(Stmt)
-		st.ID = c.stmtID
+		st.ID = c.nextStmtID()
 		st.Query = hack.String(data)
 		var err error
-		if st.Params, st.Columns, st.Context, err = c.h.HandleStmtPrepare(st.Query); err != nil {
+		if st.Params, st.Columns, st.Context, err = c.handler().HandleStmtPrepareContext(c.Context(), st.Query); err != nil {
 			return err
 		} else {
 			st.ResetParams()
-			c.stmts[c.stmtID] = st
+			c.stmts[st.ID] = st
+			c.useStmt(st.ID)
 			return st
 		}
 	case COM_STMT_EXECUTE:
+		if err := c.countQuery(); err != nil {
+			return err
+		}
+		c.clearWarnings()
 		if r, err := c.handleStmtExecute(data); err != nil {
+			c.recordWarnings(nil, err)
+			return err
+		} else {
+			return r
+		}
+	case COM_STMT_FETCH:
+		if r, err := c.handleStmtFetch(data); err != nil {
 			return err
 		} else {
 			return r
@@ -137,48 +216,77 @@ func (c *Conn) dispatch(data []byte) interface{} {
 		} else {
 			return r
 		}
-	case COM_SET_OPTION:
-		if err := c.h.HandleOtherCommand(cmd, data); err != nil {
+	case COM_PROCESS_KILL:
+		if len(data) < 4 {
+			return ErrMalformPacket
+		}
+		if err := c.handleKill(binary.LittleEndian.Uint32(data), false); err != nil {
 			return err
 		}
-
-		return eofResponse{}
+		return nil
+	case COM_CHANGE_USER:
+		if err := c.handleChangeUser(data); err != nil {
+			return err
+		}
+		return nil
+	case COM_RESET_CONNECTION:
+		if err := c.handleResetConnection(); err != nil {
+			return err
+		}
+		return nil
+	case COM_STATISTICS:
+		if r, err := c.handleStatistics(); err != nil {
+			return err
+		} else {
+			return r
+		}
+	case COM_PROCESS_INFO:
+		return c.handleProcessInfo(data)
+	case COM_DEBUG:
+		if h, ok := c.h.(DebugHandler); ok {
+			return h.HandleDebug()
+		}
+		return nil
+	case COM_SET_OPTION:
+		return c.handleSetOption(data)
 	case COM_REGISTER_SLAVE:
 		if h, ok := c.h.(ReplicationHandler); ok {
 			return h.HandleRegisterSlave(data)
 		} else {
-			return c.h.HandleOtherCommand(cmd, data)
+			return c.handler().HandleOtherCommandContext(c.Context(), cmd, data)
 		}
 	case COM_BINLOG_DUMP:
 		if h, ok := c.h.(ReplicationHandler); ok {
-			pos, err := parseBinlogDump(data)
+			pos, flags, err := parseBinlogDump(data)
 			if err != nil {
 				return err
 			}
-			if s, err := h.HandleBinlogDump(pos); err != nil {
-				return err
-			} else {
-				return s
+			if fh, ok := h.(BinlogDumpFlagsHandler); ok {
+				s, err := fh.HandleBinlogDumpFlags(pos, flags)
+				return binlogDumpResponse(s, err, false)
 			}
+			s, err := h.HandleBinlogDump(pos)
+			return binlogDumpResponse(s, err, flags&replication.BINLOG_DUMP_NON_BLOCK != 0)
 		} else {
-			return c.h.HandleOtherCommand(cmd, data)
+			return c.handler().HandleOtherCommandContext(c.Context(), cmd, data)
 		}
 	case COM_BINLOG_DUMP_GTID:
 		if h, ok := c.h.(ReplicationHandler); ok {
-			gtidSet, err := parseBinlogDumpGTID(data)
+			gtidSet, flags, err := parseBinlogDumpGTID(data)
 			if err != nil {
 				return err
 			}
-			if s, err := h.HandleBinlogDumpGTID(gtidSet); err != nil {
-				return err
-			} else {
-				return s
+			if fh, ok := h.(BinlogDumpFlagsHandler); ok {
+				s, err := fh.HandleBinlogDumpGTIDFlags(gtidSet, flags)
+				return binlogDumpResponse(s, err, false)
 			}
+			s, err := h.HandleBinlogDumpGTID(gtidSet)
+			return binlogDumpResponse(s, err, flags&replication.BINLOG_DUMP_NON_BLOCK != 0)
 		} else {
-			return c.h.HandleOtherCommand(cmd, data)
+			return c.handler().HandleOtherCommandContext(c.Context(), cmd, data)
 		}
 	default:
-		return c.h.HandleOtherCommand(cmd, data)
+		return c.handler().HandleOtherCommandContext(c.Context(), cmd, data)
 	}
 }
 
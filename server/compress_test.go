@@ -0,0 +1,56 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+type testCompressHandler struct {
+	EmptyHandler
+}
+
+func (h testCompressHandler) HandleQuery(query string) (*mysql.Result, error) {
+	var rows [][]interface{}
+	for i := 0; i < 1000; i++ {
+		rows = append(rows, []interface{}{int64(i), strings.Repeat("a", 100)})
+	}
+	r, err := mysql.BuildSimpleTextResultset([]string{"id", "value"}, rows)
+	if err != nil {
+		return nil, err
+	}
+	return &mysql.Result{Resultset: r}, nil
+}
+
+func TestCompressedProtocol(t *testing.T) {
+	addr := serveTestConns(t, nil, nil, testCompressHandler{})
+
+	for _, capability := range []uint32{mysql.CLIENT_COMPRESS, mysql.CLIENT_ZSTD_COMPRESSION_ALGORITHM} {
+		// the default settings, then a higher level compressing the large packets only
+		for _, level := range []int{0, 9} {
+			c, err := client.Connect(addr, "root", "123", "", func(c *client.Conn) {
+				c.SetCapability(capability)
+				if level > 0 {
+					c.SetCompressionLevel(level)
+					c.SetCompressionThreshold(1024)
+				}
+			})
+			require.NoError(t, err, fmt.Sprintf("capability %d level %d", capability, level))
+
+			for i := 0; i < 2; i++ {
+				r, err := c.Execute("SELECT * FROM t")
+				require.NoError(t, err)
+				require.Equal(t, 1000, r.RowNumber())
+				v, err := r.GetString(999, 1)
+				require.NoError(t, err)
+				require.Equal(t, strings.Repeat("a", 100), v)
+			}
+			require.NoError(t, c.Close())
+		}
+	}
+}
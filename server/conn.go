@@ -1,10 +1,13 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"net"
-	"sync/atomic"
+	"sync"
 
+	"github.com/siddontang/go-log/loggers"
 	"github.com/siddontang/go/sync2"
 
 	. "github.com/atoonk/go-mysql/mysql"
@@ -17,25 +20,63 @@ type Conn struct {
 
 	serverConf     *Server
 	capability     uint32
-	charset        uint8
+	charset        uint16
 	authPluginName string
 	attributes     map[string]string
 	connectionID   uint32
 	status         uint16
 	warnings       uint16
 	salt           []byte // should be 8 + 12 for auth-plugin-data-part-1 and auth-plugin-data-part-2
+	// warnings of the last statement returned by SHOW WARNINGS
+	warningList []Warning
 
 	credentialProvider  CredentialProvider
 	user                string
 	password            string
+	db                  string
 	cachingSha2FullAuth bool
+	// credentials of the peer process of a unix socket connection, nil for the other connections
+	peerCred *PeerCred
+	// the user counted by the connection limit of the server, see reserveConnection
+	limitUser    string
+	limitCounted bool
 
 	h Handler
 
-	stmts  map[uint32]*Stmt
-	stmtID uint32
-
-	closed sync2.AtomicBool
+	logger   loggers.Advanced
+	timeouts Timeouts
+	// metrics of the server when the connection was created, nil if none
+	metrics Metrics
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	// the context of the connection, ctx being the one of the running command during a command
+	connCtx context.Context
+	// cancels the context of the running command, see startCommand
+	commandMu     sync.Mutex
+	commandCancel context.CancelFunc
+	// network connection once authenticated, closed by Server.Kill
+	netConn net.Conn
+	// state shown by the process list emulation and the user owning the connection, see setOwner
+	processMu sync.Mutex
+	process   processInfo
+	owner     string
+
+	stmts     map[uint32]*Stmt
+	stmtID    uint32
+	stmtLimit PreparedStmtLimit
+	// incremented every time a prepared statement is used, see useStmt
+	stmtUses uint64
+
+	// packets read ahead, nil if the connection is not pipelined
+	pipeline <-chan pipelinedPacket
+	// closed when the connection is closed
+	closing <-chan struct{}
+	// the binlog dumps are semi-sync, see SemiSyncAckHandler
+	semiSync bool
+
+	closed    sync2.AtomicBool
+	closeOnce sync.Once
 }
 
 var baseConnID uint32 = 10000
@@ -56,23 +97,38 @@ func NewConn(conn net.Conn, user string, password string, h Handler) (*Conn, err
 		Conn:               packetConn,
 		serverConf:         defaultServer,
 		credentialProvider: p,
+		peerCred:           readPeerCred(conn),
 		h:                  h,
-		connectionID:       atomic.AddUint32(&baseConnID, 1),
+		connectionID:       defaultServer.nextConnectionID(),
+		status:             defaultServer.statusFlags,
 		stmts:              make(map[uint32]*Stmt),
 		salt:               RandomBuf(20),
 	}
+	c.initContext()
+	c.initLogger()
+	c.SetTimeouts(c.serverConf.timeouts)
+	c.SetPreparedStmtLimit(c.serverConf.stmtLimit)
 	c.closed.Set(false)
 
 	if err := c.handshake(); err != nil {
 		c.Close()
 		return nil, err
 	}
+	c.startPipeline(c.serverConf.pipelineDepth)
 
 	return c, nil
 }
 
 // NewCustomizedConn: create connection with customized server settings
 func NewCustomizedConn(conn net.Conn, serverConf *Server, p CredentialProvider, h Handler) (*Conn, error) {
+	conn, err := serverConf.acceptProxyHeader(conn)
+	if err != nil {
+		return nil, err
+	}
+	// after the PROXY header, the peer of a proxied connection is the proxy rather than the client
+	peerCred := readPeerCred(conn)
+	conn = serverConf.meteredConn(conn)
+
 	var packetConn *packet.Conn
 	if serverConf.tlsConfig != nil {
 		packetConn = packet.NewTLSConn(conn)
@@ -84,37 +140,55 @@ func NewCustomizedConn(conn net.Conn, serverConf *Server, p CredentialProvider,
 		Conn:               packetConn,
 		serverConf:         serverConf,
 		credentialProvider: p,
+		peerCred:           peerCred,
 		h:                  h,
-		connectionID:       atomic.AddUint32(&baseConnID, 1),
+		metrics:            serverConf.metrics,
+		connectionID:       serverConf.nextConnectionID(),
+		status:             serverConf.statusFlags,
 		stmts:              make(map[uint32]*Stmt),
 		salt:               RandomBuf(20),
 	}
+	c.initContext()
+	c.initLogger()
+	c.SetTimeouts(c.serverConf.timeouts)
+	c.SetPreparedStmtLimit(c.serverConf.stmtLimit)
 	c.closed.Set(false)
 
 	if err := c.handshake(); err != nil {
 		c.Close()
 		return nil, err
 	}
+	c.startPipeline(c.serverConf.pipelineDepth)
 
 	return c, nil
 }
 
 func (c *Conn) handshake() error {
+	if o := c.observer(); o != nil {
+		if err := o.OnConnect(c); err != nil {
+			_ = c.writeError(err)
+			return err
+		}
+	}
+
 	if err := c.writeInitialHandshake(); err != nil {
 		return err
 	}
 
-	if err := c.readHandshakeResponse(); err != nil {
-		if errors.Is(err, ErrAccessDenied) {
-			var usingPasswd uint16 = ER_YES
-			if errors.Is(err, ErrAccessDeniedNoPassword) {
-				usingPasswd = ER_NO
-			}
-			err = NewDefaultError(ER_ACCESS_DENIED_ERROR, c.user, c.RemoteAddr().String(), MySQLErrName[usingPasswd])
-		}
+	err := c.readHandshakeResponse()
+	if err == nil {
+		err = c.verifyTLS()
+	}
+	if err == nil {
+		err = c.reserveConnection()
+	}
+	if err != nil {
+		err = c.accessDeniedError(err)
 		_ = c.writeError(err)
+		c.auditConnect(err)
 		return err
 	}
+	c.commitConnection()
 
 	if err := c.writeOK(nil); err != nil {
 		return err
@@ -122,12 +196,83 @@ func (c *Conn) handshake() error {
 
 	c.ResetSequence()
 
+	// the packets following the OK of the handshake are compressed if both sides support it
+	if c.capability&c.serverConf.capability&CLIENT_COMPRESS > 0 {
+		c.Conn.Compression = MYSQL_COMPRESS_ZLIB
+	} else if c.capability&c.serverConf.capability&CLIENT_ZSTD_COMPRESSION_ALGORITHM > 0 {
+		c.Conn.Compression = MYSQL_COMPRESS_ZSTD
+	}
+
+	c.endProcess()
+	c.serverConf.registerConn(c)
+	if c.metrics != nil {
+		c.metrics.ConnectionOpened()
+	}
+
+	c.auditConnect(nil)
+	if o := c.observer(); o != nil {
+		o.OnAuthenticated(c)
+	}
+
 	return nil
 }
 
+// accessDeniedError converts the ErrAccessDenied errors returned by the authentication to the MySQL error sent to the client.
+func (c *Conn) accessDeniedError(err error) error {
+	if errors.Is(err, ErrAccessDenied) {
+		var usingPasswd uint16 = ER_YES
+		if errors.Is(err, ErrAccessDeniedNoPassword) {
+			usingPasswd = ER_NO
+		}
+		return NewDefaultError(ER_ACCESS_DENIED_ERROR, c.user, c.RemoteAddr().String(), MySQLErrName[usingPasswd])
+	}
+	return err
+}
+
+func (c *Conn) verifyTLS() error {
+	if c.serverConf.tlsVerifyHook == nil {
+		return nil
+	}
+	var state *tls.ConnectionState
+	if st, ok := c.TLSConnectionState(); ok {
+		state = &st
+	}
+	return c.serverConf.tlsVerifyHook(c, state)
+}
+
+// TLSConnectionState returns the TLS state of the connection, ok is false if the client did not switch to TLS.
+func (c *Conn) TLSConnectionState() (state tls.ConnectionState, ok bool) {
+	if tlsConn, isTLS := c.Conn.Conn.(*tls.Conn); isTLS {
+		return tlsConn.ConnectionState(), true
+	}
+	return tls.ConnectionState{}, false
+}
+
+func (c *Conn) initLogger() {
+	c.logger = LoggerWithFields(c.serverConf.logger, "conn_id", c.connectionID, "remote", c.RemoteAddr().String())
+}
+
 func (c *Conn) Close() {
 	c.closed.Set(true)
+	if c.cancel != nil {
+		c.cancel()
+	}
 	c.Conn.Close()
+
+	if c.serverConf != nil {
+		c.serverConf.unregisterConn(c)
+		c.releaseConnection()
+	}
+
+	c.closeOnce.Do(func() {
+		// the connection was authenticated once registered
+		if c.metrics != nil && c.netConn != nil {
+			c.metrics.ConnectionClosed()
+		}
+		if o := c.observer(); o != nil {
+			o.OnClose(c)
+		}
+	})
 }
 
 func (c *Conn) Closed() bool {
@@ -138,6 +283,11 @@ func (c *Conn) GetUser() string {
 	return c.user
 }
 
+// GetDB returns the database currently selected by the client, either during the handshake or via COM_INIT_DB.
+func (c *Conn) GetDB() string {
+	return c.db
+}
+
 func (c *Conn) Capability() uint32 {
 	return c.capability
 }
@@ -154,10 +304,12 @@ func (c *Conn) HasCapability(cap uint32) bool {
 	return c.capability&cap > 0
 }
 
-func (c *Conn) Charset() uint8 {
+func (c *Conn) Charset() uint16 {
 	return c.charset
 }
 
+// Attributes returns the connection attributes sent by the client in the handshake, e.g. program_name or
+// _client_version. Handlers can get them from the connection returned by ConnFromContext.
 func (c *Conn) Attributes() map[string]string {
 	return c.attributes
 }
@@ -1,6 +1,9 @@
 package server
 
-import "sync"
+import (
+	"net"
+	"sync"
+)
 
 // interface for user credential provider
 // hint: can be extended for more functionality
@@ -14,6 +17,27 @@ type CredentialProvider interface {
 	GetCredential(username string) (password string, found bool, err error)
 }
 
+// RemoteAddrCredentialProvider is an optional extension of CredentialProvider for credentials depending on the
+// address of the client, e.g. the one told by a PROXY protocol header. GetCredentialFrom is then used instead of GetCredential.
+type RemoteAddrCredentialProvider interface {
+	GetCredentialFrom(username string, remoteAddr net.Addr) (password string, found bool, err error)
+}
+
+// AuthMethodCredentialProvider is an optional extension of CredentialProvider choosing the authentication method of
+// each user, e.g. 'mysql_native_password' for the accounts of old clients on a server defaulting to 'caching_sha2_password'.
+// The client answering the handshake with another method is asked to switch with an AuthSwitchRequest, an empty
+// method keeps the default one of the server.
+type AuthMethodCredentialProvider interface {
+	GetAuthMethod(username string) (authMethod string, err error)
+}
+
+// AttributesCredentialProvider is an optional extension of CredentialProvider checking the connection attributes
+// sent by the client (program_name, _client_name, etc.) before authenticating it. Returning an error rejects the
+// client, with the usual access denied error if it wraps ErrAccessDenied.
+type AttributesCredentialProvider interface {
+	CheckAttributes(username string, attributes map[string]string) error
+}
+
 func NewInMemoryProvider() *InMemoryProvider {
 	return &InMemoryProvider{
 		userPool: sync.Map{},
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// HandlerWithContext is an optional extension of Handler. If the handler passed to NewConn/NewCustomizedConn
+// implements it, the context-aware methods are called instead of their Handler counterparts.
+//
+// The context passed to every method is derived from the connection context, it is cancelled once the
+// connection is closed, so long-running work can be aborted when the client goes away.
+type HandlerWithContext interface {
+	Handler
+
+	//handle COM_INIT_DB command, see Handler.UseDB
+	UseDBContext(ctx context.Context, dbName string) error
+	//handle COM_QUERY command, see Handler.HandleQuery
+	HandleQueryContext(ctx context.Context, query string) (*Result, error)
+	//handle COM_FIELD_LIST command, see Handler.HandleFieldList
+	HandleFieldListContext(ctx context.Context, table string, fieldWildcard string) ([]*Field, error)
+	//handle COM_STMT_PREPARE command, see Handler.HandleStmtPrepare
+	HandleStmtPrepareContext(ctx context.Context, query string) (params int, columns int, context interface{}, err error)
+	//handle COM_STMT_EXECUTE command, see Handler.HandleStmtExecute
+	HandleStmtExecuteContext(ctx context.Context, context interface{}, query string, args []interface{}) (*Result, error)
+	//handle COM_STMT_CLOSE command, see Handler.HandleStmtClose
+	HandleStmtCloseContext(ctx context.Context, context interface{}) error
+	//handle any other command, see Handler.HandleOtherCommand
+	HandleOtherCommandContext(ctx context.Context, cmd byte, data []byte) error
+}
+
+// contextHandler adapts a plain Handler to HandlerWithContext by dropping the context.
+type contextHandler struct {
+	Handler
+}
+
+func (h contextHandler) UseDBContext(ctx context.Context, dbName string) error {
+	return h.UseDB(dbName)
+}
+
+func (h contextHandler) HandleQueryContext(ctx context.Context, query string) (*Result, error) {
+	return h.HandleQuery(query)
+}
+
+func (h contextHandler) HandleFieldListContext(ctx context.Context, table string, fieldWildcard string) ([]*Field, error) {
+	return h.HandleFieldList(table, fieldWildcard)
+}
+
+func (h contextHandler) HandleStmtPrepareContext(ctx context.Context, query string) (int, int, interface{}, error) {
+	return h.HandleStmtPrepare(query)
+}
+
+func (h contextHandler) HandleStmtExecuteContext(ctx context.Context, context interface{}, query string, args []interface{}) (*Result, error) {
+	return h.HandleStmtExecute(context, query, args)
+}
+
+func (h contextHandler) HandleStmtCloseContext(ctx context.Context, context interface{}) error {
+	return h.HandleStmtClose(context)
+}
+
+func (h contextHandler) HandleOtherCommandContext(ctx context.Context, cmd byte, data []byte) error {
+	return h.HandleOtherCommand(cmd, data)
+}
+
+// handler returns the connection handler as a HandlerWithContext.
+func (c *Conn) handler() HandlerWithContext {
+	if h, ok := c.h.(HandlerWithContext); ok {
+		return h
+	}
+	return contextHandler{c.h}
+}
+
+type connContextKey struct{}
+
+func (c *Conn) initContext() {
+	c.ctx, c.cancel = context.WithCancel(context.WithValue(context.Background(), connContextKey{}, c))
+	c.connCtx = c.ctx
+	c.closing = c.ctx.Done()
+}
+
+// Context returns the context of the connection, it is cancelled when the connection is closed.
+// The connection itself can be retrieved from it with ConnFromContext.
+func (c *Conn) Context() context.Context {
+	if c.ctx == nil {
+		return context.WithValue(context.Background(), connContextKey{}, c)
+	}
+	return c.ctx
+}
+
+// connContext returns the context of the connection, unlike Context it is not bounded by the running command.
+func (c *Conn) connContext() context.Context {
+	if c.connCtx == nil {
+		return c.Context()
+	}
+	return c.connCtx
+}
+
+// ConnFromContext returns the connection a HandlerWithContext callback is invoked for, so that handlers can
+// tell which client (connection id, user, remote address, selected database) issued the command.
+func ConnFromContext(ctx context.Context) (*Conn, bool) {
+	c, ok := ctx.Value(connContextKey{}).(*Conn)
+	return c, ok
+}
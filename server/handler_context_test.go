@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/packet"
+	mockconn "github.com/atoonk/go-mysql/test_util/conn"
+	"github.com/stretchr/testify/require"
+)
+
+type testContextHandler struct {
+	EmptyHandler
+	ctx context.Context
+}
+
+func (h *testContextHandler) UseDBContext(ctx context.Context, dbName string) error {
+	return nil
+}
+
+func (h *testContextHandler) HandleQueryContext(ctx context.Context, query string) (*mysql.Result, error) {
+	h.ctx = ctx
+	return &mysql.Result{AffectedRows: 1}, nil
+}
+
+func (h *testContextHandler) HandleFieldListContext(ctx context.Context, table string, fieldWildcard string) ([]*mysql.Field, error) {
+	return nil, nil
+}
+
+func (h *testContextHandler) HandleStmtPrepareContext(ctx context.Context, query string) (int, int, interface{}, error) {
+	return 0, 0, nil, nil
+}
+
+func (h *testContextHandler) HandleStmtExecuteContext(ctx context.Context, context interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	return nil, nil
+}
+
+func (h *testContextHandler) HandleStmtCloseContext(ctx context.Context, context interface{}) error {
+	return nil
+}
+
+func (h *testContextHandler) HandleOtherCommandContext(ctx context.Context, cmd byte, data []byte) error {
+	return nil
+}
+
+var _ HandlerWithContext = &testContextHandler{}
+
+func TestDispatchWithContext(t *testing.T) {
+	h := &testContextHandler{}
+	c := &Conn{Conn: packet.NewConn(&mockconn.MockConn{}), h: h}
+	c.initContext()
+
+	v := c.dispatch(append([]byte{mysql.COM_QUERY}, "SELECT 1"...))
+	require.Equal(t, &mysql.Result{AffectedRows: 1}, v)
+	require.NotNil(t, h.ctx)
+	require.NoError(t, h.ctx.Err())
+
+	cc, ok := ConnFromContext(h.ctx)
+	require.True(t, ok)
+	require.Equal(t, c, cc)
+
+	require.Nil(t, c.dispatch(append([]byte{mysql.COM_INIT_DB}, "test"...)))
+	require.Equal(t, "test", c.GetDB())
+
+	c.Close()
+	require.ErrorIs(t, h.ctx.Err(), context.Canceled)
+}
+
+func TestDispatchWithoutContext(t *testing.T) {
+	c := &Conn{Conn: packet.NewConn(&mockconn.MockConn{}), h: EmptyHandler{}}
+
+	v := c.dispatch(append([]byte{mysql.COM_QUERY}, "SELECT 1"...))
+	require.Error(t, v.(error))
+	require.NoError(t, c.Context().Err())
+}
@@ -30,15 +30,7 @@ func (c *Conn) readHandshakeResponse() error {
 
 	pos = c.readPluginName(data, pos)
 
-	cont, err := c.handleAuthMatch()
-	if err != nil {
-		return err
-	}
-	if !cont {
-		return nil
-	}
-
-	// read connection attributes
+	// read connection attributes, before the client may be asked to switch to another auth method
 	if c.capability&CLIENT_CONNECT_ATTRS > 0 {
 		// readAttributes returns new position for further processing of data
 		_, err = c.readAttributes(data, pos)
@@ -46,6 +38,19 @@ func (c *Conn) readHandshakeResponse() error {
 			return err
 		}
 	}
+	if p, ok := c.credentialProvider.(AttributesCredentialProvider); ok {
+		if err := p.CheckAttributes(c.user, c.attributes); err != nil {
+			return err
+		}
+	}
+
+	cont, err := c.handleAuthMatch()
+	if err != nil {
+		return err
+	}
+	if !cont {
+		return nil
+	}
 
 	// try to authenticate the client
 	return c.compareAuthData(c.authPluginName, authData)
@@ -84,7 +89,7 @@ func (c *Conn) decodeFirstPart(data []byte) (newData []byte, pos int, err error)
 	pos += 4
 
 	// connection's default character set as defined
-	c.charset = data[pos]
+	c.charset = uint16(data[pos])
 	pos++
 
 	//skip reserved 23[00]
@@ -125,9 +130,10 @@ func (c *Conn) readDb(data []byte, pos int) (int, error) {
 		db := string(data[pos : pos+bytes.IndexByte(data[pos:], 0x00)])
 		pos += len(db) + 1
 
-		if err := c.h.UseDB(db); err != nil {
+		if err := c.handler().UseDBContext(c.Context(), db); err != nil {
 			return 0, err
 		}
+		c.db = db
 	}
 	return pos, nil
 }
@@ -200,17 +206,40 @@ func (c *Conn) handleAuthMatch() (bool, error) {
 	// if the client responds the handshake with a different auth method, the server will send the AuthSwitchRequest packet
 	// to the client to ask the client to switch.
 
-	if c.authPluginName != c.serverConf.defaultAuthMethod {
-		if err := c.writeAuthSwitchRequest(c.serverConf.defaultAuthMethod); err != nil {
-			return false, err
+	authMethod, err := c.userAuthMethod()
+	if err != nil {
+		return false, err
+	}
+	if c.authPluginName != authMethod {
+		if acceptsAnyClientMethod(authMethod) {
+			c.authPluginName = authMethod
+			return true, nil
 		}
-		c.authPluginName = c.serverConf.defaultAuthMethod
-		// handle AuthSwitchResponse
-		return false, c.handleAuthSwitchResponse()
+		return false, c.SwitchAuthMethod(authMethod)
 	}
 	return true, nil
 }
 
+// userAuthMethod returns the authentication method of the connecting user, the default one of the server unless
+// the credential provider implements AuthMethodCredentialProvider.
+func (c *Conn) userAuthMethod() (string, error) {
+	p, ok := c.credentialProvider.(AuthMethodCredentialProvider)
+	if !ok {
+		return c.serverConf.defaultAuthMethod, nil
+	}
+	authMethod, err := p.GetAuthMethod(c.user)
+	if err != nil {
+		return "", err
+	}
+	if authMethod == "" {
+		return c.serverConf.defaultAuthMethod, nil
+	}
+	if !isAuthMethodSupported(authMethod) {
+		return "", errors.Errorf("unknown authentication plugin name '%s'", authMethod)
+	}
+	return authMethod, nil
+}
+
 func (c *Conn) readAttributes(data []byte, pos int) (int, error) {
 	// read length of attribute data
 	attrLen, isNull, skip := LengthEncodedInt(data[pos:])
@@ -226,10 +255,11 @@ func (c *Conn) readAttributes(data []byte, pos int) (int, error) {
 	i := 0
 	attrs := make(map[string]string)
 	var key string
+	end := pos + int(attrLen)
 
-	// read until end of data or NUL for atrribute key/values
-	for {
-		str, isNull, strLen, err := LengthEncodedString(data[pos:])
+	// read until end of attribute data or NUL for atrribute key/values, the zstd compression level may follow
+	for pos < end {
+		str, isNull, strLen, err := LengthEncodedString(data[pos:end])
 		if err != nil {
 			return -1, err
 		}
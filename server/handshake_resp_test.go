@@ -2,8 +2,11 @@ package server
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"testing"
 
+	"github.com/atoonk/go-mysql/client"
 	"github.com/atoonk/go-mysql/mocks"
 	"github.com/atoonk/go-mysql/mysql"
 	"github.com/stretchr/testify/mock"
@@ -225,3 +228,51 @@ func TestReadAttributes(t *testing.T) {
 		}
 	}
 }
+
+// programNameProvider only accepts the clients telling their program name
+type programNameProvider struct {
+	*InMemoryProvider
+}
+
+func (p programNameProvider) CheckAttributes(username string, attributes map[string]string) error {
+	if attributes["program_name"] == "" {
+		return fmt.Errorf("%w: program_name is required", ErrAccessDenied)
+	}
+	return nil
+}
+
+func TestConnectAttributes(t *testing.T) {
+	// the client is asked to switch to 'caching_sha2_password', the attributes are read before
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_CACHING_SHA2_PASSWORD, nil, tlsConf)
+	p := programNameProvider{NewInMemoryProvider()}
+	p.AddUser("root", "123")
+
+	attributes := make(chan map[string]string, 1)
+	addr := (&testServer{svr: svr, p: p, onConn: func(co *Conn, _ Handler, err error) {
+		if err == nil {
+			attributes <- co.Attributes()
+		}
+	}}).listen(t)
+
+	c, err := client.Connect(addr, "root", "123", "", func(c *client.Conn) {
+		c.UseSSL(true)
+		c.SetProgramName("billing")
+		c.SetAttributes(map[string]string{"team": "payments", "_os": ""})
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	attrs := <-attributes
+	require.Equal(t, "billing", attrs["program_name"])
+	require.Equal(t, "payments", attrs["team"])
+	require.Equal(t, "go-mysql", attrs["_client_name"])
+	// an empty value removes a default attribute
+	require.NotContains(t, attrs, "_os")
+
+	_, err = client.Connect(addr, "root", "123", "", func(c *client.Conn) {
+		c.UseSSL(true)
+	})
+	var m *mysql.MyError
+	require.True(t, errors.As(err, &m))
+	require.EqualValues(t, mysql.ER_ACCESS_DENIED_ERROR, m.Code)
+}
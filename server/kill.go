@@ -0,0 +1,130 @@
+package server
+
+import (
+	"regexp"
+	"strconv"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+type KillHandler interface {
+	//handle COM_PROCESS_KILL and 'KILL [CONNECTION | QUERY] id' queries, called before the connection with the id is killed,
+	//an error is sent to the client instead of killing it. queryOnly tells only the running command must be interrupted
+	HandleKill(connID uint32, queryOnly bool) error
+}
+
+// KillPrivilegeHandler is an optional extension of KillHandler. The connections of the other users can't be killed
+// unless it allows it, the client gets ER_KILL_DENIED_ERROR as MySQL answers without the CONNECTION_ADMIN privilege.
+type KillPrivilegeHandler interface {
+	KillHandler
+	//called when user kills a connection with the id owned by owner, another user
+	AllowKill(user string, connID uint32, owner string) bool
+}
+
+var killQueryRegexp = regexp.MustCompile(`(?i)^\s*KILL\s+(?:(CONNECTION|QUERY)\s+)?(\d+)\s*;?\s*$`)
+
+// parseKillQuery returns the connection id of a 'KILL [CONNECTION | QUERY] id' query, ok is false for the other queries.
+func parseKillQuery(query string) (connID uint32, queryOnly bool, ok bool) {
+	m := killQueryRegexp.FindStringSubmatch(query)
+	if m == nil {
+		return 0, false, false
+	}
+	id, err := strconv.ParseUint(m[2], 10, 32)
+	if err != nil {
+		return 0, false, false
+	}
+	return uint32(id), len(m[1]) == len("QUERY"), true
+}
+
+// handleKill kills a connection of the user of c, or of any user if the KillPrivilegeHandler allows it.
+func (c *Conn) handleKill(connID uint32, queryOnly bool) error {
+	if c.serverConf != nil {
+		if target, ok := c.serverConf.Conn(connID); ok {
+			if owner := target.getOwner(); owner != c.user {
+				h, ok := c.h.(KillPrivilegeHandler)
+				if !ok || !h.AllowKill(c.user, connID, owner) {
+					return NewDefaultError(ER_KILL_DENIED_ERROR, connID)
+				}
+			}
+		}
+	}
+
+	if h, ok := c.h.(KillHandler); ok {
+		if err := h.HandleKill(connID, queryOnly); err != nil {
+			return err
+		}
+	}
+	if c.serverConf == nil {
+		return NewDefaultError(ER_NO_SUCH_THREAD, connID)
+	}
+	return c.serverConf.Kill(connID, queryOnly)
+}
+
+// Conn returns the authenticated connection created with the server with the given id.
+func (s *Server) Conn(connID uint32) (*Conn, bool) {
+	if v, ok := s.conns.Load(connID); ok {
+		return v.(*Conn), true
+	}
+	return nil, false
+}
+
+// Kill interrupts the command running on the connection with the given id if queryOnly, as 'KILL QUERY' does,
+// the command gets ER_QUERY_INTERRUPTED if it fails once its context is canceled. Otherwise the connection is closed.
+// Unlike the KILL of a client, the owner of the connection is not checked.
+func (s *Server) Kill(connID uint32, queryOnly bool) error {
+	c, ok := s.Conn(connID)
+	if !ok {
+		return NewDefaultError(ER_NO_SUCH_THREAD, connID)
+	}
+
+	if queryOnly {
+		c.cancelCommand()
+		return nil
+	}
+
+	// the connection is owned by the goroutine handling its commands, closing the network connection
+	// makes its read fail and the connection closed there
+	c.cancel()
+	return c.netConn.Close()
+}
+
+// registerConn makes an authenticated connection known to Conn and Kill.
+func (s *Server) registerConn(c *Conn) {
+	c.netConn = c.Conn.Conn
+	c.setOwner()
+	s.conns.Store(c.connectionID, c)
+}
+
+func (s *Server) unregisterConn(c *Conn) {
+	if v, ok := s.conns.Load(c.connectionID); ok && v == c {
+		s.conns.Delete(c.connectionID)
+	}
+}
+
+// cancelCommand cancels the context of the running command, if any.
+func (c *Conn) cancelCommand() {
+	c.commandMu.Lock()
+	defer c.commandMu.Unlock()
+	if c.commandCancel != nil {
+		c.commandCancel()
+	}
+}
+
+func (c *Conn) setCommandCancel(cancel func()) {
+	c.commandMu.Lock()
+	c.commandCancel = cancel
+	c.commandMu.Unlock()
+}
+
+// setOwner makes the user of the connection its owner, seen by the other connections with getOwner.
+func (c *Conn) setOwner() {
+	c.processMu.Lock()
+	c.owner = c.user
+	c.processMu.Unlock()
+}
+
+func (c *Conn) getOwner() string {
+	c.processMu.Lock()
+	defer c.processMu.Unlock()
+	return c.owner
+}
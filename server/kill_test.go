@@ -0,0 +1,170 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+func TestParseKillQuery(t *testing.T) {
+	tests := []struct {
+		query     string
+		connID    uint32
+		queryOnly bool
+		ok        bool
+	}{
+		{"KILL 12", 12, false, true},
+		{" kill connection 12;", 12, false, true},
+		{"KILL QUERY 12", 12, true, true},
+		{"KILL QUERY", 0, false, false},
+		{"KILL 99999999999", 0, false, false},
+		{"SELECT 'KILL 12'", 0, false, false},
+	}
+
+	for _, tt := range tests {
+		connID, queryOnly, ok := parseKillQuery(tt.query)
+		require.Equal(t, tt.ok, ok, tt.query)
+		require.Equal(t, tt.connID, connID, tt.query)
+		require.Equal(t, tt.queryOnly, queryOnly, tt.query)
+	}
+}
+
+type testKillHandler struct {
+	testBlockingHandler
+	kills chan uint32
+}
+
+func (h *testKillHandler) HandleKill(connID uint32, queryOnly bool) error {
+	h.kills <- connID
+	return nil
+}
+
+func TestKill(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	h := &testKillHandler{kills: make(chan uint32, 3)}
+
+	closed := make(chan uint32, 2)
+	addr := (&testServer{svr: svr, h: h, onClose: func(co *Conn, _ error) {
+		closed <- co.ConnectionID()
+	}}).listen(t)
+
+	victim, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+	defer victim.Close()
+	killer, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+	defer killer.Close()
+
+	id := victim.GetConnectionID()
+	_, ok := svr.Conn(id)
+	require.True(t, ok)
+
+	// KILL QUERY interrupts the running command, the connection is still usable
+	done := make(chan error, 1)
+	go func() {
+		_, err := victim.Execute("SELECT SLEEP(10)")
+		done <- err
+	}()
+	require.Eventually(t, func() bool {
+		c, _ := svr.Conn(id)
+		c.commandMu.Lock()
+		defer c.commandMu.Unlock()
+		return c.commandCancel != nil
+	}, 5*time.Second, 5*time.Millisecond)
+
+	_, err = killer.Execute(fmt.Sprintf("KILL QUERY %d", id))
+	require.NoError(t, err)
+	require.Equal(t, id, <-h.kills)
+	select {
+	case err := <-done:
+		var myErr *mysql.MyError
+		require.ErrorAs(t, err, &myErr)
+		require.EqualValues(t, mysql.ER_QUERY_INTERRUPTED, myErr.Code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("query not interrupted")
+	}
+	require.NoError(t, victim.Ping())
+
+	// KILL closes the connection
+	_, err = killer.Execute(fmt.Sprintf("KILL %d", id))
+	require.NoError(t, err)
+	require.Equal(t, id, <-h.kills)
+	select {
+	case closedID := <-closed:
+		require.Equal(t, id, closedID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed")
+	}
+	_, ok = svr.Conn(id)
+	require.False(t, ok)
+
+	_, err = killer.Execute("KILL 1")
+	var myErr *mysql.MyError
+	require.ErrorAs(t, err, &myErr)
+	require.EqualValues(t, mysql.ER_NO_SUCH_THREAD, myErr.Code)
+
+	// COM_PROCESS_KILL is handled the same way
+	co, ok := svr.Conn(killer.GetConnectionID())
+	require.True(t, ok)
+	v := co.dispatch(append([]byte{mysql.COM_PROCESS_KILL}, mysql.Uint32ToBytes(1)...))
+	require.EqualValues(t, mysql.ER_NO_SUCH_THREAD, v.(*mysql.MyError).Code)
+	require.Equal(t, uint32(1), <-h.kills)
+	require.Equal(t, mysql.ErrMalformPacket, co.dispatch([]byte{mysql.COM_PROCESS_KILL, 1}))
+}
+
+type testKillPrivilegeHandler struct {
+	EmptyHandler
+	admins map[string]bool
+}
+
+func (h testKillPrivilegeHandler) HandleKill(connID uint32, queryOnly bool) error {
+	return nil
+}
+
+func (h testKillPrivilegeHandler) AllowKill(user string, connID uint32, owner string) bool {
+	return h.admins[user]
+}
+
+func TestKillOtherUser(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	p := NewInMemoryProvider()
+	p.AddUser("root", "123")
+	p.AddUser("alice", "abc")
+	p.AddUser("bob", "abc")
+
+	kill := func(addr string, user string, password string, id uint32) error {
+		c, err := client.Connect(addr, user, password, "")
+		require.NoError(t, err)
+		defer c.Close()
+		_, err = c.Execute(fmt.Sprintf("KILL %d", id))
+		return err
+	}
+	requireDenied := func(err error) {
+		var myErr *mysql.MyError
+		require.ErrorAs(t, err, &myErr)
+		require.EqualValues(t, mysql.ER_KILL_DENIED_ERROR, myErr.Code)
+	}
+
+	// by default only the owner of a connection can kill it
+	addr := serveTestConns(t, svr, p, EmptyHandler{})
+	victim, err := client.Connect(addr, "alice", "abc", "")
+	require.NoError(t, err)
+	defer victim.Close()
+	requireDenied(kill(addr, "bob", "abc", victim.GetConnectionID()))
+	requireDenied(kill(addr, "root", "123", victim.GetConnectionID()))
+	require.NoError(t, victim.Ping())
+	require.NoError(t, kill(addr, "alice", "abc", victim.GetConnectionID()))
+
+	// unless the handler allows the killer
+	addr = serveTestConns(t, svr, p, testKillPrivilegeHandler{admins: map[string]bool{"root": true}})
+	victim, err = client.Connect(addr, "alice", "abc", "")
+	require.NoError(t, err)
+	defer victim.Close()
+	requireDenied(kill(addr, "bob", "abc", victim.GetConnectionID()))
+	require.NoError(t, kill(addr, "root", "123", victim.GetConnectionID()))
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// Limits bounds the resources used by every user of a server, zero values disable them.
+// Exceeding a limit fails with ER_USER_LIMIT_REACHED, as MySQL does with the account resource limits.
+type Limits struct {
+	// MaxUserConnections is the number of connections a user can have open at the same time, the authentication
+	// of one more connection fails
+	MaxUserConnections int
+	// MaxQueriesPerSecond is the number of COM_QUERY and COM_STMT_EXECUTE commands a user can run in a second over
+	// all its connections, the next ones fail before reaching the Handler until the second is over
+	MaxQueriesPerSecond int
+	// MaxResultBytes bounds the size of the rows of a resultset, a larger one is replaced by the error, or ended by it
+	// once the limit is reached if it is streamed by a ResultStreamer
+	MaxResultBytes int
+}
+
+// SetLimits sets the resource limits of the users of the server, at any time.
+func (s *Server) SetLimits(l Limits) {
+	s.limits.Store(&l)
+}
+
+// getLimits returns the limits set by SetLimits.
+func (s *Server) getLimits() Limits {
+	if l := s.limits.Load(); l != nil {
+		return *l
+	}
+	return Limits{}
+}
+
+// userUsage is the resources used by a user, see Limits.
+type userUsage struct {
+	mu    sync.Mutex
+	conns int
+	// number of queries run in the second started at second, in unix time
+	second  int64
+	queries int
+	// the usage was removed from the server with the last connection of its user, see releaseUserConnection
+	removed bool
+}
+
+// lockUserUsage returns the usage of a user, locked.
+func (s *Server) lockUserUsage(user string) *userUsage {
+	for {
+		v, _ := s.usage.LoadOrStore(user, new(userUsage))
+		u := v.(*userUsage)
+		u.mu.Lock()
+		if !u.removed {
+			return u
+		}
+		// removed once loaded, the user has a new one
+		u.mu.Unlock()
+	}
+}
+
+// reserveUserConnection counts a new connection of the user, the connections are counted even without a limit
+// so that it can be set at any time.
+func (s *Server) reserveUserConnection(user string) error {
+	u := s.lockUserUsage(user)
+	defer u.mu.Unlock()
+	if max := s.getLimits().MaxUserConnections; max > 0 && u.conns >= max {
+		return NewDefaultError(ER_USER_LIMIT_REACHED, user, "max_user_connections", max)
+	}
+	u.conns++
+	return nil
+}
+
+func (s *Server) releaseUserConnection(user string) {
+	u := s.lockUserUsage(user)
+	defer u.mu.Unlock()
+	// the users are forgotten with their last connection
+	if u.conns--; u.conns == 0 {
+		u.removed = true
+		s.usage.Delete(user)
+	}
+}
+
+// countQuery counts a query of the user against MaxQueriesPerSecond.
+func (s *Server) countQuery(user string) error {
+	max := s.getLimits().MaxQueriesPerSecond
+	if max <= 0 {
+		return nil
+	}
+
+	u := s.lockUserUsage(user)
+	defer u.mu.Unlock()
+	if now := time.Now().Unix(); now != u.second {
+		u.second, u.queries = now, 0
+	}
+	if u.queries >= max {
+		return NewDefaultError(ER_USER_LIMIT_REACHED, user, "max_queries_per_second", max)
+	}
+	u.queries++
+	return nil
+}
+
+// countQuery counts a query of the connection user against MaxQueriesPerSecond.
+func (c *Conn) countQuery() error {
+	if c.serverConf == nil {
+		return nil
+	}
+	return c.serverConf.countQuery(c.user)
+}
+
+// reserveConnection counts the connection against the connection limit of its authenticated user, the previous
+// user after a COM_CHANGE_USER is released by commitConnection.
+func (c *Conn) reserveConnection() error {
+	if c.limitCounted && c.limitUser == c.user {
+		return nil
+	}
+	return c.serverConf.reserveUserConnection(c.user)
+}
+
+// commitConnection releases the user counted before the last reserveConnection.
+func (c *Conn) commitConnection() {
+	if c.limitCounted && c.limitUser != c.user {
+		c.serverConf.releaseUserConnection(c.limitUser)
+	}
+	c.limitUser, c.limitCounted = c.user, true
+}
+
+// abortConnection releases the user counted by the last reserveConnection if it failed to be authenticated.
+func (c *Conn) abortConnection() {
+	if !c.limitCounted || c.limitUser != c.user {
+		c.serverConf.releaseUserConnection(c.user)
+	}
+}
+
+// releaseConnection releases the user of a closed connection.
+func (c *Conn) releaseConnection() {
+	if c.limitCounted {
+		c.limitCounted = false
+		c.serverConf.releaseUserConnection(c.limitUser)
+	}
+}
+
+// checkResultBytes checks the size of rows against MaxResultBytes.
+func (c *Conn) checkResultBytes(size int) error {
+	if c.serverConf == nil {
+		return nil
+	}
+	if max := c.serverConf.getLimits().MaxResultBytes; max > 0 && size > max {
+		return NewDefaultError(ER_USER_LIMIT_REACHED, c.user, "max_result_bytes", max)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/packet"
+	mockconn "github.com/atoonk/go-mysql/test_util/conn"
+)
+
+func TestMaxUserConnections(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	svr.SetLimits(Limits{MaxUserConnections: 1})
+	p := NewInMemoryProvider()
+	p.AddUser("root", "123")
+	p.AddUser("bob", "123")
+
+	addr := serveTestConns(t, svr, p, nil)
+
+	c, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+
+	_, err = client.Connect(addr, "root", "123", "")
+	var myErr *mysql.MyError
+	require.ErrorAs(t, err, &myErr)
+	require.EqualValues(t, mysql.ER_USER_LIMIT_REACHED, myErr.Code)
+
+	// the limit is per user
+	c2, err := client.Connect(addr, "bob", "123", "")
+	require.NoError(t, err)
+	c2.Close()
+
+	// a closed connection is no longer counted
+	c.Close()
+	require.Eventually(t, func() bool {
+		c, err = client.Connect(addr, "root", "123", "")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	c.Close()
+
+	// the users are forgotten with their last connection
+	require.Eventually(t, func() bool {
+		n := 0
+		svr.usage.Range(func(_, _ interface{}) bool {
+			n++
+			return true
+		})
+		return n == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMaxQueriesPerSecond(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	svr.SetLimits(Limits{MaxQueriesPerSecond: 2})
+	c := &Conn{Conn: packet.NewConn(&mockconn.MockConn{}), serverConf: svr, h: EmptyHandler{}, user: "root"}
+
+	query := append([]byte{mysql.COM_QUERY}, "SELECT 1"...)
+	for i := 0; i < 2; i++ {
+		// the handler is called
+		require.EqualError(t, c.dispatch(query).(error), "not supported now")
+	}
+	// the queries over the limit do not reach the handler until the next second
+	v := c.dispatch(query)
+	require.EqualValues(t, mysql.ER_USER_LIMIT_REACHED, v.(*mysql.MyError).Code)
+	require.Nil(t, c.dispatch([]byte{mysql.COM_PING}))
+
+	u := svr.lockUserUsage("root")
+	u.second--
+	u.mu.Unlock()
+	require.EqualError(t, c.dispatch(query).(error), "not supported now")
+}
+
+func TestMaxResultBytes(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	svr.SetLimits(Limits{MaxResultBytes: 8})
+	clientConn := &mockconn.MockConn{MultiWrite: true}
+	c := &Conn{Conn: packet.NewConn(clientConn), serverConf: svr, user: "root"}
+	c.SetCapability(mysql.CLIENT_PROTOCOL_41)
+
+	r, err := mysql.BuildSimpleTextResultset([]string{"a"}, [][]interface{}{{"1234"}, {"5678"}})
+	require.NoError(t, err)
+	require.NoError(t, c.WriteValue(&mysql.Result{Resultset: r}))
+	require.Equal(t, mysql.ERR_HEADER, clientConn.WriteBuffered[4])
+	require.Equal(t, uint16(mysql.ER_USER_LIMIT_REACHED), binary.LittleEndian.Uint16(clientConn.WriteBuffered[5:]))
+}
+
+func TestSetLimitsConcurrently(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	c := &Conn{serverConf: svr, user: "root"}
+
+	// the limits are read by the connections while they are set, see go test -race
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 100; i++ {
+			svr.SetLimits(Limits{MaxResultBytes: i})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_ = c.checkResultBytes(50)
+	}
+	<-done
+	require.Error(t, c.checkResultBytes(101))
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"io"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// localInfileReader returns the content of the file sent by the client after a LOCAL INFILE request,
+// the client sends it as a sequence of packets terminated by an empty one.
+type localInfileReader struct {
+	c   *Conn
+	buf []byte
+	eof bool
+	err error
+}
+
+func (r *localInfileReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.eof {
+			return 0, io.EOF
+		}
+
+		data, err := r.c.ReadPacket()
+		if err != nil {
+			r.err = err
+		} else if len(data) == 0 {
+			r.eof = true
+		} else {
+			r.buf = data
+		}
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// see: https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_query_response_local_infile_request.html
+func (c *Conn) writeLocalInfile(req *LocalInfileRequest) error {
+	if c.capability&CLIENT_LOCAL_FILES == 0 {
+		return c.writeError(NewDefaultError(ER_NOT_ALLOWED_COMMAND))
+	}
+
+	data := make([]byte, 4, 5+len(req.Filename))
+	data = append(data, LocalInFile_HEADER)
+	data = append(data, req.Filename...)
+	if err := c.WritePacket(data); err != nil {
+		return err
+	}
+
+	r := &localInfileReader{c: c}
+	result, err := req.Handler(r)
+
+	// the client sends the whole file whatever the handler has read, the packets left must be consumed
+	// before sending the response
+	if _, rerr := io.Copy(io.Discard, r); rerr != nil {
+		return rerr
+	}
+
+	if err != nil {
+		return c.writeError(err)
+	}
+	return c.writeOK(result)
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/packet"
+	mockconn "github.com/atoonk/go-mysql/test_util/conn"
+	"github.com/pingcap/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// sendLocalInfile plays the client side: it reads the LOCAL INFILE request, sends the file in chunks
+// and returns the filename and the final response
+func sendLocalInfile(conn net.Conn, chunks ...string) (filename string, resp []byte, err error) {
+	c := packet.NewConn(conn)
+	defer c.Close()
+
+	req, err := c.ReadPacket()
+	if err != nil {
+		return "", nil, err
+	}
+	for _, chunk := range chunks {
+		if err := c.WritePacket(append(make([]byte, 4), chunk...)); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := c.WritePacket(make([]byte, 4)); err != nil {
+		return "", nil, err
+	}
+	resp, err = c.ReadPacket()
+	return string(req[1:]), resp, err
+}
+
+func TestConnWriteLocalInfile(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	c := &Conn{Conn: packet.NewConn(serverConn)}
+	c.SetCapability(mysql.CLIENT_PROTOCOL_41 | mysql.CLIENT_LOCAL_FILES)
+
+	type clientResult struct {
+		filename string
+		resp     []byte
+		err      error
+	}
+	done := make(chan clientResult, 1)
+	go func() {
+		filename, resp, err := sendLocalInfile(clientConn, "a,b", "\nc")
+		done <- clientResult{filename, resp, err}
+	}()
+
+	var content []byte
+	req := mysql.NewLocalInfileRequest("data.csv", func(r io.Reader) (*mysql.Result, error) {
+		var err error
+		content, err = io.ReadAll(r)
+		return &mysql.Result{AffectedRows: 2}, err
+	})
+	require.NoError(t, c.WriteValue(&mysql.Result{LocalInfile: req}))
+	require.Equal(t, "a,b\nc", string(content))
+
+	res := <-done
+	require.NoError(t, res.err)
+	require.Equal(t, "data.csv", res.filename)
+	require.Equal(t, []byte{mysql.OK_HEADER, 2, 0, 0, 0, 0, 0}, res.resp)
+
+	// the file is consumed even if the handler does not read it
+	clientConn, serverConn = net.Pipe()
+	c = &Conn{Conn: packet.NewConn(serverConn)}
+	c.SetCapability(mysql.CLIENT_PROTOCOL_41 | mysql.CLIENT_LOCAL_FILES)
+
+	go func() {
+		filename, resp, err := sendLocalInfile(clientConn, "a,b")
+		done <- clientResult{filename, resp, err}
+	}()
+
+	req = mysql.NewLocalInfileRequest("data.csv", func(r io.Reader) (*mysql.Result, error) {
+		return nil, errors.New("rejected")
+	})
+	require.NoError(t, c.WriteValue(&mysql.Result{LocalInfile: req}))
+
+	res = <-done
+	require.NoError(t, res.err)
+	require.Equal(t, mysql.ERR_HEADER, res.resp[0])
+}
+
+func TestConnWriteLocalInfileDisabled(t *testing.T) {
+	clientConn := &mockconn.MockConn{}
+	c := &Conn{Conn: packet.NewConn(clientConn)}
+	c.SetCapability(mysql.CLIENT_PROTOCOL_41)
+
+	req := mysql.NewLocalInfileRequest("data.csv", func(r io.Reader) (*mysql.Result, error) {
+		return nil, nil
+	})
+	require.NoError(t, c.WriteValue(&mysql.Result{LocalInfile: req}))
+	require.Equal(t, mysql.ERR_HEADER, clientConn.WriteBuffered[4])
+	require.Equal(t, []byte{0x7c, 0x04}, clientConn.WriteBuffered[5:7])
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net"
+	"time"
+)
+
+// Metrics receives the measurements of the connections of a Server, e.g. to export them to a monitoring system,
+// see the server/prommetrics module for Prometheus. The methods are called from the goroutines using the connections,
+// they must be safe for concurrent use and should not block.
+type Metrics interface {
+	// CommandDispatched counts a command read from a client, before it is handled
+	CommandDispatched(cmd byte)
+	// CommandLatency observes the time spent handling a command and writing its response
+	CommandLatency(cmd byte, d time.Duration)
+	// BytesRead counts the bytes read from the network connections, including the handshake and TLS overhead
+	BytesRead(n int)
+	// BytesWritten counts the bytes written to the network connections, including the handshake and TLS overhead
+	BytesWritten(n int)
+	// Error counts an ERR packet sent to a client by its MySQL error code, e.g. ER_ACCESS_DENIED_ERROR
+	Error(code uint16)
+	// ConnectionOpened and ConnectionClosed track the authenticated connections
+	ConnectionOpened()
+	ConnectionClosed()
+}
+
+// SetMetrics sets the metrics measuring the connections created with the server from now on.
+func (s *Server) SetMetrics(m Metrics) {
+	s.metrics = m
+}
+
+// meteredConn counts the bytes read and written through a network connection.
+type meteredConn struct {
+	net.Conn
+	m Metrics
+}
+
+func (s *Server) meteredConn(conn net.Conn) net.Conn {
+	if s.metrics == nil {
+		return conn
+	}
+	return &meteredConn{Conn: conn, m: s.metrics}
+}
+
+func (c *meteredConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.m.BytesRead(n)
+	}
+	return n, err
+}
+
+func (c *meteredConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.m.BytesWritten(n)
+	}
+	return n, err
+}
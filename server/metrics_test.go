@@ -0,0 +1,111 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+type testMetrics struct {
+	mu          sync.Mutex
+	commands    map[byte]int
+	latencies   int
+	read        int
+	written     int
+	errors      map[uint16]int
+	connections int
+}
+
+func newTestMetrics() *testMetrics {
+	return &testMetrics{commands: make(map[byte]int), errors: make(map[uint16]int)}
+}
+
+func (m *testMetrics) CommandDispatched(cmd byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.commands[cmd]++
+}
+
+func (m *testMetrics) CommandLatency(cmd byte, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latencies++
+}
+
+func (m *testMetrics) BytesRead(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.read += n
+}
+
+func (m *testMetrics) BytesWritten(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.written += n
+}
+
+func (m *testMetrics) Error(code uint16) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors[code]++
+}
+
+func (m *testMetrics) ConnectionOpened() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connections++
+}
+
+func (m *testMetrics) ConnectionClosed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connections--
+}
+
+func TestMetrics(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	m := newTestMetrics()
+	svr.SetMetrics(m)
+
+	closed := make(chan struct{})
+	addr := (&testServer{svr: svr, onClose: func(*Conn, error) {
+		closed <- struct{}{}
+	}}).listen(t)
+
+	c, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+	require.NoError(t, c.Ping())
+	_, err = c.Execute("SELECT 1")
+	require.Error(t, err)
+	c.Close()
+	<-closed
+
+	// a failed authentication is not an active connection
+	_, err = client.Connect(addr, "root", "wrong", "")
+	require.Error(t, err)
+
+	require.Eventually(t, func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.errors[mysql.ER_ACCESS_DENIED_ERROR] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	require.Equal(t, 1, m.commands[mysql.COM_PING])
+	require.Equal(t, 1, m.commands[mysql.COM_QUERY])
+	commands := 0
+	for _, n := range m.commands {
+		commands += n
+	}
+	require.Equal(t, commands, m.latencies)
+	require.Equal(t, 1, m.errors[mysql.ER_UNKNOWN_ERROR])
+	require.Zero(t, m.connections)
+	require.Positive(t, m.read)
+	require.Positive(t, m.written)
+}
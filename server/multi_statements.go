@@ -0,0 +1,136 @@
+package server
+
+import (
+	"encoding/binary"
+	"strings"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// multiResponse is written as a chain of results, all but the last one with SERVER_MORE_RESULTS_EXISTS set.
+type multiResponse []interface{}
+
+// handleMultiStatements runs every statement of a COM_QUERY sent by a CLIENT_MULTI_STATEMENTS client,
+// execution stops at the first statement returning an error, as MySQL does.
+func (c *Conn) handleMultiStatements(queries []string) multiResponse {
+	resp := make(multiResponse, 0, len(queries))
+	for _, query := range queries {
+		r, err := c.handleQuery(query)
+		if err != nil {
+			return append(resp, err)
+		}
+		resp = append(resp, r)
+	}
+	return resp
+}
+
+// handleSetOption turns the multi-statement support of the connection on or off, as COM_SET_OPTION does.
+// It is answered with EOF, or OK if the client and the server use CLIENT_DEPRECATE_EOF.
+func (c *Conn) handleSetOption(data []byte) interface{} {
+	if len(data) < 2 {
+		return ErrMalformPacket
+	}
+
+	switch binary.LittleEndian.Uint16(data) {
+	case MYSQL_OPTION_MULTI_STATEMENTS_ON:
+		c.SetCapability(CLIENT_MULTI_STATEMENTS)
+	case MYSQL_OPTION_MULTI_STATEMENTS_OFF:
+		c.UnsetCapability(CLIENT_MULTI_STATEMENTS)
+	default:
+		return NewDefaultError(ER_UNKNOWN_COM_ERROR)
+	}
+
+	if c.serverConf != nil && c.capability&c.serverConf.capability&CLIENT_DEPRECATE_EOF > 0 {
+		return nil
+	}
+	return eofResponse{}
+}
+
+// writeMultiResponse writes every item of resp, with the results chained to a *Result by Next
+// written right after it. binary tells if the rows of a ResultStreamer use the binary protocol.
+func (c *Conn) writeMultiResponse(resp multiResponse, binary bool) error {
+	var items multiResponse
+	for _, v := range resp {
+		r, ok := v.(*Result)
+		if !ok {
+			items = append(items, v)
+			continue
+		}
+		for ; r != nil; r = r.Next {
+			items = append(items, r)
+		}
+	}
+
+	for i, v := range items {
+		more := i < len(items)-1
+		if more {
+			c.SetStatus(SERVER_MORE_RESULTS_EXISTS)
+		}
+		var err error
+		if r, ok := v.(*Result); ok {
+			err = c.writeResult(r, binary)
+		} else {
+			err = c.WriteValue(v)
+		}
+		if more {
+			c.UnsetStatus(SERVER_MORE_RESULTS_EXISTS)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitMultiStatements splits a query on the ';' separating statements, ignoring the ones in
+// quoted strings, quoted identifiers and comments. Empty statements are dropped.
+func splitMultiStatements(query string) []string {
+	var queries []string
+
+	start := 0
+	for i := 0; i < len(query); i++ {
+		switch ch := query[i]; ch {
+		case '\'', '"', '`':
+			i = skipQuoted(query, i)
+		case '#':
+			i = skipLineComment(query, i)
+		case '-':
+			// "-- " starts a comment, the second dash must be followed by a whitespace or a control character
+			if i+2 < len(query) && query[i+1] == '-' && query[i+2] <= ' ' {
+				i = skipLineComment(query, i)
+			} else if i+2 == len(query) && query[i+1] == '-' {
+				i = len(query)
+			}
+		case '/':
+			if i+1 < len(query) && query[i+1] == '*' {
+				if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+					i += end + 3
+				} else {
+					i = len(query)
+				}
+			}
+		case ';':
+			queries = appendStatement(queries, query[start:i])
+			start = i + 1
+		}
+	}
+
+	if start < len(query) {
+		queries = appendStatement(queries, query[start:])
+	}
+	return queries
+}
+
+func skipLineComment(query string, i int) int {
+	if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+		return i + end
+	}
+	return len(query)
+}
+
+func appendStatement(queries []string, query string) []string {
+	if query = strings.TrimSpace(query); query != "" {
+		queries = append(queries, query)
+	}
+	return queries
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/packet"
+	mockconn "github.com/atoonk/go-mysql/test_util/conn"
+	"github.com/pingcap/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitMultiStatements(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"SELECT 1", []string{"SELECT 1"}},
+		{"SELECT 1;", []string{"SELECT 1"}},
+		{"SELECT 1; SELECT 2", []string{"SELECT 1", "SELECT 2"}},
+		{"SELECT 1;;  ; SELECT 2;", []string{"SELECT 1", "SELECT 2"}},
+		{"SELECT ';'; SELECT \"a;b\"", []string{"SELECT ';'", "SELECT \"a;b\""}},
+		{"SELECT 'it\\'s;'; SELECT 'it''s;'", []string{"SELECT 'it\\'s;'", "SELECT 'it''s;'"}},
+		{"SELECT `a;b` FROM t; SELECT 2", []string{"SELECT `a;b` FROM t", "SELECT 2"}},
+		{"SELECT 1 /* ; */; SELECT 2", []string{"SELECT 1 /* ; */", "SELECT 2"}},
+		{"SELECT 1 # ;\n; SELECT 2", []string{"SELECT 1 # ;", "SELECT 2"}},
+		{"SELECT 1 -- ;\n; SELECT 2", []string{"SELECT 1 -- ;", "SELECT 2"}},
+		{"SELECT 1--2; SELECT 2", []string{"SELECT 1--2", "SELECT 2"}},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, splitMultiStatements(tt.query), tt.query)
+	}
+}
+
+type testMultiHandler struct {
+	testContextHandler
+	queries []string
+}
+
+func (h *testMultiHandler) HandleQueryContext(ctx context.Context, query string) (*mysql.Result, error) {
+	h.queries = append(h.queries, query)
+	if query == "FAIL" {
+		return nil, errors.New("fail")
+	}
+	return &mysql.Result{AffectedRows: uint64(len(h.queries))}, nil
+}
+
+func TestDispatchMultiStatements(t *testing.T) {
+	h := &testMultiHandler{}
+	c := &Conn{Conn: packet.NewConn(&mockconn.MockConn{}), h: h}
+
+	// without CLIENT_MULTI_STATEMENTS the query is passed as is
+	v := c.dispatch(append([]byte{mysql.COM_QUERY}, "SELECT 1; SELECT 2"...))
+	require.Equal(t, &mysql.Result{AffectedRows: 1}, v)
+	require.Equal(t, []string{"SELECT 1; SELECT 2"}, h.queries)
+
+	h.queries = nil
+	c.SetCapability(mysql.CLIENT_MULTI_STATEMENTS)
+	v = c.dispatch(append([]byte{mysql.COM_QUERY}, "SELECT 1; SELECT 2"...))
+	require.Equal(t, multiResponse{&mysql.Result{AffectedRows: 1}, &mysql.Result{AffectedRows: 2}}, v)
+
+	// execution stops at the first error
+	h.queries = nil
+	v = c.dispatch(append([]byte{mysql.COM_QUERY}, "SELECT 1; FAIL; SELECT 2"...))
+	require.Len(t, v, 2)
+	require.Error(t, v.(multiResponse)[1].(error))
+	require.Equal(t, []string{"SELECT 1", "FAIL"}, h.queries)
+}
+
+func TestConnWriteMultiResponse(t *testing.T) {
+	clientConn := &mockconn.MockConn{MultiWrite: true}
+	c := &Conn{Conn: packet.NewConn(clientConn)}
+	c.SetCapability(mysql.CLIENT_PROTOCOL_41)
+
+	err := c.writeMultiResponse(multiResponse{&mysql.Result{AffectedRows: 1}, &mysql.Result{AffectedRows: 2}}, false)
+	require.NoError(t, err)
+	// first OK packet has SERVER_MORE_RESULTS_EXISTS (0x0008), the last one has not
+	expected := []byte{7, 0, 0, 0, mysql.OK_HEADER, 1, 0, 8, 0, 0, 0}
+	expected = append(expected, 7, 0, 0, 1, mysql.OK_HEADER, 2, 0, 0, 0, 0, 0)
+	require.Equal(t, expected, clientConn.WriteBuffered)
+	require.False(t, c.HasStatus(mysql.SERVER_MORE_RESULTS_EXISTS))
+}
+
+func TestSetOption(t *testing.T) {
+	h := &testMultiHandler{}
+	c := &Conn{Conn: packet.NewConn(&mockconn.MockConn{}), h: h}
+
+	require.Equal(t, eofResponse{}, c.dispatch([]byte{mysql.COM_SET_OPTION, mysql.MYSQL_OPTION_MULTI_STATEMENTS_ON, 0}))
+	require.True(t, c.HasCapability(mysql.CLIENT_MULTI_STATEMENTS))
+	v := c.dispatch(append([]byte{mysql.COM_QUERY}, "SELECT 1; SELECT 2"...))
+	require.Len(t, v, 2)
+
+	require.Equal(t, eofResponse{}, c.dispatch([]byte{mysql.COM_SET_OPTION, mysql.MYSQL_OPTION_MULTI_STATEMENTS_OFF, 0}))
+	require.False(t, c.HasCapability(mysql.CLIENT_MULTI_STATEMENTS))
+
+	v = c.dispatch([]byte{mysql.COM_SET_OPTION, 2, 0})
+	require.EqualValues(t, mysql.ER_UNKNOWN_COM_ERROR, v.(*mysql.MyError).Code)
+	require.Equal(t, mysql.ErrMalformPacket, c.dispatch([]byte{mysql.COM_SET_OPTION}))
+
+	// answered with OK once EOF is deprecated on both sides
+	c.serverConf = NewServerWithConfig(ServerConfig{Capability: DefaultServerCapability | mysql.CLIENT_DEPRECATE_EOF})
+	c.SetCapability(mysql.CLIENT_DEPRECATE_EOF)
+	require.Nil(t, c.dispatch([]byte{mysql.COM_SET_OPTION, mysql.MYSQL_OPTION_MULTI_STATEMENTS_ON, 0}))
+}
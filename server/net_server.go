@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// ErrServerClosed is returned by NetServer.Serve once Shutdown has been called.
+var ErrServerClosed = errors.New("server: NetServer closed")
+
+// NetServer accepts the connections of listeners and serves them with the settings of a Server,
+// it replaces the accept loop every user would otherwise write and supports graceful shutdown.
+type NetServer struct {
+	serverConf *Server
+	provider   CredentialProvider
+	newHandler func() Handler
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*Conn]*netServerConn
+	shutdown  bool
+	wg        sync.WaitGroup
+}
+
+type netServerConn struct {
+	conn   net.Conn
+	active bool // a command is being handled
+}
+
+// NewNetServer creates a NetServer, newHandler is called to get the Handler of every connection.
+func NewNetServer(serverConf *Server, p CredentialProvider, newHandler func() Handler) *NetServer {
+	return &NetServer{
+		serverConf: serverConf,
+		provider:   p,
+		newHandler: newHandler,
+		listeners:  make(map[net.Listener]struct{}),
+		conns:      make(map[*Conn]*netServerConn),
+	}
+}
+
+// Serve accepts the connections of l and serves each of them in a new goroutine until Shutdown is called,
+// it then returns ErrServerClosed. Any other error of l.Accept is returned as is.
+func (s *NetServer) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.shutdown {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isShutdown() {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.shutdown {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Shutdown stops accepting new connections, closes the idle ones with an ER_SERVER_SHUTDOWN error and
+// waits for the commands in flight to be handled before closing their connections.
+// If ctx expires first, the remaining connections are closed and the context error is returned.
+func (s *NetServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shutdown = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	// the idle connections are woken up until they are all closed, as the read timeout of a connection
+	// starting to wait for its next command may override the deadline set here
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		s.wakeUpIdleConns()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			s.mu.Lock()
+			for _, sc := range s.conns {
+				sc.conn.Close()
+			}
+			s.mu.Unlock()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+const shutdownPollInterval = 50 * time.Millisecond
+
+// wakeUpIdleConns interrupts the goroutines waiting for the next command, they close their connection.
+func (s *NetServer) wakeUpIdleConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sc := range s.conns {
+		if !sc.active {
+			_ = sc.conn.SetReadDeadline(time.Now())
+		}
+	}
+}
+
+func (s *NetServer) isShutdown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shutdown
+}
+
+// setActive marks the connection as handling a command or waiting for the next one, it returns false
+// if the server is shutting down.
+func (s *NetServer) setActive(c *Conn, active bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sc, ok := s.conns[c]; ok {
+		sc.active = active
+	}
+	return !s.shutdown
+}
+
+func (s *NetServer) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+
+	c, err := NewCustomizedConn(conn, s.serverConf, s.provider, s.newHandler())
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.conns[c] = &netServerConn{conn: conn}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+	}()
+
+	for s.setActive(c, false) {
+		data, err := c.ReadPacket()
+		if err != nil {
+			if s.isShutdown() {
+				break
+			}
+			c.Close()
+			return
+		}
+
+		if !s.setActive(c, true) {
+			// the command arrived after the shutdown, answer it with the shutdown error
+			break
+		}
+
+		if err := c.handlePacket(data); err != nil || c.Conn == nil {
+			return
+		}
+	}
+
+	_ = c.writeError(NewDefaultError(ER_SERVER_SHUTDOWN))
+	c.Close()
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+type testSlowHandler struct {
+	EmptyHandler
+	started, release chan struct{}
+}
+
+func (h testSlowHandler) HandleQuery(query string) (*mysql.Result, error) {
+	if query == "SLOW" {
+		h.started <- struct{}{}
+		<-h.release
+	}
+	return &mysql.Result{}, nil
+}
+
+func TestNetServerShutdown(t *testing.T) {
+	h := testSlowHandler{started: make(chan struct{}), release: make(chan struct{})}
+	p := NewInMemoryProvider()
+	p.AddUser("root", "123")
+	s := NewNetServer(NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil), p, func() Handler {
+		return h
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(l)
+	}()
+
+	idle, err := client.Connect(l.Addr().String(), "root", "123", "")
+	require.NoError(t, err)
+	busy, err := client.Connect(l.Addr().String(), "root", "123", "")
+	require.NoError(t, err)
+
+	executed := make(chan error, 1)
+	go func() {
+		_, err := busy.Execute("SLOW")
+		executed <- err
+	}()
+	<-h.started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- s.Shutdown(context.Background())
+	}()
+	require.ErrorIs(t, <-served, ErrServerClosed)
+
+	// the idle connection is closed, the in flight command completes
+	_, err = idle.Execute("SELECT 1")
+	require.Error(t, err)
+
+	select {
+	case <-shutdown:
+		t.Fatal("shutdown did not wait for the command in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(h.release)
+	require.NoError(t, <-executed)
+	require.NoError(t, <-shutdown)
+
+	_, err = client.Connect(l.Addr().String(), "root", "123", "")
+	require.Error(t, err)
+	require.ErrorIs(t, s.Serve(l), ErrServerClosed)
+}
+
+func TestNetServerShutdownTimeout(t *testing.T) {
+	h := testSlowHandler{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(h.release)
+	p := NewInMemoryProvider()
+	p.AddUser("root", "123")
+	s := NewNetServer(NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil), p, func() Handler {
+		return h
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = s.Serve(l)
+	}()
+
+	busy, err := client.Connect(l.Addr().String(), "root", "123", "")
+	require.NoError(t, err)
+	executed := make(chan error, 1)
+	go func() {
+		_, err := busy.Execute("SLOW")
+		executed <- err
+	}()
+	<-h.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, s.Shutdown(ctx), context.DeadlineExceeded)
+
+	// the connection of the command in flight has been closed
+	require.Error(t, <-executed)
+}
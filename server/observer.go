@@ -0,0 +1,41 @@
+package server
+
+// ConnectionObserver is notified of the lifecycle of the connections of a Server, it can be used to implement
+// connection limits, audit logging or metrics. The methods are called from the goroutine using the connection,
+// they must be safe for concurrent use.
+type ConnectionObserver interface {
+	// OnConnect is called when a connection is created, before the handshake, only RemoteAddr is known yet.
+	// Returning an error rejects the connection and sends the error to the client, e.g.
+	// NewDefaultError(ER_CON_COUNT_ERROR) for a connection limit.
+	OnConnect(c *Conn) error
+	// OnAuthenticated is called once the client is authenticated, GetUser, GetDB and Capability are set.
+	OnAuthenticated(c *Conn)
+	// OnClose is called once when the connection is closed, including the connections rejected by OnConnect
+	// or failing the handshake.
+	OnClose(c *Conn)
+}
+
+// EmptyConnectionObserver can be embedded to implement only some methods of ConnectionObserver.
+type EmptyConnectionObserver struct{}
+
+func (o EmptyConnectionObserver) OnConnect(c *Conn) error {
+	return nil
+}
+
+func (o EmptyConnectionObserver) OnAuthenticated(c *Conn) {
+}
+
+func (o EmptyConnectionObserver) OnClose(c *Conn) {
+}
+
+// SetConnectionObserver sets the observer notified of the lifecycle of the connections of the server.
+func (s *Server) SetConnectionObserver(o ConnectionObserver) {
+	s.observer = o
+}
+
+func (c *Conn) observer() ConnectionObserver {
+	if c.serverConf == nil {
+		return nil
+	}
+	return c.serverConf.observer
+}
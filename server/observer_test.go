@@ -0,0 +1,85 @@
+package server
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+type testObserver struct {
+	EmptyConnectionObserver
+
+	mu     sync.Mutex
+	active int
+	users  []string
+	closed chan struct{}
+}
+
+func (o *testObserver) OnConnect(c *Conn) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.active >= 1 {
+		return mysql.NewDefaultError(mysql.ER_CON_COUNT_ERROR)
+	}
+	o.active++
+	return nil
+}
+
+func (o *testObserver) OnAuthenticated(c *Conn) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.users = append(o.users, c.GetUser())
+}
+
+func (o *testObserver) OnClose(c *Conn) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	// the connections rejected by OnConnect were not counted
+	if c.GetUser() != "" {
+		o.active--
+	}
+	o.closed <- struct{}{}
+}
+
+func TestConnectionObserver(t *testing.T) {
+	o := &testObserver{closed: make(chan struct{}, 10)}
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	svr.SetConnectionObserver(o)
+
+	addr := serveTestConns(t, svr, nil, nil)
+
+	db1, err := sql.Open("mysql", fmt.Sprintf("root:123@tcp(%s)/", addr))
+	require.NoError(t, err)
+	db1.SetMaxIdleConns(1)
+	require.NoError(t, db1.Ping())
+
+	// only one connection is allowed by the observer
+	db2, err := sql.Open("mysql", fmt.Sprintf("root:123@tcp(%s)/", addr))
+	require.NoError(t, err)
+	require.ErrorContains(t, db2.Ping(), "Too many connections")
+	db2.Close()
+
+	select {
+	case <-o.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("rejected connection not closed")
+	}
+
+	require.NoError(t, db1.Close())
+	select {
+	case <-o.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed")
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	require.Equal(t, []string{"root"}, o.users)
+	require.Equal(t, 0, o.active)
+}
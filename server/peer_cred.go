@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// PeerCred is the identity of the process at the other end of a unix socket connection, as told by the kernel
+// (SO_PEERCRED). It is only available on Linux.
+type PeerCred struct {
+	UID uint32
+	GID uint32
+	PID int32
+}
+
+// PeerCredentialProvider is an optional extension of CredentialProvider authenticating the users of 'auth_socket'
+// by the credentials of their process, instead of the default match of the OS user name, see SocketAuthPlugin.
+type PeerCredentialProvider interface {
+	CheckPeerCred(username string, cred PeerCred) (bool, error)
+}
+
+// PeerCred returns the credentials of the client process, ok is false if the connection is not a unix socket or
+// they are not available on the platform.
+func (c *Conn) PeerCred() (cred PeerCred, ok bool) {
+	if c.peerCred == nil {
+		return PeerCred{}, false
+	}
+	return *c.peerCred, true
+}
+
+func (c *Conn) isUnixSocket() bool {
+	return c.Conn != nil && c.LocalAddr() != nil && c.LocalAddr().Network() == "unix"
+}
+
+// readPeerCred returns the credentials of the peer of a unix socket connection, nil for the other connections.
+func readPeerCred(conn net.Conn) *PeerCred {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil
+	}
+	cred, err := getPeerCred(uc)
+	if err != nil {
+		return nil
+	}
+	return cred
+}
+
+// SocketAuthPlugin implements 'auth_socket' as the MySQL plugin of the same name: the clients connecting through
+// a unix socket are authenticated by the OS user running them, without password. The OS user must be the one named
+// by the password of the user in the credential provider, or the user itself if the password is empty, unless the
+// provider implements PeerCredentialProvider. It is registered by default and can be used as the method of some
+// users with an AuthMethodCredentialProvider, whatever method the client answers the handshake with.
+type SocketAuthPlugin struct{}
+
+func (SocketAuthPlugin) Name() string {
+	return AUTH_SOCKET
+}
+
+func (SocketAuthPlugin) AcceptsAnyClientMethod() bool {
+	return true
+}
+
+func (SocketAuthPlugin) Authenticate(c *Conn, authData []byte) error {
+	cred, ok := c.PeerCred()
+	if !ok {
+		return ErrAccessDeniedNoPassword
+	}
+
+	if p, ok := c.credentialProvider.(PeerCredentialProvider); ok {
+		match, err := p.CheckPeerCred(c.user, cred)
+		if err != nil {
+			return err
+		}
+		if !match {
+			return ErrAccessDeniedNoPassword
+		}
+		return nil
+	}
+
+	osUser, err := c.GetCredential()
+	if err != nil {
+		return err
+	}
+	if osUser == "" {
+		osUser = c.user
+	}
+	u, err := user.LookupId(strconv.FormatUint(uint64(cred.UID), 10))
+	if err != nil || u.Username != osUser {
+		return ErrAccessDeniedNoPassword
+	}
+	return nil
+}
+
+// ListenUnix listens on the unix socket at path, e.g. to pass it to NetServer.Serve. The socket file left by
+// a process which did not close its listener is removed first, the one of a running server is kept.
+func ListenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+		} else {
+			_ = os.Remove(path)
+		}
+	}
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+type peerCredProvider struct {
+	*InMemoryProvider
+	creds chan PeerCred
+}
+
+func (p *peerCredProvider) GetAuthMethod(username string) (string, error) {
+	if username == "sock" {
+		return mysql.AUTH_SOCKET, nil
+	}
+	return "", nil
+}
+
+func (p *peerCredProvider) CheckPeerCred(username string, cred PeerCred) (bool, error) {
+	p.creds <- cred
+	return cred.UID == uint32(os.Getuid()), nil
+}
+
+func TestUnixSocketPeerCred(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_CACHING_SHA2_PASSWORD, nil, nil)
+	// the header required from every TCP connection is not from the local ones of the socket
+	svr.SetProxyProtocol(&ProxyProtocol{})
+	p := &peerCredProvider{InMemoryProvider: NewInMemoryProvider(), creds: make(chan PeerCred, 1)}
+	p.AddUser("root", "123")
+	p.AddUser("sock", "")
+
+	path := filepath.Join(t.TempDir(), "mysql.sock")
+	l, err := ListenUnix(path)
+	require.NoError(t, err)
+	defer l.Close()
+
+	(&testServer{svr: svr, p: p}).serve(l)
+
+	// the user of 'auth_socket' is authenticated by the credentials of the client process
+	c, err := client.Connect(path, "sock", "", "")
+	require.NoError(t, err)
+	require.NoError(t, c.Ping())
+	c.Close()
+	cred := <-p.creds
+	require.EqualValues(t, os.Getuid(), cred.UID)
+	require.EqualValues(t, os.Getgid(), cred.GID)
+	require.EqualValues(t, os.Getpid(), cred.PID)
+
+	// the full authentication of 'caching_sha2_password' gets the password in clear text over a unix socket
+	c, err = client.Connect(path, "root", "123", "")
+	require.NoError(t, err)
+	c.Close()
+}
+
+func TestSocketAuthPluginNotUnixSocket(t *testing.T) {
+	c := &Conn{credentialProvider: NewInMemoryProvider(), user: "root"}
+	require.ErrorIs(t, SocketAuthPlugin{}.Authenticate(c, nil), ErrAccessDenied)
+}
+
+func TestListenUnixStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mysql.sock")
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+
+	// a running server keeps its socket
+	_, err = ListenUnix(path)
+	require.Error(t, err)
+
+	// the socket of a dead server is replaced
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+	l, err = ListenUnix(path)
+	require.NoError(t, err)
+	l.Close()
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"time"
+
+	. "github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/packet"
+	"github.com/pingcap/errors"
+)
+
+// packet read ahead by the pipeline of a connection
+type pipelinedPacket struct {
+	data     []byte
+	sequence uint8
+	next     uint8
+	err      error
+}
+
+// SetPipelineDepth enables the pipelining of the commands of the connections created with the server: the packets of
+// a client are read by another goroutine while the previous command is handled and its response written, up to depth
+// packets in advance, so the commands a client sends without waiting for their responses are ready as soon as the
+// previous one is done. The commands are still handled and answered one at a time, in order.
+//
+// The compressed connections are not pipelined. The read timeout of a pipelined connection is the one of the server,
+// it bounds the time waiting for a packet not read ahead yet.
+func (s *Server) SetPipelineDepth(depth int) {
+	s.pipelineDepth = depth
+}
+
+// startPipeline starts reading the packets ahead once the connection is authenticated.
+func (c *Conn) startPipeline(depth int) {
+	if depth <= 0 || c.Compression != MYSQL_COMPRESS_NONE {
+		return
+	}
+
+	// the reads of the pipeline have no deadline, the read timeout is applied by ReadPacket
+	_ = c.Conn.SetReadDeadline(time.Time{})
+
+	packets := make(chan pipelinedPacket, depth)
+	c.pipeline = packets
+	go readPipeline(c.Conn, packets, c.closing)
+}
+
+func readPipeline(conn *packet.Conn, packets chan<- pipelinedPacket, closing <-chan struct{}) {
+	defer close(packets)
+	for {
+		var p pipelinedPacket
+		p.data, p.sequence, p.next, p.err = conn.ReadUnsequencedPacket()
+		select {
+		case packets <- p:
+		case <-closing:
+			return
+		}
+		if p.err != nil {
+			return
+		}
+	}
+}
+
+// ReadPacket reads the next packet of the client, from the pipeline if the connection is pipelined.
+func (c *Conn) ReadPacket() ([]byte, error) {
+	if c.pipeline == nil {
+		return c.Conn.ReadPacket()
+	}
+
+	var timeout <-chan time.Time
+	if c.timeouts.Read > 0 {
+		t := time.NewTimer(c.timeouts.Read)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	for {
+		select {
+		case p, ok := <-c.pipeline:
+			if !ok {
+				return nil, errors.Trace(ErrBadConn)
+			}
+			if p.err != nil {
+				return nil, p.err
+			}
+			if c.handleLateSemiSyncAck(p) {
+				continue
+			}
+			if p.sequence != c.Sequence {
+				return nil, errors.Errorf("invalid sequence %d != %d", p.sequence, c.Sequence)
+			}
+			c.Sequence = p.next
+			return p.data, nil
+		case <-timeout:
+			// interrupt the pipeline, the connection is closed after a read timeout
+			_ = c.Conn.SetReadDeadline(time.Now())
+			return nil, errors.Wrapf(ErrBadConn, "no packet read in %v", c.timeouts.Read)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+type testPipelineHandler struct {
+	EmptyHandler
+	mu      sync.Mutex
+	queries []string
+}
+
+func (h *testPipelineHandler) HandleQuery(query string) (*mysql.Result, error) {
+	h.mu.Lock()
+	h.queries = append(h.queries, query)
+	n := len(h.queries)
+	h.mu.Unlock()
+	if query == "SLOW" {
+		time.Sleep(300 * time.Millisecond)
+	}
+	return &mysql.Result{InsertId: uint64(n)}, nil
+}
+
+func (h *testPipelineHandler) Queries() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string{}, h.queries...)
+}
+
+func TestPipeline(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	svr.SetPipelineDepth(2)
+	svr.SetTimeouts(Timeouts{Read: 100 * time.Millisecond})
+	h := &testPipelineHandler{}
+
+	closed := make(chan error, 1)
+	s := &testServer{svr: svr, h: h}
+	s.onConn = func(_ *Conn, _ Handler, err error) {
+		if err != nil {
+			closed <- err
+		}
+	}
+	s.onClose = func(_ *Conn, err error) { closed <- err }
+	addr := s.listen(t)
+
+	c, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+	defer c.Close()
+
+	// the commands are sent without waiting for the responses, the slow one outlasts the read timeout
+	queries := []string{"SLOW", "SELECT 1", "SELECT 2", "SELECT 3"}
+	for _, q := range queries {
+		c.ResetSequence()
+		require.NoError(t, c.WritePacket(append([]byte{0, 0, 0, 0, mysql.COM_QUERY}, q...)))
+	}
+	for i := range queries {
+		// the response of a command follows its packet
+		c.Sequence = 1
+		data, err := c.ReadPacket()
+		require.NoError(t, err)
+		require.Equal(t, byte(mysql.OK_HEADER), data[0])
+		// affected rows, then the insert id
+		require.Equal(t, byte(i+1), data[2])
+	}
+	require.Equal(t, queries, h.Queries())
+
+	// an idle connection is closed after the read timeout
+	select {
+	case err := <-closed:
+		require.ErrorIs(t, err, mysql.ErrBadConn)
+	case <-time.After(time.Second):
+		t.Fatal("idle connection not closed")
+	}
+}
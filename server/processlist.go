@@ -0,0 +1,277 @@
+package server
+
+import (
+	"encoding/binary"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// names of the commands in the process list, as MySQL shows them
+var commandNames = map[byte]string{
+	COM_SLEEP:               "Sleep",
+	COM_QUIT:                "Quit",
+	COM_INIT_DB:             "Init DB",
+	COM_QUERY:               "Query",
+	COM_FIELD_LIST:          "Field List",
+	COM_STATISTICS:          "Statistics",
+	COM_PROCESS_INFO:        "Processlist",
+	COM_DEBUG:               "Debug",
+	COM_PROCESS_KILL:        "Kill",
+	COM_PING:                "Ping",
+	COM_CHANGE_USER:         "Change user",
+	COM_BINLOG_DUMP:         "Binlog Dump",
+	COM_REGISTER_SLAVE:      "Register Replica",
+	COM_STMT_PREPARE:        "Prepare",
+	COM_STMT_EXECUTE:        "Execute",
+	COM_STMT_SEND_LONG_DATA: "Long Data",
+	COM_STMT_CLOSE:          "Close stmt",
+	COM_STMT_RESET:          "Reset stmt",
+	COM_SET_OPTION:          "Set option",
+	COM_STMT_FETCH:          "Fetch",
+	COM_BINLOG_DUMP_GTID:    "Binlog Dump GTID",
+	COM_RESET_CONNECTION:    "Reset Connection",
+}
+
+// length of the Info column of SHOW PROCESSLIST without FULL
+const processListInfoLen = 100
+
+var (
+	showProcessListRegexp   = regexp.MustCompile(`(?i)^\s*SHOW\s+(FULL\s+)?PROCESSLIST\s*;?\s*$`)
+	selectProcessListRegexp = regexp.MustCompile(
+		"(?i)^\\s*SELECT\\s+\\*\\s+FROM\\s+`?information_schema`?\\.`?processlist`?\\s*;?\\s*$")
+	showStatusRegexp = regexp.MustCompile(`(?i)^\s*SHOW\s+(?:GLOBAL\s+|SESSION\s+)?STATUS(?:\s+LIKE\s+'([^']*)')?\s*;?\s*$`)
+)
+
+// processInfo is the state of a connection shown in the process list, it is only updated by the goroutine
+// handling the connection and read by the others under Conn.processMu.
+type processInfo struct {
+	user    string
+	host    string
+	db      string
+	command string
+	info    *string
+	since   time.Time
+}
+
+// ProcessPrivilegeHandler is an optional extension of Handler granting the PROCESS privilege to users: the process
+// list shows them the connections of all the users, otherwise only their own connections as MySQL does.
+type ProcessPrivilegeHandler interface {
+	//returns whether user has the PROCESS privilege
+	HasProcessPrivilege(user string) bool
+}
+
+// SetProcessListEmulation enables the answers of the server itself to the queries of admin tools: SHOW [FULL] PROCESSLIST
+// and SELECT * FROM information_schema.processlist, listing the authenticated connections of the server and their running
+// command, of the user only unless the handler is a ProcessPrivilegeHandler granting the privilege, and SHOW STATUS, with Threads_connected, Threads_running, Questions and Uptime. The other queries are passed
+// to the Handler as usual.
+func (s *Server) SetProcessListEmulation(enabled bool) {
+	s.processList = enabled
+}
+
+func (c *Conn) processListEnabled() bool {
+	return c.serverConf != nil && c.serverConf.processList
+}
+
+// startProcess records the command the connection is running.
+func (c *Conn) startProcess(data []byte) {
+	if c.serverConf != nil {
+		c.serverConf.questions.Add(1)
+	}
+	if !c.processListEnabled() {
+		return
+	}
+	c.setProcess(data[0], c.commandInfo(data))
+}
+
+// endProcess records the connection is idle.
+func (c *Conn) endProcess() {
+	if !c.processListEnabled() {
+		return
+	}
+	c.setProcess(COM_SLEEP, nil)
+}
+
+// CommandName returns the name of a command as MySQL shows it in the process list, e.g. "Query" for COM_QUERY.
+func CommandName(cmd byte) string {
+	if name, ok := commandNames[cmd]; ok {
+		return name
+	}
+	return "Command " + strconv.Itoa(int(cmd))
+}
+
+func (c *Conn) setProcess(cmd byte, info *string) {
+	c.processMu.Lock()
+	c.process = processInfo{
+		user:    c.user,
+		host:    c.RemoteAddr().String(),
+		db:      c.db,
+		command: CommandName(cmd),
+		info:    info,
+		since:   time.Now(),
+	}
+	c.processMu.Unlock()
+}
+
+// commandInfo returns the statement run by a command, shown in the Info column of the process list.
+func (c *Conn) commandInfo(data []byte) *string {
+	var info string
+	switch data[0] {
+	case COM_QUERY, COM_STMT_PREPARE:
+		info = string(data[1:])
+	case COM_STMT_EXECUTE, COM_STMT_FETCH:
+		if len(data) < 5 {
+			return nil
+		}
+		s, ok := c.stmts[binary.LittleEndian.Uint32(data[1:5])]
+		if !ok {
+			return nil
+		}
+		info = s.Query
+	default:
+		return nil
+	}
+	return &info
+}
+
+// handleProcessListQuery answers the queries handled by the process list emulation, ok is false for the other queries.
+func (c *Conn) handleProcessListQuery(query string) (r *Result, ok bool, err error) {
+	if !c.processListEnabled() {
+		return nil, false, nil
+	}
+
+	user := c.processListUser()
+	if m := showProcessListRegexp.FindStringSubmatch(query); m != nil {
+		r, err = c.serverConf.processListResult([]string{"Id", "User", "Host", "db", "Command", "Time", "State", "Info"}, user, m[1] != "")
+		return r, true, err
+	}
+	if selectProcessListRegexp.MatchString(query) {
+		r, err = c.serverConf.processListResult([]string{"ID", "USER", "HOST", "DB", "COMMAND", "TIME", "STATE", "INFO"}, user, true)
+		return r, true, err
+	}
+	if m := showStatusRegexp.FindStringSubmatch(query); m != nil {
+		r, err = c.serverConf.statusResult(m[1])
+		return r, true, err
+	}
+	return nil, false, nil
+}
+
+// processListUser returns the user whose connections the process list shows to the connection, empty for all the
+// users if it has the PROCESS privilege.
+func (c *Conn) processListUser() string {
+	if h, ok := c.h.(ProcessPrivilegeHandler); ok && h.HasProcessPrivilege(c.user) {
+		return ""
+	}
+	return c.user
+}
+
+// processListResult lists the connections of user, of all the users if empty.
+func (s *Server) processListResult(names []string, user string, full bool) (*Result, error) {
+	var rows [][]interface{}
+	s.conns.Range(func(_, v interface{}) bool {
+		c := v.(*Conn)
+		c.processMu.Lock()
+		p, owner := c.process, c.owner
+		c.processMu.Unlock()
+		if user != "" && owner != user {
+			return true
+		}
+
+		var info interface{}
+		if p.info != nil {
+			i := *p.info
+			if !full && len(i) > processListInfoLen {
+				i = i[:processListInfoLen]
+			}
+			info = i
+		}
+
+		var db interface{}
+		if p.db != "" {
+			db = p.db
+		}
+
+		state := ""
+		if p.command != commandNames[COM_SLEEP] {
+			state = "executing"
+		}
+
+		rows = append(rows, []interface{}{
+			uint64(c.connectionID), p.user, p.host, db, p.command, int64(time.Since(p.since).Seconds()), state, info,
+		})
+		return true
+	})
+
+	sort.Slice(rows, func(i, j int) bool { return rows[i][0].(uint64) < rows[j][0].(uint64) })
+
+	rs, err := BuildSimpleTextResultset(names, rows)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Resultset: rs}, nil
+}
+
+func (s *Server) statusResult(like string) (*Result, error) {
+	var connected, running int
+	s.conns.Range(func(_, v interface{}) bool {
+		c := v.(*Conn)
+		connected++
+		c.processMu.Lock()
+		if c.process.command != commandNames[COM_SLEEP] {
+			running++
+		}
+		c.processMu.Unlock()
+		return true
+	})
+
+	status := [][]interface{}{
+		{"Questions", strconv.FormatUint(s.questions.Get(), 10)},
+		{"Threads_connected", strconv.Itoa(connected)},
+		{"Threads_running", strconv.Itoa(running)},
+		{"Uptime", strconv.FormatInt(int64(time.Since(s.started).Seconds()), 10)},
+	}
+
+	var re *regexp.Regexp
+	if like != "" {
+		re = likeRegexp(like)
+	}
+	var rows [][]interface{}
+	for _, row := range status {
+		if re == nil || re.MatchString(row[0].(string)) {
+			rows = append(rows, row)
+		}
+	}
+
+	rs, err := BuildSimpleTextResultset([]string{"Variable_name", "Value"}, rows)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Resultset: rs}, nil
+}
+
+// likeRegexp returns the regexp of a LIKE pattern, matching case-insensitively as MySQL does for status variables.
+func likeRegexp(pattern string) *regexp.Regexp {
+	var re strings.Builder
+	re.WriteString("(?is)^")
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '%':
+			re.WriteString(".*")
+		case '_':
+			re.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	re.WriteString("$")
+	return regexp.MustCompile(re.String())
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+type testProcessListHandler struct {
+	testContextHandler
+	release chan struct{}
+}
+
+func (h *testProcessListHandler) HandleQueryContext(ctx context.Context, query string) (*mysql.Result, error) {
+	if query == "SELECT SLEEP(10)" {
+		<-h.release
+	}
+	return &mysql.Result{AffectedRows: 1}, nil
+}
+
+func TestProcessListEmulation(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	svr.SetProcessListEmulation(true)
+	h := &testProcessListHandler{release: make(chan struct{})}
+
+	addr := serveTestConns(t, svr, nil, h)
+
+	busy, err := client.Connect(addr, "root", "123", "test")
+	require.NoError(t, err)
+	defer busy.Close()
+	admin, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+	defer admin.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := busy.Execute("SELECT SLEEP(10)")
+		done <- err
+	}()
+
+	var r *mysql.Result
+	require.Eventually(t, func() bool {
+		r, err = admin.Execute("SHOW FULL PROCESSLIST")
+		require.NoError(t, err)
+		info, _ := r.GetStringByName(0, "Info")
+		return info == "SELECT SLEEP(10)"
+	}, 5*time.Second, 5*time.Millisecond)
+
+	// the rows are sorted by connection id, the busy connection was created first
+	require.Equal(t, 2, r.RowNumber())
+	id, _ := r.GetUintByName(0, "Id")
+	require.Equal(t, uint64(busy.GetConnectionID()), id)
+	for column, expected := range map[string]string{"User": "root", "db": "test", "Command": "Query", "State": "executing"} {
+		v, _ := r.GetStringByName(0, column)
+		require.Equal(t, expected, v, column)
+	}
+	for column, expected := range map[string]string{"Command": "Query", "Info": "SHOW FULL PROCESSLIST"} {
+		v, _ := r.GetStringByName(1, column)
+		require.Equal(t, expected, v, column)
+	}
+	isNull, _ := r.IsNullByName(1, "db")
+	require.True(t, isNull)
+
+	r, err = admin.Execute("SHOW STATUS LIKE 'threads\\_%'")
+	require.NoError(t, err)
+	require.Equal(t, 2, r.RowNumber())
+	for row, expected := range []string{"Threads_connected", "Threads_running"} {
+		name, _ := r.GetString(row, 0)
+		require.Equal(t, expected, name)
+		v, _ := r.GetString(row, 1)
+		require.Equal(t, "2", v)
+	}
+
+	close(h.release)
+	require.NoError(t, <-done)
+
+	r, err = admin.Execute("select * from information_schema.PROCESSLIST")
+	require.NoError(t, err)
+	command, _ := r.GetStringByName(0, "COMMAND")
+	require.Equal(t, "Sleep", command)
+	isNull, _ = r.IsNullByName(0, "INFO")
+	require.True(t, isNull)
+
+	// the other queries are passed to the handler
+	r, err = admin.Execute("SHOW TABLES")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), r.AffectedRows)
+}
+
+type testProcessPrivilegeHandler struct {
+	EmptyHandler
+}
+
+func (h testProcessPrivilegeHandler) HasProcessPrivilege(user string) bool {
+	return user == "root"
+}
+
+func TestProcessListPrivilege(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	svr.SetProcessListEmulation(true)
+	p := NewInMemoryProvider()
+	p.AddUser("root", "123")
+	p.AddUser("alice", "abc")
+	addr := serveTestConns(t, svr, p, testProcessPrivilegeHandler{})
+
+	users := func(user string, password string) []string {
+		c, err := client.Connect(addr, user, password, "")
+		require.NoError(t, err)
+		defer c.Close()
+		r, err := c.Execute("SHOW PROCESSLIST")
+		require.NoError(t, err)
+		var users []string
+		for i := 0; i < r.RowNumber(); i++ {
+			u, _ := r.GetStringByName(i, "User")
+			users = append(users, u)
+		}
+		return users
+	}
+
+	other, err := client.Connect(addr, "alice", "abc", "")
+	require.NoError(t, err)
+	defer other.Close()
+	root, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+	defer root.Close()
+
+	// only the users with the PROCESS privilege see the connections of the others
+	require.Equal(t, []string{"alice", "alice"}, users("alice", "abc"))
+	require.Equal(t, []string{"alice", "root", "root"}, users("root", "123"))
+}
+
+func TestLikeRegexp(t *testing.T) {
+	require.True(t, likeRegexp("Threads%").MatchString("Threads_running"))
+	require.True(t, likeRegexp("threads_running").MatchString("Threads_running"))
+	require.True(t, likeRegexp("Threads_runnin_").MatchString("Threads_running"))
+	require.False(t, likeRegexp("Threads\\_c%").MatchString("Threads_running"))
+	require.True(t, likeRegexp("Up.ime").MatchString("Up.ime"))
+	require.False(t, likeRegexp("Up.ime").MatchString("Uptime"))
+}
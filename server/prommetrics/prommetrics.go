@@ -0,0 +1,110 @@
+// Package prommetrics exports the metrics of a go-mysql server to Prometheus.
+//
+//	m := prommetrics.New("mysql_proxy")
+//	prometheus.MustRegister(m)
+//	svr.SetMetrics(m)
+package prommetrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/atoonk/go-mysql/server"
+)
+
+// Metrics implements server.Metrics with Prometheus collectors, it is itself a prometheus.Collector to register.
+type Metrics struct {
+	commands     *prometheus.CounterVec
+	latency      *prometheus.HistogramVec
+	bytesRead    prometheus.Counter
+	bytesWritten prometheus.Counter
+	errors       *prometheus.CounterVec
+	connections  prometheus.Gauge
+}
+
+var _ server.Metrics = (*Metrics)(nil)
+
+// New returns the metrics named with the given namespace, e.g. "mysql_proxy_commands_total".
+func New(namespace string) *Metrics {
+	return &Metrics{
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "commands_total",
+			Help:      "Number of commands dispatched by command.",
+		}, []string{"command"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "command_duration_seconds",
+			Help:      "Time spent handling a command and writing its response by command.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"command"}),
+		bytesRead: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "read_bytes_total",
+			Help:      "Number of bytes read from the clients.",
+		}),
+		bytesWritten: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "written_bytes_total",
+			Help:      "Number of bytes written to the clients.",
+		}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "errors_total",
+			Help:      "Number of errors sent to the clients by MySQL error code.",
+		}, []string{"code"}),
+		connections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "connections",
+			Help:      "Number of authenticated connections.",
+		}),
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.commands, m.latency, m.bytesRead, m.bytesWritten, m.errors, m.connections}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+func (m *Metrics) CommandDispatched(cmd byte) {
+	m.commands.WithLabelValues(server.CommandName(cmd)).Inc()
+}
+
+func (m *Metrics) CommandLatency(cmd byte, d time.Duration) {
+	m.latency.WithLabelValues(server.CommandName(cmd)).Observe(d.Seconds())
+}
+
+func (m *Metrics) BytesRead(n int) {
+	m.bytesRead.Add(float64(n))
+}
+
+func (m *Metrics) BytesWritten(n int) {
+	m.bytesWritten.Add(float64(n))
+}
+
+func (m *Metrics) Error(code uint16) {
+	m.errors.WithLabelValues(strconv.Itoa(int(code))).Inc()
+}
+
+func (m *Metrics) ConnectionOpened() {
+	m.connections.Inc()
+}
+
+func (m *Metrics) ConnectionClosed() {
+	m.connections.Dec()
+}
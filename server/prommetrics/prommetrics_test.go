@@ -0,0 +1,48 @@
+package prommetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New("test")
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(m))
+
+	m.CommandDispatched(mysql.COM_QUERY)
+	m.CommandLatency(mysql.COM_QUERY, 10*time.Millisecond)
+	m.BytesRead(10)
+	m.BytesWritten(20)
+	m.Error(mysql.ER_ACCESS_DENIED_ERROR)
+	m.ConnectionOpened()
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	values := make(map[string]float64)
+	for _, f := range families {
+		metric := f.GetMetric()[0]
+		switch {
+		case metric.Counter != nil:
+			values[f.GetName()] = metric.Counter.GetValue()
+		case metric.Gauge != nil:
+			values[f.GetName()] = metric.Gauge.GetValue()
+		case metric.Histogram != nil:
+			values[f.GetName()] = float64(metric.Histogram.GetSampleCount())
+			require.Equal(t, "Query", metric.GetLabel()[0].GetValue())
+		}
+	}
+	require.Equal(t, map[string]float64{
+		"test_commands_total":           1,
+		"test_command_duration_seconds": 1,
+		"test_read_bytes_total":         10,
+		"test_written_bytes_total":      20,
+		"test_errors_total":             1,
+		"test_connections":              1,
+	}, values)
+}
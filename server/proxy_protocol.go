@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net"
+	"time"
+
+	. "github.com/atoonk/go-mysql/mysql"
+	"github.com/pingcap/errors"
+)
+
+// ProxyProtocol enables the PROXY protocol (v1 and v2) on the connections of a server behind a proxy such as
+// HAProxy or an AWS NLB: the header sent by the proxy before the MySQL handshake is read, and the address of
+// the client it tells is returned by Conn.RemoteAddr.
+type ProxyProtocol struct {
+	// TrustedProxies are the networks of the proxies, the connections from other addresses are direct ones
+	// and must not send a header. The header is required from every connection if empty, except the local ones
+	// of the unix sockets which never send one.
+	TrustedProxies []*net.IPNet
+	// HeaderTimeout bounds the time waiting for the header, zero disables it
+	HeaderTimeout time.Duration
+}
+
+// SetProxyProtocol enables the PROXY protocol on the connections created with the server, nil disables it.
+func (s *Server) SetProxyProtocol(p *ProxyProtocol) {
+	s.proxyProtocol = p
+}
+
+// trusts returns whether conn comes from a trusted proxy, the connections of the unix sockets being local ones.
+func (p *ProxyProtocol) trusts(conn net.Conn) bool {
+	if addr := conn.LocalAddr(); addr != nil && addr.Network() == "unix" {
+		return false
+	}
+	addr := conn.RemoteAddr()
+	if len(p.TrustedProxies) == 0 {
+		return true
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, n := range p.TrustedProxies {
+		if n.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxiedConn is a connection proxied by a trusted proxy, its addresses are the ones of its PROXY header.
+type proxiedConn struct {
+	net.Conn
+	header *ProxyHeader
+}
+
+func (c *proxiedConn) RemoteAddr() net.Addr {
+	return c.header.Source
+}
+
+func (c *proxiedConn) LocalAddr() net.Addr {
+	return c.header.Destination
+}
+
+// acceptProxyHeader reads the PROXY header of a connection from a trusted proxy, conn is returned as is for the
+// other connections and the ones not proxied (e.g. the health checks of the proxy). conn is closed on error.
+func (s *Server) acceptProxyHeader(conn net.Conn) (net.Conn, error) {
+	p := s.proxyProtocol
+	if p == nil || !p.trusts(conn) {
+		return conn, nil
+	}
+
+	if p.HeaderTimeout > 0 {
+		if err := conn.SetReadDeadline(time.Now().Add(p.HeaderTimeout)); err != nil {
+			conn.Close()
+			return nil, errors.Trace(err)
+		}
+		defer conn.SetReadDeadline(time.Time{})
+	}
+
+	h, err := ReadProxyHeader(conn)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Annotatef(err, "read PROXY header from %s", conn.RemoteAddr())
+	}
+	if h.Source == nil || h.Destination == nil {
+		return conn, nil
+	}
+	return &proxiedConn{Conn: conn, header: h}, nil
+}
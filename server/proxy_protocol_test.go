@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+type testRemoteAddrProvider struct {
+	*InMemoryProvider
+	remoteAddrs chan net.Addr
+}
+
+func (p testRemoteAddrProvider) GetCredentialFrom(username string, remoteAddr net.Addr) (string, bool, error) {
+	p.remoteAddrs <- remoteAddr
+	return p.GetCredential(username)
+}
+
+func TestProxyProtocol(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	svr.SetProxyProtocol(&ProxyProtocol{TrustedProxies: []*net.IPNet{loopback}, HeaderTimeout: 100 * time.Millisecond})
+	p := testRemoteAddrProvider{NewInMemoryProvider(), make(chan net.Addr, 1)}
+	p.AddUser("root", "123")
+
+	// addresses of the accepted connections, nil if the handshake failed
+	conns := make(chan []net.Addr, 1)
+	addr := (&testServer{svr: svr, p: p, onConn: func(co *Conn, _ Handler, err error) {
+		if err != nil {
+			conns <- nil
+		} else {
+			conns <- []net.Addr{co.RemoteAddr(), co.LocalAddr()}
+		}
+	}}).listen(t)
+
+	src := &net.TCPAddr{IP: net.ParseIP("192.0.2.1").To4(), Port: 56324}
+	for _, version := range []byte{1, 2} {
+		c, err := client.Connect(addr, "root", "123", "", func(c *client.Conn) {
+			c.SetProxyHeader(mysql.NewProxyHeader(version, src, nil))
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, src, <-p.remoteAddrs)
+		addrs := <-conns
+		require.Equal(t, src, addrs[0])
+		require.Equal(t, addr, addrs[1].String())
+		require.NoError(t, c.Ping())
+		require.NoError(t, c.Close())
+	}
+
+	// a header not proxying the connection keeps its real address
+	c, err := client.Connect(addr, "root", "123", "", func(c *client.Conn) {
+		c.SetProxyHeader(mysql.NewProxyHeader(2, nil, nil))
+	})
+	require.NoError(t, err)
+	<-p.remoteAddrs
+	require.Equal(t, c.LocalAddr().String(), (<-conns)[0].String())
+	require.NoError(t, c.Close())
+
+	// the header is required from a trusted proxy, the connection is closed once the header timeout expires
+	_, err = client.Connect(addr, "root", "123", "")
+	require.Error(t, err)
+	require.Nil(t, <-conns)
+}
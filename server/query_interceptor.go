@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// QueryHandlerFunc handles a query, it is the rest of the interceptor chain passed to a QueryInterceptor.
+type QueryHandlerFunc func(ctx context.Context, query string) (*Result, error)
+
+// QueryInterceptor is called with every query of COM_QUERY, including each statement of a multi-statement query,
+// before it is handled. It may inspect or rewrite the query and pass it on by calling next, or answer it itself
+// with a Result or an error without calling next, e.g. to block some queries or strip their comments.
+//
+// ctx is the one passed to HandlerWithContext, the connection can be retrieved from it with ConnFromContext.
+type QueryInterceptor func(ctx context.Context, query string, next QueryHandlerFunc) (*Result, error)
+
+// AddQueryInterceptors appends interceptors to the chain run for the queries of the connections of the server,
+// the first added is called first. Interceptors are not run for prepared statements.
+func (s *Server) AddQueryInterceptors(interceptors ...QueryInterceptor) {
+	s.queryInterceptors = append(s.queryInterceptors, interceptors...)
+}
+
+// interceptQuery runs the query through the interceptors of the server, the last one calling handle.
+func (c *Conn) interceptQuery(query string, handle QueryHandlerFunc) (*Result, error) {
+	if c.serverConf != nil {
+		handle = chainQueryInterceptors(c.serverConf.queryInterceptors, handle)
+	}
+	return handle(c.Context(), query)
+}
+
+func chainQueryInterceptors(interceptors []QueryInterceptor, handle QueryHandlerFunc) QueryHandlerFunc {
+	if len(interceptors) == 0 {
+		return handle
+	}
+	next := chainQueryInterceptors(interceptors[1:], handle)
+	return func(ctx context.Context, query string) (*Result, error) {
+		return interceptors[0](ctx, query, next)
+	}
+}
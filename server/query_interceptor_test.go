@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+type testQueryHandler struct {
+	EmptyHandler
+	queries []string
+}
+
+func (h *testQueryHandler) HandleQuery(query string) (*mysql.Result, error) {
+	h.queries = append(h.queries, query)
+	return &mysql.Result{AffectedRows: 1}, nil
+}
+
+func TestQueryInterceptors(t *testing.T) {
+	var calls []string
+	s := NewServerWithConfig(ServerConfig{})
+	s.AddQueryInterceptors(
+		func(ctx context.Context, query string, next QueryHandlerFunc) (*mysql.Result, error) {
+			calls = append(calls, "strip")
+			if i := strings.Index(query, "/*"); i >= 0 {
+				query = strings.TrimSpace(query[:i])
+			}
+			return next(ctx, query)
+		},
+		func(ctx context.Context, query string, next QueryHandlerFunc) (*mysql.Result, error) {
+			calls = append(calls, "block")
+			c, ok := ConnFromContext(ctx)
+			require.True(t, ok)
+			if strings.HasPrefix(strings.ToUpper(query), "DROP") {
+				return nil, mysql.NewError(mysql.ER_SPECIFIC_ACCESS_DENIED_ERROR, "DROP is blocked for "+c.GetUser())
+			}
+			return next(ctx, query)
+		},
+	)
+
+	h := &testQueryHandler{}
+	c := &Conn{h: h, serverConf: s, user: "alice"}
+
+	r := c.dispatch(append([]byte{mysql.COM_QUERY}, "UPDATE t SET a = 1 /* shard:2 */"...))
+	require.Equal(t, &mysql.Result{AffectedRows: 1}, r)
+	require.Equal(t, []string{"UPDATE t SET a = 1"}, h.queries)
+	require.Equal(t, []string{"strip", "block"}, calls)
+
+	err := c.dispatch(append([]byte{mysql.COM_QUERY}, "DROP TABLE t"...))
+	require.EqualValues(t, mysql.ER_SPECIFIC_ACCESS_DENIED_ERROR, err.(*mysql.MyError).Code)
+	require.Len(t, h.queries, 1)
+}
@@ -2,29 +2,88 @@ package server
 
 import (
 	"encoding/binary"
+	"time"
 
 	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/replication"
 )
 
-func parseBinlogDump(data []byte) (mysql.Position, error) {
+// BinlogDumpFlagsHandler is an optional extension of ReplicationHandler getting the flags of the binlog dumps,
+// its methods are called instead of HandleBinlogDump and HandleBinlogDumpGTID if the handler implements it.
+//
+// With replication.BINLOG_DUMP_NON_BLOCK, e.g. set by mysqlbinlog --read-from-remote-server without
+// --stop-never, the handler ends the dump with replication.ErrSyncClosed once the last event is sent, the
+// replica then gets an EOF. For the handlers not implementing it, a non-blocking dump ends as soon as no
+// event is queued in the streamer, so they must add the events before returning it.
+type BinlogDumpFlagsHandler interface {
+	//handle COM_BINLOG_DUMP with its flags
+	HandleBinlogDumpFlags(pos mysql.Position, flags uint16) (*replication.BinlogStreamer, error)
+	//handle COM_BINLOG_DUMP_GTID with its flags
+	HandleBinlogDumpGTIDFlags(gtidSet *mysql.MysqlGTIDSet, flags uint16) (*replication.BinlogStreamer, error)
+}
+
+// BinlogBatch batches the binlog events written to the replicas, so that a fast stream is not written with a
+// system call per event. The events are written as soon as the queue of the streamer is empty, the batch only
+// grows while the connection is slower than the producer of the events, which blocks once the queue is full.
+type BinlogBatch struct {
+	// Size is the number of bytes of events written at once, zero writes every event as soon as it is queued
+	Size int
+	// Interval bounds the time the first event of a batch stays buffered, zero only writes a batch once it reaches Size
+	Interval time.Duration
+}
+
+// SetBinlogBatch sets the batching of the binlog events written by the connections of the server.
+func (s *Server) SetBinlogBatch(b BinlogBatch) {
+	s.binlogBatch = b
+}
+
+func (c *Conn) binlogBatch() BinlogBatch {
+	if c.serverConf == nil {
+		return BinlogBatch{}
+	}
+	return c.serverConf.binlogBatch
+}
+
+// nonBlockingBinlogDump is the response to a non-blocking binlog dump of a handler not implementing
+// BinlogDumpFlagsHandler.
+type nonBlockingBinlogDump struct {
+	s *replication.BinlogStreamer
+}
+
+// binlogDumpResponse returns the response to a binlog dump answered by s, drain tells to end it with EOF
+// once the queued events are sent.
+func binlogDumpResponse(s *replication.BinlogStreamer, err error, drain bool) interface{} {
+	if err != nil {
+		return err
+	}
+	if drain {
+		return nonBlockingBinlogDump{s}
+	}
+	return s
+}
+
+func parseBinlogDump(data []byte) (mysql.Position, uint16, error) {
 	if len(data) < 10 {
-		return mysql.Position{}, mysql.ErrMalformPacket
+		return mysql.Position{}, 0, mysql.ErrMalformPacket
 	}
 	var p mysql.Position
 	p.Pos = binary.LittleEndian.Uint32(data[0:4])
+	flags := binary.LittleEndian.Uint16(data[4:6])
 	p.Name = string(data[10:])
 
-	return p, nil
+	return p, flags, nil
 }
 
-func parseBinlogDumpGTID(data []byte) (*mysql.MysqlGTIDSet, error) {
-	if len(data) < 15 {
-		return nil, mysql.ErrMalformPacket
+func parseBinlogDumpGTID(data []byte) (*mysql.MysqlGTIDSet, uint16, error) {
+	if len(data) < 10 {
+		return nil, 0, mysql.ErrMalformPacket
 	}
-	lenPosName := binary.LittleEndian.Uint32(data[11:15])
+	flags := binary.LittleEndian.Uint16(data[0:2])
+	lenPosName := binary.LittleEndian.Uint32(data[6:10])
 	if len(data) < 22+int(lenPosName) {
-		return nil, mysql.ErrMalformPacket
+		return nil, 0, mysql.ErrMalformPacket
 	}
 
-	return mysql.DecodeMysqlGTIDSet(data[22+lenPosName:])
+	gtidSet, err := mysql.DecodeMysqlGTIDSet(data[22+lenPosName:])
+	return gtidSet, flags, err
 }
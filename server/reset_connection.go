@@ -0,0 +1,37 @@
+package server
+
+import (
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+type ResetConnectionHandler interface {
+	//handle COM_RESET_CONNECTION, called once the prepared statements are closed,
+	//the handler should reset any state bound to the session, e.g. user variables and temporary tables
+	HandleResetConnection() error
+}
+
+// handleResetConnection resets the session without re-authenticating the user, as MySQL does:
+// the prepared statements are closed and the transaction and warnings state is cleared.
+func (c *Conn) handleResetConnection() error {
+	if err := c.closeStmts(); err != nil {
+		return err
+	}
+
+	c.UnsetStatus(SERVER_STATUS_IN_TRANS)
+	c.clearWarnings()
+
+	if h, ok := c.h.(ResetConnectionHandler); ok {
+		return h.HandleResetConnection()
+	}
+	return nil
+}
+
+// closeStmts closes all the prepared statements of the connection.
+func (c *Conn) closeStmts() error {
+	for id := range c.stmts {
+		if err := c.CloseStmt(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/packet"
+	mockconn "github.com/atoonk/go-mysql/test_util/conn"
+	"github.com/stretchr/testify/require"
+)
+
+type testResetConnectionHandler struct {
+	EmptyHandler
+	closed, resets int
+}
+
+func (h *testResetConnectionHandler) HandleStmtClose(context interface{}) error {
+	h.closed++
+	return nil
+}
+
+func (h *testResetConnectionHandler) HandleResetConnection() error {
+	h.resets++
+	return nil
+}
+
+func TestResetConnection(t *testing.T) {
+	h := &testResetConnectionHandler{}
+	c := &Conn{
+		Conn:  packet.NewConn(&mockconn.MockConn{}),
+		h:     h,
+		user:  "root",
+		db:    "test",
+		stmts: map[uint32]*Stmt{1: {ID: 1}, 2: {ID: 2}},
+	}
+	c.SetStatus(mysql.SERVER_STATUS_IN_TRANS | mysql.SERVER_STATUS_AUTOCOMMIT)
+	c.SetWarnings(3)
+
+	v := c.dispatch([]byte{mysql.COM_RESET_CONNECTION})
+	require.Nil(t, v)
+	require.Empty(t, c.stmts)
+	require.Equal(t, 2, h.closed)
+	require.Equal(t, 1, h.resets)
+	require.False(t, c.HasStatus(mysql.SERVER_STATUS_IN_TRANS))
+	require.True(t, c.HasStatus(mysql.SERVER_STATUS_AUTOCOMMIT))
+	require.Zero(t, c.warnings)
+	// the session stays authenticated
+	require.Equal(t, "root", c.GetUser())
+	require.Equal(t, "test", c.GetDB())
+
+	// without the hook, the reset still succeeds
+	c.h = EmptyHandler{}
+	require.Nil(t, c.dispatch([]byte{mysql.COM_RESET_CONNECTION}))
+}
@@ -1,68 +1,98 @@
 package server
 
 import (
-	"context"
 	"fmt"
+	"io"
+	"time"
 
 	. "github.com/atoonk/go-mysql/mysql"
 	"github.com/atoonk/go-mysql/replication"
+	"github.com/atoonk/go-mysql/utils"
+	"github.com/pingcap/errors"
 )
 
 func (c *Conn) writeOK(r *Result) error {
-	fmt.Println("writeOK")
 	if r == nil {
 		r = &Result{}
 	}
 
 	r.Status |= c.status
 
-	data := make([]byte, 4, 32)
+	sessionTrack := c.capability&CLIENT_SESSION_TRACK > 0 && len(r.SessionStateChanges) > 0
+	if sessionTrack {
+		r.Status |= SERVER_SESSION_STATE_CHANGED
+	}
+
+	b := utils.ByteSliceGet(4)
+	defer utils.ByteSlicePut(b)
 
-	data = append(data, OK_HEADER)
+	b.B = append(b.B, OK_HEADER)
 
-	data = append(data, PutLengthEncodedInt(r.AffectedRows)...)
-	data = append(data, PutLengthEncodedInt(r.InsertId)...)
+	b.B = AppendLengthEncodedInteger(b.B, r.AffectedRows)
+	b.B = AppendLengthEncodedInteger(b.B, r.InsertId)
 
 	if c.capability&CLIENT_PROTOCOL_41 > 0 {
-		data = append(data, byte(r.Status), byte(r.Status>>8))
-		data = append(data, byte(r.Warnings), byte(r.Warnings>>8))
+		b.B = append(b.B, byte(r.Status), byte(r.Status>>8))
+		b.B = append(b.B, byte(r.Warnings), byte(r.Warnings>>8))
 	}
 
-	return c.WritePacket(data)
+	if c.capability&CLIENT_SESSION_TRACK > 0 {
+		// the info is length encoded, followed by the session state info
+		if sessionTrack || len(r.Info) > 0 {
+			b.B = AppendLengthEncodedString(b.B, []byte(r.Info))
+		}
+		if sessionTrack {
+			var state []byte
+			for _, change := range r.SessionStateChanges {
+				state = append(state, change.Dump()...)
+			}
+			b.B = AppendLengthEncodedString(b.B, state)
+		}
+	} else {
+		b.B = append(b.B, r.Info...)
+	}
+
+	return c.WritePacket(b.B)
 }
 
 func (c *Conn) writeError(e error) error {
 	var m *MyError
 	var ok bool
-	if m, ok = e.(*MyError); !ok {
+	// the error of a handler may be traced
+	if m, ok = errors.Cause(e).(*MyError); !ok {
 		m = NewError(ER_UNKNOWN_ERROR, e.Error())
 	}
+	if c.metrics != nil {
+		c.metrics.Error(m.Code)
+	}
 
-	data := make([]byte, 4, 16+len(m.Message))
+	b := utils.ByteSliceGet(4)
+	defer utils.ByteSlicePut(b)
 
-	data = append(data, ERR_HEADER)
-	data = append(data, byte(m.Code), byte(m.Code>>8))
+	b.B = append(b.B, ERR_HEADER)
+	b.B = append(b.B, byte(m.Code), byte(m.Code>>8))
 
 	if c.capability&CLIENT_PROTOCOL_41 > 0 {
-		data = append(data, '#')
-		data = append(data, m.State...)
+		b.B = append(b.B, '#')
+		b.B = append(b.B, m.State...)
 	}
 
-	data = append(data, m.Message...)
+	b.B = append(b.B, c.encodeString([]byte(m.Message))...)
 
-	return c.WritePacket(data)
+	return c.WritePacket(b.B)
 }
 
 func (c *Conn) writeEOF() error {
-	data := make([]byte, 4, 9)
+	b := utils.ByteSliceGet(4)
+	defer utils.ByteSlicePut(b)
 
-	data = append(data, EOF_HEADER)
+	b.B = append(b.B, EOF_HEADER)
 	if c.capability&CLIENT_PROTOCOL_41 > 0 {
-		data = append(data, byte(c.warnings), byte(c.warnings>>8))
-		data = append(data, byte(c.status), byte(c.status>>8))
+		b.B = append(b.B, byte(c.warnings), byte(c.warnings>>8))
+		b.B = append(b.B, byte(c.status), byte(c.status>>8))
 	}
 
-	return c.WritePacket(data)
+	return c.WritePacket(b.B)
 }
 
 // see: https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_connection_phase_packets_protocol_auth_switch_request.html
@@ -129,16 +159,23 @@ func (c *Conn) writeResultset(r *Resultset) error {
 		}
 	}
 
-	columnLen := PutLengthEncodedInt(uint64(len(r.Fields)))
+	size := 0
+	for _, v := range r.RowDatas {
+		size += len(v)
+	}
+	if err := c.checkResultBytes(size); err != nil {
+		return c.writeError(err)
+	}
 
-	data := make([]byte, 4, 1024)
+	b := utils.ByteSliceGet(4)
+	defer utils.ByteSlicePut(b)
 
-	data = append(data, columnLen...)
-	if err := c.WritePacket(data); err != nil {
+	b.B = AppendLengthEncodedInteger(b.B, uint64(len(r.Fields)))
+	if err := c.WritePacket(b.B); err != nil {
 		return err
 	}
 
-	if err := c.writeFieldList(r.Fields, data); err != nil {
+	if err := c.writeFieldList(r.Fields); err != nil {
 		return err
 	}
 
@@ -149,9 +186,8 @@ func (c *Conn) writeResultset(r *Resultset) error {
 	}
 
 	for _, v := range r.RowDatas {
-		data = data[0:4]
-		data = append(data, v...)
-		if err := c.WritePacket(data); err != nil {
+		b.B = append(b.B[:4], v...)
+		if err := c.WritePacket(b.B); err != nil {
 			return err
 		}
 	}
@@ -163,15 +199,50 @@ func (c *Conn) writeResultset(r *Resultset) error {
 	return nil
 }
 
-func (c *Conn) writeFieldList(fs []*Field, data []byte) error {
-	if data == nil {
-		data = make([]byte, 4, 1024)
+// writeResultStreamer writes the column definitions and then every row returned by the streamer as soon as
+// it is produced. An error in the middle of the stream is sent as an ERR packet instead of the final EOF.
+func (c *Conn) writeResultStreamer(s *ResultStreamer, binary bool) error {
+	b := utils.ByteSliceGet(4)
+	defer utils.ByteSlicePut(b)
+
+	b.B = AppendLengthEncodedInteger(b.B, uint64(len(s.Fields)))
+	if err := c.WritePacket(b.B); err != nil {
+		return err
+	}
+
+	if err := c.writeFieldList(s.Fields); err != nil {
+		return err
 	}
 
+	size := 0
+	for {
+		row, err := s.NextRowData(binary)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return c.writeError(err)
+		}
+		size += len(row)
+		if err := c.checkResultBytes(size); err != nil {
+			return c.writeError(err)
+		}
+
+		b.B = append(b.B[:4], row...)
+		if err := c.WritePacket(b.B); err != nil {
+			return err
+		}
+	}
+
+	return c.writeEOF()
+}
+
+func (c *Conn) writeFieldList(fs []*Field) error {
+	b := utils.ByteSliceGet(4)
+	defer utils.ByteSlicePut(b)
+
 	for _, v := range fs {
-		data = data[0:4]
-		data = append(data, v.Dump()...)
-		if err := c.WritePacket(data); err != nil {
+		b.B = c.appendField(b.B[:4], v)
+		if err := c.WritePacket(b.B); err != nil {
 			return err
 		}
 	}
@@ -183,42 +254,179 @@ func (c *Conn) writeFieldList(fs []*Field, data []byte) error {
 }
 
 func (c *Conn) writeFieldValues(fv []FieldValue) error {
-	data := make([]byte, 4, 1024)
+	b := utils.ByteSliceGet(4)
+	defer utils.ByteSlicePut(b)
+
 	for _, v := range fv {
 		if v.Value() == nil {
 			// NULL value is encoded as 0xfb here
-			data = append(data, []byte{0xfb}...)
+			b.B = append(b.B, 0xfb)
 		} else {
 			tv, err := FormatTextValue(v.Value())
 			if err != nil {
 				return err
 			}
-			data = append(data, PutLengthEncodedString(tv)...)
+			b.B = AppendLengthEncodedString(b.B, tv)
 		}
 	}
 
-	return c.WritePacket(data)
+	return c.WritePacket(b.B)
 }
 
 // see: https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_replication.html
-func (c *Conn) writeBinlogEvents(s *replication.BinlogStreamer) error {
+//
+// The dump ends with EOF once no event is queued in s if drain is set. It lasts as long as the connection, the
+// max execution time of the commands does not bound it.
+func (c *Conn) writeBinlogEvents(s *replication.BinlogStreamer, drain bool) error {
+	ctx := c.connContext()
+	// the producer of the events stops whatever ends the dump, e.g. a cancelled connection
+	defer c.endBinlogDump(s)
+
+	semiSync := false
+	// the acknowledgments are no longer read once the end of the dump is sent, the packets following it
+	// are the next commands
+	stopAcks := func() {}
+	if h, ok := c.h.(SemiSyncAckHandler); ok && c.semiSync {
+		semiSync = true
+		stop := make(chan struct{})
+		done := c.readSemiSyncAcks(h, stop)
+		stopAcks = func() {
+			close(stop)
+			<-done
+		}
+	}
+	defer func() { stopAcks() }()
+
+	batch := c.binlogBatch()
+	var batchStart time.Time
+	var data []byte
 	for {
-		ev, err := s.GetEvent(context.Background())
+		var err error
+		ev := s.DumpEvent()
+		if ev == nil {
+			// caught up with the producer, the buffered events are sent before waiting for more
+			if err := c.flushBinlogEvents(s); err != nil {
+				return err
+			}
+			if drain {
+				err = replication.ErrSyncClosed
+			} else {
+				ev, err = s.GetEvent(ctx)
+			}
+		}
 		if err != nil {
-			return err
+			if ctx.Err() != nil {
+				return err
+			}
+			stopAcks()
+			stopAcks = func() {}
+			return c.writeBinlogDumpEnd(err)
+		}
+		if batch.Size > 0 && c.Buffered() == 0 {
+			c.StartBatch()
+			batchStart = time.Now()
+		}
+
+		// the packet is written or copied to the batch, its buffer can be reused
+		data = append(data[:0], 0, 0, 0, 0, OK_HEADER)
+		ack := false
+		if semiSync {
+			header := semiSyncHeader(ev)
+			ack = header[1] != 0
+			data = append(data, header...)
 		}
-		data := make([]byte, 4, 4+len(ev.RawData))
-		data = append(data, OK_HEADER)
 
 		data = append(data, ev.RawData...)
 		if err := c.WritePacket(data); err != nil {
+			// stop the producer of the events
+			s.AddErrorToStreamer(err)
 			return err
 		}
+
+		// the replica can not acknowledge an event still buffered
+		if ack || c.Buffered() >= batch.Size || (batch.Interval > 0 && time.Since(batchStart) >= batch.Interval) {
+			if err := c.flushBinlogEvents(s); err != nil {
+				return err
+			}
+		}
+		if ack {
+			// as MySQL, the sequence restarts after the acknowledgment packet of the replica, whenever it is sent
+			c.ResetSequence()
+			c.Sequence++
+		}
+	}
+}
+
+// binlogDumpObserver is implemented by the handlers relaying the events of another streamer, e.g. the handlers of
+// BinlogServer, which stop relaying once the dump is over.
+type binlogDumpObserver interface {
+	binlogDumpEnded()
+}
+
+// endBinlogDump tells the producer of s that its events are no longer read.
+func (c *Conn) endBinlogDump(s *replication.BinlogStreamer) {
+	s.AddErrorToStreamer(replication.ErrSyncClosed)
+	if h, ok := c.h.(binlogDumpObserver); ok {
+		h.binlogDumpEnded()
 	}
 }
 
+// flushBinlogEvents writes the binlog events buffered by writeBinlogEvents.
+func (c *Conn) flushBinlogEvents(s *replication.BinlogStreamer) error {
+	if err := c.Flush(); err != nil {
+		// stop the producer of the events
+		s.AddErrorToStreamer(err)
+		return err
+	}
+	return nil
+}
+
+// writeBinlogDumpEnd answers the end of a binlog dump: EOF if the streamer is closed with replication.ErrSyncClosed,
+// the error otherwise.
+func (c *Conn) writeBinlogDumpEnd(err error) error {
+	err = errors.Cause(err)
+	if err == replication.ErrSyncClosed {
+		return c.writeEOF()
+	}
+	if _, ok := err.(*MyError); !ok {
+		err = NewError(ER_MASTER_FATAL_ERROR_READING_BINLOG, err.Error())
+	}
+	return c.writeError(err)
+}
+
+func (c *Conn) writeResult(r *Result, binary bool) error {
+	if r == nil {
+		return c.writeOK(nil)
+	}
+	if r.LocalInfile != nil {
+		return c.writeLocalInfile(r.LocalInfile)
+	}
+	if r.Streamer == nil && r.Resultset == nil {
+		return c.writeOK(r)
+	}
+
+	// the status of a resultset, e.g. SERVER_PS_OUT_PARAMS, is sent in its EOF packets
+	status := c.status
+	c.status |= r.Status
+	defer func() { c.status = status }()
+
+	if r.Streamer != nil {
+		return c.writeResultStreamer(r.Streamer, binary)
+	}
+	return c.writeResultset(r.Resultset)
+}
+
 type noResponse struct{}
 type eofResponse struct{}
+type binaryStreamerResponse struct {
+	s *ResultStreamer
+}
+
+// binaryResultChain is the chain of results of a COM_STMT_EXECUTE, e.g. the result sets of a CALL statement
+// followed by its OUT parameters and the final OK.
+type binaryResultChain struct {
+	r *Result
+}
 
 func (c *Conn) WriteValue(value interface{}) error {
 	switch v := value.(type) {
@@ -226,25 +434,37 @@ func (c *Conn) WriteValue(value interface{}) error {
 		return nil
 	case eofResponse:
 		return c.writeEOF()
+	case statisticsResponse:
+		return c.writeStatistics(v)
 	case error:
 		return c.writeError(v)
 	case nil:
 		return c.writeOK(nil)
 	case *Result:
-		if v != nil && v.Resultset != nil {
-			fmt.Printf("writeResultset: %+v\n", v.Resultset)
-			return c.writeResultset(v.Resultset)
-		} else {
-			return c.writeOK(v)
+		if v != nil && v.Next != nil {
+			return c.writeMultiResponse(multiResponse{v}, false)
 		}
+		return c.writeResult(v, false)
 	case []*Field:
-		return c.writeFieldList(v, nil)
+		return c.writeFieldList(v)
 	case []FieldValue:
 		return c.writeFieldValues(v)
 	case *replication.BinlogStreamer:
-		return c.writeBinlogEvents(v)
+		return c.writeBinlogEvents(v, false)
+	case nonBlockingBinlogDump:
+		return c.writeBinlogEvents(v.s, true)
 	case *Stmt:
 		return c.writePrepare(v)
+	case multiResponse:
+		return c.writeMultiResponse(v, false)
+	case binaryResultChain:
+		return c.writeMultiResponse(multiResponse{v.r}, true)
+	case binaryStreamerResponse:
+		return c.writeResultStreamer(v.s, true)
+	case stmtCursorResponse:
+		return c.writeStmtCursor(v)
+	case stmtFetchResponse:
+		return c.writeStmtFetch(v)
 	default:
 		return fmt.Errorf("invalid response type %T", value)
 	}
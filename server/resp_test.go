@@ -34,6 +34,42 @@ func TestConnWriteOK(t *testing.T) {
 	require.Equal(t, expected, clientConn.WriteBuffered)
 }
 
+func TestConnWriteOKSessionTrack(t *testing.T) {
+	clientConn := &mockconn.MockConn{}
+	conn := &Conn{Conn: packet.NewConn(clientConn)}
+	conn.SetCapability(mysql.CLIENT_PROTOCOL_41)
+
+	result := &mysql.Result{
+		AffectedRows: 1,
+		SessionStateChanges: []mysql.SessionStateChange{
+			mysql.NewSchemaChange("db"),
+			mysql.NewSystemVariableChange("autocommit", "ON"),
+		},
+	}
+
+	// without CLIENT_SESSION_TRACK the changes are not sent
+	err := conn.writeOK(result)
+	require.NoError(t, err)
+	expected := []byte{7, 0, 0, 0, mysql.OK_HEADER, 1, 0, 0, 0, 0, 0}
+	require.Equal(t, expected, clientConn.WriteBuffered)
+
+	conn.SetCapability(mysql.CLIENT_SESSION_TRACK)
+	err = conn.writeOK(result)
+	require.NoError(t, err)
+	// status has SERVER_SESSION_STATE_CHANGED (0x4000), an empty info comes before the session state info
+	expected = []byte{30, 0, 0, 1, mysql.OK_HEADER, 1, 0, 0, 0x40, 0, 0, 0, 21}
+	expected = append(expected, mysql.SESSION_TRACK_SCHEMA, 3, 2, 'd', 'b')
+	expected = append(expected, mysql.SESSION_TRACK_SYSTEM_VARIABLES, 14, 10)
+	expected = append(expected, "autocommit"...)
+	expected = append(expected, 2, 'O', 'N')
+	require.Equal(t, expected, clientConn.WriteBuffered)
+}
+
+func TestSessionStateChangeDump(t *testing.T) {
+	require.Equal(t, []byte{mysql.SESSION_TRACK_STATE_CHANGE, 2, 1, '1'}, mysql.NewStateChange().Dump())
+	require.Equal(t, append([]byte{mysql.SESSION_TRACK_GTIDS, 6, 0, 4}, "a:10"...), mysql.NewGTIDsChange("a:10").Dump())
+}
+
 func TestConnWriteEOF(t *testing.T) {
 	clientConn := &mockconn.MockConn{}
 	conn := &Conn{Conn: packet.NewConn(clientConn)}
@@ -182,7 +218,7 @@ func TestConnWriteFieldList(t *testing.T) {
 
 	r, err := mysql.BuildSimpleTextResultset([]string{"c"}, [][]interface{}{{"d"}})
 	require.NoError(t, err)
-	err = conn.writeFieldList(r.Fields, nil)
+	err = conn.writeFieldList(r.Fields)
 	require.NoError(t, err)
 
 	// column length 1
@@ -200,7 +236,7 @@ func TestConnWriteFieldValues(t *testing.T) {
 	})
 
 	require.NoError(t, err)
-	err = conn.writeFieldList(r.Fields, nil)
+	err = conn.writeFieldList(r.Fields)
 	require.NoError(t, err)
 
 	// fields and EOF
@@ -229,3 +265,55 @@ func TestConnWriteFieldValues(t *testing.T) {
 	// EOF
 	require.Equal(t, []byte{1, 0, 0, 4, mysql.EOF_HEADER}, clientConn.WriteBuffered[43:])
 }
+
+func TestConnWriteResultStreamer(t *testing.T) {
+	clientConn := &mockconn.MockConn{MultiWrite: true}
+	conn := &Conn{Conn: packet.NewConn(clientConn)}
+
+	rows := make(chan []interface{}, 2)
+	rows <- []interface{}{"b"}
+	rows <- []interface{}{nil}
+	close(rows)
+
+	fields := []*mysql.Field{{Name: []byte("a"), Charset: 33, Type: mysql.MYSQL_TYPE_VAR_STRING}}
+	err := conn.WriteValue(&mysql.Result{Streamer: mysql.NewChanResultStreamer(fields, rows)})
+	require.NoError(t, err)
+	// column length 1
+	require.Equal(t, []byte{1, 0, 0, 0, 1}, clientConn.WriteBuffered[:5])
+	// fields and EOF
+	require.Equal(t, []byte{1, 0, 0, 2, mysql.EOF_HEADER}, clientConn.WriteBuffered[32:37])
+	// rowdata and EOF
+	require.Equal(t, []byte{2, 0, 0, 3, 1, 'b'}, clientConn.WriteBuffered[37:43])
+	require.Equal(t, []byte{1, 0, 0, 4, 0xfb}, clientConn.WriteBuffered[43:48])
+	require.Equal(t, []byte{1, 0, 0, 5, mysql.EOF_HEADER}, clientConn.WriteBuffered[48:])
+
+	// an error in the middle of the stream ends the resultset with an ERR packet
+	clientConn.WriteBuffered = []byte{}
+	conn.ResetSequence()
+	s := mysql.NewResultStreamer(fields, func() ([]interface{}, error) {
+		return nil, mysql.NewDefaultError(mysql.ER_YES)
+	})
+	err = conn.WriteValue(&mysql.Result{Streamer: s})
+	require.NoError(t, err)
+	require.Equal(t, []byte{6, 0, 0, 3, mysql.ERR_HEADER, 235, 3, 89, 69, 83}, clientConn.WriteBuffered[37:])
+}
+
+func BenchmarkConnWriteResultset(b *testing.B) {
+	values := make([][]interface{}, 100)
+	for i := range values {
+		values[i] = []interface{}{int64(i), "some text value"}
+	}
+	r, err := mysql.BuildSimpleTextResultset([]string{"id", "name"}, values)
+	require.NoError(b, err)
+
+	conn := &Conn{Conn: packet.NewConn(&mockconn.MockConn{})}
+	conn.SetCapability(mysql.CLIENT_PROTOCOL_41)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := conn.writeResultset(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
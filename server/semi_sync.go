@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/binary"
+	"regexp"
+
+	. "github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/replication"
+)
+
+// SemiSyncAckHandler is an optional extension of ReplicationHandler to emulate a semi-synchronous master.
+//
+// If the handler implements it, a replica enabling semi-sync with SET @rpl_semi_sync_slave = 1 (or
+// @rpl_semi_sync_replica) gets the events of its binlog dumps with the semi-sync header, the ones ending a
+// transaction (XID and transaction payload events) requesting an acknowledgment. The handler should tell the
+// replica that semi-sync is available, i.e. answer ON to SHOW VARIABLES LIKE 'rpl_semi_sync_master_enabled'.
+// The semi-sync is not supported on compressed connections.
+type SemiSyncAckHandler interface {
+	//handle the acknowledgment of a replica that it received the events up to pos, it is called by another
+	//goroutine than the one streaming the events
+	HandleSemiSyncAck(pos Position)
+}
+
+var setSemiSyncRegexp = regexp.MustCompile(`(?i)^\s*SET\s+@rpl_semi_sync_(?:slave|replica)\s*=\s*(\d+)\s*;?\s*$`)
+
+// handleSemiSyncQuery enables the semi-sync of the next binlog dumps, ok is false for the other queries.
+func (c *Conn) handleSemiSyncQuery(query string) (r *Result, ok bool, err error) {
+	if _, ok := c.h.(SemiSyncAckHandler); !ok || c.Compression != MYSQL_COMPRESS_NONE {
+		return nil, false, nil
+	}
+	m := setSemiSyncRegexp.FindStringSubmatch(query)
+	if m == nil {
+		return nil, false, nil
+	}
+	c.semiSync = m[1] != "0"
+	return &Result{}, true, nil
+}
+
+// semiSyncHeader returns the semi-sync header of an event sent to the replica.
+func semiSyncHeader(ev *replication.BinlogEvent) []byte {
+	flag := byte(0)
+	if len(ev.RawData) > 4 {
+		switch replication.EventType(ev.RawData[4]) {
+		case replication.XID_EVENT, replication.TRANSACTION_PAYLOAD_EVENT:
+			flag = 0x01 // the replica must acknowledge the event
+		}
+	}
+	return []byte{replication.SemiSyncIndicator, flag}
+}
+
+// readSemiSyncAcks passes the acknowledgments of the replica to h until stop is closed, the returned channel is
+// closed once it is done. The packets are read through the pipeline of the connection, so that the packets
+// following the dump are left to the next commands.
+func (c *Conn) readSemiSyncAcks(h SemiSyncAckHandler, stop <-chan struct{}) <-chan struct{} {
+	if c.pipeline == nil {
+		c.startPipeline(1)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case p, ok := <-c.pipeline:
+				if !ok || p.err != nil {
+					return
+				}
+				if pos, ok := parseSemiSyncAck(p.data); ok {
+					h.HandleSemiSyncAck(pos)
+				} else {
+					c.logger.Warnf("unexpected packet during a semi-sync binlog dump: %v", p.data)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// handleLateSemiSyncAck handles an acknowledgment sent by the replica after the end of its binlog dump,
+// it returns false if p is not one.
+func (c *Conn) handleLateSemiSyncAck(p pipelinedPacket) bool {
+	h, ok := c.h.(SemiSyncAckHandler)
+	if !ok || !c.semiSync || p.sequence != 0 {
+		return false
+	}
+	pos, ok := parseSemiSyncAck(p.data)
+	if ok {
+		h.HandleSemiSyncAck(pos)
+	}
+	return ok
+}
+
+// parseSemiSyncAck decodes an acknowledgment: the semi-sync indicator, the binlog position and file name.
+func parseSemiSyncAck(data []byte) (Position, bool) {
+	if len(data) < 9 || data[0] != replication.SemiSyncIndicator {
+		return Position{}, false
+	}
+	return Position{Name: string(data[9:]), Pos: uint32(binary.LittleEndian.Uint64(data[1:]))}, true
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/replication"
+)
+
+type testSemiSyncHandler struct {
+	testBinlogHandler
+	acks chan mysql.Position
+}
+
+func (h testSemiSyncHandler) HandleSemiSyncAck(pos mysql.Position) {
+	h.acks <- pos
+}
+
+func semiSyncAckPacket(pos mysql.Position) []byte {
+	data := []byte{0, 0, 0, 0, replication.SemiSyncIndicator}
+	data = append(data, mysql.Uint64ToBytes(uint64(pos.Pos))...)
+	return append(data, pos.Name...)
+}
+
+func TestSemiSyncHeader(t *testing.T) {
+	ev := &replication.BinlogEvent{RawData: make([]byte, 19)}
+	ev.RawData[4] = byte(replication.QUERY_EVENT)
+	require.Equal(t, []byte{replication.SemiSyncIndicator, 0}, semiSyncHeader(ev))
+	ev.RawData[4] = byte(replication.XID_EVENT)
+	require.Equal(t, []byte{replication.SemiSyncIndicator, 1}, semiSyncHeader(ev))
+
+	pos, ok := parseSemiSyncAck(semiSyncAckPacket(mysql.Position{Name: "mysql-bin.000001", Pos: 1234})[4:])
+	require.True(t, ok)
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000001", Pos: 1234}, pos)
+	_, ok = parseSemiSyncAck([]byte{mysql.COM_QUERY, 'S', 'E', 'L', 'E', 'C', 'T', ' ', '1'})
+	require.False(t, ok)
+}
+
+func TestSemiSyncBinlogDump(t *testing.T) {
+	s := replication.NewBinlogStreamer()
+	h := testSemiSyncHandler{testBinlogHandler{s: s}, make(chan mysql.Position, 2)}
+
+	addr := serveTestConns(t, nil, nil, h)
+
+	c, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.Execute("SET @rpl_semi_sync_slave = 1")
+	require.NoError(t, err)
+
+	c.ResetSequence()
+	dump := []byte{0, 0, 0, 0, mysql.COM_BINLOG_DUMP}
+	dump = append(dump, mysql.Uint32ToBytes(4)...)
+	dump = append(dump, 0, 0)
+	dump = append(dump, mysql.Uint32ToBytes(2)...)
+	dump = append(dump, "mysql-bin.000001"...)
+	require.NoError(t, c.WritePacket(dump))
+
+	raw := make([]byte, 19)
+	raw[4] = byte(replication.QUERY_EVENT)
+	binary.LittleEndian.PutUint32(raw[13:], 100)
+	require.NoError(t, s.AddEventToStreamer(&replication.BinlogEvent{RawData: raw}))
+	xid := make([]byte, 27)
+	xid[4] = byte(replication.XID_EVENT)
+	binary.LittleEndian.PutUint32(xid[13:], 127)
+	require.NoError(t, s.AddEventToStreamer(&replication.BinlogEvent{RawData: xid}))
+
+	c.Sequence = 1
+	data, err := c.ReadPacket()
+	require.NoError(t, err)
+	require.Equal(t, append([]byte{mysql.OK_HEADER, replication.SemiSyncIndicator, 0}, raw...), data)
+	data, err = c.ReadPacket()
+	require.NoError(t, err)
+	require.Equal(t, append([]byte{mysql.OK_HEADER, replication.SemiSyncIndicator, 1}, xid...), data)
+
+	// the acknowledgment is a packet of its own, while the events are still streamed
+	pos := mysql.Position{Name: "mysql-bin.000001", Pos: 127}
+	c.ResetSequence()
+	require.NoError(t, c.WritePacket(semiSyncAckPacket(pos)))
+	select {
+	case ack := <-h.acks:
+		require.Equal(t, pos, ack)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no acknowledgment")
+	}
+
+	// the end of the dump, then the connection is usable again
+	s.AddErrorToStreamer(replication.ErrSyncClosed)
+	data, err = c.ReadPacket()
+	require.NoError(t, err)
+	require.Equal(t, byte(mysql.EOF_HEADER), data[0])
+
+	// a late acknowledgment is not taken for a command
+	c.ResetSequence()
+	require.NoError(t, c.WritePacket(semiSyncAckPacket(pos)))
+	require.NoError(t, c.Ping())
+	select {
+	case ack := <-h.acks:
+		require.Equal(t, pos, ack)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no late acknowledgment")
+	}
+}
+
+// testSemiSyncMasterHandler answers the queries of a BinlogSyncer to a master without binlog checksum
+type testSemiSyncMasterHandler struct {
+	testSemiSyncHandler
+}
+
+func (h testSemiSyncMasterHandler) HandleQuery(query string) (*mysql.Result, error) {
+	switch {
+	case strings.Contains(query, "rpl_semi_sync"):
+		return simpleResult([]string{"Variable_name", "Value"}, [][]interface{}{{"rpl_semi_sync_master_enabled", "ON"}})
+	case strings.Contains(query, "BINLOG_CHECKSUM"):
+		return simpleResult([]string{"Variable_name", "Value"}, nil)
+	}
+	return &mysql.Result{}, nil
+}
+
+func TestSemiSyncBinlogSyncerManualAck(t *testing.T) {
+	s := replication.NewBinlogStreamer()
+	h := testSemiSyncMasterHandler{testSemiSyncHandler{testBinlogHandler{s: s}, make(chan mysql.Position, 2)}}
+
+	addr := serveTestConns(t, nil, nil, h)
+
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	portNum, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	b := replication.NewBinlogSyncer(replication.BinlogSyncerConfig{
+		ServerID:          100,
+		Host:              host,
+		Port:              uint16(portNum),
+		User:              "root",
+		Password:          "123",
+		SemiSyncEnabled:   true,
+		SemiSyncManualAck: true,
+	})
+	defer b.Close()
+
+	streamer, err := b.StartSync(mysql.Position{Name: "mysql-bin.000001", Pos: 4})
+	require.NoError(t, err)
+
+	for _, logPos := range []uint32{127, 154} {
+		xid := make([]byte, 27)
+		xid[4] = byte(replication.XID_EVENT)
+		binary.LittleEndian.PutUint32(xid[9:], uint32(len(xid)))
+		binary.LittleEndian.PutUint32(xid[13:], logPos)
+		require.NoError(t, s.AddEventToStreamer(&replication.BinlogEvent{RawData: xid}))
+	}
+
+	// both events requested an acknowledgment, the second one is read once the first one is not acknowledged
+	for _, logPos := range []uint32{127, 154} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		e, err := streamer.GetEvent(ctx)
+		cancel()
+		require.NoError(t, err)
+		require.Equal(t, logPos, e.Header.LogPos)
+	}
+	require.Empty(t, h.acks)
+
+	pos := mysql.Position{Name: "mysql-bin.000001", Pos: 154}
+	require.NoError(t, b.AckSemiSync(pos))
+	// already acknowledged
+	require.NoError(t, b.AckSemiSync(mysql.Position{Name: "mysql-bin.000001", Pos: 127}))
+	select {
+	case ack := <-h.acks:
+		require.Equal(t, pos, ack)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no acknowledgment")
+	}
+	select {
+	case ack := <-h.acks:
+		t.Fatalf("unexpected acknowledgment %s", ack)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
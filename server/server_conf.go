@@ -4,8 +4,12 @@ import (
 	"crypto/tls"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	. "github.com/atoonk/go-mysql/mysql"
+	"github.com/siddontang/go-log/loggers"
+	"github.com/siddontang/go/sync2"
 )
 
 var defaultServer = NewDefaultServer()
@@ -33,9 +37,39 @@ type Server struct {
 	defaultAuthMethod string // default authentication method, 'mysql_native_password'
 	pubKey            []byte
 	tlsConfig         *tls.Config
+	statusFlags       uint16 // initial status flags of the connections
+	connIDGenerator   func() uint32
+	queryInterceptors []QueryInterceptor
+	pipelineDepth     int
+	tlsVerifyHook     TLSVerifyHook
+	observer          ConnectionObserver
+	timeouts          Timeouts
+	proxyProtocol     *ProxyProtocol
+	stmtLimit         PreparedStmtLimit
+	limits            atomic.Pointer[Limits]
+	binlogBatch       BinlogBatch
+	metrics           Metrics
+	auditor           Auditor
+	usage             sync.Map // user -> *userUsage
+	conns             sync.Map // connection id -> authenticated *Conn
+	processList       bool
+	questions         sync2.AtomicUint64 // commands run by the connections, for SHOW STATUS and COM_STATISTICS
+	started           time.Time
 	cacheShaPassword  *sync.Map // 'user@host' -> SHA256(SHA256(PASSWORD))
+	logger            loggers.Advanced
 }
 
+// SetLogger sets the logger used by the connections of the server, they attach the connection id
+// and the remote address as fields of their messages.
+func (s *Server) SetLogger(l loggers.Advanced) {
+	s.logger = l
+}
+
+// TLSVerifyHook is called once a client has been authenticated, state is nil if the connection does not use TLS.
+// Returning an error rejects the client, if it wraps ErrAccessDenied the client gets the usual access denied error.
+// It can be used to require TLS or to authenticate clients by their certificate (state.PeerCertificates).
+type TLSVerifyHook func(c *Conn, state *tls.ConnectionState) error
+
 // NewDefaultServer: New mysql server with default settings.
 //
 // NOTES:
@@ -51,52 +85,130 @@ func NewDefaultServer() *Server {
 		serverVersion:   "5.7.0",
 		protocolVersion: 10,
 		capability: CLIENT_LONG_PASSWORD | CLIENT_LONG_FLAG | CLIENT_CONNECT_WITH_DB | CLIENT_PROTOCOL_41 |
-			CLIENT_TRANSACTIONS | CLIENT_SECURE_CONNECTION | CLIENT_PLUGIN_AUTH | CLIENT_SSL | CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA,
+			CLIENT_TRANSACTIONS | CLIENT_SECURE_CONNECTION | CLIENT_PLUGIN_AUTH | CLIENT_SSL | CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA |
+			CLIENT_MULTI_STATEMENTS | CLIENT_MULTI_RESULTS | CLIENT_LOCAL_FILES | CLIENT_COMPRESS | CLIENT_ZSTD_COMPRESSION_ALGORITHM |
+			CLIENT_SESSION_TRACK,
 		collationId:       DEFAULT_COLLATION_ID,
 		defaultAuthMethod: AUTH_NATIVE_PASSWORD,
 		pubKey:            getPublicKeyFromCert(certPem),
 		tlsConfig:         tlsConf,
 		cacheShaPassword:  new(sync.Map),
+		logger:            NewDefaultLogger(),
+		started:           time.Now(),
 	}
 }
 
+// DefaultServerCapability is the capability flags advertised by a server created with NewServer,
+// CLIENT_SSL is added if it has a TLS config.
+const DefaultServerCapability = CLIENT_LONG_PASSWORD | CLIENT_LONG_FLAG | CLIENT_CONNECT_WITH_DB | CLIENT_PROTOCOL_41 |
+	CLIENT_TRANSACTIONS | CLIENT_SECURE_CONNECTION | CLIENT_PLUGIN_AUTH | CLIENT_CONNECT_ATTRS |
+	CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA | CLIENT_MULTI_STATEMENTS | CLIENT_MULTI_RESULTS | CLIENT_LOCAL_FILES |
+	CLIENT_COMPRESS | CLIENT_ZSTD_COMPRESSION_ALGORITHM | CLIENT_SESSION_TRACK
+
+// ServerConfig is the configuration of a server created with NewServerWithConfig, e.g. for a proxy advertising
+// the version and capabilities of its backend. The zero value of a field selects the default of NewServer.
+type ServerConfig struct {
+	// ServerVersion is sent in the initial handshake, "8.0.12" by default
+	ServerVersion string
+	// Capability is the capability flags advertised to the clients, DefaultServerCapability by default. CLIENT_SSL
+	// is only advertised with a TLSConfig, and CLIENT_PROTOCOL_41 and CLIENT_SECURE_CONNECTION are always required from the clients
+	Capability uint32
+	// CollationID is the default collation sent in the initial handshake, DEFAULT_COLLATION_ID by default
+	CollationID uint8
+	// DefaultAuthMethod is the authentication method requested first, 'mysql_native_password' by default
+	DefaultAuthMethod string
+	PubKey            []byte
+	TLSConfig         *tls.Config
+	// StatusFlags are the initial status flags of the connections, sent in the initial handshake and the OK packets,
+	// e.g. SERVER_STATUS_AUTOCOMMIT
+	StatusFlags uint16
+	// ConnectionIDGenerator returns the id of every new connection, the ids are increasing from 10001 by default.
+	// It must be safe for concurrent use and the ids must be unique among the connections of the server
+	ConnectionIDGenerator func() uint32
+}
+
 // NewServer: New mysql server with customized settings.
 //
 // NOTES:
 // You can control the authentication methods and TLS settings here.
-// For auth method, you can specify one of the supported methods 'mysql_native_password', 'caching_sha2_password', and 'sha256_password'.
+// For auth method, you can specify one of the supported methods 'mysql_native_password', 'caching_sha2_password', and 'sha256_password',
+// or the name of an AuthPlugin registered with RegisterAuthPlugin.
 // The specified auth method will be enforced by the server in the connection phase. That means, client will be asked to switch auth method
 // if the supplied auth method is different from the server default.
 // And for TLS support, you can specify self-signed or CA-signed certificates and decide whether the client needs to provide
 // a signed or unsigned certificate to provide different level of security.
 func NewServer(serverVersion string, collationId uint8, defaultAuthMethod string, pubKey []byte, tlsConfig *tls.Config) *Server {
-	if !isAuthMethodSupported(defaultAuthMethod) {
-		panic(fmt.Sprintf("server authentication method '%s' is not supported", defaultAuthMethod))
+	return NewServerWithConfig(ServerConfig{
+		ServerVersion:     serverVersion,
+		CollationID:       collationId,
+		DefaultAuthMethod: defaultAuthMethod,
+		PubKey:            pubKey,
+		TLSConfig:         tlsConfig,
+	})
+}
+
+// NewServerWithConfig: New mysql server with the settings of cfg, see ServerConfig.
+func NewServerWithConfig(cfg ServerConfig) *Server {
+	if cfg.ServerVersion == "" {
+		cfg.ServerVersion = "8.0.12"
+	}
+	if cfg.CollationID == 0 {
+		cfg.CollationID = DEFAULT_COLLATION_ID
+	}
+	if cfg.DefaultAuthMethod == "" {
+		cfg.DefaultAuthMethod = AUTH_NATIVE_PASSWORD
+	}
+	if !isAuthMethodSupported(cfg.DefaultAuthMethod) {
+		panic(fmt.Sprintf("server authentication method '%s' is not supported", cfg.DefaultAuthMethod))
 	}
 
 	//if !isAuthMethodAllowedByServer(defaultAuthMethod, allowedAuthMethods) {
 	//	panic(fmt.Sprintf("default auth method is not one of the allowed auth methods"))
 	//}
-	var capFlag = CLIENT_LONG_PASSWORD | CLIENT_LONG_FLAG | CLIENT_CONNECT_WITH_DB | CLIENT_PROTOCOL_41 |
-		CLIENT_TRANSACTIONS | CLIENT_SECURE_CONNECTION | CLIENT_PLUGIN_AUTH | CLIENT_CONNECT_ATTRS |
-		CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA
-	if tlsConfig != nil {
+	capFlag := cfg.Capability
+	if capFlag == 0 {
+		capFlag = DefaultServerCapability
+	}
+	if cfg.TLSConfig != nil {
 		capFlag |= CLIENT_SSL
+	} else {
+		capFlag &^= CLIENT_SSL
 	}
 	return &Server{
-		serverVersion:     serverVersion,
+		serverVersion:     cfg.ServerVersion,
 		protocolVersion:   10,
 		capability:        capFlag,
-		collationId:       collationId,
-		defaultAuthMethod: defaultAuthMethod,
-		pubKey:            pubKey,
-		tlsConfig:         tlsConfig,
+		collationId:       cfg.CollationID,
+		defaultAuthMethod: cfg.DefaultAuthMethod,
+		pubKey:            cfg.PubKey,
+		tlsConfig:         cfg.TLSConfig,
+		statusFlags:       cfg.StatusFlags,
+		connIDGenerator:   cfg.ConnectionIDGenerator,
 		cacheShaPassword:  new(sync.Map),
+		logger:            NewDefaultLogger(),
+		started:           time.Now(),
 	}
 }
 
+// nextConnectionID returns the id of a new connection.
+func (s *Server) nextConnectionID() uint32 {
+	if s.connIDGenerator != nil {
+		return s.connIDGenerator()
+	}
+	return atomic.AddUint32(&baseConnID, 1)
+}
+
 func isAuthMethodSupported(authMethod string) bool {
-	return authMethod == AUTH_NATIVE_PASSWORD || authMethod == AUTH_CACHING_SHA2_PASSWORD || authMethod == AUTH_SHA256_PASSWORD
+	if isBuiltinAuthMethod(authMethod) {
+		return true
+	}
+	_, ok := getAuthPlugin(authMethod)
+	return ok
+}
+
+// SetTLSVerifyHook sets the hook to verify the TLS state of authenticated clients, see TLSVerifyHook.
+func (s *Server) SetTLSVerifyHook(hook TLSVerifyHook) {
+	s.tlsVerifyHook = hook
 }
 
 func (s *Server) InvalidateCache(username string, host string) {
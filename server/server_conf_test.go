@@ -0,0 +1,58 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/packet"
+	mockconn "github.com/atoonk/go-mysql/test_util/conn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewServerWithConfigDefaults(t *testing.T) {
+	s := NewServerWithConfig(ServerConfig{})
+	require.Equal(t, "8.0.12", s.serverVersion)
+	require.Equal(t, uint32(DefaultServerCapability), s.capability)
+	require.Equal(t, uint8(mysql.DEFAULT_COLLATION_ID), s.collationId)
+	require.Equal(t, mysql.AUTH_NATIVE_PASSWORD, s.defaultAuthMethod)
+
+	// CLIENT_SSL is only advertised with a TLS config
+	s = NewServerWithConfig(ServerConfig{Capability: DefaultServerCapability | mysql.CLIENT_SSL})
+	require.Zero(t, s.capability&mysql.CLIENT_SSL)
+}
+
+func TestServerConfigInitialHandshake(t *testing.T) {
+	capability := uint32(mysql.CLIENT_PROTOCOL_41 | mysql.CLIENT_SECURE_CONNECTION | mysql.CLIENT_PLUGIN_AUTH)
+	s := NewServerWithConfig(ServerConfig{
+		ServerVersion:         "5.7.42-log",
+		Capability:            capability,
+		CollationID:           33,
+		StatusFlags:           mysql.SERVER_STATUS_AUTOCOMMIT,
+		ConnectionIDGenerator: func() uint32 { return 42 },
+	})
+
+	clientConn := &mockconn.MockConn{}
+	conn := &Conn{
+		Conn:         packet.NewConn(clientConn),
+		serverConf:   s,
+		connectionID: s.nextConnectionID(),
+		status:       s.statusFlags,
+		salt:         bytes.Repeat([]byte{'a'}, 20),
+	}
+	require.NoError(t, conn.writeInitialHandshake())
+
+	data := clientConn.WriteBuffered[4:]
+	require.Equal(t, byte(10), data[0])
+	pos := 1 + bytes.IndexByte(data[1:], 0x00)
+	require.Equal(t, "5.7.42-log", string(data[1:pos]))
+	pos++
+	require.Equal(t, uint32(42), binary.LittleEndian.Uint32(data[pos:]))
+	pos += 4 + 8 + 1
+	lower := binary.LittleEndian.Uint16(data[pos:])
+	require.Equal(t, byte(33), data[pos+2])
+	require.Equal(t, uint16(mysql.SERVER_STATUS_AUTOCOMMIT), binary.LittleEndian.Uint16(data[pos+3:]))
+	upper := binary.LittleEndian.Uint16(data[pos+5:])
+	require.Equal(t, capability, uint32(upper)<<16|uint32(lower))
+}
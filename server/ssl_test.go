@@ -0,0 +1,38 @@
+package server
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/test_util/test_keys"
+)
+
+func TestTLSVerifyHook(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, test_keys.PubPem, tlsConf)
+	svr.SetTLSVerifyHook(func(c *Conn, state *tls.ConnectionState) error {
+		if state == nil {
+			return fmt.Errorf("%w: secure transport required", ErrAccessDenied)
+		}
+		return nil
+	})
+
+	addr := serveTestConns(t, svr, nil, nil)
+
+	for tlsPara, allowed := range map[string]bool{"false": false, "skip-verify": true} {
+		db, err := sql.Open("mysql", fmt.Sprintf("root:123@tcp(%s)/?tls=%s", addr, tlsPara))
+		require.NoError(t, err)
+
+		err = db.Ping()
+		if allowed {
+			require.NoError(t, err)
+		} else {
+			require.ErrorContains(t, err, "Access denied")
+		}
+		db.Close()
+	}
+}
@@ -2,11 +2,13 @@ package server
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"math"
 	"strconv"
 
 	. "github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/utils"
 	"github.com/pingcap/errors"
 )
 
@@ -31,6 +33,53 @@ type Stmt struct {
 	Args []interface{}
 
 	Context interface{}
+
+	// cursor opened by the last COM_STMT_EXECUTE with CURSOR_TYPE_READ_ONLY, nil if none is open
+	cursor *stmtCursor
+	// value of the connection use counter when the statement was last prepared or used, for the LRU eviction
+	lastUsed uint64
+	// params sent with COM_STMT_SEND_LONG_DATA since the last execution, their values are not in COM_STMT_EXECUTE
+	longData []bool
+	// first error of StmtLongDataHandler since the last execution, returned by the next COM_STMT_EXECUTE
+	longDataErr error
+}
+
+// StmtLongDataHandler is an optional extension of Handler to stream the parameters sent with COM_STMT_SEND_LONG_DATA,
+// e.g. BLOBs of hundreds of MB, instead of buffering them in Stmt.Args.
+//
+// If the handler implements StmtLongDataHandler, the chunks of a parameter are passed to HandleStmtLongData in order
+// and its value in the args of the next HandleStmtExecute is nil. COM_STMT_SEND_LONG_DATA has no response, so an
+// error is returned to the client by the next COM_STMT_EXECUTE of the statement, as MySQL does.
+type StmtLongDataHandler interface {
+	// handle COM_STMT_SEND_LONG_DATA, context is the previous one set in prepare
+	// chunk is only valid until the call returns
+	HandleStmtLongData(context interface{}, paramID int, chunk []byte) error
+}
+
+// StmtFetchHandler is an optional extension of Handler to stream the rows of a cursor in chunks.
+//
+// When a client executes a statement with CURSOR_TYPE_READ_ONLY, only the column definitions of the Resultset
+// returned by HandleStmtExecute are sent. The rows in Resultset.RowDatas (which must be encoded in the binary
+// protocol, see BuildSimpleBinaryResultset) are then sent on COM_STMT_FETCH. Once they are used up and the handler
+// implements StmtFetchHandler, HandleStmtFetch is called for more rows until it reports the cursor as done.
+type StmtFetchHandler interface {
+	// handle COM_STMT_FETCH, context is the previous one set in prepare
+	// return at most numRows binary protocol rows, done tells the cursor has no more rows
+	HandleStmtFetch(context interface{}, numRows uint32) (rows []RowData, done bool, err error)
+}
+
+type stmtCursor struct {
+	rows []RowData
+	done bool
+}
+
+type stmtCursorResponse struct {
+	r *Resultset
+}
+
+type stmtFetchResponse struct {
+	rows []RowData
+	last bool
 }
 
 func (s *Stmt) Rest(params int, columns int, context interface{}) {
@@ -42,6 +91,8 @@ func (s *Stmt) Rest(params int, columns int, context interface{}) {
 
 func (s *Stmt) ResetParams() {
 	s.Args = make([]interface{}, s.Params)
+	s.longData = make([]bool, s.Params)
+	s.longDataErr = nil
 }
 
 func (c *Conn) writePrepare(s *Stmt) error {
@@ -96,7 +147,7 @@ func (c *Conn) writePrepare(s *Stmt) error {
 	return nil
 }
 
-func (c *Conn) handleStmtExecute(data []byte) (*Result, error) {
+func (c *Conn) handleStmtExecute(data []byte) (interface{}, error) {
 	if len(data) < 9 {
 		return nil, ErrMalformPacket
 	}
@@ -105,7 +156,7 @@ func (c *Conn) handleStmtExecute(data []byte) (*Result, error) {
 	id := binary.LittleEndian.Uint32(data[0:4])
 	pos += 4
 
-	s, ok := c.stmts[id]
+	s, ok := c.useStmt(id)
 	if !ok {
 		return nil, NewDefaultError(ER_UNKNOWN_STMT_HANDLER,
 			strconv.FormatUint(uint64(id), 10), "stmt_execute")
@@ -113,10 +164,12 @@ func (c *Conn) handleStmtExecute(data []byte) (*Result, error) {
 
 	flag := data[pos]
 	pos++
-	//now we only support CURSOR_TYPE_NO_CURSOR flag
-	if flag != 0 {
+	//now we only support CURSOR_TYPE_NO_CURSOR and CURSOR_TYPE_READ_ONLY flag
+	if flag != CURSOR_TYPE_NO_CURSOR && flag != CURSOR_TYPE_READ_ONLY {
 		return nil, NewError(ER_UNKNOWN_ERROR, fmt.Sprintf("unsupported flag %d", flag))
 	}
+	// a new execution implicitly closes the cursor of the previous one
+	s.cursor = nil
 
 	//skip iteration-count, always 1
 	pos += 4
@@ -153,17 +206,118 @@ func (c *Conn) handleStmtExecute(data []byte) (*Result, error) {
 		}
 	}
 
+	if err := s.longDataErr; err != nil {
+		s.ResetParams()
+		return nil, errors.Trace(err)
+	}
+
 	var r *Result
 	var err error
-	if r, err = c.h.HandleStmtExecute(s.Context, s.Query, s.Args); err != nil {
+	if r, err = c.handler().HandleStmtExecuteContext(c.Context(), s.Context, s.Query, s.Args); err != nil {
 		return nil, errors.Trace(err)
 	}
 
 	s.ResetParams()
+	c.recordWarnings(r, nil)
+
+	if r != nil && r.Next != nil {
+		return binaryResultChain{r}, nil
+	}
+
+	if r != nil && r.Streamer != nil {
+		return binaryStreamerResponse{r.Streamer}, nil
+	}
+
+	if flag == CURSOR_TYPE_READ_ONLY && r != nil && r.Resultset != nil {
+		_, streaming := c.h.(StmtFetchHandler)
+		s.cursor = &stmtCursor{rows: r.RowDatas, done: !streaming}
+		return stmtCursorResponse{r.Resultset}, nil
+	}
 
 	return r, nil
 }
 
+func (c *Conn) handleStmtFetch(data []byte) (interface{}, error) {
+	if len(data) < 8 {
+		return nil, ErrMalformPacket
+	}
+
+	id := binary.LittleEndian.Uint32(data[0:4])
+	numRows := binary.LittleEndian.Uint32(data[4:8])
+
+	s, ok := c.useStmt(id)
+	if !ok {
+		return nil, NewDefaultError(ER_UNKNOWN_STMT_HANDLER,
+			strconv.FormatUint(uint64(id), 10), "stmt_fetch")
+	}
+	if s.cursor == nil {
+		return nil, NewDefaultError(ER_STMT_HAS_NO_OPEN_CURSOR, id)
+	}
+
+	cur := s.cursor
+	if len(cur.rows) == 0 && !cur.done {
+		rows, done, err := c.h.(StmtFetchHandler).HandleStmtFetch(s.Context, numRows)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		cur.rows = rows
+		cur.done = done
+	}
+
+	n := int(numRows)
+	if n > len(cur.rows) {
+		n = len(cur.rows)
+	}
+	rows := cur.rows[:n]
+	cur.rows = cur.rows[n:]
+
+	last := len(cur.rows) == 0 && cur.done
+	if last {
+		s.cursor = nil
+	}
+
+	return stmtFetchResponse{rows: rows, last: last}, nil
+}
+
+// see: https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_stmt_execute.html
+// with a cursor, only the column definitions are sent, rows are sent on COM_STMT_FETCH
+func (c *Conn) writeStmtCursor(v stmtCursorResponse) error {
+	b := utils.ByteSliceGet(4)
+	defer utils.ByteSlicePut(b)
+
+	b.B = AppendLengthEncodedInteger(b.B, uint64(len(v.r.Fields)))
+	if err := c.WritePacket(b.B); err != nil {
+		return err
+	}
+
+	c.SetStatus(SERVER_STATUS_CURSOR_EXISTS)
+	defer c.UnsetStatus(SERVER_STATUS_CURSOR_EXISTS)
+
+	return c.writeFieldList(v.r.Fields)
+}
+
+// see: https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_stmt_fetch.html
+func (c *Conn) writeStmtFetch(v stmtFetchResponse) error {
+	b := utils.ByteSliceGet(4)
+	defer utils.ByteSlicePut(b)
+
+	for _, row := range v.rows {
+		b.B = append(b.B[:4], row...)
+		if err := c.WritePacket(b.B); err != nil {
+			return err
+		}
+	}
+
+	status := SERVER_STATUS_CURSOR_EXISTS
+	if v.last {
+		status |= SERVER_STATUS_LAST_ROW_SEND
+	}
+	c.SetStatus(status)
+	defer c.UnsetStatus(status)
+
+	return c.writeEOF()
+}
+
 func (c *Conn) bindStmtArgs(s *Stmt, nullBitmap, paramTypes, paramValues []byte) error {
 	args := s.Args
 
@@ -175,6 +329,11 @@ func (c *Conn) bindStmtArgs(s *Stmt, nullBitmap, paramTypes, paramValues []byte)
 	var err error
 
 	for i := 0; i < s.Params; i++ {
+		// the value of a param sent with COM_STMT_SEND_LONG_DATA is not repeated
+		if s.longData[i] {
+			continue
+		}
+
 		if nullBitmap[i>>3]&(1<<(uint(i)%8)) > 0 {
 			args[i] = nil
 			continue
@@ -259,12 +418,32 @@ func (c *Conn) bindStmtArgs(s *Stmt, nullBitmap, paramTypes, paramValues []byte)
 			pos += 8
 			continue
 
+		case MYSQL_TYPE_DATE, MYSQL_TYPE_NEWDATE, MYSQL_TYPE_TIMESTAMP, MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIME:
+			// 1 byte length, then only the non zero parts of the value
+			if len(paramValues) < (pos + 1) {
+				return ErrMalformPacket
+			}
+			n = int(paramValues[pos])
+			pos++
+			if len(paramValues) < (pos + n) {
+				return ErrMalformPacket
+			}
+
+			if tp == MYSQL_TYPE_TIME {
+				args[i], err = ParseBinaryTime(n, paramValues[pos:pos+n])
+			} else {
+				args[i], err = ParseBinaryDateTime(n, paramValues[pos:pos+n])
+			}
+			if err != nil {
+				return errors.Trace(err)
+			}
+			pos += n
+			continue
+
 		case MYSQL_TYPE_DECIMAL, MYSQL_TYPE_NEWDECIMAL, MYSQL_TYPE_VARCHAR,
 			MYSQL_TYPE_BIT, MYSQL_TYPE_ENUM, MYSQL_TYPE_SET, MYSQL_TYPE_TINY_BLOB,
 			MYSQL_TYPE_MEDIUM_BLOB, MYSQL_TYPE_LONG_BLOB, MYSQL_TYPE_BLOB,
-			MYSQL_TYPE_VAR_STRING, MYSQL_TYPE_STRING, MYSQL_TYPE_GEOMETRY,
-			MYSQL_TYPE_DATE, MYSQL_TYPE_NEWDATE,
-			MYSQL_TYPE_TIMESTAMP, MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIME:
+			MYSQL_TYPE_VAR_STRING, MYSQL_TYPE_STRING, MYSQL_TYPE_GEOMETRY, MYSQL_TYPE_JSON:
 			if len(paramValues) < (pos + 1) {
 				return ErrMalformPacket
 			}
@@ -275,13 +454,28 @@ func (c *Conn) bindStmtArgs(s *Stmt, nullBitmap, paramTypes, paramValues []byte)
 				return errors.Trace(err)
 			}
 
-			if !isNull {
-				args[i] = v
-				continue
-			} else {
+			if isNull {
 				args[i] = nil
 				continue
 			}
+
+			switch tp {
+			case MYSQL_TYPE_DECIMAL, MYSQL_TYPE_NEWDECIMAL:
+				if args[i], err = ParseDecimal(string(v)); err != nil {
+					return errors.Trace(err)
+				}
+			case MYSQL_TYPE_BIT:
+				// up to 64 bits, most significant byte first
+				if len(v) > 8 {
+					return ErrMalformPacket
+				}
+				args[i] = BFixedLengthInt(v)
+			case MYSQL_TYPE_JSON:
+				args[i] = json.RawMessage(v)
+			default:
+				args[i] = v
+			}
+			continue
 		default:
 			return errors.Errorf("Stmt Unknown FieldType %d", tp)
 		}
@@ -297,7 +491,7 @@ func (c *Conn) handleStmtSendLongData(data []byte) error {
 
 	id := binary.LittleEndian.Uint32(data[0:4])
 
-	s, ok := c.stmts[id]
+	s, ok := c.useStmt(id)
 	if !ok {
 		return nil
 	}
@@ -307,6 +501,15 @@ func (c *Conn) handleStmtSendLongData(data []byte) error {
 		return nil
 	}
 
+	s.longData[paramId] = true
+
+	if h, ok := c.h.(StmtLongDataHandler); ok {
+		if err := h.HandleStmtLongData(s.Context, int(paramId), data[6:]); err != nil && s.longDataErr == nil {
+			s.longDataErr = err
+		}
+		return nil
+	}
+
 	if s.Args[paramId] == nil {
 		s.Args[paramId] = data[6:]
 	} else {
@@ -328,13 +531,14 @@ func (c *Conn) handleStmtReset(data []byte) (*Result, error) {
 
 	id := binary.LittleEndian.Uint32(data[0:4])
 
-	s, ok := c.stmts[id]
+	s, ok := c.useStmt(id)
 	if !ok {
 		return nil, NewDefaultError(ER_UNKNOWN_STMT_HANDLER,
 			strconv.FormatUint(uint64(id), 10), "stmt_reset")
 	}
 
 	s.ResetParams()
+	s.cursor = nil
 
 	return &Result{}, nil
 }
@@ -347,16 +551,5 @@ func (c *Conn) handleStmtClose(data []byte) error {
 
 	id := binary.LittleEndian.Uint32(data[0:4])
 
-	stmt, ok := c.stmts[id]
-	if !ok {
-		return nil
-	}
-
-	if err := c.h.HandleStmtClose(stmt.Context); err != nil {
-		return err
-	}
-
-	delete(c.stmts, id)
-
-	return nil
+	return c.CloseStmt(id)
 }
@@ -0,0 +1,94 @@
+package server
+
+import (
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+// PreparedStmtLimit limits the prepared statements kept by a connection, so that a long-lived connection
+// preparing statements without closing them (as some ORMs do) does not grow forever.
+type PreparedStmtLimit struct {
+	// Max is the number of prepared statements of a connection, zero for no limit. Preparing one more fails
+	// with ER_MAX_PREPARED_STMT_COUNT_REACHED, as MySQL does with max_prepared_stmt_count
+	Max int
+	// EvictLRU makes preparing a statement over Max close the least recently used one instead of failing,
+	// the client then gets ER_UNKNOWN_STMT_HANDLER if it executes the closed statement
+	EvictLRU bool
+}
+
+// SetPreparedStmtLimit sets the limit of prepared statements of the connections created with the server.
+func (s *Server) SetPreparedStmtLimit(l PreparedStmtLimit) {
+	s.stmtLimit = l
+}
+
+// SetPreparedStmtLimit overrides the limit of prepared statements of the connection set by the server.
+// The statements over the new limit are only closed by the next prepare.
+func (c *Conn) SetPreparedStmtLimit(l PreparedStmtLimit) {
+	c.stmtLimit = l
+}
+
+// NumStmts returns the number of prepared statements of the connection.
+func (c *Conn) NumStmts() int {
+	return len(c.stmts)
+}
+
+// CloseStmt closes the prepared statement with the given id, as COM_STMT_CLOSE does.
+// Closing an unknown statement is a no-op.
+func (c *Conn) CloseStmt(id uint32) error {
+	st, ok := c.stmts[id]
+	if !ok {
+		return nil
+	}
+
+	if err := c.handler().HandleStmtCloseContext(c.Context(), st.Context); err != nil {
+		return err
+	}
+
+	delete(c.stmts, id)
+	return nil
+}
+
+// reserveStmt makes room for a new prepared statement.
+func (c *Conn) reserveStmt() error {
+	max := c.stmtLimit.Max
+	if max <= 0 {
+		return nil
+	}
+
+	for len(c.stmts) >= max {
+		if !c.stmtLimit.EvictLRU {
+			return NewDefaultError(ER_MAX_PREPARED_STMT_COUNT_REACHED, max)
+		}
+
+		var lru *Stmt
+		for _, st := range c.stmts {
+			if lru == nil || st.lastUsed < lru.lastUsed {
+				lru = st
+			}
+		}
+		if err := c.CloseStmt(lru.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextStmtID returns an id for a new prepared statement. Ids are increasing, and once they wrap around
+// the ids of the statements still open are skipped, as is 0.
+func (c *Conn) nextStmtID() uint32 {
+	for {
+		c.stmtID++
+		if _, ok := c.stmts[c.stmtID]; c.stmtID != 0 && !ok {
+			return c.stmtID
+		}
+	}
+}
+
+// useStmt returns the prepared statement with the given id and marks it as the most recently used.
+func (c *Conn) useStmt(id uint32) (*Stmt, bool) {
+	st, ok := c.stmts[id]
+	if ok {
+		c.stmtUses++
+		st.lastUsed = c.stmtUses
+	}
+	return st, ok
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"math"
+	"testing"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/packet"
+	mockconn "github.com/atoonk/go-mysql/test_util/conn"
+	"github.com/stretchr/testify/require"
+)
+
+type testStmtLimitHandler struct {
+	EmptyHandler
+	closed []interface{}
+}
+
+func (h *testStmtLimitHandler) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	return 0, 0, query, nil
+}
+
+func (h *testStmtLimitHandler) HandleStmtClose(context interface{}) error {
+	h.closed = append(h.closed, context)
+	return nil
+}
+
+func newTestStmtLimitConn(h Handler, l PreparedStmtLimit) *Conn {
+	c := &Conn{Conn: packet.NewConn(&mockconn.MockConn{}), h: h, stmts: make(map[uint32]*Stmt)}
+	c.SetPreparedStmtLimit(l)
+	return c
+}
+
+func TestPreparedStmtLimit(t *testing.T) {
+	h := &testStmtLimitHandler{}
+	c := newTestStmtLimitConn(h, PreparedStmtLimit{Max: 2})
+
+	require.Equal(t, uint32(1), c.dispatch([]byte{mysql.COM_STMT_PREPARE, 'a'}).(*Stmt).ID)
+	require.Equal(t, uint32(2), c.dispatch([]byte{mysql.COM_STMT_PREPARE, 'b'}).(*Stmt).ID)
+
+	v := c.dispatch([]byte{mysql.COM_STMT_PREPARE, 'c'})
+	require.EqualValues(t, mysql.ER_MAX_PREPARED_STMT_COUNT_REACHED, v.(*mysql.MyError).Code)
+	require.Equal(t, 2, c.NumStmts())
+
+	// closing a statement makes room for a new one
+	require.Equal(t, noResponse{}, c.dispatch(stmtCommand(mysql.COM_STMT_CLOSE, 1)))
+	require.Equal(t, []interface{}{"a"}, h.closed)
+	require.Equal(t, uint32(3), c.dispatch([]byte{mysql.COM_STMT_PREPARE, 'c'}).(*Stmt).ID)
+
+	require.NoError(t, c.CloseStmt(2))
+	require.NoError(t, c.CloseStmt(2))
+	require.Equal(t, []interface{}{"a", "b"}, h.closed)
+	require.Equal(t, 1, c.NumStmts())
+}
+
+func TestPreparedStmtLimitEvictLRU(t *testing.T) {
+	h := &testStmtLimitHandler{}
+	c := newTestStmtLimitConn(h, PreparedStmtLimit{Max: 2, EvictLRU: true})
+
+	c.dispatch([]byte{mysql.COM_STMT_PREPARE, 'a'})
+	c.dispatch([]byte{mysql.COM_STMT_PREPARE, 'b'})
+	// executing a makes b the least recently used statement
+	c.dispatch(stmtCommand(mysql.COM_STMT_EXECUTE, 1, mysql.CURSOR_TYPE_NO_CURSOR, 1, 0, 0, 0))
+
+	require.Equal(t, uint32(3), c.dispatch([]byte{mysql.COM_STMT_PREPARE, 'c'}).(*Stmt).ID)
+	require.Equal(t, []interface{}{"b"}, h.closed)
+	require.Equal(t, 2, c.NumStmts())
+
+	v := c.dispatch(stmtCommand(mysql.COM_STMT_RESET, 2))
+	require.EqualValues(t, mysql.ER_UNKNOWN_STMT_HANDLER, v.(*mysql.MyError).Code)
+
+	c.dispatch([]byte{mysql.COM_STMT_PREPARE, 'd'})
+	require.Equal(t, []interface{}{"b", "a"}, h.closed)
+}
+
+func TestNextStmtID(t *testing.T) {
+	c := newTestStmtLimitConn(&testStmtLimitHandler{}, PreparedStmtLimit{})
+	c.stmts[1] = &Stmt{ID: 1}
+	c.stmts[math.MaxUint32] = &Stmt{ID: math.MaxUint32}
+	c.stmtID = math.MaxUint32 - 1
+
+	// the ids wrap around, skipping 0 and the ones still in use
+	require.Equal(t, uint32(2), c.nextStmtID())
+	require.Equal(t, uint32(3), c.nextStmtID())
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/packet"
+	mockconn "github.com/atoonk/go-mysql/test_util/conn"
+	"github.com/pingcap/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type testCursorHandler struct {
+	EmptyHandler
+}
+
+func (h testCursorHandler) HandleStmtExecute(context interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	r, err := mysql.BuildSimpleBinaryResultset([]string{"a"}, [][]interface{}{{1}, {2}, {3}})
+	if err != nil {
+		return nil, err
+	}
+	return &mysql.Result{Resultset: r}, nil
+}
+
+func stmtCommand(cmd byte, id uint32, payload ...byte) []byte {
+	data := append([]byte{cmd}, mysql.Uint32ToBytes(id)...)
+	return append(data, payload...)
+}
+
+func TestStmtCursorFetch(t *testing.T) {
+	clientConn := &mockconn.MockConn{MultiWrite: true}
+	c := &Conn{Conn: packet.NewConn(clientConn), h: testCursorHandler{}, stmts: make(map[uint32]*Stmt)}
+	c.stmts[1] = &Stmt{ID: 1, Query: "SELECT a FROM t"}
+
+	// COM_STMT_EXECUTE, flag, iteration-count
+	v := c.dispatch(stmtCommand(mysql.COM_STMT_EXECUTE, 1, mysql.CURSOR_TYPE_READ_ONLY, 1, 0, 0, 0))
+	cur, ok := v.(stmtCursorResponse)
+	require.True(t, ok)
+	require.Len(t, cur.r.Fields, 1)
+	require.NoError(t, c.WriteValue(v))
+	require.False(t, c.HasStatus(mysql.SERVER_STATUS_CURSOR_EXISTS))
+
+	v = c.dispatch(stmtCommand(mysql.COM_STMT_FETCH, 1, 2, 0, 0, 0))
+	require.Equal(t, stmtFetchResponse{rows: cur.r.RowDatas[:2]}, v)
+
+	v = c.dispatch(stmtCommand(mysql.COM_STMT_FETCH, 1, 2, 0, 0, 0))
+	require.Equal(t, stmtFetchResponse{rows: cur.r.RowDatas[2:], last: true}, v)
+
+	v = c.dispatch(stmtCommand(mysql.COM_STMT_FETCH, 1, 2, 0, 0, 0))
+	require.EqualValues(t, mysql.ER_STMT_HAS_NO_OPEN_CURSOR, v.(*mysql.MyError).Code)
+
+	v = c.dispatch(stmtCommand(mysql.COM_STMT_FETCH, 2, 2, 0, 0, 0))
+	require.EqualValues(t, mysql.ER_UNKNOWN_STMT_HANDLER, v.(*mysql.MyError).Code)
+}
+
+func TestConnWriteStmtFetch(t *testing.T) {
+	clientConn := &mockconn.MockConn{}
+	c := &Conn{Conn: packet.NewConn(clientConn)}
+	c.SetCapability(mysql.CLIENT_PROTOCOL_41)
+
+	err := c.writeStmtFetch(stmtFetchResponse{last: true})
+	require.NoError(t, err)
+	expected := []byte{5, 0, 0, 0, mysql.EOF_HEADER, 0, 0, 0xc0, 0}
+	require.Equal(t, expected, clientConn.WriteBuffered)
+	require.False(t, c.HasStatus(mysql.SERVER_STATUS_CURSOR_EXISTS))
+}
+
+func TestBindStmtArgs(t *testing.T) {
+	lenenc := func(s string) []byte { return mysql.PutLengthEncodedString([]byte(s)) }
+
+	params := []struct {
+		tp       byte
+		unsigned bool
+		value    []byte
+		expected interface{}
+	}{
+		{mysql.MYSQL_TYPE_TINY, true, []byte{0xff}, uint8(255)},
+		{mysql.MYSQL_TYPE_SHORT, false, []byte{0xfe, 0xff}, int16(-2)},
+		{mysql.MYSQL_TYPE_LONGLONG, true, mysql.Uint64ToBytes(math.MaxUint64), uint64(math.MaxUint64)},
+		{mysql.MYSQL_TYPE_NEWDECIMAL, false, lenenc("-12.340"), mysql.MustParseDecimal("-12.340")},
+		{mysql.MYSQL_TYPE_BIT, false, lenenc("\x01\x02"), uint64(0x0102)},
+		{mysql.MYSQL_TYPE_JSON, false, lenenc(`{"a":1}`), json.RawMessage(`{"a":1}`)},
+		{mysql.MYSQL_TYPE_VAR_STRING, false, lenenc("abc"), []byte("abc")},
+		{mysql.MYSQL_TYPE_DATE, false, []byte{4, 0xe8, 0x07, 2, 29}, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{mysql.MYSQL_TYPE_DATETIME, false, []byte{11, 0xe8, 0x07, 2, 29, 13, 14, 15, 0x40, 0xe2, 0x01, 0},
+			time.Date(2024, 2, 29, 13, 14, 15, 123456000, time.UTC)},
+		{mysql.MYSQL_TYPE_TIMESTAMP, false, []byte{0}, time.Time{}},
+		{mysql.MYSQL_TYPE_TIME, false, []byte{12, 1, 1, 0, 0, 0, 2, 3, 4, 0x40, 0xe2, 0x01, 0},
+			-(26*time.Hour + 3*time.Minute + 4*time.Second + 123456*time.Microsecond)},
+		{mysql.MYSQL_TYPE_TIME, false, []byte{0}, time.Duration(0)},
+		{mysql.MYSQL_TYPE_NULL, false, nil, nil},
+	}
+
+	s := &Stmt{Params: len(params)}
+	s.ResetParams()
+	nullBitmap := make([]byte, (len(params)+7)>>3)
+	var paramTypes, paramValues []byte
+	for _, p := range params {
+		flag := byte(0)
+		if p.unsigned {
+			flag = 0x80
+		}
+		paramTypes = append(paramTypes, p.tp, flag)
+		paramValues = append(paramValues, p.value...)
+	}
+
+	c := &Conn{}
+	require.NoError(t, c.bindStmtArgs(s, nullBitmap, paramTypes, paramValues))
+	for i, p := range params {
+		require.Equal(t, p.expected, s.Args[i], "param %d", i)
+	}
+
+	// a truncated value is reported
+	s.ResetParams()
+	err := c.bindStmtArgs(s, nullBitmap, paramTypes, paramValues[:len(paramValues)-3])
+	require.Error(t, err)
+}
+
+type testArgsHandler struct {
+	EmptyHandler
+	args []interface{}
+}
+
+func (h *testArgsHandler) HandleStmtExecute(context interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	h.args = append([]interface{}{}, args...)
+	return &mysql.Result{}, nil
+}
+
+type testLongDataHandler struct {
+	testArgsHandler
+	chunks []string
+	err    error
+}
+
+func (h *testLongDataHandler) HandleStmtLongData(context interface{}, paramID int, chunk []byte) error {
+	h.chunks = append(h.chunks, fmt.Sprintf("%d:%s", paramID, chunk))
+	return h.err
+}
+
+func TestStmtSendLongData(t *testing.T) {
+	// param 0 is sent as long data, param 1 inline
+	execute := stmtCommand(mysql.COM_STMT_EXECUTE, 1, mysql.CURSOR_TYPE_NO_CURSOR, 1, 0, 0, 0,
+		0, 1, mysql.MYSQL_TYPE_BLOB, 0, mysql.MYSQL_TYPE_VAR_STRING, 0, 1, 'x')
+	newConn := func(h Handler) *Conn {
+		c := &Conn{Conn: packet.NewConn(&mockconn.MockConn{}), h: h, stmts: make(map[uint32]*Stmt)}
+		c.stmts[1] = &Stmt{ID: 1, Params: 2}
+		c.stmts[1].ResetParams()
+		return c
+	}
+
+	// buffered in the args
+	h := &testArgsHandler{}
+	c := newConn(h)
+	require.Equal(t, noResponse{}, c.dispatch(stmtCommand(mysql.COM_STMT_SEND_LONG_DATA, 1, 0, 0, 'a', 'b')))
+	require.Equal(t, noResponse{}, c.dispatch(stmtCommand(mysql.COM_STMT_SEND_LONG_DATA, 1, 0, 0, 'c')))
+	require.Equal(t, &mysql.Result{}, c.dispatch(execute))
+	require.Equal(t, []interface{}{[]byte("abc"), []byte("x")}, h.args)
+
+	// streamed to the handler
+	lh := &testLongDataHandler{}
+	c = newConn(lh)
+	require.Equal(t, noResponse{}, c.dispatch(stmtCommand(mysql.COM_STMT_SEND_LONG_DATA, 1, 0, 0, 'a', 'b')))
+	require.Equal(t, noResponse{}, c.dispatch(stmtCommand(mysql.COM_STMT_SEND_LONG_DATA, 1, 0, 0, 'c')))
+	require.Equal(t, &mysql.Result{}, c.dispatch(execute))
+	require.Equal(t, []string{"0:ab", "0:c"}, lh.chunks)
+	require.Equal(t, []interface{}{nil, []byte("x")}, lh.args)
+
+	// the error of a chunk is returned by the next execution only
+	lh.err = mysql.NewDefaultError(mysql.ER_NET_PACKET_TOO_LARGE)
+	require.Equal(t, noResponse{}, c.dispatch(stmtCommand(mysql.COM_STMT_SEND_LONG_DATA, 1, 0, 0, 'd')))
+	require.Equal(t, lh.err, errors.Cause(c.dispatch(execute).(error)))
+	lh.err = nil
+	require.Equal(t, noResponse{}, c.dispatch(stmtCommand(mysql.COM_STMT_SEND_LONG_DATA, 1, 0, 0, 'e')))
+	require.Equal(t, &mysql.Result{}, c.dispatch(execute))
+}
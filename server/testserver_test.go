@@ -0,0 +1,91 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+// testServer serves the connections accepted by a listener with NewCustomizedConn until the listener is closed,
+// each in its own goroutine. All its members are optional.
+type testServer struct {
+	// svr is a MySQL 8.0 server with mysql_native_password by default
+	svr *Server
+	// p knows the user root with the password 123 by default
+	p CredentialProvider
+	// h handles the commands of all the connections, newHandler gives each connection its own handler instead,
+	// EmptyHandler by default
+	h          Handler
+	newHandler func() Handler
+	// onConn is called with a connection and its handler once its handshake is done, co is nil and err is set
+	// if it failed
+	onConn func(co *Conn, h Handler, err error)
+	// onClose is called with the error ending the commands of a connection
+	onClose func(co *Conn, err error)
+}
+
+// serveTestConns serves the connections of a local listener with h, see testServer, and returns its address.
+func serveTestConns(t *testing.T, svr *Server, p CredentialProvider, h Handler) string {
+	s := &testServer{svr: svr, p: p, h: h}
+	return s.listen(t)
+}
+
+// listen serves the connections of a local listener closed at the end of the test, and returns its address.
+func (s *testServer) listen(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	s.serve(l)
+	return l.Addr().String()
+}
+
+// serve serves the connections of l in the background.
+func (s *testServer) serve(l net.Listener) {
+	if s.svr == nil {
+		s.svr = NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	}
+	if s.p == nil {
+		p := NewInMemoryProvider()
+		p.AddUser("root", "123")
+		s.p = p
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serveConn(conn)
+		}
+	}()
+}
+
+func (s *testServer) serveConn(conn net.Conn) {
+	h := s.h
+	switch {
+	case s.newHandler != nil:
+		h = s.newHandler()
+	case h == nil:
+		h = EmptyHandler{}
+	}
+
+	co, err := NewCustomizedConn(conn, s.svr, s.p, h)
+	if s.onConn != nil {
+		s.onConn(co, h, err)
+	}
+	if err != nil {
+		return
+	}
+	for {
+		if err = co.HandleCommand(); err != nil {
+			break
+		}
+	}
+	if s.onClose != nil {
+		s.onClose(co, err)
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"time"
+)
+
+// Timeouts of a connection, zero values disable them.
+type Timeouts struct {
+	// Read bounds the time waiting for every packet of the client, including the next command of an idle
+	// connection, the connection is closed when it expires
+	Read time.Duration
+	// Write bounds the time writing every packet to the client, e.g. to a client not reading its resultset
+	Write time.Duration
+	// MaxExecution bounds the time spent handling a command, the context passed to a HandlerWithContext is
+	// then canceled and the client gets ER_QUERY_TIMEOUT if the command fails
+	MaxExecution time.Duration
+}
+
+// SetTimeouts sets the timeouts of the connections created with the server.
+func (s *Server) SetTimeouts(t Timeouts) {
+	s.timeouts = t
+}
+
+// SetTimeouts overrides the timeouts of the connection set by the server.
+func (c *Conn) SetTimeouts(t Timeouts) {
+	c.timeouts = t
+	c.Conn.ReadTimeout = t.Read
+	c.Conn.WriteTimeout = t.Write
+}
+
+// Timeouts returns the timeouts of the connection.
+func (c *Conn) Timeouts() Timeouts {
+	return c.timeouts
+}
+
+// startCommand makes Context return a context canceled once the max execution time of the command is exceeded
+// or the command is killed by KILL QUERY, the returned function must be called when the command is done.
+func (c *Conn) startCommand() (context.Context, context.CancelFunc) {
+	if c.ctx == nil {
+		return c.Context(), func() {}
+	}
+
+	connCtx := c.ctx
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if c.timeouts.MaxExecution > 0 {
+		ctx, cancel = context.WithTimeout(connCtx, c.timeouts.MaxExecution)
+	} else {
+		ctx, cancel = context.WithCancel(connCtx)
+	}
+	c.ctx = ctx
+	c.setCommandCancel(cancel)
+	return ctx, func() {
+		c.setCommandCancel(nil)
+		cancel()
+		c.ctx = connCtx
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+	"github.com/atoonk/go-mysql/packet"
+	"github.com/atoonk/go-mysql/replication"
+	mockconn "github.com/atoonk/go-mysql/test_util/conn"
+)
+
+type testBlockingHandler struct {
+	testContextHandler
+}
+
+func (h *testBlockingHandler) HandleQueryContext(ctx context.Context, query string) (*mysql.Result, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestMaxExecutionTime(t *testing.T) {
+	clientConn := &mockconn.MockConn{}
+	c := &Conn{Conn: packet.NewConn(clientConn), h: &testBlockingHandler{}, logger: mysql.NewDefaultLogger()}
+	c.initContext()
+	c.SetTimeouts(Timeouts{MaxExecution: 10 * time.Millisecond})
+
+	require.NoError(t, c.handlePacket(append([]byte{mysql.COM_QUERY}, "SELECT SLEEP(10)"...)))
+	require.Equal(t, mysql.ERR_HEADER, clientConn.WriteBuffered[4])
+	require.Equal(t, []byte{0xd0, 0x0b}, clientConn.WriteBuffered[5:7])
+
+	// the connection context is restored after the command
+	require.NoError(t, c.Context().Err())
+	_, ok := c.Context().Deadline()
+	require.False(t, ok)
+}
+
+func TestReadTimeout(t *testing.T) {
+	svr := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	svr.SetTimeouts(Timeouts{Read: 100 * time.Millisecond})
+
+	closed := make(chan error, 1)
+	s := &testServer{svr: svr}
+	s.onConn = func(_ *Conn, _ Handler, err error) {
+		if err != nil {
+			closed <- err
+		}
+	}
+	s.onClose = func(_ *Conn, err error) { closed <- err }
+	addr := s.listen(t)
+
+	c, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+	require.NoError(t, c.Ping())
+
+	select {
+	case err := <-closed:
+		require.ErrorContains(t, err, "i/o timeout")
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle connection not closed")
+	}
+	require.Error(t, c.Ping())
+}
+
+func TestMaxExecutionTimeBinlogDump(t *testing.T) {
+	clientConn := &mockconn.MockConn{MultiWrite: true}
+	s := replication.NewBinlogStreamer()
+	c := &Conn{Conn: packet.NewConn(clientConn), h: testBinlogHandler{s: s}, logger: mysql.NewDefaultLogger()}
+	c.SetCapability(mysql.CLIENT_PROTOCOL_41)
+	c.initContext()
+	c.SetTimeouts(Timeouts{MaxExecution: 10 * time.Millisecond})
+
+	// the events come well after the max execution time, the dump goes on
+	raw := make([]byte, 19)
+	raw[4] = byte(replication.QUERY_EVENT)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = s.AddEventToStreamer(&replication.BinlogEvent{RawData: raw})
+		s.AddErrorToStreamer(replication.ErrSyncClosed)
+	}()
+
+	data := append([]byte{mysql.COM_BINLOG_DUMP}, mysql.Uint32ToBytes(4)...)
+	data = append(data, 0, 0)
+	data = append(data, mysql.Uint32ToBytes(2)...)
+	require.NoError(t, c.handlePacket(append(data, "mysql-bin.000001"...)))
+	require.Equal(t, append([]byte{mysql.OK_HEADER}, raw...), clientConn.WriteBuffered[4:24])
+	require.Equal(t, byte(mysql.EOF_HEADER), clientConn.WriteBuffered[28])
+	require.False(t, c.Closed())
+}
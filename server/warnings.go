@@ -0,0 +1,93 @@
+package server
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+
+	. "github.com/atoonk/go-mysql/mysql"
+)
+
+var showWarningsRegexp = regexp.MustCompile(
+	`(?i)^\s*SHOW\s+(COUNT\s*\(\s*\*\s*\)\s+)?WARNINGS(?:\s+LIMIT\s+(?:(\d+)\s*,\s*)?(\d+))?\s*;?\s*$`)
+
+// clearWarnings forgets the warnings of the previous statement.
+func (c *Conn) clearWarnings() {
+	c.warnings = 0
+	c.warningList = nil
+}
+
+// recordWarnings stores the warnings of the result of a statement for SHOW WARNINGS, an error is stored as
+// a warning of level Error as MySQL does.
+func (c *Conn) recordWarnings(r *Result, err error) {
+	if err != nil {
+		var m *MyError
+		if !errors.As(err, &m) {
+			m = NewError(ER_UNKNOWN_ERROR, err.Error())
+		}
+		c.warningList = []Warning{{Level: "Error", Code: m.Code, Message: m.Message}}
+		return
+	}
+	if r == nil {
+		return
+	}
+
+	if len(r.WarningList) > 0 {
+		c.warningList = r.WarningList
+		if r.Warnings == 0 {
+			r.Warnings = uint16(len(r.WarningList))
+		}
+	}
+	if r.Warnings > 0 {
+		// the count of the EOF packets of a resultset
+		c.warnings = r.Warnings
+	}
+}
+
+// warningCount returns the number of warnings of the last statement.
+func (c *Conn) warningCount() int {
+	if len(c.warningList) > 0 {
+		return len(c.warningList)
+	}
+	return int(c.warnings)
+}
+
+// handleShowWarningsQuery answers SHOW WARNINGS [LIMIT [offset,] count] and SHOW COUNT(*) WARNINGS from the
+// warnings of the last statement, ok is false for the other queries.
+func (c *Conn) handleShowWarningsQuery(query string) (r *Result, ok bool, err error) {
+	m := showWarningsRegexp.FindStringSubmatch(query)
+	if m == nil {
+		return nil, false, nil
+	}
+
+	if m[1] != "" {
+		rs, err := BuildSimpleTextResultset([]string{"@@session.warning_count"}, [][]interface{}{{int64(c.warningCount())}})
+		if err != nil {
+			return nil, true, err
+		}
+		return &Result{Resultset: rs}, true, nil
+	}
+
+	warnings := c.warningList
+	if m[3] != "" {
+		offset, _ := strconv.Atoi(m[2])
+		count, _ := strconv.Atoi(m[3])
+		if offset > len(warnings) {
+			offset = len(warnings)
+		}
+		warnings = warnings[offset:]
+		if count < len(warnings) {
+			warnings = warnings[:count]
+		}
+	}
+
+	rows := make([][]interface{}, 0, len(warnings))
+	for _, w := range warnings {
+		rows = append(rows, []interface{}{w.Level, uint64(w.Code), w.Message})
+	}
+	rs, err := BuildSimpleTextResultset([]string{"Level", "Code", "Message"}, rows)
+	if err != nil {
+		return nil, true, err
+	}
+	return &Result{Resultset: rs}, true, nil
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/atoonk/go-mysql/client"
+	"github.com/atoonk/go-mysql/mysql"
+)
+
+type warningsHandler struct {
+	EmptyHandler
+}
+
+func (h warningsHandler) HandleQuery(query string) (*mysql.Result, error) {
+	switch query {
+	case "INSERT IGNORE INTO t VALUES (1), (1), (2)":
+		return &mysql.Result{AffectedRows: 2, WarningList: []mysql.Warning{
+			{Level: "Warning", Code: mysql.ER_DUP_ENTRY, Message: "Duplicate entry '1' for key 't.PRIMARY'"},
+			{Level: "Note", Code: 1051, Message: "Unknown table 'db.u'"},
+		}}, nil
+	case "SELECT 1":
+		return &mysql.Result{}, nil
+	}
+	return nil, mysql.NewDefaultError(mysql.ER_NO_SUCH_TABLE, "db", "t")
+}
+
+func (h warningsHandler) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	return 0, 0, nil, nil
+}
+
+func (h warningsHandler) HandleStmtExecute(context interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	return h.HandleQuery(query)
+}
+
+func TestShowWarnings(t *testing.T) {
+	addr := serveTestConns(t, nil, nil, warningsHandler{})
+
+	c, err := client.Connect(addr, "root", "123", "")
+	require.NoError(t, err)
+	defer c.Close()
+
+	r, err := c.Execute("INSERT IGNORE INTO t VALUES (1), (1), (2)")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, r.Warnings)
+
+	r, err = c.Execute("SHOW WARNINGS")
+	require.NoError(t, err)
+	require.Equal(t, 2, r.RowNumber())
+	level, _ := r.GetString(0, 0)
+	code, _ := r.GetInt(0, 1)
+	require.Equal(t, "Warning", level)
+	require.EqualValues(t, mysql.ER_DUP_ENTRY, code)
+
+	// SHOW WARNINGS does not clear them
+	r, err = c.Execute("show warnings limit 1, 5")
+	require.NoError(t, err)
+	require.Equal(t, 1, r.RowNumber())
+	level, _ = r.GetString(0, 0)
+	require.Equal(t, "Note", level)
+
+	r, err = c.Execute("SHOW COUNT(*) WARNINGS")
+	require.NoError(t, err)
+	count, _ := r.GetInt(0, 0)
+	require.EqualValues(t, 2, count)
+
+	// an error is shown as a warning of level Error
+	_, err = c.Execute("SELECT * FROM t")
+	require.Error(t, err)
+	r, err = c.Execute("SHOW WARNINGS")
+	require.NoError(t, err)
+	require.Equal(t, 1, r.RowNumber())
+	level, _ = r.GetString(0, 0)
+	code, _ = r.GetInt(0, 1)
+	require.Equal(t, "Error", level)
+	require.EqualValues(t, mysql.ER_NO_SUCH_TABLE, code)
+
+	// the next statement without warnings clears them, the executed statements record them too
+	_, err = c.Execute("SELECT 1")
+	require.NoError(t, err)
+	r, err = c.Execute("SHOW WARNINGS")
+	require.NoError(t, err)
+	require.Zero(t, r.RowNumber())
+
+	st, err := c.Prepare("INSERT IGNORE INTO t VALUES (1), (1), (2)")
+	require.NoError(t, err)
+	r, err = st.Execute()
+	require.NoError(t, err)
+	require.EqualValues(t, 2, r.Warnings)
+	r, err = c.Execute("SHOW COUNT(*) WARNINGS")
+	require.NoError(t, err)
+	count, _ = r.GetInt(0, 0)
+	require.EqualValues(t, 2, count)
+}
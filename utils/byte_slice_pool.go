@@ -24,7 +24,12 @@ func ByteSliceGet(length int) *ByteSlice {
 	return data
 }
 
+// ByteSlicePut returns a slice to the pool, unless it is larger than TooBigBlockSize so that the pool does not keep
+// the buffers of the largest packets.
 func ByteSlicePut(data *ByteSlice) {
+	if data == nil || cap(data.B) > TooBigBlockSize {
+		return
+	}
 	data.B = data.B[:0]
 	byteSlicePool.Put(data)
 }
@@ -1,6 +1,22 @@
 package utils
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestByteSlicePutTooBig(t *testing.T) {
+	// the slices larger than TooBigBlockSize are dropped rather than pooled, whatever their length
+	b := ByteSliceGet(TooBigBlockSize + 1)
+	b.B = b.B[:1]
+	ByteSlicePut(b)
+	require.Len(t, b.B, 1)
+
+	b = ByteSliceGet(16)
+	ByteSlicePut(b)
+	require.Empty(t, b.B)
+}
 
 func BenchmarkByteSlicePool(b *testing.B) {
 	b.ReportAllocs()